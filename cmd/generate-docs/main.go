@@ -112,6 +112,16 @@ func generateDirectoryStructure() string {
 	buf.WriteString("    └── <agent-name>.md\n")
 	buf.WriteString("```\n\n")
 
+	// XDG layout
+	buf.WriteString("### XDG Layout\n\n")
+	buf.WriteString("Setting `MULTICLAUDE_LAYOUT=xdg` splits the tree above across the XDG base directories:\n\n")
+	buf.WriteString("| Base | Contents |\n")
+	buf.WriteString("|------|----------|\n")
+	buf.WriteString("| `$XDG_STATE_HOME/multiclaude/` (`~/.local/state`) | Everything not listed below |\n")
+	buf.WriteString("| `$XDG_CONFIG_HOME/multiclaude/` (`~/.config`) | `tokens.json`, `claude-config/` |\n")
+	buf.WriteString("| `$XDG_CACHE_HOME/multiclaude/` (`~/.cache`) | `prompts/` |\n")
+	buf.WriteString("| `$XDG_RUNTIME_DIR/multiclaude/` (state directory if unset) | `daemon.sock` |\n\n")
+
 	// Generate detailed descriptions
 	docs := config.DirectoryDocs()
 	buf.WriteString("## Path Descriptions\n\n")
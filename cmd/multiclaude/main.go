@@ -10,7 +10,10 @@ import (
 
 func main() {
 	if err := run(); err != nil {
-		fmt.Fprintln(os.Stderr, errors.Format(err))
+		// In JSON mode the error has already been written to stdout
+		if _, reported := err.(*cli.ReportedError); !reported {
+			fmt.Fprintln(os.Stderr, errors.Format(err))
+		}
 		os.Exit(1)
 	}
 }
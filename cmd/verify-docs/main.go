@@ -221,8 +221,11 @@ func parseStateStructsFromCode() (map[string][]string, error) {
 		"Agent":            {},
 		"TaskHistoryEntry": {},
 		"MergeQueueConfig": {},
+		"MergeQueueEntry":  {},
 		"PRShepherdConfig": {},
 		"ForkConfig":       {},
+		"SpawnLimitConfig": {},
+		"ClaudeConfig":     {},
 	}
 
 	fset := token.NewFileSet()
@@ -2,11 +2,21 @@ package main
 
 import (
 	_ "embed"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
 
 	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsbackup"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscloudwatch"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscloudwatchactions"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsevents"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awssecretsmanager"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssns"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssnssubscriptions"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsssm"
 	"github.com/aws/constructs-go/constructs/v10"
 	"github.com/aws/jsii-runtime-go"
@@ -15,8 +25,321 @@ import (
 //go:embed user-data.sh
 var userData string
 
+//go:embed cloudwatch-agent.sh
+var cloudWatchAgentSetup string
+
+//go:embed spot-interruption.sh
+var spotInterruptionSetup string
+
+// Fallbacks for the original deployment, used only when neither CDK context
+// nor the CDK_DEFAULT_* environment variables name an account and region
+const (
+	fallbackAccount = "898769392027"
+	fallbackRegion  = "us-east-1"
+)
+
+// StackEnv resolves where to deploy. CDK context (cdk deploy -c account=...
+// -c region=...) wins, then the CDK_DEFAULT_ACCOUNT and CDK_DEFAULT_REGION
+// variables the CDK CLI sets from the active AWS profile.
+func StackEnv(scope constructs.Construct) *awscdk.Environment {
+	return &awscdk.Environment{
+		Account: jsii.String(lookup(scope, "account", "CDK_DEFAULT_ACCOUNT", fallbackAccount)),
+		Region:  jsii.String(lookup(scope, "region", "CDK_DEFAULT_REGION", fallbackRegion)),
+	}
+}
+
+// lookup reads a setting from CDK context, then the environment, then fallback
+func lookup(scope constructs.Construct, key, envVar, fallback string) string {
+	if v, ok := scope.Node().TryGetContext(jsii.String(key)).(string); ok && v != "" {
+		return v
+	}
+	if v := os.Getenv(envVar); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// Context keys for sizing the instance, e.g.
+// cdk deploy -c multiclaude:instanceType=t3.xlarge -c multiclaude:volumeSize=100
+const (
+	instanceTypeContext = "multiclaude:instanceType"
+	volumeSizeContext   = "multiclaude:volumeSize"
+
+	defaultInstanceType = "t3.medium"
+	defaultVolumeSize   = 50 // GiB
+)
+
+// instanceTypePattern matches EC2 instance type names like t3.xlarge or
+// m7i-flex.2xlarge
+var instanceTypePattern = regexp.MustCompile(`^[a-z][a-z0-9-]*\.[a-z0-9]+$`)
+
+// instanceType reads the instance type from context. Bad input is reported
+// as a synth error on stack and the default is used so synthesis can finish
+// collecting errors.
+func instanceType(stack awscdk.Stack) awsec2.InstanceType {
+	name := defaultInstanceType
+	if v := stack.Node().TryGetContext(jsii.String(instanceTypeContext)); v != nil {
+		s, ok := v.(string)
+		if ok && instanceTypePattern.MatchString(s) {
+			name = s
+		} else {
+			awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+				"invalid %s %v: want an EC2 instance type such as t3.xlarge", instanceTypeContext, v)))
+		}
+	}
+	return awsec2.NewInstanceType(jsii.String(name))
+}
+
+// volumeSize reads the root volume size in GiB from context
+func volumeSize(stack awscdk.Stack) float64 {
+	return contextWholeNumber(stack, volumeSizeContext, defaultVolumeSize, "GiB")
+}
+
+// contextWholeNumber reads a positive whole number from context. Values
+// passed with -c arrive as strings; values in cdk.json arrive as numbers.
+// Bad input is reported as a synth error on stack and fallback is used.
+func contextWholeNumber(stack awscdk.Stack, key string, fallback float64, unit string) float64 {
+	v := stack.Node().TryGetContext(jsii.String(key))
+	if v == nil {
+		return fallback
+	}
+
+	var n float64
+	var err error
+	switch v := v.(type) {
+	case float64:
+		n = v
+	case string:
+		n, err = strconv.ParseFloat(v, 64)
+	default:
+		err = fmt.Errorf("unsupported type %T", v)
+	}
+	if err != nil || n < 1 || n != float64(int64(n)) {
+		awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+			"invalid %s %v: want a whole number of %s", key, v, unit)))
+		return fallback
+	}
+	return n
+}
+
+// contextFlag reads an on/off setting from context, off when unset. Values
+// passed with -c arrive as strings; values in cdk.json arrive as booleans.
+func contextFlag(stack awscdk.Stack, key string) bool {
+	v := stack.Node().TryGetContext(jsii.String(key))
+	if v == nil {
+		return false
+	}
+
+	switch v := v.(type) {
+	case bool:
+		return v
+	case string:
+		if enabled, err := strconv.ParseBool(v); err == nil {
+			return enabled
+		}
+	}
+	awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+		"invalid %s %v: want true or false", key, v)))
+	return false
+}
+
+// Context keys for health alarms, e.g.
+// cdk deploy -c multiclaude:alarms=true -c multiclaude:alarmEmail=me@example.com
+const (
+	alarmsContext     = "multiclaude:alarms"
+	alarmEmailContext = "multiclaude:alarmEmail"
+
+	cpuAlarmThreshold  = 90 // percent
+	diskAlarmThreshold = 85 // percent of the root filesystem used
+)
+
+// emailPattern loosely matches an email address; SNS does the real checking
+// when it sends the confirmation
+var emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
+// addAlarms alarms on high CPU, failed status checks and a nearly full root
+// filesystem, notifying an SNS topic. The topic gets an email subscription
+// when alarmEmail is set in context; otherwise subscribe to it by hand.
+func addAlarms(stack awscdk.Stack, instance awsec2.Instance) awssns.Topic {
+	topic := awssns.NewTopic(stack, jsii.String("AlarmTopic"), &awssns.TopicProps{
+		DisplayName: jsii.String("multiclaude dev instance alarms"),
+	})
+
+	email, _ := stack.Node().TryGetContext(jsii.String(alarmEmailContext)).(string)
+	switch {
+	case email == "":
+		awscdk.Annotations_Of(stack).AddWarning(jsii.String(fmt.Sprintf(
+			"%s is not set; alarm notifications have no subscriber", alarmEmailContext)))
+	case !emailPattern.MatchString(email):
+		awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+			"invalid %s %s: want an email address", alarmEmailContext, email)))
+	default:
+		topic.AddSubscription(awssnssubscriptions.NewEmailSubscription(jsii.String(email), nil))
+	}
+
+	instanceDims := &map[string]*string{"InstanceId": instance.InstanceId()}
+	alarms := []awscloudwatch.Alarm{
+		awscloudwatch.NewMetric(&awscloudwatch.MetricProps{
+			Namespace:     jsii.String("AWS/EC2"),
+			MetricName:    jsii.String("CPUUtilization"),
+			DimensionsMap: instanceDims,
+			Statistic:     jsii.String("Average"),
+			Period:        awscdk.Duration_Minutes(jsii.Number(5)),
+		}).CreateAlarm(stack, jsii.String("HighCPUAlarm"), &awscloudwatch.CreateAlarmOptions{
+			AlarmDescription:   jsii.String(fmt.Sprintf("multiclaude-dev CPU above %d%% for 15 minutes", cpuAlarmThreshold)),
+			Threshold:          jsii.Number(cpuAlarmThreshold),
+			EvaluationPeriods:  jsii.Number(3),
+			ComparisonOperator: awscloudwatch.ComparisonOperator_GREATER_THAN_THRESHOLD,
+		}),
+		awscloudwatch.NewMetric(&awscloudwatch.MetricProps{
+			Namespace:     jsii.String("AWS/EC2"),
+			MetricName:    jsii.String("StatusCheckFailed"),
+			DimensionsMap: instanceDims,
+			Statistic:     jsii.String("Maximum"),
+			Period:        awscdk.Duration_Minutes(jsii.Number(1)),
+		}).CreateAlarm(stack, jsii.String("StatusCheckAlarm"), &awscloudwatch.CreateAlarmOptions{
+			AlarmDescription:   jsii.String("multiclaude-dev failed its EC2 status checks"),
+			Threshold:          jsii.Number(1),
+			EvaluationPeriods:  jsii.Number(2),
+			ComparisonOperator: awscloudwatch.ComparisonOperator_GREATER_THAN_OR_EQUAL_TO_THRESHOLD,
+		}),
+		// Published by the CloudWatch agent configured in cloudwatch-agent.sh
+		awscloudwatch.NewMetric(&awscloudwatch.MetricProps{
+			Namespace:  jsii.String("CWAgent"),
+			MetricName: jsii.String("disk_used_percent"),
+			DimensionsMap: &map[string]*string{
+				"InstanceId": instance.InstanceId(),
+				"path":       jsii.String("/"),
+				"fstype":     jsii.String("xfs"),
+			},
+			Statistic: jsii.String("Maximum"),
+			Period:    awscdk.Duration_Minutes(jsii.Number(5)),
+		}).CreateAlarm(stack, jsii.String("LowDiskAlarm"), &awscloudwatch.CreateAlarmOptions{
+			AlarmDescription:   jsii.String(fmt.Sprintf("multiclaude-dev root filesystem more than %d%% full", diskAlarmThreshold)),
+			Threshold:          jsii.Number(diskAlarmThreshold),
+			EvaluationPeriods:  jsii.Number(1),
+			ComparisonOperator: awscloudwatch.ComparisonOperator_GREATER_THAN_THRESHOLD,
+		}),
+	}
+
+	action := awscloudwatchactions.NewSnsAction(topic)
+	for _, alarm := range alarms {
+		alarm.AddAlarmAction(action)
+		alarm.AddOkAction(action)
+	}
+	return topic
+}
+
+// Context keys for daily snapshots of the instance, e.g.
+// cdk deploy -c multiclaude:snapshots=true -c multiclaude:snapshotRetentionDays=14
+const (
+	snapshotsContext             = "multiclaude:snapshots"
+	snapshotRetentionDaysContext = "multiclaude:snapshotRetentionDays"
+
+	defaultSnapshotRetentionDays = 7
+)
+
+// addSnapshots backs the instance up daily with AWS Backup, keeping each
+// recovery point for the configured number of days. Selecting the instance
+// rather than tagging its volume avoids replacing it when this is turned on.
+// EBS snapshots are always encrypted with the source volume's KMS key, so
+// the encrypted root volume's snapshots stay under the same key.
+func addSnapshots(stack awscdk.Stack, instance awsec2.Instance) awsbackup.BackupPlan {
+	retention := contextWholeNumber(stack, snapshotRetentionDaysContext, defaultSnapshotRetentionDays, "days")
+
+	// Retain the vault so deleting the stack doesn't fail on, or lose, the
+	// recovery points in it
+	vault := awsbackup.NewBackupVault(stack, jsii.String("SnapshotVault"), &awsbackup.BackupVaultProps{
+		BackupVaultName: jsii.String("multiclaude-dev"),
+		RemovalPolicy:   awscdk.RemovalPolicy_RETAIN,
+	})
+
+	plan := awsbackup.NewBackupPlan(stack, jsii.String("SnapshotPlan"), &awsbackup.BackupPlanProps{
+		BackupPlanName: jsii.String("multiclaude-dev-daily"),
+		BackupVault:    vault,
+		BackupPlanRules: &[]awsbackup.BackupPlanRule{
+			awsbackup.NewBackupPlanRule(&awsbackup.BackupPlanRuleProps{
+				RuleName: jsii.String("Daily"),
+				ScheduleExpression: awsevents.Schedule_Cron(&awsevents.CronOptions{
+					Hour:   jsii.String("5"),
+					Minute: jsii.String("0"),
+				}),
+				DeleteAfter: awscdk.Duration_Days(jsii.Number(retention)),
+			}),
+		},
+	})
+
+	plan.AddSelection(jsii.String("Instance"), &awsbackup.BackupSelectionOptions{
+		Resources: &[]awsbackup.BackupResource{
+			awsbackup.BackupResource_FromEc2Instance(instance),
+		},
+	})
+	return plan
+}
+
+// Context keys for running the instance on spot capacity, e.g.
+// cdk deploy -c multiclaude:spot=true -c multiclaude:spotMaxPrice=0.02
+const (
+	spotContext         = "multiclaude:spot"
+	spotMaxPriceContext = "multiclaude:spotMaxPrice"
+)
+
+// spotMaxPrice reads the highest hourly price in USD to pay for spot
+// capacity. Nil, the default, caps it at the on-demand price.
+func spotMaxPrice(stack awscdk.Stack) *float64 {
+	v := stack.Node().TryGetContext(jsii.String(spotMaxPriceContext))
+	if v == nil {
+		return nil
+	}
+
+	var price float64
+	var err error
+	switch v := v.(type) {
+	case float64:
+		price = v
+	case string:
+		price, err = strconv.ParseFloat(v, 64)
+	default:
+		err = fmt.Errorf("unsupported type %T", v)
+	}
+	if err != nil || price <= 0 {
+		awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+			"invalid %s %v: want a price in USD per hour such as 0.02", spotMaxPriceContext, v)))
+		return nil
+	}
+	return &price
+}
+
+// useSpot launches instance from a launch template holding only spot
+// options; its user data, role and security group stay on the instance.
+// The request is persistent and stops the instance on interruption, so the
+// EBS volume is kept and the instance starts again when capacity returns.
+// Turning this on or off replaces the instance.
+func useSpot(stack awscdk.Stack, instance awsec2.Instance) {
+	template := awsec2.NewLaunchTemplate(stack, jsii.String("SpotLaunchTemplate"), &awsec2.LaunchTemplateProps{
+		SpotOptions: &awsec2.LaunchTemplateSpotOptions{
+			RequestType:          awsec2.SpotRequestType_PERSISTENT,
+			InterruptionBehavior: awsec2.SpotInstanceInterruption_STOP,
+			MaxPrice:             spotMaxPrice(stack),
+		},
+	})
+	instance.Instance().SetLaunchTemplate(&awsec2.CfnInstance_LaunchTemplateSpecificationProperty{
+		LaunchTemplateId: template.LaunchTemplateId(),
+		Version:          template.LatestVersionNumber(),
+	})
+}
+
+// NewMulticlaudeStack defines the dev instance and its supporting resources.
+// When props carries no Env, the account and region come from StackEnv.
 func NewMulticlaudeStack(scope constructs.Construct, id string, props *awscdk.StackProps) awscdk.Stack {
-	stack := awscdk.NewStack(scope, &id, props)
+	var sprops awscdk.StackProps
+	if props != nil {
+		sprops = *props
+	}
+	if sprops.Env == nil {
+		sprops.Env = StackEnv(scope)
+	}
+	stack := awscdk.NewStack(scope, &id, &sprops)
 
 	// VPC (default)
 	vpc := awsec2.Vpc_FromLookup(stack, jsii.String("VPC"), &awsec2.VpcLookupOptions{
@@ -56,6 +379,18 @@ func NewMulticlaudeStack(scope constructs.Construct, id string, props *awscdk.St
 		},
 	})
 
+	// The CloudWatch agent needs to publish the disk metric the alarms read
+	alarms := contextFlag(stack, alarmsContext)
+	instanceUserData := userData
+	if alarms {
+		role.AddManagedPolicy(awsiam.ManagedPolicy_FromAwsManagedPolicyName(jsii.String("CloudWatchAgentServerPolicy")))
+		instanceUserData += cloudWatchAgentSetup
+	}
+	spot := contextFlag(stack, spotContext)
+	if spot {
+		instanceUserData += spotInterruptionSetup
+	}
+
 	// Grant secrets read access (nil for versionStages = all versions)
 	githubSecret.GrantRead(role, nil)
 	githubSSHKey.GrantRead(role, nil)
@@ -64,7 +399,7 @@ func NewMulticlaudeStack(scope constructs.Construct, id string, props *awscdk.St
 	// EC2 instance
 	instance := awsec2.NewInstance(stack, jsii.String("MulticlaudeInstance"), &awsec2.InstanceProps{
 		Vpc:           vpc,
-		InstanceType:  awsec2.InstanceType_Of(awsec2.InstanceClass_T3, awsec2.InstanceSize_MEDIUM),
+		InstanceType:  instanceType(stack),
 		MachineImage:  awsec2.MachineImage_LatestAmazonLinux2023(nil),
 		SecurityGroup: sg,
 		Role:          role,
@@ -72,15 +407,19 @@ func NewMulticlaudeStack(scope constructs.Construct, id string, props *awscdk.St
 		BlockDevices: &[]*awsec2.BlockDevice{
 			{
 				DeviceName: jsii.String("/dev/xvda"),
-				Volume: awsec2.BlockDeviceVolume_Ebs(jsii.Number(50), &awsec2.EbsDeviceOptions{
+				Volume: awsec2.BlockDeviceVolume_Ebs(jsii.Number(volumeSize(stack)), &awsec2.EbsDeviceOptions{
 					VolumeType: awsec2.EbsDeviceVolumeType_GP3,
 					Encrypted:  jsii.Bool(true),
 				}),
 			},
 		},
-		UserData: awsec2.UserData_Custom(jsii.String(userData)),
+		UserData: awsec2.UserData_Custom(jsii.String(instanceUserData)),
 	})
 
+	if spot {
+		useSpot(stack, instance)
+	}
+
 	// SSM document for deployments
 	awsssm.NewCfnDocument(stack, jsii.String("DeployDocument"), &awsssm.CfnDocumentProps{
 		Name:         jsii.String("multiclaude-deploy"),
@@ -190,6 +529,22 @@ func NewMulticlaudeStack(scope constructs.Construct, id string, props *awscdk.St
 		Description: jsii.String("ARN of Tailscale auth key secret"),
 	})
 
+	if contextFlag(stack, snapshotsContext) {
+		plan := addSnapshots(stack, instance)
+		awscdk.NewCfnOutput(stack, jsii.String("SnapshotPlanId"), &awscdk.CfnOutputProps{
+			Value:       plan.BackupPlanId(),
+			Description: jsii.String("AWS Backup plan taking daily snapshots of the instance"),
+		})
+	}
+
+	if alarms {
+		topic := addAlarms(stack, instance)
+		awscdk.NewCfnOutput(stack, jsii.String("AlarmTopicArn"), &awscdk.CfnOutputProps{
+			Value:       topic.TopicArn(),
+			Description: jsii.String("SNS topic notified by the instance health alarms"),
+		})
+	}
+
 	return stack
 }
 
@@ -198,12 +553,7 @@ func main() {
 
 	app := awscdk.NewApp(nil)
 
-	NewMulticlaudeStack(app, "MulticlaudeStack", &awscdk.StackProps{
-		Env: &awscdk.Environment{
-			Account: jsii.String("898769392027"),
-			Region:  jsii.String("us-east-1"),
-		},
-	})
+	NewMulticlaudeStack(app, "MulticlaudeStack", nil)
 
 	app.Synth(nil)
 }
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/assertions"
+	"github.com/aws/jsii-runtime-go"
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	jsii.Close()
+	os.Exit(code)
+}
+
+func TestStackEnvironment(t *testing.T) {
+	tests := []struct {
+		name        string
+		context     map[string]interface{}
+		envAccount  string
+		envRegion   string
+		wantAccount string
+		wantRegion  string
+	}{
+		{
+			name:        "context wins",
+			context:     map[string]interface{}{"account": "111111111111", "region": "eu-west-1"},
+			envAccount:  "222222222222",
+			envRegion:   "ap-southeast-2",
+			wantAccount: "111111111111",
+			wantRegion:  "eu-west-1",
+		},
+		{
+			name:        "environment variables",
+			envAccount:  "222222222222",
+			envRegion:   "ap-southeast-2",
+			wantAccount: "222222222222",
+			wantRegion:  "ap-southeast-2",
+		},
+		{
+			name:        "fallback",
+			wantAccount: fallbackAccount,
+			wantRegion:  fallbackRegion,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CDK_DEFAULT_ACCOUNT", tt.envAccount)
+			t.Setenv("CDK_DEFAULT_REGION", tt.envRegion)
+
+			app := awscdk.NewApp(&awscdk.AppProps{Context: &tt.context})
+			stack := NewMulticlaudeStack(app, "TestStack", nil)
+			app.Synth(nil)
+
+			if got := *stack.Account(); got != tt.wantAccount {
+				t.Errorf("Account = %q, want %q", got, tt.wantAccount)
+			}
+			if got := *stack.Region(); got != tt.wantRegion {
+				t.Errorf("Region = %q, want %q", got, tt.wantRegion)
+			}
+		})
+	}
+}
+
+func TestInstanceSizing(t *testing.T) {
+	app := awscdk.NewApp(&awscdk.AppProps{Context: &map[string]interface{}{
+		instanceTypeContext: "t3.xlarge",
+		volumeSizeContext:   "100",
+	}})
+	stack := NewMulticlaudeStack(app, "TestStack", nil)
+
+	template := assertions.Template_FromStack(stack, nil)
+	template.HasResourceProperties(jsii.String("AWS::EC2::Instance"), map[string]interface{}{
+		"InstanceType": "t3.xlarge",
+		"BlockDeviceMappings": []interface{}{
+			map[string]interface{}{
+				"DeviceName": "/dev/xvda",
+				"Ebs":        assertions.Match_ObjectLike(&map[string]interface{}{"VolumeSize": 100}),
+			},
+		},
+	})
+}
+
+func TestInstanceSizingDefaults(t *testing.T) {
+	app := awscdk.NewApp(nil)
+	stack := NewMulticlaudeStack(app, "TestStack", nil)
+
+	template := assertions.Template_FromStack(stack, nil)
+	template.HasResourceProperties(jsii.String("AWS::EC2::Instance"), map[string]interface{}{
+		"InstanceType": defaultInstanceType,
+		"BlockDeviceMappings": []interface{}{
+			map[string]interface{}{
+				"DeviceName": "/dev/xvda",
+				"Ebs":        assertions.Match_ObjectLike(&map[string]interface{}{"VolumeSize": defaultVolumeSize}),
+			},
+		},
+	})
+}
+
+func TestInstanceSizingRejectsBadInput(t *testing.T) {
+	app := awscdk.NewApp(&awscdk.AppProps{Context: &map[string]interface{}{
+		instanceTypeContext: "huge",
+		volumeSizeContext:   "lots",
+	}})
+	stack := NewMulticlaudeStack(app, "TestStack", nil)
+
+	annotations := assertions.Annotations_FromStack(stack)
+	annotations.HasError(jsii.String("*"), assertions.Match_StringLikeRegexp(jsii.String("invalid multiclaude:instanceType huge")))
+	annotations.HasError(jsii.String("*"), assertions.Match_StringLikeRegexp(jsii.String("invalid multiclaude:volumeSize lots")))
+}
+
+func TestAlarmsDisabledByDefault(t *testing.T) {
+	app := awscdk.NewApp(nil)
+	stack := NewMulticlaudeStack(app, "TestStack", nil)
+
+	template := assertions.Template_FromStack(stack, nil)
+	template.ResourceCountIs(jsii.String("AWS::CloudWatch::Alarm"), jsii.Number(0))
+	template.ResourceCountIs(jsii.String("AWS::SNS::Topic"), jsii.Number(0))
+}
+
+func TestAlarmsEnabled(t *testing.T) {
+	app := awscdk.NewApp(&awscdk.AppProps{Context: &map[string]interface{}{
+		alarmsContext:     "true",
+		alarmEmailContext: "dev@example.com",
+	}})
+	stack := NewMulticlaudeStack(app, "TestStack", nil)
+
+	template := assertions.Template_FromStack(stack, nil)
+	template.ResourceCountIs(jsii.String("AWS::CloudWatch::Alarm"), jsii.Number(3))
+	template.ResourceCountIs(jsii.String("AWS::SNS::Topic"), jsii.Number(1))
+	template.HasResourceProperties(jsii.String("AWS::SNS::Subscription"), map[string]interface{}{
+		"Protocol": "email",
+		"Endpoint": "dev@example.com",
+	})
+	for _, metric := range []string{"CPUUtilization", "StatusCheckFailed", "disk_used_percent"} {
+		template.HasResourceProperties(jsii.String("AWS::CloudWatch::Alarm"), map[string]interface{}{
+			"MetricName":   metric,
+			"AlarmActions": assertions.Match_AnyValue(),
+		})
+	}
+	template.HasOutput(jsii.String("AlarmTopicArn"), map[string]interface{}{})
+}
+
+func TestAlarmsRejectBadInput(t *testing.T) {
+	app := awscdk.NewApp(&awscdk.AppProps{Context: &map[string]interface{}{
+		alarmsContext: "sometimes",
+	}})
+	stack := NewMulticlaudeStack(app, "TestStack", nil)
+
+	annotations := assertions.Annotations_FromStack(stack)
+	annotations.HasError(jsii.String("*"), assertions.Match_StringLikeRegexp(jsii.String("invalid multiclaude:alarms sometimes")))
+}
+
+func TestSnapshotsDisabledByDefault(t *testing.T) {
+	app := awscdk.NewApp(nil)
+	stack := NewMulticlaudeStack(app, "TestStack", nil)
+
+	template := assertions.Template_FromStack(stack, nil)
+	template.ResourceCountIs(jsii.String("AWS::Backup::BackupPlan"), jsii.Number(0))
+	template.ResourceCountIs(jsii.String("AWS::Backup::BackupSelection"), jsii.Number(0))
+}
+
+func TestSnapshotsEnabled(t *testing.T) {
+	app := awscdk.NewApp(&awscdk.AppProps{Context: &map[string]interface{}{
+		snapshotsContext:             "true",
+		snapshotRetentionDaysContext: "14",
+	}})
+	stack := NewMulticlaudeStack(app, "TestStack", nil)
+
+	template := assertions.Template_FromStack(stack, nil)
+	template.ResourceCountIs(jsii.String("AWS::Backup::BackupVault"), jsii.Number(1))
+	template.HasResourceProperties(jsii.String("AWS::Backup::BackupPlan"), map[string]interface{}{
+		"BackupPlan": assertions.Match_ObjectLike(&map[string]interface{}{
+			"BackupPlanRule": []interface{}{
+				assertions.Match_ObjectLike(&map[string]interface{}{
+					"ScheduleExpression": "cron(0 5 * * ? *)",
+					"Lifecycle":          map[string]interface{}{"DeleteAfterDays": 14},
+				}),
+			},
+		}),
+	})
+	template.ResourceCountIs(jsii.String("AWS::Backup::BackupSelection"), jsii.Number(1))
+}
+
+func TestSnapshotsRejectBadRetention(t *testing.T) {
+	app := awscdk.NewApp(&awscdk.AppProps{Context: &map[string]interface{}{
+		snapshotsContext:             true,
+		snapshotRetentionDaysContext: "forever",
+	}})
+	stack := NewMulticlaudeStack(app, "TestStack", nil)
+
+	annotations := assertions.Annotations_FromStack(stack)
+	annotations.HasError(jsii.String("*"), assertions.Match_StringLikeRegexp(jsii.String("invalid multiclaude:snapshotRetentionDays forever")))
+}
+
+func TestSpotDisabledByDefault(t *testing.T) {
+	app := awscdk.NewApp(nil)
+	stack := NewMulticlaudeStack(app, "TestStack", nil)
+
+	template := assertions.Template_FromStack(stack, nil)
+	template.ResourceCountIs(jsii.String("AWS::EC2::LaunchTemplate"), jsii.Number(0))
+	template.HasResourceProperties(jsii.String("AWS::EC2::Instance"), map[string]interface{}{
+		"LaunchTemplate": assertions.Match_Absent(),
+	})
+}
+
+func TestSpotEnabled(t *testing.T) {
+	app := awscdk.NewApp(&awscdk.AppProps{Context: &map[string]interface{}{
+		spotContext:         "true",
+		spotMaxPriceContext: "0.02",
+	}})
+	stack := NewMulticlaudeStack(app, "TestStack", nil)
+
+	template := assertions.Template_FromStack(stack, nil)
+	template.HasResourceProperties(jsii.String("AWS::EC2::LaunchTemplate"), map[string]interface{}{
+		"LaunchTemplateData": assertions.Match_ObjectLike(&map[string]interface{}{
+			"InstanceMarketOptions": map[string]interface{}{
+				"MarketType": "spot",
+				"SpotOptions": map[string]interface{}{
+					"SpotInstanceType":             "persistent",
+					"InstanceInterruptionBehavior": "stop",
+					"MaxPrice":                     "0.02",
+				},
+			},
+		}),
+	})
+	// The instance keeps its own role, security group and user data, which
+	// gains the interruption watcher
+	template.HasResourceProperties(jsii.String("AWS::EC2::Instance"), map[string]interface{}{
+		"LaunchTemplate":     assertions.Match_ObjectLike(&map[string]interface{}{"LaunchTemplateId": assertions.Match_AnyValue()}),
+		"IamInstanceProfile": assertions.Match_AnyValue(),
+		"SecurityGroupIds":   assertions.Match_AnyValue(),
+		"UserData": map[string]interface{}{
+			"Fn::Base64": assertions.Match_StringLikeRegexp(jsii.String("spot-interruption-watch")),
+		},
+	})
+}
+
+func TestSpotRejectsBadMaxPrice(t *testing.T) {
+	app := awscdk.NewApp(&awscdk.AppProps{Context: &map[string]interface{}{
+		spotContext:         "true",
+		spotMaxPriceContext: "cheap",
+	}})
+	stack := NewMulticlaudeStack(app, "TestStack", nil)
+
+	annotations := assertions.Annotations_FromStack(stack)
+	annotations.HasError(jsii.String("*"), assertions.Match_StringLikeRegexp(jsii.String("invalid multiclaude:spotMaxPrice cheap")))
+}
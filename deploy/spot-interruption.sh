@@ -0,0 +1,60 @@
+
+# Spot interruption handling - appended to user-data.sh when multiclaude:spot
+# is set. The spot request stops the instance rather than terminating it, so
+# the EBS volume (repos, worktrees, state and ~/.claude) survives. A watcher
+# polls instance metadata for the two-minute interruption notice and stops
+# multiclaude cleanly before the instance goes down.
+#
+# After an interruption the instance starts again once capacity returns:
+# - Claude credentials live in ~/.claude on the volume, so there is no new
+#   device login.
+# - tailscaled reconnects on its own. If the node was ephemeral and has been
+#   removed from the tailnet, connect with SSM (aws ssm start-session) and run
+#   ./fetch-secrets.sh, or tailscale up --authkey=<key> --hostname=multiclaude-dev --ssh
+# - Start the daemon again with: systemctl --user start multiclaude-daemon
+echo "=== Installing spot interruption watcher ==="
+
+cat > /usr/local/bin/spot-interruption-watch << 'EOF'
+#!/bin/bash
+set -u
+
+# imds reads an instance metadata path with an IMDSv2 session token
+imds() {
+    local token
+    token=$(curl -sf -X PUT http://169.254.169.254/latest/api/token \
+        -H "X-aws-ec2-metadata-token-ttl-seconds: 60") || return 1
+    curl -sf -H "X-aws-ec2-metadata-token: $token" "http://169.254.169.254/latest/meta-data/$1"
+}
+
+while true; do
+    # 404 until AWS schedules an interruption
+    if action=$(imds spot/instance-action); then
+        logger -t spot-interruption "interruption notice: $action"
+        wall "Spot interruption notice ($action). Stopping multiclaude; this instance will stop shortly."
+        sudo -iu dev env XDG_RUNTIME_DIR="/run/user/$(id -u dev)" bash -c \
+            '~/go/bin/multiclaude daemon drain; systemctl --user stop multiclaude-daemon' || true
+        exit 0
+    fi
+    sleep 5
+done
+EOF
+chmod +x /usr/local/bin/spot-interruption-watch
+
+cat > /etc/systemd/system/spot-interruption-watch.service << 'EOF'
+[Unit]
+Description=Stop multiclaude cleanly on a spot interruption notice
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+ExecStart=/usr/local/bin/spot-interruption-watch
+Restart=on-failure
+RestartSec=5
+
+[Install]
+WantedBy=multi-user.target
+EOF
+
+systemctl daemon-reload
+systemctl enable --now spot-interruption-watch
@@ -0,0 +1,170 @@
+// Package agent manages the runtime lifecycle of agents tracked in state:
+// stopping their processes, closing their tmux windows, and updating state.
+//
+// Agent definitions (the markdown prompt templates) live in internal/agents;
+// this package operates on agents that are already running.
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+
+	"github.com/dlorenc/multiclaude/internal/fork"
+	"github.com/dlorenc/multiclaude/internal/procstat"
+	"github.com/dlorenc/multiclaude/internal/state"
+)
+
+// DefaultGracePeriod is how long Kill waits after SIGTERM before escalating to SIGKILL
+const DefaultGracePeriod = 10 * time.Second
+
+// killWaitTimeout bounds how long Kill waits for a process to exit after SIGKILL
+const killWaitTimeout = 2 * time.Second
+
+// TmuxClient is the subset of tmux operations used to manage agent windows.
+// *tmux.Client satisfies this interface.
+type TmuxClient interface {
+	HasWindow(ctx context.Context, session, windowName string) (bool, error)
+	KillWindow(ctx context.Context, session, windowName string) error
+}
+
+// Manager performs lifecycle operations on agents tracked in state
+type Manager struct {
+	state *state.State
+	tmux  TmuxClient
+
+	// signal and alive are swappable so tests can observe escalation
+	signal       func(pid int, sig syscall.Signal) error
+	alive        func(pid int) bool
+	pollInterval time.Duration
+
+	// divergence summarizes a review branch against its target
+	divergence func(repoPath, base, head string) (*fork.Divergence, error)
+}
+
+// NewManager creates a new agent lifecycle manager
+func NewManager(st *state.State, tmux TmuxClient) *Manager {
+	return &Manager{
+		state:        st,
+		tmux:         tmux,
+		signal:       syscall.Kill,
+		alive:        procstat.IsAlive,
+		pollInterval: 50 * time.Millisecond,
+		divergence:   fork.GetDivergence,
+	}
+}
+
+// KillOptions controls how Kill stops an agent
+type KillOptions struct {
+	// Grace is how long to wait after SIGTERM before sending SIGKILL
+	Grace time.Duration
+
+	// DryRun returns the actions Kill would take without performing them
+	DryRun bool
+}
+
+// Kill gracefully stops an agent. It sends SIGTERM to the agent's PID, waits up
+// to opts.Grace for the process to exit, and escalates to SIGKILL if it is
+// still running. It then closes the agent's tmux window and marks the agent
+// ReadyForCleanup so the health check can record history and remove it.
+// It returns the actions taken, or with DryRun the actions it would take.
+//
+// Kill is idempotent: an agent whose process or window is already gone is
+// simply marked ReadyForCleanup.
+func (m *Manager) Kill(repoName, agentName string, opts KillOptions) ([]string, error) {
+	agent, exists := m.state.GetAgent(repoName, agentName)
+	if !exists {
+		return nil, fmt.Errorf("agent %q not found in repository %q", agentName, repoName)
+	}
+
+	repo, exists := m.state.GetRepo(repoName)
+	if !exists {
+		return nil, fmt.Errorf("repository %q not found", repoName)
+	}
+
+	var actions []string
+
+	if agent.PID > 0 && m.alive(agent.PID) {
+		actions = append(actions, fmt.Sprintf("stop process %d (SIGTERM, then SIGKILL after %s)", agent.PID, opts.Grace))
+		if !opts.DryRun {
+			if err := m.stopProcess(agent.PID, opts.Grace); err != nil {
+				return actions, fmt.Errorf("failed to stop agent %s (PID %d): %w", agentName, agent.PID, err)
+			}
+		}
+	}
+
+	ctx := context.Background()
+	if hasWindow, err := m.tmux.HasWindow(ctx, repo.TmuxSession, agent.TmuxWindow); err == nil && hasWindow {
+		actions = append(actions, fmt.Sprintf("close tmux window %s:%s", repo.TmuxSession, agent.TmuxWindow))
+		if !opts.DryRun {
+			if err := m.tmux.KillWindow(ctx, repo.TmuxSession, agent.TmuxWindow); err != nil {
+				return actions, fmt.Errorf("failed to kill tmux window %s: %w", agent.TmuxWindow, err)
+			}
+		}
+	}
+
+	if agent.ReadyForCleanup {
+		return actions, nil
+	}
+
+	actions = append(actions, fmt.Sprintf("mark %s/%s ready for cleanup", repoName, agentName))
+	if opts.DryRun {
+		return actions, nil
+	}
+
+	agent.ReadyForCleanup = true
+	if err := m.state.UpdateAgent(repoName, agentName, agent); err != nil {
+		return actions, fmt.Errorf("failed to mark agent for cleanup: %w", err)
+	}
+
+	return actions, nil
+}
+
+// stopProcess sends SIGTERM, waits up to grace, then escalates to SIGKILL.
+// A process that is already gone is not an error.
+func (m *Manager) stopProcess(pid int, grace time.Duration) error {
+	if !m.alive(pid) {
+		return nil
+	}
+
+	if err := m.signal(pid, syscall.SIGTERM); err != nil {
+		if errors.Is(err, syscall.ESRCH) {
+			return nil
+		}
+		return fmt.Errorf("failed to send SIGTERM: %w", err)
+	}
+
+	if m.waitForExit(pid, grace) {
+		return nil
+	}
+
+	if err := m.signal(pid, syscall.SIGKILL); err != nil {
+		if errors.Is(err, syscall.ESRCH) {
+			return nil
+		}
+		return fmt.Errorf("failed to send SIGKILL: %w", err)
+	}
+
+	if !m.waitForExit(pid, killWaitTimeout) {
+		return fmt.Errorf("process still running after SIGKILL")
+	}
+
+	return nil
+}
+
+// waitForExit polls until the process exits or the timeout elapses.
+// Returns true if the process exited.
+func (m *Manager) waitForExit(pid int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if !m.alive(pid) {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(m.pollInterval)
+	}
+}
@@ -0,0 +1,229 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/dlorenc/multiclaude/internal/procstat"
+	"github.com/dlorenc/multiclaude/internal/state"
+)
+
+// fakeTmux records window operations instead of invoking tmux
+type fakeTmux struct {
+	windows map[string]bool
+	killed  []string
+}
+
+func (f *fakeTmux) HasWindow(ctx context.Context, session, windowName string) (bool, error) {
+	return f.windows[windowName], nil
+}
+
+func (f *fakeTmux) KillWindow(ctx context.Context, session, windowName string) error {
+	delete(f.windows, windowName)
+	f.killed = append(f.killed, windowName)
+	return nil
+}
+
+// signalRecorder wraps syscall.Kill and records which signals were sent
+type signalRecorder struct {
+	mu      sync.Mutex
+	signals []syscall.Signal
+}
+
+func (r *signalRecorder) send(pid int, sig syscall.Signal) error {
+	r.mu.Lock()
+	r.signals = append(r.signals, sig)
+	r.mu.Unlock()
+	return syscall.Kill(pid, sig)
+}
+
+func (r *signalRecorder) sent() []syscall.Signal {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]syscall.Signal(nil), r.signals...)
+}
+
+func setupState(t *testing.T, pid int) *state.State {
+	t.Helper()
+	st := state.New(filepath.Join(t.TempDir(), "state.json"))
+	if err := st.AddRepo("repo", &state.Repository{
+		GithubURL:   "https://github.com/test/repo",
+		TmuxSession: "mc-repo",
+		Agents:      make(map[string]state.Agent),
+	}); err != nil {
+		t.Fatalf("AddRepo() failed: %v", err)
+	}
+	if err := st.AddAgent("repo", "worker1", state.Agent{
+		Type:       state.AgentTypeWorker,
+		TmuxWindow: "worker1",
+		PID:        pid,
+		CreatedAt:  time.Now(),
+	}); err != nil {
+		t.Fatalf("AddAgent() failed: %v", err)
+	}
+	return st
+}
+
+// startStub starts a child process and reaps it in the background so that a
+// killed process disappears instead of lingering as a zombie.
+func startStub(t *testing.T, script string) int {
+	t.Helper()
+	cmd := exec.Command("sh", "-c", script)
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start stub process: %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		<-done
+	})
+	return cmd.Process.Pid
+}
+
+func newTestManager(st *state.State, tmux TmuxClient, rec *signalRecorder) *Manager {
+	m := NewManager(st, tmux)
+	m.signal = rec.send
+	m.pollInterval = 10 * time.Millisecond
+	return m
+}
+
+func TestKillEscalatesToSIGKILL(t *testing.T) {
+	// The stub ignores SIGTERM, so Kill must escalate once the grace period ends
+	pid := startStub(t, `trap "" TERM; while :; do sleep 1; done`)
+	// Give the shell a moment to install the trap
+	time.Sleep(100 * time.Millisecond)
+
+	st := setupState(t, pid)
+	tmux := &fakeTmux{windows: map[string]bool{"worker1": true}}
+	rec := &signalRecorder{}
+	m := newTestManager(st, tmux, rec)
+
+	if _, err := m.Kill("repo", "worker1", KillOptions{Grace: 200 * time.Millisecond}); err != nil {
+		t.Fatalf("Kill() failed: %v", err)
+	}
+
+	signals := rec.sent()
+	if len(signals) != 2 || signals[0] != syscall.SIGTERM || signals[1] != syscall.SIGKILL {
+		t.Errorf("signals sent = %v, want [SIGTERM SIGKILL]", signals)
+	}
+	if procstat.IsAlive(pid) {
+		t.Error("process should not be running after Kill()")
+	}
+	if len(tmux.killed) != 1 || tmux.killed[0] != "worker1" {
+		t.Errorf("killed windows = %v, want [worker1]", tmux.killed)
+	}
+
+	agent, _ := st.GetAgent("repo", "worker1")
+	if !agent.ReadyForCleanup {
+		t.Error("agent should be marked ReadyForCleanup")
+	}
+}
+
+func TestKillGraceful(t *testing.T) {
+	pid := startStub(t, `sleep 30`)
+
+	st := setupState(t, pid)
+	tmux := &fakeTmux{windows: map[string]bool{"worker1": true}}
+	rec := &signalRecorder{}
+	m := newTestManager(st, tmux, rec)
+
+	if _, err := m.Kill("repo", "worker1", KillOptions{Grace: 5 * time.Second}); err != nil {
+		t.Fatalf("Kill() failed: %v", err)
+	}
+
+	signals := rec.sent()
+	if len(signals) != 1 || signals[0] != syscall.SIGTERM {
+		t.Errorf("signals sent = %v, want [SIGTERM]", signals)
+	}
+}
+
+func TestKillIdempotent(t *testing.T) {
+	// PID that has already exited and a window that no longer exists
+	pid := startStub(t, `exit 0`)
+	deadline := time.Now().Add(2 * time.Second)
+	for procstat.IsAlive(pid) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	st := setupState(t, pid)
+	tmux := &fakeTmux{windows: map[string]bool{}}
+	rec := &signalRecorder{}
+	m := newTestManager(st, tmux, rec)
+
+	for i := 0; i < 2; i++ {
+		if _, err := m.Kill("repo", "worker1", KillOptions{Grace: time.Second}); err != nil {
+			t.Fatalf("Kill() call %d failed: %v", i+1, err)
+		}
+	}
+
+	if signals := rec.sent(); len(signals) != 0 {
+		t.Errorf("signals sent = %v, want none for exited process", signals)
+	}
+	if len(tmux.killed) != 0 {
+		t.Errorf("killed windows = %v, want none", tmux.killed)
+	}
+
+	agent, _ := st.GetAgent("repo", "worker1")
+	if !agent.ReadyForCleanup {
+		t.Error("agent should be marked ReadyForCleanup")
+	}
+}
+
+func TestKillDryRun(t *testing.T) {
+	pid := startStub(t, `sleep 30`)
+
+	st := setupState(t, pid)
+	tmux := &fakeTmux{windows: map[string]bool{"worker1": true}}
+	rec := &signalRecorder{}
+	m := newTestManager(st, tmux, rec)
+
+	actions, err := m.Kill("repo", "worker1", KillOptions{Grace: time.Second, DryRun: true})
+	if err != nil {
+		t.Fatalf("Kill() dry run failed: %v", err)
+	}
+	if len(actions) != 3 {
+		t.Fatalf("actions = %v, want stop process, close window, mark for cleanup", actions)
+	}
+	for i, want := range []string{fmt.Sprintf("stop process %d", pid), "close tmux window", "ready for cleanup"} {
+		if !strings.Contains(actions[i], want) {
+			t.Errorf("actions[%d] = %q, want it to mention %q", i, actions[i], want)
+		}
+	}
+
+	// Nothing happens
+	if signals := rec.sent(); len(signals) != 0 {
+		t.Errorf("signals sent = %v, want none in dry run", signals)
+	}
+	if !procstat.IsAlive(pid) {
+		t.Error("process should still be running after a dry run")
+	}
+	if len(tmux.killed) != 0 {
+		t.Errorf("killed windows = %v, want none in dry run", tmux.killed)
+	}
+	if agent, _ := st.GetAgent("repo", "worker1"); agent.ReadyForCleanup {
+		t.Error("dry run should not mark the agent ReadyForCleanup")
+	}
+}
+
+func TestKillUnknownAgent(t *testing.T) {
+	st := setupState(t, 0)
+	m := NewManager(st, &fakeTmux{})
+
+	if _, err := m.Kill("repo", "nobody", KillOptions{Grace: time.Second}); err == nil {
+		t.Error("Kill() should fail for unknown agent")
+	}
+	if _, err := m.Kill("missing", "worker1", KillOptions{Grace: time.Second}); err == nil {
+		t.Error("Kill() should fail for unknown repo")
+	}
+}
@@ -0,0 +1,118 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dlorenc/multiclaude/internal/state"
+	"github.com/dlorenc/multiclaude/internal/worktree"
+)
+
+// RemoveOptions controls what Remove cleans up besides the state entry
+type RemoveOptions struct {
+	// RepoPath is the main repository clone the agent's worktree belongs to
+	RepoPath string
+
+	// Force removes a worktree with uncommitted changes or unpushed commits
+	// and deletes its branch even if it is unmerged
+	Force bool
+
+	// DryRun returns the actions Remove would take without performing them.
+	// The dirty and unpushed checks still run, so a dry run fails exactly
+	// when the real removal would.
+	DryRun bool
+}
+
+// Remove deletes an agent from state and removes its git worktree. Without
+// Force, a worktree with uncommitted changes or unpushed commits is left in
+// place and the agent is not removed. The agent's branch is deleted only if
+// it is fully merged, unless Force is set. It returns the actions taken, or
+// with DryRun the actions it would take.
+//
+// Agents that run in the main repository (persistent agents) have no
+// worktree of their own, so only their state entry is removed.
+func (m *Manager) Remove(repoName, agentName string, opts RemoveOptions) ([]string, error) {
+	agent, exists := m.state.GetAgent(repoName, agentName)
+	if !exists {
+		return nil, fmt.Errorf("%w: %q in repository %q", state.ErrAgentNotFound, agentName, repoName)
+	}
+
+	var actions []string
+	if ownsWorktree(agent.WorktreePath, opts.RepoPath) {
+		worktreeActions, err := removeWorktree(agent.WorktreePath, opts)
+		actions = append(actions, worktreeActions...)
+		if err != nil {
+			return actions, err
+		}
+	}
+
+	actions = append(actions, fmt.Sprintf("remove agent %s/%s from state", repoName, agentName))
+	if opts.DryRun {
+		return actions, nil
+	}
+	if err := m.state.RemoveAgent(repoName, agentName); err != nil {
+		return actions, fmt.Errorf("failed to remove agent from state: %w", err)
+	}
+	return actions, nil
+}
+
+// ownsWorktree reports whether path is a separate worktree that can be removed
+func ownsWorktree(path, repoPath string) bool {
+	if path == "" || repoPath == "" {
+		return false
+	}
+	if filepath.Clean(path) == filepath.Clean(repoPath) {
+		return false
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// removeWorktree removes the worktree at path and prunes its branch
+func removeWorktree(path string, opts RemoveOptions) ([]string, error) {
+	if !opts.Force {
+		if dirty, err := worktree.HasUncommittedChanges(path); err != nil {
+			return nil, err
+		} else if dirty {
+			return nil, fmt.Errorf("worktree %s has uncommitted changes (use force to remove anyway)", path)
+		}
+		if unpushed, err := worktree.HasUnpushedCommits(path); err != nil {
+			return nil, err
+		} else if unpushed {
+			return nil, fmt.Errorf("worktree %s has unpushed commits (use force to remove anyway)", path)
+		}
+	}
+
+	// Read the branch before the worktree goes away
+	branch, _ := worktree.GetCurrentBranch(path)
+
+	actions := []string{"remove worktree " + path}
+	if branch != "" && branch != "HEAD" {
+		if opts.Force {
+			actions = append(actions, "delete branch "+branch)
+		} else {
+			actions = append(actions, "delete branch "+branch+" if merged")
+		}
+	}
+	if opts.DryRun {
+		return actions, nil
+	}
+
+	wt := worktree.NewManager(opts.RepoPath)
+	if err := wt.Remove(path, opts.Force); err != nil {
+		return nil, fmt.Errorf("failed to remove worktree: %w", err)
+	}
+
+	if branch == "" || branch == "HEAD" {
+		return actions, nil
+	}
+	if opts.Force {
+		// Branch cleanup is best-effort; the worktree itself is gone
+		_ = wt.DeleteBranch(branch)
+	} else {
+		// An unmerged branch is kept so no work is lost
+		_ = wt.DeleteMergedBranch(branch)
+	}
+	return actions, nil
+}
@@ -0,0 +1,187 @@
+package agent
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dlorenc/multiclaude/internal/state"
+)
+
+// initRepoWithWorktree creates a git repo and a worktree on a new branch
+func initRepoWithWorktree(t *testing.T, branch string) (repoPath, wtPath string) {
+	t.Helper()
+	if exec.Command("git", "version").Run() != nil {
+		t.Skip("git not available")
+	}
+
+	dir := t.TempDir()
+	repoPath = filepath.Join(dir, "repo")
+	wtPath = filepath.Join(dir, "wts", "worker")
+	if err := os.MkdirAll(repoPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repoPath
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	run("init", "-b", "main")
+	run("config", "user.name", "Test User")
+	run("config", "user.email", "test@example.com")
+	if err := os.WriteFile(filepath.Join(repoPath, "README.md"), []byte("# Test\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run("add", "README.md")
+	run("commit", "-m", "Initial commit")
+	run("worktree", "add", "-b", branch, wtPath, "main")
+	return repoPath, wtPath
+}
+
+func newRemoveTestManager(t *testing.T, wtPath string) (*Manager, *state.State) {
+	t.Helper()
+	st := state.New(filepath.Join(t.TempDir(), "state.json"))
+	if err := st.AddRepo("repo", &state.Repository{
+		TmuxSession: "mc-repo",
+		Agents:      make(map[string]state.Agent),
+	}); err != nil {
+		t.Fatalf("AddRepo() failed: %v", err)
+	}
+	if err := st.AddAgent("repo", "worker", state.Agent{
+		Type:         state.AgentTypeWorker,
+		WorktreePath: wtPath,
+		TmuxWindow:   "worker",
+	}); err != nil {
+		t.Fatalf("AddAgent() failed: %v", err)
+	}
+	return NewManager(st, &fakeTmux{}), st
+}
+
+func branchExists(t *testing.T, repoPath, branch string) bool {
+	t.Helper()
+	cmd := exec.Command("git", "show-ref", "--verify", "--quiet", "refs/heads/"+branch)
+	cmd.Dir = repoPath
+	return cmd.Run() == nil
+}
+
+func TestRemoveDeletesWorktree(t *testing.T) {
+	repoPath, wtPath := initRepoWithWorktree(t, "work/worker")
+	m, st := newRemoveTestManager(t, wtPath)
+
+	if _, err := m.Remove("repo", "worker", RemoveOptions{RepoPath: repoPath}); err != nil {
+		t.Fatalf("Remove() failed: %v", err)
+	}
+
+	if _, err := os.Stat(wtPath); !os.IsNotExist(err) {
+		t.Errorf("worktree directory still exists: %v", err)
+	}
+	if _, exists := st.GetAgent("repo", "worker"); exists {
+		t.Error("agent still in state")
+	}
+	// The branch had no new commits, so it is merged and safe to delete
+	if branchExists(t, repoPath, "work/worker") {
+		t.Error("merged branch was not deleted")
+	}
+}
+
+func TestRemoveDryRun(t *testing.T) {
+	repoPath, wtPath := initRepoWithWorktree(t, "work/worker")
+	m, st := newRemoveTestManager(t, wtPath)
+
+	actions, err := m.Remove("repo", "worker", RemoveOptions{RepoPath: repoPath, DryRun: true})
+	if err != nil {
+		t.Fatalf("Remove() dry run failed: %v", err)
+	}
+	want := []string{
+		"remove worktree " + wtPath,
+		"delete branch work/worker if merged",
+		"remove agent repo/worker from state",
+	}
+	if !reflect.DeepEqual(actions, want) {
+		t.Errorf("actions = %q, want %q", actions, want)
+	}
+
+	// Nothing is removed
+	if _, err := os.Stat(wtPath); err != nil {
+		t.Errorf("dry run should keep the worktree: %v", err)
+	}
+	if !branchExists(t, repoPath, "work/worker") {
+		t.Error("dry run should keep the branch")
+	}
+	if _, exists := st.GetAgent("repo", "worker"); !exists {
+		t.Error("dry run should keep the agent in state")
+	}
+
+	// The safety checks still apply
+	if err := os.WriteFile(filepath.Join(wtPath, "wip.txt"), []byte("wip\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Remove("repo", "worker", RemoveOptions{RepoPath: repoPath, DryRun: true}); err == nil {
+		t.Error("dry run should fail when the real removal would")
+	}
+}
+
+func TestRemoveRefusesDirtyWorktree(t *testing.T) {
+	repoPath, wtPath := initRepoWithWorktree(t, "work/worker")
+	m, st := newRemoveTestManager(t, wtPath)
+
+	if err := os.WriteFile(filepath.Join(wtPath, "wip.txt"), []byte("wip\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Remove("repo", "worker", RemoveOptions{RepoPath: repoPath}); err == nil {
+		t.Fatal("Remove() should refuse a worktree with uncommitted changes")
+	}
+	if _, err := os.Stat(wtPath); err != nil {
+		t.Errorf("worktree should be kept: %v", err)
+	}
+	if _, exists := st.GetAgent("repo", "worker"); !exists {
+		t.Error("agent should stay in state when removal is refused")
+	}
+
+	if _, err := m.Remove("repo", "worker", RemoveOptions{RepoPath: repoPath, Force: true}); err != nil {
+		t.Fatalf("Remove(force) failed: %v", err)
+	}
+	if _, err := os.Stat(wtPath); !os.IsNotExist(err) {
+		t.Errorf("worktree directory still exists after force: %v", err)
+	}
+}
+
+func TestRemoveKeepsUnmergedBranch(t *testing.T) {
+	repoPath, wtPath := initRepoWithWorktree(t, "work/worker")
+	m, _ := newRemoveTestManager(t, wtPath)
+
+	cmd := exec.Command("git", "commit", "--allow-empty", "-m", "unmerged work")
+	cmd.Dir = wtPath
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git commit failed: %v\n%s", err, out)
+	}
+
+	if _, err := m.Remove("repo", "worker", RemoveOptions{RepoPath: repoPath}); err != nil {
+		t.Fatalf("Remove() failed: %v", err)
+	}
+	if !branchExists(t, repoPath, "work/worker") {
+		t.Error("unmerged branch should be kept without force")
+	}
+}
+
+func TestRemoveSkipsRepoDirectory(t *testing.T) {
+	repoPath, _ := initRepoWithWorktree(t, "work/worker")
+	m, st := newRemoveTestManager(t, repoPath)
+
+	if _, err := m.Remove("repo", "worker", RemoveOptions{RepoPath: repoPath, Force: true}); err != nil {
+		t.Fatalf("Remove() failed: %v", err)
+	}
+	if _, err := os.Stat(repoPath); err != nil {
+		t.Errorf("main repository must not be removed: %v", err)
+	}
+	if _, exists := st.GetAgent("repo", "worker"); exists {
+		t.Error("agent still in state")
+	}
+}
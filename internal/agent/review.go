@@ -0,0 +1,106 @@
+package agent
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/dlorenc/multiclaude/internal/state"
+)
+
+// ReviewAssignment describes a pull request to hand to a review agent
+type ReviewAssignment struct {
+	PRNumber     int
+	PRURL        string
+	TargetBranch string // Branch the PR merges into (default "main")
+	Remote       string // Remote that TargetBranch is compared on (default "origin")
+}
+
+// SpawnFunc launches an agent's worktree, tmux window, and process. It returns
+// the agent with its launch details (worktree, session ID, PID) filled in and
+// must not register the agent in state; the caller owns registration.
+type SpawnFunc func(agentName string, agent state.Agent) (state.Agent, error)
+
+// ReviewAgentName returns a name for a new review agent of a PR, such as
+// "review-42-3fa9". The random suffix keeps a re-review from colliding with
+// the agent, worktree or branch of an earlier review of the same PR.
+func ReviewAgentName(prNumber int) string {
+	suffix := make([]byte, 2)
+	rand.Read(suffix)
+	return fmt.Sprintf("review-%d-%s", prNumber, hex.EncodeToString(suffix))
+}
+
+// AssignReview spawns a review agent for a pull request and records the
+// assignment in state. The PR is reserved in state before anything is
+// launched, so concurrent calls for the same PR fail rather than spawning two
+// reviewers. The agent's task includes a summary of how the PR diverges from
+// its target branch. Returns the review agent's name.
+func (m *Manager) AssignReview(repoName string, a ReviewAssignment, spawn SpawnFunc) (string, error) {
+	if a.PRNumber <= 0 {
+		return "", fmt.Errorf("invalid PR number %d", a.PRNumber)
+	}
+	if a.TargetBranch == "" {
+		a.TargetBranch = "main"
+	}
+	if a.Remote == "" {
+		a.Remote = "origin"
+	}
+
+	agentName := ReviewAgentName(a.PRNumber)
+	reserved := state.Agent{
+		Type:         state.AgentTypeReview,
+		TmuxWindow:   agentName,
+		Task:         reviewTask(a, ""),
+		PRNumber:     a.PRNumber,
+		TargetBranch: a.TargetBranch,
+		CreatedAt:    time.Now(),
+	}
+
+	if err := m.state.AssignReview(repoName, agentName, reserved); err != nil {
+		return "", err
+	}
+
+	launched, err := spawn(agentName, reserved)
+	if err != nil {
+		m.state.RemoveAgent(repoName, agentName)
+		return "", fmt.Errorf("failed to spawn review agent: %w", err)
+	}
+
+	// The spawner only fills in launch details; keep the assignment itself
+	launched.Type = state.AgentTypeReview
+	launched.PRNumber = a.PRNumber
+	launched.TargetBranch = a.TargetBranch
+	if launched.TmuxWindow == "" {
+		launched.TmuxWindow = agentName
+	}
+	if launched.CreatedAt.IsZero() {
+		launched.CreatedAt = reserved.CreatedAt
+	}
+
+	launched.Task = reviewTask(a, "")
+	if launched.WorktreePath != "" {
+		base := a.Remote + "/" + a.TargetBranch
+		if div, err := m.divergence(launched.WorktreePath, base, "HEAD"); err == nil {
+			launched.Task = reviewTask(a, div.Summary())
+		}
+	}
+
+	if err := m.state.UpdateAgent(repoName, agentName, launched); err != nil {
+		return "", fmt.Errorf("failed to record review assignment: %w", err)
+	}
+
+	return agentName, nil
+}
+
+// reviewTask builds the task description given to a review agent
+func reviewTask(a ReviewAssignment, summary string) string {
+	task := fmt.Sprintf("Review PR #%d against %s", a.PRNumber, a.TargetBranch)
+	if a.PRURL != "" {
+		task += fmt.Sprintf(" (%s)", a.PRURL)
+	}
+	if summary != "" {
+		task += ": " + summary
+	}
+	return task
+}
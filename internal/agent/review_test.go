@@ -0,0 +1,169 @@
+package agent
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dlorenc/multiclaude/internal/fork"
+	"github.com/dlorenc/multiclaude/internal/state"
+)
+
+func newReviewTestManager(t *testing.T) (*Manager, *state.State) {
+	t.Helper()
+	st := state.New(filepath.Join(t.TempDir(), "state.json"))
+	if err := st.AddRepo("repo", &state.Repository{
+		GithubURL:   "https://github.com/test/repo",
+		TmuxSession: "mc-repo",
+		Agents:      make(map[string]state.Agent),
+	}); err != nil {
+		t.Fatalf("AddRepo() failed: %v", err)
+	}
+
+	m := NewManager(st, &fakeTmux{})
+	m.divergence = func(repoPath, base, head string) (*fork.Divergence, error) {
+		return &fork.Divergence{Ahead: 3, Behind: 1, FilesChanged: []string{"a.go", "b.go"}}, nil
+	}
+	return m, st
+}
+
+// fakeSpawn returns launch details without starting anything
+func fakeSpawn(calls *int) SpawnFunc {
+	var mu sync.Mutex
+	return func(agentName string, agent state.Agent) (state.Agent, error) {
+		mu.Lock()
+		*calls++
+		mu.Unlock()
+		agent.WorktreePath = "/wts/repo/" + agentName
+		agent.SessionID = "session-" + agentName
+		agent.PID = 1234
+		return agent, nil
+	}
+}
+
+func TestAssignReview(t *testing.T) {
+	m, st := newReviewTestManager(t)
+	calls := 0
+
+	name, err := m.AssignReview("repo", ReviewAssignment{
+		PRNumber:     42,
+		PRURL:        "https://github.com/test/repo/pull/42",
+		TargetBranch: "develop",
+	}, fakeSpawn(&calls))
+	if err != nil {
+		t.Fatalf("AssignReview() failed: %v", err)
+	}
+
+	if !strings.HasPrefix(name, "review-42-") || calls != 1 {
+		t.Errorf("name = %q, spawn calls = %d", name, calls)
+	}
+
+	agent, exists := st.GetAgent("repo", name)
+	if !exists {
+		t.Fatal("review agent not recorded in state")
+	}
+	if agent.Type != state.AgentTypeReview || agent.PRNumber != 42 || agent.TargetBranch != "develop" {
+		t.Errorf("agent = %+v, want review agent for PR 42 targeting develop", agent)
+	}
+	if agent.PID != 1234 || agent.WorktreePath != "/wts/repo/"+name {
+		t.Errorf("launch details not recorded: %+v", agent)
+	}
+	if !strings.Contains(agent.Task, "PR #42") || !strings.Contains(agent.Task, "3 commit(s) ahead") {
+		t.Errorf("Task = %q, want PR number and divergence summary", agent.Task)
+	}
+}
+
+func TestAssignReviewPreventsDuplicate(t *testing.T) {
+	m, st := newReviewTestManager(t)
+	calls := 0
+
+	first, err := m.AssignReview("repo", ReviewAssignment{PRNumber: 7}, fakeSpawn(&calls))
+	if err != nil {
+		t.Fatalf("AssignReview() failed: %v", err)
+	}
+	if _, err := m.AssignReview("repo", ReviewAssignment{PRNumber: 7}, fakeSpawn(&calls)); err == nil {
+		t.Fatal("AssignReview() should reject a PR that is already assigned")
+	}
+	if calls != 1 {
+		t.Errorf("spawn called %d times, want 1", calls)
+	}
+
+	// Once the reviewer is done, the PR can be assigned again, even before
+	// the finished reviewer is cleaned up
+	agent, _ := st.GetAgent("repo", first)
+	agent.ReadyForCleanup = true
+	st.UpdateAgent("repo", first, agent)
+
+	second, err := m.AssignReview("repo", ReviewAssignment{PRNumber: 7}, fakeSpawn(&calls))
+	if err != nil {
+		t.Fatalf("AssignReview() after the first review finished failed: %v", err)
+	}
+	if second == first {
+		t.Errorf("re-review reused the name %q", first)
+	}
+}
+
+func TestAssignReviewConcurrent(t *testing.T) {
+	m, _ := newReviewTestManager(t)
+	calls := 0
+	spawn := fakeSpawn(&calls)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := m.AssignReview("repo", ReviewAssignment{PRNumber: 99}, spawn); err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded != 1 || calls != 1 {
+		t.Errorf("succeeded = %d, spawn calls = %d, want exactly one of each", succeeded, calls)
+	}
+}
+
+func TestAssignReviewSpawnFailureReleasesPR(t *testing.T) {
+	m, st := newReviewTestManager(t)
+
+	failing := func(agentName string, agent state.Agent) (state.Agent, error) {
+		return state.Agent{}, errors.New("tmux not running")
+	}
+	if _, err := m.AssignReview("repo", ReviewAssignment{PRNumber: 5}, failing); err == nil {
+		t.Fatal("AssignReview() should fail when spawn fails")
+	}
+	if agents, _ := st.ListAgents("repo"); len(agents) != 0 {
+		t.Errorf("failed assignment should not remain in state, have %v", agents)
+	}
+
+	calls := 0
+	if _, err := m.AssignReview("repo", ReviewAssignment{PRNumber: 5}, fakeSpawn(&calls)); err != nil {
+		t.Errorf("AssignReview() retry failed: %v", err)
+	}
+}
+
+func TestAssignReviewWithoutDivergence(t *testing.T) {
+	m, st := newReviewTestManager(t)
+	m.divergence = func(repoPath, base, head string) (*fork.Divergence, error) {
+		return nil, errors.New("unknown ref")
+	}
+	calls := 0
+
+	name, err := m.AssignReview("repo", ReviewAssignment{PRNumber: 3}, fakeSpawn(&calls))
+	if err != nil {
+		t.Fatalf("AssignReview() failed: %v", err)
+	}
+
+	agent, _ := st.GetAgent("repo", name)
+	if agent.Task != "Review PR #3 against main" {
+		t.Errorf("Task = %q, want plain task when divergence is unavailable", agent.Task)
+	}
+}
@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dlorenc/multiclaude/internal/state"
+)
+
+// ErrSpawnLimit is returned when spawning a worker would exceed a repository's
+// spawn limits. Use errors.Is to detect it.
+var ErrSpawnLimit = errors.New("spawn limit exceeded")
+
+// SpawnLimitError describes which spawn limit was hit
+type SpawnLimitError struct {
+	Repo   string
+	Reason string
+}
+
+func (e *SpawnLimitError) Error() string {
+	return fmt.Sprintf("spawn limit exceeded for repository %q: %s", e.Repo, e.Reason)
+}
+
+// Is makes errors.Is(err, ErrSpawnLimit) match any SpawnLimitError
+func (e *SpawnLimitError) Is(target error) bool {
+	return target == ErrSpawnLimit
+}
+
+// tokenBucket tracks spawn tokens for one repository
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// SpawnLimiter enforces per-repository spawn limits: a cap on concurrently
+// active workers and a token bucket on spawn rate. Buckets are kept in
+// memory, so they start full again after a daemon restart.
+type SpawnLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	pending map[string]int // spawns reserved but not yet registered
+	now     func() time.Time
+}
+
+// NewSpawnLimiter creates a spawn limiter with full buckets
+func NewSpawnLimiter() *SpawnLimiter {
+	return &SpawnLimiter{
+		buckets: make(map[string]*tokenBucket),
+		pending: make(map[string]int),
+		now:     time.Now,
+	}
+}
+
+// Acquire reserves a spawn for repoName. activeWorkers counts the workers
+// registered in the repository; it is called under the limiter's lock, so
+// concurrent spawns can't both take the last slot. The reservation counts
+// as an active worker until release is called, which the caller does once
+// the new worker is registered or its spawn has failed. It returns a
+// *SpawnLimitError (matching ErrSpawnLimit) without reserving anything if
+// either limit is exceeded.
+func (l *SpawnLimiter) Acquire(repoName string, limits state.SpawnLimitConfig, activeWorkers func() int) (release func(), err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if limits.MaxWorkers > 0 {
+		if active := activeWorkers() + l.pending[repoName]; active >= limits.MaxWorkers {
+			return nil, &SpawnLimitError{
+				Repo:   repoName,
+				Reason: fmt.Sprintf("%d of %d workers already active", active, limits.MaxWorkers),
+			}
+		}
+	}
+
+	if limits.SpawnsPerMinute > 0 {
+		if err := l.takeToken(repoName, limits); err != nil {
+			return nil, err
+		}
+	}
+
+	l.pending[repoName]++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			if l.pending[repoName]--; l.pending[repoName] <= 0 {
+				delete(l.pending, repoName)
+			}
+		})
+	}, nil
+}
+
+// takeToken takes a token from repoName's bucket. Caller must hold l.mu.
+func (l *SpawnLimiter) takeToken(repoName string, limits state.SpawnLimitConfig) error {
+	burst := float64(limits.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+
+	now := l.now()
+	b, exists := l.buckets[repoName]
+	if !exists {
+		b = &tokenBucket{tokens: burst, last: now}
+		l.buckets[repoName] = b
+	}
+
+	// Refill at the configured rate, never beyond the burst size
+	b.tokens += now.Sub(b.last).Minutes() * limits.SpawnsPerMinute
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / limits.SpawnsPerMinute * float64(time.Minute))
+		return &SpawnLimitError{
+			Repo:   repoName,
+			Reason: fmt.Sprintf("spawn rate of %.4g per minute reached, retry in %s", limits.SpawnsPerMinute, wait.Round(time.Second)),
+		}
+	}
+
+	b.tokens--
+	return nil
+}
+
+// ActiveWorkers counts the workers in agents that have not finished
+func ActiveWorkers(agents map[string]state.Agent) int {
+	count := 0
+	for _, a := range agents {
+		if a.Type == state.AgentTypeWorker && !a.ReadyForCleanup {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,165 @@
+package agent
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dlorenc/multiclaude/internal/state"
+)
+
+func newTestLimiter() (*SpawnLimiter, *time.Time) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := NewSpawnLimiter()
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestSpawnLimiterMaxWorkers(t *testing.T) {
+	l, _ := newTestLimiter()
+	limits := state.SpawnLimitConfig{MaxWorkers: 2}
+
+	agents := map[string]state.Agent{
+		"supervisor": {Type: state.AgentTypeSupervisor},
+		"w1":         {Type: state.AgentTypeWorker},
+		"w2":         {Type: state.AgentTypeWorker},
+	}
+
+	active := func() int { return ActiveWorkers(agents) }
+	_, err := l.Acquire("repo", limits, active)
+	if !errors.Is(err, ErrSpawnLimit) {
+		t.Fatalf("Acquire() at capacity = %v, want ErrSpawnLimit", err)
+	}
+	var limitErr *SpawnLimitError
+	if !errors.As(err, &limitErr) || limitErr.Repo != "repo" {
+		t.Errorf("error = %#v, want *SpawnLimitError for repo", err)
+	}
+
+	// A finished worker frees its slot
+	agents["w2"] = state.Agent{Type: state.AgentTypeWorker, ReadyForCleanup: true}
+	if _, err := l.Acquire("repo", limits, active); err != nil {
+		t.Errorf("Acquire() after capacity freed = %v, want nil", err)
+	}
+}
+
+func TestSpawnLimiterReservesSlot(t *testing.T) {
+	l, _ := newTestLimiter()
+	limits := state.SpawnLimitConfig{MaxWorkers: 2}
+	registered := 1
+	active := func() int { return registered }
+
+	// A reserved slot counts until it is released, so a concurrent spawn
+	// can't take it before the first worker is registered
+	release, err := l.Acquire("repo", limits, active)
+	if err != nil {
+		t.Fatalf("Acquire() = %v, want nil", err)
+	}
+	if _, err := l.Acquire("repo", limits, active); !errors.Is(err, ErrSpawnLimit) {
+		t.Fatalf("Acquire() with the last slot reserved = %v, want ErrSpawnLimit", err)
+	}
+
+	// Once registered the worker is counted by activeWorkers instead
+	registered++
+	release()
+	release()
+	if _, err := l.Acquire("repo", limits, active); !errors.Is(err, ErrSpawnLimit) {
+		t.Errorf("Acquire() after registering = %v, want ErrSpawnLimit", err)
+	}
+
+	// A failed spawn gives its slot back
+	registered--
+	release, err = l.Acquire("repo", limits, active)
+	if err != nil {
+		t.Fatalf("Acquire() = %v, want nil", err)
+	}
+	release()
+	if _, err := l.Acquire("repo", limits, active); err != nil {
+		t.Errorf("Acquire() after a released reservation = %v, want nil", err)
+	}
+}
+
+func TestSpawnLimiterConcurrent(t *testing.T) {
+	l, _ := newTestLimiter()
+	limits := state.SpawnLimitConfig{MaxWorkers: 5}
+
+	var mu sync.Mutex
+	registered := 0
+	active := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return registered
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := l.Acquire("repo", limits, active)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			registered++
+			mu.Unlock()
+			release()
+		}()
+	}
+	wg.Wait()
+
+	if registered != limits.MaxWorkers {
+		t.Errorf("%d workers registered, want %d", registered, limits.MaxWorkers)
+	}
+}
+
+func TestSpawnLimiterRate(t *testing.T) {
+	l, now := newTestLimiter()
+	limits := state.SpawnLimitConfig{SpawnsPerMinute: 2, Burst: 3}
+	none := func() int { return 0 }
+
+	for i := 0; i < 3; i++ {
+		if _, err := l.Acquire("repo", limits, none); err != nil {
+			t.Fatalf("Acquire() %d within burst = %v", i, err)
+		}
+	}
+	if _, err := l.Acquire("repo", limits, none); !errors.Is(err, ErrSpawnLimit) {
+		t.Fatalf("Acquire() past burst = %v, want ErrSpawnLimit", err)
+	}
+
+	// Buckets are per repository
+	if _, err := l.Acquire("other", limits, none); err != nil {
+		t.Errorf("Acquire() for another repo = %v, want nil", err)
+	}
+
+	// At 2 per minute, one token refills after 30 seconds
+	*now = now.Add(30 * time.Second)
+	if _, err := l.Acquire("repo", limits, none); err != nil {
+		t.Errorf("Acquire() after refill = %v, want nil", err)
+	}
+	if _, err := l.Acquire("repo", limits, none); !errors.Is(err, ErrSpawnLimit) {
+		t.Errorf("Acquire() after using refill = %v, want ErrSpawnLimit", err)
+	}
+
+	// Refill never exceeds the burst size
+	*now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if _, err := l.Acquire("repo", limits, none); err != nil {
+			t.Fatalf("Acquire() %d after long idle = %v", i, err)
+		}
+	}
+	if _, err := l.Acquire("repo", limits, none); !errors.Is(err, ErrSpawnLimit) {
+		t.Errorf("Acquire() past burst after idle = %v, want ErrSpawnLimit", err)
+	}
+}
+
+func TestSpawnLimiterDisabled(t *testing.T) {
+	l, _ := newTestLimiter()
+	limits := state.SpawnLimitConfig{MaxWorkers: -1, SpawnsPerMinute: -1}
+
+	for i := 0; i < 100; i++ {
+		if _, err := l.Acquire("repo", limits, func() int { return i }); err != nil {
+			t.Fatalf("Acquire() with limits disabled = %v", err)
+		}
+	}
+}
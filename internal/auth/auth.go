@@ -0,0 +1,146 @@
+// Package auth maps socket client tokens to the capabilities they grant.
+//
+// Tokens are listed in tokens.json under the multiclaude root. The socket's
+// file permissions already restrict it to the owning user, so by default a
+// request with no token keeps full access; tokens let scripts opt into a
+// narrower capability. Setting "require_token" makes every request present
+// a token, as an extra layer on shared machines. A token that is not in the
+// file is always rejected.
+//
+// Clients find their token with ClientToken. Tokens are secrets: they are
+// never logged or recorded in the command history.
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Capability is what a client may do over the socket
+type Capability string
+
+const (
+	// ReadOnly clients may only run commands that do not change anything
+	ReadOnly Capability = "read-only"
+	// ReadWrite clients may run every command
+	ReadWrite Capability = "read-write"
+)
+
+// Token is a named client credential
+type Token struct {
+	Name       string     `json:"name"`
+	Token      string     `json:"token"`
+	Capability Capability `json:"capability"`
+}
+
+// tokensFile is the on-disk format of tokens.json
+type tokensFile struct {
+	Tokens       []Token `json:"tokens"`
+	RequireToken bool    `json:"require_token,omitempty"`
+}
+
+// Store resolves tokens to capabilities
+type Store struct {
+	tokens       map[string]Token
+	requireToken bool
+}
+
+// NewStore creates a store holding tokens
+func NewStore(tokens ...Token) (*Store, error) {
+	s := &Store{tokens: make(map[string]Token, len(tokens))}
+	for _, t := range tokens {
+		if t.Token == "" {
+			return nil, fmt.Errorf("token %q has an empty value", t.Name)
+		}
+		if t.Capability != ReadOnly && t.Capability != ReadWrite {
+			return nil, fmt.Errorf("token %q has invalid capability %q", t.Name, t.Capability)
+		}
+		if _, dup := s.tokens[t.Token]; dup {
+			return nil, fmt.Errorf("token %q duplicates another token's value", t.Name)
+		}
+		s.tokens[t.Token] = t
+	}
+	return s, nil
+}
+
+// Load reads tokens from path. An empty path or missing file yields an
+// empty store.
+func Load(path string) (*Store, error) {
+	if path == "" {
+		return NewStore()
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return NewStore()
+		}
+		return nil, fmt.Errorf("failed to read tokens file: %w", err)
+	}
+
+	var f tokensFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse tokens file: %w", err)
+	}
+	s, err := NewStore(f.Tokens...)
+	if err != nil {
+		return nil, err
+	}
+	if f.RequireToken && len(f.Tokens) == 0 {
+		return nil, fmt.Errorf("require_token is set but no tokens are listed")
+	}
+	s.requireToken = f.RequireToken
+	return s, nil
+}
+
+// RequiresToken reports whether requests without a token are rejected
+func (s *Store) RequiresToken() bool {
+	return s.requireToken
+}
+
+// Capability returns what token grants. An empty token grants ReadWrite
+// unless the store requires a token; an unknown token grants nothing. Both
+// report false.
+func (s *Store) Capability(token string) (Capability, bool) {
+	if token == "" {
+		if s.requireToken {
+			return "", false
+		}
+		return ReadWrite, true
+	}
+	t, ok := s.tokens[token]
+	if !ok {
+		return "", false
+	}
+	return t.Capability, true
+}
+
+// TokenEnv is the environment variable holding the client's token
+const TokenEnv = "MULTICLAUDE_TOKEN"
+
+// TokenFileEnv is the environment variable naming a file that holds the
+// client's token, for when it shouldn't sit in the environment
+const TokenFileEnv = "MULTICLAUDE_TOKEN_FILE"
+
+// ClientToken returns the token a client should send: $MULTICLAUDE_TOKEN if
+// set, otherwise the contents of the file named by $MULTICLAUDE_TOKEN_FILE,
+// otherwise "" for no token.
+func ClientToken() (string, error) {
+	if token := os.Getenv(TokenEnv); token != "" {
+		return token, nil
+	}
+	path := os.Getenv(TokenFileEnv)
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", TokenFileEnv, err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", path)
+	}
+	return token, nil
+}
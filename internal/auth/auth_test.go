@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	data := `{"tokens": [
+		{"name": "dashboard", "token": "ro", "capability": "read-only"},
+		{"name": "ops", "token": "rw", "capability": "read-write"}
+	]}`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+
+	s, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	tests := []struct {
+		token  string
+		want   Capability
+		wantOK bool
+	}{
+		{"ro", ReadOnly, true},
+		{"rw", ReadWrite, true},
+		{"", ReadWrite, true},
+		{"unknown", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := s.Capability(tt.token)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("Capability(%q) = %q, %v; want %q, %v", tt.token, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	for _, path := range []string{"", filepath.Join(t.TempDir(), "missing.json")} {
+		s, err := Load(path)
+		if err != nil {
+			t.Fatalf("Load(%q) failed: %v", path, err)
+		}
+		if _, ok := s.Capability("anything"); ok {
+			t.Errorf("Load(%q) store accepted an unknown token", path)
+		}
+	}
+}
+
+func TestNewStoreRejectsInvalidTokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		tokens []Token
+	}{
+		{"empty value", []Token{{Name: "a", Capability: ReadOnly}}},
+		{"bad capability", []Token{{Name: "a", Token: "x", Capability: "admin"}}},
+		{"duplicate value", []Token{{Name: "a", Token: "x", Capability: ReadOnly}, {Name: "b", Token: "x", Capability: ReadWrite}}},
+	}
+	for _, tt := range tests {
+		if _, err := NewStore(tt.tokens...); err == nil {
+			t.Errorf("NewStore(%s) should fail", tt.name)
+		}
+	}
+}
+
+func TestLoadRequireToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	data := `{"require_token": true, "tokens": [{"name": "me", "token": "shared-secret", "capability": "read-write"}]}`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+
+	s, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if !s.RequiresToken() {
+		t.Error("RequiresToken() = false, want true")
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		wantOK bool
+	}{
+		{"accepted", "shared-secret", true},
+		{"missing", "", false},
+		{"wrong", "guess", false},
+	}
+	for _, tt := range tests {
+		if cap, ok := s.Capability(tt.token); ok != tt.wantOK || (ok && cap != ReadWrite) {
+			t.Errorf("%s: Capability(%q) = %q, %v; want ok %v", tt.name, tt.token, cap, ok, tt.wantOK)
+		}
+	}
+
+	// Requiring a token nobody has would lock everyone out
+	if err := os.WriteFile(path, []byte(`{"require_token": true}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("Load() should reject require_token without tokens")
+	}
+}
+
+func TestClientToken(t *testing.T) {
+	t.Setenv(TokenEnv, "")
+	t.Setenv(TokenFileEnv, "")
+	if token, err := ClientToken(); err != nil || token != "" {
+		t.Errorf("ClientToken() with nothing set = %q, %v; want no token", token, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(TokenFileEnv, path)
+	if token, err := ClientToken(); err != nil || token != "from-file" {
+		t.Errorf("ClientToken() from file = %q, %v; want from-file", token, err)
+	}
+
+	t.Setenv(TokenEnv, "from-env")
+	if token, err := ClientToken(); err != nil || token != "from-env" {
+		t.Errorf("ClientToken() = %q, %v; want the environment to win", token, err)
+	}
+
+	t.Setenv(TokenEnv, "")
+	t.Setenv(TokenFileEnv, filepath.Join(t.TempDir(), "missing"))
+	if _, err := ClientToken(); err == nil {
+		t.Error("ClientToken() should fail when the token file is missing")
+	}
+}
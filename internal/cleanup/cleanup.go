@@ -0,0 +1,375 @@
+// Package cleanup removes resources left behind by dead agents: agents whose
+// tmux window or session is gone, this root's tmux sessions for untracked
+// repositories, worktree directories git no longer knows about, and
+// acknowledged messages.
+//
+// A dry run reports the same candidates without removing anything.
+// FindOrphans lists a broader set of leftovers, including agents whose
+// process exited and stale lock files, without removing anything.
+//
+// Repositories, sessions, and inboxes are reaped in parallel by a bounded
+// worker pool. Git operations within one repository stay serial, and state
+// writes are serialized by the state's own mutex.
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/dlorenc/multiclaude/internal/messages"
+	"github.com/dlorenc/multiclaude/internal/procstat"
+	"github.com/dlorenc/multiclaude/internal/state"
+	"github.com/dlorenc/multiclaude/internal/worktree"
+	"github.com/dlorenc/multiclaude/pkg/claude"
+	"github.com/dlorenc/multiclaude/pkg/config"
+)
+
+// SessionPrefix is the prefix of every tmux session multiclaude creates
+const SessionPrefix = "mc-"
+
+// SessionRootEnv is set in the environment of every tmux session multiclaude
+// creates to the root directory of the daemon that owns it. Several roots can
+// share one tmux server, so only sessions marked with this root are reaped.
+const SessionRootEnv = "MULTICLAUDE_ROOT"
+
+// SessionMarker sets tmux session environment variables.
+// *tmux.Client satisfies this interface.
+type SessionMarker interface {
+	SetEnvironment(ctx context.Context, session, name, value string) error
+}
+
+// MarkSession records root as the owner of a tmux session multiclaude just
+// created, so that cleanup run against root may later reap it
+func MarkSession(ctx context.Context, tmux SessionMarker, session, root string) error {
+	if err := tmux.SetEnvironment(ctx, session, SessionRootEnv, root); err != nil {
+		return fmt.Errorf("failed to mark session %s: %w", session, err)
+	}
+	return nil
+}
+
+// DefaultConcurrency is the number of reap tasks run at once when
+// Cleaner.Concurrency is not set
+const DefaultConcurrency = 4
+
+// TmuxClient is the subset of tmux operations cleanup needs.
+// *tmux.Client satisfies this interface.
+type TmuxClient interface {
+	HasSession(ctx context.Context, name string) (bool, error)
+	HasWindow(ctx context.Context, session, windowName string) (bool, error)
+	KillSession(ctx context.Context, name string) error
+	KillWindow(ctx context.Context, session, windowName string) error
+	ListSessions(ctx context.Context) ([]string, error)
+	GetEnvironment(ctx context.Context, session, name string) (string, error)
+}
+
+// Report summarizes what a cleanup removed, or would remove in a dry run
+type Report struct {
+	DryRun            bool     `json:"dry_run"`
+	DeadAgents        []string `json:"dead_agents"`        // "<repo>/<agent>"
+	OrphanedSessions  []string `json:"orphaned_sessions"`  // tmux session names
+	OrphanedWorktrees []string `json:"orphaned_worktrees"` // directories not registered with git
+	StaleWorktrees    []string `json:"stale_worktrees"`    // git entries whose directories are gone
+	AckedMessages     int      `json:"acked_messages"`
+	Errors            []string `json:"errors,omitempty"`
+}
+
+// Total returns the number of resources in the report
+func (r *Report) Total() int {
+	return len(r.DeadAgents) + len(r.OrphanedSessions) + len(r.OrphanedWorktrees) +
+		len(r.StaleWorktrees) + r.AckedMessages
+}
+
+// Cleaner finds and removes dead resources
+type Cleaner struct {
+	state *state.State
+	tmux  TmuxClient
+	paths *config.Paths
+
+	// Concurrency bounds the number of reap tasks run at once. Zero or less
+	// means DefaultConcurrency.
+	Concurrency int
+
+	// OnRemoveAgent, if set, is called before a dead agent is removed so
+	// callers can record history for it. It may be called concurrently for
+	// agents in different repositories.
+	OnRemoveAgent func(repoName, agentName string, agent state.Agent)
+
+	// alive is swappable so tests can control process liveness
+	alive func(pid int) bool
+
+	mu     sync.Mutex // guards report and errs during a run
+	report *Report
+	errs   []error
+}
+
+// New creates a cleaner
+func New(st *state.State, tmux TmuxClient, paths *config.Paths) *Cleaner {
+	return &Cleaner{state: st, tmux: tmux, paths: paths, alive: procstat.IsAlive}
+}
+
+// Run removes all dead resources, or only reports them if dryRun is set.
+// A failure to reap one resource doesn't stop the sweep: every failure is
+// listed in Report.Errors and returned joined together, alongside the report
+// of everything that was found. Run must not be called concurrently on the
+// same Cleaner.
+func (c *Cleaner) Run(ctx context.Context, dryRun bool) (*Report, error) {
+	c.mu.Lock()
+	c.report = &Report{DryRun: dryRun}
+	c.errs = nil
+	c.mu.Unlock()
+
+	c.cleanDeadAgents(ctx)
+	c.cleanOrphanedSessions(ctx)
+	c.cleanWorktrees()
+	c.cleanAckedMessages()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	report := c.report
+	sort.Strings(report.DeadAgents)
+	sort.Strings(report.OrphanedSessions)
+	sort.Strings(report.OrphanedWorktrees)
+	sort.Strings(report.StaleWorktrees)
+	for _, err := range c.errs {
+		report.Errors = append(report.Errors, err.Error())
+	}
+	sort.Strings(report.Errors)
+	return report, errors.Join(c.errs...)
+}
+
+// record updates the report under the run's lock
+func (c *Cleaner) record(fn func(r *Report)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fn(c.report)
+}
+
+// fail records a reap failure
+func (c *Cleaner) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errs = append(c.errs, err)
+}
+
+// parallel runs tasks on at most Concurrency goroutines and waits for them
+func (c *Cleaner) parallel(tasks []func()) {
+	n := c.Concurrency
+	if n <= 0 {
+		n = DefaultConcurrency
+	}
+
+	sem := make(chan struct{}, n)
+	var wg sync.WaitGroup
+	for _, task := range tasks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			task()
+		}()
+	}
+	wg.Wait()
+}
+
+// cleanDeadAgents removes agents that are ready for cleanup or whose tmux
+// session or window no longer exists. When the whole session is gone, as
+// after a tmux crash, only transient agents are removed; persistent ones
+// stay for the daemon to restore with the session. Repositories are reaped
+// in parallel;
+// agents within a repository are removed one at a time because their
+// worktrees share the repository's git metadata.
+func (c *Cleaner) cleanDeadAgents(ctx context.Context) {
+	repos := c.state.GetAllRepos()
+	dryRun := c.report.DryRun
+
+	var tasks []func()
+	for _, repoName := range slices.Sorted(maps.Keys(repos)) {
+		repo := repos[repoName]
+		tasks = append(tasks, func() {
+			hasSession, err := c.tmux.HasSession(ctx, repo.TmuxSession)
+			if err != nil {
+				c.fail(fmt.Errorf("failed to check session %s: %w", repo.TmuxSession, err))
+				return
+			}
+
+			for _, agentName := range slices.Sorted(maps.Keys(repo.Agents)) {
+				agent := repo.Agents[agentName]
+
+				dead := agent.ReadyForCleanup
+				if !dead && !hasSession {
+					if agent.Type.IsPersistent() {
+						continue
+					}
+					dead = true
+				}
+				if !dead {
+					hasWindow, err := c.tmux.HasWindow(ctx, repo.TmuxSession, agent.TmuxWindow)
+					if err != nil {
+						c.fail(fmt.Errorf("%s/%s: %w", repoName, agentName, err))
+						continue
+					}
+					dead = !hasWindow
+				}
+				if !dead {
+					continue
+				}
+
+				c.record(func(r *Report) { r.DeadAgents = append(r.DeadAgents, repoName+"/"+agentName) })
+				if dryRun {
+					continue
+				}
+				if err := c.removeAgent(ctx, repoName, repo, agentName, agent); err != nil {
+					c.fail(err)
+				}
+			}
+
+			if !dryRun {
+				validAgents, _ := c.state.ListAgents(repoName)
+				messages.NewManager(c.paths.MessagesDir).CleanupOrphaned(repoName, validAgents)
+				claude.CleanupEnvFiles(c.paths.RepoEnvDir(repoName), validAgents)
+			}
+		})
+	}
+	c.parallel(tasks)
+}
+
+// removeAgent closes a dead agent's window, removes its worktree, and drops
+// it from state
+func (c *Cleaner) removeAgent(ctx context.Context, repoName string, repo *state.Repository, agentName string, agent state.Agent) error {
+	if c.OnRemoveAgent != nil {
+		c.OnRemoveAgent(repoName, agentName, agent)
+	}
+
+	if hasWindow, err := c.tmux.HasWindow(ctx, repo.TmuxSession, agent.TmuxWindow); err == nil && hasWindow {
+		if err := c.tmux.KillWindow(ctx, repo.TmuxSession, agent.TmuxWindow); err != nil {
+			return fmt.Errorf("failed to kill window for %s/%s: %w", repoName, agentName, err)
+		}
+	}
+
+	// Workers and review agents own their worktree; others run in the repo
+	if agent.WorktreePath != "" && (agent.Type == state.AgentTypeWorker || agent.Type == state.AgentTypeReview) {
+		if _, err := os.Stat(agent.WorktreePath); err == nil {
+			wt := worktree.NewManager(c.paths.RepoDir(repoName))
+			if err := wt.Remove(agent.WorktreePath, true); err != nil {
+				return fmt.Errorf("failed to remove worktree for %s/%s: %w", repoName, agentName, err)
+			}
+		}
+	}
+
+	if err := c.state.RemoveAgent(repoName, agentName); err != nil {
+		return fmt.Errorf("failed to remove %s/%s from state: %w", repoName, agentName, err)
+	}
+	return nil
+}
+
+// cleanOrphanedSessions kills tmux sessions owned by this root that belong
+// to no tracked repository
+func (c *Cleaner) cleanOrphanedSessions(ctx context.Context) {
+	sessions, err := c.OrphanedSessions(ctx)
+	if err != nil {
+		c.fail(err)
+		return
+	}
+	dryRun := c.report.DryRun
+
+	var tasks []func()
+	for _, session := range sessions {
+		c.record(func(r *Report) { r.OrphanedSessions = append(r.OrphanedSessions, session) })
+		if dryRun {
+			continue
+		}
+		tasks = append(tasks, func() {
+			if err := c.tmux.KillSession(ctx, session); err != nil {
+				c.fail(fmt.Errorf("failed to kill session %s: %w", session, err))
+			}
+		})
+	}
+	c.parallel(tasks)
+}
+
+// cleanWorktrees removes worktree directories git doesn't know about and
+// prunes git entries whose directories are gone, one repository per task
+func (c *Cleaner) cleanWorktrees() {
+	dryRun := c.report.DryRun
+
+	var tasks []func()
+	for _, repoName := range c.state.ListRepos() {
+		repoPath := c.paths.RepoDir(repoName)
+		if _, err := os.Stat(repoPath); err != nil {
+			continue
+		}
+
+		tasks = append(tasks, func() {
+			wt := worktree.NewManager(repoPath)
+
+			orphaned, err := worktree.FindOrphaned(c.paths.WorktreeDir(repoName), wt)
+			if err != nil {
+				c.fail(fmt.Errorf("failed to find orphaned worktrees for %s: %w", repoName, err))
+			}
+			for _, path := range orphaned {
+				c.record(func(r *Report) { r.OrphanedWorktrees = append(r.OrphanedWorktrees, path) })
+				if dryRun {
+					continue
+				}
+				if err := os.RemoveAll(path); err != nil {
+					c.fail(fmt.Errorf("failed to remove %s: %w", path, err))
+				}
+			}
+
+			stale, err := worktree.FindStaleWorktrees(repoPath)
+			if err != nil {
+				c.fail(fmt.Errorf("failed to find stale worktrees for %s: %w", repoName, err))
+				return
+			}
+			c.record(func(r *Report) { r.StaleWorktrees = append(r.StaleWorktrees, stale...) })
+			if len(stale) > 0 && !dryRun {
+				if err := wt.Prune(); err != nil {
+					c.fail(fmt.Errorf("failed to prune worktrees for %s: %w", repoName, err))
+				}
+			}
+		})
+	}
+	c.parallel(tasks)
+}
+
+// cleanAckedMessages deletes messages their recipients have acknowledged,
+// one inbox per task
+func (c *Cleaner) cleanAckedMessages() {
+	msgMgr := messages.NewManager(c.paths.MessagesDir)
+	dryRun := c.report.DryRun
+
+	var tasks []func()
+	for _, repoName := range c.state.ListRepos() {
+		agents, _ := c.state.ListAgents(repoName)
+		for _, agentName := range agents {
+			tasks = append(tasks, func() {
+				if !dryRun {
+					count, err := msgMgr.DeleteAcked(repoName, agentName)
+					if err != nil {
+						c.fail(fmt.Errorf("failed to delete acked messages for %s/%s: %w", repoName, agentName, err))
+					}
+					c.record(func(r *Report) { r.AckedMessages += count })
+					return
+				}
+
+				msgs, err := msgMgr.List(repoName, agentName)
+				if err != nil {
+					return
+				}
+				for _, msg := range msgs {
+					if msg.Status == messages.StatusAcked {
+						c.record(func(r *Report) { r.AckedMessages++ })
+					}
+				}
+			})
+		}
+	}
+	c.parallel(tasks)
+}
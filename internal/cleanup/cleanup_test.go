@@ -0,0 +1,357 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dlorenc/multiclaude/internal/messages"
+	"github.com/dlorenc/multiclaude/internal/state"
+	"github.com/dlorenc/multiclaude/pkg/config"
+)
+
+// fakeTmux tracks sessions and windows in memory
+type fakeTmux struct {
+	mu       sync.Mutex
+	windows  map[string]map[string]bool // session -> window -> exists
+	roots    map[string]string          // session -> SessionRootEnv
+	failKill map[string]bool            // sessions whose kill fails
+}
+
+func (f *fakeTmux) HasSession(ctx context.Context, name string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.windows[name]
+	return ok, nil
+}
+
+func (f *fakeTmux) HasWindow(ctx context.Context, session, window string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.windows[session][window], nil
+}
+
+func (f *fakeTmux) KillSession(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failKill[name] {
+		return fmt.Errorf("kill-session %s: permission denied", name)
+	}
+	delete(f.windows, name)
+	return nil
+}
+
+func (f *fakeTmux) KillWindow(ctx context.Context, session, window string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.windows[session], window)
+	return nil
+}
+
+func (f *fakeTmux) ListSessions(ctx context.Context) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var sessions []string
+	for name := range f.windows {
+		sessions = append(sessions, name)
+	}
+	return sessions, nil
+}
+
+func (f *fakeTmux) GetEnvironment(ctx context.Context, session, name string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.windows[session]; !ok {
+		return "", fmt.Errorf("can't find session: %s", session)
+	}
+	if name != SessionRootEnv {
+		return "", nil
+	}
+	return f.roots[session], nil
+}
+
+// initRepo creates an empty git repository for repoName under paths
+func initRepo(t *testing.T, paths *config.Paths, repoName string) {
+	t.Helper()
+	repoPath := paths.RepoDir(repoName)
+	if err := os.MkdirAll(repoPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("git", "init", "-b", "main")
+	cmd.Dir = repoPath
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v\n%s", err, out)
+	}
+}
+
+// seedEnvironment creates a tracked repo with a live supervisor, two dead
+// workers, an orphaned worktree directory, a stale tmux session, and an acked
+// message. It also adds sessions cleanup must leave alone: another root's,
+// an unmarked one, and a self-test session.
+func seedEnvironment(t *testing.T) (*Cleaner, *state.State, *fakeTmux, *config.Paths) {
+	t.Helper()
+	if exec.Command("git", "version").Run() != nil {
+		t.Skip("git not available")
+	}
+
+	paths := config.NewTestPaths(t.TempDir())
+	if err := paths.EnsureDirectories(); err != nil {
+		t.Fatal(err)
+	}
+
+	initRepo(t, paths, "repo")
+	repoPath := paths.RepoDir("repo")
+
+	orphan := filepath.Join(paths.WorktreeDir("repo"), "orphan")
+	if err := os.MkdirAll(orphan, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	st := state.New(paths.StateFile)
+	st.AddRepo("repo", &state.Repository{TmuxSession: "mc-repo", Agents: make(map[string]state.Agent)})
+	st.AddAgent("repo", "supervisor", state.Agent{Type: state.AgentTypeSupervisor, TmuxWindow: "supervisor", WorktreePath: repoPath})
+	st.AddAgent("repo", "done", state.Agent{Type: state.AgentTypeWorker, TmuxWindow: "done", ReadyForCleanup: true})
+	st.AddAgent("repo", "crashed", state.Agent{Type: state.AgentTypeWorker, TmuxWindow: "crashed"})
+
+	tmux := &fakeTmux{
+		windows: map[string]map[string]bool{
+			"mc-repo":       {"supervisor": true, "done": true},
+			"mc-stale":      {"supervisor": true},
+			"mc-other-root": {"supervisor": true},
+			"mc-unmarked":   {"supervisor": true},
+			"mc-selftest-1": {"selftest": true},
+			"personal":      {"shell": true},
+		},
+		roots: map[string]string{
+			"mc-repo":       paths.Root,
+			"mc-stale":      paths.Root,
+			"mc-other-root": filepath.Join(t.TempDir(), "other"),
+			"mc-selftest-1": paths.Root,
+		},
+	}
+
+	msgMgr := messages.NewManager(paths.MessagesDir)
+	acked, _ := msgMgr.Send("repo", "done", "supervisor", "finished")
+	msgMgr.Ack("repo", "supervisor", acked.ID)
+	msgMgr.Send("repo", "done", "supervisor", "still pending")
+
+	return New(st, tmux, paths), st, tmux, paths
+}
+
+func TestCleanupDryRun(t *testing.T) {
+	c, st, tmux, paths := seedEnvironment(t)
+
+	report, err := c.Run(context.Background(), true)
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+
+	if !report.DryRun {
+		t.Error("report should be marked as a dry run")
+	}
+	if want := []string{"repo/crashed", "repo/done"}; !reflect.DeepEqual(report.DeadAgents, want) {
+		t.Errorf("DeadAgents = %v, want %v", report.DeadAgents, want)
+	}
+	if want := []string{"mc-stale"}; !reflect.DeepEqual(report.OrphanedSessions, want) {
+		t.Errorf("OrphanedSessions = %v, want %v", report.OrphanedSessions, want)
+	}
+	if len(report.OrphanedWorktrees) != 1 || filepath.Base(report.OrphanedWorktrees[0]) != "orphan" {
+		t.Errorf("OrphanedWorktrees = %v, want the orphan directory", report.OrphanedWorktrees)
+	}
+	if report.AckedMessages != 1 {
+		t.Errorf("AckedMessages = %d, want 1", report.AckedMessages)
+	}
+
+	// Nothing is removed
+	if agents, _ := st.ListAgents("repo"); len(agents) != 3 {
+		t.Errorf("agents = %v, dry run should keep all", agents)
+	}
+	if _, ok := tmux.windows["mc-stale"]; !ok {
+		t.Error("dry run should not kill sessions")
+	}
+	if _, err := os.Stat(filepath.Join(paths.WorktreeDir("repo"), "orphan")); err != nil {
+		t.Error("dry run should not remove worktrees")
+	}
+}
+
+func TestCleanupRemovesDeadResources(t *testing.T) {
+	c, st, tmux, paths := seedEnvironment(t)
+
+	var recorded []string
+	c.OnRemoveAgent = func(repoName, agentName string, agent state.Agent) {
+		recorded = append(recorded, agentName)
+	}
+
+	report, err := c.Run(context.Background(), false)
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	if len(report.Errors) > 0 {
+		t.Errorf("Errors = %v", report.Errors)
+	}
+	if report.Total() != 5 {
+		t.Errorf("Total() = %d, want 5 (2 agents, 1 session, 1 worktree, 1 message)", report.Total())
+	}
+	if !reflect.DeepEqual(recorded, []string{"crashed", "done"}) {
+		t.Errorf("OnRemoveAgent called for %v", recorded)
+	}
+
+	if agents, _ := st.ListAgents("repo"); !reflect.DeepEqual(agents, []string{"supervisor"}) {
+		t.Errorf("agents = %v, want only the live supervisor", agents)
+	}
+	if tmux.windows["mc-repo"]["done"] {
+		t.Error("dead agent's window should be killed")
+	}
+	if _, ok := tmux.windows["mc-stale"]; ok {
+		t.Error("orphaned session should be killed")
+	}
+	for _, session := range []string{"personal", "mc-other-root", "mc-unmarked", "mc-selftest-1"} {
+		if _, ok := tmux.windows[session]; !ok {
+			t.Errorf("session %s is not this root's to reap and must be left alone", session)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(paths.WorktreeDir("repo"), "orphan")); !os.IsNotExist(err) {
+		t.Error("orphaned worktree should be removed")
+	}
+
+	msgs, _ := messages.NewManager(paths.MessagesDir).List("repo", "supervisor")
+	if len(msgs) != 1 || msgs[0].Body != "still pending" {
+		t.Errorf("supervisor inbox = %+v, want only the unacked message", msgs)
+	}
+
+	// A second run finds nothing
+	report, err = c.Run(context.Background(), false)
+	if err != nil {
+		t.Fatalf("second Run() failed: %v", err)
+	}
+	if report.Total() != 0 {
+		t.Errorf("second run found %+v, want nothing", report)
+	}
+}
+
+func TestCleanupKeepsPersistentAgentsOfMissingSession(t *testing.T) {
+	c, st, tmux, _ := seedEnvironment(t)
+	st.AddAgent("repo", "merge-queue", state.Agent{Type: state.AgentTypeMergeQueue, TmuxWindow: "merge-queue"})
+
+	// tmux crashed and took the repo's session with it
+	delete(tmux.windows, "mc-repo")
+
+	report, err := c.Run(context.Background(), false)
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	if want := []string{"repo/crashed", "repo/done"}; !reflect.DeepEqual(report.DeadAgents, want) {
+		t.Errorf("DeadAgents = %v, want only the workers %v", report.DeadAgents, want)
+	}
+	agents, _ := st.ListAgents("repo")
+	sort.Strings(agents)
+	if !reflect.DeepEqual(agents, []string{"merge-queue", "supervisor"}) {
+		t.Errorf("agents = %v, want the persistent agents kept for restoration", agents)
+	}
+}
+
+func TestCleanupParallelAggregatesErrors(t *testing.T) {
+	if exec.Command("git", "version").Run() != nil {
+		t.Skip("git not available")
+	}
+
+	paths := config.NewTestPaths(t.TempDir())
+	if err := paths.EnsureDirectories(); err != nil {
+		t.Fatal(err)
+	}
+	st := state.New(paths.StateFile)
+	tmux := &fakeTmux{
+		windows:  make(map[string]map[string]bool),
+		roots:    make(map[string]string),
+		failKill: map[string]bool{"mc-stale-3": true, "mc-stale-7": true},
+	}
+
+	const repos, agentsPerRepo, staleSessions = 8, 5, 10
+	for i := 0; i < repos; i++ {
+		repoName := fmt.Sprintf("repo-%d", i)
+		initRepo(t, paths, repoName)
+		session := "mc-" + repoName
+		st.AddRepo(repoName, &state.Repository{TmuxSession: session, Agents: make(map[string]state.Agent)})
+		tmux.windows[session] = map[string]bool{"supervisor": true}
+		st.AddAgent(repoName, "supervisor", state.Agent{Type: state.AgentTypeSupervisor, TmuxWindow: "supervisor"})
+
+		for j := 0; j < agentsPerRepo; j++ {
+			name := fmt.Sprintf("worker-%d", j)
+			st.AddAgent(repoName, name, state.Agent{Type: state.AgentTypeWorker, TmuxWindow: name})
+		}
+		for j := 0; j < 2; j++ {
+			if err := os.MkdirAll(filepath.Join(paths.WorktreeDir(repoName), fmt.Sprintf("orphan-%d", j)), 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for i := 0; i < staleSessions; i++ {
+		session := fmt.Sprintf("mc-stale-%d", i)
+		tmux.windows[session] = map[string]bool{}
+		tmux.roots[session] = paths.Root
+	}
+
+	c := New(st, tmux, paths)
+	c.Concurrency = 3
+	var mu sync.Mutex
+	removed := 0
+	c.OnRemoveAgent = func(repoName, agentName string, agent state.Agent) {
+		mu.Lock()
+		removed++
+		mu.Unlock()
+	}
+
+	report, err := c.Run(context.Background(), false)
+
+	// Both failed kills are reported together without stopping the sweep
+	if err == nil {
+		t.Fatal("Run() should return the aggregated kill failures")
+	}
+	for _, session := range []string{"mc-stale-3", "mc-stale-7"} {
+		if !strings.Contains(err.Error(), session) {
+			t.Errorf("error %q should mention %s", err, session)
+		}
+	}
+	if len(report.Errors) != 2 {
+		t.Errorf("Errors = %v, want 2", report.Errors)
+	}
+	var joined interface{ Unwrap() []error }
+	if !errors.As(err, &joined) || len(joined.Unwrap()) != 2 {
+		t.Errorf("error should join both failures, got %v", err)
+	}
+
+	if len(report.DeadAgents) != repos*agentsPerRepo || removed != repos*agentsPerRepo {
+		t.Errorf("removed %d agents, reported %d, want %d", removed, len(report.DeadAgents), repos*agentsPerRepo)
+	}
+	if len(report.OrphanedWorktrees) != repos*2 {
+		t.Errorf("OrphanedWorktrees = %d, want %d", len(report.OrphanedWorktrees), repos*2)
+	}
+	if len(report.OrphanedSessions) != staleSessions {
+		t.Errorf("OrphanedSessions = %d, want %d", len(report.OrphanedSessions), staleSessions)
+	}
+
+	for i := 0; i < repos; i++ {
+		repoName := fmt.Sprintf("repo-%d", i)
+		if agents, _ := st.ListAgents(repoName); len(agents) != 1 {
+			t.Errorf("%s agents = %v, want only supervisor", repoName, agents)
+		}
+		if entries, _ := os.ReadDir(paths.WorktreeDir(repoName)); len(entries) != 0 {
+			t.Errorf("%s worktrees = %d entries, want none", repoName, len(entries))
+		}
+	}
+	for i := 0; i < staleSessions; i++ {
+		session := fmt.Sprintf("mc-stale-%d", i)
+		_, exists := tmux.windows[session]
+		if exists != tmux.failKill[session] {
+			t.Errorf("session %s exists = %v after cleanup", session, exists)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dlorenc/multiclaude/internal/selftest"
+)
+
+// StaleFileAge is how old a lock or temporary file must be before it is
+// considered left behind by a crashed process rather than in use
+const StaleFileAge = 10 * time.Minute
+
+// Orphans lists everything that looks left behind, by kind. Finding orphans
+// never removes anything.
+type Orphans struct {
+	Sessions   []string `json:"sessions"`    // this root's tmux sessions for untracked repos
+	Worktrees  []string `json:"worktrees"`   // worktree directories no agent uses
+	DeadAgents []string `json:"dead_agents"` // "<repo>/<agent>" whose PID is not running
+	StaleFiles []string `json:"stale_files"` // lock, socket, PID, and temp files nothing owns
+}
+
+// Total returns the number of orphans found
+func (o *Orphans) Total() int {
+	return len(o.Sessions) + len(o.Worktrees) + len(o.DeadAgents) + len(o.StaleFiles)
+}
+
+// FindOrphans lists orphaned tmux sessions, worktree directories without an
+// agent, agents whose process has exited, and stale lock, socket, PID, and
+// temporary files. It is read-only.
+func (c *Cleaner) FindOrphans(ctx context.Context) (*Orphans, error) {
+	o := &Orphans{
+		Sessions:   []string{},
+		Worktrees:  []string{},
+		DeadAgents: []string{},
+		StaleFiles: []string{},
+	}
+
+	sessions, err := c.OrphanedSessions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	o.Sessions = append(o.Sessions, sessions...)
+
+	worktrees, err := c.unusedWorktrees()
+	if err != nil {
+		return nil, err
+	}
+	o.Worktrees = append(o.Worktrees, worktrees...)
+
+	repos := c.state.GetAllRepos()
+	for _, repoName := range slices.Sorted(maps.Keys(repos)) {
+		repo := repos[repoName]
+		for _, agentName := range slices.Sorted(maps.Keys(repo.Agents)) {
+			if pid := repo.Agents[agentName].PID; pid > 0 && !c.alive(pid) {
+				o.DeadAgents = append(o.DeadAgents, repoName+"/"+agentName)
+			}
+		}
+	}
+
+	o.StaleFiles = append(o.StaleFiles, c.staleFiles()...)
+
+	sort.Strings(o.Sessions)
+	sort.Strings(o.Worktrees)
+	sort.Strings(o.StaleFiles)
+	return o, nil
+}
+
+// OrphanedSessions returns the multiclaude tmux sessions that this root owns
+// but no tracked repository uses. Sessions marked with another root, or not
+// marked at all, may belong to another daemon sharing the tmux server and are
+// left alone, as are self-test sessions, which the self-test removes itself.
+func (c *Cleaner) OrphanedSessions(ctx context.Context) ([]string, error) {
+	sessions, err := c.tmux.ListSessions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tmux sessions: %w", err)
+	}
+
+	tracked := make(map[string]bool)
+	for _, repo := range c.state.GetAllRepos() {
+		tracked[repo.TmuxSession] = true
+	}
+
+	var orphaned []string
+	for _, session := range sessions {
+		if !strings.HasPrefix(session, SessionPrefix) || strings.HasPrefix(session, selftest.SessionPrefix) || tracked[session] {
+			continue
+		}
+		root, err := c.tmux.GetEnvironment(ctx, session, SessionRootEnv)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			// The session may have exited since it was listed
+			continue
+		}
+		if root == c.paths.Root {
+			orphaned = append(orphaned, session)
+		}
+	}
+	return orphaned, nil
+}
+
+// unusedWorktrees returns directories under the worktrees root that no agent
+// uses, including whole directories for repositories that are not tracked
+func (c *Cleaner) unusedWorktrees() ([]string, error) {
+	repos := c.state.GetAllRepos()
+
+	entries, err := os.ReadDir(c.paths.WorktreesDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read worktrees directory: %w", err)
+	}
+
+	var unused []string
+	for _, entry := range entries {
+		repoDir := filepath.Join(c.paths.WorktreesDir, entry.Name())
+		repo, exists := repos[entry.Name()]
+		if !exists {
+			unused = append(unused, repoDir)
+			continue
+		}
+		if !entry.IsDir() {
+			continue
+		}
+
+		inUse := make(map[string]bool)
+		for _, agent := range repo.Agents {
+			if agent.WorktreePath != "" {
+				inUse[filepath.Clean(agent.WorktreePath)] = true
+			}
+		}
+
+		wts, err := os.ReadDir(repoDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", repoDir, err)
+		}
+		for _, wt := range wts {
+			path := filepath.Join(repoDir, wt.Name())
+			if !inUse[path] {
+				unused = append(unused, path)
+			}
+		}
+	}
+	return unused, nil
+}
+
+// staleFiles returns lock, socket, PID, and temporary files left behind by
+// processes that are no longer running
+func (c *Cleaner) staleFiles() []string {
+	var stale []string
+
+	// A PID file naming a process that has exited
+	if data, err := os.ReadFile(c.paths.DaemonPID); err == nil {
+		if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err != nil || !c.alive(pid) {
+			stale = append(stale, c.paths.DaemonPID)
+		}
+	}
+
+	// Sockets other than the daemon's, and temp files from interrupted
+	// atomic writes of state, metrics, and history
+	for _, pattern := range []string{"*.sock", ".*.tmp"} {
+		matches, _ := filepath.Glob(filepath.Join(c.paths.Root, pattern))
+		for _, path := range matches {
+			if path == c.paths.DaemonSock {
+				continue
+			}
+			if pattern == "*.sock" || isOlderThan(path, StaleFileAge) {
+				stale = append(stale, path)
+			}
+		}
+	}
+
+	// Git index locks in repositories and their worktrees
+	for _, repoName := range c.state.ListRepos() {
+		gitDir := filepath.Join(c.paths.RepoDir(repoName), ".git")
+		locks := []string{filepath.Join(gitDir, "index.lock")}
+		worktreeLocks, _ := filepath.Glob(filepath.Join(gitDir, "worktrees", "*", "index.lock"))
+		locks = append(locks, worktreeLocks...)
+		for _, path := range locks {
+			if isOlderThan(path, StaleFileAge) {
+				stale = append(stale, path)
+			}
+		}
+	}
+
+	return stale
+}
+
+// isOlderThan reports whether path exists and was last modified more than
+// age ago
+func isOlderThan(path string, age time.Duration) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return time.Since(info.ModTime()) > age
+}
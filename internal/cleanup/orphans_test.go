@@ -0,0 +1,131 @@
+package cleanup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/dlorenc/multiclaude/internal/state"
+	"github.com/dlorenc/multiclaude/pkg/config"
+)
+
+func TestFindOrphans(t *testing.T) {
+	paths := config.NewTestPaths(t.TempDir())
+	if err := paths.EnsureDirectories(); err != nil {
+		t.Fatal(err)
+	}
+
+	const livePID, deadPID = 1001, 1002
+	touch := func(path string, age time.Duration) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		old := time.Now().Add(-age)
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mkdir := func(path string) {
+		t.Helper()
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Agents: one live, one whose process exited
+	st := state.New(paths.StateFile)
+	usedWorktree := paths.AgentWorktree("repo", "live")
+	st.AddRepo("repo", &state.Repository{TmuxSession: "mc-repo", Agents: make(map[string]state.Agent)})
+	st.AddAgent("repo", "live", state.Agent{Type: state.AgentTypeWorker, PID: livePID, WorktreePath: usedWorktree})
+	st.AddAgent("repo", "dead", state.Agent{Type: state.AgentTypeWorker, PID: deadPID})
+	st.AddAgent("repo", "no-pid", state.Agent{Type: state.AgentTypeWorker})
+
+	// Worktrees: one in use, one with no agent, one for an untracked repo
+	mkdir(usedWorktree)
+	unused := paths.AgentWorktree("repo", "abandoned")
+	mkdir(unused)
+	untracked := paths.WorktreeDir("forgotten")
+	mkdir(untracked)
+
+	// Files: stale ones, plus fresh and owned ones that must not be listed
+	gitDir := filepath.Join(paths.RepoDir("repo"), ".git")
+	staleIndexLock := filepath.Join(gitDir, "index.lock")
+	touch(staleIndexLock, time.Hour)
+	staleWorktreeLock := filepath.Join(gitDir, "worktrees", "abandoned", "index.lock")
+	touch(staleWorktreeLock, time.Hour)
+	touch(filepath.Join(gitDir, "worktrees", "live", "index.lock"), time.Second)
+	staleTmp := filepath.Join(paths.Root, ".state-123.tmp")
+	touch(staleTmp, time.Hour)
+	touch(filepath.Join(paths.Root, ".metrics-456.tmp"), time.Second)
+	straySock := filepath.Join(paths.Root, "old.sock")
+	touch(straySock, 0)
+	touch(paths.DaemonSock, 0)
+	if err := os.WriteFile(paths.DaemonPID, []byte("1002\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sessions: one tracked, one orphaned, and ones not ours
+	tmux := &fakeTmux{
+		windows: map[string]map[string]bool{
+			"mc-repo":       {},
+			"mc-stale":      {},
+			"mc-other-root": {},
+			"mc-selftest-1": {},
+			"personal":      {},
+		},
+		roots: map[string]string{
+			"mc-repo":       paths.Root,
+			"mc-stale":      paths.Root,
+			"mc-other-root": "/elsewhere",
+			"mc-selftest-1": paths.Root,
+		},
+	}
+
+	c := New(st, tmux, paths)
+	c.alive = func(pid int) bool { return pid == livePID }
+
+	orphans, err := c.FindOrphans(context.Background())
+	if err != nil {
+		t.Fatalf("FindOrphans() failed: %v", err)
+	}
+
+	if want := []string{"mc-stale"}; !reflect.DeepEqual(orphans.Sessions, want) {
+		t.Errorf("Sessions = %v, want %v", orphans.Sessions, want)
+	}
+	if want := []string{untracked, unused}; !reflect.DeepEqual(orphans.Worktrees, want) {
+		t.Errorf("Worktrees = %v, want %v", orphans.Worktrees, want)
+	}
+	if want := []string{"repo/dead"}; !reflect.DeepEqual(orphans.DeadAgents, want) {
+		t.Errorf("DeadAgents = %v, want %v", orphans.DeadAgents, want)
+	}
+	wantFiles := []string{paths.DaemonPID, staleTmp, staleIndexLock, staleWorktreeLock, straySock}
+	wantSorted := append([]string(nil), wantFiles...)
+	sort.Strings(wantSorted)
+	if !reflect.DeepEqual(orphans.StaleFiles, wantSorted) {
+		t.Errorf("StaleFiles = %v, want %v", orphans.StaleFiles, wantSorted)
+	}
+	if orphans.Total() != 1+2+1+len(wantFiles) {
+		t.Errorf("Total() = %d", orphans.Total())
+	}
+
+	// Listing orphans removes nothing
+	for _, path := range append([]string{unused, untracked}, wantFiles...) {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s should not be removed: %v", path, err)
+		}
+	}
+	if _, ok := tmux.windows["mc-stale"]; !ok {
+		t.Error("orphaned session should not be killed")
+	}
+	if _, exists := st.GetAgent("repo", "dead"); !exists {
+		t.Error("dead agent should stay in state")
+	}
+}
@@ -5,16 +5,22 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/dlorenc/multiclaude/internal/agent"
 	"github.com/dlorenc/multiclaude/internal/agents"
+	"github.com/dlorenc/multiclaude/internal/auth"
 	"github.com/dlorenc/multiclaude/internal/bugreport"
+	"github.com/dlorenc/multiclaude/internal/cleanup"
 	"github.com/dlorenc/multiclaude/internal/daemon"
 	"github.com/dlorenc/multiclaude/internal/diagnostics"
 	"github.com/dlorenc/multiclaude/internal/errors"
@@ -23,7 +29,11 @@ import (
 	"github.com/dlorenc/multiclaude/internal/hooks"
 	"github.com/dlorenc/multiclaude/internal/messages"
 	"github.com/dlorenc/multiclaude/internal/names"
+	"github.com/dlorenc/multiclaude/internal/output"
 	"github.com/dlorenc/multiclaude/internal/prompts"
+	"github.com/dlorenc/multiclaude/internal/reconcile"
+	"github.com/dlorenc/multiclaude/internal/report"
+	"github.com/dlorenc/multiclaude/internal/selftest"
 	"github.com/dlorenc/multiclaude/internal/socket"
 	"github.com/dlorenc/multiclaude/internal/state"
 	"github.com/dlorenc/multiclaude/internal/templates"
@@ -78,13 +88,27 @@ type Command struct {
 	Usage       string
 	Run         func(args []string) error
 	Subcommands map[string]*Command
+	LocalOnly   bool // Works on this machine's files, worktrees or tmux, so it can't reach a daemon at daemon.AddrEnv
 }
 
 // CLI manages the command-line interface
 type CLI struct {
 	rootCmd       *Command
 	paths         *config.Paths
-	documentation string // Auto-generated CLI documentation for prompts
+	documentation string    // Auto-generated CLI documentation for prompts
+	token         string    // Socket client token from auth.ClientToken; empty for none
+	tokenErr      error     // Why auth.ClientToken failed; returned when a socket client is made
+	daemonAddr    string    // TCP address of a remote daemon from daemon.AddrEnv; empty for the local socket
+	out           io.Writer // Where command text goes; nil for os.Stdout
+	executable    string    // This binary, which serves output.CaptureCommand; empty for NewWithPaths
+
+	// JSON output mode (global --json flag or MULTICLAUDE_JSON)
+	jsonOutput    bool
+	jsonResult    interface{}
+	hasJSONResult bool
+	jsonStreaming bool      // the command called streamOutput
+	jsonStdout    io.Writer // where the JSON object is written
+	jsonNoColor   bool      // color.NoColor before JSON mode disabled colors
 }
 
 // New creates a new CLI
@@ -93,9 +117,18 @@ func New() (*CLI, error) {
 	if err != nil {
 		return nil, err
 	}
+	// An unreadable token only matters to commands that reach the daemon,
+	// so help and version still work
+	token, tokenErr := auth.ClientToken()
+	// Without it agent panes are still captured, just not rotated
+	executable, _ := os.Executable()
 
 	cli := &CLI{
-		paths: paths,
+		paths:      paths,
+		executable: executable,
+		token:      token,
+		tokenErr:   tokenErr,
+		daemonAddr: os.Getenv(daemon.AddrEnv),
 		rootCmd: &Command{
 			Name:        "multiclaude",
 			Description: "repo-centric orchestrator for Claude Code",
@@ -139,6 +172,17 @@ func (c *CLI) getClaudeBinary() (string, error) {
 	return binaryPath, nil
 }
 
+// claudeConfig returns the claude overrides configured for an agent type in
+// a repository, or the zero config if there are none
+func (c *CLI) claudeConfig(repoName string, agentType state.AgentType) state.ClaudeConfig {
+	st, err := state.Load(c.paths.StateFile)
+	if err != nil {
+		return state.ClaudeConfig{}
+	}
+	claudeConfig, _ := st.GetClaudeConfig(repoName, agentType)
+	return claudeConfig
+}
+
 // loadState loads the state file, wrapping errors with context
 func (c *CLI) loadState() (*state.State, error) {
 	st, err := state.Load(c.paths.StateFile)
@@ -148,10 +192,50 @@ func (c *CLI) loadState() (*state.State, error) {
 	return st, nil
 }
 
+// socketClient returns a client for the daemon's socket that sends the
+// CLI's token, if it has one
+func (c *CLI) socketClient() (*socket.Client, error) {
+	return c.newSocketClient(socket.NoRetry)
+}
+
+// newSocketClient returns a client that connects with the given retry
+// policy, over TCP if daemon.AddrEnv names a remote daemon and to the local
+// socket otherwise. It fails if the client token could not be read.
+func (c *CLI) newSocketClient(retry socket.RetryPolicy) (*socket.Client, error) {
+	if c.tokenErr != nil {
+		return nil, errors.Wrap(errors.CategoryConfig, "failed to read the socket client token", c.tokenErr)
+	}
+	opts := socket.ClientOptions{Token: c.token, Retry: retry}
+	if c.daemonAddr != "" {
+		return socket.NewNetworkClient(socket.NetworkTCP, c.daemonAddr, opts), nil
+	}
+	return socket.NewClientWithOptions(c.paths.DaemonSock, opts), nil
+}
+
+// daemonProcess reports whether the local daemon's PID file names a live
+// process. A daemon at daemon.AddrEnv has no local PID file, so it is taken
+// to be running, with PID 0, and a health check decides.
+func (c *CLI) daemonProcess() (running bool, pid int, err error) {
+	if c.daemonAddr != "" {
+		return true, 0, nil
+	}
+	return daemon.NewPIDFile(c.paths.DaemonPID).IsRunning()
+}
+
 // sendDaemonRequest sends a request to the daemon and handles common error cases.
 // It returns the response if successful, or an error if communication fails or the daemon returns an error.
 func (c *CLI) sendDaemonRequest(command string, args map[string]interface{}) (*socket.Response, error) {
-	client := socket.NewClient(c.paths.DaemonSock)
+	retry := socket.NoRetry
+	if c.daemonAddr == "" {
+		if running, _, _ := daemon.NewPIDFile(c.paths.DaemonPID).IsRunning(); running {
+			// A daemon that is up may still be (re)starting its socket
+			retry = socket.DefaultRetryPolicy
+		}
+	}
+	client, err := c.newSocketClient(retry)
+	if err != nil {
+		return nil, err
+	}
 	resp, err := client.Send(socket.Request{
 		Command: command,
 		Args:    args,
@@ -168,40 +252,35 @@ func (c *CLI) sendDaemonRequest(command string, args map[string]interface{}) (*s
 // removeDirectoryIfExists removes a directory and prints status messages.
 // It prints a warning if removal fails, or a success message if it succeeds.
 // If the directory doesn't exist, it does nothing.
-func removeDirectoryIfExists(path, description string) {
+func (c *CLI) removeDirectoryIfExists(path, description string) {
 	if _, err := os.Stat(path); err == nil {
 		if err := os.RemoveAll(path); err != nil {
-			fmt.Printf("  Warning: failed to remove %s: %v\n", description, err)
+			fmt.Fprintf(c.stdout(), "  Warning: failed to remove %s: %v\n", description, err)
 		} else {
-			fmt.Printf("  Removed %s\n", path)
+			fmt.Fprintf(c.stdout(), "  Removed %s\n", path)
 		}
 	}
 }
 
-// tmuxSanitizer replaces problematic characters with hyphens for tmux session names.
-// tmux has issues with dots, colons, spaces, and forward slashes in session names.
-var tmuxSanitizer = strings.NewReplacer(
-	".", "-",
-	":", "-",
-	" ", "-",
-	"/", "-",
-)
-
 // sanitizeTmuxSessionName creates a tmux-safe session name from a repo name.
 // tmux has issues with certain characters like dots, so we replace them.
 func sanitizeTmuxSessionName(repoName string) string {
-	// Strip control characters (ASCII 0-31) for safety
-	sanitized := strings.Map(func(r rune) rune {
-		if r < 32 {
-			return -1 // drop the character
-		}
-		return r
-	}, repoName)
-	return fmt.Sprintf("mc-%s", tmuxSanitizer.Replace(sanitized))
+	return names.TmuxSessionName(repoName)
 }
 
-// Execute executes the CLI with the given arguments
+// Execute executes the CLI with the given arguments. With the global --json
+// flag (or MULTICLAUDE_JSON set), the command writes one JSON object to
+// stdout instead of formatted text.
 func (c *CLI) Execute(args []string) error {
+	args, jsonOutput := extractJSONFlag(args)
+	if jsonOutput {
+		return c.executeJSON(args)
+	}
+	return c.execute(args)
+}
+
+// execute dispatches args to the top-level command
+func (c *CLI) execute(args []string) error {
 	if len(args) == 0 {
 		return c.showHelp()
 	}
@@ -216,29 +295,40 @@ func (c *CLI) Execute(args []string) error {
 
 // showVersion displays the version information
 func (c *CLI) showVersion() error {
-	fmt.Printf("multiclaude %s\n", GetVersion())
+	if c.jsonOutput {
+		c.setJSONResult(versionInfo())
+		return nil
+	}
+	fmt.Fprintf(c.stdout(), "multiclaude %s\n", GetVersion())
 	return nil
 }
 
+// versionInfo returns the version details reported in JSON output
+func versionInfo() map[string]interface{} {
+	return map[string]interface{}{
+		"version":    GetVersion(),
+		"isDev":      IsDevVersion(),
+		"rawVersion": Version,
+	}
+}
+
 // versionCommand displays version information with optional JSON output
 func (c *CLI) versionCommand(args []string) error {
 	flags, _ := ParseFlags(args)
 	outputJSON := flags["json"] == "true"
 
-	version := GetVersion()
+	if c.jsonOutput {
+		c.setJSONResult(versionInfo())
+		return nil
+	}
 
 	if outputJSON {
-		output := map[string]interface{}{
-			"version":    version,
-			"isDev":      IsDevVersion(),
-			"rawVersion": Version,
-		}
-		encoder := json.NewEncoder(os.Stdout)
+		encoder := json.NewEncoder(c.stdout())
 		encoder.SetIndent("", "  ")
-		return encoder.Encode(output)
+		return encoder.Encode(versionInfo())
 	}
 
-	fmt.Printf("multiclaude %s\n", version)
+	fmt.Fprintf(c.stdout(), "multiclaude %s\n", GetVersion())
 	return nil
 }
 
@@ -246,7 +336,7 @@ func (c *CLI) versionCommand(args []string) error {
 func (c *CLI) executeCommand(cmd *Command, args []string) error {
 	if len(args) == 0 {
 		if cmd.Run != nil {
-			return cmd.Run([]string{})
+			return c.runCommand(cmd, []string{})
 		}
 		return c.showCommandHelp(cmd)
 	}
@@ -263,48 +353,59 @@ func (c *CLI) executeCommand(cmd *Command, args []string) error {
 
 	// No subcommand found, run this command with args
 	if cmd.Run != nil {
-		return cmd.Run(args)
+		return c.runCommand(cmd, args)
 	}
 
 	return errors.UnknownCommand(args[0])
 }
 
+// runCommand runs cmd, refusing local-only commands when the CLI talks to
+// a remote daemon
+func (c *CLI) runCommand(cmd *Command, args []string) error {
+	if cmd.LocalOnly && c.daemonAddr != "" {
+		return errors.New(errors.CategoryUsage, fmt.Sprintf("'%s' works on this machine's multiclaude and can't be used with %s=%s", cmd.Name, daemon.AddrEnv, c.daemonAddr)).
+			WithSuggestion(fmt.Sprintf("unset %s, or run it on the daemon's host", daemon.AddrEnv))
+	}
+	return cmd.Run(args)
+}
+
 // showHelp shows the main help message
 func (c *CLI) showHelp() error {
-	fmt.Println("multiclaude - repo-centric orchestrator for Claude Code")
-	fmt.Println()
-	fmt.Println("Usage: multiclaude <command> [options]")
-	fmt.Println()
-	fmt.Println("Commands:")
+	fmt.Fprintln(c.stdout(), "multiclaude - repo-centric orchestrator for Claude Code")
+	fmt.Fprintln(c.stdout())
+	fmt.Fprintln(c.stdout(), "Usage: multiclaude <command> [options]")
+	fmt.Fprintln(c.stdout())
+	fmt.Fprintln(c.stdout(), "Commands:")
 
 	for name, cmd := range c.rootCmd.Subcommands {
-		fmt.Printf("  %-15s %s\n", name, cmd.Description)
+		fmt.Fprintf(c.stdout(), "  %-15s %s\n", name, cmd.Description)
 	}
 
-	fmt.Println()
-	fmt.Println("Use 'multiclaude <command> --help' for more information about a command.")
+	fmt.Fprintln(c.stdout())
+	fmt.Fprintln(c.stdout(), "Use 'multiclaude <command> --help' for more information about a command.")
+	fmt.Fprintln(c.stdout(), "Pass --json (or set MULTICLAUDE_JSON=1) to any command for JSON output.")
 	return nil
 }
 
 // showCommandHelp shows help for a specific command
 func (c *CLI) showCommandHelp(cmd *Command) error {
-	fmt.Printf("%s - %s\n", cmd.Name, cmd.Description)
-	fmt.Println()
+	fmt.Fprintf(c.stdout(), "%s - %s\n", cmd.Name, cmd.Description)
+	fmt.Fprintln(c.stdout())
 	if cmd.Usage != "" {
-		fmt.Printf("Usage: %s\n", cmd.Usage)
-		fmt.Println()
+		fmt.Fprintf(c.stdout(), "Usage: %s\n", cmd.Usage)
+		fmt.Fprintln(c.stdout())
 	}
 
 	if len(cmd.Subcommands) > 0 {
-		fmt.Println("Subcommands:")
+		fmt.Fprintln(c.stdout(), "Subcommands:")
 		for name, subcmd := range cmd.Subcommands {
 			// Skip internal commands (prefixed with _)
 			if strings.HasPrefix(name, "_") {
 				continue
 			}
-			fmt.Printf("  %-15s %s\n", name, subcmd.Description)
+			fmt.Fprintf(c.stdout(), "  %-15s %s\n", name, subcmd.Description)
 		}
-		fmt.Println()
+		fmt.Fprintln(c.stdout())
 	}
 
 	return nil
@@ -317,7 +418,8 @@ func (c *CLI) registerCommands() {
 	c.rootCmd.Subcommands["start"] = &Command{
 		Name:        "start",
 		Description: "Start the daemon (alias for 'daemon start')",
-		Usage:       "multiclaude start",
+		Usage:       "multiclaude start [--force]",
+		LocalOnly:   true,
 		Run:         c.startDaemon,
 	}
 
@@ -338,7 +440,8 @@ func (c *CLI) registerCommands() {
 	daemonCmd.Subcommands["start"] = &Command{
 		Name:        "start",
 		Description: "Start the daemon",
-		Usage:       "multiclaude daemon start",
+		Usage:       "multiclaude daemon start [--force]",
+		LocalOnly:   true,
 		Run:         c.startDaemon,
 	}
 
@@ -356,16 +459,32 @@ func (c *CLI) registerCommands() {
 		Run:         c.daemonStatus,
 	}
 
+	daemonCmd.Subcommands["drain"] = &Command{
+		Name:        "drain",
+		Description: "Stop accepting new agents until running ones finish",
+		Usage:       "multiclaude daemon drain",
+		Run:         c.drainDaemon,
+	}
+
+	daemonCmd.Subcommands["undrain"] = &Command{
+		Name:        "undrain",
+		Description: "Resume accepting new agents",
+		Usage:       "multiclaude daemon undrain",
+		Run:         c.undrainDaemon,
+	}
+
 	daemonCmd.Subcommands["logs"] = &Command{
 		Name:        "logs",
 		Description: "View daemon logs",
 		Usage:       "multiclaude daemon logs [-f|--follow] [-n <lines>]",
+		LocalOnly:   true,
 		Run:         c.daemonLogs,
 	}
 
 	daemonCmd.Subcommands["_run"] = &Command{
 		Name:        "_run",
 		Description: "Internal: run daemon in foreground (used by daemon start)",
+		LocalOnly:   true,
 		Run:         c.runDaemon,
 	}
 
@@ -376,6 +495,7 @@ func (c *CLI) registerCommands() {
 		Name:        "stop-all",
 		Description: "Stop daemon and kill all multiclaude tmux sessions",
 		Usage:       "multiclaude stop-all [--clean] [--yes]",
+		LocalOnly:   true,
 		Run:         c.stopAll,
 	}
 
@@ -390,6 +510,7 @@ func (c *CLI) registerCommands() {
 		Name:        "init",
 		Description: "Initialize a repository",
 		Usage:       "multiclaude repo init <github-url> [name] [--no-merge-queue] [--mq-track=all|author|assigned]",
+		LocalOnly:   true,
 		Run:         c.initRepo,
 	}
 
@@ -403,7 +524,8 @@ func (c *CLI) registerCommands() {
 	repoCmd.Subcommands["rm"] = &Command{
 		Name:        "rm",
 		Description: "Remove a tracked repository",
-		Usage:       "multiclaude repo rm <name>",
+		Usage:       "multiclaude repo rm <name> [--yes]",
+		LocalOnly:   true,
 		Run:         c.removeRepo,
 	}
 
@@ -439,6 +561,7 @@ func (c *CLI) registerCommands() {
 		Name:        "hibernate",
 		Description: "Hibernate a repository, archiving uncommitted changes",
 		Usage:       "multiclaude repo hibernate [--repo <repo>] [--all] [--yes]",
+		LocalOnly:   true,
 		Run:         c.hibernateRepo,
 	}
 
@@ -463,20 +586,22 @@ func (c *CLI) registerCommands() {
 		Name:        "create",
 		Description: "Create a new worker agent",
 		Usage:       "multiclaude worker create <task> [--repo <repo>] [--branch <branch>] [--push-to <branch>]",
+		LocalOnly:   true,
 		Run:         c.createWorker,
 	}
 
 	workerCmd.Subcommands["list"] = &Command{
 		Name:        "list",
 		Description: "List active workers",
-		Usage:       "multiclaude worker list [--repo <repo>]",
+		Usage:       "multiclaude worker list [--repo <repo>] [--status active|completed|failed]",
 		Run:         c.listWorkers,
 	}
 
 	workerCmd.Subcommands["rm"] = &Command{
 		Name:        "rm",
 		Description: "Remove a worker",
-		Usage:       "multiclaude worker rm <worker-name>",
+		Usage:       "multiclaude worker rm <worker-name> [--yes]",
+		LocalOnly:   true,
 		Run:         c.removeWorker,
 	}
 
@@ -499,13 +624,15 @@ func (c *CLI) registerCommands() {
 		Name:        "add",
 		Description: "Add a new workspace",
 		Usage:       "multiclaude workspace add <name> [--branch <branch>]",
+		LocalOnly:   true,
 		Run:         c.addWorkspace,
 	}
 
 	workspaceCmd.Subcommands["rm"] = &Command{
 		Name:        "rm",
 		Description: "Remove a workspace",
-		Usage:       "multiclaude workspace rm <name>",
+		Usage:       "multiclaude workspace rm <name> [--yes]",
+		LocalOnly:   true,
 		Run:         c.removeWorkspace,
 	}
 
@@ -520,6 +647,7 @@ func (c *CLI) registerCommands() {
 		Name:        "connect",
 		Description: "Connect to a workspace",
 		Usage:       "multiclaude workspace connect <name>",
+		LocalOnly:   true,
 		Run:         c.connectWorkspace,
 	}
 
@@ -538,6 +666,7 @@ func (c *CLI) registerCommands() {
 		Name:        "send-message",
 		Description: "Send a message to another agent (alias for 'message send')",
 		Usage:       "multiclaude agent send-message <recipient> <message>",
+		LocalOnly:   true,
 		Run:         c.sendMessage,
 	}
 
@@ -545,6 +674,7 @@ func (c *CLI) registerCommands() {
 		Name:        "list-messages",
 		Description: "List pending messages (alias for 'message list')",
 		Usage:       "multiclaude agent list-messages",
+		LocalOnly:   true,
 		Run:         c.listMessages,
 	}
 
@@ -552,6 +682,7 @@ func (c *CLI) registerCommands() {
 		Name:        "read-message",
 		Description: "Read a specific message (alias for 'message read')",
 		Usage:       "multiclaude agent read-message <message-id>",
+		LocalOnly:   true,
 		Run:         c.readMessage,
 	}
 
@@ -559,6 +690,7 @@ func (c *CLI) registerCommands() {
 		Name:        "ack-message",
 		Description: "Acknowledge a message (alias for 'message ack')",
 		Usage:       "multiclaude agent ack-message <message-id>",
+		LocalOnly:   true,
 		Run:         c.ackMessage,
 	}
 
@@ -569,6 +701,13 @@ func (c *CLI) registerCommands() {
 		Run:         c.completeWorker,
 	}
 
+	agentCmd.Subcommands["status"] = &Command{
+		Name:        "status",
+		Description: "Report that the agent is blocked, or running again",
+		Usage:       "multiclaude agent status <blocked|running> [--repo <repo>] [--agent <name>]",
+		Run:         c.setAgentStatus,
+	}
+
 	agentCmd.Subcommands["restart"] = &Command{
 		Name:        "restart",
 		Description: "Restart a crashed or exited agent",
@@ -576,10 +715,18 @@ func (c *CLI) registerCommands() {
 		Run:         c.restartAgentCmd,
 	}
 
+	agentCmd.Subcommands["kill"] = &Command{
+		Name:        "kill",
+		Description: "Gracefully stop an agent (SIGTERM, then SIGKILL after a grace period)",
+		Usage:       "multiclaude agent kill <name> [--repo <repo>] [--grace <duration>] [--dry-run]",
+		Run:         c.killAgentCmd,
+	}
+
 	agentCmd.Subcommands["attach"] = &Command{
 		Name:        "attach",
 		Description: "Attach to an agent's tmux window",
-		Usage:       "multiclaude agent attach <agent-name> [--read-only]",
+		Usage:       "multiclaude agent attach [<repo>] <agent-name> [--read-only]",
+		LocalOnly:   true,
 		Run:         c.attachAgent,
 	}
 
@@ -597,6 +744,7 @@ func (c *CLI) registerCommands() {
 		Name:        "send",
 		Description: "Send a message to another agent",
 		Usage:       "multiclaude message send <recipient> <message>",
+		LocalOnly:   true,
 		Run:         c.sendMessage,
 	}
 
@@ -604,6 +752,7 @@ func (c *CLI) registerCommands() {
 		Name:        "list",
 		Description: "List pending messages",
 		Usage:       "multiclaude message list",
+		LocalOnly:   true,
 		Run:         c.listMessages,
 	}
 
@@ -611,6 +760,7 @@ func (c *CLI) registerCommands() {
 		Name:        "read",
 		Description: "Read a specific message",
 		Usage:       "multiclaude message read <message-id>",
+		LocalOnly:   true,
 		Run:         c.readMessage,
 	}
 
@@ -618,11 +768,49 @@ func (c *CLI) registerCommands() {
 		Name:        "ack",
 		Description: "Acknowledge a message",
 		Usage:       "multiclaude message ack <message-id>",
+		LocalOnly:   true,
 		Run:         c.ackMessage,
 	}
 
 	c.rootCmd.Subcommands["message"] = messageCmd
 
+	// Merge queue commands (used by the merge-queue agent)
+	mqCmd := &Command{
+		Name:        "mq",
+		Description: "Manage the merge queue",
+		Subcommands: make(map[string]*Command),
+	}
+
+	mqCmd.Subcommands["list"] = &Command{
+		Name:        "list",
+		Description: "Show the merge queue, head first",
+		Usage:       "multiclaude mq list [--repo <repo>]",
+		Run:         c.listMergeQueue,
+	}
+
+	mqCmd.Subcommands["enqueue"] = &Command{
+		Name:        "enqueue",
+		Description: "Add a PR to the back of the merge queue",
+		Usage:       "multiclaude mq enqueue <pr-number> [--url <pr-url>] [--branch <branch>] [--repo <repo>]",
+		Run:         c.enqueueMerge,
+	}
+
+	mqCmd.Subcommands["claim"] = &Command{
+		Name:        "claim",
+		Description: "Claim the PR at the head of the merge queue",
+		Usage:       "multiclaude mq claim [--repo <repo>] [--agent <name>]",
+		Run:         c.claimMerge,
+	}
+
+	mqCmd.Subcommands["complete"] = &Command{
+		Name:        "complete",
+		Description: "Finish the claimed PR; --failure requeues it while retries remain",
+		Usage:       "multiclaude mq complete <pr-number> [--failure <reason>] [--repo <repo>]",
+		Run:         c.completeMerge,
+	}
+
+	c.rootCmd.Subcommands["mq"] = mqCmd
+
 	// 'attach' is an alias for 'agent attach' (backward compatibility)
 	c.rootCmd.Subcommands["attach"] = agentCmd.Subcommands["attach"]
 
@@ -630,17 +818,41 @@ func (c *CLI) registerCommands() {
 	c.rootCmd.Subcommands["cleanup"] = &Command{
 		Name:        "cleanup",
 		Description: "Clean up orphaned resources",
-		Usage:       "multiclaude cleanup [--dry-run] [--verbose] [--merged]",
+		Usage:       "multiclaude cleanup [--dry-run] [--verbose] [--merged] [--concurrency <n>]",
+		LocalOnly:   true,
 		Run:         c.cleanup,
 	}
 
+	c.rootCmd.Subcommands["report"] = &Command{
+		Name:        "report",
+		Description: "Show what every agent is working on across all repos",
+		Usage:       "multiclaude report [--json]",
+		Run:         c.taskReport,
+	}
+
 	c.rootCmd.Subcommands["repair"] = &Command{
 		Name:        "repair",
 		Description: "Repair state after crash",
 		Usage:       "multiclaude repair [--verbose]",
+		LocalOnly:   true,
 		Run:         c.repair,
 	}
 
+	c.rootCmd.Subcommands["reconcile"] = &Command{
+		Name:        "reconcile",
+		Description: "Update state to match running agents, windows, and worktrees",
+		Usage:       "multiclaude reconcile [--dry-run]",
+		Run:         c.reconcile,
+	}
+
+	c.rootCmd.Subcommands["selftest"] = &Command{
+		Name:        "selftest",
+		Description: "Check that the daemon can create, track, and remove an agent",
+		Usage:       "multiclaude selftest [--json]",
+		LocalOnly:   true,
+		Run:         c.selfTest,
+	}
+
 	c.rootCmd.Subcommands["refresh"] = &Command{
 		Name:        "refresh",
 		Description: "Sync agent worktrees with main branch",
@@ -653,6 +865,7 @@ func (c *CLI) registerCommands() {
 		Name:        "claude",
 		Description: "Restart Claude in current agent context",
 		Usage:       "multiclaude claude",
+		LocalOnly:   true,
 		Run:         c.restartClaude,
 	}
 
@@ -669,6 +882,7 @@ func (c *CLI) registerCommands() {
 		Name:        "review",
 		Description: "Spawn a review agent for a PR",
 		Usage:       "multiclaude review <pr-url>",
+		LocalOnly:   true,
 		Run:         c.reviewPR,
 	}
 
@@ -676,7 +890,7 @@ func (c *CLI) registerCommands() {
 	logsCmd := &Command{
 		Name:        "logs",
 		Description: "View and manage agent output logs",
-		Usage:       "multiclaude logs [<agent-name>] [-f|--follow]",
+		Usage:       "multiclaude logs [<repo>] <agent-name> [--lines N] [-f|--follow]",
 		Subcommands: make(map[string]*Command),
 	}
 
@@ -686,6 +900,7 @@ func (c *CLI) registerCommands() {
 		Name:        "list",
 		Description: "List log files",
 		Usage:       "multiclaude logs list [--repo <repo>]",
+		LocalOnly:   true,
 		Run:         c.listLogs,
 	}
 
@@ -693,6 +908,7 @@ func (c *CLI) registerCommands() {
 		Name:        "search",
 		Description: "Search across logs",
 		Usage:       "multiclaude logs search <pattern> [--repo <repo>]",
+		LocalOnly:   true,
 		Run:         c.searchLogs,
 	}
 
@@ -700,6 +916,7 @@ func (c *CLI) registerCommands() {
 		Name:        "clean",
 		Description: "Remove old logs",
 		Usage:       "multiclaude logs clean --older-than <duration>",
+		LocalOnly:   true,
 		Run:         c.cleanLogs,
 	}
 
@@ -709,7 +926,8 @@ func (c *CLI) registerCommands() {
 	c.rootCmd.Subcommands["config"] = &Command{
 		Name:        "config",
 		Description: "View or modify repository configuration",
-		Usage:       "multiclaude config [repo] [--mq-enabled=true|false] [--mq-track=all|author|assigned] [--ps-enabled=true|false] [--ps-track=all|author|assigned]",
+		Usage:       "multiclaude config [repo] [--mq-enabled=true|false] [--mq-track=all|author|assigned] [--mq-retries=N] [--ps-enabled=true|false] [--ps-track=all|author|assigned] [--max-workers=N] [--spawn-rate=N] [--spawn-burst=N]",
+		LocalOnly:   true,
 		Run:         c.configRepo,
 	}
 
@@ -718,6 +936,7 @@ func (c *CLI) registerCommands() {
 		Name:        "bug",
 		Description: "Generate a diagnostic bug report",
 		Usage:       "multiclaude bug [--output <file>] [--verbose] [description]",
+		LocalOnly:   true,
 		Run:         c.bugReport,
 	}
 
@@ -725,11 +944,19 @@ func (c *CLI) registerCommands() {
 	c.rootCmd.Subcommands["diagnostics"] = &Command{
 		Name:        "diagnostics",
 		Description: "Show system diagnostics in machine-readable format",
-		Usage:       "multiclaude diagnostics [--json] [--output <file>]",
+		Usage:       "multiclaude diagnostics [--json] [--format json|markdown|html] [--output <file>] [--offline] [--endpoints host:port,...]",
+		LocalOnly:   true,
 		Run:         c.diagnostics,
 	}
 
 	// Version command
+	c.rootCmd.Subcommands[output.CaptureCommand] = &Command{
+		Name:        output.CaptureCommand,
+		Description: "Internal: append stdin to an agent capture file, rotating it (used by tmux pipe-pane)",
+		LocalOnly:   true,
+		Run:         c.captureOutput,
+	}
+
 	c.rootCmd.Subcommands["version"] = &Command{
 		Name:        "version",
 		Description: "Show version information",
@@ -748,6 +975,7 @@ func (c *CLI) registerCommands() {
 		Name:        "list",
 		Description: "List available agent definitions for a repository",
 		Usage:       "multiclaude agents list [--repo <repo>]",
+		LocalOnly:   true,
 		Run:         c.listAgentDefinitions,
 	}
 
@@ -762,6 +990,7 @@ func (c *CLI) registerCommands() {
 		Name:        "reset",
 		Description: "Reset agent definitions to defaults (re-copy from templates)",
 		Usage:       "multiclaude agents reset [--repo <repo>]",
+		LocalOnly:   true,
 		Run:         c.resetAgentDefinitions,
 	}
 
@@ -771,11 +1000,14 @@ func (c *CLI) registerCommands() {
 // Daemon command implementations
 
 func (c *CLI) startDaemon(args []string) error {
-	return daemon.RunDetached()
+	flags, _ := ParseFlags(args)
+	return daemon.RunDetached(flags["force"] == "true")
 }
 
 func (c *CLI) runDaemon(args []string) error {
-	return daemon.Run()
+	flags, _ := ParseFlags(args)
+	daemon.Version = GetVersion()
+	return daemon.Run(flags["force"] == "true")
 }
 
 func (c *CLI) stopDaemon(args []string) error {
@@ -784,112 +1016,242 @@ func (c *CLI) stopDaemon(args []string) error {
 		return err
 	}
 
-	fmt.Println("Daemon stopped successfully")
+	fmt.Fprintln(c.stdout(), "Daemon stopped successfully")
+	return nil
+}
+
+func (c *CLI) drainDaemon(args []string) error {
+	resp, err := c.sendDaemonRequest("drain", nil)
+	if err != nil {
+		return err
+	}
+
+	data, _ := resp.Data.(map[string]interface{})
+	if c.jsonOutput {
+		c.setJSONResult(data)
+		return nil
+	}
+
+	remaining := 0
+	if v, ok := data["remaining"].(float64); ok {
+		remaining = int(v)
+	}
+	if draining, _ := data["draining"].(bool); !draining {
+		fmt.Fprintln(c.stdout(), "No agents running; drain complete")
+		return nil
+	}
+	fmt.Fprintf(c.stdout(), "Draining: new agents are rejected until %d running agent(s) finish\n", remaining)
+	format.DimmedTo(c.stdout(), "Cancel with: multiclaude daemon undrain")
+	return nil
+}
+
+func (c *CLI) undrainDaemon(args []string) error {
+	resp, err := c.sendDaemonRequest("undrain", nil)
+	if err != nil {
+		return err
+	}
+	if c.jsonOutput {
+		c.setJSONResult(resp.Data)
+		return nil
+	}
+
+	fmt.Fprintln(c.stdout(), "Daemon is accepting new agents")
 	return nil
 }
 
 func (c *CLI) daemonStatus(args []string) error {
 	// Check PID file first
-	pidFile := daemon.NewPIDFile(c.paths.DaemonPID)
-	running, pid, err := pidFile.IsRunning()
+	running, pid, err := c.daemonProcess()
 	if err != nil {
 		return fmt.Errorf("failed to check daemon status: %w", err)
 	}
 
 	if !running {
-		fmt.Println("Daemon is not running")
+		if c.jsonOutput {
+			c.setJSONResult(DaemonStatus{})
+			return nil
+		}
+		fmt.Fprintln(c.stdout(), "Daemon is not running")
+		return nil
+	}
+
+	// A live PID is not enough; the daemon must answer a request
+	health, err := c.daemonHealth()
+	if err != nil {
+		if c.jsonOutput {
+			c.setJSONResult(DaemonStatus{Running: true, PID: pid})
+			return nil
+		}
+		if c.daemonAddr != "" {
+			fmt.Fprintf(c.stdout(), "Daemon at %s is not responding: %v\n", c.daemonAddr, err)
+			return nil
+		}
+		fmt.Fprintf(c.stdout(), "Daemon PID file exists (PID: %d) but daemon is not responding: %v\n", pid, err)
 		return nil
 	}
 
-	// Try to connect to daemon
-	client := socket.NewClient(c.paths.DaemonSock)
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
 	resp, err := client.Send(socket.Request{
 		Command: "status",
 	})
 	if err != nil {
-		fmt.Printf("Daemon PID file exists (PID: %d) but daemon is not responding\n", pid)
-		return nil
+		return errors.DaemonCommunicationFailed("status", err)
 	}
 
 	if !resp.Success {
 		return fmt.Errorf("status check failed: %s", resp.Error)
 	}
 
+	if c.jsonOutput {
+		c.setJSONResult(DaemonStatus{Running: true, PID: pid, Responding: true, Status: resp.Data, Health: health})
+		return nil
+	}
+
 	// Pretty print status
-	fmt.Println("Daemon Status:")
+	fmt.Fprintln(c.stdout(), "Daemon Status:")
 	if statusMap, ok := resp.Data.(map[string]interface{}); ok {
-		fmt.Printf("  Running: %v\n", statusMap["running"])
-		fmt.Printf("  PID: %v\n", statusMap["pid"])
-		fmt.Printf("  Repos: %v\n", statusMap["repos"])
-		fmt.Printf("  Agents: %v\n", statusMap["agents"])
-		fmt.Printf("  Socket: %v\n", statusMap["socket_path"])
+		fmt.Fprintf(c.stdout(), "  Running: %v\n", statusMap["running"])
+		fmt.Fprintf(c.stdout(), "  PID: %v\n", statusMap["pid"])
+		fmt.Fprintf(c.stdout(), "  Repos: %v\n", statusMap["repos"])
+		fmt.Fprintf(c.stdout(), "  Agents: %v\n", statusMap["agents"])
+		fmt.Fprintf(c.stdout(), "  Socket: %v\n", statusMap["socket_path"])
+		if uptime, ok := statusMap["uptime"]; ok {
+			fmt.Fprintf(c.stdout(), "  Uptime: %v (start #%v, %v)\n", uptime, statusMap["starts"], statusMap["start_reason"])
+		}
+		if draining, _ := statusMap["draining"].(bool); draining {
+			fmt.Fprintf(c.stdout(), "  Draining: %v\n", draining)
+		}
+		fmt.Fprintf(c.stdout(), "  Goroutines: %v\n", health["goroutines"])
+		if lastError, ok := health["last_error"].(string); ok {
+			fmt.Fprintf(c.stdout(), "  Last error: %s\n", lastError)
+		}
 	} else {
 		// Fallback: print as JSON
 		jsonData, _ := json.MarshalIndent(resp.Data, "  ", "  ")
-		fmt.Println(string(jsonData))
+		fmt.Fprintln(c.stdout(), string(jsonData))
 	}
 
 	return nil
 }
 
+// DaemonStatus is the JSON result of `multiclaude daemon status`
+type DaemonStatus struct {
+	Running    bool        `json:"running"`
+	PID        int         `json:"pid,omitempty"`
+	Responding bool        `json:"responding"`
+	Status     interface{} `json:"status,omitempty"` // the daemon's status response
+	// Health is the daemon's health response; Responding is only set when
+	// the health round-trip succeeded
+	Health map[string]interface{} `json:"health,omitempty"`
+}
+
+// daemonHealthTimeout bounds the health round-trip. A daemon that can't
+// answer in this time is reported as unhealthy.
+const daemonHealthTimeout = 5 * time.Second
+
+// daemonHealth round-trips a health request. An error means the daemon is
+// not serving requests, whatever its PID file says.
+func (c *CLI) daemonHealth() (map[string]interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), daemonHealthTimeout)
+	defer cancel()
+
+	client, err := c.socketClient()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.SendContext(ctx, socket.Request{Command: "health"})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("health check failed: %s", resp.Error)
+	}
+	health, _ := resp.Data.(map[string]interface{})
+	return health, nil
+}
+
+// SystemStatus is the JSON result of `multiclaude status`
+type SystemStatus struct {
+	Daemon      DaemonStatus  `json:"daemon"`
+	DaemonError string        `json:"daemon_error,omitempty"`
+	Repos       []interface{} `json:"repos"` // rich list_repos entries
+}
+
 // systemStatus shows a comprehensive system overview that gracefully handles
 // the daemon not running (unlike list commands which error).
 func (c *CLI) systemStatus(args []string) error {
 	// Check PID file first
-	pidFile := daemon.NewPIDFile(c.paths.DaemonPID)
-	running, pid, err := pidFile.IsRunning()
+	running, pid, err := c.daemonProcess()
 	if err != nil {
 		return fmt.Errorf("failed to check daemon status: %w", err)
 	}
 
+	if c.jsonOutput {
+		c.setJSONResult(c.collectSystemStatus(running, pid))
+		return nil
+	}
+
 	if !running {
-		format.Header("Multiclaude Status")
-		fmt.Println()
-		fmt.Printf("  Daemon: %s\n", format.Red.Sprint("not running"))
-		fmt.Println()
-		format.Dimmed("Start with: multiclaude daemon start")
+		format.HeaderTo(c.stdout(), "Multiclaude Status")
+		fmt.Fprintln(c.stdout())
+		fmt.Fprintf(c.stdout(), "  Daemon: %s\n", format.Red.Sprint("not running"))
+		fmt.Fprintln(c.stdout())
+		format.DimmedTo(c.stdout(), "Start with: multiclaude daemon start")
 		return nil
 	}
 
-	// Try to connect to daemon and get rich status
-	client := socket.NewClient(c.paths.DaemonSock)
-	resp, err := client.Send(socket.Request{
-		Command: "list_repos",
-		Args:    map[string]interface{}{"rich": true},
-	})
+	// Confirm the daemon answers requests before asking for rich status
+	var resp *socket.Response
+	_, err = c.daemonHealth()
+	if err == nil {
+		var client *socket.Client
+		if client, err = c.socketClient(); err == nil {
+			resp, err = client.Send(socket.Request{
+				Command: "list_repos",
+				Args:    map[string]interface{}{"rich": true},
+			})
+		}
+	}
 
 	if err != nil {
-		format.Header("Multiclaude Status")
-		fmt.Println()
-		fmt.Printf("  Daemon: %s (PID: %d, not responding)\n", format.Yellow.Sprint("unhealthy"), pid)
-		fmt.Println()
-		format.Dimmed("Try: multiclaude daemon stop && multiclaude daemon start")
+		format.HeaderTo(c.stdout(), "Multiclaude Status")
+		fmt.Fprintln(c.stdout())
+		if c.daemonAddr != "" {
+			fmt.Fprintf(c.stdout(), "  Daemon: %s (%s, not responding: %v)\n", format.Yellow.Sprint("unreachable"), c.daemonAddr, err)
+			return nil
+		}
+		fmt.Fprintf(c.stdout(), "  Daemon: %s (PID: %d, not responding)\n", format.Yellow.Sprint("unhealthy"), pid)
+		fmt.Fprintln(c.stdout())
+		format.DimmedTo(c.stdout(), "Try: multiclaude daemon stop && multiclaude daemon start")
 		return nil
 	}
 
 	if !resp.Success {
-		format.Header("Multiclaude Status")
-		fmt.Println()
-		fmt.Printf("  Daemon: %s (PID: %d)\n", format.Yellow.Sprint("error"), pid)
-		fmt.Printf("  Error: %s\n", resp.Error)
+		format.HeaderTo(c.stdout(), "Multiclaude Status")
+		fmt.Fprintln(c.stdout())
+		fmt.Fprintf(c.stdout(), "  Daemon: %s (PID: %d)\n", format.Yellow.Sprint("error"), pid)
+		fmt.Fprintf(c.stdout(), "  Error: %s\n", resp.Error)
 		return nil
 	}
 
 	// Print status header
-	format.Header("Multiclaude Status")
-	fmt.Println()
-	fmt.Printf("  Daemon: %s (PID: %d)\n", format.Green.Sprint("running"), pid)
+	format.HeaderTo(c.stdout(), "Multiclaude Status")
+	fmt.Fprintln(c.stdout())
+	fmt.Fprintf(c.stdout(), "  Daemon: %s (PID: %d)\n", format.Green.Sprint("running"), pid)
 
 	repos, ok := resp.Data.([]interface{})
 	if !ok || len(repos) == 0 {
-		fmt.Printf("  Repos:  %s\n", format.Dim.Sprint("none"))
-		fmt.Println()
-		format.Dimmed("Initialize a repo with: multiclaude init <github-url>")
+		fmt.Fprintf(c.stdout(), "  Repos:  %s\n", format.Dim.Sprint("none"))
+		fmt.Fprintln(c.stdout())
+		format.DimmedTo(c.stdout(), "Initialize a repo with: multiclaude init <github-url>")
 		return nil
 	}
 
-	fmt.Printf("  Repos:  %d\n", len(repos))
-	fmt.Println()
+	fmt.Fprintf(c.stdout(), "  Repos:  %d\n", len(repos))
+	fmt.Fprintln(c.stdout())
 
 	// Show each repo with agents
 	for _, repo := range repos {
@@ -914,30 +1276,67 @@ func (c *CLI) systemStatus(args []string) error {
 		if !sessionHealthy {
 			repoStatus = format.Yellow.Sprint("○")
 		}
-		fmt.Printf("  %s %s\n", repoStatus, format.Bold.Sprint(name))
+		fmt.Fprintf(c.stdout(), "  %s %s\n", repoStatus, format.Bold.Sprint(name))
 
 		// Agent summary
 		coreAgents := totalAgents - workerCount
 		if coreAgents < 0 {
 			coreAgents = 0
 		}
-		fmt.Printf("      Agents: %d core, %d workers\n", coreAgents, workerCount)
+		fmt.Fprintf(c.stdout(), "      Agents: %d core, %d workers\n", coreAgents, workerCount)
 
 		// Show fork info if applicable
 		if isFork, _ := repoMap["is_fork"].(bool); isFork {
 			upstreamOwner, _ := repoMap["upstream_owner"].(string)
 			upstreamRepo, _ := repoMap["upstream_repo"].(string)
 			if upstreamOwner != "" && upstreamRepo != "" {
-				fmt.Printf("      Fork of: %s/%s\n", upstreamOwner, upstreamRepo)
+				fmt.Fprintf(c.stdout(), "      Fork of: %s/%s\n", upstreamOwner, upstreamRepo)
 			}
 		}
 	}
 
-	fmt.Println()
-	format.Dimmed("Details: multiclaude repo list | multiclaude worker list")
+	fmt.Fprintln(c.stdout())
+	format.DimmedTo(c.stdout(), "Details: multiclaude repo list | multiclaude worker list")
 	return nil
 }
 
+// collectSystemStatus gathers the data shown by `multiclaude status`
+func (c *CLI) collectSystemStatus(running bool, pid int) SystemStatus {
+	status := SystemStatus{Repos: []interface{}{}}
+	if !running {
+		return status
+	}
+	status.Daemon = DaemonStatus{Running: true, PID: pid}
+
+	health, err := c.daemonHealth()
+	if err != nil {
+		return status
+	}
+	status.Daemon.Health = health
+
+	client, err := c.socketClient()
+	if err != nil {
+		return status
+	}
+	resp, err := client.Send(socket.Request{
+		Command: "list_repos",
+		Args:    map[string]interface{}{"rich": true},
+	})
+	if err != nil {
+		return status
+	}
+	status.Daemon.Responding = true
+	if !resp.Success {
+		status.DaemonError = resp.Error
+		return status
+	}
+
+	if repos, ok := resp.Data.([]interface{}); ok {
+		status.Repos = repos
+	}
+	return status
+}
+
 func (c *CLI) daemonLogs(args []string) error {
 	flags, _ := ParseFlags(args)
 
@@ -945,6 +1344,18 @@ func (c *CLI) daemonLogs(args []string) error {
 	follow := flags["follow"] == "true" || flags["f"] == "true"
 
 	if follow {
+		c.streamOutput()
+
+		// Stream from the daemon when it is running, so the log can be
+		// followed through the socket without access to the file
+		client, err := c.socketClient()
+		if err != nil {
+			return err
+		}
+		if _, err := client.Send(socket.Request{Command: "ping"}); err == nil {
+			return c.followDaemonLogs()
+		}
+
 		// Use tail -f to follow logs
 		cmd := exec.Command("tail", "-f", c.paths.DaemonLog)
 		cmd.Stdout = os.Stdout
@@ -959,11 +1370,45 @@ func (c *CLI) daemonLogs(args []string) error {
 	}
 
 	cmd := exec.Command("tail", "-n", lines, c.paths.DaemonLog)
-	cmd.Stdout = os.Stdout
+	cmd.Stdout = c.stdout()
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
 }
 
+// followDaemonLogs streams the daemon log from the daemon until interrupted
+func (c *CLI) followDaemonLogs() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
+	resp, err := client.SendStream(ctx, socket.Request{
+		Command: "daemon_logs",
+		Args: map[string]interface{}{
+			"follow": true,
+			"lines":  10,
+		},
+	}, func(msg socket.Response) error {
+		if line, ok := msg.Data.(string); ok {
+			fmt.Fprintln(c.stdout(), line)
+		}
+		return nil
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			// Interrupted by the user
+			return nil
+		}
+		return errors.DaemonCommunicationFailed("streaming daemon logs", err)
+	}
+	if !resp.Success {
+		return errors.Wrap(errors.CategoryRuntime, "failed to stream daemon logs", fmt.Errorf("%s", resp.Error))
+	}
+	return nil
+}
+
 func (c *CLI) stopAll(args []string) error {
 	flags, _ := ParseFlags(args)
 	clean := flags["clean"] == "true"
@@ -971,7 +1416,10 @@ func (c *CLI) stopAll(args []string) error {
 
 	// Get list of repos (try daemon first, then state file)
 	var repos []string
-	client := socket.NewClient(c.paths.DaemonSock)
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
 	resp, err := client.Send(socket.Request{Command: "list_repos"})
 	if err == nil && resp.Success {
 		// Daemon is running, get repos from it
@@ -992,22 +1440,25 @@ func (c *CLI) stopAll(args []string) error {
 
 	// If --clean is specified, require confirmation
 	if clean {
-		fmt.Println("WARNING: This will permanently delete:")
-		fmt.Println("  - All worktrees (~/.multiclaude/wts/)")
-		fmt.Println("  - All agent state (state.json agents section)")
-		fmt.Println("  - All message queues (~/.multiclaude/messages/)")
-		fmt.Println("  - All output logs (~/.multiclaude/output/)")
-		fmt.Println("  - All agent configs (~/.multiclaude/claude-config/)")
-		fmt.Println("  - All prompts (~/.multiclaude/prompts/)")
-		fmt.Println("  - Local branches (work/*, multiclaude/*)")
-		fmt.Println()
-		fmt.Println("The following will be PRESERVED:")
-		fmt.Println("  - Cloned repositories (~/.multiclaude/repos/)")
-		fmt.Println("  - Git credentials")
-		fmt.Println()
+		fmt.Fprintln(c.stdout(), "WARNING: This will permanently delete:")
+		fmt.Fprintln(c.stdout(), "  - All worktrees (~/.multiclaude/wts/)")
+		fmt.Fprintln(c.stdout(), "  - All agent state (state.json agents section)")
+		fmt.Fprintln(c.stdout(), "  - All message queues (~/.multiclaude/messages/)")
+		fmt.Fprintln(c.stdout(), "  - All output logs (~/.multiclaude/output/)")
+		fmt.Fprintln(c.stdout(), "  - All agent configs (~/.multiclaude/claude-config/)")
+		fmt.Fprintln(c.stdout(), "  - All prompts (~/.multiclaude/prompts/)")
+		fmt.Fprintln(c.stdout(), "  - Local branches (work/*, multiclaude/*)")
+		fmt.Fprintln(c.stdout())
+		fmt.Fprintln(c.stdout(), "The following will be PRESERVED:")
+		fmt.Fprintln(c.stdout(), "  - Cloned repositories (~/.multiclaude/repos/)")
+		fmt.Fprintln(c.stdout(), "  - Git credentials")
+		fmt.Fprintln(c.stdout())
 
 		if !skipConfirm {
-			fmt.Print("Type 'NUKE' to confirm: ")
+			if c.jsonOutput {
+				return errConfirmationRequired
+			}
+			fmt.Fprint(c.stdout(), "Type 'NUKE' to confirm: ")
 			reader := bufio.NewReader(os.Stdin)
 			input, err := reader.ReadString('\n')
 			if err != nil {
@@ -1015,14 +1466,14 @@ func (c *CLI) stopAll(args []string) error {
 			}
 			input = strings.TrimSpace(input)
 			if input != "NUKE" {
-				fmt.Println("Aborted.")
+				fmt.Fprintln(c.stdout(), "Aborted.")
 				return nil
 			}
-			fmt.Println()
+			fmt.Fprintln(c.stdout())
 		}
 	}
 
-	fmt.Println("Stopping all multiclaude sessions...")
+	fmt.Fprintln(c.stdout(), "Stopping all multiclaude sessions...")
 
 	// Kill all multiclaude tmux sessions
 	tmuxClient := tmux.NewClient()
@@ -1031,9 +1482,9 @@ func (c *CLI) stopAll(args []string) error {
 			sessionName := fmt.Sprintf("mc-%s", repo)
 			exists, err := tmuxClient.HasSession(context.Background(), sessionName)
 			if err == nil && exists {
-				fmt.Printf("Killing tmux session: %s\n", sessionName)
+				fmt.Fprintf(c.stdout(), "Killing tmux session: %s\n", sessionName)
 				if err := tmuxClient.KillSession(context.Background(), sessionName); err != nil {
-					fmt.Printf("Warning: failed to kill session %s: %v\n", sessionName, err)
+					fmt.Fprintf(c.stdout(), "Warning: failed to kill session %s: %v\n", sessionName, err)
 				}
 			}
 		}
@@ -1051,9 +1502,9 @@ func (c *CLI) stopAll(args []string) error {
 						}
 					}
 					if !exists {
-						fmt.Printf("Killing orphaned tmux session: %s\n", session)
+						fmt.Fprintf(c.stdout(), "Killing orphaned tmux session: %s\n", session)
 						if err := tmuxClient.KillSession(context.Background(), session); err != nil {
-							fmt.Printf("Warning: failed to kill session %s: %v\n", session, err)
+							fmt.Fprintf(c.stdout(), "Warning: failed to kill session %s: %v\n", session, err)
 						}
 					}
 				}
@@ -1062,53 +1513,53 @@ func (c *CLI) stopAll(args []string) error {
 	}
 
 	// Stop the daemon
-	fmt.Println("Stopping daemon...")
+	fmt.Fprintln(c.stdout(), "Stopping daemon...")
 	resp, err = client.Send(socket.Request{Command: "stop"})
 	if err != nil {
-		fmt.Printf("Daemon already stopped or not responding\n")
+		fmt.Fprintf(c.stdout(), "Daemon already stopped or not responding\n")
 	} else if resp.Success {
-		fmt.Println("Daemon stopped")
+		fmt.Fprintln(c.stdout(), "Daemon stopped")
 	}
 
 	// Full cleanup if --clean is specified
 	if clean {
 		// Remove worktrees directory
-		fmt.Println("\nRemoving worktrees...")
-		removeDirectoryIfExists(c.paths.WorktreesDir, "worktrees")
+		fmt.Fprintln(c.stdout(), "\nRemoving worktrees...")
+		c.removeDirectoryIfExists(c.paths.WorktreesDir, "worktrees")
 
 		// Remove messages directory
-		fmt.Println("Removing messages...")
-		removeDirectoryIfExists(c.paths.MessagesDir, "messages")
+		fmt.Fprintln(c.stdout(), "Removing messages...")
+		c.removeDirectoryIfExists(c.paths.MessagesDir, "messages")
 
 		// Remove output logs
-		fmt.Println("Removing output logs...")
-		removeDirectoryIfExists(c.paths.OutputDir, "output logs")
+		fmt.Fprintln(c.stdout(), "Removing output logs...")
+		c.removeDirectoryIfExists(c.paths.OutputDir, "output logs")
 
 		// Remove claude config (per-agent settings)
-		fmt.Println("Removing agent configs...")
-		removeDirectoryIfExists(c.paths.ClaudeConfigDir, "agent configs")
+		fmt.Fprintln(c.stdout(), "Removing agent configs...")
+		c.removeDirectoryIfExists(c.paths.ClaudeConfigDir, "agent configs")
 
 		// Remove prompts directory
-		fmt.Println("Removing prompts...")
-		promptsDir := filepath.Join(c.paths.Root, "prompts")
-		removeDirectoryIfExists(promptsDir, "prompts")
+		fmt.Fprintln(c.stdout(), "Removing prompts...")
+		promptsDir := c.paths.PromptsDir()
+		c.removeDirectoryIfExists(promptsDir, "prompts")
 
 		// Clean up local branches in each repository
-		fmt.Println("\nCleaning up local branches...")
+		fmt.Fprintln(c.stdout(), "\nCleaning up local branches...")
 		for _, repoName := range repos {
 			repoPath := c.paths.RepoDir(repoName)
 			if _, err := os.Stat(repoPath); os.IsNotExist(err) {
 				continue
 			}
 
-			fmt.Printf("  Repository: %s\n", repoName)
+			fmt.Fprintf(c.stdout(), "  Repository: %s\n", repoName)
 
 			// Delete work/* and multiclaude/* branches
 			wt := worktree.NewManager(repoPath)
 			for _, prefix := range []string{"work/", "multiclaude/"} {
 				branches, err := c.listBranchesWithPrefix(repoPath, prefix)
 				if err != nil {
-					fmt.Printf("    Warning: failed to list %s branches: %v\n", prefix, err)
+					fmt.Fprintf(c.stdout(), "    Warning: failed to list %s branches: %v\n", prefix, err)
 					continue
 				}
 				for _, branch := range branches {
@@ -1118,42 +1569,44 @@ func (c *CLI) stopAll(args []string) error {
 					}
 					// Delete the branch
 					if err := c.deleteBranch(repoPath, branch); err != nil {
-						fmt.Printf("    Warning: failed to delete branch %s: %v\n", branch, err)
+						fmt.Fprintf(c.stdout(), "    Warning: failed to delete branch %s: %v\n", branch, err)
 					} else {
-						fmt.Printf("    Deleted branch: %s\n", branch)
+						fmt.Fprintf(c.stdout(), "    Deleted branch: %s\n", branch)
 					}
 				}
 			}
 
 			// Prune worktrees
 			if err := wt.Prune(); err != nil {
-				fmt.Printf("    Warning: failed to prune worktrees: %v\n", err)
+				fmt.Fprintf(c.stdout(), "    Warning: failed to prune worktrees: %v\n", err)
 			}
 		}
 
 		// Clear agent state but preserve repository entries
-		fmt.Println("\nClearing agent state...")
+		fmt.Fprintln(c.stdout(), "\nClearing agent state...")
 		st, err := state.Load(c.paths.StateFile)
 		if err == nil {
-			st.ClearAllAgents()
-			if err := st.Save(); err != nil {
-				fmt.Printf("  Warning: failed to save state: %v\n", err)
+			err := st.LockedUpdate(func(st *state.State) error {
+				return st.ClearAllAgents()
+			})
+			if err != nil {
+				fmt.Fprintf(c.stdout(), "  Warning: failed to save state: %v\n", err)
 			} else {
-				fmt.Println("  Cleared all agents from state")
+				fmt.Fprintln(c.stdout(), "  Cleared all agents from state")
 			}
 		}
 
 		// Remove daemon files (they'll be recreated on next start)
-		fmt.Println("Cleaning up daemon files...")
+		fmt.Fprintln(c.stdout(), "Cleaning up daemon files...")
 		os.Remove(c.paths.DaemonPID)
 		os.Remove(c.paths.DaemonSock)
 		os.Remove(c.paths.DaemonLog)
 
-		fmt.Println("\n✓ Full cleanup complete! Multiclaude has been reset to a clean state.")
-		fmt.Println("Your repositories are preserved at:", c.paths.ReposDir)
-		fmt.Println("\nRun 'multiclaude daemon start' to begin fresh.")
+		fmt.Fprintln(c.stdout(), "\n✓ Full cleanup complete! Multiclaude has been reset to a clean state.")
+		fmt.Fprintln(c.stdout(), "Your repositories are preserved at:", c.paths.ReposDir)
+		fmt.Fprintln(c.stdout(), "\nRun 'multiclaude daemon start' to begin fresh.")
 	} else {
-		fmt.Println("\n✓ All multiclaude sessions stopped")
+		fmt.Fprintln(c.stdout(), "\n✓ All multiclaude sessions stopped")
 	}
 
 	return nil
@@ -1209,16 +1662,19 @@ func (c *CLI) initRepo(args []string) error {
 		TrackMode: mqTrackMode,
 	}
 
-	fmt.Printf("Initializing repository: %s\n", repoName)
-	fmt.Printf("GitHub URL: %s\n", githubURL)
+	fmt.Fprintf(c.stdout(), "Initializing repository: %s\n", repoName)
+	fmt.Fprintf(c.stdout(), "GitHub URL: %s\n", githubURL)
 	if mqEnabled {
-		fmt.Printf("Merge queue: enabled (tracking: %s)\n", mqTrackMode)
+		fmt.Fprintf(c.stdout(), "Merge queue: enabled (tracking: %s)\n", mqTrackMode)
 	} else {
-		fmt.Printf("Merge queue: disabled\n")
+		fmt.Fprintf(c.stdout(), "Merge queue: disabled\n")
 	}
 
 	// Check if daemon is running
-	client := socket.NewClient(c.paths.DaemonSock)
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
 	if _, err := client.Send(socket.Request{Command: "ping"}); err != nil {
 		return errors.DaemonNotRunning()
 	}
@@ -1239,13 +1695,13 @@ func (c *CLI) initRepo(args []string) error {
 	}
 	tmuxClient := tmux.NewClient()
 	if exists, err := tmuxClient.HasSession(context.Background(), tmuxSession); err == nil && exists {
-		fmt.Printf("Warning: Tmux session '%s' already exists\n", tmuxSession)
-		fmt.Printf("This may be from a previous incomplete initialization.\n")
-		fmt.Printf("Auto-repairing: killing existing tmux session...\n")
+		fmt.Fprintf(c.stdout(), "Warning: Tmux session '%s' already exists\n", tmuxSession)
+		fmt.Fprintf(c.stdout(), "This may be from a previous incomplete initialization.\n")
+		fmt.Fprintf(c.stdout(), "Auto-repairing: killing existing tmux session...\n")
 		if err := tmuxClient.KillSession(context.Background(), tmuxSession); err != nil {
 			return fmt.Errorf("failed to clean up existing tmux session: %w\nPlease manually kill it with: tmux kill-session -t %s", err, tmuxSession)
 		}
-		fmt.Println("✓ Cleaned up stale tmux session")
+		fmt.Fprintln(c.stdout(), "✓ Cleaned up stale tmux session")
 	}
 
 	// Check if repository directory already exists
@@ -1255,10 +1711,10 @@ func (c *CLI) initRepo(args []string) error {
 	}
 
 	// Clone repository
-	fmt.Printf("Cloning to: %s\n", repoPath)
+	fmt.Fprintf(c.stdout(), "Cloning to: %s\n", repoPath)
 
 	cmd := exec.Command("git", "clone", githubURL, repoPath)
-	cmd.Stdout = os.Stdout
+	cmd.Stdout = c.stdout()
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
 		return errors.GitOperationFailed("clone", err)
@@ -1267,14 +1723,14 @@ func (c *CLI) initRepo(args []string) error {
 	// Detect if this is a fork
 	forkInfo, err := fork.DetectFork(repoPath)
 	if err != nil {
-		fmt.Printf("Warning: Failed to detect fork status: %v\n", err)
+		fmt.Fprintf(c.stdout(), "Warning: Failed to detect fork status: %v\n", err)
 		forkInfo = &fork.ForkInfo{IsFork: false}
 	}
 
 	// Store fork config
 	var forkConfig state.ForkConfig
 	if forkInfo.IsFork {
-		fmt.Printf("Detected fork of %s/%s\n", forkInfo.UpstreamOwner, forkInfo.UpstreamRepo)
+		fmt.Fprintf(c.stdout(), "Detected fork of %s/%s\n", forkInfo.UpstreamOwner, forkInfo.UpstreamRepo)
 		forkConfig = state.ForkConfig{
 			IsFork:        true,
 			UpstreamURL:   forkInfo.UpstreamURL,
@@ -1284,9 +1740,9 @@ func (c *CLI) initRepo(args []string) error {
 
 		// Add upstream remote if not already present
 		if !fork.HasUpstreamRemote(repoPath) {
-			fmt.Printf("Adding upstream remote: %s\n", forkInfo.UpstreamURL)
+			fmt.Fprintf(c.stdout(), "Adding upstream remote: %s\n", forkInfo.UpstreamURL)
 			if err := fork.AddUpstreamRemote(repoPath, forkInfo.UpstreamURL); err != nil {
-				fmt.Printf("Warning: Failed to add upstream remote: %v\n", err)
+				fmt.Fprintf(c.stdout(), "Warning: Failed to add upstream remote: %v\n", err)
 			}
 		}
 
@@ -1301,19 +1757,22 @@ func (c *CLI) initRepo(args []string) error {
 
 	// Copy agent templates to per-repo agents directory
 	agentsDir := c.paths.RepoAgentsDir(repoName)
-	fmt.Printf("Copying agent templates to: %s\n", agentsDir)
+	fmt.Fprintf(c.stdout(), "Copying agent templates to: %s\n", agentsDir)
 	if err := templates.CopyAgentTemplates(agentsDir); err != nil {
 		return fmt.Errorf("failed to copy agent templates: %w", err)
 	}
 
 	// Create tmux session (tmuxSession already defined and validated earlier)
-	fmt.Printf("Creating tmux session: %s\n", tmuxSession)
+	fmt.Fprintf(c.stdout(), "Creating tmux session: %s\n", tmuxSession)
 
 	// Create session with supervisor window
 	cmd = exec.Command("tmux", "new-session", "-d", "-s", tmuxSession, "-n", "supervisor", "-c", repoPath)
 	if err := cmd.Run(); err != nil {
 		return errors.TmuxOperationFailed("create session", err)
 	}
+	if err := cleanup.MarkSession(context.Background(), tmux.NewClient(), tmuxSession, c.paths.Root); err != nil {
+		fmt.Fprintf(c.stdout(), "Warning: %v\n", err)
+	}
 
 	// Create merge-queue or pr-shepherd window based on mode
 	if mqEnabled {
@@ -1368,7 +1827,7 @@ func (c *CLI) initRepo(args []string) error {
 
 	// Copy hooks configuration if it exists (for supervisor and merge-queue)
 	if err := hooks.CopyConfig(repoPath, repoPath); err != nil {
-		fmt.Printf("Warning: failed to copy hooks config: %v\n", err)
+		fmt.Fprintf(c.stdout(), "Warning: failed to copy hooks config: %v\n", err)
 	}
 
 	// Start Claude in supervisor window (skip in test mode)
@@ -1380,8 +1839,8 @@ func (c *CLI) initRepo(args []string) error {
 			return fmt.Errorf("failed to resolve claude binary: %w", err)
 		}
 
-		fmt.Println("Starting Claude Code in supervisor window...")
-		pid, err := c.startClaudeInTmux(claudeBinary, tmuxSession, "supervisor", repoPath, supervisorSessionID, supervisorPromptFile, repoName, "")
+		fmt.Fprintln(c.stdout(), "Starting Claude Code in supervisor window...")
+		pid, err := c.startClaudeInTmux(claudeBinary, tmuxSession, "supervisor", repoPath, supervisorSessionID, supervisorPromptFile, repoName, state.AgentTypeSupervisor, "")
 		if err != nil {
 			return fmt.Errorf("failed to start supervisor Claude: %w", err)
 		}
@@ -1389,13 +1848,13 @@ func (c *CLI) initRepo(args []string) error {
 
 		// Set up output capture for supervisor
 		if err := c.setupOutputCapture(tmuxSession, "supervisor", repoName, "supervisor", "supervisor"); err != nil {
-			fmt.Printf("Warning: failed to setup output capture for supervisor: %v\n", err)
+			fmt.Fprintf(c.stdout(), "Warning: failed to setup output capture for supervisor: %v\n", err)
 		}
 
 		// Start Claude in merge-queue window only if enabled
 		if mqEnabled {
-			fmt.Println("Starting Claude Code in merge-queue window...")
-			pid, err = c.startClaudeInTmux(claudeBinary, tmuxSession, "merge-queue", repoPath, mergeQueueSessionID, mergeQueuePromptFile, repoName, "")
+			fmt.Fprintln(c.stdout(), "Starting Claude Code in merge-queue window...")
+			pid, err = c.startClaudeInTmux(claudeBinary, tmuxSession, "merge-queue", repoPath, mergeQueueSessionID, mergeQueuePromptFile, repoName, state.AgentTypeMergeQueue, "")
 			if err != nil {
 				return fmt.Errorf("failed to start merge-queue Claude: %w", err)
 			}
@@ -1403,11 +1862,11 @@ func (c *CLI) initRepo(args []string) error {
 
 			// Set up output capture for merge-queue
 			if err := c.setupOutputCapture(tmuxSession, "merge-queue", repoName, "merge-queue", "merge-queue"); err != nil {
-				fmt.Printf("Warning: failed to setup output capture for merge-queue: %v\n", err)
+				fmt.Fprintf(c.stdout(), "Warning: failed to setup output capture for merge-queue: %v\n", err)
 			}
 		} else if psEnabled {
-			fmt.Println("Starting Claude Code in pr-shepherd window...")
-			pid, err = c.startClaudeInTmux(claudeBinary, tmuxSession, "pr-shepherd", repoPath, prShepherdSessionID, prShepherdPromptFile, repoName, "")
+			fmt.Fprintln(c.stdout(), "Starting Claude Code in pr-shepherd window...")
+			pid, err = c.startClaudeInTmux(claudeBinary, tmuxSession, "pr-shepherd", repoPath, prShepherdSessionID, prShepherdPromptFile, repoName, state.AgentTypePRShepherd, "")
 			if err != nil {
 				return fmt.Errorf("failed to start pr-shepherd Claude: %w", err)
 			}
@@ -1415,7 +1874,7 @@ func (c *CLI) initRepo(args []string) error {
 
 			// Set up output capture for pr-shepherd
 			if err := c.setupOutputCapture(tmuxSession, "pr-shepherd", repoName, "pr-shepherd", "pr-shepherd"); err != nil {
-				fmt.Printf("Warning: failed to setup output capture for pr-shepherd: %v\n", err)
+				fmt.Fprintf(c.stdout(), "Warning: failed to setup output capture for pr-shepherd: %v\n", err)
 			}
 		}
 	}
@@ -1527,11 +1986,11 @@ func (c *CLI) initRepo(args []string) error {
 		return fmt.Errorf("failed to check workspace branch state: %w", err)
 	}
 	if migrated {
-		fmt.Println("Migrated legacy 'workspace' branch to 'workspace/default'")
+		fmt.Fprintln(c.stdout(), "Migrated legacy 'workspace' branch to 'workspace/default'")
 	}
 	workspaceBranch := "workspace/default"
 
-	fmt.Printf("Creating default workspace worktree at: %s\n", workspacePath)
+	fmt.Fprintf(c.stdout(), "Creating default workspace worktree at: %s\n", workspacePath)
 	if err := wt.CreateNewBranch(workspacePath, workspaceBranch, "HEAD"); err != nil {
 		return fmt.Errorf("failed to create default workspace worktree: %w", err)
 	}
@@ -1556,7 +2015,7 @@ func (c *CLI) initRepo(args []string) error {
 
 	// Copy hooks configuration if it exists
 	if err := hooks.CopyConfig(repoPath, workspacePath); err != nil {
-		fmt.Printf("Warning: failed to copy hooks config to default workspace: %v\n", err)
+		fmt.Fprintf(c.stdout(), "Warning: failed to copy hooks config to default workspace: %v\n", err)
 	}
 
 	// Start Claude in default workspace window (skip in test mode)
@@ -1568,8 +2027,8 @@ func (c *CLI) initRepo(args []string) error {
 			return fmt.Errorf("failed to resolve claude binary: %w", err)
 		}
 
-		fmt.Println("Starting Claude Code in default workspace window...")
-		pid, err := c.startClaudeInTmux(claudeBinary, tmuxSession, "default", workspacePath, workspaceSessionID, workspacePromptFile, repoName, "")
+		fmt.Fprintln(c.stdout(), "Starting Claude Code in default workspace window...")
+		pid, err := c.startClaudeInTmux(claudeBinary, tmuxSession, "default", workspacePath, workspaceSessionID, workspacePromptFile, repoName, state.AgentTypeWorkspace, "")
 		if err != nil {
 			return fmt.Errorf("failed to start default workspace Claude: %w", err)
 		}
@@ -1577,7 +2036,7 @@ func (c *CLI) initRepo(args []string) error {
 
 		// Set up output capture for default workspace
 		if err := c.setupOutputCapture(tmuxSession, "default", repoName, "default", "workspace"); err != nil {
-			fmt.Printf("Warning: failed to setup output capture for default workspace: %v\n", err)
+			fmt.Fprintf(c.stdout(), "Warning: failed to setup output capture for default workspace: %v\n", err)
 		}
 	}
 
@@ -1601,16 +2060,16 @@ func (c *CLI) initRepo(args []string) error {
 		return fmt.Errorf("failed to register default workspace: %s", resp.Error)
 	}
 
-	fmt.Println()
-	fmt.Println("✓ Repository initialized successfully!")
-	fmt.Printf("  Tmux session: %s\n", tmuxSession)
+	fmt.Fprintln(c.stdout())
+	fmt.Fprintln(c.stdout(), "✓ Repository initialized successfully!")
+	fmt.Fprintf(c.stdout(), "  Tmux session: %s\n", tmuxSession)
 	if mqEnabled {
-		fmt.Printf("  Agents: supervisor, merge-queue, default (workspace)\n")
+		fmt.Fprintf(c.stdout(), "  Agents: supervisor, merge-queue, default (workspace)\n")
 	} else {
-		fmt.Printf("  Agents: supervisor, default (workspace)\n")
+		fmt.Fprintf(c.stdout(), "  Agents: supervisor, default (workspace)\n")
 	}
-	fmt.Printf("\nAttach to session: tmux attach -t %s\n", tmuxSession)
-	fmt.Printf("Or connect to your workspace: multiclaude workspace connect default\n")
+	fmt.Fprintf(c.stdout(), "\nAttach to session: tmux attach -t %s\n", tmuxSession)
+	fmt.Fprintf(c.stdout(), "Or connect to your workspace: multiclaude workspace connect default\n")
 
 	return nil
 }
@@ -1628,14 +2087,19 @@ func (c *CLI) listRepos(args []string) error {
 		return errors.New(errors.CategoryRuntime, "unexpected response format from daemon")
 	}
 
+	if c.jsonOutput {
+		c.setJSONResult(map[string]interface{}{"repos": repos})
+		return nil
+	}
+
 	if len(repos) == 0 {
-		fmt.Println("No repositories tracked")
-		format.Dimmed("\nInitialize a repository with: multiclaude init <github-url>")
+		fmt.Fprintln(c.stdout(), "No repositories tracked")
+		format.DimmedTo(c.stdout(), "\nInitialize a repository with: multiclaude init <github-url>")
 		return nil
 	}
 
-	format.Header("Tracked repositories (%d):", len(repos))
-	fmt.Println()
+	format.HeaderTo(c.stdout(), "Tracked repositories (%d):", len(repos))
+	fmt.Fprintln(c.stdout())
 
 	table := format.NewColoredTable("REPO", "MODE", "AGENTS", "STATUS", "SESSION")
 	for _, repo := range repos {
@@ -1688,18 +2152,24 @@ func (c *CLI) listRepos(args []string) error {
 			)
 		}
 	}
-	table.Print()
+	table.PrintTo(c.stdout())
 
 	return nil
 }
 
 func (c *CLI) removeRepo(args []string) error {
+	flags, args := ParseFlags(args)
+	skipConfirm := flags["yes"] == "true"
+
 	var repoName string
 	if len(args) > 0 {
 		repoName = args[0]
 	} else {
 		// Interactive selection - list repos
-		client := socket.NewClient(c.paths.DaemonSock)
+		client, err := c.socketClient()
+		if err != nil {
+			return err
+		}
 		resp, err := client.Send(socket.Request{
 			Command: "list_repos",
 			Args: map[string]interface{}{
@@ -1718,21 +2188,24 @@ func (c *CLI) removeRepo(args []string) error {
 		if len(items) == 0 {
 			return errors.NoRepositoriesFound()
 		}
-		selected, err := SelectFromList("Select repository to remove:", items)
+		selected, err := c.selectFromList("Select repository to remove:", items)
 		if err != nil {
 			return err
 		}
 		if selected == "" {
-			fmt.Println("Cancelled")
+			fmt.Fprintln(c.stdout(), "Cancelled")
 			return nil
 		}
 		repoName = selected
 	}
 
-	fmt.Printf("Removing repository '%s'...\n", repoName)
+	fmt.Fprintf(c.stdout(), "Removing repository '%s'...\n", repoName)
 
 	// Get repo info from daemon
-	client := socket.NewClient(c.paths.DaemonSock)
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
 	resp, err := client.Send(socket.Request{
 		Command: "list_agents",
 		Args: map[string]interface{}{
@@ -1759,15 +2232,17 @@ func (c *CLI) removeRepo(args []string) error {
 					hasUncommitted, err := worktree.HasUncommittedChanges(wtPath)
 					if err == nil && hasUncommitted {
 						agentName, _ := agentMap["name"].(string)
-						fmt.Printf("\nWarning: Agent '%s' has uncommitted changes!\n", agentName)
-						fmt.Println("Files may be lost if you continue.")
-						fmt.Print("Continue with removal? [y/N]: ")
-
-						var response string
-						fmt.Scanln(&response)
-						if response != "y" && response != "Y" {
-							fmt.Println("Removal cancelled")
-							return nil
+						fmt.Fprintf(c.stdout(), "\nWarning: Agent '%s' has uncommitted changes!\n", agentName)
+						fmt.Fprintln(c.stdout(), "Files may be lost if you continue.")
+						if !skipConfirm {
+							ok, err := c.confirm("Continue with removal? [y/N]: ")
+							if err != nil {
+								return err
+							}
+							if !ok {
+								fmt.Fprintln(c.stdout(), "Removal cancelled")
+								return nil
+							}
 						}
 						break // Only ask once
 					}
@@ -1780,9 +2255,9 @@ func (c *CLI) removeRepo(args []string) error {
 	tmuxSession := sanitizeTmuxSessionName(repoName)
 	tmuxClient := tmux.NewClient()
 	if exists, err := tmuxClient.HasSession(context.Background(), tmuxSession); err == nil && exists {
-		fmt.Printf("Killing tmux session: %s\n", tmuxSession)
+		fmt.Fprintf(c.stdout(), "Killing tmux session: %s\n", tmuxSession)
 		if err := tmuxClient.KillSession(context.Background(), tmuxSession); err != nil {
-			fmt.Printf("Warning: failed to kill tmux session: %v\n", err)
+			fmt.Fprintf(c.stdout(), "Warning: failed to kill tmux session: %v\n", err)
 		}
 	}
 
@@ -1794,9 +2269,9 @@ func (c *CLI) removeRepo(args []string) error {
 			wtPath, _ := agentMap["worktree_path"].(string)
 			agentName, _ := agentMap["name"].(string)
 			if wtPath != "" && wtPath != repoPath {
-				fmt.Printf("Removing worktree for '%s': %s\n", agentName, wtPath)
+				fmt.Fprintf(c.stdout(), "Removing worktree for '%s': %s\n", agentName, wtPath)
 				if err := wt.Remove(wtPath, true); err != nil {
-					fmt.Printf("Warning: failed to remove worktree: %v\n", err)
+					fmt.Fprintf(c.stdout(), "Warning: failed to remove worktree: %v\n", err)
 				}
 			}
 		}
@@ -1805,18 +2280,18 @@ func (c *CLI) removeRepo(args []string) error {
 	// Remove the worktrees directory for this repo
 	wtDir := c.paths.WorktreeDir(repoName)
 	if _, err := os.Stat(wtDir); err == nil {
-		fmt.Printf("Removing worktrees directory: %s\n", wtDir)
+		fmt.Fprintf(c.stdout(), "Removing worktrees directory: %s\n", wtDir)
 		if err := os.RemoveAll(wtDir); err != nil {
-			fmt.Printf("Warning: failed to remove worktrees directory: %v\n", err)
+			fmt.Fprintf(c.stdout(), "Warning: failed to remove worktrees directory: %v\n", err)
 		}
 	}
 
 	// Clean up messages directory for this repo
 	msgDir := filepath.Join(c.paths.MessagesDir, repoName)
 	if _, err := os.Stat(msgDir); err == nil {
-		fmt.Printf("Removing messages directory: %s\n", msgDir)
+		fmt.Fprintf(c.stdout(), "Removing messages directory: %s\n", msgDir)
 		if err := os.RemoveAll(msgDir); err != nil {
-			fmt.Printf("Warning: failed to remove messages directory: %v\n", err)
+			fmt.Fprintf(c.stdout(), "Warning: failed to remove messages directory: %v\n", err)
 		}
 	}
 
@@ -1834,9 +2309,9 @@ func (c *CLI) removeRepo(args []string) error {
 		return errors.Wrap(errors.CategoryRuntime, "failed to remove repo from state", fmt.Errorf("%s", resp.Error))
 	}
 
-	fmt.Println("✓ Repository removed successfully")
-	fmt.Printf("\nNote: The cloned repository at '%s' was NOT deleted.\n", repoPath)
-	fmt.Println("Delete it manually if you no longer need it.")
+	fmt.Fprintln(c.stdout(), "✓ Repository removed successfully")
+	fmt.Fprintf(c.stdout(), "\nNote: The cloned repository at '%s' was NOT deleted.\n", repoPath)
+	fmt.Fprintln(c.stdout(), "Delete it manually if you no longer need it.")
 	return nil
 }
 
@@ -1854,7 +2329,7 @@ func (c *CLI) setCurrentRepo(args []string) error {
 		return err
 	}
 
-	fmt.Printf("Current repository set to: %s\n", repoName)
+	fmt.Fprintf(c.stdout(), "Current repository set to: %s\n", repoName)
 	return nil
 }
 
@@ -1866,10 +2341,10 @@ func (c *CLI) getCurrentRepo(args []string) error {
 
 	currentRepo, _ := resp.Data.(string)
 	if currentRepo == "" {
-		fmt.Println("No current repository set")
-		fmt.Println("\nUse 'multiclaude repo use <name>' to set one")
+		fmt.Fprintln(c.stdout(), "No current repository set")
+		fmt.Fprintln(c.stdout(), "\nUse 'multiclaude repo use <name>' to set one")
 	} else {
-		fmt.Printf("Current repository: %s\n", currentRepo)
+		fmt.Fprintf(c.stdout(), "Current repository: %s\n", currentRepo)
 	}
 	return nil
 }
@@ -1880,7 +2355,7 @@ func (c *CLI) clearCurrentRepo(args []string) error {
 		return err
 	}
 
-	fmt.Println("Current repository cleared")
+	fmt.Fprintln(c.stdout(), "Current repository cleared")
 	return nil
 }
 
@@ -1921,10 +2396,12 @@ func (c *CLI) configRepo(args []string) error {
 	// Check if any config flags are provided
 	hasMqEnabled := flags["mq-enabled"] != ""
 	hasMqTrack := flags["mq-track"] != ""
+	hasMqRetries := flags["mq-retries"] != ""
 	hasPsEnabled := flags["ps-enabled"] != ""
 	hasPsTrack := flags["ps-track"] != ""
+	hasSpawnLimit := flags["max-workers"] != "" || flags["spawn-rate"] != "" || flags["spawn-burst"] != ""
 
-	if !hasMqEnabled && !hasMqTrack && !hasPsEnabled && !hasPsTrack {
+	if !hasMqEnabled && !hasMqTrack && !hasMqRetries && !hasPsEnabled && !hasPsTrack && !hasSpawnLimit {
 		// No flags - just show current config
 		return c.showRepoConfig(repoName)
 	}
@@ -1934,7 +2411,10 @@ func (c *CLI) configRepo(args []string) error {
 }
 
 func (c *CLI) showRepoConfig(repoName string) error {
-	client := socket.NewClient(c.paths.DaemonSock)
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
 	resp, err := client.Send(socket.Request{
 		Command: "get_repo_config",
 		Args: map[string]interface{}{
@@ -1955,21 +2435,21 @@ func (c *CLI) showRepoConfig(repoName string) error {
 		return fmt.Errorf("unexpected response format")
 	}
 
-	fmt.Printf("Configuration for repository: %s\n\n", repoName)
+	fmt.Fprintf(c.stdout(), "Configuration for repository: %s\n\n", repoName)
 
 	// Show fork info if this is a fork
 	isFork, _ := configMap["is_fork"].(bool)
 	if isFork {
 		upstreamOwner, _ := configMap["upstream_owner"].(string)
 		upstreamRepo, _ := configMap["upstream_repo"].(string)
-		fmt.Printf("Fork Mode: Yes (fork of %s/%s)\n\n", upstreamOwner, upstreamRepo)
+		fmt.Fprintf(c.stdout(), "Fork Mode: Yes (fork of %s/%s)\n\n", upstreamOwner, upstreamRepo)
 	} else {
-		fmt.Println("Fork Mode: No (upstream/direct repository)")
-		fmt.Println()
+		fmt.Fprintln(c.stdout(), "Fork Mode: No (upstream/direct repository)")
+		fmt.Fprintln(c.stdout())
 	}
 
 	// Show merge queue config
-	fmt.Println("Merge Queue:")
+	fmt.Fprintln(c.stdout(), "Merge Queue:")
 	mqEnabled := true
 	if enabled, ok := configMap["mq_enabled"].(bool); ok {
 		mqEnabled = enabled
@@ -1979,14 +2459,17 @@ func (c *CLI) showRepoConfig(repoName string) error {
 		mqTrackMode = trackMode
 	}
 	if mqEnabled {
-		fmt.Printf("  Enabled: true\n")
-		fmt.Printf("  Track mode: %s\n", mqTrackMode)
+		fmt.Fprintf(c.stdout(), "  Enabled: true\n")
+		fmt.Fprintf(c.stdout(), "  Track mode: %s\n", mqTrackMode)
+		if maxRetries, ok := configMap["mq_max_retries"].(float64); ok {
+			fmt.Fprintf(c.stdout(), "  Max retries: %d\n", int(maxRetries))
+		}
 	} else {
-		fmt.Printf("  Enabled: false\n")
+		fmt.Fprintf(c.stdout(), "  Enabled: false\n")
 	}
 
 	// Show PR shepherd config
-	fmt.Println("\nPR Shepherd:")
+	fmt.Fprintln(c.stdout(), "\nPR Shepherd:")
 	psEnabled := true
 	if enabled, ok := configMap["ps_enabled"].(bool); ok {
 		psEnabled = enabled
@@ -1996,21 +2479,43 @@ func (c *CLI) showRepoConfig(repoName string) error {
 		psTrackMode = trackMode
 	}
 	if psEnabled {
-		fmt.Printf("  Enabled: true\n")
-		fmt.Printf("  Track mode: %s\n", psTrackMode)
+		fmt.Fprintf(c.stdout(), "  Enabled: true\n")
+		fmt.Fprintf(c.stdout(), "  Track mode: %s\n", psTrackMode)
 	} else {
-		fmt.Printf("  Enabled: false\n")
+		fmt.Fprintf(c.stdout(), "  Enabled: false\n")
+	}
+
+	// Show spawn limits
+	fmt.Fprintln(c.stdout(), "\nSpawn Limits:")
+	if maxWorkers, ok := configMap["spawn_max_workers"].(float64); ok {
+		fmt.Fprintf(c.stdout(), "  Max workers: %s\n", formatLimit(maxWorkers))
+	}
+	if rate, ok := configMap["spawn_per_minute"].(float64); ok {
+		fmt.Fprintf(c.stdout(), "  Spawns per minute: %s\n", formatLimit(rate))
+	}
+	if burst, ok := configMap["spawn_burst"].(float64); ok {
+		fmt.Fprintf(c.stdout(), "  Burst: %d\n", int(burst))
 	}
 
-	fmt.Println("\nTo modify:")
-	fmt.Printf("  multiclaude config %s --mq-enabled=true|false\n", repoName)
-	fmt.Printf("  multiclaude config %s --mq-track=all|author|assigned\n", repoName)
-	fmt.Printf("  multiclaude config %s --ps-enabled=true|false\n", repoName)
-	fmt.Printf("  multiclaude config %s --ps-track=all|author|assigned\n", repoName)
+	fmt.Fprintln(c.stdout(), "\nTo modify:")
+	fmt.Fprintf(c.stdout(), "  multiclaude config %s --mq-enabled=true|false\n", repoName)
+	fmt.Fprintf(c.stdout(), "  multiclaude config %s --mq-track=all|author|assigned\n", repoName)
+	fmt.Fprintf(c.stdout(), "  multiclaude config %s --mq-retries=N\n", repoName)
+	fmt.Fprintf(c.stdout(), "  multiclaude config %s --ps-enabled=true|false\n", repoName)
+	fmt.Fprintf(c.stdout(), "  multiclaude config %s --ps-track=all|author|assigned\n", repoName)
+	fmt.Fprintf(c.stdout(), "  multiclaude config %s --max-workers=N --spawn-rate=N --spawn-burst=N (-1 disables a limit)\n", repoName)
 
 	return nil
 }
 
+// formatLimit renders a spawn limit value, where non-positive means unlimited
+func formatLimit(v float64) string {
+	if v <= 0 {
+		return "unlimited"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
 func (c *CLI) updateRepoConfig(repoName string, flags map[string]string) error {
 	// Build update args
 	updateArgs := map[string]interface{}{
@@ -2038,6 +2543,37 @@ func (c *CLI) updateRepoConfig(repoName string, flags map[string]string) error {
 		}
 	}
 
+	if mqRetries, ok := flags["mq-retries"]; ok {
+		retries, err := strconv.Atoi(mqRetries)
+		if err != nil || retries < 0 {
+			return fmt.Errorf("invalid --mq-retries value: %s (must be a non-negative integer)", mqRetries)
+		}
+		updateArgs["mq_max_retries"] = retries
+	}
+
+	// Parse spawn limit flags
+	if maxWorkers, ok := flags["max-workers"]; ok {
+		n, err := strconv.Atoi(maxWorkers)
+		if err != nil || n == 0 || n < -1 {
+			return fmt.Errorf("invalid --max-workers value: %s (must be a positive integer, or -1 for unlimited)", maxWorkers)
+		}
+		updateArgs["spawn_max_workers"] = n
+	}
+	if spawnRate, ok := flags["spawn-rate"]; ok {
+		rate, err := strconv.ParseFloat(spawnRate, 64)
+		if err != nil || (rate <= 0 && rate != -1) {
+			return fmt.Errorf("invalid --spawn-rate value: %s (must be a positive number of spawns per minute, or -1 for unlimited)", spawnRate)
+		}
+		updateArgs["spawn_per_minute"] = rate
+	}
+	if spawnBurst, ok := flags["spawn-burst"]; ok {
+		burst, err := strconv.Atoi(spawnBurst)
+		if err != nil || burst < 1 {
+			return fmt.Errorf("invalid --spawn-burst value: %s (must be a positive integer)", spawnBurst)
+		}
+		updateArgs["spawn_burst"] = burst
+	}
+
 	// Parse PR shepherd flags
 	if psEnabled, ok := flags["ps-enabled"]; ok {
 		switch psEnabled {
@@ -2059,7 +2595,10 @@ func (c *CLI) updateRepoConfig(repoName string, flags map[string]string) error {
 		}
 	}
 
-	client := socket.NewClient(c.paths.DaemonSock)
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
 	resp, err := client.Send(socket.Request{
 		Command: "update_repo_config",
 		Args:    updateArgs,
@@ -2072,7 +2611,7 @@ func (c *CLI) updateRepoConfig(repoName string, flags map[string]string) error {
 		return fmt.Errorf("failed to update repo config: %s", resp.Error)
 	}
 
-	fmt.Printf("Configuration updated for repository: %s\n", repoName)
+	fmt.Fprintf(c.stdout(), "Configuration updated for repository: %s\n", repoName)
 
 	// Show the updated config
 	return c.showRepoConfig(repoName)
@@ -2096,6 +2635,9 @@ func (c *CLI) createWorker(args []string) error {
 	// Generate worker name (Docker-style)
 	workerName := names.Generate()
 	if name, ok := flags["name"]; ok {
+		if err := names.ValidateAgentName(name); err != nil {
+			return errors.InvalidUsage(err.Error())
+		}
 		workerName = name
 	}
 
@@ -2116,34 +2658,38 @@ func (c *CLI) createWorker(args []string) error {
 	// Note: We use "git fetch origin main" (not "main:main") because the latter
 	// fails when main is checked out in the bare repo with:
 	// "fatal: refusing to fetch into branch 'refs/heads/main' checked out at ..."
-	fmt.Println("Fetching latest from origin...")
+	fmt.Fprintln(c.stdout(), "Fetching latest from origin...")
 	fetchCmd := exec.Command("git", "fetch", "origin")
 	fetchCmd.Dir = repoPath
 	if err := fetchCmd.Run(); err != nil {
 		// Best effort - don't fail if offline or fetch fails
-		fmt.Printf("Warning: failed to fetch from origin: %v (continuing with local refs)\n", err)
+		fmt.Fprintf(c.stdout(), "Warning: failed to fetch from origin: %v (continuing with local refs)\n", err)
 	}
 
 	// Determine branch to start from
-	// Prefer origin/main if it exists (updated by fetch), otherwise fall back to HEAD
+	// Prefer origin's default branch if it exists (updated by fetch), otherwise fall back to HEAD
 	// This handles both normal repos and test repos without remotes
 	startBranch := "HEAD"
-	checkOriginCmd := exec.Command("git", "rev-parse", "--verify", "origin/main")
+	originBranch := "origin/main"
+	if defaultBranch := fork.DefaultBranch(repoPath, "origin"); defaultBranch != "" {
+		originBranch = "origin/" + defaultBranch
+	}
+	checkOriginCmd := exec.Command("git", "rev-parse", "--verify", originBranch)
 	checkOriginCmd.Dir = repoPath
 	if err := checkOriginCmd.Run(); err == nil {
-		startBranch = "origin/main"
+		startBranch = originBranch
 	}
 	if branch, ok := flags["branch"]; ok {
 		startBranch = branch
 		if hasPushTo {
-			fmt.Printf("Creating worker '%s' in repo '%s' to iterate on branch '%s'\n", workerName, repoName, pushTo)
+			fmt.Fprintf(c.stdout(), "Creating worker '%s' in repo '%s' to iterate on branch '%s'\n", workerName, repoName, pushTo)
 		} else {
-			fmt.Printf("Creating worker '%s' in repo '%s' from branch '%s'\n", workerName, repoName, branch)
+			fmt.Fprintf(c.stdout(), "Creating worker '%s' in repo '%s' from branch '%s'\n", workerName, repoName, branch)
 		}
 	} else {
-		fmt.Printf("Creating worker '%s' in repo '%s'\n", workerName, repoName)
+		fmt.Fprintf(c.stdout(), "Creating worker '%s' in repo '%s'\n", workerName, repoName)
 	}
-	fmt.Printf("Task: %s\n", task)
+	fmt.Fprintf(c.stdout(), "Task: %s\n", task)
 
 	// Create worktree
 	wt := worktree.NewManager(repoPath)
@@ -2154,7 +2700,7 @@ func (c *CLI) createWorker(args []string) error {
 		// When --push-to is specified, we're iterating on an existing PR branch
 		// Create a worktree that checks out the remote branch into a local branch
 		branchName = pushTo
-		fmt.Printf("Creating worktree at: %s (checking out %s)\n", wtPath, startBranch)
+		fmt.Fprintf(c.stdout(), "Creating worktree at: %s (checking out %s)\n", wtPath, startBranch)
 
 		// Check if the local branch already exists
 		branchExists, err := wt.BranchExists(branchName)
@@ -2176,14 +2722,17 @@ func (c *CLI) createWorker(args []string) error {
 	} else {
 		// Normal case: create a new branch for this worker
 		branchName = fmt.Sprintf("work/%s", workerName)
-		fmt.Printf("Creating worktree at: %s\n", wtPath)
+		fmt.Fprintf(c.stdout(), "Creating worktree at: %s\n", wtPath)
 		if err := wt.CreateNewBranch(wtPath, branchName, startBranch); err != nil {
 			return errors.WorktreeCreationFailed(err)
 		}
 	}
 
 	// Get repository info to determine tmux session
-	client := socket.NewClient(c.paths.DaemonSock)
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
 	resp, err := client.Send(socket.Request{
 		Command: "list_agents",
 		Args: map[string]interface{}{
@@ -2208,14 +2757,17 @@ func (c *CLI) createWorker(args []string) error {
 		return errors.TmuxOperationFailed("check session", err)
 	}
 	if !hasSession {
-		fmt.Printf("Tmux session '%s' not found, creating it...\n", tmuxSession)
+		fmt.Fprintf(c.stdout(), "Tmux session '%s' not found, creating it...\n", tmuxSession)
 		if err := tmuxClient.CreateSession(context.Background(), tmuxSession, true); err != nil {
 			return errors.TmuxOperationFailed("create session", err)
 		}
+		if err := cleanup.MarkSession(context.Background(), tmuxClient, tmuxSession, c.paths.Root); err != nil {
+			fmt.Fprintf(c.stdout(), "Warning: %v\n", err)
+		}
 	}
 
 	// Create tmux window for worker (detached so it doesn't switch focus)
-	fmt.Printf("Creating tmux window: %s\n", workerName)
+	fmt.Fprintf(c.stdout(), "Creating tmux window: %s\n", workerName)
 	cmd := exec.Command("tmux", "new-window", "-d", "-t", tmuxSession, "-n", workerName, "-c", wtPath)
 	if err := cmd.Run(); err != nil {
 		return errors.TmuxOperationFailed("create window", err)
@@ -2260,7 +2812,7 @@ func (c *CLI) createWorker(args []string) error {
 
 	// Copy hooks configuration if it exists
 	if err := hooks.CopyConfig(repoPath, wtPath); err != nil {
-		fmt.Printf("Warning: failed to copy hooks config: %v\n", err)
+		fmt.Fprintf(c.stdout(), "Warning: failed to copy hooks config: %v\n", err)
 	}
 
 	// Start Claude in worker window with initial task (skip in test mode)
@@ -2272,9 +2824,9 @@ func (c *CLI) createWorker(args []string) error {
 			return fmt.Errorf("failed to resolve claude binary: %w", err)
 		}
 
-		fmt.Println("Starting Claude Code in worker window...")
+		fmt.Fprintln(c.stdout(), "Starting Claude Code in worker window...")
 		initialMessage := fmt.Sprintf("Task: %s", task)
-		pid, err := c.startClaudeInTmux(claudeBinary, tmuxSession, workerName, wtPath, workerSessionID, workerPromptFile, repoName, initialMessage)
+		pid, err := c.startClaudeInTmux(claudeBinary, tmuxSession, workerName, wtPath, workerSessionID, workerPromptFile, repoName, state.AgentTypeWorker, initialMessage)
 		if err != nil {
 			return fmt.Errorf("failed to start worker Claude: %w", err)
 		}
@@ -2282,7 +2834,7 @@ func (c *CLI) createWorker(args []string) error {
 
 		// Set up output capture for worker
 		if err := c.setupOutputCapture(tmuxSession, workerName, repoName, workerName, "worker"); err != nil {
-			fmt.Printf("Warning: failed to setup output capture for worker: %v\n", err)
+			fmt.Fprintf(c.stdout(), "Warning: failed to setup output capture for worker: %v\n", err)
 		}
 	}
 
@@ -2304,19 +2856,23 @@ func (c *CLI) createWorker(args []string) error {
 		return fmt.Errorf("failed to register worker: %w", err)
 	}
 	if !resp.Success {
+		// Registration can be refused (e.g. spawn limits); don't leave an
+		// unregistered worker running
+		tmuxClient.KillWindow(context.Background(), tmuxSession, workerName)
+		wt.Remove(wtPath, true)
 		return fmt.Errorf("failed to register worker: %s", resp.Error)
 	}
 
-	fmt.Println()
-	fmt.Println("✓ Worker created successfully!")
-	fmt.Printf("  Name: %s\n", workerName)
-	fmt.Printf("  Branch: %s\n", branchName)
-	fmt.Printf("  Worktree: %s\n", wtPath)
+	fmt.Fprintln(c.stdout())
+	fmt.Fprintln(c.stdout(), "✓ Worker created successfully!")
+	fmt.Fprintf(c.stdout(), "  Name: %s\n", workerName)
+	fmt.Fprintf(c.stdout(), "  Branch: %s\n", branchName)
+	fmt.Fprintf(c.stdout(), "  Worktree: %s\n", wtPath)
 	if hasPushTo {
-		fmt.Printf("  Mode: Push to existing PR branch (%s)\n", pushTo)
+		fmt.Fprintf(c.stdout(), "  Mode: Push to existing PR branch (%s)\n", pushTo)
 	}
-	fmt.Printf("\nAttach to worker: tmux select-window -t %s:%s\n", tmuxSession, workerName)
-	fmt.Printf("Or use: multiclaude attach %s\n", workerName)
+	fmt.Fprintf(c.stdout(), "\nAttach to worker: tmux select-window -t %s:%s\n", tmuxSession, workerName)
+	fmt.Fprintf(c.stdout(), "Or use: multiclaude attach %s\n", workerName)
 
 	return nil
 }
@@ -2330,10 +2886,14 @@ func (c *CLI) listWorkers(args []string) error {
 		return errors.NotInRepo()
 	}
 
-	resp, err := c.sendDaemonRequest("list_agents", map[string]interface{}{
+	reqArgs := map[string]interface{}{
 		"repo": repoName,
 		"rich": true,
-	})
+	}
+	if status := flags["status"]; status != "" {
+		reqArgs["status"] = status
+	}
+	resp, err := c.sendDaemonRequest("list_agents", reqArgs)
 	if err != nil {
 		return err
 	}
@@ -2357,25 +2917,34 @@ func (c *CLI) listWorkers(args []string) error {
 		}
 	}
 
+	if c.jsonOutput {
+		c.setJSONResult(map[string]interface{}{
+			"repo":      repoName,
+			"workers":   workers,
+			"workspace": workspace,
+		})
+		return nil
+	}
+
 	// Show workspace first if it exists
 	if workspace != nil {
-		format.Header("Workspace in '%s':", repoName)
+		format.HeaderTo(c.stdout(), "Workspace in '%s':", repoName)
 		status, _ := workspace["status"].(string)
 		statusCell := formatAgentStatusCell(status)
-		fmt.Printf("  workspace ")
-		fmt.Print(statusCell.Text)
-		fmt.Println()
-		fmt.Println()
+		fmt.Fprintf(c.stdout(), "  workspace ")
+		fmt.Fprint(c.stdout(), statusCell.Text)
+		fmt.Fprintln(c.stdout())
+		fmt.Fprintln(c.stdout())
 	}
 
 	if len(workers) == 0 {
-		fmt.Printf("No workers in repository '%s'\n", repoName)
-		format.Dimmed("\nCreate a worker with: multiclaude worker create <task>")
+		fmt.Fprintf(c.stdout(), "No workers in repository '%s'\n", repoName)
+		format.DimmedTo(c.stdout(), "\nCreate a worker with: multiclaude worker create <task>")
 		return nil
 	}
 
-	format.Header("Workers in '%s' (%d):", repoName, len(workers))
-	fmt.Println()
+	format.HeaderTo(c.stdout(), "Workers in '%s' (%d):", repoName, len(workers))
+	fmt.Fprintln(c.stdout())
 
 	table := format.NewColoredTable("NAME", "STATUS", "BRANCH", "MSGS", "TASK")
 	for _, worker := range workers {
@@ -2415,7 +2984,7 @@ func (c *CLI) listWorkers(args []string) error {
 			format.Cell(truncTask),
 		)
 	}
-	table.Print()
+	table.PrintTo(c.stdout())
 
 	return nil
 }
@@ -2441,15 +3010,33 @@ func (c *CLI) listAgentDefinitions(args []string) error {
 		return errors.Wrap(errors.CategoryRuntime, "failed to read agent definitions", err)
 	}
 
+	if c.jsonOutput {
+		definitions := make([]map[string]interface{}, 0, len(defs))
+		for _, def := range defs {
+			definitions = append(definitions, map[string]interface{}{
+				"name":        def.Name,
+				"source":      def.Source,
+				"path":        def.SourcePath,
+				"title":       def.ParseTitle(),
+				"description": def.ParseDescription(),
+			})
+		}
+		c.setJSONResult(map[string]interface{}{
+			"repo":        repoName,
+			"definitions": definitions,
+		})
+		return nil
+	}
+
 	if len(defs) == 0 {
-		fmt.Println("No agent definitions found.")
-		fmt.Printf("\nAgent definitions are stored in:\n")
-		fmt.Printf("  Local: %s\n", localAgentsDir)
-		fmt.Printf("  Repo:  %s/.multiclaude/agents/\n", repoPath)
+		fmt.Fprintln(c.stdout(), "No agent definitions found.")
+		fmt.Fprintf(c.stdout(), "\nAgent definitions are stored in:\n")
+		fmt.Fprintf(c.stdout(), "  Local: %s\n", localAgentsDir)
+		fmt.Fprintf(c.stdout(), "  Repo:  %s/.multiclaude/agents/\n", repoPath)
 		return nil
 	}
 
-	fmt.Printf("Agent definitions for %s:\n\n", repoName)
+	fmt.Fprintf(c.stdout(), "Agent definitions for %s:\n\n", repoName)
 
 	// Create colored table
 	table := format.NewColoredTable("Name", "Source", "Title", "Description")
@@ -2476,7 +3063,7 @@ func (c *CLI) listAgentDefinitions(args []string) error {
 		)
 	}
 
-	table.Print()
+	table.PrintTo(c.stdout())
 
 	return nil
 }
@@ -2491,6 +3078,9 @@ func (c *CLI) spawnAgentFromFile(args []string) error {
 	if !ok || agentName == "" {
 		return errors.InvalidUsage("--name is required")
 	}
+	if err := names.ValidateAgentName(agentName); err != nil {
+		return errors.InvalidUsage(err.Error())
+	}
 
 	agentClass, ok := flags["class"]
 	if !ok || agentClass == "" {
@@ -2521,9 +3111,12 @@ func (c *CLI) spawnAgentFromFile(args []string) error {
 	task := flags["task"]
 
 	// Send spawn_agent request to daemon
-	client := socket.NewClient(c.paths.DaemonSock)
-	reqArgs := map[string]interface{}{
-		"repo":   repoName,
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
+	reqArgs := map[string]interface{}{
+		"repo":   repoName,
 		"name":   agentName,
 		"class":  agentClass,
 		"prompt": string(promptContent),
@@ -2543,7 +3136,7 @@ func (c *CLI) spawnAgentFromFile(args []string) error {
 		return errors.Wrap(errors.CategoryRuntime, "failed to spawn agent", fmt.Errorf("%s", resp.Error))
 	}
 
-	fmt.Printf("Agent '%s' spawned successfully (class: %s)\n", agentName, agentClass)
+	fmt.Fprintf(c.stdout(), "Agent '%s' spawned successfully (class: %s)\n", agentName, agentClass)
 	return nil
 }
 
@@ -2562,11 +3155,11 @@ func (c *CLI) resetAgentDefinitions(args []string) error {
 
 	// Check if directory exists
 	if _, err := os.Stat(agentsDir); os.IsNotExist(err) {
-		fmt.Printf("No agent definitions found at %s\n", agentsDir)
-		fmt.Println("Creating new definitions from templates...")
+		fmt.Fprintf(c.stdout(), "No agent definitions found at %s\n", agentsDir)
+		fmt.Fprintln(c.stdout(), "Creating new definitions from templates...")
 	} else {
 		// Remove existing directory
-		fmt.Printf("Removing existing agent definitions at %s...\n", agentsDir)
+		fmt.Fprintf(c.stdout(), "Removing existing agent definitions at %s...\n", agentsDir)
 		if err := os.RemoveAll(agentsDir); err != nil {
 			return errors.Wrap(errors.CategoryRuntime, "failed to remove agent definitions", err)
 		}
@@ -2583,10 +3176,10 @@ func (c *CLI) resetAgentDefinitions(args []string) error {
 		return errors.Wrap(errors.CategoryRuntime, "failed to list agent definitions", err)
 	}
 
-	fmt.Printf("Reset complete. Agent definitions in %s:\n", agentsDir)
+	fmt.Fprintf(c.stdout(), "Reset complete. Agent definitions in %s:\n", agentsDir)
 	for _, entry := range entries {
 		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".md" {
-			fmt.Printf("  - %s\n", entry.Name())
+			fmt.Fprintf(c.stdout(), "  - %s\n", entry.Name())
 		}
 	}
 
@@ -2633,7 +3226,10 @@ func (c *CLI) showHistory(args []string) error {
 	}
 
 	// Get task history from daemon
-	client := socket.NewClient(c.paths.DaemonSock)
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
 	resp, err := client.Send(socket.Request{
 		Command: "task_history",
 		Args: map[string]interface{}{
@@ -2650,8 +3246,8 @@ func (c *CLI) showHistory(args []string) error {
 
 	history, ok := resp.Data.([]interface{})
 	if !ok || len(history) == 0 {
-		fmt.Printf("No task history for repository '%s'\n", repoName)
-		format.Dimmed("\nCreate workers with: multiclaude worker create <task>")
+		fmt.Fprintf(c.stdout(), "No task history for repository '%s'\n", repoName)
+		format.DimmedTo(c.stdout(), "\nCreate workers with: multiclaude worker create <task>")
 		return nil
 	}
 
@@ -2666,8 +3262,8 @@ func (c *CLI) showHistory(args []string) error {
 	if searchQuery != "" {
 		headerParts = append(headerParts, fmt.Sprintf("search=%q", searchQuery))
 	}
-	format.Header("%s:", strings.Join(headerParts, ", "))
-	fmt.Println()
+	format.HeaderTo(c.stdout(), "%s:", strings.Join(headerParts, ", "))
+	fmt.Fprintln(c.stdout())
 
 	// First pass: collect entries with details to show after table
 	type entryDetails struct {
@@ -2787,24 +3383,24 @@ func (c *CLI) showHistory(args []string) error {
 	// Show message if no results after filtering
 	if displayedCount == 0 {
 		if statusFilter != "" || searchQuery != "" {
-			fmt.Printf("No tasks match the filter criteria\n")
+			fmt.Fprintf(c.stdout(), "No tasks match the filter criteria\n")
 		}
 		return nil
 	}
 
-	table.Print()
+	table.PrintTo(c.stdout())
 
 	// Print detailed summary/failure section if any entries have them
 	if len(detailsToShow) > 0 {
-		fmt.Println()
-		format.Header("Details:")
+		fmt.Fprintln(c.stdout())
+		format.HeaderTo(c.stdout(), "Details:")
 		for _, d := range detailsToShow {
-			format.Bold.Printf("\n%s:\n", d.name)
+			format.Bold.Fprintf(c.stdout(), "\n%s:\n", d.name)
 			if d.summary != "" {
-				format.Dimmed("  Summary: %s", d.summary)
+				format.DimmedTo(c.stdout(), "  Summary: %s", d.summary)
 			}
 			if d.failureReason != "" {
-				format.Red.Printf("  Failure: %s\n", d.failureReason)
+				format.Red.Fprintf(c.stdout(), "  Failure: %s\n", d.failureReason)
 			}
 		}
 	}
@@ -2865,6 +3461,7 @@ func (c *CLI) getPRStatusForBranch(repoPath, branch, existingPRURL string) (stat
 
 func (c *CLI) removeWorker(args []string) error {
 	flags, remainingArgs := ParseFlags(args)
+	skipConfirm := flags["yes"] == "true"
 
 	// Determine repository
 	repoName, err := c.resolveRepo(flags)
@@ -2873,7 +3470,10 @@ func (c *CLI) removeWorker(args []string) error {
 	}
 
 	// Get worker info
-	client := socket.NewClient(c.paths.DaemonSock)
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
 	resp, err := client.Send(socket.Request{
 		Command: "list_agents",
 		Args: map[string]interface{}{
@@ -2899,18 +3499,18 @@ func (c *CLI) removeWorker(args []string) error {
 		if len(items) == 0 {
 			return errors.NoWorkersFound(repoName)
 		}
-		selected, err := SelectFromList("Select worker to remove:", items)
+		selected, err := c.selectFromList("Select worker to remove:", items)
 		if err != nil {
 			return err
 		}
 		if selected == "" {
-			fmt.Println("Cancelled")
+			fmt.Fprintln(c.stdout(), "Cancelled")
 			return nil
 		}
 		workerName = selected
 	}
 
-	fmt.Printf("Removing worker '%s' from repo '%s'\n", workerName, repoName)
+	fmt.Fprintf(c.stdout(), "Removing worker '%s' from repo '%s'\n", workerName, repoName)
 
 	// Find worker
 	var workerInfo map[string]interface{}
@@ -2933,41 +3533,43 @@ func (c *CLI) removeWorker(args []string) error {
 	// Check for uncommitted changes
 	hasUncommitted, err := worktree.HasUncommittedChanges(wtPath)
 	if err != nil {
-		fmt.Printf("Warning: failed to check for uncommitted changes: %v\n", err)
+		fmt.Fprintf(c.stdout(), "Warning: failed to check for uncommitted changes: %v\n", err)
 	} else if hasUncommitted {
-		fmt.Println("\nWarning: Worker has uncommitted changes!")
-		fmt.Println("Files may be lost if you continue with cleanup.")
-		fmt.Print("Continue with cleanup? [y/N]: ")
-
-		var response string
-		fmt.Scanln(&response)
-		if response != "y" && response != "Y" {
-			fmt.Println("Cleanup cancelled")
-			return nil
+		fmt.Fprintln(c.stdout(), "\nWarning: Worker has uncommitted changes!")
+		fmt.Fprintln(c.stdout(), "Files may be lost if you continue with cleanup.")
+		if !skipConfirm {
+			ok, err := c.confirm("Continue with cleanup? [y/N]: ")
+			if err != nil {
+				return err
+			}
+			if !ok {
+				fmt.Fprintln(c.stdout(), "Cleanup cancelled")
+				return nil
+			}
 		}
 	}
 
 	// Check for unpushed commits
-	if err := checkUnpushedCommits(wtPath, "Worker", "cleanup"); err != nil {
-		return nil
+	if ok, err := c.checkUnpushedCommits(wtPath, "Worker", "cleanup", skipConfirm); err != nil || !ok {
+		return err
 	}
 
 	// Kill tmux window
 	tmuxSession := sanitizeTmuxSessionName(repoName)
 	tmuxWindow := workerInfo["tmux_window"].(string)
-	fmt.Printf("Killing tmux window: %s\n", tmuxWindow)
+	fmt.Fprintf(c.stdout(), "Killing tmux window: %s\n", tmuxWindow)
 	cmd := exec.Command("tmux", "kill-window", "-t", fmt.Sprintf("%s:%s", tmuxSession, tmuxWindow))
 	if err := cmd.Run(); err != nil {
-		fmt.Printf("Warning: failed to kill tmux window: %v\n", err)
+		fmt.Fprintf(c.stdout(), "Warning: failed to kill tmux window: %v\n", err)
 	}
 
 	// Remove worktree
 	repoPath := c.paths.RepoDir(repoName)
 	wt := worktree.NewManager(repoPath)
 
-	fmt.Printf("Removing worktree: %s\n", wtPath)
+	fmt.Fprintf(c.stdout(), "Removing worktree: %s\n", wtPath)
 	if err := wt.Remove(wtPath, false); err != nil {
-		fmt.Printf("Warning: failed to remove worktree: %v\n", err)
+		fmt.Fprintf(c.stdout(), "Warning: failed to remove worktree: %v\n", err)
 	}
 
 	// Unregister from daemon
@@ -2985,7 +3587,7 @@ func (c *CLI) removeWorker(args []string) error {
 		return fmt.Errorf("failed to unregister worker: %s", resp.Error)
 	}
 
-	fmt.Println("✓ Worker removed successfully")
+	fmt.Fprintln(c.stdout(), "✓ Worker removed successfully")
 	return nil
 }
 
@@ -3002,7 +3604,10 @@ func (c *CLI) hibernateRepo(args []string) error {
 	}
 
 	// Get agent list from daemon
-	client := socket.NewClient(c.paths.DaemonSock)
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
 	resp, err := client.Send(socket.Request{
 		Command: "list_agents",
 		Args: map[string]interface{}{
@@ -3018,7 +3623,7 @@ func (c *CLI) hibernateRepo(args []string) error {
 
 	agents, _ := resp.Data.([]interface{})
 	if len(agents) == 0 {
-		fmt.Printf("No agents running in repository '%s'\n", repoName)
+		fmt.Fprintf(c.stdout(), "No agents running in repository '%s'\n", repoName)
 		return nil
 	}
 
@@ -3060,15 +3665,15 @@ func (c *CLI) hibernateRepo(args []string) error {
 	}
 
 	if len(agentsToHibernate) == 0 {
-		fmt.Printf("No agents to hibernate in repository '%s'\n", repoName)
+		fmt.Fprintf(c.stdout(), "No agents to hibernate in repository '%s'\n", repoName)
 		if !hibernateAll {
-			fmt.Println("Use --all to also hibernate persistent agents (supervisor, workspace, etc.)")
+			fmt.Fprintln(c.stdout(), "Use --all to also hibernate persistent agents (supervisor, workspace, etc.)")
 		}
 		return nil
 	}
 
 	// Show summary and confirm
-	fmt.Printf("Hibernating %d agent(s) in repository '%s':\n", len(agentsToHibernate), repoName)
+	fmt.Fprintf(c.stdout(), "Hibernating %d agent(s) in repository '%s':\n", len(agentsToHibernate), repoName)
 	for _, agent := range agentsToHibernate {
 		name, _ := agent["name"].(string)
 		agentType, _ := agent["type"].(string)
@@ -3083,19 +3688,20 @@ func (c *CLI) hibernateRepo(args []string) error {
 		if hasChanges {
 			changeMarker = " [has uncommitted changes]"
 		}
-		fmt.Printf("  - %s (%s)%s\n", name, agentType, changeMarker)
+		fmt.Fprintf(c.stdout(), "  - %s (%s)%s\n", name, agentType, changeMarker)
 	}
 
 	if len(agentsWithChanges) > 0 {
-		fmt.Printf("\n%d agent(s) have uncommitted changes that will be archived.\n", len(agentsWithChanges))
+		fmt.Fprintf(c.stdout(), "\n%d agent(s) have uncommitted changes that will be archived.\n", len(agentsWithChanges))
 	}
 
 	if !skipConfirm {
-		fmt.Print("\nContinue? [y/N]: ")
-		var response string
-		fmt.Scanln(&response)
-		if response != "y" && response != "Y" {
-			fmt.Println("Cancelled")
+		ok, err := c.confirm("\nContinue? [y/N]: ")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Fprintln(c.stdout(), "Cancelled")
 			return nil
 		}
 	}
@@ -3107,7 +3713,7 @@ func (c *CLI) hibernateRepo(args []string) error {
 		if err := os.MkdirAll(archiveDir, 0755); err != nil {
 			return fmt.Errorf("failed to create archive directory: %w", err)
 		}
-		fmt.Printf("\nArchiving to: %s\n", archiveDir)
+		fmt.Fprintf(c.stdout(), "\nArchiving to: %s\n", archiveDir)
 	}
 
 	// Archive uncommitted changes
@@ -3118,7 +3724,7 @@ func (c *CLI) hibernateRepo(args []string) error {
 		branch, _ := agent["branch"].(string)
 		task, _ := agent["task"].(string)
 
-		fmt.Printf("Archiving changes from %s...\n", name)
+		fmt.Fprintf(c.stdout(), "Archiving changes from %s...\n", name)
 
 		// Create patch file with git diff
 		patchPath := filepath.Join(archiveDir, name+".patch")
@@ -3126,7 +3732,7 @@ func (c *CLI) hibernateRepo(args []string) error {
 		cmd.Dir = wtPath
 		output, err := cmd.Output()
 		if err != nil {
-			fmt.Printf("Warning: failed to create patch for %s: %v\n", name, err)
+			fmt.Fprintf(c.stdout(), "Warning: failed to create patch for %s: %v\n", name, err)
 			continue
 		}
 
@@ -3137,7 +3743,7 @@ func (c *CLI) hibernateRepo(args []string) error {
 
 		// Write patch file
 		if err := os.WriteFile(patchPath, output, 0644); err != nil {
-			fmt.Printf("Warning: failed to write patch for %s: %v\n", name, err)
+			fmt.Fprintf(c.stdout(), "Warning: failed to write patch for %s: %v\n", name, err)
 			continue
 		}
 
@@ -3181,13 +3787,13 @@ func (c *CLI) hibernateRepo(args []string) error {
 	repoPath := c.paths.RepoDir(repoName)
 	wt := worktree.NewManager(repoPath)
 
-	fmt.Println()
+	fmt.Fprintln(c.stdout())
 	for _, agent := range agentsToHibernate {
 		name, _ := agent["name"].(string)
 		wtPath, _ := agent["worktree_path"].(string)
 		tmuxWindow, _ := agent["tmux_window"].(string)
 
-		fmt.Printf("Stopping %s...\n", name)
+		fmt.Fprintf(c.stdout(), "Stopping %s...\n", name)
 
 		// Kill tmux window
 		if tmuxWindow != "" {
@@ -3215,14 +3821,14 @@ func (c *CLI) hibernateRepo(args []string) error {
 		})
 	}
 
-	fmt.Println()
-	fmt.Printf("✓ Hibernated %d agent(s) in '%s'\n", len(agentsToHibernate), repoName)
+	fmt.Fprintln(c.stdout())
+	fmt.Fprintf(c.stdout(), "✓ Hibernated %d agent(s) in '%s'\n", len(agentsToHibernate), repoName)
 	if len(archivedAgents) > 0 {
-		fmt.Printf("✓ Archived %d agent(s) with uncommitted changes to:\n", len(archivedAgents))
-		fmt.Printf("  %s\n", archiveDir)
-		fmt.Println("\nTo restore archived patches:")
-		fmt.Println("  cd <worktree>")
-		fmt.Printf("  git apply %s/<agent>.patch\n", archiveDir)
+		fmt.Fprintf(c.stdout(), "✓ Archived %d agent(s) with uncommitted changes to:\n", len(archivedAgents))
+		fmt.Fprintf(c.stdout(), "  %s\n", archiveDir)
+		fmt.Fprintln(c.stdout(), "\nTo restore archived patches:")
+		fmt.Fprintln(c.stdout(), "  cd <worktree>")
+		fmt.Fprintf(c.stdout(), "  git apply %s/<agent>.patch\n", archiveDir)
 	}
 
 	return nil
@@ -3271,13 +3877,16 @@ func (c *CLI) addWorkspace(args []string) error {
 	startBranch := "HEAD" // Default to current branch/HEAD
 	if branch, ok := flags["branch"]; ok {
 		startBranch = branch
-		fmt.Printf("Creating workspace '%s' in repo '%s' from branch '%s'\n", workspaceName, repoName, branch)
+		fmt.Fprintf(c.stdout(), "Creating workspace '%s' in repo '%s' from branch '%s'\n", workspaceName, repoName, branch)
 	} else {
-		fmt.Printf("Creating workspace '%s' in repo '%s'\n", workspaceName, repoName)
+		fmt.Fprintf(c.stdout(), "Creating workspace '%s' in repo '%s'\n", workspaceName, repoName)
 	}
 
 	// Check if workspace already exists
-	client := socket.NewClient(c.paths.DaemonSock)
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
 	resp, err := client.Send(socket.Request{
 		Command: "list_agents",
 		Args: map[string]interface{}{
@@ -3312,16 +3921,16 @@ func (c *CLI) addWorkspace(args []string) error {
 
 	// Check if worktree path already exists (from previous incomplete workspace add)
 	if _, err := os.Stat(wtPath); err == nil {
-		fmt.Printf("Warning: Worktree path '%s' already exists\n", wtPath)
-		fmt.Printf("This may be from a previous incomplete workspace creation.\n")
-		fmt.Printf("Auto-repairing: removing existing worktree...\n")
+		fmt.Fprintf(c.stdout(), "Warning: Worktree path '%s' already exists\n", wtPath)
+		fmt.Fprintf(c.stdout(), "This may be from a previous incomplete workspace creation.\n")
+		fmt.Fprintf(c.stdout(), "Auto-repairing: removing existing worktree...\n")
 		if err := wt.Remove(wtPath, true); err != nil {
 			return fmt.Errorf("failed to clean up existing worktree: %w\nPlease manually remove it with: git worktree remove %s", err, wtPath)
 		}
-		fmt.Println("✓ Cleaned up stale worktree")
+		fmt.Fprintln(c.stdout(), "✓ Cleaned up stale worktree")
 	}
 
-	fmt.Printf("Creating worktree at: %s\n", wtPath)
+	fmt.Fprintf(c.stdout(), "Creating worktree at: %s\n", wtPath)
 	if err := wt.CreateNewBranch(wtPath, branchName, startBranch); err != nil {
 		return errors.WorktreeCreationFailed(err)
 	}
@@ -3332,17 +3941,17 @@ func (c *CLI) addWorkspace(args []string) error {
 	// Check if tmux window already exists (stale window from previous incomplete workspace add)
 	tmuxClient := tmux.NewClient()
 	if exists, err := tmuxClient.HasWindow(context.Background(), tmuxSession, workspaceName); err == nil && exists {
-		fmt.Printf("Warning: Tmux window '%s' already exists in session '%s'\n", workspaceName, tmuxSession)
-		fmt.Printf("This may be from a previous incomplete workspace creation.\n")
-		fmt.Printf("Auto-repairing: killing existing tmux window...\n")
+		fmt.Fprintf(c.stdout(), "Warning: Tmux window '%s' already exists in session '%s'\n", workspaceName, tmuxSession)
+		fmt.Fprintf(c.stdout(), "This may be from a previous incomplete workspace creation.\n")
+		fmt.Fprintf(c.stdout(), "Auto-repairing: killing existing tmux window...\n")
 		if err := tmuxClient.KillWindow(context.Background(), tmuxSession, workspaceName); err != nil {
 			return fmt.Errorf("failed to clean up existing tmux window: %w\nPlease manually kill it with: tmux kill-window -t %s:%s", err, tmuxSession, workspaceName)
 		}
-		fmt.Println("✓ Cleaned up stale tmux window")
+		fmt.Fprintln(c.stdout(), "✓ Cleaned up stale tmux window")
 	}
 
 	// Create tmux window for workspace (detached so it doesn't switch focus)
-	fmt.Printf("Creating tmux window: %s\n", workspaceName)
+	fmt.Fprintf(c.stdout(), "Creating tmux window: %s\n", workspaceName)
 	cmd := exec.Command("tmux", "new-window", "-d", "-t", tmuxSession, "-n", workspaceName, "-c", wtPath)
 	if err := cmd.Run(); err != nil {
 		return errors.TmuxOperationFailed("create window", err)
@@ -3362,7 +3971,7 @@ func (c *CLI) addWorkspace(args []string) error {
 
 	// Copy hooks configuration if it exists
 	if err := hooks.CopyConfig(repoPath, wtPath); err != nil {
-		fmt.Printf("Warning: failed to copy hooks config: %v\n", err)
+		fmt.Fprintf(c.stdout(), "Warning: failed to copy hooks config: %v\n", err)
 	}
 
 	// Start Claude in workspace window (skip in test mode)
@@ -3374,8 +3983,8 @@ func (c *CLI) addWorkspace(args []string) error {
 			return fmt.Errorf("failed to resolve claude binary: %w", err)
 		}
 
-		fmt.Println("Starting Claude Code in workspace window...")
-		pid, err := c.startClaudeInTmux(claudeBinary, tmuxSession, workspaceName, wtPath, workspaceSessionID, workspacePromptFile, repoName, "")
+		fmt.Fprintln(c.stdout(), "Starting Claude Code in workspace window...")
+		pid, err := c.startClaudeInTmux(claudeBinary, tmuxSession, workspaceName, wtPath, workspaceSessionID, workspacePromptFile, repoName, state.AgentTypeWorkspace, "")
 		if err != nil {
 			return fmt.Errorf("failed to start workspace Claude: %w", err)
 		}
@@ -3383,7 +3992,7 @@ func (c *CLI) addWorkspace(args []string) error {
 
 		// Set up output capture for workspace
 		if err := c.setupOutputCapture(tmuxSession, workspaceName, repoName, workspaceName, "workspace"); err != nil {
-			fmt.Printf("Warning: failed to setup output capture for workspace: %v\n", err)
+			fmt.Fprintf(c.stdout(), "Warning: failed to setup output capture for workspace: %v\n", err)
 		}
 	}
 
@@ -3407,13 +4016,13 @@ func (c *CLI) addWorkspace(args []string) error {
 		return fmt.Errorf("failed to register workspace: %s", resp.Error)
 	}
 
-	fmt.Println()
-	fmt.Println("✓ Workspace created successfully!")
-	fmt.Printf("  Name: %s\n", workspaceName)
-	fmt.Printf("  Branch: %s\n", branchName)
-	fmt.Printf("  Worktree: %s\n", wtPath)
-	fmt.Printf("\nConnect to workspace: multiclaude workspace connect %s\n", workspaceName)
-	fmt.Printf("Or use: multiclaude attach %s\n", workspaceName)
+	fmt.Fprintln(c.stdout())
+	fmt.Fprintln(c.stdout(), "✓ Workspace created successfully!")
+	fmt.Fprintf(c.stdout(), "  Name: %s\n", workspaceName)
+	fmt.Fprintf(c.stdout(), "  Branch: %s\n", branchName)
+	fmt.Fprintf(c.stdout(), "  Worktree: %s\n", wtPath)
+	fmt.Fprintf(c.stdout(), "\nConnect to workspace: multiclaude workspace connect %s\n", workspaceName)
+	fmt.Fprintf(c.stdout(), "Or use: multiclaude attach %s\n", workspaceName)
 
 	return nil
 }
@@ -3421,6 +4030,7 @@ func (c *CLI) addWorkspace(args []string) error {
 // removeWorkspace removes a workspace
 func (c *CLI) removeWorkspace(args []string) error {
 	flags, remainingArgs := ParseFlags(args)
+	skipConfirm := flags["yes"] == "true"
 
 	// Determine repository
 	repoName, err := c.resolveRepo(flags)
@@ -3429,7 +4039,10 @@ func (c *CLI) removeWorkspace(args []string) error {
 	}
 
 	// Get workspace info
-	client := socket.NewClient(c.paths.DaemonSock)
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
 	resp, err := client.Send(socket.Request{
 		Command: "list_agents",
 		Args: map[string]interface{}{
@@ -3455,18 +4068,18 @@ func (c *CLI) removeWorkspace(args []string) error {
 		if len(items) == 0 {
 			return errors.NoWorkspacesFound(repoName)
 		}
-		selected, err := SelectFromList("Select workspace to remove:", items)
+		selected, err := c.selectFromList("Select workspace to remove:", items)
 		if err != nil {
 			return err
 		}
 		if selected == "" {
-			fmt.Println("Cancelled")
+			fmt.Fprintln(c.stdout(), "Cancelled")
 			return nil
 		}
 		workspaceName = selected
 	}
 
-	fmt.Printf("Removing workspace '%s' from repo '%s'\n", workspaceName, repoName)
+	fmt.Fprintf(c.stdout(), "Removing workspace '%s' from repo '%s'\n", workspaceName, repoName)
 
 	// Find workspace
 	var workspaceInfo map[string]interface{}
@@ -3491,41 +4104,43 @@ func (c *CLI) removeWorkspace(args []string) error {
 	// Check for uncommitted changes
 	hasUncommitted, err := worktree.HasUncommittedChanges(wtPath)
 	if err != nil {
-		fmt.Printf("Warning: failed to check for uncommitted changes: %v\n", err)
+		fmt.Fprintf(c.stdout(), "Warning: failed to check for uncommitted changes: %v\n", err)
 	} else if hasUncommitted {
-		fmt.Println("\nWarning: Workspace has uncommitted changes!")
-		fmt.Println("Files may be lost if you continue with removal.")
-		fmt.Print("Continue with removal? [y/N]: ")
-
-		var response string
-		fmt.Scanln(&response)
-		if response != "y" && response != "Y" {
-			fmt.Println("Removal cancelled")
-			return nil
+		fmt.Fprintln(c.stdout(), "\nWarning: Workspace has uncommitted changes!")
+		fmt.Fprintln(c.stdout(), "Files may be lost if you continue with removal.")
+		if !skipConfirm {
+			ok, err := c.confirm("Continue with removal? [y/N]: ")
+			if err != nil {
+				return err
+			}
+			if !ok {
+				fmt.Fprintln(c.stdout(), "Removal cancelled")
+				return nil
+			}
 		}
 	}
 
 	// Check for unpushed commits
-	if err := checkUnpushedCommits(wtPath, "Workspace", "removal"); err != nil {
-		return nil
+	if ok, err := c.checkUnpushedCommits(wtPath, "Workspace", "removal", skipConfirm); err != nil || !ok {
+		return err
 	}
 
 	// Kill tmux window
 	tmuxSession := sanitizeTmuxSessionName(repoName)
 	tmuxWindow := workspaceInfo["tmux_window"].(string)
-	fmt.Printf("Killing tmux window: %s\n", tmuxWindow)
+	fmt.Fprintf(c.stdout(), "Killing tmux window: %s\n", tmuxWindow)
 	cmd := exec.Command("tmux", "kill-window", "-t", fmt.Sprintf("%s:%s", tmuxSession, tmuxWindow))
 	if err := cmd.Run(); err != nil {
-		fmt.Printf("Warning: failed to kill tmux window: %v\n", err)
+		fmt.Fprintf(c.stdout(), "Warning: failed to kill tmux window: %v\n", err)
 	}
 
 	// Remove worktree
 	repoPath := c.paths.RepoDir(repoName)
 	wt := worktree.NewManager(repoPath)
 
-	fmt.Printf("Removing worktree: %s\n", wtPath)
+	fmt.Fprintf(c.stdout(), "Removing worktree: %s\n", wtPath)
 	if err := wt.Remove(wtPath, false); err != nil {
-		fmt.Printf("Warning: failed to remove worktree: %v\n", err)
+		fmt.Fprintf(c.stdout(), "Warning: failed to remove worktree: %v\n", err)
 	}
 
 	// Unregister from daemon
@@ -3543,7 +4158,7 @@ func (c *CLI) removeWorkspace(args []string) error {
 		return fmt.Errorf("failed to unregister workspace: %s", resp.Error)
 	}
 
-	fmt.Println("✓ Workspace removed successfully")
+	fmt.Fprintln(c.stdout(), "✓ Workspace removed successfully")
 	return nil
 }
 
@@ -3557,7 +4172,10 @@ func (c *CLI) listWorkspaces(args []string) error {
 		return errors.NotInRepo()
 	}
 
-	client := socket.NewClient(c.paths.DaemonSock)
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
 	resp, err := client.Send(socket.Request{
 		Command: "list_agents",
 		Args: map[string]interface{}{
@@ -3589,14 +4207,22 @@ func (c *CLI) listWorkspaces(args []string) error {
 		}
 	}
 
+	if c.jsonOutput {
+		c.setJSONResult(map[string]interface{}{
+			"repo":       repoName,
+			"workspaces": workspaces,
+		})
+		return nil
+	}
+
 	if len(workspaces) == 0 {
-		fmt.Printf("No workspaces in repository '%s'\n", repoName)
-		format.Dimmed("\nCreate a workspace with: multiclaude workspace add <name>")
+		fmt.Fprintf(c.stdout(), "No workspaces in repository '%s'\n", repoName)
+		format.DimmedTo(c.stdout(), "\nCreate a workspace with: multiclaude workspace add <name>")
 		return nil
 	}
 
-	format.Header("Workspaces in '%s' (%d):", repoName, len(workspaces))
-	fmt.Println()
+	format.HeaderTo(c.stdout(), "Workspaces in '%s' (%d):", repoName, len(workspaces))
+	fmt.Fprintln(c.stdout())
 
 	table := format.NewColoredTable("NAME", "BRANCH", "STATUS")
 	for _, ws := range workspaces {
@@ -3619,13 +4245,15 @@ func (c *CLI) listWorkspaces(args []string) error {
 			statusCell,
 		)
 	}
-	table.Print()
+	table.PrintTo(c.stdout())
 
 	return nil
 }
 
 // connectWorkspace attaches to a workspace
 func (c *CLI) connectWorkspace(args []string) error {
+	c.streamOutput()
+
 	flags, remainingArgs := ParseFlags(args)
 
 	// Determine repository
@@ -3635,7 +4263,10 @@ func (c *CLI) connectWorkspace(args []string) error {
 	}
 
 	// Get workspace info
-	client := socket.NewClient(c.paths.DaemonSock)
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
 	resp, err := client.Send(socket.Request{
 		Command: "list_agents",
 		Args: map[string]interface{}{
@@ -3666,7 +4297,7 @@ func (c *CLI) connectWorkspace(args []string) error {
 			return err
 		}
 		if selected == "" {
-			fmt.Println("Cancelled")
+			fmt.Fprintln(c.stdout(), "Cancelled")
 			return nil
 		}
 		workspaceName = selected
@@ -3751,7 +4382,10 @@ func validateWorkspaceName(name string) error {
 
 // getReposList is a helper to get the list of repos
 func (c *CLI) getReposList() []string {
-	client := socket.NewClient(c.paths.DaemonSock)
+	client, err := c.socketClient()
+	if err != nil {
+		return []string{}
+	}
 	resp, err := client.Send(socket.Request{Command: "list_repos"})
 	if err != nil {
 		return []string{}
@@ -3800,11 +4434,12 @@ func (c *CLI) sendMessage(args []string) error {
 	}
 
 	// Trigger immediate routing (best-effort, polling is fallback)
-	client := socket.NewClient(c.paths.DaemonSock)
-	_, _ = client.Send(socket.Request{Command: "route_messages"})
 	// Ignore errors - 2-minute polling fallback will catch it
+	if client, err := c.socketClient(); err == nil {
+		_, _ = client.Send(socket.Request{Command: "route_messages"})
+	}
 
-	fmt.Printf("Message sent to %s (ID: %s)\n", to, msg.ID)
+	fmt.Fprintf(c.stdout(), "Message sent to %s (ID: %s)\n", to, msg.ID)
 	return nil
 }
 
@@ -3823,18 +4458,30 @@ func (c *CLI) listMessages(args []string) error {
 		return fmt.Errorf("failed to list messages: %w", err)
 	}
 
+	if c.jsonOutput {
+		if msgs == nil {
+			msgs = []*messages.Message{}
+		}
+		c.setJSONResult(map[string]interface{}{
+			"repo":     repoName,
+			"agent":    agentName,
+			"messages": msgs,
+		})
+		return nil
+	}
+
 	if len(msgs) == 0 {
-		fmt.Println("No messages")
+		fmt.Fprintln(c.stdout(), "No messages")
 		return nil
 	}
 
-	fmt.Printf("Messages for %s (%d):\n", agentName, len(msgs))
+	fmt.Fprintf(c.stdout(), "Messages for %s (%d):\n", agentName, len(msgs))
 	for _, msg := range msgs {
 		status := msg.Status
 		if msg.Status == messages.StatusAcked && msg.AckedAt != nil {
 			status = messages.Status(fmt.Sprintf("acked (%s)", formatTime(*msg.AckedAt)))
 		}
-		fmt.Printf("  [%s] %s - From: %s - %s - %s\n",
+		fmt.Fprintf(c.stdout(), "  [%s] %s - From: %s - %s - %s\n",
 			msg.ID,
 			formatTime(msg.Timestamp),
 			msg.From,
@@ -3869,21 +4516,21 @@ func (c *CLI) readMessage(args []string) error {
 	// Update status to read
 	if msg.Status == messages.StatusPending || msg.Status == messages.StatusDelivered {
 		if err := msgMgr.UpdateStatus(repoName, agentName, messageID, messages.StatusRead); err != nil {
-			fmt.Printf("Warning: failed to update message status: %v\n", err)
+			fmt.Fprintf(c.stdout(), "Warning: failed to update message status: %v\n", err)
 		}
 	}
 
 	// Display message
-	fmt.Printf("Message: %s\n", msg.ID)
-	fmt.Printf("From: %s\n", msg.From)
-	fmt.Printf("To: %s\n", msg.To)
-	fmt.Printf("Time: %s\n", msg.Timestamp.Format(time.RFC3339))
-	fmt.Printf("Status: %s\n", msg.Status)
+	fmt.Fprintf(c.stdout(), "Message: %s\n", msg.ID)
+	fmt.Fprintf(c.stdout(), "From: %s\n", msg.From)
+	fmt.Fprintf(c.stdout(), "To: %s\n", msg.To)
+	fmt.Fprintf(c.stdout(), "Time: %s\n", msg.Timestamp.Format(time.RFC3339))
+	fmt.Fprintf(c.stdout(), "Status: %s\n", msg.Status)
 	if msg.AckedAt != nil {
-		fmt.Printf("Acked: %s\n", msg.AckedAt.Format(time.RFC3339))
+		fmt.Fprintf(c.stdout(), "Acked: %s\n", msg.AckedAt.Format(time.RFC3339))
 	}
-	fmt.Println()
-	fmt.Println(msg.Body)
+	fmt.Fprintln(c.stdout())
+	fmt.Fprintln(c.stdout(), msg.Body)
 
 	return nil
 }
@@ -3908,7 +4555,7 @@ func (c *CLI) ackMessage(args []string) error {
 		return fmt.Errorf("failed to acknowledge message: %w", err)
 	}
 
-	fmt.Printf("Message %s acknowledged\n", messageID)
+	fmt.Fprintf(c.stdout(), "Message %s acknowledged\n", messageID)
 	return nil
 }
 
@@ -4056,7 +4703,10 @@ func (c *CLI) resolveRepo(flags map[string]string) (string, error) {
 	}
 
 	// 4. Check current repo from daemon
-	client := socket.NewClient(c.paths.DaemonSock)
+	client, err := c.socketClient()
+	if err != nil {
+		return "", err
+	}
 	resp, err := client.Send(socket.Request{
 		Command: "get_current_repo",
 	})
@@ -4164,38 +4814,67 @@ func truncateString(s string, maxLen int) string {
 }
 
 // checkUnpushedCommits checks if a worktree has unpushed commits and prompts the user for confirmation.
-// Returns nil if the user wants to continue, or an error to cancel the operation.
+// It reports whether to continue; with skipConfirm the warning is printed but not asked about.
 // The entityType parameter should be "Worker" or "Workspace" for appropriate messaging.
 // The action parameter should be "cleanup" or "removal" for appropriate messaging.
-func checkUnpushedCommits(wtPath, entityType, action string) error {
+func (c *CLI) checkUnpushedCommits(wtPath, entityType, action string, skipConfirm bool) (bool, error) {
 	hasUnpushed, err := worktree.HasUnpushedCommits(wtPath)
 	if err != nil {
 		// This is ok - might not have a tracking branch
-		fmt.Printf("Note: Could not check for unpushed commits (no tracking branch?)\n")
-		return nil
+		fmt.Fprintln(c.stdout(), "Note: Could not check for unpushed commits (no tracking branch?)")
+		return true, nil
 	}
 
 	if !hasUnpushed {
-		return nil
+		return true, nil
 	}
 
-	fmt.Printf("\nWarning: %s has unpushed commits!\n", entityType)
+	fmt.Fprintf(c.stdout(), "\nWarning: %s has unpushed commits!\n", entityType)
 	branch, err := worktree.GetCurrentBranch(wtPath)
 	if err == nil {
-		fmt.Printf("Branch '%s' has commits not pushed to remote.\n", branch)
+		fmt.Fprintf(c.stdout(), "Branch '%s' has commits not pushed to remote.\n", branch)
+	}
+	fmt.Fprintf(c.stdout(), "These commits may be lost if you continue with %s.\n", action)
+	if skipConfirm {
+		return true, nil
 	}
-	fmt.Printf("These commits may be lost if you continue with %s.\n", action)
-	fmt.Printf("Continue with %s? [y/N]: ", action)
 
-	var response string
-	fmt.Scanln(&response)
-	if response != "y" && response != "Y" {
+	ok, err := c.confirm(fmt.Sprintf("Continue with %s? [y/N]: ", action))
+	if err != nil {
+		return false, err
+	}
+	if !ok {
 		// Capitalize first letter of action for the message
 		actionCapitalized := strings.ToUpper(action[:1]) + action[1:]
-		fmt.Printf("%s cancelled\n", actionCapitalized)
-		return fmt.Errorf("cancelled by user")
+		fmt.Fprintf(c.stdout(), "%s cancelled\n", actionCapitalized)
 	}
-	return nil
+	return ok, nil
+}
+
+// selectFromList prompts the user to pick one of items with SelectFromList.
+// In JSON mode it fails instead, so the caller must be given a name.
+func (c *CLI) selectFromList(prompt string, items []SelectableItem) (string, error) {
+	if c.jsonOutput && !c.jsonStreaming {
+		return "", errors.InvalidUsage("a name is required in JSON mode")
+	}
+	return SelectFromList(prompt, items)
+}
+
+// errConfirmationRequired is returned instead of prompting in JSON mode,
+// where nobody is reading stdout to answer
+var errConfirmationRequired = errors.InvalidUsage("confirmation required: pass --yes to proceed without prompting in JSON mode")
+
+// confirm prints prompt and reports whether the user answered y. In JSON
+// mode it fails with errConfirmationRequired instead of reading stdin.
+func (c *CLI) confirm(prompt string) (bool, error) {
+	if c.jsonOutput {
+		return false, errConfirmationRequired
+	}
+	fmt.Fprint(c.stdout(), prompt)
+
+	var response string
+	fmt.Scanln(&response)
+	return response == "y" || response == "Y", nil
 }
 
 func (c *CLI) completeWorker(args []string) error {
@@ -4208,7 +4887,7 @@ func (c *CLI) completeWorker(args []string) error {
 		return fmt.Errorf("failed to determine agent context: %w", err)
 	}
 
-	fmt.Printf("Marking agent '%s' as complete...\n", agentName)
+	fmt.Fprintf(c.stdout(), "Marking agent '%s' as complete...\n", agentName)
 
 	// Build request args
 	reqArgs := map[string]interface{}{
@@ -4219,16 +4898,19 @@ func (c *CLI) completeWorker(args []string) error {
 	// Add optional summary
 	if summary, ok := flags["summary"]; ok && summary != "" {
 		reqArgs["summary"] = summary
-		fmt.Printf("Summary: %s\n", summary)
+		fmt.Fprintf(c.stdout(), "Summary: %s\n", summary)
 	}
 
 	// Add optional failure reason
 	if failureReason, ok := flags["failure"]; ok && failureReason != "" {
 		reqArgs["failure_reason"] = failureReason
-		fmt.Printf("Failure reason: %s\n", failureReason)
+		fmt.Fprintf(c.stdout(), "Failure reason: %s\n", failureReason)
 	}
 
-	client := socket.NewClient(c.paths.DaemonSock)
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
 	resp, err := client.Send(socket.Request{
 		Command: "complete_agent",
 		Args:    reqArgs,
@@ -4240,8 +4922,53 @@ func (c *CLI) completeWorker(args []string) error {
 		return errors.Wrap(errors.CategoryRuntime, "failed to mark agent complete", fmt.Errorf("%s", resp.Error))
 	}
 
-	fmt.Println("✓ Agent marked as complete")
-	fmt.Println("The daemon will clean up this agent's resources shortly.")
+	fmt.Fprintln(c.stdout(), "✓ Agent marked as complete")
+	fmt.Fprintln(c.stdout(), "The daemon will clean up this agent's resources shortly.")
+	return nil
+}
+
+// setAgentStatus reports the current agent as blocked or running
+func (c *CLI) setAgentStatus(args []string) error {
+	flags, remaining := ParseFlags(args)
+	if len(remaining) < 1 || (remaining[0] != "blocked" && remaining[0] != "running") {
+		return errors.InvalidUsage("usage: multiclaude agent status <blocked|running> [--repo <repo>] [--agent <name>]")
+	}
+	status := remaining[0]
+
+	repoName, agentName, err := c.inferAgentContext()
+	if r := flags["repo"]; r != "" {
+		repoName = r
+	}
+	if a := flags["agent"]; a != "" {
+		agentName = a
+	}
+	if repoName == "" || agentName == "" {
+		if err == nil {
+			err = fmt.Errorf("repository and agent are required")
+		}
+		return errors.InvalidUsage(fmt.Sprintf("could not determine agent context (%v) - run from an agent or pass --repo and --agent", err))
+	}
+
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
+	resp, err := client.Send(socket.Request{
+		Command: "set_agent_status",
+		Args: map[string]interface{}{
+			"repo":   repoName,
+			"agent":  agentName,
+			"status": status,
+		},
+	})
+	if err != nil {
+		return errors.DaemonCommunicationFailed("setting agent status", err)
+	}
+	if !resp.Success {
+		return errors.Wrap(errors.CategoryRuntime, "failed to set agent status", fmt.Errorf("%s", resp.Error))
+	}
+
+	fmt.Fprintf(c.stdout(), "Agent '%s' is now %s\n", agentName, status)
 	return nil
 }
 
@@ -4268,9 +4995,12 @@ func (c *CLI) restartAgentCmd(args []string) error {
 
 	force := flags["force"] == "true"
 
-	fmt.Printf("Restarting agent '%s' in repository '%s'...\n", agentName, repoName)
+	fmt.Fprintf(c.stdout(), "Restarting agent '%s' in repository '%s'...\n", agentName, repoName)
 
-	client := socket.NewClient(c.paths.DaemonSock)
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
 	resp, err := client.Send(socket.Request{
 		Command: "restart_agent",
 		Args: map[string]interface{}{
@@ -4289,17 +5019,86 @@ func (c *CLI) restartAgentCmd(args []string) error {
 	// Extract PID from response
 	if data, ok := resp.Data.(map[string]interface{}); ok {
 		if pid, ok := data["pid"].(float64); ok {
-			fmt.Printf("✓ Agent '%s' restarted successfully (PID: %d)\n", agentName, int(pid))
+			fmt.Fprintf(c.stdout(), "✓ Agent '%s' restarted successfully (PID: %d)\n", agentName, int(pid))
 		} else {
-			fmt.Printf("✓ Agent '%s' restarted successfully\n", agentName)
+			fmt.Fprintf(c.stdout(), "✓ Agent '%s' restarted successfully\n", agentName)
 		}
 	} else {
-		fmt.Printf("✓ Agent '%s' restarted successfully\n", agentName)
+		fmt.Fprintf(c.stdout(), "✓ Agent '%s' restarted successfully\n", agentName)
 	}
 
 	return nil
 }
 
+func (c *CLI) killAgentCmd(args []string) error {
+	flags, remaining := ParseFlags(args)
+
+	if len(remaining) < 1 {
+		return errors.InvalidUsage("usage: multiclaude agent kill <name> [--repo <repo>] [--grace <duration>] [--dry-run]")
+	}
+	agentName := remaining[0]
+
+	repoName := flags["repo"]
+	if repoName == "" {
+		inferred, err := c.inferRepoFromCwd()
+		if err != nil {
+			return errors.InvalidUsage("could not determine repository - use --repo flag or run from within a multiclaude worktree")
+		}
+		repoName = inferred
+	}
+
+	reqArgs := map[string]interface{}{
+		"repo":  repoName,
+		"agent": agentName,
+	}
+	if grace := flags["grace"]; grace != "" {
+		if _, err := time.ParseDuration(grace); err != nil {
+			return errors.InvalidUsage(fmt.Sprintf("invalid --grace value %q - use a duration like 10s or 1m", grace))
+		}
+		reqArgs["grace"] = grace
+	}
+	dryRun := flags["dry-run"] == "true"
+	if dryRun {
+		reqArgs["dry_run"] = true
+	} else {
+		fmt.Fprintf(c.stdout(), "Killing agent '%s' in repository '%s'...\n", agentName, repoName)
+	}
+
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
+	resp, err := client.Send(socket.Request{
+		Command: "kill_agent",
+		Args:    reqArgs,
+	})
+	if err != nil {
+		return errors.DaemonCommunicationFailed("killing agent", err)
+	}
+	if !resp.Success {
+		return errors.Wrap(errors.CategoryRuntime, "failed to kill agent", fmt.Errorf("%s", resp.Error))
+	}
+
+	if dryRun {
+		var result struct {
+			Actions []string `json:"actions"`
+		}
+		if data, err := json.Marshal(resp.Data); err == nil {
+			json.Unmarshal(data, &result)
+		}
+		fmt.Fprintf(c.stdout(), "Would kill agent '%s' in repository '%s':\n", agentName, repoName)
+		for _, action := range result.Actions {
+			fmt.Fprintf(c.stdout(), "  - %s\n", action)
+		}
+		fmt.Fprintln(c.stdout(), "\nRun without --dry-run to kill the agent.")
+		return nil
+	}
+
+	fmt.Fprintf(c.stdout(), "✓ Agent '%s' killed\n", agentName)
+	fmt.Fprintln(c.stdout(), "The daemon will clean up this agent's resources shortly.")
+	return nil
+}
+
 func (c *CLI) reviewPR(args []string) error {
 	if len(args) < 1 {
 		return errors.InvalidUsage("usage: multiclaude review <pr-url>")
@@ -4320,7 +5119,11 @@ func (c *CLI) reviewPR(args []string) error {
 	}
 
 	prNumber := parts[4]
-	fmt.Printf("Reviewing PR #%s\n", prNumber)
+	prNum, err := parsePRNumber(prNumber)
+	if err != nil {
+		return errors.InvalidPRURL()
+	}
+	fmt.Fprintf(c.stdout(), "Reviewing PR #%s\n", prNumber)
 
 	// Determine repository from flag or current directory
 	flags, _ := ParseFlags(args[1:])
@@ -4349,17 +5152,17 @@ func (c *CLI) reviewPR(args []string) error {
 		}
 	}
 
-	// Generate review agent name
-	reviewerName := fmt.Sprintf("review-%s", prNumber)
+	// Generate review agent name; it is unique, so re-reviews don't collide
+	reviewerName := agent.ReviewAgentName(prNum)
 
-	fmt.Printf("Creating review agent '%s' in repo '%s'\n", reviewerName, repoName)
+	fmt.Fprintf(c.stdout(), "Creating review agent '%s' in repo '%s'\n", reviewerName, repoName)
 
 	// Get repository path
 	repoPath := c.paths.RepoDir(repoName)
 
 	// Fetch the PR using GitHub's PR refs - this works for both same-repo and fork PRs
 	// The refs/pull/<number>/head ref always exists and points to the PR's head commit
-	fmt.Printf("Fetching PR #%s...\n", prNumber)
+	fmt.Fprintf(c.stdout(), "Fetching PR #%s...\n", prNumber)
 	prRef := fmt.Sprintf("refs/pull/%s/head", prNumber)
 	localRef := fmt.Sprintf("refs/multiclaude/pr-%s", prNumber)
 	cmd := exec.Command("git", "fetch", "origin", fmt.Sprintf("%s:%s", prRef, localRef))
@@ -4374,7 +5177,7 @@ func (c *CLI) reviewPR(args []string) error {
 	wtPath := c.paths.AgentWorktree(repoName, reviewerName)
 	reviewBranch := fmt.Sprintf("review/%s", reviewerName)
 
-	fmt.Printf("Creating worktree at: %s\n", wtPath)
+	fmt.Fprintf(c.stdout(), "Creating worktree at: %s\n", wtPath)
 	if err := wt.CreateNewBranch(wtPath, reviewBranch, localRef); err != nil {
 		return fmt.Errorf("failed to create worktree: %w", err)
 	}
@@ -4383,7 +5186,7 @@ func (c *CLI) reviewPR(args []string) error {
 	tmuxSession := sanitizeTmuxSessionName(repoName)
 
 	// Create tmux window for reviewer (detached so it doesn't switch focus)
-	fmt.Printf("Creating tmux window: %s\n", reviewerName)
+	fmt.Fprintf(c.stdout(), "Creating tmux window: %s\n", reviewerName)
 	cmd = exec.Command("tmux", "new-window", "-d", "-t", tmuxSession, "-n", reviewerName, "-c", wtPath)
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("failed to create tmux window: %w", err)
@@ -4403,7 +5206,7 @@ func (c *CLI) reviewPR(args []string) error {
 
 	// Copy hooks configuration if it exists
 	if err := hooks.CopyConfig(repoPath, wtPath); err != nil {
-		fmt.Printf("Warning: failed to copy hooks config: %v\n", err)
+		fmt.Fprintf(c.stdout(), "Warning: failed to copy hooks config: %v\n", err)
 	}
 
 	// Start Claude in reviewer window with initial task (skip in test mode)
@@ -4415,9 +5218,9 @@ func (c *CLI) reviewPR(args []string) error {
 			return fmt.Errorf("failed to resolve claude binary: %w", err)
 		}
 
-		fmt.Println("Starting Claude Code in reviewer window...")
+		fmt.Fprintln(c.stdout(), "Starting Claude Code in reviewer window...")
 		initialMessage := fmt.Sprintf("Review PR #%s: https://github.com/%s/%s/pull/%s", prNumber, parts[1], parts[2], prNumber)
-		pid, err := c.startClaudeInTmux(claudeBinary, tmuxSession, reviewerName, wtPath, reviewerSessionID, reviewerPromptFile, repoName, initialMessage)
+		pid, err := c.startClaudeInTmux(claudeBinary, tmuxSession, reviewerName, wtPath, reviewerSessionID, reviewerPromptFile, repoName, state.AgentTypeReview, initialMessage)
 		if err != nil {
 			return fmt.Errorf("failed to start reviewer Claude: %w", err)
 		}
@@ -4425,12 +5228,15 @@ func (c *CLI) reviewPR(args []string) error {
 
 		// Set up output capture for reviewer
 		if err := c.setupOutputCapture(tmuxSession, reviewerName, repoName, reviewerName, "review"); err != nil {
-			fmt.Printf("Warning: failed to setup output capture for reviewer: %v\n", err)
+			fmt.Fprintf(c.stdout(), "Warning: failed to setup output capture for reviewer: %v\n", err)
 		}
 	}
 
 	// Register reviewer with daemon
-	client := socket.NewClient(c.paths.DaemonSock)
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
 	resp, err := client.Send(socket.Request{
 		Command: "add_agent",
 		Args: map[string]interface{}{
@@ -4451,13 +5257,13 @@ func (c *CLI) reviewPR(args []string) error {
 		return fmt.Errorf("failed to register reviewer: %s", resp.Error)
 	}
 
-	fmt.Println()
-	fmt.Println("✓ Review agent created successfully!")
-	fmt.Printf("  Name: %s\n", reviewerName)
-	fmt.Printf("  Branch: %s\n", reviewBranch)
-	fmt.Printf("  Worktree: %s\n", wtPath)
-	fmt.Printf("\nAttach to reviewer: tmux select-window -t %s:%s\n", tmuxSession, reviewerName)
-	fmt.Printf("Or use: multiclaude attach %s\n", reviewerName)
+	fmt.Fprintln(c.stdout())
+	fmt.Fprintln(c.stdout(), "✓ Review agent created successfully!")
+	fmt.Fprintf(c.stdout(), "  Name: %s\n", reviewerName)
+	fmt.Fprintf(c.stdout(), "  Branch: %s\n", reviewBranch)
+	fmt.Fprintf(c.stdout(), "  Worktree: %s\n", wtPath)
+	fmt.Fprintf(c.stdout(), "\nAttach to reviewer: tmux select-window -t %s:%s\n", tmuxSession, reviewerName)
+	fmt.Fprintf(c.stdout(), "Or use: multiclaude attach %s\n", reviewerName)
 
 	return nil
 }
@@ -4465,18 +5271,22 @@ func (c *CLI) reviewPR(args []string) error {
 // Logs command implementations
 
 func (c *CLI) viewLogs(args []string) error {
-	if len(args) < 1 {
-		return fmt.Errorf("usage: multiclaude logs <agent> [--lines N] [--follow]")
+	flags, positional := ParseFlags(args)
+
+	// Accept either "logs <agent> [--repo <repo>]" or "logs <repo> <agent>"
+	var repoName, agentName string
+	switch len(positional) {
+	case 1:
+		agentName = positional[0]
+		repoName = flags["repo"]
+	case 2:
+		repoName, agentName = positional[0], positional[1]
+	default:
+		return fmt.Errorf("usage: multiclaude logs [<repo>] <agent> [--lines N] [-f|--follow]")
 	}
 
-	agentName := args[0]
-	flags, _ := ParseFlags(args[1:])
-
 	// Determine repository
-	var repoName string
-	if r, ok := flags["repo"]; ok {
-		repoName = r
-	} else {
+	if repoName == "" {
 		repos := c.getReposList()
 		if len(repos) == 0 {
 			return fmt.Errorf("no repositories tracked")
@@ -4488,26 +5298,23 @@ func (c *CLI) viewLogs(args []string) error {
 		}
 	}
 
-	// Determine if it's a worker or system agent by checking if it exists in workers dir
-	workerLogFile := c.paths.AgentLogFile(repoName, agentName, true)
-	systemLogFile := c.paths.AgentLogFile(repoName, agentName, false)
-
-	var logFile string
-	if _, err := os.Stat(workerLogFile); err == nil {
-		logFile = workerLogFile
-	} else if _, err := os.Stat(systemLogFile); err == nil {
-		logFile = systemLogFile
-	} else {
-		return fmt.Errorf("no log file found for agent %s in repo %s", agentName, repoName)
+	// Check for --follow flag
+	if flags["follow"] == "true" || flags["f"] == "true" {
+		lines := 10
+		if l, ok := flags["lines"]; ok {
+			n, err := strconv.Atoi(l)
+			if err != nil {
+				return errors.InvalidUsage(fmt.Sprintf("invalid --lines value %q", l))
+			}
+			lines = n
+		}
+		return c.followLogs(repoName, agentName, lines)
 	}
 
-	// Check for --follow flag
-	if _, ok := flags["follow"]; ok {
-		// Use tail -f
-		cmd := exec.Command("tail", "-f", logFile)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		return cmd.Run()
+	// Find the capture file (workers dir first, then system agents)
+	logFile, err := output.NewManager(c.paths.OutputDir).FindLogPath(repoName, agentName)
+	if err != nil {
+		return fmt.Errorf("no log file found for agent %s in repo %s", agentName, repoName)
 	}
 
 	// Determine number of lines
@@ -4518,11 +5325,48 @@ func (c *CLI) viewLogs(args []string) error {
 
 	// Use tail to get recent lines
 	cmd := exec.Command("tail", "-n", lines, logFile)
-	cmd.Stdout = os.Stdout
+	cmd.Stdout = c.stdout()
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
 }
 
+// followLogs streams an agent's output from the daemon until interrupted
+func (c *CLI) followLogs(repoName, agentName string, lines int) error {
+	c.streamOutput()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
+	resp, err := client.SendStream(ctx, socket.Request{
+		Command: "logs",
+		Args: map[string]interface{}{
+			"repo":   repoName,
+			"agent":  agentName,
+			"follow": true,
+			"lines":  lines,
+		},
+	}, func(msg socket.Response) error {
+		if line, ok := msg.Data.(string); ok {
+			fmt.Fprintln(c.stdout(), line)
+		}
+		return nil
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			// Interrupted by the user
+			return nil
+		}
+		return errors.DaemonCommunicationFailed("streaming logs", err)
+	}
+	if !resp.Success {
+		return errors.Wrap(errors.CategoryRuntime, "failed to stream logs", fmt.Errorf("%s", resp.Error))
+	}
+	return nil
+}
+
 func (c *CLI) listLogs(args []string) error {
 	flags, _ := ParseFlags(args)
 
@@ -4540,13 +5384,13 @@ func (c *CLI) listLogs(args []string) error {
 	// List logs for all repos
 	repos := c.getReposList()
 	if len(repos) == 0 {
-		fmt.Println("No repositories tracked")
+		fmt.Fprintln(c.stdout(), "No repositories tracked")
 		return nil
 	}
 
 	for _, repo := range repos {
 		if err := c.listLogsForRepo(repo); err != nil {
-			fmt.Printf("Warning: failed to list logs for %s: %v\n", repo, err)
+			fmt.Fprintf(c.stdout(), "Warning: failed to list logs for %s: %v\n", repo, err)
 		}
 	}
 	return nil
@@ -4557,11 +5401,11 @@ func (c *CLI) listLogsForRepo(repoName string) error {
 
 	// Check if directory exists
 	if _, err := os.Stat(repoOutputDir); os.IsNotExist(err) {
-		fmt.Printf("No logs for %s\n", repoName)
+		fmt.Fprintf(c.stdout(), "No logs for %s\n", repoName)
 		return nil
 	}
 
-	fmt.Printf("\n%s:\n", repoName)
+	fmt.Fprintf(c.stdout(), "\n%s:\n", repoName)
 
 	// List system agent logs
 	entries, err := os.ReadDir(repoOutputDir)
@@ -4577,9 +5421,9 @@ func (c *CLI) listLogsForRepo(repoName string) error {
 			info, _ := entry.Info()
 			agentName := strings.TrimSuffix(entry.Name(), ".log")
 			if info != nil {
-				fmt.Printf("  %s (%d bytes)\n", agentName, info.Size())
+				fmt.Fprintf(c.stdout(), "  %s (%d bytes)\n", agentName, info.Size())
 			} else {
-				fmt.Printf("  %s\n", agentName)
+				fmt.Fprintf(c.stdout(), "  %s\n", agentName)
 			}
 		}
 	}
@@ -4589,15 +5433,15 @@ func (c *CLI) listLogsForRepo(repoName string) error {
 	if _, err := os.Stat(workersDir); err == nil {
 		workerEntries, err := os.ReadDir(workersDir)
 		if err == nil && len(workerEntries) > 0 {
-			fmt.Println("  workers/")
+			fmt.Fprintln(c.stdout(), "  workers/")
 			for _, entry := range workerEntries {
 				if strings.HasSuffix(entry.Name(), ".log") {
 					info, _ := entry.Info()
 					workerName := strings.TrimSuffix(entry.Name(), ".log")
 					if info != nil {
-						fmt.Printf("    %s (%d bytes)\n", workerName, info.Size())
+						fmt.Fprintf(c.stdout(), "    %s (%d bytes)\n", workerName, info.Size())
 					} else {
-						fmt.Printf("    %s\n", workerName)
+						fmt.Fprintf(c.stdout(), "    %s\n", workerName)
 					}
 				}
 			}
@@ -4640,7 +5484,7 @@ func (c *CLI) searchLogs(args []string) error {
 	}
 
 	if len(searchPaths) == 0 {
-		fmt.Println("No log directories found")
+		fmt.Fprintln(c.stdout(), "No log directories found")
 		return nil
 	}
 
@@ -4649,13 +5493,13 @@ func (c *CLI) searchLogs(args []string) error {
 	grepArgs = append(grepArgs, searchPaths...)
 
 	cmd := exec.Command("grep", grepArgs...)
-	cmd.Stdout = os.Stdout
+	cmd.Stdout = c.stdout()
 	cmd.Stderr = os.Stderr
 
 	// Run grep (exit code 1 means no matches, which is fine)
 	err := cmd.Run()
 	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
-		fmt.Println("No matches found")
+		fmt.Fprintln(c.stdout(), "No matches found")
 		return nil
 	}
 	return err
@@ -4676,7 +5520,7 @@ func (c *CLI) cleanLogs(args []string) error {
 	}
 
 	cutoff := time.Now().Add(-duration)
-	fmt.Printf("Cleaning logs older than %s...\n", cutoff.Format(time.RFC3339))
+	fmt.Fprintf(c.stdout(), "Cleaning logs older than %s...\n", cutoff.Format(time.RFC3339))
 
 	var deletedCount, deletedBytes int64
 
@@ -4694,7 +5538,7 @@ func (c *CLI) cleanLogs(args []string) error {
 		if info.ModTime().Before(cutoff) {
 			deletedBytes += info.Size()
 			if err := os.Remove(path); err != nil {
-				fmt.Printf("Warning: failed to remove %s: %v\n", path, err)
+				fmt.Fprintf(c.stdout(), "Warning: failed to remove %s: %v\n", path, err)
 			} else {
 				deletedCount++
 			}
@@ -4706,7 +5550,7 @@ func (c *CLI) cleanLogs(args []string) error {
 		return fmt.Errorf("failed to walk output directory: %w", err)
 	}
 
-	fmt.Printf("Deleted %d files (%.2f MB)\n", deletedCount, float64(deletedBytes)/(1024*1024))
+	fmt.Fprintf(c.stdout(), "Deleted %d files (%.2f MB)\n", deletedCount, float64(deletedBytes)/(1024*1024))
 	return nil
 }
 
@@ -4737,37 +5581,46 @@ func parseDuration(s string) (time.Duration, error) {
 }
 
 func (c *CLI) attachAgent(args []string) error {
+	c.streamOutput()
+
 	flags, remainingArgs := ParseFlags(args)
 	readOnly := flags["read-only"] == "true" || flags["r"] == "true"
 
+	// "attach <repo> <agent>" names the repository explicitly
+	if len(remainingArgs) >= 2 {
+		flags["repo"] = remainingArgs[0]
+		remainingArgs = remainingArgs[1:]
+	}
+
 	// Determine repository
 	repoName, err := c.resolveRepo(flags)
 	if err != nil {
 		return errors.NotInRepo()
 	}
 
-	// Get agent info to find tmux session and window
-	client := socket.NewClient(c.paths.DaemonSock)
-	resp, err := client.Send(socket.Request{
-		Command: "list_agents",
-		Args: map[string]interface{}{
-			"repo": repoName,
-		},
-	})
-	if err != nil {
-		return fmt.Errorf("failed to get agent info: %w (is daemon running?)", err)
-	}
-	if !resp.Success {
-		return fmt.Errorf("failed to get agent info: %s", resp.Error)
-	}
-
-	agents, _ := resp.Data.([]interface{})
-
 	// Determine agent name - from args or interactive selection
 	var agentName string
 	if len(remainingArgs) > 0 {
 		agentName = remainingArgs[0]
 	} else {
+		client, err := c.socketClient()
+		if err != nil {
+			return err
+		}
+		resp, err := client.Send(socket.Request{
+			Command: "list_agents",
+			Args: map[string]interface{}{
+				"repo": repoName,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get agent info: %w (is daemon running?)", err)
+		}
+		if !resp.Success {
+			return fmt.Errorf("failed to get agent info: %s", resp.Error)
+		}
+		agents, _ := resp.Data.([]interface{})
+
 		// Interactive selection - all agent types
 		items := agentsToSelectableItems(agents, nil)
 		if len(items) == 0 {
@@ -4778,39 +5631,28 @@ func (c *CLI) attachAgent(args []string) error {
 			return err
 		}
 		if selected == "" {
-			fmt.Println("Cancelled")
+			fmt.Fprintln(c.stdout(), "Cancelled")
 			return nil
 		}
 		agentName = selected
 	}
 
-	// Find agent
-	var agentInfo map[string]interface{}
-	for _, agent := range agents {
-		if agentMap, ok := agent.(map[string]interface{}); ok {
-			if name, _ := agentMap["name"].(string); name == agentName {
-				agentInfo = agentMap
-				break
-			}
-		}
-	}
-
-	if agentInfo == nil {
-		return errors.AgentNotFound("agent", agentName, repoName)
+	target, err := c.resolveAttachTarget(context.Background(), repoName, agentName)
+	if err != nil {
+		return err
 	}
 
-	// Get tmux session and window
-	tmuxSession := sanitizeTmuxSessionName(repoName)
-	tmuxWindow := agentInfo["tmux_window"].(string)
-
-	// Attach to tmux
-	target := fmt.Sprintf("%s:%s", tmuxSession, tmuxWindow)
-
 	tmuxArgs := []string{"attach", "-t", target}
 	if readOnly {
 		tmuxArgs = append(tmuxArgs, "-r")
 	}
 
+	// Without a terminal tmux cannot attach, so show the command instead
+	if !stdinIsTerminal() {
+		fmt.Fprintf(c.stdout(), "tmux %s\n", strings.Join(tmuxArgs, " "))
+		return nil
+	}
+
 	cmd := exec.Command("tmux", tmuxArgs...)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
@@ -4819,6 +5661,50 @@ func (c *CLI) attachAgent(args []string) error {
 	return cmd.Run()
 }
 
+// resolveAttachTarget returns the "session:window" tmux target for an agent
+// as recorded in state, checking that the window still exists
+func (c *CLI) resolveAttachTarget(ctx context.Context, repoName, agentName string) (string, error) {
+	st, err := state.Load(c.paths.StateFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to load state: %w", err)
+	}
+	repo, exists := st.GetRepo(repoName)
+	if !exists {
+		return "", errors.RepoNotFound(repoName)
+	}
+	agent, exists := repo.Agents[agentName]
+	if !exists {
+		return "", errors.AgentNotFound("agent", agentName, repoName)
+	}
+
+	target := fmt.Sprintf("%s:%s", repo.TmuxSession, agent.TmuxWindow)
+	tmuxClient := tmux.NewClient()
+	hasSession, err := tmuxClient.HasSession(ctx, repo.TmuxSession)
+	if err != nil {
+		return "", errors.TmuxOperationFailed("has-session", err)
+	}
+	if !hasSession {
+		return "", errors.AgentWindowGone(agentName, target)
+	}
+	hasWindow, err := tmuxClient.HasWindow(ctx, repo.TmuxSession, agent.TmuxWindow)
+	if err != nil {
+		return "", errors.TmuxOperationFailed("list-windows", err)
+	}
+	if !hasWindow {
+		return "", errors.AgentWindowGone(agentName, target)
+	}
+	return target, nil
+}
+
+// stdinIsTerminal reports whether standard input is an interactive terminal
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
 func (c *CLI) cleanup(args []string) error {
 	flags, _ := ParseFlags(args)
 	dryRun := flags["dry-run"] == "true"
@@ -4826,9 +5712,9 @@ func (c *CLI) cleanup(args []string) error {
 	cleanMerged := flags["merged"] == "true"
 
 	if dryRun {
-		fmt.Println("Running cleanup in dry-run mode (no changes will be made)...")
+		fmt.Fprintln(c.stdout(), "Running cleanup in dry-run mode (no changes will be made)...")
 	} else {
-		fmt.Println("Running cleanup...")
+		fmt.Fprintln(c.stdout(), "Running cleanup...")
 	}
 
 	// If --merged flag is set, run merged branch cleanup
@@ -4836,21 +5722,32 @@ func (c *CLI) cleanup(args []string) error {
 		return c.cleanupMergedBranches(dryRun, verbose)
 	}
 
-	client := socket.NewClient(c.paths.DaemonSock)
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
 
 	// Check if daemon is running
-	_, err := client.Send(socket.Request{Command: "ping"})
+	_, err = client.Send(socket.Request{Command: "ping"})
 	if err != nil {
-		fmt.Println("Daemon is not running. Running local cleanup...")
+		fmt.Fprintln(c.stdout(), "Daemon is not running. Running local cleanup...")
 		return c.localCleanup(dryRun, verbose)
 	}
 
 	// Trigger daemon cleanup
+	cleanupArgs := map[string]interface{}{
+		"dry_run": dryRun,
+	}
+	if v, ok := flags["concurrency"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return errors.InvalidArgument("--concurrency", v, "a positive integer")
+		}
+		cleanupArgs["concurrency"] = n
+	}
 	resp, err := client.Send(socket.Request{
 		Command: "trigger_cleanup",
-		Args: map[string]interface{}{
-			"dry_run": dryRun,
-		},
+		Args:    cleanupArgs,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to trigger cleanup: %w", err)
@@ -4859,13 +5756,65 @@ func (c *CLI) cleanup(args []string) error {
 		return fmt.Errorf("cleanup failed: %s", resp.Error)
 	}
 
-	fmt.Println("Cleanup completed")
+	var report cleanup.Report
+	if data, err := json.Marshal(resp.Data); err == nil {
+		json.Unmarshal(data, &report)
+	}
+	if c.jsonOutput {
+		c.setJSONResult(report)
+		return nil
+	}
+	printCleanupReport(c.stdout(), &report, verbose)
 	return nil
 }
 
+// printCleanupReport writes a summary of a daemon cleanup run
+func printCleanupReport(w io.Writer, report *cleanup.Report, verbose bool) {
+	verb := "Removed"
+	if report.DryRun {
+		verb = "Would remove"
+	}
+
+	sections := []struct {
+		label string
+		items []string
+	}{
+		{"dead agent(s)", report.DeadAgents},
+		{"orphaned tmux session(s)", report.OrphanedSessions},
+		{"orphaned worktree(s)", report.OrphanedWorktrees},
+		{"stale worktree entry(ies)", report.StaleWorktrees},
+	}
+	for _, section := range sections {
+		if len(section.items) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%s %d %s\n", verb, len(section.items), section.label)
+		if verbose || report.DryRun {
+			for _, item := range section.items {
+				fmt.Fprintf(w, "  - %s\n", item)
+			}
+		}
+	}
+	if report.AckedMessages > 0 {
+		fmt.Fprintf(w, "%s %d acked message(s)\n", verb, report.AckedMessages)
+	}
+
+	for _, e := range report.Errors {
+		fmt.Fprintf(w, "Warning: %s\n", e)
+	}
+
+	if report.Total() == 0 {
+		fmt.Fprintln(w, "Nothing to clean up")
+	} else if report.DryRun {
+		fmt.Fprintln(w, "\nRun without --dry-run to remove these resources.")
+	} else {
+		fmt.Fprintln(w, "Cleanup completed")
+	}
+}
+
 // cleanupMergedBranches cleans up branches that have been merged upstream
 func (c *CLI) cleanupMergedBranches(dryRun bool, verbose bool) error {
-	fmt.Println("\nChecking for branches merged upstream...")
+	fmt.Fprintln(c.stdout(), "\nChecking for branches merged upstream...")
 
 	// Load state to get repository list
 	st, err := c.loadState()
@@ -4879,7 +5828,7 @@ func (c *CLI) cleanupMergedBranches(dryRun bool, verbose bool) error {
 	// Process each repository
 	repos := st.ListRepos()
 	if len(repos) == 0 {
-		fmt.Println("No repositories tracked. Nothing to clean up.")
+		fmt.Fprintln(c.stdout(), "No repositories tracked. Nothing to clean up.")
 		return nil
 	}
 
@@ -4889,13 +5838,13 @@ func (c *CLI) cleanupMergedBranches(dryRun bool, verbose bool) error {
 		// Check if repo exists
 		if _, err := os.Stat(repoPath); os.IsNotExist(err) {
 			if verbose {
-				fmt.Printf("\nRepository %s: path does not exist, skipping\n", repoName)
+				fmt.Fprintf(c.stdout(), "\nRepository %s: path does not exist, skipping\n", repoName)
 			}
 			continue
 		}
 
 		if verbose {
-			fmt.Printf("\nRepository: %s\n", repoName)
+			fmt.Fprintf(c.stdout(), "\nRepository: %s\n", repoName)
 		}
 
 		wt := worktree.NewManager(repoPath)
@@ -4905,14 +5854,14 @@ func (c *CLI) cleanupMergedBranches(dryRun bool, verbose bool) error {
 			mergedBranches, err := wt.FindMergedUpstreamBranches(prefix)
 			if err != nil {
 				if verbose {
-					fmt.Printf("  Warning: failed to find merged branches with prefix %s: %v\n", prefix, err)
+					fmt.Fprintf(c.stdout(), "  Warning: failed to find merged branches with prefix %s: %v\n", prefix, err)
 				}
 				continue
 			}
 
 			if len(mergedBranches) == 0 {
 				if verbose {
-					fmt.Printf("  No merged branches with prefix %s\n", prefix)
+					fmt.Fprintf(c.stdout(), "  No merged branches with prefix %s\n", prefix)
 				}
 				continue
 			}
@@ -4921,7 +5870,7 @@ func (c *CLI) cleanupMergedBranches(dryRun bool, verbose bool) error {
 			worktrees, err := wt.List()
 			if err != nil {
 				if verbose {
-					fmt.Printf("  Warning: failed to list worktrees: %v\n", err)
+					fmt.Fprintf(c.stdout(), "  Warning: failed to list worktrees: %v\n", err)
 				}
 				continue
 			}
@@ -4933,34 +5882,34 @@ func (c *CLI) cleanupMergedBranches(dryRun bool, verbose bool) error {
 				}
 			}
 
-			fmt.Printf("\nMerged branches with prefix %s for %s:\n", prefix, repoName)
+			fmt.Fprintf(c.stdout(), "\nMerged branches with prefix %s for %s:\n", prefix, repoName)
 			for _, branch := range mergedBranches {
 				if activeBranches[branch] {
 					if verbose {
-						fmt.Printf("  Skipping %s (still checked out)\n", branch)
+						fmt.Fprintf(c.stdout(), "  Skipping %s (still checked out)\n", branch)
 					}
 					continue
 				}
 
 				totalFound++
 				if dryRun {
-					fmt.Printf("  Would delete: %s\n", branch)
+					fmt.Fprintf(c.stdout(), "  Would delete: %s\n", branch)
 				} else {
 					// Delete local branch
 					if err := wt.DeleteBranch(branch); err != nil {
-						fmt.Printf("  Failed to delete %s: %v\n", branch, err)
+						fmt.Fprintf(c.stdout(), "  Failed to delete %s: %v\n", branch, err)
 						continue
 					}
-					fmt.Printf("  Deleted: %s\n", branch)
+					fmt.Fprintf(c.stdout(), "  Deleted: %s\n", branch)
 					totalDeleted++
 
 					// Try to delete remote branch from origin (the fork)
 					if err := wt.DeleteRemoteBranch("origin", branch); err != nil {
 						if verbose {
-							fmt.Printf("    (remote branch deletion failed: %v)\n", err)
+							fmt.Fprintf(c.stdout(), "    (remote branch deletion failed: %v)\n", err)
 						}
 					} else if verbose {
-						fmt.Printf("    (also deleted from origin)\n")
+						fmt.Fprintf(c.stdout(), "    (also deleted from origin)\n")
 					}
 				}
 			}
@@ -4969,15 +5918,15 @@ func (c *CLI) cleanupMergedBranches(dryRun bool, verbose bool) error {
 
 	if dryRun {
 		if totalFound > 0 {
-			fmt.Printf("\nFound %d merged branch(es) that would be deleted\n", totalFound)
+			fmt.Fprintf(c.stdout(), "\nFound %d merged branch(es) that would be deleted\n", totalFound)
 		} else {
-			fmt.Println("\nNo merged branches found to clean up")
+			fmt.Fprintln(c.stdout(), "\nNo merged branches found to clean up")
 		}
 	} else {
 		if totalDeleted > 0 {
-			fmt.Printf("\nDeleted %d merged branch(es)\n", totalDeleted)
+			fmt.Fprintf(c.stdout(), "\nDeleted %d merged branch(es)\n", totalDeleted)
 		} else {
-			fmt.Println("\nNo merged branches found to clean up")
+			fmt.Fprintln(c.stdout(), "\nNo merged branches found to clean up")
 		}
 	}
 
@@ -4988,7 +5937,7 @@ func (c *CLI) cleanupMergedBranches(dryRun bool, verbose bool) error {
 func (c *CLI) cleanupOrphanedBranchesWithPrefix(wt *worktree.Manager, branchPrefix, repoName string, dryRun, verbose bool) (removed int, issues int) {
 	orphanedBranches, err := wt.FindOrphanedBranches(branchPrefix)
 	if err != nil && verbose {
-		fmt.Printf("  Warning: failed to find orphaned %s branches: %v\n", branchPrefix, err)
+		fmt.Fprintf(c.stdout(), "  Warning: failed to find orphaned %s branches: %v\n", branchPrefix, err)
 		return 0, 0
 	}
 
@@ -4998,7 +5947,7 @@ func (c *CLI) cleanupOrphanedBranchesWithPrefix(wt *worktree.Manager, branchPref
 			if branchPrefix == "workspace/" {
 				branchType = "workspace"
 			}
-			fmt.Printf("  No orphaned %s branches\n", branchType)
+			fmt.Fprintf(c.stdout(), "  No orphaned %s branches\n", branchType)
 		}
 		return 0, 0
 	}
@@ -5007,17 +5956,17 @@ func (c *CLI) cleanupOrphanedBranchesWithPrefix(wt *worktree.Manager, branchPref
 	if branchPrefix == "workspace/" {
 		branchType = "workspace"
 	}
-	fmt.Printf("\nOrphaned %s branches (%d) for %s:\n", branchType, len(orphanedBranches), repoName)
+	fmt.Fprintf(c.stdout(), "\nOrphaned %s branches (%d) for %s:\n", branchType, len(orphanedBranches), repoName)
 
 	for _, branch := range orphanedBranches {
 		if dryRun {
-			fmt.Printf("  Would delete branch: %s\n", branch)
+			fmt.Fprintf(c.stdout(), "  Would delete branch: %s\n", branch)
 			issues++
 		} else {
 			if err := wt.DeleteBranch(branch); err != nil {
-				fmt.Printf("  Failed to delete %s: %v\n", branch, err)
+				fmt.Fprintf(c.stdout(), "  Failed to delete %s: %v\n", branch, err)
 			} else {
-				fmt.Printf("  Deleted branch: %s\n", branch)
+				fmt.Fprintf(c.stdout(), "  Deleted branch: %s\n", branch)
 				removed++
 			}
 		}
@@ -5028,7 +5977,7 @@ func (c *CLI) cleanupOrphanedBranchesWithPrefix(wt *worktree.Manager, branchPref
 
 func (c *CLI) localCleanup(dryRun bool, verbose bool) error {
 	// Clean up orphaned worktrees, tmux sessions, and other resources
-	fmt.Println("\nChecking for orphaned resources...")
+	fmt.Fprintln(c.stdout(), "\nChecking for orphaned resources...")
 
 	totalRemoved := 0
 	totalIssues := 0
@@ -5036,44 +5985,31 @@ func (c *CLI) localCleanup(dryRun bool, verbose bool) error {
 	// Load state for reference
 	st, err := state.Load(c.paths.StateFile)
 	if err != nil {
-		fmt.Printf("Warning: could not load state file: %v\n", err)
+		fmt.Fprintf(c.stdout(), "Warning: could not load state file: %v\n", err)
 		st = state.New(c.paths.StateFile)
 	}
 
-	// Check for orphaned tmux sessions (mc-* sessions not in state)
+	// Check for orphaned tmux sessions (this root's sessions not in state)
 	tmuxClient := tmux.NewClient()
 	if tmuxClient.IsTmuxAvailable() {
-		sessions, err := tmuxClient.ListSessions(context.Background())
+		orphanedSessions, err := cleanup.New(st, tmuxClient, c.paths).OrphanedSessions(context.Background())
 		if err == nil {
-			repos := st.ListRepos()
-			validSessions := make(map[string]bool)
-			for _, repo := range repos {
-				validSessions[fmt.Sprintf("mc-%s", repo)] = true
-			}
-
-			orphanedSessions := []string{}
-			for _, session := range sessions {
-				if strings.HasPrefix(session, "mc-") && !validSessions[session] {
-					orphanedSessions = append(orphanedSessions, session)
-				}
-			}
-
 			if len(orphanedSessions) > 0 {
-				fmt.Printf("\nOrphaned tmux sessions (%d):\n", len(orphanedSessions))
+				fmt.Fprintf(c.stdout(), "\nOrphaned tmux sessions (%d):\n", len(orphanedSessions))
 				for _, session := range orphanedSessions {
 					if dryRun {
-						fmt.Printf("  Would kill: %s\n", session)
+						fmt.Fprintf(c.stdout(), "  Would kill: %s\n", session)
 					} else {
 						if err := tmuxClient.KillSession(context.Background(), session); err != nil {
-							fmt.Printf("  Failed to kill %s: %v\n", session, err)
+							fmt.Fprintf(c.stdout(), "  Failed to kill %s: %v\n", session, err)
 						} else {
-							fmt.Printf("  Killed: %s\n", session)
+							fmt.Fprintf(c.stdout(), "  Killed: %s\n", session)
 							totalRemoved++
 						}
 					}
 				}
 			} else if verbose {
-				fmt.Println("\nNo orphaned tmux sessions found")
+				fmt.Fprintln(c.stdout(), "\nNo orphaned tmux sessions found")
 			}
 		}
 	}
@@ -5081,7 +6017,7 @@ func (c *CLI) localCleanup(dryRun bool, verbose bool) error {
 	// Check for orphaned worktree directories (in wts/ but not in any repo's git worktrees)
 	entries, err := os.ReadDir(c.paths.WorktreesDir)
 	if err != nil && !os.IsNotExist(err) {
-		fmt.Printf("Warning: failed to read worktrees directory: %v\n", err)
+		fmt.Fprintf(c.stdout(), "Warning: failed to read worktrees directory: %v\n", err)
 	} else if err == nil {
 		for _, entry := range entries {
 			if !entry.IsDir() {
@@ -5094,12 +6030,12 @@ func (c *CLI) localCleanup(dryRun bool, verbose bool) error {
 
 			// Check if the repo still exists
 			if _, err := os.Stat(repoPath); os.IsNotExist(err) {
-				fmt.Printf("\nOrphaned worktree directory (repo missing): %s\n", wtRootDir)
+				fmt.Fprintf(c.stdout(), "\nOrphaned worktree directory (repo missing): %s\n", wtRootDir)
 				if !dryRun {
 					if err := os.RemoveAll(wtRootDir); err != nil {
-						fmt.Printf("  Failed to remove: %v\n", err)
+						fmt.Fprintf(c.stdout(), "  Failed to remove: %v\n", err)
 					} else {
-						fmt.Printf("  Removed\n")
+						fmt.Fprintf(c.stdout(), "  Removed\n")
 						totalRemoved++
 					}
 				}
@@ -5107,7 +6043,7 @@ func (c *CLI) localCleanup(dryRun bool, verbose bool) error {
 			}
 
 			if verbose {
-				fmt.Printf("\nRepository: %s\n", repoName)
+				fmt.Fprintf(c.stdout(), "\nRepository: %s\n", repoName)
 			}
 
 			wt := worktree.NewManager(repoPath)
@@ -5116,14 +6052,14 @@ func (c *CLI) localCleanup(dryRun bool, verbose bool) error {
 			if !dryRun {
 				removed, err := worktree.CleanupOrphaned(wtRootDir, wt)
 				if err != nil {
-					fmt.Printf("  Warning: failed to cleanup worktrees: %v\n", err)
+					fmt.Fprintf(c.stdout(), "  Warning: failed to cleanup worktrees: %v\n", err)
 				} else if len(removed) > 0 {
 					for _, path := range removed {
-						fmt.Printf("  Removed: %s\n", path)
+						fmt.Fprintf(c.stdout(), "  Removed: %s\n", path)
 					}
 					totalRemoved += len(removed)
 				} else if verbose {
-					fmt.Println("  No orphaned worktrees")
+					fmt.Fprintln(c.stdout(), "  No orphaned worktrees")
 				}
 			} else {
 				// Dry run: just check what would be removed
@@ -5150,7 +6086,7 @@ func (c *CLI) localCleanup(dryRun bool, verbose bool) error {
 						evalPath = absPath
 					}
 					if !gitPaths[evalPath] {
-						fmt.Printf("  Would remove: %s\n", path)
+						fmt.Fprintf(c.stdout(), "  Would remove: %s\n", path)
 						totalIssues++
 					}
 				}
@@ -5159,7 +6095,7 @@ func (c *CLI) localCleanup(dryRun bool, verbose bool) error {
 			// Prune git worktree references
 			if !dryRun {
 				if err := wt.Prune(); err != nil && verbose {
-					fmt.Printf("  Warning: failed to prune worktrees: %v\n", err)
+					fmt.Fprintf(c.stdout(), "  Warning: failed to prune worktrees: %v\n", err)
 				}
 			}
 
@@ -5177,7 +6113,7 @@ func (c *CLI) localCleanup(dryRun bool, verbose bool) error {
 	// Check for orphaned message directories
 	msgEntries, err := os.ReadDir(c.paths.MessagesDir)
 	if err != nil && !os.IsNotExist(err) {
-		fmt.Printf("Warning: failed to read messages directory: %v\n", err)
+		fmt.Fprintf(c.stdout(), "Warning: failed to read messages directory: %v\n", err)
 	} else if err == nil {
 		for _, entry := range msgEntries {
 			if !entry.IsDir() {
@@ -5192,9 +6128,9 @@ func (c *CLI) localCleanup(dryRun bool, verbose bool) error {
 			if !dryRun {
 				count, err := msgMgr.CleanupOrphaned(repoName, validAgents)
 				if err != nil && verbose {
-					fmt.Printf("Warning: failed to cleanup messages for %s: %v\n", repoName, err)
+					fmt.Fprintf(c.stdout(), "Warning: failed to cleanup messages for %s: %v\n", repoName, err)
 				} else if count > 0 {
-					fmt.Printf("Cleaned up %d orphaned message dir(s) for %s\n", count, repoName)
+					fmt.Fprintf(c.stdout(), "Cleaned up %d orphaned message dir(s) for %s\n", count, repoName)
 					totalRemoved += count
 				}
 			} else {
@@ -5207,7 +6143,7 @@ func (c *CLI) localCleanup(dryRun bool, verbose bool) error {
 				}
 				for _, ae := range agentEntries {
 					if ae.IsDir() && !validAgentMap[ae.Name()] {
-						fmt.Printf("Would remove orphaned message dir: %s/%s\n", repoName, ae.Name())
+						fmt.Fprintf(c.stdout(), "Would remove orphaned message dir: %s/%s\n", repoName, ae.Name())
 						totalIssues++
 					}
 				}
@@ -5221,58 +6157,77 @@ func (c *CLI) localCleanup(dryRun bool, verbose bool) error {
 		// Daemon not running, check for stale files
 		if _, err := os.Stat(c.paths.DaemonPID); err == nil {
 			if dryRun {
-				fmt.Printf("\nWould remove stale PID file: %s\n", c.paths.DaemonPID)
+				fmt.Fprintf(c.stdout(), "\nWould remove stale PID file: %s\n", c.paths.DaemonPID)
 				totalIssues++
 			} else {
 				if err := os.Remove(c.paths.DaemonPID); err == nil {
-					fmt.Printf("Removed stale PID file: %s\n", c.paths.DaemonPID)
+					fmt.Fprintf(c.stdout(), "Removed stale PID file: %s\n", c.paths.DaemonPID)
 					totalRemoved++
 				}
 			}
 		}
 		if _, err := os.Stat(c.paths.DaemonSock); err == nil {
 			if dryRun {
-				fmt.Printf("Would remove stale socket file: %s\n", c.paths.DaemonSock)
+				fmt.Fprintf(c.stdout(), "Would remove stale socket file: %s\n", c.paths.DaemonSock)
 				totalIssues++
 			} else {
 				if err := os.Remove(c.paths.DaemonSock); err == nil {
-					fmt.Printf("Removed stale socket file: %s\n", c.paths.DaemonSock)
+					fmt.Fprintf(c.stdout(), "Removed stale socket file: %s\n", c.paths.DaemonSock)
 					totalRemoved++
 				}
 			}
 		}
 	}
 
-	fmt.Println()
+	fmt.Fprintln(c.stdout())
 	if dryRun {
 		if totalIssues > 0 {
-			fmt.Printf("✓ Dry run completed: would fix %d issue(s)\n", totalIssues)
+			fmt.Fprintf(c.stdout(), "✓ Dry run completed: would fix %d issue(s)\n", totalIssues)
 		} else {
-			fmt.Println("✓ Dry run completed: no issues found")
+			fmt.Fprintln(c.stdout(), "✓ Dry run completed: no issues found")
 		}
 	} else {
 		if totalRemoved > 0 {
-			fmt.Printf("✓ Cleanup completed: removed %d item(s)\n", totalRemoved)
+			fmt.Fprintf(c.stdout(), "✓ Cleanup completed: removed %d item(s)\n", totalRemoved)
 		} else {
-			fmt.Println("✓ Cleanup completed: no orphaned resources found")
+			fmt.Fprintln(c.stdout(), "✓ Cleanup completed: no orphaned resources found")
 		}
 	}
 
 	return nil
 }
 
+// taskReport prints every repository's agents with their types, tasks, and
+// status. It reads the state file directly, so it works without the daemon.
+func (c *CLI) taskReport(args []string) error {
+	st, err := c.loadState()
+	if err != nil {
+		return err
+	}
+
+	r := report.Build(st)
+	if c.jsonOutput {
+		c.setJSONResult(r)
+		return nil
+	}
+	return r.WriteText(c.stdout())
+}
+
 func (c *CLI) repair(args []string) error {
 	flags, _ := ParseFlags(args)
 	verbose := flags["verbose"] == "true" || flags["v"] == "true"
 
-	fmt.Println("Repairing state...")
+	fmt.Fprintln(c.stdout(), "Repairing state...")
 
 	// Check if daemon is running
-	client := socket.NewClient(c.paths.DaemonSock)
-	_, err := client.Send(socket.Request{Command: "ping"})
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
+	_, err = client.Send(socket.Request{Command: "ping"})
 	if err != nil {
 		// Daemon not running - do local repair
-		fmt.Println("Daemon is not running. Performing local repair...")
+		fmt.Fprintln(c.stdout(), "Daemon is not running. Performing local repair...")
 		return c.localRepair(verbose)
 	}
 
@@ -5287,29 +6242,108 @@ func (c *CLI) repair(args []string) error {
 		return fmt.Errorf("repair failed: %s", resp.Error)
 	}
 
-	fmt.Println("✓ State repaired successfully")
+	fmt.Fprintln(c.stdout(), "✓ State repaired successfully")
 	if data, ok := resp.Data.(map[string]interface{}); ok {
 		if removed, ok := data["agents_removed"].(float64); ok && removed > 0 {
-			fmt.Printf("  Removed %d dead agent(s)\n", int(removed))
+			fmt.Fprintf(c.stdout(), "  Removed %d dead agent(s)\n", int(removed))
 		}
 		if fixed, ok := data["issues_fixed"].(float64); ok && fixed > 0 {
-			fmt.Printf("  Fixed %d issue(s)\n", int(fixed))
+			fmt.Fprintf(c.stdout(), "  Fixed %d issue(s)\n", int(fixed))
 		}
 	}
 
 	return nil
 }
 
+// reconcile asks the daemon to converge state with running agents and
+// prints the changes
+func (c *CLI) reconcile(args []string) error {
+	flags, _ := ParseFlags(args)
+	dryRun := flags["dry-run"] == "true"
+
+	resp, err := c.sendDaemonRequest("reconcile", map[string]interface{}{
+		"dry_run": dryRun,
+	})
+	if err != nil {
+		return err
+	}
+
+	var report reconcile.Report
+	if data, err := json.Marshal(resp.Data); err == nil {
+		json.Unmarshal(data, &report)
+	}
+	if c.jsonOutput {
+		c.setJSONResult(report)
+		return nil
+	}
+
+	verb := "Changed"
+	if report.DryRun {
+		verb = "Would change"
+	}
+	fmt.Fprintf(c.stdout(), "Checked %d agent(s)\n", report.Checked)
+	if len(report.Changes) > 0 {
+		fmt.Fprintf(c.stdout(), "%s %d agent(s):\n", verb, len(report.Changes))
+		for _, change := range report.Changes {
+			fmt.Fprintf(c.stdout(), "  - %s\n", change)
+		}
+	}
+	for _, e := range report.Errors {
+		fmt.Fprintf(c.stdout(), "Warning: %s\n", e)
+	}
+
+	if len(report.Changes) == 0 {
+		fmt.Fprintln(c.stdout(), "State matches reality")
+	} else if report.DryRun {
+		fmt.Fprintln(c.stdout(), "\nRun without --dry-run to apply these changes.")
+	}
+	return nil
+}
+
+// selfTest asks the daemon to run a throwaway agent through its life and
+// prints each step
+func (c *CLI) selfTest(args []string) error {
+	resp, err := c.sendDaemonRequest("selftest", nil)
+	if err != nil {
+		return err
+	}
+
+	var report selftest.Report
+	if data, err := json.Marshal(resp.Data); err == nil {
+		json.Unmarshal(data, &report)
+	}
+	if c.jsonOutput {
+		c.setJSONResult(report)
+		return nil
+	}
+
+	for _, step := range report.Steps {
+		if step.OK {
+			fmt.Fprintf(c.stdout(), "✓ %s\n", step.Name)
+		} else {
+			fmt.Fprintf(c.stdout(), "✗ %s: %s\n", step.Name, step.Error)
+		}
+	}
+	if !report.OK {
+		return fmt.Errorf("self-test failed")
+	}
+	fmt.Fprintln(c.stdout(), "\nSelf-test passed")
+	return nil
+}
+
 // refresh triggers an immediate worktree sync for all agents
 func (c *CLI) refresh(args []string) error {
 	// Connect to daemon
-	client := socket.NewClient(c.paths.DaemonSock)
-	_, err := client.Send(socket.Request{Command: "ping"})
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
+	_, err = client.Send(socket.Request{Command: "ping"})
 	if err != nil {
 		return errors.DaemonNotRunning()
 	}
 
-	fmt.Println("Triggering worktree refresh...")
+	fmt.Fprintln(c.stdout(), "Triggering worktree refresh...")
 
 	resp, err := client.Send(socket.Request{
 		Command: "trigger_refresh",
@@ -5321,9 +6355,9 @@ func (c *CLI) refresh(args []string) error {
 		return fmt.Errorf("refresh failed: %s", resp.Error)
 	}
 
-	fmt.Println("✓ Worktree refresh triggered")
-	fmt.Println("  Agent worktrees will be synced with main branch in the background.")
-	fmt.Println("  Agents will receive a notification when their worktree is refreshed.")
+	fmt.Fprintln(c.stdout(), "✓ Worktree refresh triggered")
+	fmt.Fprintln(c.stdout(), "  Agent worktrees will be synced with main branch in the background.")
+	fmt.Fprintln(c.stdout(), "  Agents will receive a notification when their worktree is refreshed.")
 
 	return nil
 }
@@ -5343,47 +6377,37 @@ func (c *CLI) localRepair(verbose bool) error {
 	// Track orphaned tmux sessions
 	orphanedSessions := []string{}
 
-	// Get all tmux sessions and find orphaned ones
-	sessions, err := tmuxClient.ListSessions(context.Background())
-	if err == nil {
-		repos := st.ListRepos()
-		validSessions := make(map[string]bool)
-		for _, repo := range repos {
-			validSessions[fmt.Sprintf("mc-%s", repo)] = true
-		}
-		for _, session := range sessions {
-			if strings.HasPrefix(session, "mc-") && !validSessions[session] {
-				orphanedSessions = append(orphanedSessions, session)
-			}
-		}
+	// Get this root's tmux sessions that no repository uses
+	if sessions, err := cleanup.New(st, tmuxClient, c.paths).OrphanedSessions(context.Background()); err == nil {
+		orphanedSessions = append(orphanedSessions, sessions...)
 	}
 
-	// Check each repo and its agents
+	// Check each repo and its agents, collecting the dead ones so they are
+	// removed from the latest state in one locked update
+	var deadAgents []state.AgentRef
 	repos := st.GetAllRepos()
 	for repoName, repo := range repos {
 		if verbose {
-			fmt.Printf("\nChecking repository: %s\n", repoName)
+			fmt.Fprintf(c.stdout(), "\nChecking repository: %s\n", repoName)
 		}
 
 		// Check if tmux session exists
 		hasSession, err := tmuxClient.HasSession(context.Background(), repo.TmuxSession)
 		if err != nil && verbose {
-			fmt.Printf("  Warning: failed to check session %s: %v\n", repo.TmuxSession, err)
+			fmt.Fprintf(c.stdout(), "  Warning: failed to check session %s: %v\n", repo.TmuxSession, err)
 			continue
 		}
 
 		if !hasSession {
 			if verbose {
-				fmt.Printf("  Tmux session %s not found\n", repo.TmuxSession)
+				fmt.Fprintf(c.stdout(), "  Tmux session %s not found\n", repo.TmuxSession)
 			}
 			// Remove all agents for this repo
 			for agentName := range repo.Agents {
 				if verbose {
-					fmt.Printf("  Removing agent %s (session gone)\n", agentName)
-				}
-				if err := st.RemoveAgent(repoName, agentName); err == nil {
-					agentsRemoved++
+					fmt.Fprintf(c.stdout(), "  Removing agent %s (session gone)\n", agentName)
 				}
+				deadAgents = append(deadAgents, state.AgentRef{Repo: repoName, Name: agentName})
 			}
 			issuesFixed++
 			continue
@@ -5395,12 +6419,10 @@ func (c *CLI) localRepair(verbose bool) error {
 			hasWindow, _ := tmuxClient.HasWindow(context.Background(), repo.TmuxSession, agent.TmuxWindow)
 			if !hasWindow {
 				if verbose {
-					fmt.Printf("  Removing agent %s (window %s not found)\n", agentName, agent.TmuxWindow)
-				}
-				if err := st.RemoveAgent(repoName, agentName); err == nil {
-					agentsRemoved++
-					issuesFixed++
+					fmt.Fprintf(c.stdout(), "  Removing agent %s (window %s not found)\n", agentName, agent.TmuxWindow)
 				}
+				deadAgents = append(deadAgents, state.AgentRef{Repo: repoName, Name: agentName})
+				issuesFixed++
 				continue
 			}
 
@@ -5408,18 +6430,33 @@ func (c *CLI) localRepair(verbose bool) error {
 			if agent.Type == state.AgentTypeWorker && agent.WorktreePath != "" {
 				if _, err := os.Stat(agent.WorktreePath); os.IsNotExist(err) {
 					if verbose {
-						fmt.Printf("  Warning: worktree missing for %s: %s\n", agentName, agent.WorktreePath)
+						fmt.Fprintf(c.stdout(), "  Warning: worktree missing for %s: %s\n", agentName, agent.WorktreePath)
 					}
 					// Don't remove - window exists, user may have manually deleted worktree
 				}
 			}
 
 			if verbose {
-				fmt.Printf("  Agent %s: OK\n", agentName)
+				fmt.Fprintf(c.stdout(), "  Agent %s: OK\n", agentName)
 			}
 		}
 	}
 
+	if len(deadAgents) > 0 {
+		err := st.LockedUpdate(func(st *state.State) error {
+			for _, ref := range deadAgents {
+				// The daemon or another repair may have removed it already
+				if err := st.RemoveAgent(ref.Repo, ref.Name); err == nil {
+					agentsRemoved++
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to save repaired state: %w", err)
+		}
+	}
+
 	// Clean up orphaned worktrees
 	for _, repoName := range st.ListRepos() {
 		repoPath := c.paths.RepoDir(repoName)
@@ -5433,21 +6470,21 @@ func (c *CLI) localRepair(verbose bool) error {
 		removed, err := worktree.CleanupOrphaned(wtRootDir, wt)
 		if err != nil {
 			if verbose {
-				fmt.Printf("  Warning: failed to cleanup worktrees for %s: %v\n", repoName, err)
+				fmt.Fprintf(c.stdout(), "  Warning: failed to cleanup worktrees for %s: %v\n", repoName, err)
 			}
 			continue
 		}
 
 		if len(removed) > 0 {
 			if verbose {
-				fmt.Printf("  Cleaned up %d orphaned worktree(s) for %s\n", len(removed), repoName)
+				fmt.Fprintf(c.stdout(), "  Cleaned up %d orphaned worktree(s) for %s\n", len(removed), repoName)
 			}
 			issuesFixed += len(removed)
 		}
 
 		// Prune git worktree references
 		if err := wt.Prune(); err != nil && verbose {
-			fmt.Printf("  Warning: failed to prune worktrees for %s: %v\n", repoName, err)
+			fmt.Fprintf(c.stdout(), "  Warning: failed to prune worktrees for %s: %v\n", repoName, err)
 		}
 	}
 
@@ -5457,7 +6494,7 @@ func (c *CLI) localRepair(verbose bool) error {
 		validAgents, _ := st.ListAgents(repoName)
 		if count, err := msgMgr.CleanupOrphaned(repoName, validAgents); err == nil && count > 0 {
 			if verbose {
-				fmt.Printf("  Cleaned up %d orphaned message dir(s) for %s\n", count, repoName)
+				fmt.Fprintf(c.stdout(), "  Cleaned up %d orphaned message dir(s) for %s\n", count, repoName)
 			}
 			issuesFixed += count
 		}
@@ -5465,28 +6502,23 @@ func (c *CLI) localRepair(verbose bool) error {
 
 	// Report orphaned tmux sessions
 	if len(orphanedSessions) > 0 {
-		fmt.Printf("\nFound %d orphaned tmux session(s) not in state:\n", len(orphanedSessions))
+		fmt.Fprintf(c.stdout(), "\nFound %d orphaned tmux session(s) not in state:\n", len(orphanedSessions))
 		for _, session := range orphanedSessions {
-			fmt.Printf("  - %s\n", session)
+			fmt.Fprintf(c.stdout(), "  - %s\n", session)
 		}
-		fmt.Println("To remove these, run: tmux kill-session -t <session>")
-		fmt.Println("Or use: multiclaude stop-all")
-	}
-
-	// Save updated state
-	if err := st.Save(); err != nil {
-		return fmt.Errorf("failed to save repaired state: %w", err)
+		fmt.Fprintln(c.stdout(), "To remove these, run: tmux kill-session -t <session>")
+		fmt.Fprintln(c.stdout(), "Or use: multiclaude stop-all")
 	}
 
-	fmt.Println("\n✓ Local repair completed")
+	fmt.Fprintln(c.stdout(), "\n✓ Local repair completed")
 	if agentsRemoved > 0 {
-		fmt.Printf("  Removed %d dead agent(s)\n", agentsRemoved)
+		fmt.Fprintf(c.stdout(), "  Removed %d dead agent(s)\n", agentsRemoved)
 	}
 	if issuesFixed > 0 {
-		fmt.Printf("  Fixed %d issue(s)\n", issuesFixed)
+		fmt.Fprintf(c.stdout(), "  Fixed %d issue(s)\n", issuesFixed)
 	}
 	if agentsRemoved == 0 && issuesFixed == 0 {
-		fmt.Println("  No issues found")
+		fmt.Fprintln(c.stdout(), "  No issues found")
 	}
 
 	return nil
@@ -5495,6 +6527,8 @@ func (c *CLI) localRepair(verbose bool) error {
 // restartClaude restarts Claude in the current agent context.
 // It auto-detects whether to use --resume or --session-id based on session history.
 func (c *CLI) restartClaude(args []string) error {
+	c.streamOutput()
+
 	// Infer agent context from cwd
 	repoName, agentName, err := c.inferAgentContext()
 	if err != nil {
@@ -5517,7 +6551,7 @@ func (c *CLI) restartClaude(args []string) error {
 	}
 
 	// Get the prompt file path (stored as ~/.multiclaude/prompts/<agent-name>.md)
-	promptFile := filepath.Join(c.paths.Root, "prompts", agentName+".md")
+	promptFile := filepath.Join(c.paths.PromptsDir(), agentName+".md")
 
 	// Check if the session has history by looking for the .jsonl file
 	// Claude stores sessions in ~/.claude/projects/<encoded-path>/<session-id>.jsonl
@@ -5541,11 +6575,11 @@ func (c *CLI) restartClaude(args []string) error {
 	if hasHistory {
 		// Session has history - use --resume to continue
 		cmdArgs = []string{"--resume", agent.SessionID}
-		fmt.Printf("Resuming Claude session %s...\n", agent.SessionID)
+		fmt.Fprintf(c.stdout(), "Resuming Claude session %s...\n", agent.SessionID)
 	} else {
 		// New session - use --session-id
 		cmdArgs = []string{"--session-id", agent.SessionID}
-		fmt.Printf("Starting new Claude session %s...\n", agent.SessionID)
+		fmt.Fprintf(c.stdout(), "Starting new Claude session %s...\n", agent.SessionID)
 	}
 
 	// Add common flags
@@ -5557,7 +6591,7 @@ func (c *CLI) restartClaude(args []string) error {
 	// Exec claude
 	claudePath := "claude"
 
-	fmt.Printf("Running: %s %s\n\n", claudePath, strings.Join(cmdArgs, " "))
+	fmt.Fprintf(c.stdout(), "Running: %s %s\n\n", claudePath, strings.Join(cmdArgs, " "))
 
 	// Run claude interactively
 	cmd := exec.Command(claudePath, cmdArgs...)
@@ -5570,7 +6604,7 @@ func (c *CLI) restartClaude(args []string) error {
 }
 
 func (c *CLI) showDocs(args []string) error {
-	fmt.Println(c.documentation)
+	fmt.Fprintln(c.stdout(), c.documentation)
 	return nil
 }
 
@@ -5669,7 +6703,7 @@ func ParseFlags(args []string) (map[string]string, []string) {
 // savePromptToFile writes prompt text to the prompts directory and returns the path.
 // This is a common helper used by various prompt-writing functions.
 func (c *CLI) savePromptToFile(agentName, promptText string) (string, error) {
-	promptDir := filepath.Join(c.paths.Root, "prompts")
+	promptDir := c.paths.PromptsDir()
 	if err := os.MkdirAll(promptDir, 0755); err != nil {
 		return "", fmt.Errorf("failed to create prompt directory: %w", err)
 	}
@@ -5843,32 +6877,36 @@ Do NOT create a new PR. The existing PR will be updated automatically when you p
 	return c.savePromptToFile(agentName, promptText)
 }
 
+// captureOutput copies stdin to the capture file named by its argument,
+// rotating the file as it grows. tmux pipe-pane runs it for each agent pane.
+func (c *CLI) captureOutput(args []string) error {
+	if len(args) != 1 {
+		return errors.InvalidUsage("usage: multiclaude " + output.CaptureCommand + " <file>")
+	}
+
+	return output.CopyRotating(args[0], os.Stdin, output.DefaultMaxLogSize, output.DefaultMaxBackups)
+}
+
 // setupOutputCapture sets up tmux pipe-pane to capture agent output to a log file.
 // It creates the necessary directories and starts the pipe-pane command.
 // The agentType should be "worker" for worker agents, anything else for system agents.
 func (c *CLI) setupOutputCapture(tmuxSession, tmuxWindow, repoName, agentName, agentType string) error {
-	// Determine log file path based on agent type
 	isWorker := agentType == "worker" || agentType == "review"
-	logFile := c.paths.AgentLogFile(repoName, agentName, isWorker)
-
-	// Ensure directory exists
-	logDir := filepath.Dir(logFile)
-	if err := os.MkdirAll(logDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
-	}
-
-	// Set up pipe-pane
-	tmuxClient := tmux.NewClient()
-	if err := tmuxClient.StartPipePane(context.Background(), tmuxSession, tmuxWindow, logFile); err != nil {
-		return fmt.Errorf("failed to start output capture: %w", err)
-	}
-
-	return nil
+	outMgr := output.NewManager(c.paths.OutputDir)
+	outMgr.CaptureExecutable = c.executable
+	_, err := outMgr.Capture(context.Background(), tmux.NewClient(), tmuxSession, tmuxWindow, repoName, agentName, isWorker)
+	return err
 }
 
 // startClaudeInTmux starts Claude Code in a tmux window with the given configuration
 // Returns the PID of the Claude process
-func (c *CLI) startClaudeInTmux(binaryPath, tmuxSession, tmuxWindow, workDir, sessionID, promptFile, repoName string, initialMessage string) (int, error) {
+func (c *CLI) startClaudeInTmux(binaryPath, tmuxSession, tmuxWindow, workDir, sessionID, promptFile, repoName string, agentType state.AgentType, initialMessage string) (int, error) {
+	// Apply any claude overrides configured for this agent type
+	claudeConfig := c.claudeConfig(repoName, agentType)
+	if claudeConfig.BinaryPath != "" {
+		binaryPath = claudeConfig.BinaryPath
+	}
+
 	// Build Claude command - uses global ~/.claude/ for auth and slash commands are embedded in prompts
 	claudeCmd := fmt.Sprintf("%s --session-id %s --dangerously-skip-permissions --chrome", binaryPath, sessionID)
 
@@ -5877,6 +6915,8 @@ func (c *CLI) startClaudeInTmux(binaryPath, tmuxSession, tmuxWindow, workDir, se
 		claudeCmd += fmt.Sprintf(" --append-system-prompt-file %s", promptFile)
 	}
 
+	claudeCmd += claude.ExtraArgs(claudeConfig.Model, claudeConfig.ExtraArgs)
+
 	// Send command to tmux window
 	target := fmt.Sprintf("%s:%s", tmuxSession, tmuxWindow)
 	cmd := exec.Command("tmux", "send-keys", "-t", target, claudeCmd, "C-m")
@@ -5892,7 +6932,7 @@ func (c *CLI) startClaudeInTmux(binaryPath, tmuxSession, tmuxWindow, workDir, se
 	pid, err := tmuxClient.GetPanePID(context.Background(), tmuxSession, tmuxWindow)
 	if err != nil {
 		// Non-fatal - we'll just not have the PID
-		fmt.Printf("Warning: failed to get Claude PID: %v\n", err)
+		fmt.Fprintf(c.stdout(), "Warning: failed to get Claude PID: %v\n", err)
 		pid = 0
 	}
 
@@ -5939,12 +6979,12 @@ func (c *CLI) bugReport(args []string) error {
 		if err := os.WriteFile(outputFile, []byte(markdown), 0644); err != nil {
 			return fmt.Errorf("failed to write report to %s: %w", outputFile, err)
 		}
-		fmt.Printf("Bug report written to: %s\n", outputFile)
+		fmt.Fprintf(c.stdout(), "Bug report written to: %s\n", outputFile)
 		return nil
 	}
 
 	// Print to stdout
-	fmt.Print(markdown)
+	fmt.Fprint(c.stdout(), markdown)
 	return nil
 }
 
@@ -5953,30 +6993,65 @@ func (c *CLI) diagnostics(args []string) error {
 	flags, _ := ParseFlags(args)
 
 	// Create collector and generate report
-	collector := diagnostics.NewCollector(c.paths, Version)
+	collector := diagnostics.NewCollectorWithOptions(c.paths, Version, diagnostics.CollectorOptions{
+		DaemonStatus: daemon.NewPIDFile(c.paths.DaemonPID).IsRunning,
+	})
+	collector.SetOffline(flags["offline"] == "true")
+	if list, ok := flags["endpoints"]; ok {
+		var endpoints []string
+		for _, e := range strings.Split(list, ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				endpoints = append(endpoints, e)
+			}
+		}
+		collector.SetEndpoints(endpoints)
+	}
 	report, err := collector.Collect()
 	if err != nil {
 		return fmt.Errorf("failed to collect diagnostics: %w", err)
 	}
+	for _, f := range report.Health.Findings {
+		switch f.Severity {
+		case diagnostics.SeverityError:
+			fmt.Fprintf(os.Stderr, "Error: %s\n", f.Message)
+		case diagnostics.SeverityWarn:
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", f.Message)
+		}
+	}
+
+	if _, toFile := flags["output"]; c.jsonOutput && !toFile {
+		c.setJSONResult(report)
+		return nil
+	}
 
-	// Always output as pretty JSON by default (unless --json=false for compact)
-	prettyJSON := flags["json"] != "false"
-	jsonOutput, err := report.ToJSON(prettyJSON)
+	var output string
+	switch format := flags["format"]; format {
+	case "", "json":
+		// Always output as pretty JSON by default (unless --json=false for compact)
+		prettyJSON := flags["json"] != "false"
+		output, err = report.ToJSON(prettyJSON)
+	case "markdown", "md":
+		output, err = report.ToMarkdown()
+	case "html":
+		output, err = report.ToHTML()
+	default:
+		return errors.InvalidUsage(fmt.Sprintf("unknown format %q (expected json, markdown, or html)", format))
+	}
 	if err != nil {
-		return fmt.Errorf("failed to format diagnostics as JSON: %w", err)
+		return fmt.Errorf("failed to format diagnostics: %w", err)
 	}
 
 	// Check if output file specified
 	if outputFile, ok := flags["output"]; ok {
-		if err := os.WriteFile(outputFile, []byte(jsonOutput), 0644); err != nil {
+		if err := os.WriteFile(outputFile, []byte(output), 0644); err != nil {
 			return fmt.Errorf("failed to write diagnostics to %s: %w", outputFile, err)
 		}
-		fmt.Printf("Diagnostics written to: %s\n", outputFile)
+		fmt.Fprintf(c.stdout(), "Diagnostics written to: %s\n", outputFile)
 		return nil
 	}
 
 	// Print to stdout
-	fmt.Println(jsonOutput)
+	fmt.Fprintln(c.stdout(), strings.TrimRight(output, "\n"))
 	return nil
 }
 
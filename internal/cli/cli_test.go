@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"bytes"
 	"context"
 	"os"
 	"os/exec"
@@ -9,7 +10,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/dlorenc/multiclaude/internal/auth"
 	"github.com/dlorenc/multiclaude/internal/daemon"
+	"github.com/dlorenc/multiclaude/internal/errors"
 	"github.com/dlorenc/multiclaude/internal/messages"
 	"github.com/dlorenc/multiclaude/internal/socket"
 	"github.com/dlorenc/multiclaude/internal/state"
@@ -919,6 +922,64 @@ func TestCLISocketCommunication(t *testing.T) {
 	}
 }
 
+func TestResolveAttachTarget(t *testing.T) {
+	tmuxClient := tmux.NewClient()
+	if !tmuxClient.IsTmuxAvailable() {
+		t.Fatal("tmux is required for this test but not available")
+	}
+
+	cli, d, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	tmuxSession := "mc-attach-test"
+	if err := tmuxClient.CreateSession(ctx, tmuxSession, true); err != nil {
+		t.Fatalf("Failed to create tmux session: %v", err)
+	}
+	defer tmuxClient.KillSession(ctx, tmuxSession)
+	if err := tmuxClient.CreateWindow(ctx, tmuxSession, "worker1"); err != nil {
+		t.Fatalf("Failed to create tmux window: %v", err)
+	}
+
+	repo := &state.Repository{
+		GithubURL:   "https://github.com/test/repo",
+		TmuxSession: tmuxSession,
+		Agents:      make(map[string]state.Agent),
+	}
+	if err := d.GetState().AddRepo("test-repo", repo); err != nil {
+		t.Fatalf("Failed to add repo: %v", err)
+	}
+	for _, name := range []string{"worker1", "worker2"} {
+		agent := state.Agent{Type: state.AgentTypeWorker, TmuxWindow: name, CreatedAt: time.Now()}
+		if err := d.GetState().AddAgent("test-repo", name, agent); err != nil {
+			t.Fatalf("Failed to add agent: %v", err)
+		}
+	}
+
+	target, err := cli.resolveAttachTarget(ctx, "test-repo", "worker1")
+	if err != nil {
+		t.Fatalf("resolveAttachTarget() failed: %v", err)
+	}
+	if target != tmuxSession+":worker1" {
+		t.Errorf("target = %q, want %q", target, tmuxSession+":worker1")
+	}
+
+	// An agent whose window was closed points at reconcile
+	if _, err := cli.resolveAttachTarget(ctx, "test-repo", "worker2"); err == nil || !strings.Contains(errors.Format(err), "multiclaude reconcile") {
+		t.Errorf("missing window error = %v, want a reconcile suggestion", err)
+	}
+
+	// So does one whose whole session is gone
+	tmuxClient.KillSession(ctx, tmuxSession)
+	if _, err := cli.resolveAttachTarget(ctx, "test-repo", "worker1"); err == nil || !strings.Contains(errors.Format(err), "no longer exists") {
+		t.Errorf("missing session error = %v, want it to say the window no longer exists", err)
+	}
+
+	if _, err := cli.resolveAttachTarget(ctx, "test-repo", "nobody"); err == nil {
+		t.Error("resolveAttachTarget() should fail for an unknown agent")
+	}
+}
+
 func TestCLIWorkCreateWithRealTmux(t *testing.T) {
 	tmuxClient := tmux.NewClient()
 	if !tmuxClient.IsTmuxAvailable() {
@@ -2811,6 +2872,48 @@ func TestVersionCommandJSON(t *testing.T) {
 	}
 }
 
+func TestNewWithUnreadableTokenFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(auth.TokenEnv, "")
+	t.Setenv(auth.TokenFileEnv, filepath.Join(t.TempDir(), "missing"))
+
+	// Help and version don't need the token
+	cli, err := New()
+	if err != nil {
+		t.Fatalf("New() with an unreadable token file failed: %v", err)
+	}
+	if err := cli.versionCommand([]string{}); err != nil {
+		t.Errorf("versionCommand() failed: %v", err)
+	}
+
+	// Commands that reach the daemon report it
+	if _, err := cli.socketClient(); err == nil || !strings.Contains(errors.Format(err), auth.TokenFileEnv) {
+		t.Errorf("socketClient() error = %v, want one naming %s", err, auth.TokenFileEnv)
+	}
+}
+
+func TestRemoteDaemonAddr(t *testing.T) {
+	cli, _, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	// Nothing listens here; the local daemon's PID file must not be consulted
+	cli.daemonAddr = "127.0.0.1:1"
+
+	err := cli.Execute([]string{"cleanup"})
+	if err == nil || !strings.Contains(errors.Format(err), daemon.AddrEnv) {
+		t.Errorf("cleanup with a remote daemon = %v, want an error naming %s", err, daemon.AddrEnv)
+	}
+
+	var out bytes.Buffer
+	cli.out = &out
+	if err := cli.Execute([]string{"daemon", "status"}); err != nil {
+		t.Fatalf("daemon status failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Daemon at 127.0.0.1:1 is not responding") {
+		t.Errorf("daemon status output = %q, want the remote daemon reported unreachable", out.String())
+	}
+}
+
 func TestShowHelpNoPanic(t *testing.T) {
 	cli, _, cleanup := setupTestEnvironment(t)
 	defer cleanup()
@@ -3139,23 +3242,29 @@ func TestRemoveDirectoryIfExists(t *testing.T) {
 	}
 	defer os.RemoveAll(tmpDir)
 
+	var out bytes.Buffer
+	cli := &CLI{out: &out}
+
 	t.Run("removes existing directory", func(t *testing.T) {
 		testDir := filepath.Join(tmpDir, "test-dir")
 		if err := os.Mkdir(testDir, 0755); err != nil {
 			t.Fatalf("Failed to create test dir: %v", err)
 		}
 
-		removeDirectoryIfExists(testDir, "test directory")
+		cli.removeDirectoryIfExists(testDir, "test directory")
 
 		if _, err := os.Stat(testDir); !os.IsNotExist(err) {
 			t.Error("Directory should be removed")
 		}
+		if got := out.String(); got != "  Removed "+testDir+"\n" {
+			t.Errorf("output = %q, want the removal written to the CLI's stdout", got)
+		}
 	})
 
 	t.Run("handles nonexistent directory gracefully", func(t *testing.T) {
 		nonexistentDir := filepath.Join(tmpDir, "nonexistent")
 		// Should not panic or error
-		removeDirectoryIfExists(nonexistentDir, "nonexistent directory")
+		cli.removeDirectoryIfExists(nonexistentDir, "nonexistent directory")
 	})
 }
 
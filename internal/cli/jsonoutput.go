@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/dlorenc/multiclaude/internal/errors"
+	"github.com/fatih/color"
+)
+
+// JSONEnvVar enables JSON output for every command when set to a true value,
+// equivalent to passing the global --json flag
+const JSONEnvVar = "MULTICLAUDE_JSON"
+
+// JSONError is the object written to stdout when a command fails in JSON mode
+type JSONError struct {
+	Error      string `json:"error"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// JSONText is the object written to stdout in JSON mode for commands that
+// have no structured result; Output holds the text the command printed
+type JSONText struct {
+	Output string `json:"output"`
+}
+
+// ReportedError wraps an error that has already been written to stdout as
+// JSON. Callers should exit non-zero without printing it again.
+type ReportedError struct {
+	Err error
+}
+
+func (e *ReportedError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *ReportedError) Unwrap() error {
+	return e.Err
+}
+
+// extractJSONFlag removes the global --json flag from args and reports
+// whether JSON output was requested by the flag or JSONEnvVar.
+// --json=<value> is left alone because diagnostics uses it to pick
+// compact output.
+func extractJSONFlag(args []string) ([]string, bool) {
+	enabled, _ := strconv.ParseBool(os.Getenv(JSONEnvVar))
+
+	rest := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == "--json" {
+			enabled = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	return rest, enabled
+}
+
+// setJSONResult records v as the command's result in JSON mode. Commands with
+// structured output call this instead of printing text.
+func (c *CLI) setJSONResult(v interface{}) {
+	c.jsonResult = v
+	c.hasJSONResult = true
+}
+
+// stdout returns where commands write their text output: os.Stdout, or the
+// buffer collecting it while a command runs in JSON mode
+func (c *CLI) stdout() io.Writer {
+	if c.out != nil {
+		return c.out
+	}
+	return os.Stdout
+}
+
+// streamOutput is called by interactive and streaming commands before they
+// take over the terminal. They have no single result to report, so in JSON
+// mode their output goes straight to stdout and no JSON object is written.
+func (c *CLI) streamOutput() {
+	if !c.jsonOutput || c.jsonStreaming {
+		return
+	}
+	c.jsonStreaming = true
+	c.out = c.jsonStdout
+	color.NoColor = c.jsonNoColor
+}
+
+// executeJSON runs a command with its text output collected, then writes a
+// single JSON object to stdout: the command's structured result if it set
+// one, a JSONText with the collected text otherwise, or a JSONError on
+// failure. Commands that call streamOutput are left alone.
+func (c *CLI) executeJSON(args []string) error {
+	out := c.out
+	var text bytes.Buffer
+	c.jsonStdout = c.stdout()
+	c.jsonNoColor = color.NoColor
+	c.out = &text
+	color.NoColor = true
+	defer func() {
+		c.out = out
+		color.NoColor = c.jsonNoColor
+	}()
+
+	c.jsonOutput = true
+	c.jsonStreaming = false
+	c.jsonResult = nil
+	c.hasJSONResult = false
+
+	runErr := c.execute(args)
+	if c.jsonStreaming {
+		return runErr
+	}
+
+	var result interface{}
+	switch {
+	case runErr != nil:
+		jsonErr := JSONError{Error: runErr.Error()}
+		if cliErr, ok := runErr.(*errors.CLIError); ok {
+			if cliErr.Cause != nil {
+				jsonErr.Error += ": " + cliErr.Cause.Error()
+			}
+			jsonErr.Suggestion = cliErr.Suggestion
+		}
+		result = jsonErr
+	case c.hasJSONResult:
+		result = c.jsonResult
+	default:
+		result = JSONText{Output: strings.TrimRight(text.String(), "\n")}
+	}
+
+	encoder := json.NewEncoder(c.jsonStdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		return err
+	}
+
+	if runErr != nil {
+		return &ReportedError{Err: runErr}
+	}
+	return nil
+}
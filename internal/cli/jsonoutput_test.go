@@ -0,0 +1,361 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dlorenc/multiclaude/internal/daemon"
+	"github.com/dlorenc/multiclaude/internal/messages"
+	"github.com/dlorenc/multiclaude/internal/state"
+)
+
+// executeForJSON runs the CLI with its output collected and decodes the
+// single JSON object it writes
+func executeForJSON(t *testing.T, cli *CLI, args []string) (map[string]interface{}, error) {
+	t.Helper()
+
+	var out bytes.Buffer
+	cli.out = &out
+	defer func() { cli.out = nil }()
+	runErr := cli.Execute(args)
+	data := out.Bytes()
+
+	var result map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	if err := decoder.Decode(&result); err != nil {
+		t.Fatalf("output is not a JSON object: %v\n%s", err, data)
+	}
+	if decoder.More() {
+		t.Fatalf("output contains more than one JSON value:\n%s", data)
+	}
+	return result, runErr
+}
+
+func sortedMapKeys(m map[string]interface{}) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestExtractJSONFlag(t *testing.T) {
+	t.Setenv(JSONEnvVar, "")
+
+	args, enabled := extractJSONFlag([]string{"status", "--json"})
+	if !enabled || !reflect.DeepEqual(args, []string{"status"}) {
+		t.Errorf("extractJSONFlag(status --json) = %v, %v", args, enabled)
+	}
+
+	args, enabled = extractJSONFlag([]string{"diagnostics", "--json=false"})
+	if enabled || !reflect.DeepEqual(args, []string{"diagnostics", "--json=false"}) {
+		t.Errorf("--json=value should be left for the command, got %v, %v", args, enabled)
+	}
+
+	t.Setenv(JSONEnvVar, "1")
+	if _, enabled := extractJSONFlag([]string{"status"}); !enabled {
+		t.Errorf("%s=1 should enable JSON output", JSONEnvVar)
+	}
+}
+
+func TestJSONOutputSystemStatus(t *testing.T) {
+	cli, d, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	repo := &state.Repository{
+		GithubURL:   "https://github.com/test/repo",
+		TmuxSession: "mc-test-repo",
+		Agents:      make(map[string]state.Agent),
+	}
+	if err := d.GetState().AddRepo("test-repo", repo); err != nil {
+		t.Fatalf("Failed to add repo: %v", err)
+	}
+
+	result, err := executeForJSON(t, cli, []string{"--json", "status"})
+	if err != nil {
+		t.Fatalf("status --json failed: %v", err)
+	}
+
+	if got := sortedMapKeys(result); !reflect.DeepEqual(got, []string{"daemon", "repos"}) {
+		t.Errorf("status keys = %v, want [daemon repos]", got)
+	}
+	daemonStatus, _ := result["daemon"].(map[string]interface{})
+	if daemonStatus["running"] != true || daemonStatus["responding"] != true {
+		t.Errorf("daemon = %v, want running and responding", daemonStatus)
+	}
+	if health, _ := daemonStatus["health"].(map[string]interface{}); health["goroutines"] == nil {
+		t.Errorf("daemon health = %v, want the daemon's health response", daemonStatus["health"])
+	}
+	repos, _ := result["repos"].([]interface{})
+	if len(repos) != 1 {
+		t.Fatalf("repos = %v, want one repo", result["repos"])
+	}
+	if name := repos[0].(map[string]interface{})["name"]; name != "test-repo" {
+		t.Errorf("repo name = %v, want test-repo", name)
+	}
+}
+
+func TestJSONOutputFromEnv(t *testing.T) {
+	cli, _, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+	t.Setenv(JSONEnvVar, "true")
+
+	result, err := executeForJSON(t, cli, []string{"repo", "list"})
+	if err != nil {
+		t.Fatalf("repo list failed: %v", err)
+	}
+	repos, ok := result["repos"].([]interface{})
+	if !ok || len(repos) != 0 {
+		t.Errorf("repos = %#v, want empty array", result["repos"])
+	}
+}
+
+func TestJSONOutputText(t *testing.T) {
+	cli, _, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	// Commands without structured output wrap their text
+	result, err := executeForJSON(t, cli, []string{"--json"})
+	if err != nil {
+		t.Fatalf("help --json failed: %v", err)
+	}
+	if got := sortedMapKeys(result); !reflect.DeepEqual(got, []string{"output"}) {
+		t.Errorf("keys = %v, want [output]", got)
+	}
+	if output, _ := result["output"].(string); !strings.Contains(output, "Usage: multiclaude") {
+		t.Errorf("output = %q, want help text", output)
+	}
+}
+
+func TestJSONOutputError(t *testing.T) {
+	cli, _, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	result, err := executeForJSON(t, cli, []string{"--json", "no-such-command"})
+	var reported *ReportedError
+	if !errors.As(err, &reported) {
+		t.Fatalf("error = %v, want *ReportedError so the caller exits non-zero", err)
+	}
+	msg, _ := result["error"].(string)
+	if !strings.Contains(msg, "no-such-command") {
+		t.Errorf("error = %q, want it to name the command", msg)
+	}
+}
+
+func TestJSONOutputStreamingCommand(t *testing.T) {
+	cli, _, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	stdout := os.Stdout
+	var sawStdout bool
+	cli.rootCmd.Subcommands["stream-test"] = &Command{
+		Name: "stream-test",
+		Run: func(args []string) error {
+			cli.streamOutput()
+			sawStdout = os.Stdout == stdout
+			fmt.Fprintln(cli.stdout(), "line 1")
+			fmt.Fprintln(cli.stdout(), "line 2")
+			return nil
+		},
+	}
+
+	var out bytes.Buffer
+	cli.out = &out
+	defer func() { cli.out = nil }()
+	if err := cli.Execute([]string{"--json", "stream-test"}); err != nil {
+		t.Fatalf("stream-test --json failed: %v", err)
+	}
+
+	// Streaming commands write their output as it comes, not wrapped in JSON
+	if got := out.String(); got != "line 1\nline 2\n" {
+		t.Errorf("output = %q, want the raw lines", got)
+	}
+	if !sawStdout {
+		t.Error("os.Stdout was replaced while the command ran")
+	}
+}
+
+// addJSONTestRepo registers test-repo with the daemon and adds agents to it
+func addJSONTestRepo(t *testing.T, d *daemon.Daemon, agents map[string]state.AgentType) {
+	t.Helper()
+
+	repo := &state.Repository{
+		GithubURL:   "https://github.com/test/repo",
+		TmuxSession: "mc-test-repo",
+		Agents:      make(map[string]state.Agent),
+	}
+	if err := d.GetState().AddRepo("test-repo", repo); err != nil {
+		t.Fatalf("Failed to add repo: %v", err)
+	}
+	for name, agentType := range agents {
+		agent := state.Agent{
+			Type:       agentType,
+			TmuxWindow: name,
+			CreatedAt:  time.Now(),
+		}
+		if err := d.GetState().AddAgent("test-repo", name, agent); err != nil {
+			t.Fatalf("Failed to add agent %s: %v", name, err)
+		}
+	}
+}
+
+func TestJSONOutputListWorkers(t *testing.T) {
+	cli, d, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+	addJSONTestRepo(t, d, map[string]state.AgentType{
+		"clever-fox": state.AgentTypeWorker,
+		"default":    state.AgentTypeWorkspace,
+	})
+
+	result, err := executeForJSON(t, cli, []string{"--json", "worker", "list", "--repo", "test-repo"})
+	if err != nil {
+		t.Fatalf("worker list --json failed: %v", err)
+	}
+
+	if got := sortedMapKeys(result); !reflect.DeepEqual(got, []string{"repo", "workers", "workspace"}) {
+		t.Errorf("keys = %v, want [repo workers workspace]", got)
+	}
+	if result["repo"] != "test-repo" {
+		t.Errorf("repo = %v, want test-repo", result["repo"])
+	}
+	workers, _ := result["workers"].([]interface{})
+	if len(workers) != 1 {
+		t.Fatalf("workers = %v, want one worker", result["workers"])
+	}
+	worker := workers[0].(map[string]interface{})
+	if worker["name"] != "clever-fox" || worker["type"] != "worker" {
+		t.Errorf("worker = %v, want clever-fox of type worker", worker)
+	}
+	if workspace, _ := result["workspace"].(map[string]interface{}); workspace["name"] != "default" {
+		t.Errorf("workspace = %v, want default", result["workspace"])
+	}
+}
+
+func TestJSONOutputListWorkspaces(t *testing.T) {
+	cli, d, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+	addJSONTestRepo(t, d, map[string]state.AgentType{
+		"clever-fox": state.AgentTypeWorker,
+		"default":    state.AgentTypeWorkspace,
+	})
+
+	result, err := executeForJSON(t, cli, []string{"--json", "workspace", "list", "--repo", "test-repo"})
+	if err != nil {
+		t.Fatalf("workspace list --json failed: %v", err)
+	}
+
+	if got := sortedMapKeys(result); !reflect.DeepEqual(got, []string{"repo", "workspaces"}) {
+		t.Errorf("keys = %v, want [repo workspaces]", got)
+	}
+	workspaces, _ := result["workspaces"].([]interface{})
+	if len(workspaces) != 1 {
+		t.Fatalf("workspaces = %v, want one workspace", result["workspaces"])
+	}
+	if name := workspaces[0].(map[string]interface{})["name"]; name != "default" {
+		t.Errorf("workspace name = %v, want default", name)
+	}
+}
+
+func TestJSONOutputListAgentDefinitions(t *testing.T) {
+	cli, d, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+	addJSONTestRepo(t, d, nil)
+
+	agentsDir := cli.paths.RepoAgentsDir("test-repo")
+	if err := os.MkdirAll(agentsDir, 0755); err != nil {
+		t.Fatalf("Failed to create agents dir: %v", err)
+	}
+	content := "# Reviewer\n\nReviews pull requests.\n"
+	if err := os.WriteFile(filepath.Join(agentsDir, "reviewer.md"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write definition: %v", err)
+	}
+
+	result, err := executeForJSON(t, cli, []string{"--json", "agents", "list", "--repo", "test-repo"})
+	if err != nil {
+		t.Fatalf("agents list --json failed: %v", err)
+	}
+
+	if got := sortedMapKeys(result); !reflect.DeepEqual(got, []string{"definitions", "repo"}) {
+		t.Errorf("keys = %v, want [definitions repo]", got)
+	}
+	definitions, _ := result["definitions"].([]interface{})
+	if len(definitions) != 1 {
+		t.Fatalf("definitions = %v, want one definition", result["definitions"])
+	}
+	def := definitions[0].(map[string]interface{})
+	if got := sortedMapKeys(def); !reflect.DeepEqual(got, []string{"description", "name", "path", "source", "title"}) {
+		t.Errorf("definition keys = %v, want [description name path source title]", got)
+	}
+	if def["name"] != "reviewer" || def["source"] != "local" || def["title"] != "Reviewer" {
+		t.Errorf("definition = %v, want local reviewer titled Reviewer", def)
+	}
+}
+
+func TestJSONOutputListMessages(t *testing.T) {
+	cli, _, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	worktreeDir := filepath.Join(cli.paths.WorktreesDir, "test-repo", "clever-fox")
+	if err := os.MkdirAll(worktreeDir, 0755); err != nil {
+		t.Fatalf("Failed to create worktree dir: %v", err)
+	}
+	t.Chdir(worktreeDir)
+
+	// No messages is an empty array, not null
+	result, err := executeForJSON(t, cli, []string{"--json", "message", "list"})
+	if err != nil {
+		t.Fatalf("message list --json failed: %v", err)
+	}
+	if msgs, ok := result["messages"].([]interface{}); !ok || len(msgs) != 0 {
+		t.Errorf("messages = %#v, want empty array", result["messages"])
+	}
+
+	sent, err := messages.NewManager(cli.paths.MessagesDir).Send("test-repo", "supervisor", "clever-fox", "hello")
+	if err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+
+	result, err = executeForJSON(t, cli, []string{"--json", "message", "list"})
+	if err != nil {
+		t.Fatalf("message list --json failed: %v", err)
+	}
+	if got := sortedMapKeys(result); !reflect.DeepEqual(got, []string{"agent", "messages", "repo"}) {
+		t.Errorf("keys = %v, want [agent messages repo]", got)
+	}
+	if result["repo"] != "test-repo" || result["agent"] != "clever-fox" {
+		t.Errorf("repo, agent = %v, %v, want test-repo, clever-fox", result["repo"], result["agent"])
+	}
+	msgs, _ := result["messages"].([]interface{})
+	if len(msgs) != 1 {
+		t.Fatalf("messages = %v, want one message", result["messages"])
+	}
+	msg := msgs[0].(map[string]interface{})
+	if msg["id"] != sent.ID || msg["from"] != "supervisor" || msg["body"] != "hello" || msg["status"] != "pending" {
+		t.Errorf("message = %v, want the pending message from supervisor", msg)
+	}
+}
+
+func TestJSONOutputRequiresYes(t *testing.T) {
+	cli, _, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	// Prompting would hang on stdin with nobody reading the question
+	result, err := executeForJSON(t, cli, []string{"--json", "stop-all", "--clean"})
+	var reported *ReportedError
+	if !errors.As(err, &reported) {
+		t.Fatalf("error = %v, want *ReportedError", err)
+	}
+	if msg, _ := result["error"].(string); !strings.Contains(msg, "--yes") {
+		t.Errorf("error = %q, want it to name --yes", msg)
+	}
+}
@@ -0,0 +1,242 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/dlorenc/multiclaude/internal/errors"
+	"github.com/dlorenc/multiclaude/internal/format"
+	"github.com/dlorenc/multiclaude/internal/socket"
+	"github.com/dlorenc/multiclaude/internal/state"
+)
+
+// listMergeQueue shows the repository's merge queue, head first
+func (c *CLI) listMergeQueue(args []string) error {
+	flags, _ := ParseFlags(args)
+	repoName, err := c.resolveRepo(flags)
+	if err != nil {
+		return errors.NotInRepo()
+	}
+
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
+	resp, err := client.Send(socket.Request{
+		Command: "merge_queue",
+		Args:    map[string]interface{}{"repo": repoName},
+	})
+	if err != nil {
+		return errors.DaemonCommunicationFailed("listing merge queue", err)
+	}
+	if !resp.Success {
+		return errors.Wrap(errors.CategoryRuntime, "failed to list merge queue", fmt.Errorf("%s", resp.Error))
+	}
+
+	var queue []state.MergeQueueEntry
+	if err := decodeData(resp.Data, &queue); err != nil {
+		return err
+	}
+	if len(queue) == 0 {
+		fmt.Fprintf(c.stdout(), "Merge queue for '%s' is empty\n", repoName)
+		return nil
+	}
+
+	format.HeaderTo(c.stdout(), "Merge queue for '%s':", repoName)
+	table := format.NewColoredTable("PR", "STATUS", "ATTEMPTS", "BRANCH", "QUEUED", "LAST ERROR")
+	for _, entry := range queue {
+		statusCell := format.Cell(string(entry.Status))
+		if entry.Status == state.MergeQueueStatusClaimed {
+			statusCell = format.ColorCell(fmt.Sprintf("claimed by %s", entry.ClaimedBy), format.Yellow)
+		}
+		table.AddRow(
+			format.Cell(fmt.Sprintf("#%d", entry.PRNumber)),
+			statusCell,
+			format.Cell(strconv.Itoa(entry.Attempts)),
+			format.Cell(entry.Branch),
+			format.Cell(format.TimeAgo(entry.EnqueuedAt)),
+			format.Cell(format.Truncate(entry.LastError, 40)),
+		)
+	}
+	table.PrintTo(c.stdout())
+	return nil
+}
+
+// enqueueMerge adds a PR to the back of the repository's merge queue
+func (c *CLI) enqueueMerge(args []string) error {
+	flags, remaining := ParseFlags(args)
+	if len(remaining) < 1 {
+		return errors.InvalidUsage("usage: multiclaude mq enqueue <pr-number> [--url <pr-url>] [--branch <branch>] [--repo <repo>]")
+	}
+	prNumber, err := parsePRNumber(remaining[0])
+	if err != nil {
+		return err
+	}
+	repoName, err := c.resolveRepo(flags)
+	if err != nil {
+		return errors.NotInRepo()
+	}
+
+	reqArgs := map[string]interface{}{
+		"repo":      repoName,
+		"pr_number": prNumber,
+	}
+	if url := flags["url"]; url != "" {
+		reqArgs["pr_url"] = url
+	}
+	if branch := flags["branch"]; branch != "" {
+		reqArgs["branch"] = branch
+	}
+
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
+	resp, err := client.Send(socket.Request{
+		Command: "enqueue_merge",
+		Args:    reqArgs,
+	})
+	if err != nil {
+		return errors.DaemonCommunicationFailed("queueing PR", err)
+	}
+	if !resp.Success {
+		return errors.Wrap(errors.CategoryRuntime, "failed to queue PR", fmt.Errorf("%s", resp.Error))
+	}
+
+	fmt.Fprintf(c.stdout(), "PR #%d queued for merge in '%s'\n", prNumber, repoName)
+	return nil
+}
+
+// claimMerge claims the head of the merge queue for the current agent
+func (c *CLI) claimMerge(args []string) error {
+	flags, _ := ParseFlags(args)
+	repoName, agentName, err := c.inferAgentContext()
+	if r := flags["repo"]; r != "" {
+		repoName = r
+	}
+	if a := flags["agent"]; a != "" {
+		agentName = a
+	}
+	if repoName == "" || agentName == "" {
+		if err == nil {
+			err = fmt.Errorf("repository and agent are required")
+		}
+		return errors.InvalidUsage(fmt.Sprintf("could not determine agent context (%v) - run from a merge-queue agent or pass --repo and --agent", err))
+	}
+
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
+	resp, err := client.Send(socket.Request{
+		Command: "claim_merge",
+		Args: map[string]interface{}{
+			"repo":  repoName,
+			"agent": agentName,
+		},
+	})
+	if err != nil {
+		return errors.DaemonCommunicationFailed("claiming merge queue head", err)
+	}
+	if !resp.Success {
+		return errors.Wrap(errors.CategoryRuntime, "failed to claim merge queue head", fmt.Errorf("%s", resp.Error))
+	}
+	if resp.Data == nil {
+		fmt.Fprintln(c.stdout(), "Nothing to claim: the merge queue is empty or its head is already claimed")
+		return nil
+	}
+
+	var entry state.MergeQueueEntry
+	if err := decodeData(resp.Data, &entry); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout(), "Claimed PR #%d (attempt %d)\n", entry.PRNumber, entry.Attempts)
+	if entry.PRURL != "" {
+		fmt.Fprintf(c.stdout(), "URL: %s\n", entry.PRURL)
+	}
+	if entry.LastError != "" {
+		fmt.Fprintf(c.stdout(), "Last failure: %s\n", entry.LastError)
+	}
+	return nil
+}
+
+// completeMerge finishes the claimed head of the merge queue
+func (c *CLI) completeMerge(args []string) error {
+	flags, remaining := ParseFlags(args)
+	if len(remaining) < 1 {
+		return errors.InvalidUsage("usage: multiclaude mq complete <pr-number> [--failure <reason>] [--repo <repo>]")
+	}
+	prNumber, err := parsePRNumber(remaining[0])
+	if err != nil {
+		return err
+	}
+	repoName, err := c.resolveRepo(flags)
+	if err != nil {
+		return errors.NotInRepo()
+	}
+
+	reqArgs := map[string]interface{}{
+		"repo":      repoName,
+		"pr_number": prNumber,
+	}
+	failure := flags["failure"]
+	if failure != "" {
+		reqArgs["failure_reason"] = failure
+	}
+
+	client, err := c.socketClient()
+	if err != nil {
+		return err
+	}
+	resp, err := client.Send(socket.Request{
+		Command: "complete_merge",
+		Args:    reqArgs,
+	})
+	if err != nil {
+		return errors.DaemonCommunicationFailed("completing merge", err)
+	}
+	if !resp.Success {
+		return errors.Wrap(errors.CategoryRuntime, "failed to complete merge", fmt.Errorf("%s", resp.Error))
+	}
+
+	var result struct {
+		Requeued bool `json:"requeued"`
+	}
+	if err := decodeData(resp.Data, &result); err != nil {
+		return err
+	}
+	switch {
+	case failure == "":
+		fmt.Fprintf(c.stdout(), "PR #%d merged and removed from the queue\n", prNumber)
+	case result.Requeued:
+		fmt.Fprintf(c.stdout(), "PR #%d failed and was moved to the back of the queue\n", prNumber)
+	default:
+		fmt.Fprintf(c.stdout(), "PR #%d failed and has no retries left; removed from the queue\n", prNumber)
+	}
+	return nil
+}
+
+// parsePRNumber parses a PR number given as "123" or "#123"
+func parsePRNumber(s string) (int, error) {
+	if len(s) > 0 && s[0] == '#' {
+		s = s[1:]
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, errors.InvalidUsage(fmt.Sprintf("invalid PR number %q", s))
+	}
+	return n, nil
+}
+
+// decodeData converts a response's generic Data into v
+func decodeData(data interface{}, v interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+// Package command runs external programs behind an interface so code that
+// shells out to git, gh, tmux, or claude can be tested without them.
+package command
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrTimeout is wrapped by errors from commands that ran past their
+// deadline and were killed
+var ErrTimeout = errors.New("timed out")
+
+// DefaultTimeout bounds local commands such as git plumbing and version
+// probes. A tool stuck on a prompt is killed instead of hanging its caller.
+const DefaultTimeout = 30 * time.Second
+
+// NetworkTimeout bounds commands that talk to a remote, such as fetch or gh
+const NetworkTimeout = 2 * time.Minute
+
+// waitDelay is how long to wait for output pipes after killing a timed-out
+// command, in case it left children holding them open
+const waitDelay = time.Second
+
+// Runner runs an external program and returns its standard output. It must
+// stop the program when ctx is done.
+type Runner interface {
+	Run(ctx context.Context, name string, args ...string) ([]byte, error)
+}
+
+// ExecRunner runs programs with os/exec
+type ExecRunner struct {
+	// Timeout, if positive, bounds every call in addition to ctx's deadline
+	Timeout time.Duration
+}
+
+// Run runs name with args. If the program fails, the returned error wraps
+// the exec error and includes whatever it wrote to standard error. If it
+// runs past its deadline it is killed and the error wraps ErrTimeout.
+func (r ExecRunner) Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.WaitDelay = waitDelay
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return output, timeoutError(name)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return output, fmt.Errorf("%w: %s", err, msg)
+		}
+		return output, err
+	}
+	return output, nil
+}
+
+// RunWithTimeout runs name through runner, giving up after timeout
+func RunWithTimeout(runner Runner, timeout time.Duration, name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return runner.Run(ctx, name, args...)
+}
+
+// IsTimeout reports whether err means a command ran past its deadline
+func IsTimeout(err error) bool {
+	return errors.Is(err, ErrTimeout)
+}
+
+func timeoutError(name string) error {
+	return fmt.Errorf("%s %w", name, ErrTimeout)
+}
+
+// Fake is a Runner for tests that returns canned results keyed by the full
+// command line and records every call. Commands with no canned result fail
+// with exec.ErrNotFound, as if the program were not installed.
+type Fake struct {
+	mu        sync.Mutex
+	responses map[string]fakeResponse
+	calls     []string
+}
+
+type fakeResponse struct {
+	output []byte
+	err    error
+	hang   bool
+}
+
+// NewFake creates a Fake with no canned results
+func NewFake() *Fake {
+	return &Fake{responses: make(map[string]fakeResponse)}
+}
+
+// Set makes the command line cmdline (name and args joined by single
+// spaces) return output and err
+func (f *Fake) Set(cmdline, output string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses[cmdline] = fakeResponse{output: []byte(output), err: err}
+}
+
+// Hang makes the command line cmdline block until its context is done, as
+// a tool stuck on a prompt would
+func (f *Fake) Hang(cmdline string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses[cmdline] = fakeResponse{hang: true}
+}
+
+// Run returns the canned result for the command line
+func (f *Fake) Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	cmdline := strings.Join(append([]string{name}, args...), " ")
+
+	f.mu.Lock()
+	f.calls = append(f.calls, cmdline)
+	resp, ok := f.responses[cmdline]
+	f.mu.Unlock()
+
+	if resp.hang {
+		<-ctx.Done()
+	}
+	if err := ctx.Err(); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, timeoutError(name)
+		}
+		return nil, err
+	}
+	if !ok {
+		return nil, &exec.Error{Name: name, Err: exec.ErrNotFound}
+	}
+	return resp.output, resp.err
+}
+
+// Calls returns the command lines run so far, in order
+func (f *Fake) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+// IsNotFound reports whether err means the program is not installed
+func IsNotFound(err error) bool {
+	return errors.Is(err, exec.ErrNotFound)
+}
@@ -0,0 +1,91 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecRunner(t *testing.T) {
+	ctx := context.Background()
+
+	output, err := ExecRunner{}.Run(ctx, "sh", "-c", "echo hello")
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	if string(output) != "hello\n" {
+		t.Errorf("output = %q, want %q", output, "hello\n")
+	}
+
+	_, err = ExecRunner{}.Run(ctx, "sh", "-c", "echo boom >&2; exit 3")
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("Run() error = %v, want it to include stderr", err)
+	}
+
+	_, err = ExecRunner{}.Run(ctx, "definitely-not-a-real-binary-12345")
+	if !IsNotFound(err) {
+		t.Errorf("Run() error = %v, want not found", err)
+	}
+}
+
+func TestFake(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+	fake.Set("git status", "clean\n", nil)
+	errBroken := errors.New("broken")
+	fake.Set("git push", "", errBroken)
+
+	if output, err := fake.Run(ctx, "git", "status"); err != nil || string(output) != "clean\n" {
+		t.Errorf("Run(git status) = %q, %v", output, err)
+	}
+	if _, err := fake.Run(ctx, "git", "push"); !errors.Is(err, errBroken) {
+		t.Errorf("Run(git push) error = %v, want %v", err, errBroken)
+	}
+	if _, err := fake.Run(ctx, "tmux", "-V"); !IsNotFound(err) {
+		t.Errorf("Run(tmux -V) error = %v, want not found", err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := fake.Run(cancelled, "git", "status"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() with cancelled context error = %v", err)
+	}
+
+	want := []string{"git status", "git push", "tmux -V", "git status"}
+	if got := fake.Calls(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Calls() = %v, want %v", got, want)
+	}
+}
+
+func TestExecRunnerTimeout(t *testing.T) {
+	start := time.Now()
+	_, err := ExecRunner{Timeout: 100 * time.Millisecond}.Run(context.Background(), "sleep", "10")
+	if !IsTimeout(err) {
+		t.Fatalf("Run() error = %v, want a timeout", err)
+	}
+	if !strings.Contains(err.Error(), "sleep timed out") {
+		t.Errorf("error = %q, want it to name the command", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Run() took %s, want it killed promptly", elapsed)
+	}
+
+	// A caller's deadline is honored the same way
+	_, err = RunWithTimeout(ExecRunner{}, 100*time.Millisecond, "sleep", "10")
+	if !IsTimeout(err) {
+		t.Errorf("RunWithTimeout() error = %v, want a timeout", err)
+	}
+}
+
+func TestFakeHang(t *testing.T) {
+	fake := NewFake()
+	fake.Hang("git fetch origin")
+
+	_, err := RunWithTimeout(fake, 20*time.Millisecond, "git", "fetch", "origin")
+	if !IsTimeout(err) {
+		t.Errorf("Run() error = %v, want a timeout", err)
+	}
+}
@@ -1,31 +1,77 @@
 package daemon
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"maps"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/dlorenc/multiclaude/internal/agent"
 	"github.com/dlorenc/multiclaude/internal/agents"
+	"github.com/dlorenc/multiclaude/internal/auth"
+	"github.com/dlorenc/multiclaude/internal/cleanup"
+	"github.com/dlorenc/multiclaude/internal/command"
 	"github.com/dlorenc/multiclaude/internal/diagnostics"
+	"github.com/dlorenc/multiclaude/internal/events"
+	"github.com/dlorenc/multiclaude/internal/fork"
+	"github.com/dlorenc/multiclaude/internal/history"
 	"github.com/dlorenc/multiclaude/internal/hooks"
 	"github.com/dlorenc/multiclaude/internal/logging"
 	"github.com/dlorenc/multiclaude/internal/messages"
+	"github.com/dlorenc/multiclaude/internal/metrics"
+	"github.com/dlorenc/multiclaude/internal/names"
+	"github.com/dlorenc/multiclaude/internal/output"
+	"github.com/dlorenc/multiclaude/internal/procstat"
 	"github.com/dlorenc/multiclaude/internal/prompts"
+	"github.com/dlorenc/multiclaude/internal/reconcile"
+	"github.com/dlorenc/multiclaude/internal/redact"
+	"github.com/dlorenc/multiclaude/internal/selftest"
 	"github.com/dlorenc/multiclaude/internal/socket"
 	"github.com/dlorenc/multiclaude/internal/state"
 	"github.com/dlorenc/multiclaude/internal/worktree"
 	"github.com/dlorenc/multiclaude/pkg/claude"
 	"github.com/dlorenc/multiclaude/pkg/config"
 	"github.com/dlorenc/multiclaude/pkg/tmux"
+	"github.com/google/uuid"
 )
 
+// ErrDraining is returned when a spawn is rejected because the daemon is
+// draining
+var ErrDraining = errors.New("daemon is draining: not accepting new agents")
+
+// ErrAgentBusy is returned when another actor holds a lease on an agent
+var ErrAgentBusy = errors.New("agent is busy")
+
+// Version is the version the daemon reports over the socket. The CLI sets it
+// from its build-time version before starting the daemon.
+var Version = "dev"
+
+// ListenTCPEnv names a "host:port", such as a Tailscale address, on which
+// the daemon also serves its socket API over TCP. Because anyone who can
+// reach the port could connect, the daemon refuses to start unless
+// tokens.json sets require_token.
+const ListenTCPEnv = "MULTICLAUDE_LISTEN_TCP"
+
+// AddrEnv names the "host:port" of a daemon listening on TCP. When it is
+// set the CLI talks to that daemon instead of the local socket.
+const AddrEnv = "MULTICLAUDE_DAEMON_ADDR"
+
 // Daemon represents the main daemon process
 type Daemon struct {
 	paths        *config.Paths
@@ -33,8 +79,21 @@ type Daemon struct {
 	tmux         *tmux.Client
 	logger       *logging.Logger
 	server       *socket.Server
+	tcpServer    *socket.Server // Serves the same API over TCP; nil unless ListenTCPEnv is set
 	pidFile      *PIDFile
 	claudeRunner *claude.Runner
+	events       *events.Bus
+	metrics      *metrics.Collector
+	requests     *metrics.Requests
+	history      *history.Recorder
+	auth         *auth.Store // Replaced by Reload; guarded by authMu
+	authMu       sync.RWMutex
+	spawnLimiter *agent.SpawnLimiter
+	userConfig   atomic.Pointer[config.Config] // config file defaults; replaced by Reload
+	draining     atomic.Bool                   // reject new agents until running ones finish
+	forceClaim   bool                          // claim the PID file even if its process is alive
+	executable   string                        // this binary, which serves output.CaptureCommand; set by Run
+	startMeta    StartMetadata
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -43,9 +102,15 @@ type Daemon struct {
 
 // New creates a new daemon instance
 func New(paths *config.Paths) (*Daemon, error) {
-	// Ensure directories exist
+	// Ensure directories exist, failing early if one is unusable rather
+	// than when the first agent writes to it
 	if err := paths.EnsureDirectories(); err != nil {
-		return nil, fmt.Errorf("failed to create directories: %w", err)
+		return nil, fmt.Errorf("invalid multiclaude directory: %w", err)
+	}
+
+	userConfig, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
 
 	// Initialize logger
@@ -53,6 +118,9 @@ func New(paths *config.Paths) (*Daemon, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create logger: %w", err)
 	}
+	if err := logger.SetLevel(userConfig.LogLevel); err != nil {
+		return nil, fmt.Errorf("failed to set log level: %w", err)
+	}
 
 	// Load or create state
 	st, err := state.Load(paths.StateFile)
@@ -60,8 +128,39 @@ func New(paths *config.Paths) (*Daemon, error) {
 		return nil, fmt.Errorf("failed to load state: %w", err)
 	}
 
+	// Publish agent lifecycle changes to in-process subscribers
+	bus := events.NewBus()
+	st.SetEventBus(bus)
+
+	collector, err := metrics.NewCollector(paths.MetricsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load metrics: %w", err)
+	}
+
+	recorder, err := history.NewRecorder(paths.HistoryFile, history.DefaultSize)
+	if errors.Is(err, history.ErrCorrupt) {
+		// History is only for debugging, so it must not keep the daemon down
+		moved, moveErr := history.MoveAside(paths.HistoryFile)
+		if moveErr != nil {
+			return nil, fmt.Errorf("failed to load command history: %w", errors.Join(err, moveErr))
+		}
+		logger.Warn("Command history was unreadable (%v); moved it to %s and starting empty", err, moved)
+		recorder, err = history.NewRecorder(paths.HistoryFile, history.DefaultSize)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load command history: %w", err)
+	}
+
+	tokens, err := auth.Load(paths.TokensFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client tokens: %w", err)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
+	// Subscribe before anything can publish so no lifecycle event is missed
+	collector.Start(ctx, bus)
+
 	tmuxClient := tmux.NewClient()
 	d := &Daemon{
 		paths:        paths,
@@ -70,12 +169,27 @@ func New(paths *config.Paths) (*Daemon, error) {
 		logger:       logger,
 		pidFile:      NewPIDFile(paths.DaemonPID),
 		claudeRunner: claude.NewRunner(claude.WithTerminal(tmuxClient)),
+		events:       bus,
+		metrics:      collector,
+		requests:     metrics.NewRequests(),
+		history:      recorder,
+		auth:         tokens,
+		spawnLimiter: agent.NewSpawnLimiter(),
 		ctx:          ctx,
 		cancel:       cancel,
 	}
+	d.userConfig.Store(userConfig)
 
-	// Create socket server
-	d.server = socket.NewServer(paths.DaemonSock, socket.HandlerFunc(d.handleRequest))
+	// Create socket server, and a TCP server if one is configured
+	d.server = socket.NewServer(paths.DaemonSock, socket.HandlerFunc(d.serveRequest))
+	if addr := os.Getenv(ListenTCPEnv); addr != "" {
+		d.tcpServer = socket.NewNetworkServer(socket.NetworkTCP, addr, socket.HandlerFunc(d.serveRequest))
+		d.tcpServer.Authorize = d.validToken
+	}
+	for _, server := range d.servers() {
+		server.HandleStream("logs", socket.StreamHandlerFunc(d.handleLogsStream))
+		server.HandleStream("daemon_logs", socket.StreamHandlerFunc(d.handleDaemonLogsStream))
+	}
 
 	return d, nil
 }
@@ -84,11 +198,25 @@ func New(paths *config.Paths) (*Daemon, error) {
 func (d *Daemon) Start() error {
 	d.logger.Info("Starting daemon")
 
-	// Check and claim PID file
-	if err := d.pidFile.CheckAndClaim(); err != nil {
+	// Check and claim PID file, noting what the previous daemon left behind
+	reason := d.pidFile.startReason()
+	var err error
+	if d.forceClaim {
+		err = d.pidFile.CheckAndClaimForce(d.logger)
+	} else {
+		err = d.pidFile.CheckAndClaim()
+	}
+	if err != nil {
 		return err
 	}
 
+	meta, err := recordStart(d.paths.DaemonMeta, reason, time.Now())
+	if err != nil {
+		d.logger.Warn("Failed to record daemon start: %v", err)
+	}
+	d.startMeta = meta
+	d.logger.Info("Daemon start #%d (%s)", meta.TotalStarts, meta.LastStartReason)
+
 	// Start socket server
 	if err := d.server.Start(); err != nil {
 		return fmt.Errorf("failed to start socket server: %w", err)
@@ -96,6 +224,20 @@ func (d *Daemon) Start() error {
 
 	d.logger.Info("Socket server started at %s", d.paths.DaemonSock)
 
+	if d.tcpServer != nil {
+		d.authMu.RLock()
+		d.tcpServer.RequireToken = d.auth.RequiresToken()
+		d.authMu.RUnlock()
+		if err := d.tcpServer.Start(); err != nil {
+			d.server.Stop()
+			if errors.Is(err, socket.ErrTokenRequired) {
+				return fmt.Errorf("%s is set but %s does not set require_token: %w", ListenTCPEnv, d.paths.TokensFile, err)
+			}
+			return fmt.Errorf("failed to start TCP server: %w", err)
+		}
+		d.logger.Info("TCP server started at %s", d.tcpServer.Addr())
+	}
+
 	d.logger.Info("Daemon started successfully")
 
 	// Log system diagnostics for monitoring and debugging
@@ -105,13 +247,19 @@ func (d *Daemon) Start() error {
 	// This prevents race conditions where health check cleans up agents being restored
 	d.restoreTrackedRepos()
 
+	// Register before returning so a SIGUSR1 or SIGHUP sent right after
+	// Start cannot hit the default action, which would kill the daemon
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGHUP)
+
 	// Start core loops after restore completes
-	d.wg.Add(5)
+	d.wg.Add(6)
 	go d.healthCheckLoop()
 	go d.messageRouterLoop()
 	go d.wakeLoop()
 	go d.serverLoop()
 	go d.worktreeRefreshLoop()
+	go d.signalLoop(signals)
 
 	return nil
 }
@@ -146,17 +294,122 @@ func (d *Daemon) TriggerWake() {
 	d.wakeAgents()
 }
 
+// signalLoop handles control signals: SIGUSR1 writes a diagnostics report,
+// so a misbehaving daemon can be inspected without a client, and SIGHUP
+// reloads the log file and client tokens
+func (d *Daemon) signalLoop(signals chan os.Signal) {
+	defer d.wg.Done()
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				// Reload logs its own outcome
+				d.Reload()
+				continue
+			}
+			path, err := d.dumpDiagnostics(time.Now())
+			if err != nil {
+				d.logger.Error("Failed to dump diagnostics on SIGUSR1: %v", err)
+				continue
+			}
+			d.logger.Info("Wrote diagnostics on SIGUSR1 to %s", path)
+		}
+	}
+}
+
+// Reload re-opens the daemon log at its configured path and re-reads the
+// client tokens file, without touching agents or state. Re-opening the log
+// lets it be rotated by renaming it. If the tokens file is invalid, or drops
+// require_token while the daemon listens on TCP, the current tokens stay in
+// effect. Every other setting is read at startup or
+// from state on use; see docs/COMMANDS.md.
+func (d *Daemon) Reload() error {
+	var errs []error
+	if err := d.logger.Reopen(d.paths.DaemonLog); err != nil {
+		errs = append(errs, fmt.Errorf("failed to reopen daemon log: %w", err))
+	}
+
+	tokens, err := auth.Load(d.paths.TokensFile)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to reload client tokens: %w", err))
+	} else if d.tcpServer != nil && !tokens.RequiresToken() {
+		errs = append(errs, fmt.Errorf("failed to reload client tokens: %s is set but %s does not set require_token: %w", ListenTCPEnv, d.paths.TokensFile, socket.ErrTokenRequired))
+	} else {
+		d.authMu.Lock()
+		d.auth = tokens
+		if d.tcpServer != nil {
+			d.tcpServer.SetRequireToken(d.auth.RequiresToken())
+		}
+		d.authMu.Unlock()
+	}
+
+	// A bad config file keeps the previous config rather than half of it
+	if userConfig, err := config.Load(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to reload config: %w", err))
+	} else {
+		d.logger.SetLevel(userConfig.LogLevel)
+		d.userConfig.Store(userConfig)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		d.logger.Error("Reload incomplete: %v", err)
+		return err
+	}
+	d.logger.Info("Reloaded daemon log, client tokens and config")
+	return nil
+}
+
+// diagnosticsCollector returns a collector that checks the daemon through
+// its PID file lock
+func (d *Daemon) diagnosticsCollector() *diagnostics.Collector {
+	return diagnostics.NewCollectorWithOptions(d.paths, Version, diagnostics.CollectorOptions{
+		DaemonStatus: d.pidFile.IsRunning,
+	})
+}
+
+// dumpDiagnostics writes a diagnostics report to the output directory,
+// named for the time it was taken, and returns its path
+func (d *Daemon) dumpDiagnostics(now time.Time) (string, error) {
+	collector := d.diagnosticsCollector()
+	// Never stall the signal handler on the network
+	collector.SetOffline(true)
+	report, err := collector.Collect()
+	if err != nil {
+		return "", fmt.Errorf("failed to collect diagnostics: %w", err)
+	}
+
+	jsonOutput, err := report.ToJSON(true)
+	if err != nil {
+		return "", fmt.Errorf("failed to format diagnostics: %w", err)
+	}
+
+	if err := os.MkdirAll(d.paths.OutputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+	path := filepath.Join(d.paths.OutputDir, "diagnostics-"+now.Format("20060102-150405")+".json")
+	if err := os.WriteFile(path, []byte(jsonOutput), 0644); err != nil {
+		return "", fmt.Errorf("failed to write diagnostics: %w", err)
+	}
+	return path, nil
+}
+
 // logDiagnostics logs system diagnostics in machine-readable JSON format
 func (d *Daemon) logDiagnostics() {
-	// Get version from CLI package (same as used by CLI)
-	version := "dev"
-
-	collector := diagnostics.NewCollector(d.paths, version)
+	collector := d.diagnosticsCollector()
+	// Startup must not wait on the network; check the clock against state only
+	collector.SetOffline(true)
 	report, err := collector.Collect()
 	if err != nil {
 		d.logger.Error("Failed to collect diagnostics: %v", err)
 		return
 	}
+	if report.Clock.Warning != "" {
+		d.logger.Warn("Clock skew: %s", report.Clock.Warning)
+	}
 
 	jsonOutput, err := report.ToJSON(false) // Compact JSON for logs
 	if err != nil {
@@ -177,15 +430,15 @@ func (d *Daemon) Stop() error {
 	// Wait for all goroutines to finish
 	d.wg.Wait()
 
-	// Stop socket server
-	if err := d.server.Stop(); err != nil {
-		d.logger.Error("Failed to stop socket server: %v", err)
+	// Stop socket servers
+	for _, server := range d.servers() {
+		if err := server.Stop(); err != nil {
+			d.logger.Error("Failed to stop socket server: %v", err)
+		}
 	}
 
-	// Save state
-	if err := d.state.Save(); err != nil {
-		d.logger.Error("Failed to save state: %v", err)
-	}
+	// State is saved by every change, so there is nothing to write here;
+	// saving the in-memory copy could overwrite a newer CLI update.
 
 	// Remove PID file
 	if err := d.pidFile.Remove(); err != nil {
@@ -201,11 +454,30 @@ func (d *Daemon) Stop() error {
 func getRequiredStringArg(args map[string]interface{}, key, description string) (string, socket.Response, bool) {
 	val, ok := args[key].(string)
 	if !ok || val == "" {
-		return "", socket.ErrorResponse("missing '%s': %s", key, description), false
+		return "", socket.CodedErrorResponse(socket.CodeBadRequest, "missing '%s': %s", key, description), false
 	}
 	return val, socket.Response{}, true
 }
 
+// errorResponse turns err into a failure response coded by errorCode
+func errorResponse(err error) socket.Response {
+	return socket.CodedErrorResponse(errorCode(err, socket.CodeInternal), "%s", err.Error())
+}
+
+// errorCode classifies err for socket clients, returning fallback when no
+// sentinel in its chain says more
+func errorCode(err error, fallback string) string {
+	switch {
+	case errors.Is(err, state.ErrRepoNotFound), errors.Is(err, state.ErrAgentNotFound):
+		return socket.CodeNotFound
+	case errors.Is(err, state.ErrRepoExists), errors.Is(err, state.ErrAgentExists), errors.Is(err, state.ErrRepoHasAgents), errors.Is(err, state.ErrMergeQueueConflict), errors.Is(err, fork.ErrDirtyWorktree):
+		return socket.CodeConflict
+	case errors.Is(err, ErrDraining), errors.Is(err, ErrAgentBusy), errors.Is(err, agent.ErrSpawnLimit):
+		return socket.CodeBusy
+	}
+	return fallback
+}
+
 // getOptionalStringArg extracts an optional string argument from request Args.
 // Returns the value if present, or the default value if missing.
 func getOptionalStringArg(args map[string]interface{}, key, defaultVal string) string {
@@ -224,6 +496,74 @@ func getOptionalBoolArg(args map[string]interface{}, key string, defaultVal bool
 	return defaultVal
 }
 
+// getOptionalIntArg extracts an optional integer argument from request Args.
+// JSON numbers decode as float64, so both float64 and int values are accepted.
+// Returns the value if present, or the default value if missing.
+func getOptionalIntArg(args map[string]interface{}, key string, defaultVal int) int {
+	switch val := args[key].(type) {
+	case float64:
+		return int(val)
+	case int:
+		return val
+	}
+	return defaultVal
+}
+
+// getStringSliceArg extracts a list of strings from args
+func getStringSliceArg(args map[string]interface{}, key string) ([]string, error) {
+	switch val := args[key].(type) {
+	case nil:
+		return nil, nil
+	case []string:
+		return val, nil
+	case []interface{}:
+		out := make([]string, 0, len(val))
+		for _, v := range val {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid '%s': must be a list of strings", key)
+			}
+			out = append(out, s)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("invalid '%s': must be a list of strings", key)
+	}
+}
+
+// getOptionalEnvArg extracts an optional map of environment variables from
+// args, checking that names are valid and values are strings
+func getOptionalEnvArg(args map[string]interface{}, key string) (map[string]string, error) {
+	raw, ok := args[key]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+
+	var env map[string]string
+	switch val := raw.(type) {
+	case map[string]string:
+		env = val
+	case map[string]interface{}:
+		env = make(map[string]string, len(val))
+		for name, v := range val {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid '%s': value of %s must be a string", key, name)
+			}
+			env[name] = s
+		}
+	default:
+		return nil, fmt.Errorf("invalid '%s': must be an object of variable names to values", key)
+	}
+
+	for name := range env {
+		if !claude.ValidEnvName(name) {
+			return nil, fmt.Errorf("invalid '%s': %q is not a valid environment variable name", key, name)
+		}
+	}
+	return env, nil
+}
+
 // periodicLoop runs a function periodically at the specified interval.
 // If onStartup is provided, it's called immediately before entering the loop.
 // The onTick function is called on each timer tick.
@@ -250,16 +590,27 @@ func (d *Daemon) periodicLoop(name string, interval time.Duration, onStartup, on
 	}
 }
 
+// servers returns the Unix socket server and, if configured, the TCP one
+func (d *Daemon) servers() []*socket.Server {
+	if d.tcpServer == nil {
+		return []*socket.Server{d.server}
+	}
+	return []*socket.Server{d.server, d.tcpServer}
+}
+
 // serverLoop handles socket connections
 func (d *Daemon) serverLoop() {
 	defer d.wg.Done()
 	d.logger.Info("Starting server loop")
 
-	// Run server in a goroutine so we can handle cancellation
-	errCh := make(chan error, 1)
-	go func() {
-		errCh <- d.server.Serve()
-	}()
+	// Run servers in goroutines so we can handle cancellation
+	servers := d.servers()
+	errCh := make(chan error, len(servers))
+	for _, server := range servers {
+		go func() {
+			errCh <- server.Serve(d.ctx)
+		}()
+	}
 
 	select {
 	case err := <-errCh:
@@ -276,6 +627,7 @@ func (d *Daemon) healthCheckLoop() {
 	startup := func() {
 		d.checkAgentHealth()
 		d.rotateLogsIfNeeded()
+		d.compactHistoryIfNeeded()
 		d.cleanupMergedBranches()
 	}
 	d.periodicLoop("health check", 2*time.Minute, startup, startup)
@@ -314,6 +666,9 @@ func (d *Daemon) checkAgentHealth() {
 
 		// Check each agent
 		for agentName, agent := range repo.Agents {
+			// Sync the session transcript first so it is complete before cleanup
+			d.syncTranscript(repoName, agentName, agent)
+
 			// Check if agent is marked as ready for cleanup
 			if agent.ReadyForCleanup {
 				d.logger.Info("Agent %s is ready for cleanup", agentName)
@@ -336,19 +691,28 @@ func (d *Daemon) checkAgentHealth() {
 
 			// Check if process is alive (if we have a PID)
 			if agent.PID > 0 {
-				if !isProcessAlive(agent.PID) {
-					d.logger.Warn("Agent %s process (PID %d) not running", agentName, agent.PID)
+				if status := procstat.Check(agent.PID); status != procstat.StatusRunning {
+					if status == procstat.StatusZombie {
+						d.logger.Warn("Agent %s process (PID %d) is defunct (zombie, not reaped)", agentName, agent.PID)
+					} else {
+						d.logger.Warn("Agent %s process (PID %d) not running", agentName, agent.PID)
+					}
 
 					// For persistent agents, attempt auto-restart
 					if agent.Type.IsPersistent() {
-						d.logger.Info("Attempting to auto-restart agent %s", agentName)
-						if err := d.restartAgent(repoName, agentName, agent, repo); err != nil {
-							d.logger.Error("Failed to restart agent %s: %v", agentName, err)
-						} else {
-							d.logger.Info("Successfully restarted agent %s", agentName)
-						}
+						d.autoRestartAgent(repoName, agentName, agent, repo)
+					} else if agent.Lifecycle() == state.AgentStatusActive {
+						// Transient agents (workers, review) aren't restarted;
+						// one that exits without completing has failed
+						d.failAgent(repoName, agentName, "process exited without completing")
+					}
+				} else if state.DefaultRestartBackoff.Stable(agent, time.Now()) {
+					// It has stayed up since its last restart, so earlier
+					// crashes no longer count against it
+					d.logger.Info("Agent %s has run stably since its last restart, clearing its %d restarts", agentName, agent.RestartCount)
+					if err := d.state.ResetRestarts(repoName, agentName); err != nil {
+						d.logger.Warn("Failed to reset restarts of agent %s: %v", agentName, err)
 					}
-					// For transient agents (workers, review), don't auto-restart - they complete and clean up
 				}
 			}
 		}
@@ -361,6 +725,8 @@ func (d *Daemon) checkAgentHealth() {
 
 	// Clean up orphaned worktrees
 	d.cleanupOrphanedWorktrees()
+
+	d.checkDrainComplete()
 }
 
 // messageRouterLoop watches for new messages and delivers them
@@ -613,9 +979,94 @@ func (d *Daemon) TriggerWorktreeRefresh() {
 	d.refreshWorktrees()
 }
 
+// serveRequest handles a socket request, counting it in the request
+// metrics and recording it in the command history. Reading the history is
+// not itself recorded. The servers are served under d.ctx, so the
+// request's context ends when d.ctx does; handlers use d.ctx directly.
+func (d *Daemon) serveRequest(_ context.Context, req socket.Request) socket.Response {
+	start := time.Now()
+	resp, ok := d.authorize(req)
+	if ok {
+		resp = d.handleRequest(req)
+	}
+	d.requests.Observe(req.Command, resp.Success, resp.Code, time.Since(start))
+	if req.Command != "history" {
+		if err := d.history.Record(req.Command, req.Args, resp.Success, resp.Error); err != nil {
+			d.logger.Warn("Failed to record command history for request %s: %v", req.ID, err)
+		}
+	}
+	return resp
+}
+
+// handleMetrics renders the daemon's metrics in the Prometheus text
+// exposition format: agent lifecycle totals, agents that have not finished
+// by type, and socket request counts and latencies
+func (d *Daemon) handleMetrics(req socket.Request) socket.Response {
+	var e metrics.Exposition
+	d.metrics.Expose(&e)
+
+	active := make(map[state.AgentType]int)
+	for _, repo := range d.state.GetAllRepos() {
+		for _, agent := range repo.Agents {
+			if agent.Lifecycle() == state.AgentStatusActive {
+				active[agent.Type]++
+			}
+		}
+	}
+	samples := make([]metrics.Sample, 0, len(active))
+	for agentType, n := range active {
+		samples = append(samples, metrics.Sample{Labels: metrics.Labels{"type": string(agentType)}, Value: float64(n)})
+	}
+	e.Gauge("multiclaude_active_agents", "Agents in state that have not finished, by type.", samples...)
+
+	d.requests.Expose(&e)
+	return socket.SuccessResponse(e.String())
+}
+
+// readOnlyCommands are the commands a read-only client may run. Anything
+// not listed here is treated as mutating.
+var readOnlyCommands = map[string]bool{
+	"ping":             true,
+	"status":           true,
+	"health":           true,
+	"version":          true,
+	"list_repos":       true,
+	"list":             true,
+	"list_agents":      true,
+	"list_orphans":     true,
+	"get_repo_config":  true,
+	"get_current_repo": true,
+	"task_history":     true,
+	"merge_queue":      true,
+	"history":          true,
+	"get_metrics":      true,
+	"metrics":          true,
+	"dump":             true,
+	"logs":             true,
+	"daemon_logs":      true,
+}
+
+// authorize checks the request's token against the command. It returns an
+// unauthorized response and false if the request may not proceed.
+func (d *Daemon) authorize(req socket.Request) (socket.Response, bool) {
+	d.authMu.RLock()
+	capability, ok := d.auth.Capability(req.Token)
+	d.authMu.RUnlock()
+	if !ok {
+		if req.Token == "" {
+			return socket.UnauthorizedResponse("a client token is required; set %s or %s", auth.TokenEnv, auth.TokenFileEnv), false
+		}
+		return socket.UnauthorizedResponse("unknown client token"), false
+	}
+	if capability == auth.ReadOnly && !readOnlyCommands[req.Command] {
+		return socket.UnauthorizedResponse("command %q requires a read-write token", req.Command), false
+	}
+	return socket.Response{}, true
+}
+
 // handleRequest handles incoming socket requests
 func (d *Daemon) handleRequest(req socket.Request) socket.Response {
-	d.logger.Debug("Handling request: %s", req.Command)
+	d.logger.Debug("Handling request %s: %s", req.ID, req.Command)
 
 	switch req.Command {
 	case "ping":
@@ -624,6 +1075,18 @@ func (d *Daemon) handleRequest(req socket.Request) socket.Response {
 	case "status":
 		return d.handleStatus(req)
 
+	case "health":
+		return d.handleHealth(req)
+
+	case "version":
+		return d.handleVersion(req)
+
+	case "drain":
+		return d.handleDrain(req)
+
+	case "undrain":
+		return d.handleUndrain(req)
+
 	case "stop":
 		go func() {
 			time.Sleep(100 * time.Millisecond)
@@ -634,12 +1097,18 @@ func (d *Daemon) handleRequest(req socket.Request) socket.Response {
 	case "list_repos":
 		return d.handleListRepos(req)
 
+	case "list":
+		return d.handleList(req)
+
 	case "add_repo":
 		return d.handleAddRepo(req)
 
 	case "remove_repo":
 		return d.handleRemoveRepo(req)
 
+	case "rename_repo":
+		return d.handleRenameRepo(req)
+
 	case "add_agent":
 		return d.handleAddAgent(req)
 
@@ -652,15 +1121,42 @@ func (d *Daemon) handleRequest(req socket.Request) socket.Response {
 	case "complete_agent":
 		return d.handleCompleteAgent(req)
 
+	case "heartbeat":
+		return d.handleHeartbeat(req)
+
+	case "set_agent_status":
+		return d.handleSetAgentStatus(req)
+
 	case "restart_agent":
 		return d.handleRestartAgent(req)
 
+	case "kill_agent":
+		return d.handleKillAgent(req)
+
 	case "trigger_cleanup":
 		return d.handleTriggerCleanup(req)
 
 	case "repair_state":
 		return d.handleRepairState(req)
 
+	case "prune_worktrees":
+		return d.handlePruneWorktrees(req)
+
+	case "compact":
+		return d.handleCompact(req)
+
+	case "selftest":
+		return d.handleSelfTest(req)
+
+	case "reconcile":
+		return d.handleReconcile(req)
+
+	case "list_orphans":
+		return d.handleListOrphans(req)
+
+	case "sync":
+		return d.handleSyncFork(req)
+
 	case "get_repo_config":
 		return d.handleGetRepoConfig(req)
 
@@ -683,14 +1179,41 @@ func (d *Daemon) handleRequest(req socket.Request) socket.Response {
 	case "task_history":
 		return d.handleTaskHistory(req)
 
+	case "merge_queue":
+		return d.handleMergeQueue(req)
+
+	case "enqueue_merge":
+		return d.handleEnqueueMerge(req)
+
+	case "claim_merge":
+		return d.handleClaimMerge(req)
+
+	case "complete_merge":
+		return d.handleCompleteMerge(req)
+
 	case "spawn_agent":
 		return d.handleSpawnAgent(req)
 
+	case "assign_review":
+		return d.handleAssignReview(req)
+
+	case "history":
+		return d.handleHistory(req)
+
+	case "get_metrics":
+		return socket.SuccessResponse(d.metrics.Snapshot())
+
+	case "metrics":
+		return d.handleMetrics(req)
+
+	case "dump":
+		return d.handleDump(req)
+
 	case "trigger_refresh":
 		return d.handleTriggerRefresh(req)
 
 	default:
-		return socket.ErrorResponse("unknown command: %q. Run 'multiclaude --help' for available commands", req.Command)
+		return socket.CodedErrorResponse(socket.CodeBadRequest, "unknown command: %q. Run 'multiclaude --help' for available commands", req.Command)
 	}
 }
 
@@ -704,14 +1227,131 @@ func (d *Daemon) handleStatus(req socket.Request) socket.Response {
 	}
 
 	return socket.SuccessResponse(map[string]interface{}{
-		"running":     true,
-		"pid":         os.Getpid(),
-		"repos":       len(repos),
-		"agents":      agentCount,
-		"socket_path": d.paths.DaemonSock,
+		"running":      true,
+		"pid":          os.Getpid(),
+		"repos":        len(repos),
+		"agents":       agentCount,
+		"socket_path":  d.paths.DaemonSock,
+		"draining":     d.draining.Load(),
+		"starts":       d.startMeta.TotalStarts,
+		"last_start":   d.startMeta.LastStart,
+		"start_reason": d.startMeta.LastStartReason,
+		"uptime":       d.uptime().String(),
+	})
+}
+
+// handleHealth answers a liveness probe. A reply shows the request loop is
+// responsive; the figures help spot a daemon that is up but struggling.
+func (d *Daemon) handleHealth(req socket.Request) socket.Response {
+	agentCount := 0
+	for _, repo := range d.state.ListRepos() {
+		agents, _ := d.state.ListAgents(repo)
+		agentCount += len(agents)
+	}
+
+	var lastError interface{}
+	if t := d.logger.LastError(); !t.IsZero() {
+		lastError = t
+	}
+
+	uptime := d.uptime()
+	return socket.SuccessResponse(map[string]interface{}{
+		"uptime":         uptime.String(),
+		"uptime_seconds": int64(uptime.Seconds()),
+		"agents":         agentCount,
+		"goroutines":     runtime.NumGoroutine(),
+		"last_error":     lastError,
+	})
+}
+
+// StartTime returns when the daemon last started, or the zero time before
+// Start. It matches the PID file's mtime, which diagnostics report.
+func (d *Daemon) StartTime() time.Time {
+	return d.startMeta.LastStart
+}
+
+// uptime is how long the daemon has been running since it last started
+func (d *Daemon) uptime() time.Duration {
+	started := d.StartTime()
+	if started.IsZero() {
+		return 0
+	}
+	return time.Since(started).Round(time.Second)
+}
+
+// handleVersion returns the daemon's version, Go version, and build info so
+// clients can detect a daemon built from a different release
+func (d *Daemon) handleVersion(req socket.Request) socket.Response {
+	build := map[string]interface{}{}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		build["path"] = info.Path
+		build["module_version"] = info.Main.Version
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				build["revision"] = setting.Value
+			case "vcs.time":
+				build["time"] = setting.Value
+			case "vcs.modified":
+				build["modified"] = setting.Value == "true"
+			}
+		}
+	}
+
+	return socket.SuccessResponse(map[string]interface{}{
+		"version":    Version,
+		"go_version": runtime.Version(),
+		"build":      build,
+	})
+}
+
+// handleDrain stops the daemon from accepting new agents while letting running
+// workers and reviewers finish. Draining ends on its own once none remain, or
+// on undrain.
+func (d *Daemon) handleDrain(req socket.Request) socket.Response {
+	d.draining.Store(true)
+	d.logger.Info("Draining: rejecting new agents")
+	d.checkDrainComplete()
+
+	return socket.SuccessResponse(map[string]interface{}{
+		"draining":  d.draining.Load(),
+		"remaining": d.drainRemaining(),
+	})
+}
+
+// handleUndrain resumes accepting new agents
+func (d *Daemon) handleUndrain(req socket.Request) socket.Response {
+	if d.draining.Swap(false) {
+		d.logger.Info("Drain cancelled: accepting new agents")
+	}
+
+	return socket.SuccessResponse(map[string]interface{}{
+		"draining":  false,
+		"remaining": d.drainRemaining(),
 	})
 }
 
+// drainRemaining counts the workers and reviewers that have not finished.
+// Persistent agents never finish, so they don't hold a drain open.
+func (d *Daemon) drainRemaining() int {
+	remaining := 0
+	for _, repo := range d.state.GetAllRepos() {
+		for _, a := range repo.Agents {
+			if (a.Type == state.AgentTypeWorker || a.Type == state.AgentTypeReview) && !a.ReadyForCleanup {
+				remaining++
+			}
+		}
+	}
+	return remaining
+}
+
+// checkDrainComplete ends draining once no running agents remain
+func (d *Daemon) checkDrainComplete() {
+	if d.draining.Load() && d.drainRemaining() == 0 && d.draining.CompareAndSwap(true, false) {
+		d.logger.Info("Drain complete: all agents finished, accepting new agents")
+	}
+}
+
 // handleListRepos lists all repositories with detailed status
 func (d *Daemon) handleListRepos(req socket.Request) socket.Response {
 	repos := d.state.GetAllRepos()
@@ -768,6 +1408,76 @@ func (d *Daemon) handleListRepos(req socket.Request) socket.Response {
 	return socket.SuccessResponse(repoDetails)
 }
 
+// inventoryRepo is one repository in the list command's response
+type inventoryRepo struct {
+	Name        string           `json:"name"`
+	GithubURL   string           `json:"github_url"`
+	TmuxSession string           `json:"tmux_session"`
+	Agents      []inventoryAgent `json:"agents"`
+}
+
+// inventoryAgent is an agent's state plus its name
+type inventoryAgent struct {
+	Name string `json:"name"`
+	state.Agent
+}
+
+// handleList returns every repository and its agents, sorted by name, with
+// credentials redacted. Optional "repo" and "type" args narrow it to one
+// repository or one agent type.
+func (d *Daemon) handleList(req socket.Request) socket.Response {
+	var filter state.AgentFilter
+	if t := getOptionalStringArg(req.Args, "type", ""); t != "" {
+		agentType, err := state.ParseAgentType(t)
+		if err != nil {
+			return socket.CodedErrorResponse(socket.CodeBadRequest, "%v", err)
+		}
+		filter.Type = agentType
+	}
+
+	repos := d.state.GetAllRepos()
+	if repoName := getOptionalStringArg(req.Args, "repo", ""); repoName != "" {
+		repo, exists := repos[repoName]
+		if !exists {
+			return socket.CodedErrorResponse(socket.CodeNotFound, "repository '%s' not found - list tracked repos with: multiclaude repo list", repoName)
+		}
+		repos = map[string]*state.Repository{repoName: repo}
+	}
+
+	names := make([]string, 0, len(repos))
+	for name := range repos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	inventory := make([]inventoryRepo, 0, len(names))
+	for _, name := range names {
+		repo := repos[name]
+		entry := inventoryRepo{
+			Name:        name,
+			GithubURL:   redact.URLCredentials(repo.GithubURL),
+			TmuxSession: repo.TmuxSession,
+			Agents:      []inventoryAgent{},
+		}
+
+		agentNames := make([]string, 0, len(repo.Agents))
+		for agentName, agent := range repo.Agents {
+			if filter.Matches(agent) {
+				agentNames = append(agentNames, agentName)
+			}
+		}
+		sort.Strings(agentNames)
+		for _, agentName := range agentNames {
+			agent := repo.Agents[agentName]
+			agent.Env = redact.Env(agent.Env)
+			entry.Agents = append(entry.Agents, inventoryAgent{Name: agentName, Agent: agent})
+		}
+		inventory = append(inventory, entry)
+	}
+
+	return socket.SuccessResponse(inventory)
+}
+
 // handleAddRepo adds a new repository
 func (d *Daemon) handleAddRepo(req socket.Request) socket.Response {
 	name, errResp, ok := getRequiredStringArg(req.Args, "name", "repository name is required (e.g., 'my-project')")
@@ -793,7 +1503,7 @@ func (d *Daemon) handleAddRepo(req socket.Request) socket.Response {
 	if mqTrackMode := getOptionalStringArg(req.Args, "mq_track_mode", ""); mqTrackMode != "" {
 		mode, err := state.ParseTrackMode(mqTrackMode)
 		if err != nil {
-			return socket.ErrorResponse("%s", err.Error())
+			return socket.CodedErrorResponse(socket.CodeBadRequest, "%v", err)
 		}
 		mqConfig.TrackMode = mode
 	}
@@ -814,7 +1524,7 @@ func (d *Daemon) handleAddRepo(req socket.Request) socket.Response {
 	if psTrackMode := getOptionalStringArg(req.Args, "ps_track_mode", ""); psTrackMode != "" {
 		mode, err := state.ParseTrackMode(psTrackMode)
 		if err != nil {
-			return socket.ErrorResponse("%s", err.Error())
+			return socket.CodedErrorResponse(socket.CodeBadRequest, "%v", err)
 		}
 		psConfig.TrackMode = mode
 	}
@@ -835,7 +1545,7 @@ func (d *Daemon) handleAddRepo(req socket.Request) socket.Response {
 	}
 
 	if err := d.state.AddRepo(name, repo); err != nil {
-		return socket.ErrorResponse("%s", err.Error())
+		return errorResponse(err)
 	}
 
 	if forkConfig.IsFork {
@@ -854,44 +1564,144 @@ func (d *Daemon) handleRemoveRepo(req socket.Request) socket.Response {
 	}
 
 	if err := d.state.RemoveRepo(name); err != nil {
-		return socket.ErrorResponse("%s", err.Error())
+		return errorResponse(err)
 	}
 
 	d.logger.Info("Removed repository: %s", name)
 	return socket.SuccessResponse(nil)
 }
 
-// handleAddAgent adds a new agent
-func (d *Daemon) handleAddAgent(req socket.Request) socket.Response {
-	repoName, errResp, ok := getRequiredStringArg(req.Args, "repo", "repository name is required")
+// handleRenameRepo renames a repository along with its directories and tmux
+// session
+func (d *Daemon) handleRenameRepo(req socket.Request) socket.Response {
+	oldName, errResp, ok := getRequiredStringArg(req.Args, "old", "current repository name is required")
 	if !ok {
 		return errResp
 	}
 
-	agentName, errResp, ok := getRequiredStringArg(req.Args, "agent", "agent name is required")
+	newName, errResp, ok := getRequiredStringArg(req.Args, "new", "new repository name is required")
 	if !ok {
 		return errResp
 	}
 
-	agentTypeStr, errResp, ok := getRequiredStringArg(req.Args, "type", "agent type is required (supervisor, worker, merge-queue, or reviewer)")
-	if !ok {
-		return errResp
+	if err := names.ValidateRepoName(newName); err != nil {
+		return socket.CodedErrorResponse(socket.CodeBadRequest, "%v", err)
 	}
 
-	worktreePath, errResp, ok := getRequiredStringArg(req.Args, "worktree_path", "path to the agent's git worktree is required")
-	if !ok {
-		return errResp
+	if err := d.renameRepo(oldName, newName); err != nil {
+		return errorResponse(err)
 	}
 
-	tmuxWindow, errResp, ok := getRequiredStringArg(req.Args, "tmux_window", "tmux window name is required")
-	if !ok {
-		return errResp
+	d.logger.Info("Renamed repository: %s -> %s", oldName, newName)
+	return socket.SuccessResponse(nil)
+}
+
+// repoDirs returns the directories that belong to a repository and are named
+// after it
+func (d *Daemon) repoDirs(repoName string) []string {
+	return []string{
+		d.paths.RepoDir(repoName),
+		d.paths.WorktreeDir(repoName),
+		d.paths.RepoMessagesDir(repoName),
+		d.paths.RepoOutputDir(repoName),
+		d.paths.RepoEnvDir(repoName),
+		d.paths.RepoArchiveDir(repoName),
+		filepath.Join(d.paths.ClaudeConfigDir, repoName),
 	}
+}
 
-	// Get session ID from args or generate one
-	sessionID, _, ok := getRequiredStringArg(req.Args, "session_id", "")
-	if !ok {
-		sessionID = fmt.Sprintf("agent-%d", time.Now().UnixNano())
+// renameRepo moves a repository's directories and tmux session to newName,
+// then renames it in state. Repositories with agents are refused, since
+// their worktrees and windows are tied to the old name. On failure the
+// directories and session are moved back.
+func (d *Daemon) renameRepo(oldName, newName string) error {
+	repo, exists := d.state.GetRepo(oldName)
+	if !exists {
+		return fmt.Errorf("%w: %q", state.ErrRepoNotFound, oldName)
+	}
+	if _, taken := d.state.GetRepo(newName); taken {
+		return fmt.Errorf("%w: %q", state.ErrRepoExists, newName)
+	}
+	if len(repo.Agents) > 0 {
+		return fmt.Errorf("%w: %q has %d; remove them before renaming", state.ErrRepoHasAgents, oldName, len(repo.Agents))
+	}
+
+	oldDirs, newDirs := d.repoDirs(oldName), d.repoDirs(newName)
+	for _, dir := range newDirs {
+		if _, err := os.Stat(dir); err == nil {
+			return fmt.Errorf("%w: %s is in the way", state.ErrRepoExists, dir)
+		}
+	}
+
+	var undo []func()
+	rollback := func() {
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+	}
+
+	for i, dir := range oldDirs {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+		if err := os.Rename(dir, newDirs[i]); err != nil {
+			rollback()
+			return fmt.Errorf("failed to move %s: %w", dir, err)
+		}
+		from, to := dir, newDirs[i]
+		undo = append(undo, func() { os.Rename(to, from) })
+	}
+
+	session := names.TmuxSessionName(newName)
+	if repo.TmuxSession != "" {
+		if hasSession, err := d.tmux.HasSession(d.ctx, repo.TmuxSession); err == nil && hasSession {
+			if err := d.tmux.RenameSession(d.ctx, repo.TmuxSession, session); err != nil {
+				rollback()
+				return fmt.Errorf("failed to rename tmux session: %w", err)
+			}
+			from := repo.TmuxSession
+			undo = append(undo, func() { d.tmux.RenameSession(d.ctx, session, from) })
+		}
+	}
+
+	if err := d.state.RenameRepo(oldName, newName, session); err != nil {
+		rollback()
+		return err
+	}
+	return nil
+}
+
+// handleAddAgent adds a new agent
+func (d *Daemon) handleAddAgent(req socket.Request) socket.Response {
+	repoName, errResp, ok := getRequiredStringArg(req.Args, "repo", "repository name is required")
+	if !ok {
+		return errResp
+	}
+
+	agentName, errResp, ok := getRequiredStringArg(req.Args, "agent", "agent name is required")
+	if !ok {
+		return errResp
+	}
+
+	agentTypeStr, errResp, ok := getRequiredStringArg(req.Args, "type", "agent type is required (supervisor, worker, merge-queue, or reviewer)")
+	if !ok {
+		return errResp
+	}
+
+	worktreePath, errResp, ok := getRequiredStringArg(req.Args, "worktree_path", "path to the agent's git worktree is required")
+	if !ok {
+		return errResp
+	}
+
+	tmuxWindow, errResp, ok := getRequiredStringArg(req.Args, "tmux_window", "tmux window name is required")
+	if !ok {
+		return errResp
+	}
+
+	// Get session ID from args or generate one
+	sessionID, _, ok := getRequiredStringArg(req.Args, "session_id", "")
+	if !ok {
+		sessionID = fmt.Sprintf("agent-%d", time.Now().UnixNano())
 	}
 
 	// Get PID from args (optional)
@@ -914,8 +1724,26 @@ func (d *Daemon) handleAddAgent(req socket.Request) socket.Response {
 	// Optional task field for workers
 	agent.Task = getOptionalStringArg(req.Args, "task", "")
 
+	// Branch defaults to whatever the worktree has checked out
+	agent.Branch = getOptionalStringArg(req.Args, "branch", "")
+	if agent.Branch == "" {
+		if branch, err := worktree.GetCurrentBranch(worktreePath); err == nil {
+			agent.Branch = branch
+		}
+	}
+
+	if d.draining.Load() {
+		return errorResponse(ErrDraining)
+	}
+
+	release, err := d.acquireSpawn(repoName, agent.Type)
+	if err != nil {
+		return errorResponse(err)
+	}
+	defer release()
+
 	if err := d.state.AddAgent(repoName, agentName, agent); err != nil {
-		return socket.ErrorResponse("%s", err.Error())
+		return errorResponse(err)
 	}
 
 	d.logger.Info("Added agent %s to repo %s", agentName, repoName)
@@ -934,12 +1762,39 @@ func (d *Daemon) handleRemoveAgent(req socket.Request) socket.Response {
 		return errResp
 	}
 
-	if err := d.state.RemoveAgent(repoName, agentName); err != nil {
-		return socket.ErrorResponse("%s", err.Error())
+	dryRun := getOptionalBoolArg(req.Args, "dry_run", false)
+
+	var actions []string
+	if getOptionalBoolArg(req.Args, "remove_worktree", false) {
+		opts := agent.RemoveOptions{
+			RepoPath: d.paths.RepoDir(repoName),
+			Force:    getOptionalBoolArg(req.Args, "force", false),
+			DryRun:   dryRun,
+		}
+		var err error
+		actions, err = agent.NewManager(d.state, d.tmux).Remove(repoName, agentName, opts)
+		if err != nil {
+			return errorResponse(err)
+		}
+	} else {
+		if _, exists := d.state.GetAgent(repoName, agentName); !exists {
+			return errorResponse(fmt.Errorf("%w: %q in repository %q", state.ErrAgentNotFound, agentName, repoName))
+		}
+		actions = []string{fmt.Sprintf("remove agent %s/%s from state", repoName, agentName)}
+		if !dryRun {
+			if err := d.state.RemoveAgent(repoName, agentName); err != nil {
+				return errorResponse(err)
+			}
+		}
 	}
 
-	d.logger.Info("Removed agent %s from repo %s", agentName, repoName)
-	return socket.SuccessResponse(nil)
+	if !dryRun {
+		d.logger.Info("Removed agent %s from repo %s", agentName, repoName)
+	}
+	return socket.SuccessResponse(map[string]interface{}{
+		"dry_run": dryRun,
+		"actions": actions,
+	})
 }
 
 // handleListAgents lists agents for a repository
@@ -949,9 +1804,32 @@ func (d *Daemon) handleListAgents(req socket.Request) socket.Response {
 		return errResp
 	}
 
-	agents, err := d.state.ListAgents(repoName)
+	var filter state.AgentFilter
+	if t := getOptionalStringArg(req.Args, "type", ""); t != "" {
+		agentType, err := state.ParseAgentType(t)
+		if err != nil {
+			return socket.CodedErrorResponse(socket.CodeBadRequest, "%v", err)
+		}
+		filter.Type = agentType
+	}
+	if st := getOptionalStringArg(req.Args, "status", ""); st != "" {
+		status, err := state.ParseAgentStatus(st)
+		if err != nil {
+			return socket.CodedErrorResponse(socket.CodeBadRequest, "%v", err)
+		}
+		filter.Status = status
+	}
+
+	// Paging is opt-in so existing callers keep receiving a plain list
+	_, hasOffset := req.Args["offset"]
+	_, hasLimit := req.Args["limit"]
+	paged := hasOffset || hasLimit
+
+	offset := getOptionalIntArg(req.Args, "offset", 0)
+	limit := getOptionalIntArg(req.Args, "limit", 0)
+	agents, total, err := d.state.ListAgentNamesPage(repoName, filter, offset, limit)
 	if err != nil {
-		return socket.ErrorResponse("%s", err.Error())
+		return errorResponse(err)
 	}
 
 	// Check if rich format is requested
@@ -972,6 +1850,7 @@ func (d *Daemon) handleListAgents(req socket.Request) socket.Response {
 			"name":          agentName,
 			"type":          agent.Type,
 			"worktree_path": agent.WorktreePath,
+			"branch":        agent.Branch,
 			"tmux_window":   agent.TmuxWindow,
 			"task":          agent.Task,
 			"created_at":    agent.CreatedAt,
@@ -994,14 +1873,12 @@ func (d *Daemon) handleListAgents(req socket.Request) socket.Response {
 			}
 			detail["status"] = status
 
-			// Get current branch from worktree
-			branch := ""
+			// Prefer the branch currently checked out in the worktree
 			if agent.WorktreePath != "" {
 				if b, err := worktree.GetCurrentBranch(agent.WorktreePath); err == nil {
-					branch = b
+					detail["branch"] = b
 				}
 			}
-			detail["branch"] = branch
 
 			// Get message counts
 			msgManager := messages.NewManager(d.paths.MessagesDir)
@@ -1019,9 +1896,73 @@ func (d *Daemon) handleListAgents(req socket.Request) socket.Response {
 		agentDetails = append(agentDetails, detail)
 	}
 
+	if paged {
+		return socket.SuccessResponse(map[string]interface{}{
+			"agents": agentDetails,
+			"total":  total,
+		})
+	}
 	return socket.SuccessResponse(agentDetails)
 }
 
+// handleHeartbeat records that an agent is alive. Agents report through the
+// daemon so it stays the only writer of state.
+func (d *Daemon) handleHeartbeat(req socket.Request) socket.Response {
+	repoName, errResp, ok := getRequiredStringArg(req.Args, "repo", "repository name is required")
+	if !ok {
+		return errResp
+	}
+
+	agentName, errResp, ok := getRequiredStringArg(req.Args, "agent", "agent name is required")
+	if !ok {
+		return errResp
+	}
+
+	if err := d.state.Heartbeat(repoName, agentName); err != nil {
+		return errorResponse(err)
+	}
+
+	return socket.SuccessResponse(nil)
+}
+
+// handleSetAgentStatus records that an agent is blocked, or running again.
+// The daemon sets the other statuses itself as agents start and finish.
+func (d *Daemon) handleSetAgentStatus(req socket.Request) socket.Response {
+	repoName, errResp, ok := getRequiredStringArg(req.Args, "repo", "repository name is required")
+	if !ok {
+		return errResp
+	}
+
+	agentName, errResp, ok := getRequiredStringArg(req.Args, "agent", "agent name is required")
+	if !ok {
+		return errResp
+	}
+
+	statusStr, errResp, ok := getRequiredStringArg(req.Args, "status", "status is required (blocked or running)")
+	if !ok {
+		return errResp
+	}
+	status := state.AgentStatus(statusStr)
+	if status != state.AgentStatusBlocked && status != state.AgentStatusRunning {
+		return socket.CodedErrorResponse(socket.CodeBadRequest, "status must be blocked or running, got %q - report completion with complete_agent", statusStr)
+	}
+
+	agent, exists := d.state.GetAgent(repoName, agentName)
+	if !exists {
+		return socket.CodedErrorResponse(socket.CodeNotFound, "agent '%s' not found in repository '%s'", agentName, repoName)
+	}
+	if agent.Lifecycle() != state.AgentStatusActive {
+		return socket.CodedErrorResponse(socket.CodeConflict, "agent '%s' has already %s", agentName, agent.Lifecycle())
+	}
+
+	if err := d.state.SetAgentStatus(repoName, agentName, status); err != nil {
+		return errorResponse(err)
+	}
+
+	d.logger.Info("Agent %s/%s is %s", repoName, agentName, status)
+	return socket.SuccessResponse(nil)
+}
+
 // handleCompleteAgent marks an agent as ready for cleanup
 func (d *Daemon) handleCompleteAgent(req socket.Request) socket.Response {
 	repoName, errResp, ok := getRequiredStringArg(req.Args, "repo", "repository name is required")
@@ -1036,7 +1977,7 @@ func (d *Daemon) handleCompleteAgent(req socket.Request) socket.Response {
 
 	agent, exists := d.state.GetAgent(repoName, agentName)
 	if !exists {
-		return socket.ErrorResponse("agent '%s' not found in repository '%s' - check available agents with: multiclaude worker list --repo %s", agentName, repoName, repoName)
+		return socket.CodedErrorResponse(socket.CodeNotFound, "agent '%s' not found in repository '%s' - check available agents with: multiclaude worker list --repo %s", agentName, repoName, repoName)
 	}
 
 	// Mark as ready for cleanup
@@ -1051,10 +1992,11 @@ func (d *Daemon) handleCompleteAgent(req socket.Request) socket.Response {
 	}
 
 	if err := d.state.UpdateAgent(repoName, agentName, agent); err != nil {
-		return socket.ErrorResponse("%s", err.Error())
+		return errorResponse(err)
 	}
 
 	d.logger.Info("Agent %s/%s marked as ready for cleanup", repoName, agentName)
+	d.checkDrainComplete()
 
 	// Notify supervisor and merge-queue that worker or review agent completed
 	if agent.Type == state.AgentTypeWorker || agent.Type == state.AgentTypeReview {
@@ -1116,39 +2058,50 @@ func (d *Daemon) handleRestartAgent(req socket.Request) socket.Response {
 
 	agent, exists := d.state.GetAgent(repoName, agentName)
 	if !exists {
-		return socket.ErrorResponse("agent '%s' not found in repository '%s' - check available agents with: multiclaude worker list --repo %s", agentName, repoName, repoName)
+		return socket.CodedErrorResponse(socket.CodeNotFound, "agent '%s' not found in repository '%s' - check available agents with: multiclaude worker list --repo %s", agentName, repoName, repoName)
 	}
 
 	// Check if agent is marked for cleanup (completed)
 	if agent.ReadyForCleanup {
-		return socket.ErrorResponse("agent '%s' is marked as complete and pending cleanup - cannot restart a completed agent", agentName)
+		return socket.CodedErrorResponse(socket.CodeConflict, "agent '%s' is marked as complete and pending cleanup - cannot restart a completed agent", agentName)
 	}
 
 	// Check if tmux window exists
 	repo, exists := d.state.GetRepo(repoName)
 	if !exists {
-		return socket.ErrorResponse("repository '%s' not found in state", repoName)
+		return socket.CodedErrorResponse(socket.CodeNotFound, "repository '%s' not found in state", repoName)
 	}
 
 	hasWindow, err := d.tmux.HasWindow(d.ctx, repo.TmuxSession, agentName)
 	if err != nil {
-		return socket.ErrorResponse("failed to check tmux window: %v", err)
+		return socket.CodedErrorResponse(socket.CodeInternal, "failed to check tmux window: %v", err)
 	}
 	if !hasWindow {
-		return socket.ErrorResponse("tmux window '%s' does not exist - the agent may need to be recreated", agentName)
+		return socket.CodedErrorResponse(socket.CodeNotFound, "tmux window '%s' does not exist - the agent may need to be recreated", agentName)
 	}
 
 	// Check if agent is already running
-	if agent.PID > 0 && isProcessAlive(agent.PID) {
+	if agent.PID > 0 && procstat.IsAlive(agent.PID) {
 		if !force {
-			return socket.ErrorResponse("agent '%s' is already running with PID %d - use --force to restart anyway", agentName, agent.PID)
+			return socket.CodedErrorResponse(socket.CodeConflict, "agent '%s' is already running with PID %d - use --force to restart anyway", agentName, agent.PID)
 		}
 		d.logger.Info("Force restarting agent %s (PID %d was still running)", agentName, agent.PID)
 	}
 
+	release, err := d.acquireAgentLease(repoName, agentName, requestLeaseOwner(req), agentLeaseTTL)
+	if err != nil {
+		return errorResponse(err)
+	}
+	defer release()
+
 	// Restart the agent
 	if err := d.restartAgent(repoName, agentName, agent, repo); err != nil {
-		return socket.ErrorResponse("failed to restart agent: %v", err)
+		return socket.CodedErrorResponse(errorCode(err, socket.CodeInternal), "failed to restart agent: %v", err)
+	}
+
+	// A manual restart gives automatic restarts a fresh backoff schedule
+	if err := d.state.ResetRestarts(repoName, agentName); err != nil {
+		d.logger.Warn("Failed to reset restart count for agent %s: %v", agentName, err)
 	}
 
 	// Get updated PID from state
@@ -1161,14 +2114,368 @@ func (d *Daemon) handleRestartAgent(req socket.Request) socket.Response {
 	})
 }
 
-// handleTriggerCleanup manually triggers cleanup operations
+// handleKillAgent gracefully stops an agent's process and closes its window
+func (d *Daemon) handleKillAgent(req socket.Request) socket.Response {
+	repoName, errResp, ok := getRequiredStringArg(req.Args, "repo", "repository name is required")
+	if !ok {
+		return errResp
+	}
+
+	agentName, errResp, ok := getRequiredStringArg(req.Args, "agent", "agent name is required")
+	if !ok {
+		return errResp
+	}
+
+	grace := agent.DefaultGracePeriod
+	if graceStr := getOptionalStringArg(req.Args, "grace", ""); graceStr != "" {
+		parsed, err := time.ParseDuration(graceStr)
+		if err != nil || parsed < 0 {
+			return socket.CodedErrorResponse(socket.CodeBadRequest, "invalid grace period %q - use a duration like 10s or 1m", graceStr)
+		}
+		grace = parsed
+	}
+
+	if _, exists := d.state.GetAgent(repoName, agentName); !exists {
+		return socket.CodedErrorResponse(socket.CodeNotFound, "agent '%s' not found in repository '%s' - check available agents with: multiclaude worker list --repo %s", agentName, repoName, repoName)
+	}
+
+	dryRun := getOptionalBoolArg(req.Args, "dry_run", false)
+	if dryRun {
+		actions, err := agent.NewManager(d.state, d.tmux).Kill(repoName, agentName, agent.KillOptions{Grace: grace, DryRun: true})
+		if err != nil {
+			return socket.CodedErrorResponse(errorCode(err, socket.CodeInternal), "failed to plan kill: %v", err)
+		}
+		return socket.SuccessResponse(map[string]interface{}{
+			"agent":   agentName,
+			"repo":    repoName,
+			"dry_run": true,
+			"actions": actions,
+			"message": fmt.Sprintf("Agent '%s' would be killed", agentName),
+		})
+	}
+
+	release, err := d.acquireAgentLease(repoName, agentName, requestLeaseOwner(req), grace+agentLeaseTTL)
+	if err != nil {
+		return errorResponse(err)
+	}
+	defer release()
+
+	d.logger.Info("Killing agent %s in repo %s (grace %s)", agentName, repoName, grace)
+	actions, err := agent.NewManager(d.state, d.tmux).Kill(repoName, agentName, agent.KillOptions{Grace: grace})
+	if err != nil {
+		return socket.CodedErrorResponse(errorCode(err, socket.CodeInternal), "failed to kill agent: %v", err)
+	}
+
+	// Let the health check record history and clean up the worktree
+	go d.checkAgentHealth()
+
+	return socket.SuccessResponse(map[string]interface{}{
+		"agent":   agentName,
+		"repo":    repoName,
+		"dry_run": false,
+		"actions": actions,
+		"message": fmt.Sprintf("Agent '%s' killed", agentName),
+	})
+}
+
+// defaultLogLines is how many lines of history the logs stream sends by default
+const defaultLogLines = 100
+
+// handleLogsStream streams an agent's captured output to the client. Without
+// follow it sends the last lines of the capture file and ends; with follow it
+// keeps sending new lines as they are written until the client disconnects.
+func (d *Daemon) handleLogsStream(ctx context.Context, req socket.Request, send func(socket.Response) error) error {
+	if resp, ok := d.authorize(req); !ok {
+		return fmt.Errorf("%s", resp.Error)
+	}
+
+	repoName, errResp, ok := getRequiredStringArg(req.Args, "repo", "repository name is required")
+	if !ok {
+		return fmt.Errorf("%s", errResp.Error)
+	}
+
+	agentName, errResp, ok := getRequiredStringArg(req.Args, "agent", "agent name is required")
+	if !ok {
+		return fmt.Errorf("%s", errResp.Error)
+	}
+
+	follow := getOptionalBoolArg(req.Args, "follow", false)
+	lines := getOptionalIntArg(req.Args, "lines", defaultLogLines)
+
+	// Stop streaming when the daemon shuts down as well as on disconnect
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(d.ctx, cancel)
+	defer stop()
+
+	emit := func(line string) error {
+		return send(socket.SuccessResponse(line))
+	}
+
+	outputMgr := output.NewManager(d.paths.OutputDir)
+	if _, err := outputMgr.FindLogPath(repoName, agentName); err != nil {
+		// An agent that exists but hasn't produced output yet is worth waiting
+		// for when following; anything else is an error
+		if _, exists := d.state.GetAgent(repoName, agentName); !exists {
+			return fmt.Errorf("agent '%s' not found in repository '%s' and has no captured output", agentName, repoName)
+		}
+		if !follow {
+			return fmt.Errorf("no output captured yet for agent '%s' - use follow to wait for it", agentName)
+		}
+	}
+
+	if !follow {
+		tail, err := outputMgr.Tail(repoName, agentName, lines)
+		if err != nil {
+			return err
+		}
+		for _, line := range tail {
+			if err := emit(line); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err := outputMgr.Follow(ctx, repoName, agentName, lines, emit)
+	if ctx.Err() != nil {
+		// Disconnect or shutdown is the normal way a follow ends
+		return nil
+	}
+	return err
+}
+
+// handleDaemonLogsStream streams the daemon's own log file. It behaves like
+// the logs stream: the last lines are sent, and with follow new lines keep
+// coming until the client disconnects.
+func (d *Daemon) handleDaemonLogsStream(ctx context.Context, req socket.Request, send func(socket.Response) error) error {
+	if resp, ok := d.authorize(req); !ok {
+		return fmt.Errorf("%s", resp.Error)
+	}
+
+	follow := getOptionalBoolArg(req.Args, "follow", false)
+	lines := getOptionalIntArg(req.Args, "lines", defaultLogLines)
+
+	// Stop streaming when the daemon shuts down as well as on disconnect
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(d.ctx, cancel)
+	defer stop()
+
+	emit := func(line string) error {
+		return send(socket.SuccessResponse(line))
+	}
+
+	if !follow {
+		tail, err := output.TailFile(d.paths.DaemonLog, lines)
+		if err != nil {
+			return err
+		}
+		for _, line := range tail {
+			if err := emit(line); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err := output.FollowFile(ctx, d.paths.DaemonLog, lines, emit)
+	if ctx.Err() != nil {
+		// Disconnect or shutdown is the normal way a follow ends
+		return nil
+	}
+	return err
+}
+
+// handleTriggerCleanup removes dead agents, orphaned tmux sessions and
+// worktrees, and acked messages, returning a report of what was removed.
+// With dry_run set it only reports the candidates.
 func (d *Daemon) handleTriggerCleanup(req socket.Request) socket.Response {
-	d.logger.Info("Manual cleanup triggered")
+	dryRun := getOptionalBoolArg(req.Args, "dry_run", false)
+	d.logger.Info("Manual cleanup triggered (dry run: %v)", dryRun)
 
-	// Run health check to find dead agents
-	d.checkAgentHealth()
+	c := cleanup.New(d.state, d.tmux, d.paths)
+	c.Concurrency = getOptionalIntArg(req.Args, "concurrency", 0)
+	c.OnRemoveAgent = func(repoName, agentName string, agent state.Agent) {
+		d.syncTranscript(repoName, agentName, agent)
+		if agent.Type == state.AgentTypeWorker {
+			d.recordTaskHistory(repoName, agentName, agent)
+		}
+	}
+
+	// Individual failures are listed in the report rather than failing the
+	// whole cleanup
+	report, _ := c.Run(d.ctx, dryRun)
+	for _, e := range report.Errors {
+		d.logger.Warn("Cleanup: %s", e)
+	}
+	if !dryRun {
+		d.logger.Info("Cleanup removed %d resources", report.Total())
+	}
+
+	return socket.SuccessResponse(report)
+}
+
+// handleListOrphans lists everything that looks left behind without
+// removing any of it
+func (d *Daemon) handleListOrphans(req socket.Request) socket.Response {
+	orphans, err := cleanup.New(d.state, d.tmux, d.paths).FindOrphans(d.ctx)
+	if err != nil {
+		return socket.CodedErrorResponse(socket.CodeInternal, "failed to list orphans: %v", err)
+	}
+	return socket.SuccessResponse(orphans)
+}
+
+// handleSyncFork fetches a fork's sync target, "upstream" unless the request
+// or fork config names another remote, and brings the branch checked out in
+// the repository directory up to date, fast-forwarding when it can and
+// merging when the branch has diverged
+func (d *Daemon) handleSyncFork(req socket.Request) socket.Response {
+	repoName, errResp, ok := getRequiredStringArg(req.Args, "repo", "repository name is required")
+	if !ok {
+		return errResp
+	}
+
+	forkConfig, err := d.state.GetForkConfig(repoName)
+	if err != nil {
+		return errorResponse(err)
+	}
+	if !forkConfig.IsFork && !forkConfig.ForceForkMode {
+		return socket.CodedErrorResponse(socket.CodeConflict, "repository %q is not a fork", repoName)
+	}
+
+	repoPath := d.paths.RepoDir(repoName)
+	remoteName := getOptionalStringArg(req.Args, "remote", forkConfig.SyncRemote)
+	if (remoteName == "" || remoteName == fork.UpstreamRemoteName) && !fork.HasUpstreamRemote(repoPath) {
+		if forkConfig.UpstreamURL == "" {
+			return socket.CodedErrorResponse(socket.CodeConflict, "repository %q has no upstream remote", repoName)
+		}
+		if err := fork.AddUpstreamRemote(repoPath, forkConfig.UpstreamURL); err != nil {
+			return socket.CodedErrorResponse(socket.CodeInternal, "failed to add upstream remote: %v", err)
+		}
+	}
+
+	info := &fork.ForkInfo{Remotes: fork.ListRemotes(repoPath)}
+	target, err := info.SyncTarget(remoteName)
+	if err != nil {
+		return socket.CodedErrorResponse(socket.CodeConflict, "cannot sync %s: %v", repoName, err)
+	}
+
+	result, err := fork.SyncWithUpstream(repoPath, target.Name, "")
+	if err != nil {
+		return socket.CodedErrorResponse(errorCode(err, socket.CodeInternal), "failed to sync %s: %v", repoName, err)
+	}
+	if result.Status == fork.SyncDiverged {
+		// Local commits on both sides; merge rather than leave it behind
+		if result, err = fork.MergeUpstream(repoPath, target.Name); err != nil {
+			return socket.CodedErrorResponse(errorCode(err, socket.CodeInternal), "failed to sync %s: %v", repoName, err)
+		}
+	}
+
+	if result.Conflicts {
+		d.logger.Warn("Syncing %s with %s conflicted; %s left unchanged", repoName, target.Name, result.Branch)
+	} else {
+		d.logger.Info("Synced %s with %s: %d commit(s) behind, %d ahead", repoName, target.Name, result.Behind, result.Ahead)
+	}
+	return socket.SuccessResponse(result)
+}
+
+// handleHistory returns the most recent socket commands, oldest first
+func (d *Daemon) handleHistory(req socket.Request) socket.Response {
+	limit := getOptionalIntArg(req.Args, "limit", 0)
+	if limit < 0 {
+		return socket.CodedErrorResponse(socket.CodeBadRequest, "invalid limit: must be a non-negative integer")
+	}
+	return socket.SuccessResponse(d.history.Recent(limit))
+}
+
+// handleCompact archives old command history entries. With neither max_age
+// nor keep, it archives everything beyond the newest half of what the
+// history keeps in memory.
+func (d *Daemon) handleCompact(req socket.Request) socket.Response {
+	opts := history.CompactOptions{
+		Keep:        getOptionalIntArg(req.Args, "keep", 0),
+		Summarize:   getOptionalBoolArg(req.Args, "summarize", false),
+		MaxArchives: getOptionalIntArg(req.Args, "max_archives", 0),
+	}
+	if opts.Keep < 0 {
+		return socket.CodedErrorResponse(socket.CodeBadRequest, "invalid keep: must be a non-negative integer")
+	}
+	if opts.MaxArchives < 0 {
+		return socket.CodedErrorResponse(socket.CodeBadRequest, "invalid max_archives: must be a non-negative integer")
+	}
+	if ageStr := getOptionalStringArg(req.Args, "max_age", ""); ageStr != "" {
+		parsed, err := time.ParseDuration(ageStr)
+		if err != nil || parsed <= 0 {
+			return socket.CodedErrorResponse(socket.CodeBadRequest, "invalid max_age %q - use a duration like 24h or 168h", ageStr)
+		}
+		opts.MaxAge = parsed
+	}
+	if opts.MaxAge == 0 && opts.Keep == 0 {
+		opts.Keep = history.DefaultSize / 2
+	}
+
+	result, err := d.history.Compact(opts)
+	if err != nil {
+		return socket.CodedErrorResponse(socket.CodeInternal, "failed to compact history: %v", err)
+	}
+	if result.Archived > 0 {
+		d.logger.Info("Compacted history: archived %d entries to %s, pruned %d old archives", result.Archived, result.Archive, result.Pruned)
+	}
+	return socket.SuccessResponse(result)
+}
+
+// handleSelfTest creates, verifies, and removes a throwaway agent. It runs
+// against a scratch multiclaude root, so the real state and files are never
+// touched. The report lists each step; a failed step does not fail the
+// command.
+func (d *Daemon) handleSelfTest(req socket.Request) socket.Response {
+	root, err := os.MkdirTemp("", "multiclaude-selftest-home-")
+	if err != nil {
+		return socket.CodedErrorResponse(socket.CodeInternal, "failed to create self-test root: %v", err)
+	}
+	defer os.RemoveAll(root)
+	paths := config.PathsAt(root)
+	if err := paths.EnsureDirectories(); err != nil {
+		return socket.CodedErrorResponse(socket.CodeInternal, "failed to create self-test root: %v", err)
+	}
 
-	return socket.SuccessResponse("Cleanup triggered")
+	worktrees := selftest.GitWorktrees{Runner: command.ExecRunner{Timeout: command.DefaultTimeout}}
+	report := selftest.New(state.New(paths.StateFile), d.tmux, worktrees, paths.Root).Run(d.ctx)
+	if !report.OK {
+		d.logger.Warn("Self-test failed: %+v", report.Steps)
+	}
+	return socket.SuccessResponse(report)
+}
+
+// handleDump returns the whole state, with secrets redacted, optionally
+// gzip-compressed
+func (d *Daemon) handleDump(req socket.Request) socket.Response {
+	data, err := d.state.Export()
+	if err != nil {
+		return socket.CodedErrorResponse(socket.CodeInternal, "failed to export state: %v", err)
+	}
+
+	if !getOptionalBoolArg(req.Args, "compress", false) {
+		return socket.SuccessResponse(map[string]interface{}{
+			"encoding": "json",
+			"state":    json.RawMessage(data),
+		})
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return socket.CodedErrorResponse(socket.CodeInternal, "failed to compress state: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		return socket.CodedErrorResponse(socket.CodeInternal, "failed to compress state: %v", err)
+	}
+	// []byte is sent as base64 in the JSON response
+	return socket.SuccessResponse(map[string]interface{}{
+		"encoding": "gzip",
+		"state":    buf.Bytes(),
+	})
 }
 
 // handleTriggerRefresh manually triggers worktree refresh for all agents
@@ -1181,12 +2488,67 @@ func (d *Daemon) handleTriggerRefresh(req socket.Request) socket.Response {
 	return socket.SuccessResponse("Worktree refresh triggered")
 }
 
+// handlePruneWorktrees prunes git worktree entries whose directories no
+// longer exist, for one repository or all of them
+func (d *Daemon) handlePruneWorktrees(req socket.Request) socket.Response {
+	dryRun := getOptionalBoolArg(req.Args, "dry_run", false)
+
+	repoNames := d.state.ListRepos()
+	if repoName := getOptionalStringArg(req.Args, "repo", ""); repoName != "" {
+		if _, exists := d.state.GetRepo(repoName); !exists {
+			return socket.CodedErrorResponse(socket.CodeNotFound, "repository '%s' not found - list tracked repos with: multiclaude repo list", repoName)
+		}
+		repoNames = []string{repoName}
+	}
+
+	pruned := make(map[string][]string)
+	for _, repoName := range repoNames {
+		paths, err := worktree.RepairWorktrees(d.paths.RepoDir(repoName), dryRun)
+		if err != nil {
+			return socket.CodedErrorResponse(socket.CodeInternal, "failed to prune worktrees for %s: %v", repoName, err)
+		}
+		if len(paths) == 0 {
+			continue
+		}
+		pruned[repoName] = paths
+		if !dryRun {
+			for _, path := range paths {
+				d.logger.Info("Pruned stale worktree entry: %s", path)
+			}
+		}
+	}
+
+	return socket.SuccessResponse(map[string]interface{}{
+		"dry_run": dryRun,
+		"pruned":  pruned,
+	})
+}
+
+// handleReconcile updates state to match the agents' processes, windows,
+// and worktrees
+func (d *Daemon) handleReconcile(req socket.Request) socket.Response {
+	dryRun := getOptionalBoolArg(req.Args, "dry_run", false)
+	d.logger.Info("Reconcile triggered (dry run: %v)", dryRun)
+
+	report := reconcile.New(d.state, d.tmux, d.paths.Root).Run(d.ctx, dryRun)
+	for _, e := range report.Errors {
+		d.logger.Warn("Reconcile: %s", e)
+	}
+	if !dryRun {
+		for _, change := range report.Changes {
+			d.logger.Info("Reconciled %s", change)
+		}
+	}
+
+	return socket.SuccessResponse(report)
+}
+
 // handleRepairState repairs state inconsistencies
 func (d *Daemon) handleRepairState(req socket.Request) socket.Response {
 	d.logger.Info("State repair triggered")
 
-	agentsRemoved := 0
-	issuesFixed := 0
+	// Remove worktree symlinks pointing nowhere, and the agents using them
+	agentsRemoved, issuesFixed := d.repairBrokenWorktreeSymlinks()
 
 	// Get a snapshot of repos to avoid concurrent map access
 	repos := d.state.GetAllRepos()
@@ -1234,6 +2596,19 @@ func (d *Daemon) handleRepairState(req socket.Request) socket.Response {
 		}
 	}
 
+	// Prune git worktree entries whose directories were deleted
+	for repoName := range repos {
+		pruned, err := worktree.RepairWorktrees(d.paths.RepoDir(repoName), false)
+		if err != nil {
+			d.logger.Warn("Failed to repair worktrees for %s: %v", repoName, err)
+			continue
+		}
+		for _, path := range pruned {
+			d.logger.Info("Pruned stale worktree entry: %s", path)
+		}
+		issuesFixed += len(pruned)
+	}
+
 	// Clean up orphaned worktrees
 	d.cleanupOrphanedWorktrees()
 
@@ -1255,6 +2630,44 @@ func (d *Daemon) handleRepairState(req socket.Request) socket.Response {
 	})
 }
 
+// repairBrokenWorktreeSymlinks removes worktree paths that are symlinks to
+// missing targets, along with any agent in state whose worktree is one.
+// Returns the number of agents removed and links fixed.
+func (d *Daemon) repairBrokenWorktreeSymlinks() (agentsRemoved, linksRemoved int) {
+	for repoName, repo := range d.state.GetAllRepos() {
+		owners := make(map[string]string) // worktree path -> agent name
+		var agentPaths []string
+		for agentName, agent := range repo.Agents {
+			if agent.WorktreePath != "" {
+				owners[agent.WorktreePath] = agentName
+				agentPaths = append(agentPaths, agent.WorktreePath)
+			}
+		}
+
+		broken, err := worktree.FindBrokenSymlinks(d.paths.WorktreeDir(repoName), agentPaths...)
+		if err != nil {
+			d.logger.Warn("Failed to check worktree symlinks for %s: %v", repoName, err)
+			continue
+		}
+
+		for _, link := range broken {
+			d.logger.Warn("Worktree %s is a broken symlink to %s, removing", link.Path, link.Target)
+			if err := worktree.RemoveBrokenSymlink(link.Path); err != nil {
+				d.logger.Error("Failed to remove broken symlink %s: %v", link.Path, err)
+				continue
+			}
+			linksRemoved++
+
+			if agentName, ok := owners[link.Path]; ok {
+				if err := d.state.RemoveAgent(repoName, agentName); err == nil {
+					agentsRemoved++
+				}
+			}
+		}
+	}
+	return agentsRemoved, linksRemoved
+}
+
 // handleGetRepoConfig returns the configuration for a repository
 func (d *Daemon) handleGetRepoConfig(req socket.Request) socket.Response {
 	name, errResp, ok := getRequiredStringArg(req.Args, "name", "repository name is required")
@@ -1264,7 +2677,7 @@ func (d *Daemon) handleGetRepoConfig(req socket.Request) socket.Response {
 
 	repo, exists := d.state.GetRepo(name)
 	if !exists {
-		return socket.ErrorResponse("repository %q not found", name)
+		return socket.CodedErrorResponse(socket.CodeNotFound, "repository %q not found", name)
 	}
 
 	// Get merge queue config (use default if not set for backward compatibility)
@@ -1282,16 +2695,24 @@ func (d *Daemon) handleGetRepoConfig(req socket.Request) socket.Response {
 	// Get fork config
 	forkConfig := repo.ForkConfig
 
+	// Get spawn limits (zero means the limit is off)
+	spawnConfig := repo.SpawnLimitConfig
+
 	return socket.SuccessResponse(map[string]interface{}{
-		"mq_enabled":      mqConfig.Enabled,
-		"mq_track_mode":   string(mqConfig.TrackMode),
-		"ps_enabled":      psConfig.Enabled,
-		"ps_track_mode":   string(psConfig.TrackMode),
-		"is_fork":         forkConfig.IsFork,
-		"upstream_url":    forkConfig.UpstreamURL,
-		"upstream_owner":  forkConfig.UpstreamOwner,
-		"upstream_repo":   forkConfig.UpstreamRepo,
-		"force_fork_mode": forkConfig.ForceForkMode,
+		"mq_enabled":        mqConfig.Enabled,
+		"mq_track_mode":     string(mqConfig.TrackMode),
+		"mq_max_retries":    mqConfig.Retries(),
+		"ps_enabled":        psConfig.Enabled,
+		"ps_track_mode":     string(psConfig.TrackMode),
+		"spawn_max_workers": spawnConfig.MaxWorkers,
+		"spawn_per_minute":  spawnConfig.SpawnsPerMinute,
+		"spawn_burst":       spawnConfig.Burst,
+		"is_fork":           forkConfig.IsFork,
+		"upstream_url":      forkConfig.UpstreamURL,
+		"upstream_owner":    forkConfig.UpstreamOwner,
+		"upstream_repo":     forkConfig.UpstreamRepo,
+		"force_fork_mode":   forkConfig.ForceForkMode,
+		"claude_config":     repo.ClaudeConfig,
 	})
 }
 
@@ -1305,7 +2726,7 @@ func (d *Daemon) handleUpdateRepoConfig(req socket.Request) socket.Response {
 	// Get current merge queue config
 	currentMQConfig, err := d.state.GetMergeQueueConfig(name)
 	if err != nil {
-		return socket.ErrorResponse("%s", err.Error())
+		return errorResponse(err)
 	}
 
 	// Update merge queue config with provided values
@@ -1317,15 +2738,27 @@ func (d *Daemon) handleUpdateRepoConfig(req socket.Request) socket.Response {
 	if mqTrackMode := getOptionalStringArg(req.Args, "mq_track_mode", ""); mqTrackMode != "" {
 		mode, err := state.ParseTrackMode(mqTrackMode)
 		if err != nil {
-			return socket.ErrorResponse("%s", err.Error())
+			return socket.CodedErrorResponse(socket.CodeBadRequest, "%v", err)
 		}
 		currentMQConfig.TrackMode = mode
 		mqUpdated = true
 	}
+	if _, hasMaxRetries := req.Args["mq_max_retries"]; hasMaxRetries {
+		maxRetries := getOptionalIntArg(req.Args, "mq_max_retries", -1)
+		if maxRetries < 0 {
+			return socket.CodedErrorResponse(socket.CodeBadRequest, "invalid mq_max_retries: must be a non-negative integer")
+		}
+		// Zero is stored as -1 so it is not mistaken for "use the default"
+		if maxRetries == 0 {
+			maxRetries = -1
+		}
+		currentMQConfig.MaxRetries = maxRetries
+		mqUpdated = true
+	}
 
 	if mqUpdated {
 		if err := d.state.UpdateMergeQueueConfig(name, currentMQConfig); err != nil {
-			return socket.ErrorResponse("%s", err.Error())
+			return errorResponse(err)
 		}
 		d.logger.Info("Updated merge queue config for repo %s: enabled=%v, track=%s", name, currentMQConfig.Enabled, currentMQConfig.TrackMode)
 	}
@@ -1333,7 +2766,7 @@ func (d *Daemon) handleUpdateRepoConfig(req socket.Request) socket.Response {
 	// Get current PR shepherd config
 	currentPSConfig, err := d.state.GetPRShepherdConfig(name)
 	if err != nil {
-		return socket.ErrorResponse("%s", err.Error())
+		return errorResponse(err)
 	}
 
 	// Update PR shepherd config with provided values
@@ -1345,7 +2778,7 @@ func (d *Daemon) handleUpdateRepoConfig(req socket.Request) socket.Response {
 	if psTrackMode := getOptionalStringArg(req.Args, "ps_track_mode", ""); psTrackMode != "" {
 		mode, err := state.ParseTrackMode(psTrackMode)
 		if err != nil {
-			return socket.ErrorResponse("%s", err.Error())
+			return socket.CodedErrorResponse(socket.CodeBadRequest, "%v", err)
 		}
 		currentPSConfig.TrackMode = mode
 		psUpdated = true
@@ -1353,9 +2786,66 @@ func (d *Daemon) handleUpdateRepoConfig(req socket.Request) socket.Response {
 
 	if psUpdated {
 		if err := d.state.UpdatePRShepherdConfig(name, currentPSConfig); err != nil {
-			return socket.ErrorResponse("%s", err.Error())
+			return errorResponse(err)
+		}
+		d.logger.Info("Updated PR shepherd config for repo %s: enabled=%v, track=%s", name, currentPSConfig.Enabled, currentPSConfig.TrackMode)
+	}
+
+	// Get current spawn limits
+	currentSpawnConfig, err := d.state.GetSpawnLimitConfig(name)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	// Update spawn limits with provided values; -1 disables a limit
+	spawnUpdated := false
+	if _, ok := req.Args["spawn_max_workers"]; ok {
+		currentSpawnConfig.MaxWorkers = getOptionalIntArg(req.Args, "spawn_max_workers", currentSpawnConfig.MaxWorkers)
+		spawnUpdated = true
+	}
+	if rate, ok := req.Args["spawn_per_minute"].(float64); ok {
+		currentSpawnConfig.SpawnsPerMinute = rate
+		spawnUpdated = true
+	}
+	if _, ok := req.Args["spawn_burst"]; ok {
+		currentSpawnConfig.Burst = getOptionalIntArg(req.Args, "spawn_burst", currentSpawnConfig.Burst)
+		spawnUpdated = true
+	}
+
+	if spawnUpdated {
+		if err := d.state.UpdateSpawnLimitConfig(name, currentSpawnConfig); err != nil {
+			return errorResponse(err)
+		}
+		d.logger.Info("Updated spawn limits for repo %s: max_workers=%d, per_minute=%g, burst=%d", name, currentSpawnConfig.MaxWorkers, currentSpawnConfig.SpawnsPerMinute, currentSpawnConfig.Burst)
+	}
+
+	// Update claude invocation for one agent type; only given fields change
+	if typeArg := getOptionalStringArg(req.Args, "claude_agent_type", ""); typeArg != "" {
+		agentType, err := state.ParseAgentType(typeArg)
+		if err != nil {
+			return socket.CodedErrorResponse(socket.CodeBadRequest, "%v", err)
+		}
+		claudeConfig, err := d.state.GetClaudeConfig(name, agentType)
+		if err != nil {
+			return errorResponse(err)
+		}
+		if binary, ok := req.Args["claude_binary"].(string); ok {
+			claudeConfig.BinaryPath = binary
+		}
+		if model, ok := req.Args["claude_model"].(string); ok {
+			claudeConfig.Model = model
+		}
+		if _, ok := req.Args["claude_args"]; ok {
+			extraArgs, err := getStringSliceArg(req.Args, "claude_args")
+			if err != nil {
+				return socket.CodedErrorResponse(socket.CodeBadRequest, "%v", err)
+			}
+			claudeConfig.ExtraArgs = extraArgs
 		}
-		d.logger.Info("Updated PR shepherd config for repo %s: enabled=%v, track=%s", name, currentPSConfig.Enabled, currentPSConfig.TrackMode)
+		if err := d.state.UpdateClaudeConfig(name, agentType, claudeConfig); err != nil {
+			return errorResponse(err)
+		}
+		d.logger.Info("Updated claude config for %s agents in repo %s: binary=%q, model=%q, args=%v", agentType, name, claudeConfig.BinaryPath, claudeConfig.Model, claudeConfig.ExtraArgs)
 	}
 
 	return socket.SuccessResponse(nil)
@@ -1369,7 +2859,7 @@ func (d *Daemon) handleSetCurrentRepo(req socket.Request) socket.Response {
 	}
 
 	if err := d.state.SetCurrentRepo(name); err != nil {
-		return socket.ErrorResponse("%s", err.Error())
+		return errorResponse(err)
 	}
 
 	d.logger.Info("Set current repository to: %s", name)
@@ -1380,7 +2870,7 @@ func (d *Daemon) handleSetCurrentRepo(req socket.Request) socket.Response {
 func (d *Daemon) handleGetCurrentRepo(req socket.Request) socket.Response {
 	currentRepo := d.state.GetCurrentRepo()
 	if currentRepo == "" {
-		return socket.ErrorResponse("no current repository set")
+		return socket.CodedErrorResponse(socket.CodeNotFound, "no current repository set")
 	}
 	return socket.SuccessResponse(currentRepo)
 }
@@ -1388,7 +2878,7 @@ func (d *Daemon) handleGetCurrentRepo(req socket.Request) socket.Response {
 // handleClearCurrentRepo clears the current/default repository
 func (d *Daemon) handleClearCurrentRepo(req socket.Request) socket.Response {
 	if err := d.state.ClearCurrentRepo(); err != nil {
-		return socket.ErrorResponse("%s", err.Error())
+		return errorResponse(err)
 	}
 
 	d.logger.Info("Cleared current repository")
@@ -1441,12 +2931,15 @@ func (d *Daemon) cleanupDeadAgents(deadAgents map[string][]string) {
 				}
 			}
 
-			// Clean up message directory
+			// Clean up message directory and environment file
 			msgMgr := d.getMessageManager()
 			validAgents, _ := d.state.ListAgents(repoName)
 			if _, err := msgMgr.CleanupOrphaned(repoName, validAgents); err != nil {
 				d.logger.Warn("Failed to cleanup orphaned messages for %s: %v", repoName, err)
 			}
+			if _, err := claude.CleanupEnvFiles(d.paths.RepoEnvDir(repoName), validAgents); err != nil {
+				d.logger.Warn("Failed to cleanup environment files for %s: %v", repoName, err)
+			}
 		}
 	}
 }
@@ -1503,7 +2996,7 @@ func (d *Daemon) handleTaskHistory(req socket.Request) socket.Response {
 
 	history, err := d.state.GetTaskHistory(repoName, limit)
 	if err != nil {
-		return socket.ErrorResponse("%s", err.Error())
+		return errorResponse(err)
 	}
 
 	// Convert to interface slice for JSON serialization
@@ -1526,6 +3019,130 @@ func (d *Daemon) handleTaskHistory(req socket.Request) socket.Response {
 	return socket.SuccessResponse(result)
 }
 
+// handleMergeQueue returns the repository's merge queue, head first
+func (d *Daemon) handleMergeQueue(req socket.Request) socket.Response {
+	repoName, errResp, ok := getRequiredStringArg(req.Args, "repo", "repository name is required")
+	if !ok {
+		return errResp
+	}
+
+	queue, err := d.state.PeekQueue(repoName)
+	if err != nil {
+		return errorResponse(err)
+	}
+	return socket.SuccessResponse(queue)
+}
+
+// handleEnqueueMerge adds a PR to the back of the repository's merge queue
+func (d *Daemon) handleEnqueueMerge(req socket.Request) socket.Response {
+	repoName, errResp, ok := getRequiredStringArg(req.Args, "repo", "repository name is required")
+	if !ok {
+		return errResp
+	}
+	prNumber := getOptionalIntArg(req.Args, "pr_number", 0)
+	if prNumber <= 0 {
+		return socket.CodedErrorResponse(socket.CodeBadRequest, "pr_number must be a positive integer")
+	}
+
+	entry := state.MergeQueueEntry{
+		PRNumber: prNumber,
+		PRURL:    getOptionalStringArg(req.Args, "pr_url", ""),
+		Branch:   getOptionalStringArg(req.Args, "branch", ""),
+	}
+	if err := d.state.EnqueueMerge(repoName, entry); err != nil {
+		return errorResponse(err)
+	}
+
+	d.logger.Info("Queued PR #%d for merge in %s", prNumber, repoName)
+	return socket.SuccessResponse(fmt.Sprintf("PR #%d queued for merge", prNumber))
+}
+
+// handleClaimMerge claims the head of the merge queue for the calling agent.
+// Data is null when there is nothing to claim.
+func (d *Daemon) handleClaimMerge(req socket.Request) socket.Response {
+	repoName, errResp, ok := getRequiredStringArg(req.Args, "repo", "repository name is required")
+	if !ok {
+		return errResp
+	}
+	claimant, errResp, ok := getRequiredStringArg(req.Args, "agent", "agent name is required")
+	if !ok {
+		return errResp
+	}
+
+	entry, claimed, err := d.state.ClaimMergeHead(repoName, claimant)
+	if err != nil {
+		return errorResponse(err)
+	}
+	if !claimed {
+		return socket.SuccessResponse(nil)
+	}
+	return socket.SuccessResponse(entry)
+}
+
+// handleCompleteMerge finishes the claimed head of the merge queue. A
+// failure_reason marks the merge as failed, which requeues it while it has
+// retries left.
+func (d *Daemon) handleCompleteMerge(req socket.Request) socket.Response {
+	repoName, errResp, ok := getRequiredStringArg(req.Args, "repo", "repository name is required")
+	if !ok {
+		return errResp
+	}
+	prNumber := getOptionalIntArg(req.Args, "pr_number", 0)
+	if prNumber <= 0 {
+		return socket.CodedErrorResponse(socket.CodeBadRequest, "pr_number must be a positive integer")
+	}
+	failureReason := getOptionalStringArg(req.Args, "failure_reason", "")
+
+	requeued, err := d.state.CompleteMerge(repoName, prNumber, failureReason)
+	if err != nil {
+		return errorResponse(err)
+	}
+	return socket.SuccessResponse(map[string]interface{}{
+		"pr_number": prNumber,
+		"requeued":  requeued,
+	})
+}
+
+// acquireSpawn enforces the repository's spawn limits before a new agent of
+// agentType is started. Only workers are limited. The returned release must
+// be called once the agent is registered in state or its spawn has failed.
+// Returns an error matching agent.ErrSpawnLimit if a limit is hit.
+func (d *Daemon) acquireSpawn(repoName string, agentType state.AgentType) (func(), error) {
+	if agentType != state.AgentTypeWorker {
+		return func() {}, nil
+	}
+
+	limits, err := d.spawnLimits(repoName)
+	if err != nil {
+		return nil, err
+	}
+
+	activeWorkers := func() int {
+		return agent.ActiveWorkers(d.state.GetAllRepos()[repoName].Agents)
+	}
+	release, err := d.spawnLimiter.Acquire(repoName, limits, activeWorkers)
+	if err != nil {
+		d.logger.Warn("Rejected worker spawn: %v", err)
+		return nil, err
+	}
+	return release, nil
+}
+
+// spawnLimits returns the repository's spawn limits, or the config file
+// defaults if the repository sets none of its own
+func (d *Daemon) spawnLimits(repoName string) (state.SpawnLimitConfig, error) {
+	limits, err := d.state.GetSpawnLimitConfig(repoName)
+	if err != nil || limits != (state.SpawnLimitConfig{}) {
+		return limits, err
+	}
+	cfg := d.userConfig.Load()
+	return state.SpawnLimitConfig{
+		MaxWorkers:      cfg.MaxWorkers,
+		SpawnsPerMinute: cfg.SpawnsPerMinute,
+		Burst:           cfg.SpawnBurst,
+	}, nil
+}
+
 // handleSpawnAgent spawns a new agent with an inline prompt (no hardcoded type).
 // This is used by the supervisor to spawn agents based on markdown definitions.
 // Args:
@@ -1555,23 +3172,32 @@ func (d *Daemon) handleSpawnAgent(req socket.Request) socket.Response {
 		return errResp
 	}
 
+	if err := names.ValidateAgentName(agentName); err != nil {
+		return socket.CodedErrorResponse(socket.CodeBadRequest, "%v", err)
+	}
+
 	// Validate class
 	if agentClass != "persistent" && agentClass != "ephemeral" {
-		return socket.ErrorResponse("invalid agent class %q: must be 'persistent' or 'ephemeral'", agentClass)
+		return socket.CodedErrorResponse(socket.CodeBadRequest, "invalid agent class %q: must be 'persistent' or 'ephemeral'", agentClass)
 	}
 
 	// Get optional task
 	task := getOptionalStringArg(req.Args, "task", "")
 
+	env, err := getOptionalEnvArg(req.Args, "env")
+	if err != nil {
+		return socket.CodedErrorResponse(socket.CodeBadRequest, "%v", err)
+	}
+
 	// Get repository
 	repo, exists := d.state.GetRepo(repoName)
 	if !exists {
-		return socket.ErrorResponse("repository %q not found", repoName)
+		return socket.CodedErrorResponse(socket.CodeNotFound, "repository %q not found", repoName)
 	}
 
 	// Check if agent already exists
 	if _, exists := d.state.GetAgent(repoName, agentName); exists {
-		return socket.ErrorResponse("agent %q already exists in repository %q", agentName, repoName)
+		return socket.CodedErrorResponse(socket.CodeConflict, "agent %q already exists in repository %q", agentName, repoName)
 	}
 
 	// Determine agent type based on class
@@ -1597,6 +3223,16 @@ func (d *Daemon) handleSpawnAgent(req socket.Request) socket.Response {
 		}
 	}
 
+	if d.draining.Load() {
+		return errorResponse(ErrDraining)
+	}
+
+	release, err := d.acquireSpawn(repoName, agentType)
+	if err != nil {
+		return errorResponse(err)
+	}
+	defer release()
+
 	// Create worktree for the agent
 	repoPath := d.paths.RepoDir(repoName)
 	worktreePath := d.paths.AgentWorktree(repoName, agentName)
@@ -1611,7 +3247,7 @@ func (d *Daemon) handleSpawnAgent(req socket.Request) socket.Response {
 		// Ephemeral agents get their own worktree with a new branch
 		branchName := fmt.Sprintf("work/%s", agentName)
 		if err := wt.CreateNewBranch(worktreePath, branchName, "HEAD"); err != nil {
-			return socket.ErrorResponse("failed to create worktree: %v", err)
+			return socket.CodedErrorResponse(socket.CodeInternal, "failed to create worktree: %v", err)
 		}
 	}
 
@@ -1622,18 +3258,24 @@ func (d *Daemon) handleSpawnAgent(req socket.Request) socket.Response {
 		if agentClass != "persistent" {
 			wt.Remove(worktreePath, true)
 		}
-		return socket.ErrorResponse("failed to create tmux window: %v", err)
+		return socket.CodedErrorResponse(socket.CodeInternal, "failed to create tmux window: %v", err)
+	}
+
+	// Capture pane output so it can be tailed and streamed
+	isWorker := agentType == state.AgentTypeWorker || agentType == state.AgentTypeReview
+	if _, err := d.outputManager().Capture(d.ctx, d.tmux, repo.TmuxSession, agentName, repoName, agentName, isWorker); err != nil {
+		d.logger.Warn("Failed to start output capture for %s: %v", agentName, err)
 	}
 
 	// Write prompt to file
-	promptDir := filepath.Join(d.paths.Root, "prompts")
+	promptDir := d.paths.PromptsDir()
 	if err := os.MkdirAll(promptDir, 0755); err != nil {
-		return socket.ErrorResponse("failed to create prompt directory: %v", err)
+		return socket.CodedErrorResponse(socket.CodeInternal, "failed to create prompt directory: %v", err)
 	}
 
 	promptPath := filepath.Join(promptDir, fmt.Sprintf("%s.md", agentName))
 	if err := os.WriteFile(promptPath, []byte(promptText), 0644); err != nil {
-		return socket.ErrorResponse("failed to write prompt file: %v", err)
+		return socket.CodedErrorResponse(socket.CodeInternal, "failed to write prompt file: %v", err)
 	}
 
 	// Copy hooks config
@@ -1647,6 +3289,8 @@ func (d *Daemon) handleSpawnAgent(req socket.Request) socket.Response {
 		agentType:  agentType,
 		promptFile: promptPath,
 		workDir:    worktreePath,
+		task:       task,
+		env:        env,
 	}
 
 	if err := d.startAgentWithConfig(repoName, repo, cfg); err != nil {
@@ -1655,14 +3299,7 @@ func (d *Daemon) handleSpawnAgent(req socket.Request) socket.Response {
 		if agentClass != "persistent" {
 			wt.Remove(worktreePath, true)
 		}
-		return socket.ErrorResponse("failed to start agent: %v", err)
-	}
-
-	// Update task if provided
-	if task != "" {
-		agent, _ := d.state.GetAgent(repoName, agentName)
-		agent.Task = task
-		d.state.UpdateAgent(repoName, agentName, agent)
+		return socket.CodedErrorResponse(socket.CodeInternal, "failed to start agent: %v", err)
 	}
 
 	d.logger.Info("Spawned agent %s/%s (class=%s, type=%s)", repoName, agentName, agentClass, agentType)
@@ -1675,6 +3312,108 @@ func (d *Daemon) handleSpawnAgent(req socket.Request) socket.Response {
 	})
 }
 
+// handleAssignReview spawns a review agent for a pull request, refusing to
+// assign a PR that already has an active reviewer
+func (d *Daemon) handleAssignReview(req socket.Request) socket.Response {
+	repoName, errResp, ok := getRequiredStringArg(req.Args, "repo", "repository name is required")
+	if !ok {
+		return errResp
+	}
+
+	prNumber := getOptionalIntArg(req.Args, "pr_number", 0)
+	if prNumber <= 0 {
+		return socket.CodedErrorResponse(socket.CodeBadRequest, "missing 'pr_number': a positive PR number is required")
+	}
+
+	if d.draining.Load() {
+		return errorResponse(ErrDraining)
+	}
+
+	repo, exists := d.state.GetRepo(repoName)
+	if !exists {
+		return socket.CodedErrorResponse(socket.CodeNotFound, "repository %q not found", repoName)
+	}
+
+	targetBranch := getOptionalStringArg(req.Args, "target_branch", repo.TargetBranch)
+	assignment := agent.ReviewAssignment{
+		PRNumber:     prNumber,
+		PRURL:        getOptionalStringArg(req.Args, "pr_url", ""),
+		TargetBranch: targetBranch,
+	}
+
+	repoPath := d.paths.RepoDir(repoName)
+	spawn := func(agentName string, reserved state.Agent) (state.Agent, error) {
+		// Fetch the PR head; refs/pull/<n>/head works for same-repo and fork PRs
+		localRef := fmt.Sprintf("refs/multiclaude/pr-%d", prNumber)
+		fetch := exec.Command("git", "fetch", "origin", fmt.Sprintf("refs/pull/%d/head:%s", prNumber, localRef))
+		fetch.Dir = repoPath
+		if output, err := fetch.CombinedOutput(); err != nil {
+			return state.Agent{}, fmt.Errorf("failed to fetch PR #%d: %s", prNumber, strings.TrimSpace(string(output)))
+		}
+
+		wt := worktree.NewManager(repoPath)
+		worktreePath := d.paths.AgentWorktree(repoName, agentName)
+		if err := wt.CreateNewBranch(worktreePath, "review/"+agentName, localRef); err != nil {
+			return state.Agent{}, fmt.Errorf("failed to create worktree: %w", err)
+		}
+
+		cmd := exec.Command("tmux", "new-window", "-d", "-t", repo.TmuxSession, "-n", agentName, "-c", worktreePath)
+		if err := cmd.Run(); err != nil {
+			wt.Remove(worktreePath, true)
+			return state.Agent{}, fmt.Errorf("failed to create tmux window: %w", err)
+		}
+
+		if _, err := d.outputManager().Capture(d.ctx, d.tmux, repo.TmuxSession, agentName, repoName, agentName, true); err != nil {
+			d.logger.Warn("Failed to start output capture for %s: %v", agentName, err)
+		}
+
+		cleanup := func() {
+			d.tmux.KillWindow(d.ctx, repo.TmuxSession, agentName)
+			wt.Remove(worktreePath, true)
+		}
+
+		promptFile, err := d.writePromptFile(repoName, state.AgentTypeReview, agentName)
+		if err != nil {
+			cleanup()
+			return state.Agent{}, fmt.Errorf("failed to write prompt file: %w", err)
+		}
+
+		launched, err := d.launchAgent(repoName, repo, agentStartConfig{
+			agentName:  agentName,
+			agentType:  state.AgentTypeReview,
+			promptFile: promptFile,
+			workDir:    worktreePath,
+		})
+		if err != nil {
+			cleanup()
+			return state.Agent{}, err
+		}
+		return launched, nil
+	}
+
+	agentName, err := agent.NewManager(d.state, d.tmux).AssignReview(repoName, assignment, spawn)
+	if err != nil {
+		return socket.CodedErrorResponse(errorCode(err, socket.CodeInternal), "failed to assign review: %v", err)
+	}
+
+	reviewer, _ := d.state.GetAgent(repoName, agentName)
+	d.markStarted(repoName, agentName, reviewer.PID)
+
+	// Hand the reviewer its task through the inbox; the router delivers it
+	if _, err := d.getMessageManager().Send(repoName, "supervisor", agentName, reviewer.Task); err != nil {
+		d.logger.Warn("Failed to send review task to %s: %v", agentName, err)
+	}
+
+	d.logger.Info("Assigned PR #%d in %s to review agent %s", prNumber, repoName, agentName)
+
+	return socket.SuccessResponse(map[string]interface{}{
+		"name":          agentName,
+		"pr_number":     prNumber,
+		"target_branch": reviewer.TargetBranch,
+		"task":          reviewer.Task,
+	})
+}
+
 // cleanupOrphanedWorktrees removes worktree directories without git tracking
 func (d *Daemon) cleanupOrphanedWorktrees() {
 	repoNames := d.state.ListRepos()
@@ -1796,7 +3535,7 @@ func (d *Daemon) restoreDeadAgents(repoName string, repo *state.Repository) {
 		}
 
 		// Check if the process is still alive
-		if isProcessAlive(agent.PID) {
+		if procstat.IsAlive(agent.PID) {
 			d.logger.Debug("Agent %s process (PID %d) is alive", agentName, agent.PID)
 			continue
 		}
@@ -1840,6 +3579,9 @@ func (d *Daemon) restoreRepoAgents(repoName string, repo *state.Repository) erro
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("failed to create tmux session: %w", err)
 	}
+	if err := cleanup.MarkSession(d.ctx, d.tmux, repo.TmuxSession, d.paths.Root); err != nil {
+		d.logger.Warn("%v", err)
+	}
 
 	// Get merge queue config (use default if not set for backward compatibility)
 	mqConfig := repo.MergeQueueConfig
@@ -2031,20 +3773,98 @@ func (d *Daemon) getClaudeBinaryPath() (string, error) {
 	return binaryPath, nil
 }
 
+// resolveClaudeConfig returns the claude overrides configured for an agent
+// type, with the binary path resolved. Spawning and restarting an agent both
+// use it, so a restarted agent runs the same binary, model and arguments.
+func (d *Daemon) resolveClaudeConfig(repoName string, agentType state.AgentType) (state.ClaudeConfig, error) {
+	claudeConfig, err := d.state.GetClaudeConfig(repoName, agentType)
+	if err != nil {
+		return state.ClaudeConfig{}, err
+	}
+	if claudeConfig.BinaryPath == "" {
+		claudeConfig.BinaryPath, err = d.getClaudeBinaryPath()
+		if err != nil {
+			return state.ClaudeConfig{}, fmt.Errorf("failed to resolve claude binary: %w", err)
+		}
+	}
+	return claudeConfig, nil
+}
+
+// claudeCommand builds the shell command that starts claude in an agent's
+// tmux window. The agent's extra environment is read from envFile, if set,
+// so its values are never typed into the window.
+func claudeCommand(binaryPath, sessionID, promptFile string, claudeConfig state.ClaudeConfig, envFile string) string {
+	prefix := ""
+	if envFile != "" {
+		prefix = claude.EnvFilePrefix(envFile)
+	}
+	return prefix +
+		fmt.Sprintf("%s --session-id %s --dangerously-skip-permissions --chrome --append-system-prompt-file %s", binaryPath, sessionID, promptFile) +
+		claude.ExtraArgs(claudeConfig.Model, claudeConfig.ExtraArgs)
+}
+
 // agentStartConfig holds configuration for starting an agent
 type agentStartConfig struct {
 	agentName  string
 	agentType  state.AgentType
 	promptFile string
 	workDir    string
+	task       string            // Initial task, delivered to the agent's inbox before it starts
+	env        map[string]string // Extra environment for the Claude process
 }
 
 // startAgentWithConfig is the unified agent start function that handles all common logic
 func (d *Daemon) startAgentWithConfig(repoName string, repo *state.Repository, cfg agentStartConfig) error {
+	agent, err := d.launchAgent(repoName, repo, cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := d.state.AddAgent(repoName, cfg.agentName, agent); err != nil {
+		return fmt.Errorf("failed to register agent: %w", err)
+	}
+
+	d.logger.Info("Started and registered agent %s/%s", repoName, cfg.agentName)
+	d.markStarted(repoName, cfg.agentName, agent.PID)
+	return nil
+}
+
+// markStarted moves a newly registered agent from starting to running, or
+// to failed if its process has already exited
+func (d *Daemon) markStarted(repoName, agentName string, pid int) {
+	if pid > 0 && !procstat.IsAlive(pid) {
+		d.failAgent(repoName, agentName, "process exited while starting")
+		return
+	}
+	if err := d.state.SetAgentStatus(repoName, agentName, state.AgentStatusRunning); err != nil {
+		d.logger.Warn("Failed to mark agent %s/%s running: %v", repoName, agentName, err)
+	}
+}
+
+// failAgent records that an agent failed without reporting completion
+func (d *Daemon) failAgent(repoName, agentName, reason string) {
+	agent, exists := d.state.GetAgent(repoName, agentName)
+	if !exists {
+		return
+	}
+	agent.Status = state.AgentStatusFailed
+	if agent.FailureReason == "" {
+		agent.FailureReason = reason
+	}
+	if err := d.state.UpdateAgent(repoName, agentName, agent); err != nil {
+		d.logger.Warn("Failed to mark agent %s/%s failed: %v", repoName, agentName, err)
+		return
+	}
+	d.logger.Warn("Agent %s/%s failed: %s", repoName, agentName, reason)
+}
+
+// launchAgent starts Claude in an agent's tmux window and returns the agent
+// without registering it in state
+func (d *Daemon) launchAgent(repoName string, repo *state.Repository, cfg agentStartConfig) (state.Agent, error) {
 	// Generate session ID
 	sessionID, err := claude.GenerateSessionID()
 	if err != nil {
-		return fmt.Errorf("failed to generate session ID: %w", err)
+		return state.Agent{}, fmt.Errorf("failed to generate session ID: %w", err)
 	}
 
 	// Copy hooks config if needed
@@ -2053,25 +3873,46 @@ func (d *Daemon) startAgentWithConfig(repoName string, repo *state.Repository, c
 		d.logger.Warn("Failed to copy hooks config: %v", err)
 	}
 
+	// Deliver the initial task before Claude starts so it is already waiting
+	// in the inbox when the agent first checks its messages
+	if cfg.task != "" {
+		if _, err := d.getMessageManager().Send(repoName, "supervisor", cfg.agentName, cfg.task); err != nil {
+			return state.Agent{}, fmt.Errorf("failed to deliver initial task: %w", err)
+		}
+	}
+
 	var pid int
 
 	// Skip actual Claude startup in test mode
 	if os.Getenv("MULTICLAUDE_TEST_MODE") != "1" {
-		// Resolve claude binary path
-		binaryPath, err := d.getClaudeBinaryPath()
+		claudeConfig, err := d.resolveClaudeConfig(repoName, cfg.agentType)
+		if err != nil {
+			return state.Agent{}, err
+		}
+
+		env, err := d.agentEnv(cfg.env)
 		if err != nil {
-			return fmt.Errorf("failed to resolve claude binary: %w", err)
+			return state.Agent{}, err
+		}
+
+		// Keep the environment in a file; its values may be secrets and the
+		// window's output is captured
+		envFile := ""
+		if len(env) > 0 {
+			envFile = d.paths.AgentEnvFile(repoName, cfg.agentName)
+			if err := claude.WriteEnvFile(envFile, env); err != nil {
+				return state.Agent{}, err
+			}
 		}
 
 		// Build CLI command
-		claudeCmd := fmt.Sprintf("%s --session-id %s --dangerously-skip-permissions --chrome --append-system-prompt-file %s",
-			binaryPath, sessionID, cfg.promptFile)
+		claudeCmd := claudeCommand(claudeConfig.BinaryPath, sessionID, cfg.promptFile, claudeConfig, envFile)
 
 		// Send command to tmux window
 		target := fmt.Sprintf("%s:%s", repo.TmuxSession, cfg.agentName)
 		cmd := exec.Command("tmux", "send-keys", "-t", target, claudeCmd, "C-m")
 		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to start Claude in tmux: %w", err)
+			return state.Agent{}, fmt.Errorf("failed to start Claude in tmux: %w", err)
 		}
 
 		// Wait a moment for Claude to start
@@ -2080,26 +3921,83 @@ func (d *Daemon) startAgentWithConfig(repoName string, repo *state.Repository, c
 		// Get PID
 		pid, err = d.tmux.GetPanePID(d.ctx, repo.TmuxSession, cfg.agentName)
 		if err != nil {
-			return fmt.Errorf("failed to get Claude PID: %w", err)
+			return state.Agent{}, fmt.Errorf("failed to get Claude PID: %w", err)
 		}
 	}
 
-	// Register agent with state
-	agent := state.Agent{
+	// Record the branch so agents can be found by what they are working on
+	branch, err := worktree.GetCurrentBranch(cfg.workDir)
+	if err != nil {
+		d.logger.Debug("Could not determine branch for %s: %v", cfg.agentName, err)
+	}
+
+	return state.Agent{
 		Type:         cfg.agentType,
 		WorktreePath: cfg.workDir,
+		Branch:       branch,
 		TmuxWindow:   cfg.agentName,
+		Task:         cfg.task,
 		SessionID:    sessionID,
 		PID:          pid,
 		CreatedAt:    time.Now(),
+		Env:          redact.Env(cfg.env),
+		Status:       state.AgentStatusStarting,
+	}, nil
+}
+
+// validToken reports whether token is one the daemon's tokens file lists
+func (d *Daemon) validToken(token string) bool {
+	d.authMu.RLock()
+	defer d.authMu.RUnlock()
+	_, ok := d.auth.Capability(token)
+	return ok
+}
+
+// agentEnv returns the environment for an agent's Claude process: extra
+// plus, when the daemon requires a client token, $MULTICLAUDE_TOKEN_FILE so
+// the agent's multiclaude commands are accepted
+func (d *Daemon) agentEnv(extra map[string]string) (map[string]string, error) {
+	tokenFile, err := d.agentTokenFile()
+	if err != nil || tokenFile == "" {
+		return extra, err
+	}
+	env := maps.Clone(extra)
+	if env == nil {
+		env = make(map[string]string, 1)
 	}
+	env[auth.TokenFileEnv] = tokenFile
+	return env, nil
+}
 
-	if err := d.state.AddAgent(repoName, cfg.agentName, agent); err != nil {
-		return fmt.Errorf("failed to register agent: %w", err)
+// agentTokenFile returns the file agents should read their client token
+// from, or "" if the daemon does not require one. Agents share the token the
+// daemon was started with. A token from $MULTICLAUDE_TOKEN is copied to a
+// private file so only its path enters the agent's environment.
+func (d *Daemon) agentTokenFile() (string, error) {
+	d.authMu.RLock()
+	required := d.auth.RequiresToken()
+	d.authMu.RUnlock()
+	if !required {
+		return "", nil
+	}
+
+	if token := os.Getenv(auth.TokenEnv); token != "" {
+		path := d.paths.AgentTokenFile()
+		if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+			return "", fmt.Errorf("failed to write agent token file: %w", err)
+		}
+		// WriteFile keeps the mode of a file that already exists
+		if err := os.Chmod(path, 0600); err != nil {
+			return "", fmt.Errorf("failed to write agent token file: %w", err)
+		}
+		return path, nil
+	}
+	if path := os.Getenv(auth.TokenFileEnv); path != "" {
+		return filepath.Abs(path)
 	}
 
-	d.logger.Info("Started and registered agent %s/%s", repoName, cfg.agentName)
-	return nil
+	d.logger.Warn("%s requires a client token but the daemon was started without %s or %s; agents will not be able to reach the daemon", d.paths.TokensFile, auth.TokenEnv, auth.TokenFileEnv)
+	return "", nil
 }
 
 // startAgent starts a Claude agent in a tmux window and registers it with state
@@ -2133,7 +4031,7 @@ func (d *Daemon) writePromptFileWithPrefix(repoName string, agentType state.Agen
 	}
 
 	// Create prompt file in prompts directory
-	promptDir := filepath.Join(d.paths.Root, "prompts")
+	promptDir := d.paths.PromptsDir()
 	if err := os.MkdirAll(promptDir, 0755); err != nil {
 		return "", fmt.Errorf("failed to create prompt directory: %w", err)
 	}
@@ -2146,6 +4044,24 @@ func (d *Daemon) writePromptFileWithPrefix(repoName string, agentType state.Agen
 	return promptPath, nil
 }
 
+// syncTranscript copies new entries from an agent's Claude session file into
+// its transcript under OutputDir
+func (d *Daemon) syncTranscript(repoName, agentName string, agent state.Agent) {
+	if agent.SessionID == "" || agent.WorktreePath == "" {
+		return
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return
+	}
+
+	sessionFile := output.SessionFile(home, agent.WorktreePath, agent.SessionID)
+	if err := output.NewManager(d.paths.OutputDir).CaptureTranscript(repoName, agentName, sessionFile); err != nil {
+		d.logger.Warn("Failed to capture transcript for %s: %v", agentName, err)
+	}
+}
+
 // restartAgent restarts an agent that has exited.
 // It uses --resume to continue the existing session if history exists.
 // This works for all agent types: supervisor, merge-queue, workspace, workers, and review agents.
@@ -2166,7 +4082,7 @@ func (d *Daemon) restartAgent(repoName, agentName string, agent state.Agent, rep
 	}
 
 	// Get the existing prompt file path
-	promptFile := filepath.Join(d.paths.Root, "prompts", agentName+".md")
+	promptFile := filepath.Join(d.paths.PromptsDir(), agentName+".md")
 	if _, err := os.Stat(promptFile); os.IsNotExist(err) {
 		// Regenerate the prompt file if it doesn't exist
 		promptFile, err = d.writePromptFile(repoName, prompts.AgentType(agent.Type), agentName)
@@ -2175,12 +4091,30 @@ func (d *Daemon) restartAgent(repoName, agentName string, agent state.Agent, rep
 		}
 	}
 
+	// The environment file written at spawn holds the full environment;
+	// agents started before it existed only have their recorded, redacted one
+	envFile := d.paths.AgentEnvFile(repoName, agentName)
+	var env map[string]string
+	if _, err := os.Stat(envFile); os.IsNotExist(err) {
+		env = restorableEnv(agent.Env)
+	}
+
+	claudeConfig, err := d.resolveClaudeConfig(repoName, agent.Type)
+	if err != nil {
+		return err
+	}
+
 	// Restart Claude using the runner
 	// Note: Slash commands are embedded in prompts, not via CLAUDE_CONFIG_DIR
 	result, err := d.claudeRunner.Start(d.ctx, repo.TmuxSession, agentName, claude.Config{
 		SessionID:        agent.SessionID,
 		Resume:           hasHistory,
 		SystemPromptFile: promptFile,
+		Env:              env,
+		EnvFile:          envFile,
+		BinaryPath:       claudeConfig.BinaryPath,
+		Model:            claudeConfig.Model,
+		ExtraArgs:        claudeConfig.ExtraArgs,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to restart Claude: %w", err)
@@ -2195,21 +4129,84 @@ func (d *Daemon) restartAgent(repoName, agentName string, agent state.Agent, rep
 	return nil
 }
 
-// writePromptFile writes the agent prompt to a file and returns the path
-func (d *Daemon) writePromptFile(repoName string, agentType state.AgentType, agentName string) (string, error) {
-	return d.writePromptFileWithPrefix(repoName, agentType, agentName, "")
+// agentLeaseTTL is how long an actor may hold an agent. A lease held by an
+// operation that crashed lapses after this.
+const agentLeaseTTL = 2 * time.Minute
+
+// leaseOwnerHealthCheck is the lease owner for the health check's restarts
+const leaseOwnerHealthCheck = "health-check"
+
+// acquireAgentLease claims an agent so no other actor operates on it at the
+// same time. It returns a func that releases the lease.
+func (d *Daemon) acquireAgentLease(repoName, agentName, owner string, ttl time.Duration) (func(), error) {
+	acquired, err := d.state.AcquireLease(repoName, agentName, owner, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		agent, _ := d.state.GetAgent(repoName, agentName)
+		return nil, fmt.Errorf("%w - %s holds agent '%s' until %s", ErrAgentBusy, agent.LeaseOwner, agentName, agent.LeaseExpiry.Format(time.RFC3339))
+	}
+	return func() {
+		if err := d.state.ReleaseLease(repoName, agentName, owner); err != nil {
+			d.logger.Warn("Failed to release lease on agent %s: %v", agentName, err)
+		}
+	}, nil
+}
+
+// requestLeaseOwner returns a lease owner unique to one socket request
+func requestLeaseOwner(req socket.Request) string {
+	return req.Command + ":" + uuid.NewString()[:8]
 }
 
-// isProcessAlive checks if a process is running
-func isProcessAlive(pid int) bool {
-	process, err := os.FindProcess(pid)
+// autoRestartAgent restarts a dead persistent agent unless its restart
+// backoff says to wait, or it has been restarted too many times
+func (d *Daemon) autoRestartAgent(repoName, agentName string, agent state.Agent, repo *state.Repository) {
+	release, err := d.acquireAgentLease(repoName, agentName, leaseOwnerHealthCheck, agentLeaseTTL)
 	if err != nil {
-		return false
+		d.logger.Info("Skipping restart of agent %s: %v", agentName, err)
+		return
+	}
+	defer release()
+
+	next, ok := state.DefaultRestartBackoff.NextRestart(agent)
+	if !ok {
+		d.logger.Warn("Agent %s has been restarted %d times, not restarting again - restart it manually with: multiclaude agent restart %s", agentName, agent.RestartCount, agentName)
+		return
 	}
+	if time.Now().Before(next) {
+		d.logger.Info("Delaying restart of agent %s until %s (restart %d)", agentName, next.Format(time.RFC3339), agent.RestartCount+1)
+		return
+	}
+
+	d.logger.Info("Attempting to auto-restart agent %s", agentName)
+	if err := d.restartAgent(repoName, agentName, agent, repo); err != nil {
+		d.logger.Error("Failed to restart agent %s: %v", agentName, err)
+	} else {
+		d.logger.Info("Successfully restarted agent %s", agentName)
+	}
+
+	// Failed attempts count too, so a restart loop still backs off
+	if err := d.state.RecordRestart(repoName, agentName, time.Now()); err != nil {
+		d.logger.Warn("Failed to record restart of agent %s: %v", agentName, err)
+	}
+}
+
+// restorableEnv returns the variables of a recorded agent environment whose
+// values were not redacted
+func restorableEnv(env map[string]string) map[string]string {
+	restorable := make(map[string]string)
+	for name, value := range env {
+		if value != redact.Redacted {
+			restorable[name] = value
+		}
+	}
+	return restorable
+}
 
-	// Send signal 0 to check if process exists (doesn't actually signal, just checks)
-	err = process.Signal(syscall.Signal(0))
-	return err == nil
+// writePromptFile writes the agent prompt to a file and returns the path
+func (d *Daemon) writePromptFile(repoName string, agentType state.AgentType, agentName string) (string, error) {
+	return d.writePromptFileWithPrefix(repoName, agentType, agentName, "")
 }
 
 // appendToSliceMap appends a value to a slice in a map, initializing the slice if needed.
@@ -2220,8 +4217,17 @@ func appendToSliceMap(m map[string][]string, key, value string) {
 	m[key] = append(m[key], value)
 }
 
-// Run runs the daemon in the foreground
-func Run() error {
+// outputManager returns an output manager that pipes panes through this
+// binary's output.CaptureCommand
+func (d *Daemon) outputManager() *output.Manager {
+	m := output.NewManager(d.paths.OutputDir)
+	m.CaptureExecutable = d.executable
+	return m
+}
+
+// Run runs the daemon in the foreground. With force, it takes over the PID
+// file even if the recorded process is still alive.
+func Run(force bool) error {
 	paths, err := config.DefaultPaths()
 	if err != nil {
 		return fmt.Errorf("failed to get paths: %w", err)
@@ -2231,6 +4237,9 @@ func Run() error {
 	if err != nil {
 		return fmt.Errorf("failed to create daemon: %w", err)
 	}
+	d.forceClaim = force
+	// Without it agent panes are still captured, just not rotated
+	d.executable, _ = os.Executable()
 
 	if err := d.Start(); err != nil {
 		return fmt.Errorf("failed to start daemon: %w", err)
@@ -2242,8 +4251,9 @@ func Run() error {
 	return nil
 }
 
-// RunDetached starts the daemon in detached mode
-func RunDetached() error {
+// RunDetached starts the daemon in detached mode. With force, the new
+// daemon takes over the PID file even if the recorded process is alive.
+func RunDetached(force bool) error {
 	paths, err := config.DefaultPaths()
 	if err != nil {
 		return fmt.Errorf("failed to get paths: %w", err)
@@ -2251,8 +4261,11 @@ func RunDetached() error {
 
 	// Check if already running
 	pidFile := NewPIDFile(paths.DaemonPID)
-	if running, pid, _ := pidFile.IsRunning(); running {
-		return fmt.Errorf("daemon already running (PID: %d)", pid)
+	if running, pid, _ := pidFile.IsRunning(); running && (pidLocking || !force) {
+		if pidLocking {
+			return fmt.Errorf("daemon already running (PID: %d)", pid)
+		}
+		return fmt.Errorf("daemon already running (PID: %d) - if that process is not a daemon, retry with --force", pid)
 	}
 
 	// Ensure config directory exists
@@ -2285,7 +4298,11 @@ func RunDetached() error {
 	}
 
 	// Start daemon process
-	process, err := os.StartProcess(executable, []string{executable, "daemon", "_run"}, attr)
+	argv := []string{executable, "daemon", "_run"}
+	if force {
+		argv = append(argv, "--force")
+	}
+	process, err := os.StartProcess(executable, argv, attr)
 	if err != nil {
 		return fmt.Errorf("failed to start daemon process: %w", err)
 	}
@@ -2299,10 +4316,32 @@ func RunDetached() error {
 	return nil
 }
 
-// MaxLogFileSize is the threshold for log rotation (10MB)
-const MaxLogFileSize = 10 * 1024 * 1024
+// HistoryMaxAge is how old command history entries get before the health
+// check archives them. The history recorder archives by size on its own, and
+// the compact command archives on demand.
+const HistoryMaxAge = 7 * 24 * time.Hour
+
+// compactHistoryIfNeeded archives command history entries older than
+// HistoryMaxAge, the way rotateLogsIfNeeded rotates agent logs
+func (d *Daemon) compactHistoryIfNeeded() {
+	result, err := d.history.Compact(history.CompactOptions{MaxAge: HistoryMaxAge})
+	if err != nil {
+		d.logger.Error("Failed to compact command history: %v", err)
+		return
+	}
+	if result.Archived > 0 {
+		d.logger.Info("Archived %d command history entries older than %s to %s", result.Archived, HistoryMaxAge, result.Archive)
+	}
+}
+
+// MaxLogFileSize is the threshold for log rotation, the same limit the
+// capture command's RotatingWriter applies
+const MaxLogFileSize = output.DefaultMaxLogSize
 
-// rotateLogsIfNeeded checks log files and rotates any that exceed MaxLogFileSize
+// rotateLogsIfNeeded checks log files and rotates any that exceed
+// MaxLogFileSize. Capture files rotate themselves as they are written; this
+// catches files written without a RotatingWriter, such as by the plain cat
+// pipes of agents started by older builds.
 func (d *Daemon) rotateLogsIfNeeded() {
 	d.logger.Debug("Checking for log rotation")
 
@@ -2332,21 +4371,11 @@ func (d *Daemon) rotateLogsIfNeeded() {
 	}
 }
 
-// rotateLog rotates a single log file by renaming it with a timestamp suffix
+// rotateLog rotates a single log file by renaming it with a timestamp
+// suffix, keeping output.DefaultMaxBackups rotated copies. A RotatingWriter
+// appending to the file reopens the path on its next write.
 func (d *Daemon) rotateLog(logPath string) error {
-	// Generate rotated filename with timestamp
-	timestamp := time.Now().Format("20060102-150405")
-	rotatedPath := logPath + "." + timestamp
-
-	// Rename the current log file
-	if err := os.Rename(logPath, rotatedPath); err != nil {
-		return fmt.Errorf("failed to rename log: %w", err)
-	}
-
-	// The tmux pipe-pane will create a new file automatically when it next writes
-	// No need to recreate the file or restart the pipe
-
-	return nil
+	return output.RotateFile(logPath, output.DefaultMaxBackups)
 }
 
 // isLogFile checks if a file is a log file
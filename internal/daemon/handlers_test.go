@@ -3,6 +3,7 @@ package daemon
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -328,7 +329,7 @@ func TestHandleRemoveAgentTableDriven(t *testing.T) {
 			wantError:   "not found",
 		},
 		{
-			name: "agent does not exist - idempotent delete succeeds",
+			name: "agent does not exist",
 			args: map[string]interface{}{
 				"repo":  "test-repo",
 				"agent": "nonexistent",
@@ -340,7 +341,7 @@ func TestHandleRemoveAgentTableDriven(t *testing.T) {
 					Agents:      make(map[string]state.Agent),
 				})
 			},
-			wantSuccess: true, // Delete is idempotent - removing non-existent agent succeeds
+			wantSuccess: false,
 		},
 		{
 			name: "successful remove",
@@ -1020,6 +1021,42 @@ func TestHandleAddAgentCreatedAtIsSet(t *testing.T) {
 	}
 }
 
+// TestHandleAddAgentRecordsBranch verifies the worktree's branch is stored
+// unless one is given explicitly
+func TestHandleAddAgentRecordsBranch(t *testing.T) {
+	d, cleanup := setupTestDaemonWithState(t, func(s *state.State) {
+		s.AddRepo("test-repo", &state.Repository{
+			GithubURL:   "https://github.com/test/repo",
+			TmuxSession: "test-session",
+			Agents:      make(map[string]state.Agent),
+		})
+	})
+	defer cleanup()
+
+	repoDir := t.TempDir()
+	createTestGitRepo(t, repoDir)
+
+	for name, args := range map[string]map[string]interface{}{
+		"detected": {"worktree_path": repoDir},
+		"explicit": {"worktree_path": "/tmp/test", "branch": "work/explicit"},
+	} {
+		args["repo"] = "test-repo"
+		args["agent"] = name
+		args["type"] = "worker"
+		args["tmux_window"] = name
+		if resp := d.handleAddAgent(socket.Request{Command: "add_agent", Args: args}); !resp.Success {
+			t.Fatalf("handleAddAgent(%s) failed: %s", name, resp.Error)
+		}
+	}
+
+	if agent, _ := d.state.GetAgent("test-repo", "detected"); agent.Branch != "main" {
+		t.Errorf("detected Branch = %q, want main", agent.Branch)
+	}
+	if agent, _ := d.state.GetAgent("test-repo", "explicit"); agent.Branch != "work/explicit" {
+		t.Errorf("explicit Branch = %q, want work/explicit", agent.Branch)
+	}
+}
+
 // TestHandleAddRepoEmptyAgentsMap verifies the Agents map is initialized
 func TestHandleAddRepoEmptyAgentsMap(t *testing.T) {
 	d, cleanup := setupTestDaemonWithState(t, nil)
@@ -1443,6 +1480,132 @@ func TestHandleRestartAgentTableDriven(t *testing.T) {
 	}
 }
 
+// TestHandleKillAgentTableDriven tests handleKillAgent argument validation
+func TestHandleKillAgentTableDriven(t *testing.T) {
+	withRepo := func(s *state.State) {
+		s.AddRepo("test-repo", &state.Repository{
+			GithubURL:   "https://github.com/test/repo",
+			TmuxSession: "test-session",
+			Agents:      make(map[string]state.Agent),
+		})
+	}
+
+	tests := []struct {
+		name       string
+		args       map[string]interface{}
+		setupState func(*state.State)
+		wantError  string
+	}{
+		{
+			name:      "missing repo argument",
+			args:      map[string]interface{}{"agent": "test"},
+			wantError: "repo",
+		},
+		{
+			name:      "missing agent argument",
+			args:      map[string]interface{}{"repo": "test-repo"},
+			wantError: "agent",
+		},
+		{
+			name:       "invalid grace period",
+			args:       map[string]interface{}{"repo": "test-repo", "agent": "test", "grace": "soon"},
+			setupState: withRepo,
+			wantError:  "grace",
+		},
+		{
+			name:       "agent does not exist",
+			args:       map[string]interface{}{"repo": "test-repo", "agent": "nonexistent"},
+			setupState: withRepo,
+			wantError:  "not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, cleanup := setupTestDaemonWithState(t, tt.setupState)
+			defer cleanup()
+
+			resp := d.handleKillAgent(socket.Request{
+				Command: "kill_agent",
+				Args:    tt.args,
+			})
+
+			if resp.Success {
+				t.Fatalf("handleKillAgent() should fail")
+			}
+			if !strings.Contains(resp.Error, tt.wantError) {
+				t.Errorf("handleKillAgent() error = %q, want it to contain %q", resp.Error, tt.wantError)
+			}
+		})
+	}
+}
+
+// TestHandleAssignReviewTableDriven tests handleAssignReview validation and duplicate prevention
+func TestHandleAssignReviewTableDriven(t *testing.T) {
+	withReviewer := func(s *state.State) {
+		s.AddRepo("test-repo", &state.Repository{
+			GithubURL:   "https://github.com/test/repo",
+			TmuxSession: "test-session",
+			Agents:      make(map[string]state.Agent),
+		})
+		s.AddAgent("test-repo", "review-5", state.Agent{
+			Type:       state.AgentTypeReview,
+			TmuxWindow: "review-5",
+			PRNumber:   5,
+			CreatedAt:  time.Now(),
+		})
+	}
+
+	tests := []struct {
+		name       string
+		args       map[string]interface{}
+		setupState func(*state.State)
+		wantError  string
+	}{
+		{
+			name:      "missing repo argument",
+			args:      map[string]interface{}{"pr_number": float64(5)},
+			wantError: "repo",
+		},
+		{
+			name:       "missing pr number",
+			args:       map[string]interface{}{"repo": "test-repo"},
+			setupState: withReviewer,
+			wantError:  "pr_number",
+		},
+		{
+			name:      "repo does not exist",
+			args:      map[string]interface{}{"repo": "nonexistent", "pr_number": float64(5)},
+			wantError: "not found",
+		},
+		{
+			name:       "PR already assigned",
+			args:       map[string]interface{}{"repo": "test-repo", "pr_number": float64(5)},
+			setupState: withReviewer,
+			wantError:  "already assigned",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, cleanup := setupTestDaemonWithState(t, tt.setupState)
+			defer cleanup()
+
+			resp := d.handleAssignReview(socket.Request{
+				Command: "assign_review",
+				Args:    tt.args,
+			})
+
+			if resp.Success {
+				t.Fatalf("handleAssignReview() should fail")
+			}
+			if !strings.Contains(resp.Error, tt.wantError) {
+				t.Errorf("handleAssignReview() error = %q, want it to contain %q", resp.Error, tt.wantError)
+			}
+		})
+	}
+}
+
 // TestHandleSpawnAgentTableDriven tests handleSpawnAgent with various argument combinations
 func TestHandleSpawnAgentTableDriven(t *testing.T) {
 	tests := []struct {
@@ -2033,3 +2196,121 @@ func TestHandleGetRepoConfigTableDriven(t *testing.T) {
 		})
 	}
 }
+
+// TestHandleAddAgentSpawnLimit tests that worker registration respects the
+// repository's concurrent worker limit
+func TestHandleAddAgentSpawnLimit(t *testing.T) {
+	d, cleanup := setupTestDaemonWithState(t, func(s *state.State) {
+		s.AddRepo("test-repo", &state.Repository{
+			GithubURL:        "https://github.com/test/repo",
+			TmuxSession:      "test-session",
+			Agents:           make(map[string]state.Agent),
+			SpawnLimitConfig: state.SpawnLimitConfig{MaxWorkers: 2, SpawnsPerMinute: -1},
+		})
+	})
+	defer cleanup()
+
+	addWorker := func(name string) socket.Response {
+		return d.handleAddAgent(socket.Request{
+			Command: "add_agent",
+			Args: map[string]interface{}{
+				"repo":          "test-repo",
+				"agent":         name,
+				"type":          "worker",
+				"worktree_path": "/tmp/" + name,
+				"tmux_window":   name,
+			},
+		})
+	}
+
+	for _, name := range []string{"w1", "w2"} {
+		if resp := addWorker(name); !resp.Success {
+			t.Fatalf("add_agent %s failed: %s", name, resp.Error)
+		}
+	}
+
+	resp := addWorker("w3")
+	if resp.Success {
+		t.Fatal("add_agent should be rejected at the worker limit")
+	}
+	if !strings.Contains(resp.Error, "spawn limit exceeded") {
+		t.Errorf("error = %q, want spawn limit error", resp.Error)
+	}
+	if _, exists := d.state.GetAgent("test-repo", "w3"); exists {
+		t.Error("rejected worker should not be registered")
+	}
+
+	// Completing a worker frees its slot
+	w1, _ := d.state.GetAgent("test-repo", "w1")
+	w1.ReadyForCleanup = true
+	d.state.UpdateAgent("test-repo", "w1", w1)
+
+	if resp := addWorker("w3"); !resp.Success {
+		t.Errorf("add_agent after capacity freed failed: %s", resp.Error)
+	}
+}
+
+// TestSpawnLimitConfigFileDefaults tests that the config file's spawn limits
+// apply to repositories without their own, and are re-read on reload
+func TestSpawnLimitConfigFileDefaults(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	writeConfig := func(content string) {
+		if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile() failed: %v", err)
+		}
+	}
+	writeConfig(`{"max_workers": 1, "spawns_per_minute": -1}`)
+	t.Setenv(config.FileEnv, configFile)
+
+	d, cleanup := setupTestDaemonWithState(t, func(s *state.State) {
+		s.AddRepo("test-repo", &state.Repository{
+			GithubURL:   "https://github.com/test/repo",
+			TmuxSession: "test-session",
+			Agents:      make(map[string]state.Agent),
+		})
+	})
+	defer cleanup()
+
+	addWorker := func(name string) socket.Response {
+		return d.handleAddAgent(socket.Request{
+			Command: "add_agent",
+			Args: map[string]interface{}{
+				"repo":          "test-repo",
+				"agent":         name,
+				"type":          "worker",
+				"worktree_path": "/tmp/" + name,
+				"tmux_window":   name,
+			},
+		})
+	}
+
+	if resp := addWorker("w1"); !resp.Success {
+		t.Fatalf("add_agent w1 failed: %s", resp.Error)
+	}
+	if resp := addWorker("w2"); resp.Success {
+		t.Fatal("add_agent should be rejected at the config file's worker limit")
+	}
+
+	writeConfig(`{"max_workers": 2, "spawns_per_minute": -1, "log_level": "error"}`)
+	if err := d.Reload(); err != nil {
+		t.Fatalf("Reload() failed: %v", err)
+	}
+	if resp := addWorker("w2"); !resp.Success {
+		t.Errorf("add_agent after raising the limit failed: %s", resp.Error)
+	}
+
+	// The reloaded log level drops anything less severe
+	d.logger.Warn("dropped warning")
+	if data, _ := os.ReadFile(d.paths.DaemonLog); strings.Contains(string(data), "dropped warning") {
+		t.Error("warning logged below the configured log level")
+	}
+
+	// A broken config file keeps the previous config
+	writeConfig(`{"max_workers": "many"}`)
+	if err := d.Reload(); err == nil {
+		t.Error("Reload() with an invalid config file should fail")
+	}
+	if resp := addWorker("w3"); resp.Success {
+		t.Error("previous worker limit should survive a failed reload")
+	}
+}
@@ -0,0 +1,100 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dlorenc/multiclaude/internal/fsutil"
+)
+
+// StartReason explains why the daemon started
+type StartReason string
+
+const (
+	// StartFresh means no earlier daemon left a PID file behind
+	StartFresh StartReason = "fresh"
+	// StartCrashRecovery means the previous daemon exited without removing
+	// its PID file
+	StartCrashRecovery StartReason = "crash-recovery"
+	// StartStaleTakeover means the PID file was forcibly claimed from a live
+	// process. Where flock is available a recycled PID counts as crash
+	// recovery instead, since the lock shows the old daemon is gone.
+	StartStaleTakeover StartReason = "stale-takeover"
+)
+
+// StartMetadata records how often and why the daemon has started. It
+// survives restarts in daemon-meta.json.
+type StartMetadata struct {
+	TotalStarts     int         `json:"total_starts"`
+	LastStart       time.Time   `json:"last_start"`
+	LastStartReason StartReason `json:"last_start_reason"`
+}
+
+// startReason classifies a start from the PID file as it is before being
+// claimed. A clean shutdown removes the file, so a leftover PID means the
+// previous daemon crashed.
+func (p *PIDFile) startReason() StartReason {
+	running, _, _ := p.IsRunning()
+	if running {
+		return StartStaleTakeover
+	}
+	if pid, err := p.recorded(); err != nil || pid != 0 {
+		return StartCrashRecovery
+	}
+	return StartFresh
+}
+
+// loadStartMetadata reads start metadata from path. An empty path or
+// missing file yields zero metadata.
+func loadStartMetadata(path string) (StartMetadata, error) {
+	var meta StartMetadata
+	if path == "" {
+		return meta, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return meta, nil
+		}
+		return meta, fmt.Errorf("failed to read daemon metadata: %w", err)
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return meta, fmt.Errorf("failed to parse daemon metadata: %w", err)
+	}
+	return meta, nil
+}
+
+// recordStart counts a start at now for reason and persists the result to
+// path. An empty path keeps the metadata in memory only. Unreadable metadata
+// is reported but counting starts over rather than blocking the daemon.
+func recordStart(path string, reason StartReason, now time.Time) (StartMetadata, error) {
+	meta, loadErr := loadStartMetadata(path)
+	if loadErr != nil {
+		meta = StartMetadata{}
+	}
+	meta.TotalStarts++
+	meta.LastStart = now
+	meta.LastStartReason = reason
+
+	var saveErr error
+	if path != "" {
+		saveErr = saveStartMetadata(path, meta)
+	}
+	return meta, errors.Join(loadErr, saveErr)
+}
+
+// saveStartMetadata writes meta to path atomically
+func saveStartMetadata(path string, meta StartMetadata) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal daemon metadata: %w", err)
+	}
+
+	if err := fsutil.WriteFileAtomic(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write daemon metadata: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordStartReasons(t *testing.T) {
+	dir := t.TempDir()
+	pidPath := filepath.Join(dir, "daemon.pid")
+	metaPath := filepath.Join(dir, "daemon-meta.json")
+	pf := NewPIDFile(pidPath)
+
+	// A live process that is not a daemon, standing in for a recycled PID
+	sleeper := exec.Command("sleep", "30")
+	if err := sleeper.Start(); err != nil {
+		t.Skipf("cannot start sleep: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = sleeper.Process.Kill()
+		_ = sleeper.Wait()
+	})
+
+	// Only the lock shows that a live recorded PID is not a daemon
+	recycledReason := StartStaleTakeover
+	if pidLocking {
+		recycledReason = StartCrashRecovery
+	}
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	starts := []struct {
+		name     string
+		pidFile  string // contents before the start; empty removes the file
+		want     StartReason
+		startsAt time.Time
+	}{
+		{"first start", "", StartFresh, base},
+		{"after crash", "999999\n", StartCrashRecovery, base.Add(time.Minute)},
+		{"after clean stop", "", StartFresh, base.Add(2 * time.Minute)},
+		{"recycled PID", fmt.Sprintf("%d\n", sleeper.Process.Pid), recycledReason, base.Add(3 * time.Minute)},
+	}
+
+	for i, s := range starts {
+		if s.pidFile == "" {
+			if err := pf.Remove(); err != nil {
+				t.Fatalf("Remove() failed: %v", err)
+			}
+		} else if err := os.WriteFile(pidPath, []byte(s.pidFile), 0644); err != nil {
+			t.Fatalf("WriteFile() failed: %v", err)
+		}
+
+		reason := pf.startReason()
+		if reason != s.want {
+			t.Errorf("%s: startReason() = %q, want %q", s.name, reason, s.want)
+		}
+		if _, err := recordStart(metaPath, reason, s.startsAt); err != nil {
+			t.Fatalf("%s: recordStart() failed: %v", s.name, err)
+		}
+
+		// Each start reloads what the previous one persisted
+		meta, err := loadStartMetadata(metaPath)
+		if err != nil {
+			t.Fatalf("loadStartMetadata() failed: %v", err)
+		}
+		if meta.TotalStarts != i+1 {
+			t.Errorf("%s: TotalStarts = %d, want %d", s.name, meta.TotalStarts, i+1)
+		}
+		if meta.LastStartReason != s.want || !meta.LastStart.Equal(s.startsAt) {
+			t.Errorf("%s: last start = %s (%s), want %s (%s)", s.name, meta.LastStart, meta.LastStartReason, s.startsAt, s.want)
+		}
+	}
+}
+
+func TestRecordStartCorruptMetadata(t *testing.T) {
+	metaPath := filepath.Join(t.TempDir(), "daemon-meta.json")
+	if err := os.WriteFile(metaPath, []byte("not json"), 0644); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+
+	meta, err := recordStart(metaPath, StartFresh, time.Now())
+	if err == nil {
+		t.Error("recordStart() should report corrupt metadata")
+	}
+	if meta.TotalStarts != 1 {
+		t.Errorf("TotalStarts = %d, want counting to start over at 1", meta.TotalStarts)
+	}
+	if reloaded, err := loadStartMetadata(metaPath); err != nil || reloaded.TotalStarts != 1 {
+		t.Errorf("reloaded metadata = %+v, %v; want the corrupt file replaced", reloaded, err)
+	}
+}
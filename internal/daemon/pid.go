@@ -1,16 +1,25 @@
 package daemon
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
-	"syscall"
+
+	"github.com/dlorenc/multiclaude/internal/logging"
+	"github.com/dlorenc/multiclaude/internal/procstat"
 )
 
-// PIDFile manages the daemon PID file
+// errPIDLocked reports that another process holds the PID file's lock
+var errPIDLocked = errors.New("PID file is locked by another process")
+
+// PIDFile manages the daemon PID file. The daemon that claims it holds an
+// exclusive flock on it for as long as it lives, so a stale file whose PID
+// has been recycled by an unrelated process is never mistaken for a daemon.
 type PIDFile struct {
 	path string
+	lock *os.File // Open and flocked while this process owns the file
 }
 
 // NewPIDFile creates a new PIDFile manager
@@ -18,14 +27,71 @@ func NewPIDFile(path string) *PIDFile {
 	return &PIDFile{path: path}
 }
 
-// Write writes the current process PID to the file
+// Write writes the current process PID to the file and locks it until
+// Remove. It returns errPIDLocked if another process holds the lock.
 func (p *PIDFile) Write() error {
-	pid := os.Getpid()
-	return os.WriteFile(p.path, []byte(fmt.Sprintf("%d\n", pid)), 0644)
+	if p.lock == nil {
+		f, err := p.openLocked()
+		if err != nil {
+			return err
+		}
+		p.lock = f
+	}
+
+	data := []byte(fmt.Sprintf("%d\n", os.Getpid()))
+	if _, err := p.lock.WriteAt(data, 0); err != nil {
+		return err
+	}
+	return p.lock.Truncate(int64(len(data)))
+}
+
+// openLocked opens the PID file and takes its lock. A daemon removing the
+// file as it stops may unlink it between our open and our lock, leaving us
+// holding a lock nobody else can see, so retry until the locked file is
+// still the one at path.
+func (p *PIDFile) openLocked() (*os.File, error) {
+	for {
+		f, err := os.OpenFile(p.path, os.O_CREATE|os.O_RDWR, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if err := lockPIDFile(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		held, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		current, err := os.Stat(p.path)
+		if err == nil && os.SameFile(held, current) {
+			return f, nil
+		}
+		f.Close()
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
 }
 
-// Read reads the PID from the file
+// Read returns the PID of the daemon holding the PID file, or 0 if there is
+// none. Where flock is available, a file nobody has locked was left behind by
+// a dead daemon and also reads as 0.
 func (p *PIDFile) Read() (int, error) {
+	if pidLocking {
+		held, err := p.locked()
+		if err != nil || !held {
+			return 0, err
+		}
+	}
+	return p.recorded()
+}
+
+// recorded reads the PID written in the file, whether or not anyone holds
+// its lock
+func (p *PIDFile) recorded() (int, error) {
 	data, err := os.ReadFile(p.path)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -43,18 +109,53 @@ func (p *PIDFile) Read() (int, error) {
 	return pid, nil
 }
 
-// Remove removes the PID file
+// locked reports whether any process, including this one, holds the PID
+// file's lock
+func (p *PIDFile) locked() (bool, error) {
+	f, err := os.Open(p.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer f.Close()
+	return pidFileLocked(f)
+}
+
+// Remove removes the PID file and releases its lock
 func (p *PIDFile) Remove() error {
-	if err := os.Remove(p.path); err != nil && !os.IsNotExist(err) {
+	err := os.Remove(p.path)
+	p.release()
+	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
 	return nil
 }
 
-// IsRunning checks if the daemon is running by checking the PID file
-// and verifying the process is alive
+// release drops this process's lock without removing the file, as exiting
+// does
+func (p *PIDFile) release() {
+	if p.lock != nil {
+		p.lock.Close()
+		p.lock = nil
+	}
+}
+
+// IsRunning checks if the daemon is running. Where flock is available the
+// lock alone decides; elsewhere the recorded process must be alive.
 func (p *PIDFile) IsRunning() (bool, int, error) {
-	pid, err := p.Read()
+	if pidLocking {
+		held, err := p.locked()
+		if err != nil || !held {
+			return false, 0, err
+		}
+		// The holder may not have written its PID yet
+		pid, _ := p.recorded()
+		return true, pid, nil
+	}
+
+	pid, err := p.recorded()
 	if err != nil {
 		return false, 0, err
 	}
@@ -63,15 +164,8 @@ func (p *PIDFile) IsRunning() (bool, int, error) {
 		return false, 0, nil
 	}
 
-	// Check if process exists by sending signal 0
-	process, err := os.FindProcess(pid)
-	if err != nil {
-		return false, 0, nil
-	}
-
-	err = process.Signal(syscall.Signal(0))
-	if err != nil {
-		// Process doesn't exist or we don't have permission
+	// A zombie daemon has exited even though it still answers signal 0
+	if !procstat.IsAlive(pid) {
 		return false, 0, nil
 	}
 
@@ -90,13 +184,37 @@ func (p *PIDFile) CheckAndClaim() error {
 		return fmt.Errorf("daemon already running (PID: %d)", pid)
 	}
 
-	// Remove stale PID file if exists
-	if err := p.Remove(); err != nil {
-		return fmt.Errorf("failed to remove stale PID file: %w", err)
+	// Write our PID, overwriting any stale one
+	if err := p.Write(); err != nil {
+		if errors.Is(err, errPIDLocked) {
+			return fmt.Errorf("daemon already running (%s is locked)", p.path)
+		}
+		return fmt.Errorf("failed to write PID file: %w", err)
+	}
+
+	return nil
+}
+
+// CheckAndClaimForce claims the PID file even if the recorded process is
+// alive. Use it only when the old daemon is known to be dead and its PID has
+// been recycled by an unrelated process; CheckAndClaim is the safe default.
+// Where flock is available a recycled PID never blocks CheckAndClaim, and
+// force cannot take the file from a daemon that holds its lock.
+func (p *PIDFile) CheckAndClaimForce(logger *logging.Logger) error {
+	running, pid, err := p.IsRunning()
+	if err != nil {
+		// An unreadable PID file is exactly what force is for
+		logger.Warn("Overwriting unreadable PID file %s: %v", p.path, err)
+	} else if running && pidLocking {
+		return fmt.Errorf("daemon already running (PID: %d) and holds the lock on %s", pid, p.path)
+	} else if running {
+		logger.Warn("Forcing takeover of PID file %s from live process %d; make sure it is not a daemon", p.path, pid)
 	}
 
-	// Write our PID
 	if err := p.Write(); err != nil {
+		if errors.Is(err, errPIDLocked) {
+			return fmt.Errorf("daemon already running (%s is locked)", p.path)
+		}
 		return fmt.Errorf("failed to write PID file: %w", err)
 	}
 
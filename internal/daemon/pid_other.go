@@ -0,0 +1,19 @@
+//go:build !unix
+
+package daemon
+
+import "os"
+
+// pidLocking is false where flock is unavailable; liveness falls back to
+// checking the recorded process
+const pidLocking = false
+
+// lockPIDFile is a no-op where flock is unavailable
+func lockPIDFile(f *os.File) error {
+	return nil
+}
+
+// pidFileLocked never sees a lock where flock is unavailable
+func pidFileLocked(f *os.File) (bool, error) {
+	return false, nil
+}
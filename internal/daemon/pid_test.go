@@ -1,9 +1,15 @@
 package daemon
 
 import (
+	"bytes"
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/dlorenc/multiclaude/internal/logging"
 )
 
 func TestPIDFileWriteRead(t *testing.T) {
@@ -111,7 +117,8 @@ func TestPIDFileIsRunning(t *testing.T) {
 		t.Errorf("PID = %d, want %d", pid, os.Getpid())
 	}
 
-	// Write a PID that doesn't exist (use very high number)
+	// The process exits: its lock goes away, but its PID file stays behind
+	pf.release()
 	if err := os.WriteFile(pidPath, []byte("999999\n"), 0644); err != nil {
 		t.Fatalf("WriteFile() failed: %v", err)
 	}
@@ -150,7 +157,8 @@ func TestPIDFileCheckAndClaim(t *testing.T) {
 		t.Error("CheckAndClaim() succeeded when process already running")
 	}
 
-	// Write stale PID and verify we can claim it
+	// Write stale PID and verify we can claim it once the owner is gone
+	pf.release()
 	if err := os.WriteFile(pidPath, []byte("999999\n"), 0644); err != nil {
 		t.Fatalf("WriteFile() failed: %v", err)
 	}
@@ -168,3 +176,84 @@ func TestPIDFileCheckAndClaim(t *testing.T) {
 		t.Errorf("PID = %d, want %d after claiming stale", pid, os.Getpid())
 	}
 }
+
+func TestPIDFileCheckAndClaimRecycledPID(t *testing.T) {
+	pidPath := filepath.Join(t.TempDir(), "test.pid")
+
+	// A live process that is not a daemon, standing in for a recycled PID
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start sleep: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	if err := os.WriteFile(pidPath, []byte(fmt.Sprintf("%d\n", cmd.Process.Pid)), 0644); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+
+	pf := NewPIDFile(pidPath)
+	if !pidLocking {
+		t.Skip("PID file locking is unavailable on this platform")
+	}
+	if running, _, err := pf.IsRunning(); err != nil || running {
+		t.Fatalf("IsRunning() = %v, %v; want a recycled PID without the lock treated as stale", running, err)
+	}
+	if pid, err := pf.Read(); err != nil || pid != 0 {
+		t.Errorf("Read() = %d, %v; want 0 for an unlocked file", pid, err)
+	}
+
+	if err := pf.CheckAndClaim(); err != nil {
+		t.Fatalf("CheckAndClaim() failed over a recycled PID: %v", err)
+	}
+	defer pf.Remove()
+	if pid, err := pf.Read(); err != nil || pid != os.Getpid() {
+		t.Errorf("Read() = %d, %v; want %d after claiming", pid, err, os.Getpid())
+	}
+
+	// A second daemon sees the lock even though it could signal our PID
+	other := NewPIDFile(pidPath)
+	if err := other.CheckAndClaim(); err == nil {
+		t.Error("CheckAndClaim() succeeded while another owner holds the lock")
+	}
+	if err := other.CheckAndClaimForce(logging.New(&bytes.Buffer{})); err == nil {
+		t.Error("CheckAndClaimForce() took the file from an owner holding the lock")
+	}
+
+	// A clean stop frees the file for the next daemon
+	if err := pf.Remove(); err != nil {
+		t.Fatalf("Remove() failed: %v", err)
+	}
+	if err := other.CheckAndClaim(); err != nil {
+		t.Errorf("CheckAndClaim() failed after the owner stopped: %v", err)
+	}
+	other.Remove()
+}
+
+func TestPIDFileCheckAndClaimForce(t *testing.T) {
+	pidPath := filepath.Join(t.TempDir(), "test.pid")
+	pf := NewPIDFile(pidPath)
+
+	// A file nobody can parse is exactly what force is for
+	if err := os.WriteFile(pidPath, []byte("not a pid\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := pf.CheckAndClaimForce(logging.New(&buf)); err != nil {
+		t.Fatalf("CheckAndClaimForce() failed: %v", err)
+	}
+	defer pf.Remove()
+
+	pid, err := pf.Read()
+	if err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if pid != os.Getpid() {
+		t.Errorf("PID = %d, want %d after forced claim", pid, os.Getpid())
+	}
+	if !pidLocking && !strings.Contains(buf.String(), "WARN") {
+		t.Errorf("log = %q, want a warning about the unreadable file", buf.String())
+	}
+}
@@ -0,0 +1,44 @@
+//go:build unix
+
+package daemon
+
+import (
+	"os"
+	"syscall"
+)
+
+// pidLocking reports whether the PID file's flock decides liveness
+const pidLocking = true
+
+// lockPIDFile takes an exclusive flock on f without blocking, returning
+// errPIDLocked if another open file already holds it
+func lockPIDFile(f *os.File) error {
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		switch err {
+		case syscall.EINTR:
+			continue
+		case syscall.EWOULDBLOCK:
+			return errPIDLocked
+		}
+		return err
+	}
+}
+
+// pidFileLocked reports whether another open file holds an exclusive flock
+// on f's file, by briefly trying to share it
+func pidFileLocked(f *os.File) (bool, error) {
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_SH|syscall.LOCK_NB)
+		switch err {
+		case syscall.EINTR:
+			continue
+		case syscall.EWOULDBLOCK:
+			return true, nil
+		case nil:
+			syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+			return false, nil
+		}
+		return false, err
+	}
+}
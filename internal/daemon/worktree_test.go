@@ -7,7 +7,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/dlorenc/multiclaude/internal/fork"
+	"github.com/dlorenc/multiclaude/internal/socket"
 	"github.com/dlorenc/multiclaude/internal/state"
+	"github.com/dlorenc/multiclaude/internal/worktree"
 	"github.com/dlorenc/multiclaude/pkg/config"
 )
 
@@ -531,3 +534,144 @@ func TestCleanupMergedBranches_WithLocalClone(t *testing.T) {
 	// Should not panic - the repo has no remote so it will skip cleanup
 	d.cleanupMergedBranches()
 }
+
+func TestHandlePruneWorktrees(t *testing.T) {
+	d, cleanup := setupTestDaemon(t)
+	defer cleanup()
+
+	repoPath := d.paths.RepoDir("test-repo")
+	if err := os.MkdirAll(repoPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	createTestGitRepo(t, repoPath)
+	d.state.AddRepo("test-repo", &state.Repository{
+		TmuxSession: "mc-test-repo",
+		Agents:      make(map[string]state.Agent),
+	})
+
+	stalePath := filepath.Join(d.paths.WorktreeDir("test-repo"), "stale")
+	cmd := exec.Command("git", "worktree", "add", "-b", "work/stale", stalePath, "main")
+	cmd.Dir = repoPath
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Failed to create worktree: %v", err)
+	}
+	if err := os.RemoveAll(stalePath); err != nil {
+		t.Fatal(err)
+	}
+
+	prune := func(dryRun bool) map[string][]string {
+		t.Helper()
+		resp := d.handlePruneWorktrees(socket.Request{
+			Command: "prune_worktrees",
+			Args:    map[string]interface{}{"repo": "test-repo", "dry_run": dryRun},
+		})
+		if !resp.Success {
+			t.Fatalf("handlePruneWorktrees() failed: %s", resp.Error)
+		}
+		return resp.Data.(map[string]interface{})["pruned"].(map[string][]string)
+	}
+
+	if pruned := prune(true); len(pruned["test-repo"]) != 1 {
+		t.Errorf("dry run pruned = %v, want the stale worktree", pruned)
+	}
+	if stale, _ := worktree.FindStaleWorktrees(repoPath); len(stale) != 1 {
+		t.Errorf("dry run should not prune, stale = %v", stale)
+	}
+
+	if pruned := prune(false); len(pruned["test-repo"]) != 1 {
+		t.Errorf("pruned = %v, want the stale worktree", pruned)
+	}
+	if stale, _ := worktree.FindStaleWorktrees(repoPath); len(stale) != 0 {
+		t.Errorf("stale worktrees remain: %v", stale)
+	}
+
+	resp := d.handlePruneWorktrees(socket.Request{
+		Command: "prune_worktrees",
+		Args:    map[string]interface{}{"repo": "missing"},
+	})
+	if resp.Success {
+		t.Error("handlePruneWorktrees() should fail for an unknown repo")
+	}
+}
+
+func TestHandleSyncFork(t *testing.T) {
+	d, cleanup := setupTestDaemon(t)
+	defer cleanup()
+
+	git := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, out)
+		}
+	}
+
+	// upstream <- origin <- the daemon's clone of the fork
+	upstream := t.TempDir()
+	createTestGitRepo(t, upstream)
+	origin := filepath.Join(t.TempDir(), "origin.git")
+	git(upstream, "clone", "--bare", upstream, origin)
+	repoPath := d.paths.RepoDir("fork-repo")
+	git(upstream, "clone", origin, repoPath)
+	git(repoPath, "config", "user.name", "Test User")
+	git(repoPath, "config", "user.email", "test@example.com")
+
+	d.state.AddRepo("fork-repo", &state.Repository{
+		TmuxSession: "mc-fork-repo",
+		Agents:      make(map[string]state.Agent),
+		ForkConfig:  state.ForkConfig{IsFork: true, UpstreamURL: upstream},
+	})
+	d.state.AddRepo("plain-repo", &state.Repository{
+		TmuxSession: "mc-plain-repo",
+		Agents:      make(map[string]state.Agent),
+	})
+
+	// Upstream gains a commit the fork doesn't have
+	if err := os.WriteFile(filepath.Join(upstream, "new.go"), []byte("package new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git(upstream, "add", "new.go")
+	git(upstream, "commit", "-m", "Add new.go")
+
+	resp := d.handleSyncFork(socket.Request{Command: "sync", Args: map[string]interface{}{"repo": "fork-repo"}})
+	if !resp.Success {
+		t.Fatalf("handleSyncFork() failed: %s", resp.Error)
+	}
+	result := resp.Data.(*fork.SyncResult)
+	if result.Branch != "main" || result.Status != fork.SyncFastForwarded || result.Behind != 1 || result.Ahead != 0 || result.Conflicts {
+		t.Errorf("result = %+v, want main fast-forwarded 1 behind 0 ahead without conflicts", result)
+	}
+	if _, err := os.Stat(filepath.Join(repoPath, "new.go")); err != nil {
+		t.Error("upstream commit should have been merged into the fork")
+	}
+
+	// A named remote is synced with instead of upstream
+	staging := t.TempDir()
+	git(upstream, "clone", upstream, staging)
+	git(staging, "config", "user.name", "Test User")
+	git(staging, "config", "user.email", "test@example.com")
+	if err := os.WriteFile(filepath.Join(staging, "staged.go"), []byte("package staged\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git(staging, "add", "staged.go")
+	git(staging, "commit", "-m", "Add staged.go")
+	git(repoPath, "remote", "add", "staging", staging)
+
+	resp = d.handleSyncFork(socket.Request{Command: "sync", Args: map[string]interface{}{"repo": "fork-repo", "remote": "staging"}})
+	if !resp.Success {
+		t.Fatalf("handleSyncFork(staging) failed: %s", resp.Error)
+	}
+	if _, err := os.Stat(filepath.Join(repoPath, "staged.go")); err != nil {
+		t.Error("the staging commit should have been merged into the fork")
+	}
+	resp = d.handleSyncFork(socket.Request{Command: "sync", Args: map[string]interface{}{"repo": "fork-repo", "remote": "missing"}})
+	if resp.Success || resp.Code != socket.CodeConflict {
+		t.Errorf("syncing with an unknown remote = %+v, want conflict", resp)
+	}
+
+	resp = d.handleSyncFork(socket.Request{Command: "sync", Args: map[string]interface{}{"repo": "plain-repo"}})
+	if resp.Success || resp.Error != `repository "plain-repo" is not a fork` {
+		t.Errorf("syncing a non-fork = %+v, want refusal", resp)
+	}
+}
@@ -0,0 +1,124 @@
+package diagnostics
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/dlorenc/multiclaude/internal/state"
+)
+
+// MaxClockSkew is how far the local clock may drift from the reference
+// before it is flagged. Leases, restart backoff, and staleness checks all
+// compare timestamps, so larger skew makes them wrong.
+const MaxClockSkew = 30 * time.Second
+
+// DefaultNTPServer is queried for the reference time unless offline
+const DefaultNTPServer = "pool.ntp.org:123"
+
+// ntpTimeout bounds how long the NTP query may take
+const ntpTimeout = 3 * time.Second
+
+// ClockInfo compares the local clock with a reference. Skew is the
+// reference time minus the local time, so a positive skew means the local
+// clock is behind.
+type ClockInfo struct {
+	Local     time.Time     `json:"local"`
+	Reference string        `json:"reference"` // "ntp", "state", or "none"
+	Skew      time.Duration `json:"skew"`
+	Warning   string        `json:"warning,omitempty"`
+}
+
+// collectClock checks the local clock against NTP, unless offline, and
+// against the newest timestamp recorded in state. A timestamp in the future
+// means the clock has jumped backwards since it was written.
+func (c *Collector) collectClock() ClockInfo {
+	now := c.now()
+	info := ClockInfo{Local: now, Reference: "none"}
+
+	if !c.offline && c.ntpTime != nil {
+		if ref, err := c.ntpTime(); err == nil {
+			info.Reference = "ntp"
+			info.Skew = ref.Sub(now)
+			if info.Skew > MaxClockSkew || info.Skew < -MaxClockSkew {
+				info.Warning = fmt.Sprintf("local clock differs from NTP by %s; timestamps, leases, and staleness checks may be wrong", info.Skew.Round(time.Second))
+			}
+			return info
+		}
+	}
+
+	st, err := state.Load(c.paths.StateFile)
+	if err != nil {
+		return info
+	}
+	newest := newestTimestamp(st)
+	if newest.IsZero() {
+		return info
+	}
+	info.Reference = "state"
+	if ahead := newest.Sub(now); ahead > MaxClockSkew {
+		info.Skew = ahead
+		info.Warning = fmt.Sprintf("state has timestamps %s in the future; the clock may have jumped backwards", ahead.Round(time.Second))
+	}
+	return info
+}
+
+// newestTimestamp returns the latest time recorded for any agent or task
+func newestTimestamp(st *state.State) time.Time {
+	var newest time.Time
+	later := func(t time.Time) {
+		if t.After(newest) {
+			newest = t
+		}
+	}
+	for _, repo := range st.GetAllRepos() {
+		for _, agent := range repo.Agents {
+			later(agent.CreatedAt)
+			later(agent.LastNudge)
+			later(agent.LastRestart)
+		}
+		for _, task := range repo.TaskHistory {
+			later(task.CreatedAt)
+			later(task.CompletedAt)
+		}
+	}
+	return newest
+}
+
+// queryNTP asks an NTP server for the current time using a single SNTP
+// request, correcting for half the round trip
+func queryNTP(server string) (time.Time, error) {
+	conn, err := net.DialTimeout("udp", server, ntpTimeout)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to reach NTP server: %w", err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(ntpTimeout)); err != nil {
+		return time.Time{}, err
+	}
+
+	// Client request: leap indicator 0, version 3, mode 3
+	req := make([]byte, 48)
+	req[0] = 0x1B
+
+	sent := time.Now()
+	if _, err := conn.Write(req); err != nil {
+		return time.Time{}, fmt.Errorf("failed to send NTP request: %w", err)
+	}
+	resp := make([]byte, 48)
+	if _, err := conn.Read(resp); err != nil {
+		return time.Time{}, fmt.Errorf("failed to read NTP response: %w", err)
+	}
+	rtt := time.Since(sent)
+
+	// Transmit timestamp: seconds and fraction since 1900
+	secs := binary.BigEndian.Uint32(resp[40:44])
+	frac := binary.BigEndian.Uint32(resp[44:48])
+	if secs == 0 {
+		return time.Time{}, fmt.Errorf("NTP server returned no time")
+	}
+	const ntpEpochOffset = 2208988800 // seconds from 1900 to 1970
+	nanos := (int64(frac) * 1e9) >> 32
+	return time.Unix(int64(secs)-ntpEpochOffset, nanos).Add(rtt / 2), nil
+}
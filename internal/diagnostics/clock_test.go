@@ -0,0 +1,94 @@
+package diagnostics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dlorenc/multiclaude/internal/state"
+	"github.com/dlorenc/multiclaude/pkg/config"
+)
+
+func newClockCollector(t *testing.T, now time.Time, ntp func() (time.Time, error)) *Collector {
+	t.Helper()
+	c := NewCollector(config.NewTestPaths(t.TempDir()), "test")
+	c.now = func() time.Time { return now }
+	c.ntpTime = ntp
+	return c
+}
+
+func TestCollectClockNTPSkew(t *testing.T) {
+	ref := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		local    time.Time
+		wantWarn bool
+	}{
+		{"in sync", ref.Add(2 * time.Second), false},
+		{"local ahead", ref.Add(5 * time.Minute), true},
+		{"local behind", ref.Add(-5 * time.Minute), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newClockCollector(t, tt.local, func() (time.Time, error) { return ref, nil })
+			info := c.collectClock()
+
+			if info.Reference != "ntp" {
+				t.Errorf("Reference = %q, want ntp", info.Reference)
+			}
+			if info.Skew != ref.Sub(tt.local) {
+				t.Errorf("Skew = %v, want %v", info.Skew, ref.Sub(tt.local))
+			}
+			if (info.Warning != "") != tt.wantWarn {
+				t.Errorf("Warning = %q, want warning: %v", info.Warning, tt.wantWarn)
+			}
+		})
+	}
+}
+
+func TestCollectClockOfflineUsesState(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := newClockCollector(t, now, func() (time.Time, error) {
+		t.Fatal("NTP queried while offline")
+		return time.Time{}, nil
+	})
+	c.SetOffline(true)
+
+	// Written by a clock ten minutes ahead of the current one
+	st := state.New(c.paths.StateFile)
+	if err := st.AddRepo("repo", &state.Repository{Agents: make(map[string]state.Agent)}); err != nil {
+		t.Fatalf("AddRepo() failed: %v", err)
+	}
+	if err := st.AddAgent("repo", "worker", state.Agent{Type: state.AgentTypeWorker, CreatedAt: now.Add(10 * time.Minute)}); err != nil {
+		t.Fatalf("AddAgent() failed: %v", err)
+	}
+	if err := st.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	info := c.collectClock()
+	if info.Reference != "state" {
+		t.Errorf("Reference = %q, want state", info.Reference)
+	}
+	if !strings.Contains(info.Warning, "future") {
+		t.Errorf("Warning = %q, want a future-timestamp warning", info.Warning)
+	}
+}
+
+func TestCollectClockNTPUnavailable(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := newClockCollector(t, now, func() (time.Time, error) {
+		return time.Time{}, errors.New("no network")
+	})
+
+	info := c.collectClock()
+	if info.Reference != "none" {
+		t.Errorf("Reference = %q, want none with no NTP and empty state", info.Reference)
+	}
+	if info.Warning != "" {
+		t.Errorf("Warning = %q, want none", info.Warning)
+	}
+}
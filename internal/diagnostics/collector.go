@@ -5,11 +5,18 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/dlorenc/multiclaude/internal/cleanup"
+	"github.com/dlorenc/multiclaude/internal/command"
+	"github.com/dlorenc/multiclaude/internal/procstat"
 	"github.com/dlorenc/multiclaude/internal/state"
+	"github.com/dlorenc/multiclaude/internal/worktree"
 	"github.com/dlorenc/multiclaude/pkg/config"
+	"github.com/dlorenc/multiclaude/pkg/tmux"
 )
 
 // Report contains all diagnostic information in machine-readable format
@@ -21,6 +28,14 @@ type Report struct {
 	Tools        ToolsInfo        `json:"tools"`
 	Daemon       DaemonInfo       `json:"daemon"`
 	Statistics   StatisticsInfo   `json:"statistics"`
+	Agents       []AgentInfo      `json:"agents"`
+	Worktrees    WorktreesInfo    `json:"worktrees"`
+	Clock        ClockInfo        `json:"clock"`
+	Disk         DiskInfo         `json:"disk"`
+	Orphans      OrphansInfo      `json:"orphans"`
+	Tailscale    TailscaleInfo    `json:"tailscale"`
+	Connectivity ConnectivityInfo `json:"connectivity"`
+	Health       HealthInfo       `json:"health"`
 }
 
 // VersionInfo contains version details for multiclaude and dependencies
@@ -72,12 +87,23 @@ type ClaudeInfo struct {
 	Installed bool   `json:"installed"`
 	Version   string `json:"version"`
 	Path      string `json:"path"`
+
+	// ConfiguredPaths maps "<repo>/<agent type>" to a binary path configured
+	// to run instead of Path
+	ConfiguredPaths map[string]string `json:"configured_paths,omitempty"`
 }
 
 // DaemonInfo contains information about the daemon process
 type DaemonInfo struct {
 	Running bool `json:"running"`
 	PID     int  `json:"pid"`
+	// Zombie is set when the PID has exited but was never reaped
+	Zombie bool `json:"zombie,omitempty"`
+	// StartedAt and Uptime are zero unless the daemon is running. The
+	// daemon claims its PID file as it boots, so the file's mtime is its
+	// start time.
+	StartedAt time.Time `json:"started_at"`
+	Uptime    string    `json:"uptime,omitempty"`
 }
 
 // StatisticsInfo contains agent and repository counts
@@ -88,22 +114,125 @@ type StatisticsInfo struct {
 	MergeQueues  int `json:"merge_queues"`
 	Workspaces   int `json:"workspaces"`
 	ReviewAgents int `json:"review_agents"`
+	// ByStatus counts agents by their current status
+	ByStatus map[state.AgentStatus]int `json:"by_status,omitempty"`
+}
+
+// AgentInfo describes one agent in state
+type AgentInfo struct {
+	Repo   string `json:"repo"`
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Branch string `json:"branch,omitempty"`
+	// Process is the procstat status of the agent's PID, when one is known
+	Process string `json:"process,omitempty"`
+}
+
+// WorktreesInfo lists problems found with agent worktrees
+type WorktreesInfo struct {
+	BrokenSymlinks []BrokenSymlinkInfo `json:"broken_symlinks"`
+}
+
+// BrokenSymlinkInfo describes a worktree path that is a symlink to a missing
+// target. Agent is set when the path belongs to an agent in state.
+type BrokenSymlinkInfo struct {
+	Repo   string `json:"repo"`
+	Agent  string `json:"agent,omitempty"`
+	Path   string `json:"path"`
+	Target string `json:"target"`
 }
 
 // Collector gathers diagnostic information
 type Collector struct {
 	paths   *config.Paths
 	version string
+	offline bool
+
+	// runner runs the tools being detected; tests swap in a fake
+	runner      command.Runner
+	toolTimeout time.Duration
+
+	// tmux lists sessions when looking for orphans; tests swap in a fake
+	tmux cleanup.TmuxClient
+
+	// now and ntpTime are swappable so tests can simulate clock skew
+	now     func() time.Time
+	ntpTime func() (time.Time, error)
+
+	// diskTimeout and diskMaxEntries bound each directory size walk
+	diskTimeout    time.Duration
+	diskMaxEntries int
+
+	// endpoints are dialed by the connectivity check, each bounded by
+	// dialTimeout
+	endpoints   []string
+	dialTimeout time.Duration
+
+	// redaction decides which environment values are hidden
+	redaction RedactionPolicy
+
+	// daemonStatus reports whether the daemon is running and its PID
+	daemonStatus func() (bool, int, error)
+}
+
+// CollectorOptions configures a collector made by NewCollectorWithOptions
+type CollectorOptions struct {
+	// Redaction decides which environment values are hidden; nil means
+	// DefaultRedactionPolicy
+	Redaction *RedactionPolicy
+
+	// DaemonStatus reports whether the daemon is running and its PID.
+	// Callers pass daemon.PIDFile.IsRunning, which trusts the PID file's
+	// lock over a recorded PID that may have been recycled; this package
+	// cannot import daemon. nil means checking that the recorded PID is
+	// alive.
+	DaemonStatus func() (bool, int, error)
 }
 
-// NewCollector creates a new diagnostic collector
+// NewCollector creates a new diagnostic collector with default options
 func NewCollector(paths *config.Paths, version string) *Collector {
+	return NewCollectorWithOptions(paths, version, CollectorOptions{})
+}
+
+// NewCollectorWithOptions creates a new diagnostic collector configured by
+// opts
+func NewCollectorWithOptions(paths *config.Paths, version string, opts CollectorOptions) *Collector {
+	redaction := DefaultRedactionPolicy()
+	if opts.Redaction != nil {
+		redaction = *opts.Redaction
+	}
 	return &Collector{
-		paths:   paths,
-		version: version,
+		paths:          paths,
+		version:        version,
+		runner:         command.ExecRunner{},
+		tmux:           tmux.NewClient(),
+		toolTimeout:    DefaultToolTimeout,
+		now:            time.Now,
+		ntpTime:        func() (time.Time, error) { return queryNTP(DefaultNTPServer) },
+		diskTimeout:    DefaultDiskWalkTimeout,
+		diskMaxEntries: DefaultDiskWalkEntries,
+		endpoints:      DefaultEndpoints,
+		dialTimeout:    DefaultDialTimeout,
+		redaction:      redaction,
+		daemonStatus:   opts.DaemonStatus,
 	}
 }
 
+// DefaultToolTimeout bounds each tool version probe. A tool that hangs, for
+// example on a credential prompt, is reported as timed out.
+const DefaultToolTimeout = 10 * time.Second
+
+// SetToolTimeout changes how long each tool version probe may run
+func (c *Collector) SetToolTimeout(timeout time.Duration) {
+	c.toolTimeout = timeout
+}
+
+// SetOffline skips checks that need the network. The clock is then checked
+// only against timestamps in state.
+func (c *Collector) SetOffline(offline bool) {
+	c.offline = offline
+}
+
 // Collect gathers all diagnostic information
 func (c *Collector) Collect() (*Report, error) {
 	report := &Report{
@@ -112,15 +241,25 @@ func (c *Collector) Collect() (*Report, error) {
 			Go:          runtime.Version(),
 			IsDev:       strings.Contains(c.version, "dev") || strings.Contains(c.version, "unknown"),
 		},
-		Environment: c.collectEnvironment(),
-		Tools:       c.collectTools(),
-		Daemon:      c.collectDaemon(),
-		Statistics:  c.collectStatistics(),
+		Environment:  c.collectEnvironment(),
+		Tools:        c.collectTools(),
+		Daemon:       c.collectDaemon(),
+		Statistics:   c.collectStatistics(),
+		Agents:       c.collectAgents(),
+		Worktrees:    c.collectWorktrees(),
+		Clock:        c.collectClock(),
+		Disk:         c.collectDisk(),
+		Orphans:      c.collectOrphans(),
+		Tailscale:    c.collectTailscale(),
+		Connectivity: c.collectConnectivity(),
 	}
 
 	// Determine capabilities based on tool versions
 	report.Capabilities = c.determineCapabilities(report.Tools)
 
+	// Judge the facts once they are all in
+	report.Health = assessHealth(report)
+
 	return report, nil
 }
 
@@ -143,13 +282,7 @@ func (c *Collector) collectEnvironment() EnvironmentInfo {
 
 	for _, varName := range importantVars {
 		if value := os.Getenv(varName); value != "" {
-			// Redact sensitive values
-			if strings.Contains(strings.ToLower(varName), "token") ||
-				strings.Contains(strings.ToLower(varName), "key") {
-				envVars[varName] = "[REDACTED]"
-			} else {
-				envVars[varName] = value
-			}
+			envVars[varName] = c.redaction.Redact(varName, value)
 		}
 	}
 
@@ -183,20 +316,53 @@ func (c *Collector) collectTools() ToolsInfo {
 
 // getClaudeInfo returns detailed information about Claude CLI
 func (c *Collector) getClaudeInfo() ClaudeInfo {
-	path, err := exec.LookPath("claude")
+	info := c.getDefaultClaudeInfo()
+	info.ConfiguredPaths = c.configuredClaudePaths()
+	return info
+}
+
+// configuredClaudePaths returns the claude binary overrides in state
+func (c *Collector) configuredClaudePaths() map[string]string {
+	st, err := state.Load(c.paths.StateFile)
 	if err != nil {
+		return nil
+	}
+
+	var paths map[string]string
+	for repoName, repo := range st.GetAllRepos() {
+		for agentType, config := range repo.ClaudeConfig {
+			if config.BinaryPath == "" {
+				continue
+			}
+			if paths == nil {
+				paths = make(map[string]string)
+			}
+			paths[repoName+"/"+string(agentType)] = config.BinaryPath
+		}
+	}
+	return paths
+}
+
+// getDefaultClaudeInfo returns information about the claude binary on PATH
+func (c *Collector) getDefaultClaudeInfo() ClaudeInfo {
+	output, err := command.RunWithTimeout(c.runner, c.toolTimeout, "claude", "--version")
+	if command.IsNotFound(err) {
 		return ClaudeInfo{
 			Installed: false,
 		}
 	}
 
-	cmd := exec.Command("claude", "--version")
-	output, err := cmd.Output()
+	// Best effort: the path is informational only
+	path, _ := exec.LookPath("claude")
 	if err != nil {
+		version := "unknown"
+		if command.IsTimeout(err) {
+			version = "timed out"
+		}
 		return ClaudeInfo{
 			Installed: true,
 			Path:      path,
-			Version:   "unknown",
+			Version:   version,
 		}
 	}
 
@@ -210,8 +376,10 @@ func (c *Collector) getClaudeInfo() ClaudeInfo {
 
 // getToolVersion returns the version string for a tool
 func (c *Collector) getToolVersion(tool string, versionFlag string) string {
-	cmd := exec.Command(tool, versionFlag)
-	output, err := cmd.Output()
+	output, err := command.RunWithTimeout(c.runner, c.toolTimeout, tool, versionFlag)
+	if command.IsTimeout(err) {
+		return "timed out"
+	}
 	if err != nil {
 		return "not installed"
 	}
@@ -262,44 +430,41 @@ func (c *Collector) detectTaskManagementSupport(version string) bool {
 
 // collectDaemon gathers daemon status information
 func (c *Collector) collectDaemon() DaemonInfo {
-	pidData, err := os.ReadFile(c.paths.DaemonPID)
-	if err != nil {
-		return DaemonInfo{
-			Running: false,
-			PID:     0,
+	var info DaemonInfo
+	if c.daemonStatus != nil {
+		running, pid, err := c.daemonStatus()
+		if err == nil && running {
+			info = DaemonInfo{Running: true, PID: pid}
+		} else if pid := c.recordedDaemonPID(); pid != 0 && procstat.Check(pid) == procstat.StatusZombie {
+			// A dead daemon no longer holds the lock; say why if it was
+			// never reaped
+			info = DaemonInfo{PID: pid, Zombie: true}
 		}
-	}
-
-	pid, err := strconv.Atoi(strings.TrimSpace(string(pidData)))
-	if err != nil {
-		return DaemonInfo{
-			Running: false,
-			PID:     0,
-		}
-	}
-
-	// Check if process is running
-	process, err := os.FindProcess(pid)
-	if err != nil {
-		return DaemonInfo{
-			Running: false,
+	} else if pid := c.recordedDaemonPID(); pid != 0 {
+		status := procstat.Check(pid)
+		info = DaemonInfo{
+			Running: status == procstat.StatusRunning,
 			PID:     pid,
+			Zombie:  status == procstat.StatusZombie,
 		}
 	}
-
-	// On Unix, FindProcess always succeeds, so we send signal 0 to check
-	err = process.Signal(os.Signal(nil))
-	if err != nil {
-		return DaemonInfo{
-			Running: false,
-			PID:     pid,
+	if info.Running {
+		if fi, err := os.Stat(c.paths.DaemonPID); err == nil {
+			info.StartedAt = fi.ModTime()
+			info.Uptime = c.now().Sub(info.StartedAt).Round(time.Second).String()
 		}
 	}
+	return info
+}
 
-	return DaemonInfo{
-		Running: true,
-		PID:     pid,
+// recordedDaemonPID returns the PID written in the daemon's PID file, or 0
+func (c *Collector) recordedDaemonPID() int {
+	data, err := os.ReadFile(c.paths.DaemonPID)
+	if err != nil {
+		return 0
 	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
 }
 
 // collectStatistics gathers agent and repository statistics
@@ -327,12 +492,92 @@ func (c *Collector) collectStatistics() StatisticsInfo {
 			case state.AgentTypeReview:
 				stats.ReviewAgents++
 			}
+			if stats.ByStatus == nil {
+				stats.ByStatus = make(map[state.AgentStatus]int)
+			}
+			stats.ByStatus[agent.CurrentStatus()]++
 		}
 	}
 
 	return stats
 }
 
+// collectAgents lists every agent in state with the branch it works on
+func (c *Collector) collectAgents() []AgentInfo {
+	agents := []AgentInfo{}
+
+	st, err := state.Load(c.paths.StateFile)
+	if err != nil {
+		return agents
+	}
+
+	for repoName, repo := range st.GetAllRepos() {
+		for agentName, agent := range repo.Agents {
+			info := AgentInfo{
+				Repo:   repoName,
+				Name:   agentName,
+				Type:   string(agent.Type),
+				Branch: agent.Branch,
+			}
+			if agent.PID > 0 {
+				info.Process = string(procstat.Check(agent.PID))
+			}
+			agents = append(agents, info)
+		}
+	}
+	sort.Slice(agents, func(i, j int) bool {
+		if agents[i].Repo != agents[j].Repo {
+			return agents[i].Repo < agents[j].Repo
+		}
+		return agents[i].Name < agents[j].Name
+	})
+	return agents
+}
+
+// collectWorktrees checks each repository's worktrees for dangling symlinks
+func (c *Collector) collectWorktrees() WorktreesInfo {
+	info := WorktreesInfo{BrokenSymlinks: []BrokenSymlinkInfo{}}
+
+	st, err := state.Load(c.paths.StateFile)
+	if err != nil {
+		return info
+	}
+
+	repos := st.GetAllRepos()
+	repoNames := make([]string, 0, len(repos))
+	for name := range repos {
+		repoNames = append(repoNames, name)
+	}
+	sort.Strings(repoNames)
+
+	for _, repoName := range repoNames {
+		owners := make(map[string]string) // worktree path -> agent name
+		var agentPaths []string
+		for agentName, agent := range repos[repoName].Agents {
+			if agent.WorktreePath != "" {
+				owners[agent.WorktreePath] = agentName
+				agentPaths = append(agentPaths, agent.WorktreePath)
+			}
+		}
+		sort.Strings(agentPaths)
+
+		broken, err := worktree.FindBrokenSymlinks(c.paths.WorktreeDir(repoName), agentPaths...)
+		if err != nil {
+			continue
+		}
+		for _, link := range broken {
+			info.BrokenSymlinks = append(info.BrokenSymlinks, BrokenSymlinkInfo{
+				Repo:   repoName,
+				Agent:  owners[link.Path],
+				Path:   link.Path,
+				Target: link.Target,
+			})
+		}
+	}
+
+	return info
+}
+
 // ToJSON converts the report to JSON format
 func (r *Report) ToJSON(pretty bool) (string, error) {
 	var data []byte
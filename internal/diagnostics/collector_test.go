@@ -0,0 +1,176 @@
+package diagnostics
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dlorenc/multiclaude/internal/command"
+	"github.com/dlorenc/multiclaude/internal/state"
+	"github.com/dlorenc/multiclaude/pkg/config"
+)
+
+func TestCollectToolsWithFakeRunner(t *testing.T) {
+	tests := []struct {
+		name         string
+		setup        func(*command.Fake)
+		wantClaude   ClaudeInfo
+		wantTmux     string
+		wantGit      string
+		wantTaskMgmt bool
+	}{
+		{
+			name:       "nothing installed",
+			setup:      func(*command.Fake) {},
+			wantClaude: ClaudeInfo{Installed: false},
+			wantTmux:   "not installed",
+			wantGit:    "not installed",
+		},
+		{
+			name: "claude version fails",
+			setup: func(f *command.Fake) {
+				f.Set("claude --version", "", errors.New("exit status 1"))
+				f.Set("tmux -V", "tmux 3.4\n", nil)
+				f.Set("git --version", "git version 2.44.0\n", nil)
+			},
+			wantClaude: ClaudeInfo{Installed: true, Version: "unknown"},
+			wantTmux:   "tmux 3.4",
+			wantGit:    "git version 2.44.0",
+		},
+		{
+			name: "tools hang",
+			setup: func(f *command.Fake) {
+				f.Hang("claude --version")
+				f.Hang("tmux -V")
+				f.Set("git --version", "git version 2.44.0\n", nil)
+			},
+			wantClaude: ClaudeInfo{Installed: true, Version: "timed out"},
+			wantTmux:   "timed out",
+			wantGit:    "git version 2.44.0",
+		},
+		{
+			name: "all installed",
+			setup: func(f *command.Fake) {
+				f.Set("claude --version", "2.1.17 (Claude Code)\n", nil)
+				f.Set("tmux -V", "tmux 3.4\n", nil)
+				f.Set("git --version", "git version 2.44.0\n", nil)
+			},
+			wantClaude:   ClaudeInfo{Installed: true, Version: "2.1.17 (Claude Code)"},
+			wantTmux:     "tmux 3.4",
+			wantGit:      "git version 2.44.0",
+			wantTaskMgmt: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := command.NewFake()
+			tt.setup(fake)
+			c := NewCollector(config.NewTestPaths(t.TempDir()), "test")
+			c.runner = fake
+			c.SetToolTimeout(20 * time.Millisecond)
+
+			tools := c.collectTools()
+			// The path comes from the real PATH, so only compare the rest
+			got := tools.Claude
+			if got.Installed != tt.wantClaude.Installed || got.Version != tt.wantClaude.Version {
+				t.Errorf("Claude = %+v, want %+v", got, tt.wantClaude)
+			}
+			if tools.Tmux != tt.wantTmux || tools.Git != tt.wantGit {
+				t.Errorf("tmux, git = %q, %q; want %q, %q", tools.Tmux, tools.Git, tt.wantTmux, tt.wantGit)
+			}
+
+			caps := c.determineCapabilities(tools)
+			if caps.TaskManagement != tt.wantTaskMgmt {
+				t.Errorf("TaskManagement = %v, want %v", caps.TaskManagement, tt.wantTaskMgmt)
+			}
+		})
+	}
+}
+
+func TestCollectDaemonStartTime(t *testing.T) {
+	paths := config.NewTestPaths(t.TempDir())
+	c := NewCollector(paths, "test")
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	if info := c.collectDaemon(); !info.StartedAt.IsZero() || info.Uptime != "" {
+		t.Errorf("no daemon: StartedAt, Uptime = %v, %q; want zero values", info.StartedAt, info.Uptime)
+	}
+
+	// This test process stands in for a running daemon
+	started := now.Add(-90 * time.Minute)
+	if err := os.WriteFile(paths.DaemonPID, []byte(fmt.Sprintf("%d\n", os.Getpid())), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(paths.DaemonPID, started, started); err != nil {
+		t.Fatal(err)
+	}
+
+	info := c.collectDaemon()
+	if !info.Running {
+		t.Fatalf("Running = false for PID %d", os.Getpid())
+	}
+	if !info.StartedAt.Equal(started) {
+		t.Errorf("StartedAt = %v, want %v", info.StartedAt, started)
+	}
+	if info.Uptime != "1h30m0s" {
+		t.Errorf("Uptime = %q, want %q", info.Uptime, "1h30m0s")
+	}
+
+	// A stale PID file says nothing about uptime
+	if err := os.WriteFile(paths.DaemonPID, []byte("999999\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if info := c.collectDaemon(); !info.StartedAt.IsZero() || info.Uptime != "" {
+		t.Errorf("stale PID: StartedAt, Uptime = %v, %q; want zero values", info.StartedAt, info.Uptime)
+	}
+}
+
+func TestCollectDaemonTrustsDaemonStatus(t *testing.T) {
+	paths := config.NewTestPaths(t.TempDir())
+
+	// The recorded PID is alive, but nobody holds the PID file's lock
+	if err := os.WriteFile(paths.DaemonPID, []byte(fmt.Sprintf("%d\n", os.Getpid())), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := NewCollectorWithOptions(paths, "test", CollectorOptions{
+		DaemonStatus: func() (bool, int, error) { return false, 0, nil },
+	})
+	if info := c.collectDaemon(); info.Running || info.Uptime != "" {
+		t.Errorf("unlocked PID file: collectDaemon() = %+v, want not running", info)
+	}
+
+	c = NewCollectorWithOptions(paths, "test", CollectorOptions{
+		DaemonStatus: func() (bool, int, error) { return true, 4242, nil },
+	})
+	if info := c.collectDaemon(); !info.Running || info.PID != 4242 {
+		t.Errorf("locked PID file: collectDaemon() = %+v, want running as 4242", info)
+	}
+}
+
+func TestCollectStatisticsByStatus(t *testing.T) {
+	paths := config.NewTestPaths(t.TempDir())
+	st := state.New(paths.StateFile)
+	st.AddRepo("repo", &state.Repository{Agents: make(map[string]state.Agent)})
+	st.AddAgent("repo", "supervisor", state.Agent{Type: state.AgentTypeSupervisor})
+	st.AddAgent("repo", "w1", state.Agent{Type: state.AgentTypeWorker, Status: state.AgentStatusBlocked})
+	st.AddAgent("repo", "w2", state.Agent{Type: state.AgentTypeWorker, ReadyForCleanup: true})
+
+	stats := NewCollector(paths, "test").collectStatistics()
+	want := map[state.AgentStatus]int{
+		state.AgentStatusRunning:   1,
+		state.AgentStatusBlocked:   1,
+		state.AgentStatusCompleted: 1,
+	}
+	if len(stats.ByStatus) != len(want) {
+		t.Errorf("ByStatus = %v, want %v", stats.ByStatus, want)
+	}
+	for status, n := range want {
+		if stats.ByStatus[status] != n {
+			t.Errorf("ByStatus[%s] = %d, want %d", status, stats.ByStatus[status], n)
+		}
+	}
+}
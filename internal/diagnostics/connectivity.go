@@ -0,0 +1,74 @@
+package diagnostics
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultEndpoints are dialed by the connectivity check. Agents need GitHub
+// for every push and PR, and the Anthropic API to run at all.
+var DefaultEndpoints = []string{"github.com:443", "api.anthropic.com:443"}
+
+// DefaultDialTimeout bounds each endpoint's connection attempt
+const DefaultDialTimeout = 3 * time.Second
+
+// ConnectivityInfo reports which network endpoints this host can reach.
+// Skipped is set when the collector is offline.
+type ConnectivityInfo struct {
+	Skipped   bool           `json:"skipped,omitempty"`
+	Endpoints []EndpointInfo `json:"endpoints"`
+}
+
+// EndpointInfo is the result of dialing one "host:port" endpoint.
+// LatencyMS is the time to establish a TCP connection in milliseconds, and
+// is zero if none was made.
+type EndpointInfo struct {
+	Address   string  `json:"address"`
+	Reachable bool    `json:"reachable"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// SetEndpoints changes the "host:port" endpoints the connectivity check
+// dials
+func (c *Collector) SetEndpoints(endpoints []string) {
+	c.endpoints = endpoints
+}
+
+// collectConnectivity dials every endpoint in parallel, each with its own
+// timeout, so one that hangs doesn't hold up the rest
+func (c *Collector) collectConnectivity() ConnectivityInfo {
+	info := ConnectivityInfo{Endpoints: make([]EndpointInfo, len(c.endpoints))}
+	if c.offline {
+		info.Skipped = true
+		info.Endpoints = []EndpointInfo{}
+		return info
+	}
+
+	var wg sync.WaitGroup
+	for i, addr := range c.endpoints {
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			info.Endpoints[i] = dialEndpoint(addr, c.dialTimeout)
+		}(i, addr)
+	}
+	wg.Wait()
+	return info
+}
+
+// dialEndpoint opens and immediately closes a TCP connection to addr
+func dialEndpoint(addr string, timeout time.Duration) EndpointInfo {
+	result := EndpointInfo{Address: addr}
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.LatencyMS = float64(time.Since(start)) / float64(time.Millisecond)
+	result.Reachable = true
+	conn.Close()
+	return result
+}
@@ -0,0 +1,68 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dlorenc/multiclaude/pkg/config"
+)
+
+func TestCollectConnectivity(t *testing.T) {
+	open, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer open.Close()
+
+	// Grab a free port, then close it so nothing is listening there
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	c := NewCollector(config.NewTestPaths(t.TempDir()), "test")
+	c.SetEndpoints([]string{open.Addr().String(), closedAddr})
+	c.dialTimeout = time.Second
+
+	info := c.collectConnectivity()
+	if info.Skipped {
+		t.Fatal("Skipped = true while online")
+	}
+	if len(info.Endpoints) != 2 {
+		t.Fatalf("got %d endpoints, want 2", len(info.Endpoints))
+	}
+
+	up := info.Endpoints[0]
+	if up.Address != open.Addr().String() || !up.Reachable || up.LatencyMS <= 0 || up.Error != "" {
+		t.Errorf("listening endpoint = %+v, want reachable with a latency", up)
+	}
+
+	down := info.Endpoints[1]
+	if down.Address != closedAddr || down.Reachable || down.LatencyMS != 0 || down.Error == "" {
+		t.Errorf("closed endpoint = %+v, want unreachable with an error", down)
+	}
+}
+
+func TestEndpointInfoJSON(t *testing.T) {
+	data, err := json.Marshal(EndpointInfo{Address: "github.com:443", Reachable: true, LatencyMS: 23.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"address":"github.com:443","reachable":true,"latency_ms":23.5}`; string(data) != want {
+		t.Errorf("json.Marshal() = %s, want %s", data, want)
+	}
+}
+
+func TestCollectConnectivityOffline(t *testing.T) {
+	c := NewCollector(config.NewTestPaths(t.TempDir()), "test")
+	c.SetOffline(true)
+
+	info := c.collectConnectivity()
+	if !info.Skipped || len(info.Endpoints) != 0 {
+		t.Errorf("offline connectivity = %+v, want skipped with no endpoints", info)
+	}
+}
@@ -0,0 +1,103 @@
+package diagnostics
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+// DefaultDiskWalkTimeout bounds how long sizing each directory may take
+const DefaultDiskWalkTimeout = 10 * time.Second
+
+// DefaultDiskWalkEntries is how many files and directories a directory walk
+// visits before it stops counting. A walk costs a stat per entry whatever
+// the files hold, and past this point the exact figure matters less than
+// knowing the directory is large.
+const DefaultDiskWalkEntries = 500000
+
+// DiskInfo reports space on the filesystem holding the multiclaude root and
+// how much of it the repos, worktrees and output directories take up
+type DiskInfo struct {
+	TotalBytes uint64 `json:"total_bytes"`
+	UsedBytes  uint64 `json:"used_bytes"`
+	FreeBytes  uint64 `json:"free_bytes"` // Available to unprivileged users
+	// Error explains why the filesystem figures are missing
+	Error string `json:"error,omitempty"`
+
+	Repos     DirUsage `json:"repos"`
+	Worktrees DirUsage `json:"worktrees"`
+	Output    DirUsage `json:"output"`
+}
+
+// DirUsage is the total size of the regular files under a directory.
+// Truncated is set when the walk hit the entry cap or timeout, in which case
+// Bytes is a lower bound.
+type DirUsage struct {
+	Path      string `json:"path"`
+	Bytes     int64  `json:"bytes"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// errWalkLimit stops a directory walk that hit its cap or deadline
+var errWalkLimit = errors.New("walk limit reached")
+
+// collectDisk gathers filesystem usage for the multiclaude root and sizes
+// the directories that grow with use
+func (c *Collector) collectDisk() DiskInfo {
+	var info DiskInfo
+	if usage, err := statFS(c.paths.Root); err != nil {
+		info.Error = err.Error()
+	} else {
+		info.TotalBytes = usage.total
+		info.UsedBytes = usage.total - usage.free
+		info.FreeBytes = usage.available
+	}
+
+	info.Repos = c.dirUsage(c.paths.ReposDir)
+	info.Worktrees = c.dirUsage(c.paths.WorktreesDir)
+	info.Output = c.dirUsage(c.paths.OutputDir)
+	return info
+}
+
+// dirUsage adds up the sizes of regular files under path, without following
+// symlinks, stopping at the collector's entry cap or timeout. Entries that
+// can't be read are skipped, and a missing directory has size zero.
+func (c *Collector) dirUsage(path string) DirUsage {
+	usage := DirUsage{Path: path}
+	deadline := c.now().Add(c.diskTimeout)
+	entries := 0
+
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		entries++
+		if entries > c.diskMaxEntries || c.now().After(deadline) {
+			return errWalkLimit
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		usage.Bytes += fi.Size()
+		return nil
+	})
+	if errors.Is(err, errWalkLimit) {
+		usage.Truncated = true
+	}
+	return usage
+}
+
+// fsUsage is what statFS reports about a filesystem, in bytes
+type fsUsage struct {
+	total     uint64
+	free      uint64
+	available uint64
+}
@@ -0,0 +1,117 @@
+package diagnostics
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/dlorenc/multiclaude/pkg/config"
+)
+
+// writeSized creates a file of exactly size bytes, making parent directories
+func writeSized(t *testing.T, path string, size int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectDisk(t *testing.T) {
+	paths := config.NewTestPaths(t.TempDir())
+	writeSized(t, filepath.Join(paths.ReposDir, "app", "main.go"), 1000)
+	writeSized(t, filepath.Join(paths.ReposDir, "app", ".git", "objects", "ab", "cdef"), 234)
+	writeSized(t, filepath.Join(paths.WorktreesDir, "app", "worker", "big.bin"), 4096)
+	writeSized(t, filepath.Join(paths.OutputDir, "app", "worker.log"), 10)
+
+	// A symlink is not followed, so its target is counted once
+	if err := os.Symlink(filepath.Join(paths.WorktreesDir, "app", "worker", "big.bin"), filepath.Join(paths.OutputDir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	info := NewCollector(paths, "test").collectDisk()
+
+	if info.Repos.Bytes != 1234 {
+		t.Errorf("Repos.Bytes = %d, want 1234", info.Repos.Bytes)
+	}
+	if info.Worktrees.Bytes != 4096 {
+		t.Errorf("Worktrees.Bytes = %d, want 4096", info.Worktrees.Bytes)
+	}
+	if info.Output.Bytes != 10 {
+		t.Errorf("Output.Bytes = %d, want 10", info.Output.Bytes)
+	}
+	if info.Repos.Truncated || info.Worktrees.Truncated || info.Output.Truncated {
+		t.Errorf("walks truncated: %+v", info)
+	}
+	if info.Repos.Path != paths.ReposDir {
+		t.Errorf("Repos.Path = %q, want %q", info.Repos.Path, paths.ReposDir)
+	}
+
+	if runtime.GOOS == "linux" || runtime.GOOS == "darwin" {
+		if info.Error != "" {
+			t.Fatalf("Error = %q", info.Error)
+		}
+		if info.TotalBytes == 0 || info.UsedBytes > info.TotalBytes || info.FreeBytes > info.TotalBytes {
+			t.Errorf("implausible filesystem figures: %+v", info)
+		}
+	}
+}
+
+func TestDirUsageMissingDirectory(t *testing.T) {
+	c := NewCollector(config.NewTestPaths(t.TempDir()), "test")
+	usage := c.dirUsage(filepath.Join(t.TempDir(), "missing"))
+	if usage.Bytes != 0 || usage.Truncated {
+		t.Errorf("dirUsage(missing) = %+v, want zero", usage)
+	}
+}
+
+func TestDirUsageEntryCap(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a", "b", "c", "d"} {
+		writeSized(t, filepath.Join(dir, name), 100)
+	}
+
+	c := NewCollector(config.NewTestPaths(t.TempDir()), "test")
+	// The directory itself and two of its files
+	c.diskMaxEntries = 3
+	usage := c.dirUsage(dir)
+	if !usage.Truncated {
+		t.Error("walk past the cap should be truncated")
+	}
+	if usage.Bytes != 200 {
+		t.Errorf("Bytes = %d, want 200 from the files walked before the cap", usage.Bytes)
+	}
+
+	c.diskMaxEntries = 5
+	if usage := c.dirUsage(dir); usage.Truncated || usage.Bytes != 400 {
+		t.Errorf("dirUsage() within the cap = %+v, want 400 bytes untruncated", usage)
+	}
+}
+
+func TestDirUsageTimeout(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a", "b", "c", "d"} {
+		writeSized(t, filepath.Join(dir, name), 100)
+	}
+
+	// Each look at the clock moves it forward a second
+	c := NewCollector(config.NewTestPaths(t.TempDir()), "test")
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	c.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	c.diskTimeout = 2500 * time.Millisecond
+
+	usage := c.dirUsage(dir)
+	if !usage.Truncated {
+		t.Error("walk past the timeout should be truncated")
+	}
+	if usage.Bytes >= 400 {
+		t.Errorf("Bytes = %d, want the walk stopped early", usage.Bytes)
+	}
+}
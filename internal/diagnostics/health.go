@@ -0,0 +1,197 @@
+package diagnostics
+
+import (
+	"fmt"
+
+	"github.com/dlorenc/multiclaude/internal/procstat"
+)
+
+// Severity ranks how much a health finding matters
+type Severity string
+
+const (
+	// SeverityInfo is worth knowing but needs no action
+	SeverityInfo Severity = "info"
+	// SeverityWarn means something works less well than it should
+	SeverityWarn Severity = "warn"
+	// SeverityError means multiclaude cannot work properly until it is fixed
+	SeverityError Severity = "error"
+)
+
+// Overall health statuses
+const (
+	HealthHealthy   = "healthy"   // No warnings or errors
+	HealthDegraded  = "degraded"  // At least one warning
+	HealthUnhealthy = "unhealthy" // At least one error
+)
+
+// LowDiskSpace is the free space below which the disk is flagged
+const LowDiskSpace = 1 << 30 // 1 GiB
+
+// Finding is one problem, or notable fact, found in a Report
+type Finding struct {
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"` // Stable identifier for scripts
+	Message  string   `json:"message"`
+}
+
+// HealthInfo summarizes what is wrong, if anything
+type HealthInfo struct {
+	Status   string    `json:"status"`
+	Findings []Finding `json:"findings"`
+}
+
+// healthCheck inspects a report and returns any findings
+type healthCheck func(r *Report) []Finding
+
+// healthChecks are run in order by assessHealth. To add a rule, write a
+// healthCheck and list it here.
+var healthChecks = []healthCheck{
+	checkClaudeInstalled,
+	checkTmuxInstalled,
+	checkGitInstalled,
+	checkTaskManagement,
+	checkDaemon,
+	checkAgentProcesses,
+	checkBrokenSymlinks,
+	checkClockSkew,
+	checkDiskSpace,
+	checkOrphans,
+	checkTailscale,
+	checkConnectivity,
+}
+
+// assessHealth runs every health check against r and derives the overall
+// status from the most severe finding
+func assessHealth(r *Report) HealthInfo {
+	health := HealthInfo{Status: HealthHealthy, Findings: []Finding{}}
+	for _, check := range healthChecks {
+		for _, f := range check(r) {
+			health.Findings = append(health.Findings, f)
+			switch {
+			case f.Severity == SeverityError:
+				health.Status = HealthUnhealthy
+			case f.Severity == SeverityWarn && health.Status == HealthHealthy:
+				health.Status = HealthDegraded
+			}
+		}
+	}
+	return health
+}
+
+// finding is shorthand for a single-finding result
+func finding(severity Severity, code, format string, args ...interface{}) []Finding {
+	return []Finding{{Severity: severity, Code: code, Message: fmt.Sprintf(format, args...)}}
+}
+
+func checkClaudeInstalled(r *Report) []Finding {
+	if r.Capabilities.ClaudeInstalled {
+		return nil
+	}
+	return finding(SeverityError, "claude_missing", "claude CLI not found on PATH; agents cannot start")
+}
+
+func checkTmuxInstalled(r *Report) []Finding {
+	if r.Capabilities.TmuxInstalled {
+		return nil
+	}
+	return finding(SeverityError, "tmux_missing", "tmux not found (%s); agents cannot run", r.Tools.Tmux)
+}
+
+func checkGitInstalled(r *Report) []Finding {
+	if r.Capabilities.GitInstalled {
+		return nil
+	}
+	return finding(SeverityError, "git_missing", "git not found (%s); worktrees cannot be created", r.Tools.Git)
+}
+
+func checkTaskManagement(r *Report) []Finding {
+	if !r.Capabilities.ClaudeInstalled || r.Capabilities.TaskManagement {
+		return nil
+	}
+	return finding(SeverityWarn, "task_management_unsupported",
+		"claude %s does not support task management; upgrade to 2.0 or later", r.Tools.Claude.Version)
+}
+
+func checkDaemon(r *Report) []Finding {
+	switch {
+	case r.Daemon.Running:
+		return nil
+	case r.Daemon.Zombie:
+		return finding(SeverityWarn, "daemon_zombie", "daemon process %d has exited but was not reaped; run 'multiclaude start'", r.Daemon.PID)
+	case r.Daemon.PID != 0:
+		return finding(SeverityWarn, "daemon_stale_pid", "PID file names process %d, which is not running; run 'multiclaude start'", r.Daemon.PID)
+	default:
+		return finding(SeverityInfo, "daemon_stopped", "daemon is not running")
+	}
+}
+
+func checkAgentProcesses(r *Report) []Finding {
+	var findings []Finding
+	for _, a := range r.Agents {
+		if a.Process == "" || a.Process == string(procstat.StatusRunning) {
+			continue
+		}
+		findings = append(findings, finding(SeverityWarn, "agent_process_dead",
+			"agent %s/%s has process status %q", a.Repo, a.Name, a.Process)...)
+	}
+	return findings
+}
+
+func checkBrokenSymlinks(r *Report) []Finding {
+	var findings []Finding
+	for _, l := range r.Worktrees.BrokenSymlinks {
+		findings = append(findings, finding(SeverityWarn, "worktree_broken_symlink",
+			"worktree path %s points to missing %s", l.Path, l.Target)...)
+	}
+	return findings
+}
+
+func checkClockSkew(r *Report) []Finding {
+	if r.Clock.Warning == "" {
+		return nil
+	}
+	return finding(SeverityWarn, "clock_skew", "%s", r.Clock.Warning)
+}
+
+func checkDiskSpace(r *Report) []Finding {
+	if r.Disk.TotalBytes == 0 || r.Disk.FreeBytes >= LowDiskSpace {
+		return nil
+	}
+	return finding(SeverityWarn, "disk_low", "only %d MiB free on the filesystem holding %s",
+		r.Disk.FreeBytes>>20, r.Environment.Paths.Root)
+}
+
+func checkOrphans(r *Report) []Finding {
+	if n := r.Orphans.Total(); n > 0 {
+		return finding(SeverityInfo, "orphans_found", "%d orphaned resource(s) left behind; run 'multiclaude cleanup --dry-run' to review", n)
+	}
+	return nil
+}
+
+func checkTailscale(r *Report) []Finding {
+	ts := r.Tailscale
+	switch {
+	case !ts.Installed:
+		return nil
+	case ts.Error != "":
+		return finding(SeverityWarn, "tailscale_status_unknown", "could not read tailscale status: %s", ts.Error)
+	case ts.KeyExpired:
+		return finding(SeverityWarn, "tailscale_key_expired", "tailscale node key expired; run 'tailscale up' to re-authenticate")
+	case ts.BackendState != "Running":
+		return finding(SeverityWarn, "tailscale_down", "tailscale is %s; SSH over the tailnet will not work", ts.BackendState)
+	}
+	return nil
+}
+
+func checkConnectivity(r *Report) []Finding {
+	var findings []Finding
+	for _, e := range r.Connectivity.Endpoints {
+		if e.Reachable {
+			continue
+		}
+		findings = append(findings, finding(SeverityWarn, "endpoint_unreachable",
+			"cannot reach %s: %s", e.Address, e.Error)...)
+	}
+	return findings
+}
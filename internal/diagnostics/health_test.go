@@ -0,0 +1,140 @@
+package diagnostics
+
+import (
+	"testing"
+)
+
+// healthyReport returns a report with nothing wrong in it
+func healthyReport() *Report {
+	return &Report{
+		Capabilities: CapabilitiesInfo{
+			TaskManagement:  true,
+			ClaudeInstalled: true,
+			TmuxInstalled:   true,
+			GitInstalled:    true,
+		},
+		Daemon: DaemonInfo{Running: true, PID: 1234},
+		Disk:   DiskInfo{TotalBytes: 100 << 30, FreeBytes: 50 << 30},
+	}
+}
+
+// codes returns the finding codes in order
+func codes(h HealthInfo) []string {
+	var out []string
+	for _, f := range h.Findings {
+		out = append(out, f.Code)
+	}
+	return out
+}
+
+func TestAssessHealth(t *testing.T) {
+	tests := []struct {
+		name       string
+		modify     func(r *Report)
+		wantStatus string
+		wantCodes  []string
+	}{
+		{
+			name:       "healthy",
+			modify:     func(r *Report) {},
+			wantStatus: HealthHealthy,
+		},
+		{
+			name:       "daemon stopped is only informational",
+			modify:     func(r *Report) { r.Daemon = DaemonInfo{} },
+			wantStatus: HealthHealthy,
+			wantCodes:  []string{"daemon_stopped"},
+		},
+		{
+			name:       "stale daemon PID file",
+			modify:     func(r *Report) { r.Daemon = DaemonInfo{PID: 4242} },
+			wantStatus: HealthDegraded,
+			wantCodes:  []string{"daemon_stale_pid"},
+		},
+		{
+			name: "old claude without task management",
+			modify: func(r *Report) {
+				r.Capabilities.TaskManagement = false
+				r.Tools.Claude.Version = "1.0.3"
+			},
+			wantStatus: HealthDegraded,
+			wantCodes:  []string{"task_management_unsupported"},
+		},
+		{
+			name: "claude missing outweighs warnings",
+			modify: func(r *Report) {
+				r.Capabilities.ClaudeInstalled = false
+				r.Capabilities.TaskManagement = false
+				r.Clock.Warning = "local clock differs from NTP by 5m0s"
+			},
+			wantStatus: HealthUnhealthy,
+			wantCodes:  []string{"claude_missing", "clock_skew"},
+		},
+		{
+			name: "dead agent and broken worktree",
+			modify: func(r *Report) {
+				r.Agents = []AgentInfo{
+					{Repo: "app", Name: "ok", Process: "running"},
+					{Repo: "app", Name: "stuck", Process: "zombie"},
+					{Repo: "app", Name: "nopid"},
+				}
+				r.Worktrees.BrokenSymlinks = []BrokenSymlinkInfo{{Repo: "app", Path: "/wt/app/x", Target: "/gone"}}
+			},
+			wantStatus: HealthDegraded,
+			wantCodes:  []string{"agent_process_dead", "worktree_broken_symlink"},
+		},
+		{
+			name:       "low disk space",
+			modify:     func(r *Report) { r.Disk.FreeBytes = 200 << 20 },
+			wantStatus: HealthDegraded,
+			wantCodes:  []string{"disk_low"},
+		},
+		{
+			name:       "tailscale not installed is fine",
+			modify:     func(r *Report) { r.Tailscale = TailscaleInfo{} },
+			wantStatus: HealthHealthy,
+		},
+		{
+			name:       "tailscale logged out",
+			modify:     func(r *Report) { r.Tailscale = TailscaleInfo{Installed: true, BackendState: "NeedsLogin"} },
+			wantStatus: HealthDegraded,
+			wantCodes:  []string{"tailscale_down"},
+		},
+		{
+			name: "tailscale key expired",
+			modify: func(r *Report) {
+				r.Tailscale = TailscaleInfo{Installed: true, BackendState: "Running", KeyExpired: true}
+			},
+			wantStatus: HealthDegraded,
+			wantCodes:  []string{"tailscale_key_expired"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := healthyReport()
+			tt.modify(r)
+			health := assessHealth(r)
+
+			if health.Status != tt.wantStatus {
+				t.Errorf("Status = %q, want %q (findings %+v)", health.Status, tt.wantStatus, health.Findings)
+			}
+			got := codes(health)
+			if len(got) != len(tt.wantCodes) {
+				t.Fatalf("codes = %v, want %v", got, tt.wantCodes)
+			}
+			for i := range got {
+				if got[i] != tt.wantCodes[i] {
+					t.Errorf("codes = %v, want %v", got, tt.wantCodes)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestAssessHealthFindingsNeverNil(t *testing.T) {
+	if h := assessHealth(healthyReport()); h.Findings == nil {
+		t.Error("Findings is nil; it should marshal as an empty list")
+	}
+}
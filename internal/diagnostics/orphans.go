@@ -0,0 +1,56 @@
+package diagnostics
+
+import (
+	"context"
+
+	"github.com/dlorenc/multiclaude/internal/cleanup"
+	"github.com/dlorenc/multiclaude/internal/state"
+)
+
+// OrphansInfo lists resources left behind by agents that are gone, as found
+// by cleanup.FindOrphans. Nothing is removed; 'multiclaude cleanup' does
+// that.
+type OrphansInfo struct {
+	Sessions   []string `json:"sessions"`    // tmux sessions for untracked repos
+	Worktrees  []string `json:"worktrees"`   // worktree directories no agent uses
+	DeadAgents []string `json:"dead_agents"` // "<repo>/<agent>" whose PID is not running
+	StaleFiles []string `json:"stale_files"` // lock, socket, PID, and temp files nothing owns
+	// Error explains why orphans could not be looked for
+	Error string `json:"error,omitempty"`
+}
+
+// Total returns the number of orphans found
+func (o OrphansInfo) Total() int {
+	return len(o.Sessions) + len(o.Worktrees) + len(o.DeadAgents) + len(o.StaleFiles)
+}
+
+// collectOrphans cross-references tmux sessions and worktree directories
+// against the agents in state
+func (c *Collector) collectOrphans() OrphansInfo {
+	info := OrphansInfo{
+		Sessions:   []string{},
+		Worktrees:  []string{},
+		DeadAgents: []string{},
+		StaleFiles: []string{},
+	}
+
+	st, err := state.Load(c.paths.StateFile)
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.toolTimeout)
+	defer cancel()
+	orphans, err := cleanup.New(st, c.tmux, c.paths).FindOrphans(ctx)
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+
+	info.Sessions = orphans.Sessions
+	info.Worktrees = orphans.Worktrees
+	info.DeadAgents = orphans.DeadAgents
+	info.StaleFiles = orphans.StaleFiles
+	return info
+}
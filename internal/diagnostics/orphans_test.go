@@ -0,0 +1,107 @@
+package diagnostics
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dlorenc/multiclaude/internal/state"
+	"github.com/dlorenc/multiclaude/pkg/config"
+)
+
+// fakeTmux reports a fixed list of sessions, all marked with root
+type fakeTmux struct {
+	sessions []string
+	root     string
+	err      error
+}
+
+func (f *fakeTmux) HasSession(ctx context.Context, name string) (bool, error) { return true, nil }
+func (f *fakeTmux) HasWindow(ctx context.Context, session, windowName string) (bool, error) {
+	return true, nil
+}
+func (f *fakeTmux) KillSession(ctx context.Context, name string) error { return nil }
+func (f *fakeTmux) KillWindow(ctx context.Context, session, windowName string) error {
+	return nil
+}
+func (f *fakeTmux) ListSessions(ctx context.Context) ([]string, error) { return f.sessions, f.err }
+func (f *fakeTmux) GetEnvironment(ctx context.Context, session, name string) (string, error) {
+	return f.root, nil
+}
+
+func TestCollectOrphans(t *testing.T) {
+	paths := config.NewTestPaths(t.TempDir())
+
+	agentWorktree := paths.AgentWorktree("app", "worker1")
+	strayWorktree := paths.AgentWorktree("app", "crashed")
+	for _, dir := range []string{agentWorktree, strayWorktree} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	st := state.New(paths.StateFile)
+	if err := st.AddRepo("app", &state.Repository{
+		TmuxSession: "mc-app",
+		Agents:      make(map[string]state.Agent),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.AddAgent("app", "worker1", state.Agent{
+		Type:         state.AgentTypeWorker,
+		WorktreePath: agentWorktree,
+		TmuxWindow:   "worker1",
+		CreatedAt:    time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewCollector(paths, "test")
+	c.tmux = &fakeTmux{sessions: []string{"mc-app", "mc-gone", "unrelated"}, root: paths.Root}
+	info := c.collectOrphans()
+
+	if info.Error != "" {
+		t.Fatalf("Error = %q", info.Error)
+	}
+	if want := []string{strayWorktree}; !reflect.DeepEqual(info.Worktrees, want) {
+		t.Errorf("Worktrees = %v, want %v", info.Worktrees, want)
+	}
+	if want := []string{"mc-gone"}; !reflect.DeepEqual(info.Sessions, want) {
+		t.Errorf("Sessions = %v, want %v", info.Sessions, want)
+	}
+	if info.Total() != 2 {
+		t.Errorf("Total() = %d, want 2", info.Total())
+	}
+}
+
+func TestCollectOrphansTmuxFailure(t *testing.T) {
+	c := NewCollector(config.NewTestPaths(t.TempDir()), "test")
+	c.tmux = &fakeTmux{err: errors.New("tmux not found")}
+
+	info := c.collectOrphans()
+	if info.Error == "" {
+		t.Error("Error should explain why orphans could not be listed")
+	}
+	if info.Worktrees == nil || info.Sessions == nil {
+		t.Error("lists should be empty, not nil, so they marshal as []")
+	}
+}
+
+func TestCollectOrphansUnusedRepoDir(t *testing.T) {
+	paths := config.NewTestPaths(t.TempDir())
+	untracked := filepath.Join(paths.WorktreesDir, "deleted-repo")
+	if err := os.MkdirAll(filepath.Join(untracked, "worker"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewCollector(paths, "test")
+	c.tmux = &fakeTmux{}
+	info := c.collectOrphans()
+	if want := []string{untracked}; !reflect.DeepEqual(info.Worktrees, want) {
+		t.Errorf("Worktrees = %v, want %v", info.Worktrees, want)
+	}
+}
@@ -0,0 +1,50 @@
+package diagnostics
+
+import (
+	"regexp"
+
+	"github.com/dlorenc/multiclaude/internal/redact"
+)
+
+// RedactionPolicy decides which environment variable values a report hides.
+// A value is redacted if its name matches a NamePattern or the value itself
+// matches a ValuePattern, unless the name is in Safelist.
+type RedactionPolicy struct {
+	// NamePatterns match names of variables that hold secrets
+	NamePatterns []*regexp.Regexp
+	// ValuePatterns match values that look like secrets whatever the
+	// variable is called
+	ValuePatterns []*regexp.Regexp
+	// Safelist names variables that are never redacted
+	Safelist []string
+}
+
+// DefaultRedactionPolicy returns the policy used when none is given. It
+// redacts variables by redact.SensitiveNamePattern, the same rule as
+// redact.IsSensitiveEnv. Add redact.TokenPatterns to a policy's
+// ValuePatterns to catch secrets stored under innocuous names.
+func DefaultRedactionPolicy() RedactionPolicy {
+	return RedactionPolicy{
+		NamePatterns: []*regexp.Regexp{redact.SensitiveNamePattern},
+	}
+}
+
+// Redact returns value, or redact.Redacted if the policy hides it
+func (p RedactionPolicy) Redact(name, value string) string {
+	for _, safe := range p.Safelist {
+		if name == safe {
+			return value
+		}
+	}
+	for _, re := range p.NamePatterns {
+		if re.MatchString(name) {
+			return redact.Redacted
+		}
+	}
+	for _, re := range p.ValuePatterns {
+		if re.MatchString(value) {
+			return redact.Redacted
+		}
+	}
+	return value
+}
@@ -0,0 +1,55 @@
+package diagnostics
+
+import (
+	"testing"
+
+	"github.com/dlorenc/multiclaude/internal/redact"
+	"github.com/dlorenc/multiclaude/pkg/config"
+)
+
+func TestRedactionPolicy(t *testing.T) {
+	token := "ghp_" + "abcdefghijklmnopqrstuvwxyz0123456789"
+	withTokens := DefaultRedactionPolicy()
+	withTokens.ValuePatterns = redact.TokenPatterns
+	safelisted := withTokens
+	safelisted.Safelist = []string{"TERM"}
+
+	tests := []struct {
+		name   string
+		policy RedactionPolicy
+		env    string
+		value  string
+		want   string
+	}{
+		{"default redacts by name", DefaultRedactionPolicy(), "CLAUDE_CODE_OAUTH_TOKEN", "abc", redact.Redacted},
+		{"default keeps benign names", DefaultRedactionPolicy(), "TERM", token, token},
+		{"token-shaped value under benign name", withTokens, "TERM", token, redact.Redacted},
+		{"token embedded in a longer value", withTokens, "PATH", "/usr/bin:/opt/" + token, redact.Redacted},
+		{"ordinary value", withTokens, "TERM", "xterm-256color", "xterm-256color"},
+		{"safelist wins", safelisted, "TERM", token, token},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Redact(tt.env, tt.value); got != tt.want {
+				t.Errorf("Redact(%q, %q) = %q, want %q", tt.env, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectorRedactionOption(t *testing.T) {
+	token := "sk-ant-" + "api03-abcdefghijklmnopqrstuvwxyz"
+	t.Setenv("TERM", token)
+	paths := config.NewTestPaths(t.TempDir())
+
+	if got := NewCollector(paths, "test").collectEnvironment().Variables["TERM"]; got != token {
+		t.Errorf("default collector TERM = %q, want it unchanged", got)
+	}
+
+	policy := DefaultRedactionPolicy()
+	policy.ValuePatterns = redact.TokenPatterns
+	c := NewCollectorWithOptions(paths, "test", CollectorOptions{Redaction: &policy})
+	if got := c.collectEnvironment().Variables["TERM"]; got != redact.Redacted {
+		t.Errorf("TERM = %q, want %q", got, redact.Redacted)
+	}
+}
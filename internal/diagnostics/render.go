@@ -0,0 +1,388 @@
+package diagnostics
+
+import (
+	htmltemplate "html/template"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/dlorenc/multiclaude/internal/state"
+)
+
+// section is one titled table of a rendered report. Both renderers work
+// from the same sections, so Markdown and HTML always show the same data.
+type section struct {
+	Title   string
+	Note    string // Shown above the table, or alone when there are no rows
+	Headers []string
+	Rows    []row
+}
+
+// row is one table row. Severity is set on health findings so renderers
+// can highlight them.
+type row struct {
+	Severity Severity
+	Cells    []string
+}
+
+var markdownTemplate = template.Must(template.New("markdown").Funcs(template.FuncMap{
+	"cell":   markdownCell,
+	"marker": severityMarker,
+	"rule":   func(headers []string) string { return strings.Repeat("|---", len(headers)) + "|" },
+}).Parse(`# Multiclaude Diagnostics
+{{range .}}
+## {{.Title}}
+{{if .Note}}
+{{cell .Note}}
+{{end}}{{if .Rows}}
+|{{range .Headers}} {{.}} |{{end}}
+{{rule .Headers}}
+{{range .Rows}}|{{$sev := .Severity}}{{range $i, $c := .Cells}} {{if and $sev (eq $i 0)}}{{marker $sev}}{{cell $c}}{{marker $sev}}{{else}}{{cell $c}}{{end}} |{{end}}
+{{end}}{{end}}{{end}}`))
+
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("html").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Multiclaude Diagnostics</title>
+<style>
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+tr.error { background: #fdd; }
+tr.warn { background: #ffc; }
+</style>
+</head>
+<body>
+<h1>Multiclaude Diagnostics</h1>
+{{range .}}
+<h2>{{.Title}}</h2>
+{{if .Note}}<p>{{.Note}}</p>
+{{end}}{{if .Rows}}<table>
+<tr>{{range .Headers}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr{{if .Severity}} class="{{.Severity}}"{{end}}>{{range .Cells}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+{{end}}{{end}}</body>
+</html>
+`))
+
+// ToMarkdown renders the report as Markdown tables suitable for pasting into
+// an issue. Health findings come first, with warnings and errors in bold.
+func (r *Report) ToMarkdown() (string, error) {
+	var sb strings.Builder
+	if err := markdownTemplate.Execute(&sb, r.sections()); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// ToHTML renders the report as a standalone HTML page. Health findings come
+// first, with warning and error rows highlighted.
+func (r *Report) ToHTML() (string, error) {
+	var sb strings.Builder
+	if err := htmlTemplate.Execute(&sb, r.sections()); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// sections lays the report out as tables. Map entries are sorted so the
+// output is deterministic.
+func (r *Report) sections() []section {
+	health := section{
+		Title:   "Health",
+		Note:    "Status: " + r.Health.Status,
+		Headers: []string{"Severity", "Code", "Message"},
+	}
+	for _, f := range r.Health.Findings {
+		health.Rows = append(health.Rows, row{
+			Severity: f.Severity,
+			Cells:    []string{string(f.Severity), f.Code, f.Message},
+		})
+	}
+
+	version := section{
+		Title:   "Version",
+		Headers: []string{"Property", "Value"},
+		Rows: []row{
+			cells("multiclaude", r.Version.Multiclaude),
+			cells("Go", r.Version.Go),
+			cells("Development build", yesNo(r.Version.IsDev)),
+		},
+	}
+
+	paths := r.Environment.Paths
+	environment := section{
+		Title:   "Environment",
+		Headers: []string{"Property", "Value"},
+		Rows: []row{
+			cells("OS", r.Environment.OS),
+			cells("Architecture", r.Environment.Arch),
+			cells("Home directory", r.Environment.HomeDir),
+			cells("Root", paths.Root),
+			cells("State file", paths.StateFile),
+			cells("Daemon PID file", paths.DaemonPID),
+			cells("Daemon socket", paths.DaemonSock),
+			cells("Daemon log", paths.DaemonLog),
+			cells("Repos directory", paths.ReposDir),
+			cells("Worktrees directory", paths.WorktreesDir),
+			cells("Output directory", paths.OutputDir),
+			cells("Messages directory", paths.MessagesDir),
+		},
+	}
+	for _, name := range sortedKeys(r.Environment.Variables) {
+		environment.Rows = append(environment.Rows, cells("$"+name, r.Environment.Variables[name]))
+	}
+
+	claude := r.Tools.Claude
+	tools := section{
+		Title:   "Tools",
+		Headers: []string{"Tool", "Version", "Path"},
+		Rows: []row{
+			cells("claude", orDash(claude.Version), orDash(claude.Path)),
+			cells("tmux", r.Tools.Tmux, "-"),
+			cells("git", r.Tools.Git, "-"),
+		},
+	}
+	for _, key := range sortedKeys(claude.ConfiguredPaths) {
+		tools.Rows = append(tools.Rows, cells("claude ("+key+")", "-", claude.ConfiguredPaths[key]))
+	}
+
+	capabilities := section{
+		Title:   "Capabilities",
+		Headers: []string{"Capability", "Available"},
+		Rows: []row{
+			cells("Task management", yesNo(r.Capabilities.TaskManagement)),
+			cells("claude installed", yesNo(r.Capabilities.ClaudeInstalled)),
+			cells("tmux installed", yesNo(r.Capabilities.TmuxInstalled)),
+			cells("git installed", yesNo(r.Capabilities.GitInstalled)),
+		},
+	}
+
+	daemon := section{
+		Title:   "Daemon",
+		Headers: []string{"Property", "Value"},
+		Rows: []row{
+			cells("Running", yesNo(r.Daemon.Running)),
+			cells("PID", strconv.Itoa(r.Daemon.PID)),
+			cells("Zombie", yesNo(r.Daemon.Zombie)),
+			cells("Started", formatTime(r.Daemon.StartedAt)),
+			cells("Uptime", orDash(r.Daemon.Uptime)),
+		},
+	}
+
+	stats := r.Statistics
+	statistics := section{
+		Title:   "Statistics",
+		Headers: []string{"Metric", "Count"},
+		Rows: []row{
+			cells("Repositories", strconv.Itoa(stats.Repositories)),
+			cells("Workers", strconv.Itoa(stats.Workers)),
+			cells("Supervisors", strconv.Itoa(stats.Supervisors)),
+			cells("Merge queues", strconv.Itoa(stats.MergeQueues)),
+			cells("Workspaces", strconv.Itoa(stats.Workspaces)),
+			cells("Review agents", strconv.Itoa(stats.ReviewAgents)),
+		},
+	}
+	for _, status := range statusOrder {
+		if n, ok := stats.ByStatus[status]; ok {
+			statistics.Rows = append(statistics.Rows, cells("Status: "+string(status), strconv.Itoa(n)))
+		}
+	}
+
+	agents := section{
+		Title:   "Agents",
+		Headers: []string{"Repo", "Name", "Type", "Branch", "Process"},
+	}
+	for _, a := range r.Agents {
+		agents.Rows = append(agents.Rows, cells(a.Repo, a.Name, a.Type, orDash(a.Branch), orDash(a.Process)))
+	}
+	if len(agents.Rows) == 0 {
+		agents.Note = "No agents"
+	}
+
+	worktrees := section{
+		Title:   "Broken Worktree Symlinks",
+		Headers: []string{"Repo", "Agent", "Path", "Target"},
+	}
+	for _, l := range r.Worktrees.BrokenSymlinks {
+		worktrees.Rows = append(worktrees.Rows, cells(l.Repo, orDash(l.Agent), l.Path, l.Target))
+	}
+	if len(worktrees.Rows) == 0 {
+		worktrees.Note = "None"
+	}
+
+	clock := section{
+		Title:   "Clock",
+		Headers: []string{"Property", "Value"},
+		Rows: []row{
+			cells("Local time", formatTime(r.Clock.Local)),
+			cells("Reference", r.Clock.Reference),
+			cells("Skew", r.Clock.Skew.String()),
+			cells("Warning", orDash(r.Clock.Warning)),
+		},
+	}
+
+	disk := section{
+		Title:   "Disk",
+		Note:    r.Disk.Error,
+		Headers: []string{"Usage", "Path", "Size"},
+	}
+	if r.Disk.TotalBytes > 0 {
+		disk.Rows = append(disk.Rows,
+			cells("Filesystem total", paths.Root, formatMiB(int64(r.Disk.TotalBytes), false)),
+			cells("Filesystem used", paths.Root, formatMiB(int64(r.Disk.UsedBytes), false)),
+			cells("Filesystem free", paths.Root, formatMiB(int64(r.Disk.FreeBytes), false)),
+		)
+	}
+	disk.Rows = append(disk.Rows,
+		cells("Repos", r.Disk.Repos.Path, formatMiB(r.Disk.Repos.Bytes, r.Disk.Repos.Truncated)),
+		cells("Worktrees", r.Disk.Worktrees.Path, formatMiB(r.Disk.Worktrees.Bytes, r.Disk.Worktrees.Truncated)),
+		cells("Output", r.Disk.Output.Path, formatMiB(r.Disk.Output.Bytes, r.Disk.Output.Truncated)),
+	)
+
+	orphans := section{
+		Title:   "Orphans",
+		Note:    r.Orphans.Error,
+		Headers: []string{"Kind", "Resource"},
+	}
+	for _, group := range []struct {
+		kind  string
+		items []string
+	}{
+		{"tmux session", r.Orphans.Sessions},
+		{"worktree", r.Orphans.Worktrees},
+		{"dead agent", r.Orphans.DeadAgents},
+		{"stale file", r.Orphans.StaleFiles},
+	} {
+		for _, item := range group.items {
+			orphans.Rows = append(orphans.Rows, cells(group.kind, item))
+		}
+	}
+	if len(orphans.Rows) == 0 && orphans.Note == "" {
+		orphans.Note = "None"
+	}
+
+	ts := r.Tailscale
+	tailscale := section{
+		Title:   "Tailscale",
+		Note:    ts.Error,
+		Headers: []string{"Property", "Value"},
+		Rows:    []row{cells("Installed", yesNo(ts.Installed))},
+	}
+	if ts.Installed && ts.Error == "" {
+		keyExpiry := "-"
+		if ts.KeyExpiry != nil {
+			keyExpiry = formatTime(*ts.KeyExpiry)
+		}
+		tailscale.Rows = append(tailscale.Rows,
+			cells("State", orDash(ts.BackendState)),
+			cells("Online", yesNo(ts.Online)),
+			cells("Host name", orDash(ts.HostName)),
+			cells("IPs", orDash(strings.Join(ts.IPs, ", "))),
+			cells("Key expiry", keyExpiry),
+			cells("Key expired", yesNo(ts.KeyExpired)),
+		)
+		for _, h := range ts.Health {
+			tailscale.Rows = append(tailscale.Rows, cells("Health", h))
+		}
+	}
+
+	connectivity := section{
+		Title:   "Connectivity",
+		Headers: []string{"Endpoint", "Reachable", "Latency", "Error"},
+	}
+	for _, e := range r.Connectivity.Endpoints {
+		latency := "-"
+		if e.Reachable {
+			latency = strconv.FormatFloat(e.LatencyMS, 'f', 0, 64) + "ms"
+		}
+		connectivity.Rows = append(connectivity.Rows, cells(e.Address, yesNo(e.Reachable), latency, orDash(e.Error)))
+	}
+	if r.Connectivity.Skipped {
+		connectivity.Note = "Skipped (offline)"
+	}
+
+	return []section{
+		health, version, environment, tools, capabilities, daemon,
+		statistics, agents, worktrees, clock, disk, orphans, tailscale,
+		connectivity,
+	}
+}
+
+// statusOrder is the order agent status counts are listed in, roughly
+// following an agent's life
+var statusOrder = []state.AgentStatus{
+	state.AgentStatusStarting,
+	state.AgentStatusRunning,
+	state.AgentStatusBlocked,
+	state.AgentStatusCompleted,
+	state.AgentStatusFailed,
+}
+
+// cells builds an unhighlighted row
+func cells(values ...string) row {
+	return row{Cells: values}
+}
+
+// sortedKeys returns m's keys in sorted order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// formatTime renders t in UTC so output doesn't depend on the local zone
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// formatMiB renders a byte count in MiB. A truncated walk is a lower bound.
+func formatMiB(bytes int64, truncated bool) string {
+	s := strconv.FormatInt(bytes>>20, 10) + " MiB"
+	if truncated {
+		s = "at least " + s
+	}
+	return s
+}
+
+// markdownEscaper escapes characters that would end a table cell or be
+// read as inline HTML
+var markdownEscaper = strings.NewReplacer("|", `\|`, "<", "&lt;", ">", "&gt;")
+
+// markdownCell escapes a value for use in a Markdown table cell, keeping it
+// on one line
+func markdownCell(s string) string {
+	return markdownEscaper.Replace(strings.Join(strings.Fields(s), " "))
+}
+
+// severityMarker returns the Markdown emphasis used for a finding's severity
+func severityMarker(sev Severity) string {
+	switch sev {
+	case SeverityError, SeverityWarn:
+		return "**"
+	default:
+		return ""
+	}
+}
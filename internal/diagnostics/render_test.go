@@ -0,0 +1,169 @@
+package diagnostics
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dlorenc/multiclaude/internal/redact"
+	"github.com/dlorenc/multiclaude/internal/state"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// sampleReport returns a report exercising every section, including values
+// that need escaping in Markdown and HTML
+func sampleReport() *Report {
+	root := "/home/dev/.multiclaude"
+	r := &Report{
+		Version: VersionInfo{Multiclaude: "v1.4.0", Go: "go1.22.1"},
+		Environment: EnvironmentInfo{
+			OS:      "linux",
+			Arch:    "amd64",
+			HomeDir: "/home/dev",
+			Paths: PathsInfo{
+				Root:         root,
+				StateFile:    root + "/state.json",
+				DaemonPID:    root + "/daemon.pid",
+				DaemonSock:   root + "/daemon.sock",
+				DaemonLog:    root + "/daemon.log",
+				ReposDir:     root + "/repos",
+				WorktreesDir: root + "/wts",
+				OutputDir:    root + "/output",
+				MessagesDir:  root + "/messages",
+			},
+			Variables: map[string]string{
+				"TERM":                    "xterm-256color",
+				"CLAUDE_CODE_OAUTH_TOKEN": redact.Redacted,
+				"SHELL":                   "/bin/zsh",
+			},
+		},
+		Capabilities: CapabilitiesInfo{ClaudeInstalled: true, TmuxInstalled: true, GitInstalled: true},
+		Tools: ToolsInfo{
+			Claude: ClaudeInfo{
+				Installed: true,
+				Version:   "1.0.3",
+				Path:      "/usr/local/bin/claude",
+				ConfiguredPaths: map[string]string{
+					"web/worker":     "/opt/claude-next",
+					"api/supervisor": "/opt/claude-stable",
+				},
+			},
+			Tmux: "tmux 3.4",
+			Git:  "git version 2.44.0",
+		},
+		Daemon: DaemonInfo{
+			Running:   true,
+			PID:       4242,
+			StartedAt: time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC),
+			Uptime:    "2h0m0s",
+		},
+		Statistics: StatisticsInfo{
+			Repositories: 2,
+			Workers:      2,
+			Supervisors:  1,
+			ByStatus:     map[state.AgentStatus]int{state.AgentStatusRunning: 2, state.AgentStatusBlocked: 1},
+		},
+		Agents: []AgentInfo{
+			{Repo: "api", Name: "supervisor", Type: "supervisor", Process: "running"},
+			{Repo: "web", Name: "happy-fox", Type: "worker", Branch: "work/happy-fox", Process: "zombie"},
+			{Repo: "web", Name: "calm-owl", Type: "worker", Branch: "work/calm-owl"},
+		},
+		Worktrees: WorktreesInfo{BrokenSymlinks: []BrokenSymlinkInfo{
+			{Repo: "web", Agent: "calm-owl", Path: root + "/wts/web/calm-owl", Target: "/mnt/gone"},
+		}},
+		Clock: ClockInfo{
+			Local:     time.Date(2024, 1, 15, 11, 0, 0, 0, time.UTC),
+			Reference: "state",
+			Skew:      90 * time.Second,
+			Warning:   "clock is 1m30s ahead of state file timestamps",
+		},
+		Disk: DiskInfo{
+			TotalBytes: 100 << 30,
+			UsedBytes:  99 << 30,
+			FreeBytes:  512 << 20,
+			Repos:      DirUsage{Path: root + "/repos", Bytes: 300 << 20},
+			Worktrees:  DirUsage{Path: root + "/wts", Bytes: 2 << 30, Truncated: true},
+			Output:     DirUsage{Path: root + "/output", Bytes: 1 << 20},
+		},
+		Orphans: OrphansInfo{
+			Sessions:   []string{"mc-old|repo"},
+			StaleFiles: []string{root + "/<tmp>.lock"},
+		},
+		Tailscale: TailscaleInfo{
+			Installed:    true,
+			BackendState: "Running",
+			Online:       true,
+			HostName:     "multiclaude-dev",
+			IPs:          []string{"100.64.0.7", "fd7a:115c:a1e0::7"},
+			KeyExpiry:    timePtr(time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)),
+		},
+		Connectivity: ConnectivityInfo{Endpoints: []EndpointInfo{
+			{Address: "github.com:443", Reachable: true, LatencyMS: 23.4},
+			{Address: "api.anthropic.com:443", Error: "dial tcp: i/o timeout"},
+		}},
+	}
+	r.Health = assessHealth(r)
+	return r
+}
+
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run with -update to create it): %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s mismatch (run with -update to accept):\n--- got ---\n%s", name, got)
+	}
+}
+
+func TestReportToMarkdown(t *testing.T) {
+	r := sampleReport()
+	got, err := r.ToMarkdown()
+	if err != nil {
+		t.Fatalf("ToMarkdown() failed: %v", err)
+	}
+	checkGolden(t, "report.md", got)
+
+	again, _ := r.ToMarkdown()
+	if again != got {
+		t.Error("ToMarkdown() is not deterministic")
+	}
+}
+
+func TestReportToHTML(t *testing.T) {
+	r := sampleReport()
+	got, err := r.ToHTML()
+	if err != nil {
+		t.Fatalf("ToHTML() failed: %v", err)
+	}
+	checkGolden(t, "report.html", got)
+
+	if strings.Contains(got, "<tmp>") {
+		t.Error("ToHTML() did not escape report values")
+	}
+}
+
+func TestRenderKeepsRedactedValues(t *testing.T) {
+	r := sampleReport()
+	md, _ := r.ToMarkdown()
+	page, _ := r.ToHTML()
+	for name, out := range map[string]string{"markdown": md, "html": page} {
+		if !strings.Contains(out, redact.Redacted) {
+			t.Errorf("%s output lost the redaction marker", name)
+		}
+	}
+}
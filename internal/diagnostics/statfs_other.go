@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package diagnostics
+
+import "errors"
+
+// statFS is not implemented on this platform; directory sizes are still
+// reported
+func statFS(path string) (fsUsage, error) {
+	return fsUsage{}, errors.New("filesystem usage not supported on this platform")
+}
@@ -0,0 +1,19 @@
+//go:build linux || darwin
+
+package diagnostics
+
+import "syscall"
+
+// statFS reports the size and free space of the filesystem holding path
+func statFS(path string) (fsUsage, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return fsUsage{}, err
+	}
+	bsize := uint64(st.Bsize)
+	return fsUsage{
+		total:     st.Blocks * bsize,
+		free:      st.Bfree * bsize,
+		available: st.Bavail * bsize,
+	}, nil
+}
@@ -0,0 +1,90 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/dlorenc/multiclaude/internal/command"
+	"github.com/dlorenc/multiclaude/internal/redact"
+)
+
+// TailscaleInfo reports the state of the Tailscale client. The deploy stack
+// only allows SSH over Tailscale, so when a host can't be reached this says
+// whether the tailnet is the reason.
+type TailscaleInfo struct {
+	Installed bool `json:"installed"`
+	// BackendState is the client's state, e.g. "Running", "Stopped" or
+	// "NeedsLogin"
+	BackendState string     `json:"backend_state,omitempty"`
+	Online       bool       `json:"online"`
+	HostName     string     `json:"host_name,omitempty"`
+	IPs          []string   `json:"ips,omitempty"`
+	KeyExpiry    *time.Time `json:"key_expiry,omitempty"` // nil if the key never expires
+	KeyExpired   bool       `json:"key_expired"`
+	// Health lists the problems the client itself reports
+	Health []string `json:"health,omitempty"`
+	// Error explains why the status could not be read
+	Error string `json:"error,omitempty"`
+}
+
+// tailscaleStatus is the subset of 'tailscale status --json' that
+// diagnostics reads
+type tailscaleStatus struct {
+	BackendState string
+	TailscaleIPs []string
+	Health       []string
+	Self         *struct {
+		HostName     string
+		Online       bool
+		TailscaleIPs []string
+		KeyExpiry    *time.Time
+		Expired      bool
+	}
+}
+
+// collectTailscale reads the Tailscale client's status, if it is installed
+func (c *Collector) collectTailscale() TailscaleInfo {
+	output, err := command.RunWithTimeout(c.runner, c.toolTimeout, "tailscale", "status", "--json")
+	if command.IsNotFound(err) {
+		return TailscaleInfo{Installed: false}
+	}
+
+	info := TailscaleInfo{Installed: true}
+	if command.IsTimeout(err) {
+		info.Error = "timed out"
+		return info
+	}
+
+	// tailscale exits non-zero when it isn't logged in, but still prints
+	// its status, so parse whatever came back before giving up
+	var status tailscaleStatus
+	if jsonErr := json.Unmarshal(output, &status); jsonErr != nil {
+		if err == nil {
+			err = jsonErr
+		}
+		info.Error = redactTailscaleKeys(err.Error())
+		return info
+	}
+
+	info.BackendState = status.BackendState
+	info.IPs = status.TailscaleIPs
+	for _, h := range status.Health {
+		info.Health = append(info.Health, redactTailscaleKeys(h))
+	}
+	if self := status.Self; self != nil {
+		info.HostName = self.HostName
+		info.Online = self.Online
+		if len(info.IPs) == 0 {
+			info.IPs = self.TailscaleIPs
+		}
+		info.KeyExpiry = self.KeyExpiry
+		info.KeyExpired = self.Expired || (info.KeyExpiry != nil && info.KeyExpiry.Before(c.now()))
+	}
+	return info
+}
+
+// redactTailscaleKeys replaces any Tailscale key in s
+func redactTailscaleKeys(s string) string {
+	return redact.TailscaleKeyPattern.ReplaceAllString(strings.TrimSpace(s), redact.Redacted)
+}
@@ -172,6 +172,16 @@ func AgentNotFound(agentType, name, repo string) *CLIError {
 	}
 }
 
+// AgentWindowGone creates an error for when an agent is in state but its
+// tmux session or window no longer exists
+func AgentWindowGone(name, target string) *CLIError {
+	return &CLIError{
+		Category:   CategoryNotFound,
+		Message:    fmt.Sprintf("tmux window '%s' for agent '%s' no longer exists", target, name),
+		Suggestion: "multiclaude reconcile",
+	}
+}
+
 // InvalidPRURL creates an error for invalid PR URLs
 func InvalidPRURL() *CLIError {
 	return &CLIError{
@@ -170,6 +170,18 @@ func TestAgentNotFound(t *testing.T) {
 	}
 }
 
+func TestAgentWindowGone(t *testing.T) {
+	err := AgentWindowGone("test-worker", "mc-repo:test-worker")
+	formatted := Format(err)
+
+	if !strings.Contains(formatted, "mc-repo:test-worker") {
+		t.Errorf("expected tmux target, got: %s", formatted)
+	}
+	if !strings.Contains(formatted, "multiclaude reconcile") {
+		t.Errorf("expected reconcile suggestion, got: %s", formatted)
+	}
+}
+
 func TestInvalidPRURL(t *testing.T) {
 	err := InvalidPRURL()
 	formatted := Format(err)
@@ -0,0 +1,117 @@
+// Package events provides an in-process bus for agent lifecycle events and
+// state changes.
+//
+// Subsystems that react to agent changes (notifications, metrics, logging)
+// subscribe to the bus instead of polling state. State mutators publish to
+// the bus automatically once one is attached with state.SetEventBus.
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Type identifies the kind of lifecycle event
+type Type string
+
+const (
+	// AgentSpawned is published when an agent is registered in state
+	AgentSpawned Type = "agent_spawned"
+	// AgentCompleted is published when an agent finishes without a failure reason
+	AgentCompleted Type = "agent_completed"
+	// AgentFailed is published when an agent finishes with a failure reason
+	AgentFailed Type = "agent_failed"
+
+	// Added is published when a repository or agent is added to state
+	Added Type = "added"
+	// Updated is published when a repository or agent in state is modified
+	Updated Type = "updated"
+	// Removed is published when a repository or agent is removed from state
+	Removed Type = "removed"
+)
+
+// DefaultBufferSize is the subscriber channel capacity used when none is given
+const DefaultBufferSize = 64
+
+// Event describes a change in an agent's lifecycle, or a saved change to a
+// repository or agent
+type Event struct {
+	Type      Type
+	Repo      string
+	Agent     string // Empty for a change to the repository itself
+	AgentType string
+	Reason    string    // Failure reason (AgentFailed only)
+	StartedAt time.Time // When the agent was created
+	Time      time.Time // When the event occurred
+}
+
+// Bus fans published events out to subscribers. Publishing never blocks:
+// a subscriber whose buffer is full misses the event rather than stalling
+// the publisher.
+type Bus struct {
+	mu      sync.RWMutex
+	subs    map[int]chan Event
+	nextID  int
+	dropped atomic.Int64
+}
+
+// NewBus creates an empty event bus
+func NewBus() *Bus {
+	return &Bus{subs: make(map[int]chan Event)}
+}
+
+// Subscribe registers a subscriber with room for buffer pending events
+// (DefaultBufferSize if buffer <= 0). It returns the channel events arrive on
+// and a function that unsubscribes and closes the channel.
+func (b *Bus) Subscribe(buffer int) (<-chan Event, func()) {
+	if buffer <= 0 {
+		buffer = DefaultBufferSize
+	}
+	ch := make(chan Event, buffer)
+
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.subs[id] = ch
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, id)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+	return ch, unsubscribe
+}
+
+// Publish delivers e to every subscriber without blocking. If e.Time is
+// zero it is set to the current time. Publishing to a nil bus is a no-op.
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			b.dropped.Add(1)
+		}
+	}
+}
+
+// Dropped returns how many deliveries were skipped because a subscriber's
+// buffer was full
+func (b *Bus) Dropped() int64 {
+	return b.dropped.Load()
+}
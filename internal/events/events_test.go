@@ -0,0 +1,89 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+func receive(t *testing.T, ch <-chan Event) Event {
+	t.Helper()
+	select {
+	case e := <-ch:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+		return Event{}
+	}
+}
+
+func TestPublishToMultipleSubscribers(t *testing.T) {
+	bus := NewBus()
+	first, unsubFirst := bus.Subscribe(0)
+	defer unsubFirst()
+	second, unsubSecond := bus.Subscribe(0)
+	defer unsubSecond()
+
+	bus.Publish(Event{Type: AgentSpawned, Repo: "repo", Agent: "worker1"})
+
+	for i, ch := range []<-chan Event{first, second} {
+		e := receive(t, ch)
+		if e.Type != AgentSpawned || e.Repo != "repo" || e.Agent != "worker1" {
+			t.Errorf("subscriber %d got %+v", i, e)
+		}
+		if e.Time.IsZero() {
+			t.Errorf("subscriber %d got event without a timestamp", i)
+		}
+	}
+}
+
+func TestPublishDoesNotBlockOnFullSubscriber(t *testing.T) {
+	bus := NewBus()
+	slow, unsubSlow := bus.Subscribe(1)
+	defer unsubSlow()
+	fast, unsubFast := bus.Subscribe(10)
+	defer unsubFast()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			bus.Publish(Event{Type: AgentCompleted, Agent: "worker1"})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Publish() blocked on a subscriber that is not reading")
+	}
+
+	if len(fast) != 5 {
+		t.Errorf("fast subscriber got %d events, want 5", len(fast))
+	}
+	if len(slow) != 1 {
+		t.Errorf("slow subscriber buffered %d events, want 1", len(slow))
+	}
+	if bus.Dropped() != 4 {
+		t.Errorf("Dropped() = %d, want 4", bus.Dropped())
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	bus := NewBus()
+	ch, unsubscribe := bus.Subscribe(0)
+
+	unsubscribe()
+	unsubscribe() // Safe to call twice
+
+	if _, ok := <-ch; ok {
+		t.Error("channel should be closed after unsubscribe")
+	}
+
+	// Publishing after unsubscribe must not panic
+	bus.Publish(Event{Type: AgentFailed})
+}
+
+func TestPublishNilBus(t *testing.T) {
+	var bus *Bus
+	bus.Publish(Event{Type: AgentSpawned})
+}
@@ -253,11 +253,12 @@ func TestParseGitHubURL_EdgeCases(t *testing.T) {
 		wantRepo  string
 		wantErr   bool
 	}{
-		// The current regex implementation doesn't handle trailing slashes
 		{
-			name:    "URL with trailing slash - current impl returns error",
-			url:     "https://github.com/owner/repo/",
-			wantErr: true,
+			name:      "URL with trailing slash",
+			url:       "https://github.com/owner/repo/",
+			wantOwner: "owner",
+			wantRepo:  "repo",
+			wantErr:   false,
 		},
 		{
 			name:    "empty string",
@@ -282,10 +283,10 @@ func TestParseGitHubURL_EdgeCases(t *testing.T) {
 		},
 		// The current impl captures query params as part of repo name
 		{
-			name:      "URL with query params - captured as part of repo name",
+			name:      "URL with query params - ignored",
 			url:       "https://github.com/owner/repo?tab=readme",
 			wantOwner: "owner",
-			wantRepo:  "repo?tab=readme", // Query params are captured
+			wantRepo:  "repo",
 			wantErr:   false,
 		},
 		{
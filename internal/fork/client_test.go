@@ -0,0 +1,258 @@
+package fork
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dlorenc/multiclaude/internal/command"
+)
+
+func TestClientDetectForkGitHubAPIFailure(t *testing.T) {
+	fake := command.NewFake()
+	fake.Set("git -C /repo remote get-url origin", "https://github.com/me/repo.git\n", nil)
+	fake.Set("git -C /repo remote get-url upstream", "", errors.New("no such remote"))
+	// gh is not registered, so it fails as if not installed
+
+	info, err := NewClient(fake).DetectFork("/repo")
+	if err != nil {
+		t.Fatalf("DetectFork() failed: %v", err)
+	}
+	if info.IsFork {
+		t.Error("DetectFork() should not report a fork when gh fails")
+	}
+	if info.OriginOwner != "me" || info.OriginRepo != "repo" {
+		t.Errorf("origin = %s/%s, want me/repo", info.OriginOwner, info.OriginRepo)
+	}
+}
+
+func TestClientDetectForkSelfHostedUpstream(t *testing.T) {
+	fake := command.NewFake()
+	fake.Set("git -C /repo remote get-url origin", "git@gitlab.company.com:2222/me/tools/repo.git\n", nil)
+	fake.Set("git -C /repo remote get-url upstream", "https://gitlab.company.com/platform/tools/repo.git\n", nil)
+
+	info, err := NewClient(fake).DetectFork("/repo")
+	if err != nil {
+		t.Fatalf("DetectFork() failed: %v", err)
+	}
+	if !info.IsFork || info.UpstreamOwner != "platform/tools" || info.UpstreamRepo != "repo" {
+		t.Errorf("DetectFork() = %+v, want a fork of platform/tools/repo", info)
+	}
+	if info.OriginOwner != "me/tools" || info.OriginRepo != "repo" {
+		t.Errorf("origin = %s/%s, want me/tools/repo", info.OriginOwner, info.OriginRepo)
+	}
+}
+
+func TestClientDetectForkDefaultBranches(t *testing.T) {
+	fake := command.NewFake()
+	fake.Set("git -C /repo remote get-url origin", "https://github.com/me/repo.git\n", nil)
+	fake.Set("git -C /repo remote get-url upstream", "https://github.com/them/repo.git\n", nil)
+	fake.Set("git -C /repo symbolic-ref --short refs/remotes/origin/HEAD", "origin/trunk\n", nil)
+	fake.Set("git -C /repo symbolic-ref --short refs/remotes/upstream/HEAD", "upstream/release/v2\n", nil)
+
+	info, err := NewClient(fake).DetectFork("/repo")
+	if err != nil {
+		t.Fatalf("DetectFork() failed: %v", err)
+	}
+	if info.OriginDefaultBranch != "trunk" {
+		t.Errorf("OriginDefaultBranch = %q, want trunk", info.OriginDefaultBranch)
+	}
+	if info.UpstreamDefaultBranch != "release/v2" {
+		t.Errorf("UpstreamDefaultBranch = %q, want release/v2", info.UpstreamDefaultBranch)
+	}
+}
+
+func TestClientDefaultBranchAsksRemote(t *testing.T) {
+	fake := command.NewFake()
+	fake.Set("git -C /repo symbolic-ref --short refs/remotes/upstream/HEAD", "", errors.New("not a symbolic ref"))
+	fake.Set("git -C /repo ls-remote --symref upstream HEAD", "ref: refs/heads/release/v2\tHEAD\n0123456789abcdef\tHEAD\n", nil)
+
+	if got := NewClient(fake).DefaultBranch("/repo", "upstream"); got != "release/v2" {
+		t.Errorf("DefaultBranch() = %q, want release/v2", got)
+	}
+}
+
+func TestClientDetectForkSkipsGitHubAPIOnOtherHosts(t *testing.T) {
+	fake := command.NewFake()
+	fake.Set("git -C /repo remote get-url origin", "https://bitbucket.org/team/repo.git\n", nil)
+	fake.Set("git -C /repo remote get-url upstream", "", errors.New("no such remote"))
+
+	info, err := NewClient(fake).DetectFork("/repo")
+	if err != nil {
+		t.Fatalf("DetectFork() failed: %v", err)
+	}
+	if info.IsFork {
+		t.Error("DetectFork() reported a fork without an upstream remote")
+	}
+	for _, call := range fake.Calls() {
+		if strings.HasPrefix(call, "gh ") {
+			t.Errorf("DetectFork() called %q for a non-GitHub origin", call)
+		}
+	}
+}
+
+func TestClientDetectForkMissingOrigin(t *testing.T) {
+	fake := command.NewFake()
+	fake.Set("git -C /repo remote get-url origin", "", errors.New("no such remote 'origin'"))
+
+	if _, err := NewClient(fake).DetectFork("/repo"); err == nil || !strings.Contains(err.Error(), "origin") {
+		t.Errorf("DetectFork() error = %v, want an origin error", err)
+	}
+}
+
+func TestClientAddUpstreamRemoteFailure(t *testing.T) {
+	fake := command.NewFake()
+	fake.Set("git -C /repo remote get-url upstream", "https://github.com/old/repo\n", nil)
+	fake.Set("git -C /repo remote set-url upstream https://github.com/new/repo", "", errors.New("config locked"))
+
+	if err := NewClient(fake).AddUpstreamRemote("/repo", "https://github.com/new/repo"); err == nil {
+		t.Error("AddUpstreamRemote() should surface a set-url failure")
+	}
+}
+
+func TestClientFetchUpstreamFailure(t *testing.T) {
+	fake := command.NewFake()
+	fake.Set("git -C /repo fetch upstream", "", errors.New("exit status 128: could not resolve host"))
+
+	err := NewClient(fake).FetchUpstream("/repo", "")
+	if err == nil || !strings.Contains(err.Error(), "could not resolve host") {
+		t.Errorf("FetchUpstream() error = %v, want git's message", err)
+	}
+}
+
+func TestClientFetchUpstreamTimeout(t *testing.T) {
+	fake := command.NewFake()
+	fake.Hang("git -C /repo fetch upstream")
+	client := NewClient(fake)
+	client.SetTimeouts(time.Second, 20*time.Millisecond)
+
+	err := client.FetchUpstream("/repo", "")
+	if !command.IsTimeout(err) {
+		t.Errorf("FetchUpstream() error = %v, want a timeout", err)
+	}
+}
+
+func TestClientGetDivergenceBadOutput(t *testing.T) {
+	fake := command.NewFake()
+	fake.Set("git -C /repo rev-list --left-right --count main...feature", "garbage\n", nil)
+
+	if _, err := NewClient(fake).GetDivergence("/repo", "main", "feature"); err == nil {
+		t.Error("GetDivergence() should fail on unparseable rev-list output")
+	}
+}
+
+func TestClientMergeUpstreamFailures(t *testing.T) {
+	setup := func() *command.Fake {
+		fake := command.NewFake()
+		fake.Set("git -C /repo rev-parse --abbrev-ref HEAD", "main\n", nil)
+		fake.Set("git -C /repo rev-list --left-right --count upstream/main...main", "2\t1\n", nil)
+		fake.Set("git -C /repo diff --name-only upstream/main...main", "a.go\n", nil)
+		return fake
+	}
+
+	t.Run("abort fails after conflict", func(t *testing.T) {
+		fake := setup()
+		fake.Set("git -C /repo merge --no-edit upstream/main", "CONFLICT (content): a.go\n", errors.New("exit status 1"))
+		fake.Set("git -C /repo diff --name-only --diff-filter=U", "a.go\n", nil)
+		fake.Set("git -C /repo merge --abort", "", errors.New("exit status 128: no merge in progress"))
+
+		_, err := NewClient(fake).MergeUpstream("/repo", "")
+		if err == nil || !strings.Contains(err.Error(), "abort") {
+			t.Errorf("MergeUpstream() error = %v, want an abort failure", err)
+		}
+	})
+
+	t.Run("merge fails without conflicts", func(t *testing.T) {
+		fake := setup()
+		fake.Set("git -C /repo merge --no-edit upstream/main", "untracked files would be overwritten\n", errors.New("exit status 1"))
+		fake.Set("git -C /repo diff --name-only --diff-filter=U", "", nil)
+
+		_, err := NewClient(fake).MergeUpstream("/repo", "")
+		if err == nil || !strings.Contains(err.Error(), "untracked files") {
+			t.Errorf("MergeUpstream() error = %v, want the merge output", err)
+		}
+		for _, call := range fake.Calls() {
+			if call == "git -C /repo merge --abort" {
+				t.Error("MergeUpstream() should not abort when nothing conflicted")
+			}
+		}
+	})
+
+	t.Run("conflict is aborted", func(t *testing.T) {
+		fake := setup()
+		fake.Set("git -C /repo merge --no-edit upstream/main", "CONFLICT (content): a.go\n", errors.New("exit status 1"))
+		fake.Set("git -C /repo diff --name-only --diff-filter=U", "a.go\n", nil)
+		fake.Set("git -C /repo merge --abort", "", nil)
+
+		result, err := NewClient(fake).MergeUpstream("/repo", "")
+		if err != nil {
+			t.Fatalf("MergeUpstream() failed: %v", err)
+		}
+		want := &SyncResult{Branch: "main", Status: SyncDiverged, Ahead: 1, Behind: 2, Conflicts: true}
+		if !reflect.DeepEqual(result, want) {
+			t.Errorf("result = %+v, want %+v", result, want)
+		}
+	})
+}
+
+func TestClientSyncWithUpstreamDirtyWorktree(t *testing.T) {
+	fake := command.NewFake()
+	fake.Set("git -C /repo status --porcelain --untracked-files=no", " M README.md\n", nil)
+
+	_, err := NewClient(fake).SyncWithUpstream("/repo", "", "main")
+	if !errors.Is(err, ErrDirtyWorktree) {
+		t.Fatalf("SyncWithUpstream() error = %v, want ErrDirtyWorktree", err)
+	}
+	for _, call := range fake.Calls() {
+		if strings.Contains(call, "fetch") || strings.Contains(call, "merge") {
+			t.Errorf("SyncWithUpstream() ran %q on a dirty working tree", call)
+		}
+	}
+}
+
+func TestClientDetectForkMultipleRemotes(t *testing.T) {
+	fake := command.NewFake()
+	fake.Set("git -C /repo remote", "origin\nstaging\nupstream\n", nil)
+	fake.Set("git -C /repo remote get-url origin", "https://github.com/me/repo.git\n", nil)
+	fake.Set("git -C /repo remote get-url staging", "git@github.com:staging-org/repo.git\n", nil)
+	fake.Set("git -C /repo remote get-url upstream", "https://github.com/them/repo.git\n", nil)
+
+	info, err := NewClient(fake).DetectFork("/repo")
+	if err != nil {
+		t.Fatalf("DetectFork() failed: %v", err)
+	}
+	want := []Remote{
+		{Name: "staging", URL: "git@github.com:staging-org/repo.git", Owner: "staging-org", Repo: "repo"},
+		{Name: "upstream", URL: "https://github.com/them/repo.git", Owner: "them", Repo: "repo"},
+	}
+	if !reflect.DeepEqual(info.Remotes, want) {
+		t.Errorf("Remotes = %+v, want %+v", info.Remotes, want)
+	}
+	if !info.IsFork || info.UpstreamOwner != "them" {
+		t.Errorf("DetectFork() = %+v, want a fork of them/repo", info)
+	}
+
+	tests := []struct {
+		name      string
+		wantOwner string
+		wantErr   bool
+	}{
+		{name: "", wantOwner: "them"},
+		{name: "upstream", wantOwner: "them"},
+		{name: "staging", wantOwner: "staging-org"},
+		{name: "origin", wantErr: true},
+		{name: "missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		target, err := info.SyncTarget(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SyncTarget(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if target.Owner != tt.wantOwner {
+			t.Errorf("SyncTarget(%q) owner = %q, want %q", tt.name, target.Owner, tt.wantOwner)
+		}
+	}
+}
@@ -3,12 +3,47 @@ package fork
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os/exec"
+	"net/url"
 	"regexp"
 	"strings"
+	"time"
+
+	"github.com/dlorenc/multiclaude/internal/command"
 )
 
+// Client runs fork operations through a command.Runner. The package-level
+// functions use a Client backed by the real git and gh binaries.
+type Client struct {
+	runner         command.Runner
+	timeout        time.Duration // bounds local git commands
+	networkTimeout time.Duration // bounds fetches and GitHub API calls
+}
+
+// NewClient creates a Client that runs git and gh through runner
+func NewClient(runner command.Runner) *Client {
+	return &Client{
+		runner:         runner,
+		timeout:        command.DefaultTimeout,
+		networkTimeout: command.NetworkTimeout,
+	}
+}
+
+// SetTimeouts changes how long local git commands and network operations
+// may run before they are killed and reported as timed out.
+func (c *Client) SetTimeouts(local, network time.Duration) {
+	c.timeout = local
+	c.networkTimeout = network
+}
+
+var defaultClient = NewClient(command.ExecRunner{})
+
+// git runs a local git command in the repository at repoPath
+func (c *Client) git(repoPath string, args ...string) ([]byte, error) {
+	return command.RunWithTimeout(c.runner, c.timeout, "git", append([]string{"-C", repoPath}, args...)...)
+}
+
 // ForkInfo contains information about whether a repository is a fork
 // and its relationship to upstream.
 type ForkInfo struct {
@@ -32,100 +67,267 @@ type ForkInfo struct {
 
 	// UpstreamRepo is the name of the upstream repository (if fork)
 	UpstreamRepo string `json:"upstream_repo,omitempty"`
+
+	// OriginDefaultBranch is the origin repository's default branch, or
+	// empty if it isn't known locally
+	OriginDefaultBranch string `json:"origin_default_branch,omitempty"`
+
+	// UpstreamDefaultBranch is the upstream repository's default branch (if
+	// fork), or empty if it isn't known
+	UpstreamDefaultBranch string `json:"upstream_default_branch,omitempty"`
+
+	// Remotes lists every configured remote other than origin, such as an
+	// "upstream" and a "staging" fork, in the order git reports them
+	Remotes []Remote `json:"remotes,omitempty"`
+}
+
+// UpstreamRemoteName is the remote that syncs with by default
+const UpstreamRemoteName = "upstream"
+
+// Remote is a git remote other than origin.
+type Remote struct {
+	// Name is the remote's name, e.g. "upstream"
+	Name string `json:"name"`
+
+	// URL is the remote's fetch URL
+	URL string `json:"url"`
+
+	// Owner is the owner of the remote repository, or empty if URL could
+	// not be parsed
+	Owner string `json:"owner,omitempty"`
+
+	// Repo is the name of the remote repository, or empty if URL could not
+	// be parsed
+	Repo string `json:"repo,omitempty"`
+}
+
+// Remote returns the remote called name, if it is configured
+func (f *ForkInfo) Remote(name string) (Remote, bool) {
+	for _, r := range f.Remotes {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Remote{}, false
+}
+
+// SyncTarget returns the remote to sync with. An empty name means
+// UpstreamRemoteName. It fails if the remote isn't configured.
+func (f *ForkInfo) SyncTarget(name string) (Remote, error) {
+	if name == "" {
+		name = UpstreamRemoteName
+	}
+	r, ok := f.Remote(name)
+	if !ok {
+		return Remote{}, fmt.Errorf("remote %q is not configured", name)
+	}
+	return r, nil
 }
 
 // DetectFork analyzes a git repository to determine if it's a fork.
 // It uses multiple detection strategies:
-// 1. Check for "upstream" git remote (common convention)
-// 2. Query GitHub API for fork status (most reliable)
+// 1. Check for "upstream" git remote (common convention, any host)
+// 2. Query GitHub API for fork status (most reliable, github.com only)
 //
 // The repoPath should be the path to the git repository root.
 func DetectFork(repoPath string) (*ForkInfo, error) {
+	return defaultClient.DetectFork(repoPath)
+}
+
+// DetectFork is like the package-level DetectFork but uses c's runner.
+func (c *Client) DetectFork(repoPath string) (*ForkInfo, error) {
 	// Get origin remote URL
-	originURL, err := getRemoteURL(repoPath, "origin")
+	originURL, err := c.getRemoteURL(repoPath, "origin")
 	if err != nil {
 		return nil, fmt.Errorf("failed to get origin remote: %w", err)
 	}
 
 	// Parse origin URL
-	originOwner, originRepo, err := ParseGitHubURL(originURL)
+	originHost, originOwner, originRepo, err := ParseRepoURL(originURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse origin URL: %w", err)
 	}
 
 	info := &ForkInfo{
-		IsFork:      false,
-		OriginURL:   originURL,
-		OriginOwner: originOwner,
-		OriginRepo:  originRepo,
+		IsFork:              false,
+		OriginURL:           originURL,
+		OriginOwner:         originOwner,
+		OriginRepo:          originRepo,
+		OriginDefaultBranch: c.DefaultBranch(repoPath, "origin"),
+		Remotes:             c.listRemotes(repoPath),
 	}
 
 	// Check for upstream remote (common fork convention)
-	upstreamURL, err := getRemoteURL(repoPath, "upstream")
-	if err == nil && upstreamURL != "" {
+	if upstream, ok := info.Remote(UpstreamRemoteName); ok && upstream.Owner != "" {
 		// Upstream remote exists - this is a fork
-		upstreamOwner, upstreamRepo, err := ParseGitHubURL(upstreamURL)
-		if err == nil {
-			info.IsFork = true
-			info.UpstreamURL = upstreamURL
-			info.UpstreamOwner = upstreamOwner
-			info.UpstreamRepo = upstreamRepo
-			return info, nil
-		}
+		info.IsFork = true
+		info.UpstreamURL = upstream.URL
+		info.UpstreamOwner = upstream.Owner
+		info.UpstreamRepo = upstream.Repo
+		info.UpstreamDefaultBranch = c.DefaultBranch(repoPath, UpstreamRemoteName)
+		return info, nil
+	}
+
+	// Only GitHub repositories can be asked about their parent
+	if originHost != githubHost {
+		return info, nil
 	}
 
 	// Try to detect via GitHub API using gh CLI
-	forkInfo, err := detectForkViaGitHubAPI(originOwner, originRepo)
+	forkInfo, err := c.detectForkViaGitHubAPI(originOwner, originRepo)
 	if err == nil && forkInfo.IsFork {
 		info.IsFork = true
 		info.UpstreamURL = forkInfo.UpstreamURL
 		info.UpstreamOwner = forkInfo.UpstreamOwner
 		info.UpstreamRepo = forkInfo.UpstreamRepo
+		info.UpstreamDefaultBranch = forkInfo.UpstreamDefaultBranch
 	}
 
 	return info, nil
 }
 
+// DefaultBranch returns the default branch of remote as recorded by the
+// refs/remotes/<remote>/HEAD symbolic ref, which clone and
+// `git remote set-head` create. A remote that was added without that ref,
+// such as one never fetched, is asked with `git ls-remote --symref`. It
+// returns "" if neither says.
+func DefaultBranch(repoPath, remote string) string {
+	return defaultClient.DefaultBranch(repoPath, remote)
+}
+
+// DefaultBranch is like the package-level DefaultBranch but uses c's runner.
+func (c *Client) DefaultBranch(repoPath, remote string) string {
+	if output, err := c.git(repoPath, "symbolic-ref", "--short", "refs/remotes/"+remote+"/HEAD"); err == nil {
+		// Output is like "origin/main"
+		return strings.TrimPrefix(strings.TrimSpace(string(output)), remote+"/")
+	}
+
+	output, err := command.RunWithTimeout(c.runner, c.networkTimeout, "git", "-C", repoPath, "ls-remote", "--symref", remote, "HEAD")
+	if err != nil {
+		return ""
+	}
+	// The symref line is like "ref: refs/heads/main\tHEAD"
+	for _, line := range strings.Split(string(output), "\n") {
+		if ref, ok := strings.CutPrefix(line, "ref: refs/heads/"); ok {
+			branch, _, _ := strings.Cut(ref, "\t")
+			return branch
+		}
+	}
+	return ""
+}
+
 // getRemoteURL returns the URL of a git remote.
 func getRemoteURL(repoPath, remoteName string) (string, error) {
-	cmd := exec.Command("git", "-C", repoPath, "remote", "get-url", remoteName)
-	output, err := cmd.Output()
+	return defaultClient.getRemoteURL(repoPath, remoteName)
+}
+
+func (c *Client) getRemoteURL(repoPath, remoteName string) (string, error) {
+	output, err := c.git(repoPath, "remote", "get-url", remoteName)
 	if err != nil {
 		return "", err
 	}
 	return strings.TrimSpace(string(output)), nil
 }
 
+// ListRemotes returns every remote of the repository except origin, as
+// DetectFork records them in ForkInfo.Remotes. Unlike DetectFork it never
+// asks GitHub and works whatever origin's URL is.
+func ListRemotes(repoPath string) []Remote {
+	return defaultClient.listRemotes(repoPath)
+}
+
+// listRemotes returns every remote except origin. If the remotes can't be
+// listed it still looks for an upstream remote, so fork detection works
+// with the common single-upstream setup.
+func (c *Client) listRemotes(repoPath string) []Remote {
+	names := []string{UpstreamRemoteName}
+	if output, err := c.git(repoPath, "remote"); err == nil {
+		names = strings.Fields(string(output))
+	}
+
+	var remotes []Remote
+	for _, name := range names {
+		if name == "origin" {
+			continue
+		}
+		remoteURL, err := c.getRemoteURL(repoPath, name)
+		if err != nil || remoteURL == "" {
+			continue
+		}
+		r := Remote{Name: name, URL: remoteURL}
+		if _, owner, repo, err := ParseRepoURL(remoteURL); err == nil {
+			r.Owner, r.Repo = owner, repo
+		}
+		remotes = append(remotes, r)
+	}
+	return remotes
+}
+
+// githubHost is the host whose repositories can be queried with gh
+const githubHost = "github.com"
+
+// scpRegex matches scp-style SSH remotes: [user@]host:[port/]path. A port is
+// not part of git's scp syntax, but self-hosted servers commonly advertise
+// remotes that way.
+var scpRegex = regexp.MustCompile(`^(?:[^@/:]+@)?([^@/:]+):(?:(\d+)/)?([^/].*)$`)
+
+// ParseRepoURL extracts the host, owner and repo from a git remote URL on any
+// host, such as github.com, gitlab.com, bitbucket.org or a self-hosted
+// server. Supported formats:
+// - https://host[:port]/owner/repo(.git)
+// - ssh://[user@]host[:port]/owner/repo(.git)
+// - [user@]host:[port/]owner/repo(.git)
+//
+// The host is returned without any port. On hosts with nested groups, such
+// as GitLab, owner is the full group path (e.g. "group/subgroup").
+func ParseRepoURL(rawURL string) (host, owner, repo string, err error) {
+	var path string
+	if u, perr := url.Parse(rawURL); perr == nil && u.Host != "" {
+		switch u.Scheme {
+		case "https", "http", "ssh", "git":
+			host, path = u.Hostname(), u.Path
+		}
+	} else if m := scpRegex.FindStringSubmatch(rawURL); m != nil {
+		host, path = m[1], m[3]
+	}
+	if host == "" {
+		return "", "", "", fmt.Errorf("unable to parse repository URL: %s", rawURL)
+	}
+
+	// Note: repo name can contain dots (e.g., demos.expanso.io)
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	i := strings.LastIndex(path, "/")
+	if i <= 0 || i == len(path)-1 {
+		return "", "", "", fmt.Errorf("unable to parse repository URL: %s", rawURL)
+	}
+	return host, path[:i], path[i+1:], nil
+}
+
 // ParseGitHubURL extracts owner and repo from a GitHub URL.
 // Supports both HTTPS and SSH formats:
 // - https://github.com/owner/repo.git
 // - https://github.com/owner/repo
 // - git@github.com:owner/repo.git
 // - git@github.com:owner/repo
+//
+// URLs on other hosts are rejected; use ParseRepoURL for those.
 func ParseGitHubURL(url string) (owner, repo string, err error) {
-	// HTTPS format: https://github.com/owner/repo(.git)?
-	// Note: repo name can contain dots (e.g., demos.expanso.io)
-	httpsRegex := regexp.MustCompile(`^https://github\.com/([^/]+)/([^/]+?)(?:\.git)?$`)
-	if matches := httpsRegex.FindStringSubmatch(url); matches != nil {
-		return matches[1], matches[2], nil
-	}
-
-	// SSH format: git@github.com:owner/repo(.git)?
-	// Note: repo name can contain dots (e.g., demos.expanso.io)
-	sshRegex := regexp.MustCompile(`^git@github\.com:([^/]+)/([^/]+?)(?:\.git)?$`)
-	if matches := sshRegex.FindStringSubmatch(url); matches != nil {
-		return matches[1], matches[2], nil
+	host, owner, repo, err := ParseRepoURL(url)
+	if err != nil || host != githubHost || strings.Contains(owner, "/") {
+		return "", "", fmt.Errorf("unable to parse GitHub URL: %s", url)
 	}
-
-	return "", "", fmt.Errorf("unable to parse GitHub URL: %s", url)
+	return owner, repo, nil
 }
 
 // detectForkViaGitHubAPI uses the gh CLI to check if a repo is a fork.
 func detectForkViaGitHubAPI(owner, repo string) (*ForkInfo, error) {
+	return defaultClient.detectForkViaGitHubAPI(owner, repo)
+}
+
+func (c *Client) detectForkViaGitHubAPI(owner, repo string) (*ForkInfo, error) {
 	// Use gh api to get repo info
-	cmd := exec.Command("gh", "api", fmt.Sprintf("repos/%s/%s", owner, repo),
-		"--jq", "{fork: .fork, parent_owner: .parent.owner.login, parent_repo: .parent.name, parent_url: .parent.clone_url}")
-	output, err := cmd.Output()
+	output, err := command.RunWithTimeout(c.runner, c.networkTimeout, "gh", "api", fmt.Sprintf("repos/%s/%s", owner, repo),
+		"--jq", "{fork: .fork, parent_owner: .parent.owner.login, parent_repo: .parent.name, parent_url: .parent.clone_url, parent_default_branch: .parent.default_branch}")
 	if err != nil {
 		return nil, fmt.Errorf("gh api failed: %w", err)
 	}
@@ -135,6 +337,7 @@ func detectForkViaGitHubAPI(owner, repo string) (*ForkInfo, error) {
 		ParentOwner string `json:"parent_owner"`
 		ParentRepo  string `json:"parent_repo"`
 		ParentURL   string `json:"parent_url"`
+		ParentHead  string `json:"parent_default_branch"`
 	}
 
 	if err := json.Unmarshal(output, &result); err != nil {
@@ -149,6 +352,7 @@ func detectForkViaGitHubAPI(owner, repo string) (*ForkInfo, error) {
 		info.UpstreamOwner = result.ParentOwner
 		info.UpstreamRepo = result.ParentRepo
 		info.UpstreamURL = result.ParentURL
+		info.UpstreamDefaultBranch = result.ParentHead
 	}
 
 	return info, nil
@@ -156,21 +360,328 @@ func detectForkViaGitHubAPI(owner, repo string) (*ForkInfo, error) {
 
 // AddUpstreamRemote adds an upstream remote to a git repository.
 func AddUpstreamRemote(repoPath, upstreamURL string) error {
-	// Check if upstream already exists
-	_, err := getRemoteURL(repoPath, "upstream")
-	if err == nil {
-		// Upstream already exists - update it
-		cmd := exec.Command("git", "-C", repoPath, "remote", "set-url", "upstream", upstreamURL)
-		return cmd.Run()
+	return defaultClient.AddUpstreamRemote(repoPath, upstreamURL)
+}
+
+// AddUpstreamRemote is like the package-level AddUpstreamRemote but uses
+// c's runner.
+func (c *Client) AddUpstreamRemote(repoPath, upstreamURL string) error {
+	plan := c.AddUpstreamRemoteDryRun(repoPath, upstreamURL)
+	for _, argv := range plan.Commands {
+		if _, err := command.RunWithTimeout(c.runner, c.timeout, argv[0], argv[1:]...); err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+// RemotePlan describes what AddUpstreamRemote would change
+type RemotePlan struct {
+	// Update is true if the upstream remote exists and would be repointed,
+	// false if it would be added
+	Update bool `json:"update"`
+
+	// CurrentURL is the upstream remote's URL before the change, if it
+	// exists
+	CurrentURL string `json:"current_url,omitempty"`
 
-	// Add new upstream remote
-	cmd := exec.Command("git", "-C", repoPath, "remote", "add", "upstream", upstreamURL)
-	return cmd.Run()
+	// Commands are the git commands that would run, as argument lists
+	Commands [][]string `json:"commands"`
+}
+
+// AddUpstreamRemoteDryRun returns what AddUpstreamRemote would do, without
+// changing the repository.
+func AddUpstreamRemoteDryRun(repoPath, upstreamURL string) *RemotePlan {
+	return defaultClient.AddUpstreamRemoteDryRun(repoPath, upstreamURL)
+}
+
+// AddUpstreamRemoteDryRun is like the package-level AddUpstreamRemoteDryRun
+// but uses c's runner.
+func (c *Client) AddUpstreamRemoteDryRun(repoPath, upstreamURL string) *RemotePlan {
+	plan := &RemotePlan{}
+	verb := "add"
+	if currentURL, err := c.getRemoteURL(repoPath, UpstreamRemoteName); err == nil {
+		plan.Update = true
+		plan.CurrentURL = currentURL
+		verb = "set-url"
+	}
+	plan.Commands = [][]string{{"git", "-C", repoPath, "remote", verb, UpstreamRemoteName, upstreamURL}}
+	return plan
 }
 
 // HasUpstreamRemote checks if the upstream remote is configured.
 func HasUpstreamRemote(repoPath string) bool {
-	_, err := getRemoteURL(repoPath, "upstream")
+	return defaultClient.HasUpstreamRemote(repoPath)
+}
+
+// HasUpstreamRemote is like the package-level HasUpstreamRemote but uses
+// c's runner.
+func (c *Client) HasUpstreamRemote(repoPath string) bool {
+	_, err := c.getRemoteURL(repoPath, UpstreamRemoteName)
 	return err == nil
 }
+
+// Divergence describes how a head ref differs from a base ref.
+type Divergence struct {
+	// Ahead is the number of commits on head that are not on base
+	Ahead int `json:"ahead"`
+
+	// Behind is the number of commits on base that are not on head
+	Behind int `json:"behind"`
+
+	// FilesChanged lists the files changed on head since it diverged from base
+	FilesChanged []string `json:"files_changed"`
+}
+
+// Summary returns a one-line human-readable description of the divergence.
+func (d *Divergence) Summary() string {
+	return fmt.Sprintf("%d commit(s) ahead, %d behind, %d file(s) changed", d.Ahead, d.Behind, len(d.FilesChanged))
+}
+
+// GetDivergence compares head against base in the repository at repoPath.
+// Both refs must already exist locally (fetch remote refs first).
+func GetDivergence(repoPath, base, head string) (*Divergence, error) {
+	return defaultClient.GetDivergence(repoPath, base, head)
+}
+
+// GetDivergence is like the package-level GetDivergence but uses c's runner.
+func (c *Client) GetDivergence(repoPath, base, head string) (*Divergence, error) {
+	output, err := c.git(repoPath, "rev-list", "--left-right", "--count", fmt.Sprintf("%s...%s", base, head))
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare %s with %s: %w", head, base, err)
+	}
+
+	div := &Divergence{}
+	// Output is "behind\tahead"
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(output)), "%d %d", &div.Behind, &div.Ahead); err != nil {
+		return nil, fmt.Errorf("failed to parse rev-list output %q: %w", strings.TrimSpace(string(output)), err)
+	}
+
+	output, err = c.git(repoPath, "diff", "--name-only", fmt.Sprintf("%s...%s", base, head))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changed files: %w", err)
+	}
+
+	div.FilesChanged = []string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if line != "" {
+			div.FilesChanged = append(div.FilesChanged, line)
+		}
+	}
+
+	return div, nil
+}
+
+// ErrNotFetched is returned when an upstream ref doesn't exist locally,
+// usually because the remote hasn't been fetched yet
+var ErrNotFetched = errors.New("upstream ref not fetched")
+
+// BranchDivergence counts the commits localRef has that upstreamRef
+// doesn't (ahead), and the reverse (behind). It fails with ErrNotFetched if
+// upstreamRef doesn't exist locally, so callers can fetch and retry.
+func BranchDivergence(repoPath, localRef, upstreamRef string) (ahead, behind int, err error) {
+	return defaultClient.BranchDivergence(repoPath, localRef, upstreamRef)
+}
+
+// BranchDivergence is like the package-level BranchDivergence but uses c's
+// runner.
+func (c *Client) BranchDivergence(repoPath, localRef, upstreamRef string) (ahead, behind int, err error) {
+	if _, err := c.git(repoPath, "rev-parse", "--verify", "--quiet", upstreamRef+"^{commit}"); err != nil {
+		return 0, 0, fmt.Errorf("%w: %s", ErrNotFetched, upstreamRef)
+	}
+
+	// The local branch is the head being compared against upstream
+	div, err := c.GetDivergence(repoPath, upstreamRef, localRef)
+	if err != nil {
+		return 0, 0, err
+	}
+	return div.Ahead, div.Behind, nil
+}
+
+// FetchUpstream fetches remote so its branches can be compared and merged.
+// An empty remote means UpstreamRemoteName.
+func FetchUpstream(repoPath, remote string) error {
+	return defaultClient.FetchUpstream(repoPath, remote)
+}
+
+// FetchUpstream is like the package-level FetchUpstream but uses c's runner.
+func (c *Client) FetchUpstream(repoPath, remote string) error {
+	remote = syncRemote(remote)
+	if _, err := command.RunWithTimeout(c.runner, c.networkTimeout, "git", "-C", repoPath, "fetch", remote); err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", remote, err)
+	}
+	return nil
+}
+
+// syncRemote returns remote, or UpstreamRemoteName if it is empty
+func syncRemote(remote string) string {
+	if remote == "" {
+		return UpstreamRemoteName
+	}
+	return remote
+}
+
+// ErrDirtyWorktree is returned when a sync would touch a working tree with
+// uncommitted changes
+var ErrDirtyWorktree = errors.New("working tree has uncommitted changes")
+
+// SyncStatus says what syncing a branch with upstream did
+type SyncStatus string
+
+const (
+	// SyncUpToDate means the branch already had every upstream commit
+	SyncUpToDate SyncStatus = "up_to_date"
+	// SyncFastForwarded means the branch had no local commits and was moved
+	// to upstream's
+	SyncFastForwarded SyncStatus = "fast_forwarded"
+	// SyncDiverged means both sides have commits, so the branch was left
+	// unchanged and needs a merge
+	SyncDiverged SyncStatus = "diverged"
+	// SyncMerged means upstream was merged into the branch by MergeUpstream
+	SyncMerged SyncStatus = "merged"
+)
+
+// SyncResult describes the outcome of syncing a branch with upstream.
+type SyncResult struct {
+	// Branch is the local branch that was synced
+	Branch string `json:"branch"`
+
+	// Status is what the sync did to the branch
+	Status SyncStatus `json:"status"`
+
+	// Ahead is the number of local commits not on upstream
+	Ahead int `json:"ahead"`
+
+	// Behind is the number of upstream commits the branch was missing
+	Behind int `json:"behind"`
+
+	// Conflicts is true if merging upstream conflicted. The merge is aborted
+	// and the branch is left unchanged.
+	Conflicts bool `json:"conflicts"`
+}
+
+// SyncWithUpstream fetches remote and fast-forwards branch to remote's copy
+// of it when the branch has no local commits. An empty remote means
+// UpstreamRemoteName, such as a ForkInfo.SyncTarget; an empty branch means
+// the checked-out one. A diverged branch is left unchanged and reported with
+// SyncDiverged. It fails with ErrDirtyWorktree if the working tree has
+// uncommitted changes.
+func SyncWithUpstream(repoPath, remote, branch string) (*SyncResult, error) {
+	return defaultClient.SyncWithUpstream(repoPath, remote, branch)
+}
+
+// SyncWithUpstream is like the package-level SyncWithUpstream but uses c's
+// runner.
+func (c *Client) SyncWithUpstream(repoPath, remote, branch string) (*SyncResult, error) {
+	if err := c.checkClean(repoPath); err != nil {
+		return nil, err
+	}
+	current, err := c.currentBranch(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	if branch == "" {
+		branch = current
+	}
+	if err := c.FetchUpstream(repoPath, remote); err != nil {
+		return nil, err
+	}
+
+	upstreamRef := syncRemote(remote) + "/" + branch
+	div, err := c.GetDivergence(repoPath, upstreamRef, branch)
+	if err != nil {
+		return nil, err
+	}
+	result := &SyncResult{Branch: branch, Ahead: div.Ahead, Behind: div.Behind}
+	switch {
+	case div.Behind == 0:
+		result.Status = SyncUpToDate
+		return result, nil
+	case div.Ahead > 0:
+		result.Status = SyncDiverged
+		return result, nil
+	}
+
+	// A branch that isn't checked out can simply be moved; the checked-out
+	// one has to be merged so the working tree follows
+	if branch == current {
+		output, err := c.git(repoPath, "merge", "--ff-only", upstreamRef)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fast-forward %s: %w: %s", branch, err, strings.TrimSpace(string(output)))
+		}
+	} else if _, err := c.git(repoPath, "branch", "--force", branch, upstreamRef); err != nil {
+		return nil, fmt.Errorf("failed to fast-forward %s: %w", branch, err)
+	}
+	result.Status = SyncFastForwarded
+	return result, nil
+}
+
+// MergeUpstream merges remote's copy of the checked-out branch into it,
+// fast-forwarding when there are no local commits. An empty remote means
+// UpstreamRemoteName. Call FetchUpstream first. A conflicting merge is
+// aborted and reported in the result rather than as an error.
+func MergeUpstream(repoPath, remote string) (*SyncResult, error) {
+	return defaultClient.MergeUpstream(repoPath, remote)
+}
+
+// MergeUpstream is like the package-level MergeUpstream but uses c's
+// runner.
+func (c *Client) MergeUpstream(repoPath, remote string) (*SyncResult, error) {
+	branch, err := c.currentBranch(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	upstreamRef := syncRemote(remote) + "/" + branch
+
+	div, err := c.GetDivergence(repoPath, upstreamRef, branch)
+	if err != nil {
+		return nil, err
+	}
+	result := &SyncResult{Branch: branch, Status: SyncUpToDate, Ahead: div.Ahead, Behind: div.Behind}
+	if div.Behind == 0 {
+		return result, nil
+	}
+
+	mode, status := "--no-edit", SyncMerged
+	if div.Ahead == 0 {
+		mode, status = "--ff-only", SyncFastForwarded
+	}
+	if output, err := c.git(repoPath, "merge", mode, upstreamRef); err != nil {
+		conflicted, _ := c.git(repoPath, "diff", "--name-only", "--diff-filter=U")
+		if strings.TrimSpace(string(conflicted)) == "" {
+			// git reports why the merge stopped on stdout
+			return nil, fmt.Errorf("failed to merge %s: %w: %s", upstreamRef, err, strings.TrimSpace(string(output)))
+		}
+		if _, err := c.git(repoPath, "merge", "--abort"); err != nil {
+			return nil, fmt.Errorf("failed to abort conflicting merge: %w", err)
+		}
+		result.Status = SyncDiverged
+		result.Conflicts = true
+		return result, nil
+	}
+
+	result.Status = status
+	return result, nil
+}
+
+// currentBranch returns the branch checked out in the repository
+func (c *Client) currentBranch(repoPath string) (string, error) {
+	output, err := c.git(repoPath, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to get current branch: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// checkClean fails with ErrDirtyWorktree if tracked files in the repository
+// have uncommitted changes
+func (c *Client) checkClean(repoPath string) error {
+	output, err := c.git(repoPath, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return fmt.Errorf("failed to check working tree: %w", err)
+	}
+	if changes := strings.TrimSpace(string(output)); changes != "" {
+		return fmt.Errorf("%w: %s", ErrDirtyWorktree, repoPath)
+	}
+	return nil
+}
@@ -1,9 +1,11 @@
 package fork
 
 import (
+	"errors"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -73,6 +75,11 @@ func TestParseGitHubURL(t *testing.T) {
 			url:     "https://github.com/owner",
 			wantErr: true,
 		},
+		{
+			name:    "Nested path",
+			url:     "https://github.com/group/sub/repo",
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -94,6 +101,49 @@ func TestParseGitHubURL(t *testing.T) {
 	}
 }
 
+func TestParseRepoURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		url       string
+		wantHost  string
+		wantOwner string
+		wantRepo  string
+		wantErr   bool
+	}{
+		{"GitHub HTTPS", "https://github.com/owner/repo.git", "github.com", "owner", "repo", false},
+		{"GitHub SSH", "git@github.com:owner/repo", "github.com", "owner", "repo", false},
+		{"GitLab HTTPS", "https://gitlab.com/owner/repo", "gitlab.com", "owner", "repo", false},
+		{"GitLab SSH", "git@gitlab.com:owner/repo.git", "gitlab.com", "owner", "repo", false},
+		{"GitLab nested groups HTTPS", "https://gitlab.com/group/sub/repo.git", "gitlab.com", "group/sub", "repo", false},
+		{"GitLab nested groups SSH", "git@gitlab.com:group/sub/deeper/repo.git", "gitlab.com", "group/sub/deeper", "repo", false},
+		{"Bitbucket HTTPS with user", "https://someone@bitbucket.org/team/repo.git", "bitbucket.org", "team", "repo", false},
+		{"Bitbucket SSH", "git@bitbucket.org:team/repo.git", "bitbucket.org", "team", "repo", false},
+		{"self-hosted HTTPS with port", "https://git.company.com:8443/team/repo.git", "git.company.com", "team", "repo", false},
+		{"self-hosted SSH with port", "git@gitlab.company.com:2222/group/sub/repo.git", "gitlab.company.com", "group/sub", "repo", false},
+		{"ssh scheme with port", "ssh://git@gitlab.company.com:2222/group/repo.git", "gitlab.company.com", "group", "repo", false},
+		{"repo name with dots", "git@gitlab.company.com:docs/demos.expanso.io.git", "gitlab.company.com", "docs", "demos.expanso.io", false},
+		{"trailing slash", "https://gitlab.com/owner/repo/", "gitlab.com", "owner", "repo", false},
+		{"missing repo", "https://gitlab.com/owner", "", "", "", true},
+		{"missing repo SSH", "git@gitlab.company.com:2222/repo.git", "", "", "", true},
+		{"local path", "/srv/git/repo.git", "", "", "", true},
+		{"file URL", "file:///srv/git/repo.git", "", "", "", true},
+		{"not a URL", "not-a-url", "", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, owner, repo, err := ParseRepoURL(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRepoURL(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+			}
+			if host != tt.wantHost || owner != tt.wantOwner || repo != tt.wantRepo {
+				t.Errorf("ParseRepoURL(%q) = %q, %q, %q, want %q, %q, %q",
+					tt.url, host, owner, repo, tt.wantHost, tt.wantOwner, tt.wantRepo)
+			}
+		})
+	}
+}
+
 func TestForkInfo(t *testing.T) {
 	// Test ForkInfo struct defaults
 	info := &ForkInfo{
@@ -342,3 +392,387 @@ func TestDetectFork_InvalidPath(t *testing.T) {
 		t.Error("expected error for non-existent path")
 	}
 }
+
+func TestGetDivergence(t *testing.T) {
+	tmpDir := setupTestRepo(t)
+	defer os.RemoveAll(tmpDir)
+
+	commit := func(file, msg string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(tmpDir, file), []byte(msg), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := gitCmdIsolated(tmpDir, "add", file).Run(); err != nil {
+			t.Fatalf("git add failed: %v", err)
+		}
+		if out, err := gitCmdIsolated(tmpDir, "commit", "-m", msg).CombinedOutput(); err != nil {
+			t.Fatalf("git commit failed: %v: %s", err, out)
+		}
+	}
+
+	commit("README.md", "initial")
+	gitCmdIsolated(tmpDir, "branch", "-M", "main").Run()
+	gitCmdIsolated(tmpDir, "checkout", "-b", "feature").Run()
+	commit("a.go", "add a")
+	commit("b.go", "add b")
+	gitCmdIsolated(tmpDir, "checkout", "main").Run()
+	commit("c.go", "add c")
+
+	div, err := GetDivergence(tmpDir, "main", "feature")
+	if err != nil {
+		t.Fatalf("GetDivergence() failed: %v", err)
+	}
+
+	if div.Ahead != 2 || div.Behind != 1 {
+		t.Errorf("ahead/behind = %d/%d, want 2/1", div.Ahead, div.Behind)
+	}
+	if len(div.FilesChanged) != 2 || div.FilesChanged[0] != "a.go" || div.FilesChanged[1] != "b.go" {
+		t.Errorf("FilesChanged = %v, want [a.go b.go]", div.FilesChanged)
+	}
+	if got := div.Summary(); got != "2 commit(s) ahead, 1 behind, 2 file(s) changed" {
+		t.Errorf("Summary() = %q", got)
+	}
+
+	if _, err := GetDivergence(tmpDir, "main", "no-such-branch"); err == nil {
+		t.Error("GetDivergence() should fail for unknown ref")
+	}
+}
+
+// setupForkWithUpstream creates a bare "upstream" repository seeded from a
+// working copy, and a fork cloned from it with upstream configured. It
+// returns a func that commits to upstream's main branch, and the fork's path.
+func setupForkWithUpstream(t *testing.T) (pushUpstream func(file, content string), forkDir string) {
+	t.Helper()
+
+	commit := func(dir, file, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := gitCmdIsolated(dir, "add", file).Run(); err != nil {
+			t.Fatalf("git add failed: %v", err)
+		}
+		if out, err := gitCmdIsolated(dir, "commit", "-m", content).CombinedOutput(); err != nil {
+			t.Fatalf("git commit failed: %v: %s", err, out)
+		}
+	}
+
+	seed := setupTestRepo(t)
+	t.Cleanup(func() { os.RemoveAll(seed) })
+	commit(seed, "README.md", "initial")
+	gitCmdIsolated(seed, "branch", "-M", "main").Run()
+
+	upstream := filepath.Join(t.TempDir(), "upstream.git")
+	if out, err := gitCmdIsolated(seed, "clone", "--bare", seed, upstream).CombinedOutput(); err != nil {
+		t.Fatalf("git clone --bare failed: %v: %s", err, out)
+	}
+	if out, err := gitCmdIsolated(seed, "remote", "add", "origin", upstream).CombinedOutput(); err != nil {
+		t.Fatalf("git remote add failed: %v: %s", err, out)
+	}
+
+	forkDir = filepath.Join(t.TempDir(), "fork")
+	if out, err := gitCmdIsolated(filepath.Dir(forkDir), "clone", upstream, forkDir).CombinedOutput(); err != nil {
+		t.Fatalf("git clone failed: %v: %s", err, out)
+	}
+	gitCmdIsolated(forkDir, "config", "user.email", "test@example.com").Run()
+	gitCmdIsolated(forkDir, "config", "user.name", "Test User").Run()
+	if err := AddUpstreamRemote(forkDir, upstream); err != nil {
+		t.Fatalf("AddUpstreamRemote() failed: %v", err)
+	}
+
+	pushUpstream = func(file, content string) {
+		t.Helper()
+		commit(seed, file, content)
+		if out, err := gitCmdIsolated(seed, "push", "origin", "main").CombinedOutput(); err != nil {
+			t.Fatalf("git push failed: %v: %s", err, out)
+		}
+	}
+	return pushUpstream, forkDir
+}
+
+func TestBranchDivergence(t *testing.T) {
+	pushUpstream, forkDir := setupForkWithUpstream(t)
+
+	if _, _, err := BranchDivergence(forkDir, "main", "upstream/main"); !errors.Is(err, ErrNotFetched) {
+		t.Fatalf("BranchDivergence() before fetch error = %v, want ErrNotFetched", err)
+	}
+
+	pushUpstream("a.go", "add a")
+	for _, file := range []string{"b.go", "c.go"} {
+		if err := os.WriteFile(filepath.Join(forkDir, file), []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+		gitCmdIsolated(forkDir, "add", file).Run()
+		if out, err := gitCmdIsolated(forkDir, "commit", "-m", file).CombinedOutput(); err != nil {
+			t.Fatalf("git commit failed: %v: %s", err, out)
+		}
+	}
+	if err := FetchUpstream(forkDir, ""); err != nil {
+		t.Fatalf("FetchUpstream() failed: %v", err)
+	}
+
+	ahead, behind, err := BranchDivergence(forkDir, "main", "upstream/main")
+	if err != nil {
+		t.Fatalf("BranchDivergence() failed: %v", err)
+	}
+	if ahead != 2 || behind != 1 {
+		t.Errorf("ahead/behind = %d/%d, want 2/1", ahead, behind)
+	}
+}
+
+func TestSyncWithUpstream(t *testing.T) {
+	pushUpstream, forkDir := setupForkWithUpstream(t)
+
+	result, err := SyncWithUpstream(forkDir, "", "main")
+	if err != nil {
+		t.Fatalf("SyncWithUpstream() failed: %v", err)
+	}
+	if result.Status != SyncUpToDate || result.Ahead != 0 || result.Behind != 0 {
+		t.Errorf("result = %+v, want up to date", result)
+	}
+
+	// Upstream moves ahead: fast-forward
+	pushUpstream("a.go", "add a")
+	result, err = SyncWithUpstream(forkDir, "", "")
+	if err != nil {
+		t.Fatalf("SyncWithUpstream() failed: %v", err)
+	}
+	if result.Branch != "main" || result.Status != SyncFastForwarded || result.Ahead != 0 || result.Behind != 1 {
+		t.Errorf("result = %+v, want main fast-forwarded 0 ahead 1 behind", result)
+	}
+	if _, err := os.Stat(filepath.Join(forkDir, "a.go")); err != nil {
+		t.Error("a.go should have been brought in from upstream")
+	}
+
+	// Uncommitted changes: refused before anything is fetched
+	if err := os.WriteFile(filepath.Join(forkDir, "README.md"), []byte("edited"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SyncWithUpstream(forkDir, "", "main"); !errors.Is(err, ErrDirtyWorktree) {
+		t.Errorf("SyncWithUpstream() error = %v, want ErrDirtyWorktree", err)
+	}
+	if out, err := gitCmdIsolated(forkDir, "checkout", "README.md").CombinedOutput(); err != nil {
+		t.Fatalf("git checkout failed: %v: %s", err, out)
+	}
+
+	// Both sides have commits: left alone
+	pushUpstream("b.go", "add b")
+	if err := os.WriteFile(filepath.Join(forkDir, "c.go"), []byte("add c"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitCmdIsolated(forkDir, "add", "c.go").Run()
+	if out, err := gitCmdIsolated(forkDir, "commit", "-m", "add c").CombinedOutput(); err != nil {
+		t.Fatalf("git commit failed: %v: %s", err, out)
+	}
+	head, _ := gitCmdIsolated(forkDir, "rev-parse", "HEAD").Output()
+	result, err = SyncWithUpstream(forkDir, "", "main")
+	if err != nil {
+		t.Fatalf("SyncWithUpstream() failed: %v", err)
+	}
+	if result.Status != SyncDiverged || result.Ahead != 1 || result.Behind != 1 {
+		t.Errorf("result = %+v, want diverged 1 ahead 1 behind", result)
+	}
+	if after, _ := gitCmdIsolated(forkDir, "rev-parse", "HEAD").Output(); string(after) != string(head) {
+		t.Error("a diverged sync should leave the branch unchanged")
+	}
+}
+
+func TestSyncWithNamedRemote(t *testing.T) {
+	pushUpstream, forkDir := setupForkWithUpstream(t)
+	if out, err := gitCmdIsolated(forkDir, "remote", "rename", "upstream", "staging").CombinedOutput(); err != nil {
+		t.Fatalf("git remote rename failed: %v: %s", err, out)
+	}
+	pushUpstream("a.go", "add a")
+
+	if _, err := SyncWithUpstream(forkDir, "", "main"); err == nil {
+		t.Error("SyncWithUpstream() with no upstream remote should fail")
+	}
+	result, err := SyncWithUpstream(forkDir, "staging", "main")
+	if err != nil {
+		t.Fatalf("SyncWithUpstream(staging) failed: %v", err)
+	}
+	if result.Status != SyncFastForwarded || result.Behind != 1 {
+		t.Errorf("result = %+v, want fast-forwarded 1 behind", result)
+	}
+	if _, err := os.Stat(filepath.Join(forkDir, "a.go")); err != nil {
+		t.Error("a.go should have been brought in from staging")
+	}
+}
+
+func TestSyncWithUpstreamBranchNotCheckedOut(t *testing.T) {
+	pushUpstream, forkDir := setupForkWithUpstream(t)
+
+	if out, err := gitCmdIsolated(forkDir, "checkout", "-b", "feature").CombinedOutput(); err != nil {
+		t.Fatalf("git checkout failed: %v: %s", err, out)
+	}
+	pushUpstream("a.go", "add a")
+
+	result, err := SyncWithUpstream(forkDir, "", "main")
+	if err != nil {
+		t.Fatalf("SyncWithUpstream() failed: %v", err)
+	}
+	if result.Status != SyncFastForwarded || result.Behind != 1 {
+		t.Errorf("result = %+v, want fast-forwarded 1 behind", result)
+	}
+
+	mainHead, _ := gitCmdIsolated(forkDir, "rev-parse", "main").Output()
+	upstreamHead, _ := gitCmdIsolated(forkDir, "rev-parse", "upstream/main").Output()
+	if string(mainHead) != string(upstreamHead) {
+		t.Error("main should point at upstream/main")
+	}
+	if branch, _ := gitCmdIsolated(forkDir, "rev-parse", "--abbrev-ref", "HEAD").Output(); strings.TrimSpace(string(branch)) != "feature" {
+		t.Errorf("checked-out branch = %q, want feature", branch)
+	}
+}
+
+func TestMergeUpstream(t *testing.T) {
+	pushUpstream, forkDir := setupForkWithUpstream(t)
+
+	// Both sides change the same file: conflict, aborted
+	pushUpstream("README.md", "upstream change")
+	if err := os.WriteFile(filepath.Join(forkDir, "README.md"), []byte("fork change"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := gitCmdIsolated(forkDir, "commit", "-am", "fork change").CombinedOutput(); err != nil {
+		t.Fatalf("git commit failed: %v: %s", err, out)
+	}
+	head, _ := gitCmdIsolated(forkDir, "rev-parse", "HEAD").Output()
+	if err := FetchUpstream(forkDir, ""); err != nil {
+		t.Fatalf("FetchUpstream() failed: %v", err)
+	}
+	result, err := MergeUpstream(forkDir, "")
+	if err != nil {
+		t.Fatalf("MergeUpstream() failed: %v", err)
+	}
+	if !result.Conflicts || result.Status != SyncDiverged || result.Ahead != 1 || result.Behind != 1 {
+		t.Errorf("result = %+v, want diverged 1 ahead 1 behind with conflicts", result)
+	}
+	if after, _ := gitCmdIsolated(forkDir, "rev-parse", "HEAD").Output(); string(after) != string(head) {
+		t.Error("a conflicting merge should leave the branch unchanged")
+	}
+	if status, _ := gitCmdIsolated(forkDir, "status", "--porcelain").Output(); len(status) != 0 {
+		t.Errorf("working tree should be clean after abort, got %s", status)
+	}
+
+	// Upstream changes a different file: merged
+	if out, err := gitCmdIsolated(forkDir, "reset", "--hard", "upstream/main").CombinedOutput(); err != nil {
+		t.Fatalf("git reset failed: %v: %s", err, out)
+	}
+	if err := os.WriteFile(filepath.Join(forkDir, "local.go"), []byte("local"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitCmdIsolated(forkDir, "add", "local.go").Run()
+	if out, err := gitCmdIsolated(forkDir, "commit", "-m", "local").CombinedOutput(); err != nil {
+		t.Fatalf("git commit failed: %v: %s", err, out)
+	}
+	pushUpstream("a.go", "add a")
+	if err := FetchUpstream(forkDir, ""); err != nil {
+		t.Fatalf("FetchUpstream() failed: %v", err)
+	}
+	result, err = MergeUpstream(forkDir, "")
+	if err != nil {
+		t.Fatalf("MergeUpstream() failed: %v", err)
+	}
+	if result.Status != SyncMerged || result.Conflicts {
+		t.Errorf("result = %+v, want merged without conflicts", result)
+	}
+	if _, err := os.Stat(filepath.Join(forkDir, "a.go")); err != nil {
+		t.Error("a.go should have been merged in from upstream")
+	}
+}
+
+func TestDefaultBranch(t *testing.T) {
+	newRepo := func(branch string) string {
+		t.Helper()
+		dir := setupTestRepo(t)
+		t.Cleanup(func() { os.RemoveAll(dir) })
+		if out, err := gitCmdIsolated(dir, "commit", "--allow-empty", "-m", "initial").CombinedOutput(); err != nil {
+			t.Fatalf("git commit failed: %v: %s", err, out)
+		}
+		gitCmdIsolated(dir, "branch", "-M", branch).Run()
+		return dir
+	}
+
+	origin := newRepo("trunk")
+	upstream := newRepo("develop")
+
+	forkDir := filepath.Join(t.TempDir(), "fork")
+	if out, err := gitCmdIsolated(filepath.Dir(forkDir), "clone", origin, forkDir).CombinedOutput(); err != nil {
+		t.Fatalf("git clone failed: %v: %s", err, out)
+	}
+	if got := DefaultBranch(forkDir, "origin"); got != "trunk" {
+		t.Errorf("DefaultBranch(origin) = %q, want trunk", got)
+	}
+
+	// Added but never fetched: the remote is asked
+	if err := AddUpstreamRemote(forkDir, upstream); err != nil {
+		t.Fatalf("AddUpstreamRemote() failed: %v", err)
+	}
+	if got := DefaultBranch(forkDir, "upstream"); got != "develop" {
+		t.Errorf("DefaultBranch(unfetched upstream) = %q, want develop", got)
+	}
+	if got := DefaultBranch(forkDir, "missing"); got != "" {
+		t.Errorf("DefaultBranch(missing) = %q, want empty", got)
+	}
+
+	if err := FetchUpstream(forkDir, ""); err != nil {
+		t.Fatalf("FetchUpstream() failed: %v", err)
+	}
+	if out, err := gitCmdIsolated(forkDir, "remote", "set-head", "upstream", "--auto").CombinedOutput(); err != nil {
+		t.Fatalf("git remote set-head failed: %v: %s", err, out)
+	}
+	if got := DefaultBranch(forkDir, "upstream"); got != "develop" {
+		t.Errorf("DefaultBranch(upstream) = %q, want develop", got)
+	}
+}
+
+func TestFetchUpstreamWithoutRemote(t *testing.T) {
+	tmpDir := setupTestRepo(t)
+	defer os.RemoveAll(tmpDir)
+
+	if err := FetchUpstream(tmpDir, ""); err == nil {
+		t.Error("FetchUpstream() should fail without an upstream remote")
+	}
+}
+
+func TestAddUpstreamRemoteDryRun(t *testing.T) {
+	tmpDir := setupTestRepo(t)
+	defer os.RemoveAll(tmpDir)
+
+	remotes := func() string {
+		t.Helper()
+		out, err := gitCmdIsolated(tmpDir, "remote", "-v").Output()
+		if err != nil {
+			t.Fatalf("git remote failed: %v", err)
+		}
+		return string(out)
+	}
+
+	plan := AddUpstreamRemoteDryRun(tmpDir, "https://github.com/original/repo")
+	if plan.Update || plan.CurrentURL != "" {
+		t.Errorf("plan = %+v, want an add", plan)
+	}
+	want := [][]string{{"git", "-C", tmpDir, "remote", "add", "upstream", "https://github.com/original/repo"}}
+	if !reflect.DeepEqual(plan.Commands, want) {
+		t.Errorf("Commands = %v, want %v", plan.Commands, want)
+	}
+	if got := remotes(); got != "" {
+		t.Errorf("dry run added remotes: %q", got)
+	}
+
+	if err := gitCmdIsolated(tmpDir, "remote", "add", "upstream", "https://github.com/old/repo").Run(); err != nil {
+		t.Fatalf("failed to add upstream: %v", err)
+	}
+	before := remotes()
+
+	plan = AddUpstreamRemoteDryRun(tmpDir, "https://github.com/new/repo")
+	if !plan.Update || !urlsEquivalent(plan.CurrentURL, "https://github.com/old/repo") {
+		t.Errorf("plan = %+v, want an update from old/repo", plan)
+	}
+	if len(plan.Commands) != 1 || plan.Commands[0][4] != "set-url" {
+		t.Errorf("Commands = %v, want a set-url", plan.Commands)
+	}
+	if after := remotes(); after != before {
+		t.Errorf("dry run changed remotes:\nbefore %q\nafter  %q", before, after)
+	}
+}
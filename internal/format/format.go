@@ -3,6 +3,7 @@ package format
 
 import (
 	"fmt"
+	"io"
 	"strings"
 	"time"
 
@@ -75,12 +76,22 @@ func ColoredStatus(status Status) string {
 
 // Header prints a bold header line
 func Header(format string, args ...interface{}) {
-	Bold.Printf(format+"\n", args...)
+	HeaderTo(color.Output, format, args...)
+}
+
+// HeaderTo writes a bold header line to w
+func HeaderTo(w io.Writer, format string, args ...interface{}) {
+	Bold.Fprintf(w, format+"\n", args...)
 }
 
 // Dimmed prints dimmed/muted text
 func Dimmed(format string, args ...interface{}) {
-	Dim.Printf(format+"\n", args...)
+	DimmedTo(color.Output, format, args...)
+}
+
+// DimmedTo writes dimmed/muted text to w
+func DimmedTo(w io.Writer, format string, args ...interface{}) {
+	Dim.Fprintf(w, format+"\n", args...)
 }
 
 // TimeAgo formats a time as a human-readable relative time
@@ -250,33 +261,38 @@ func (t *ColoredTable) AddRow(cells ...ColoredCell) {
 
 // Print prints the colored table
 func (t *ColoredTable) Print() {
+	t.PrintTo(color.Output)
+}
+
+// PrintTo writes the colored table to w
+func (t *ColoredTable) PrintTo(w io.Writer) {
 	// Header
 	for i, h := range t.headers {
 		if i > 0 {
-			fmt.Print("  ")
+			fmt.Fprint(w, "  ")
 		}
-		t.headerColors[i].Printf("%-*s", t.widths[i], h)
+		t.headerColors[i].Fprintf(w, "%-*s", t.widths[i], h)
 	}
-	fmt.Println()
+	fmt.Fprintln(w)
 
 	// Separator
-	Dim.Print(strings.Repeat("-", t.totalWidth()))
-	fmt.Println()
+	Dim.Fprint(w, strings.Repeat("-", t.totalWidth()))
+	fmt.Fprintln(w)
 
 	// Rows
 	for _, row := range t.rows {
 		for i, cell := range row {
 			if i > 0 {
-				fmt.Print("  ")
+				fmt.Fprint(w, "  ")
 			}
 			text := fmt.Sprintf("%-*s", t.widths[i], cell.Text)
 			if cell.Color != nil {
-				cell.Color.Print(text)
+				cell.Color.Fprint(w, text)
 			} else {
-				fmt.Print(text)
+				fmt.Fprint(w, text)
 			}
 		}
-		fmt.Println()
+		fmt.Fprintln(w)
 	}
 }
 
@@ -1,9 +1,12 @@
 package format
 
 import (
+	"bytes"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/fatih/color"
 )
 
 func TestStatusColor(t *testing.T) {
@@ -364,6 +367,24 @@ func TestColoredTablePrint(t *testing.T) {
 	table.Print()
 }
 
+func TestColoredTablePrintTo(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	table := NewColoredTable("STATUS", "NAME")
+	table.AddRow(ColorCell("running", Green), Cell("worker-1"))
+
+	var buf bytes.Buffer
+	table.PrintTo(&buf)
+	HeaderTo(&buf, "Header %d", 1)
+
+	want := "STATUS   NAME    \n-----------------\nrunning  worker-1\nHeader 1\n"
+	if got := buf.String(); got != want {
+		t.Errorf("PrintTo() wrote %q, want %q", got, want)
+	}
+}
+
 func TestColoredTableTotalWidthCalculation(t *testing.T) {
 	// Test totalWidth calculation explicitly
 	table := NewColoredTable("A", "BB", "CCC")
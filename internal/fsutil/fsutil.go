@@ -0,0 +1,41 @@
+// Package fsutil holds small filesystem helpers shared across packages.
+package fsutil
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// WriteFileAtomic writes data to path with the given permissions, like
+// os.WriteFile, but through a temp file in the same directory that is renamed
+// into place. Readers, and a process that crashes mid-write, see either the
+// old contents or the new, never a partial file. The temp file is named like
+// ".<base>-*.tmp", so stale ones left by a crash are easy to spot, and is
+// removed if the write fails.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+
+	_, writeErr := tmp.Write(data)
+	closeErr := tmp.Close()
+	if writeErr != nil || closeErr != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write temp file: %w", errors.Join(writeErr, closeErr))
+	}
+	// CreateTemp always uses 0600
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename temp file: %w", err)
+	}
+	return nil
+}
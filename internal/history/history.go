@@ -0,0 +1,448 @@
+// Package history keeps a bounded record of the socket commands the daemon
+// has processed, for debugging.
+//
+// A Recorder appends every command to a JSON Lines file, so history survives
+// daemon restarts, and holds the most recent ones in memory for Recent; a
+// recorder created with an empty path keeps history in memory only. Argument
+// values that look sensitive are redacted before they are recorded.
+//
+// Compact moves old entries into a timestamped archive beside the file, the
+// way the daemon rotates agent logs, and prunes the oldest archives. Record
+// compacts on its own once the file passes DefaultMaxFileSize, keeping only
+// the entries held in memory, so the file stays small however busy the
+// daemon is. The archive is written before the live file is replaced, so a
+// failure part way through can repeat entries but never lose them.
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dlorenc/multiclaude/internal/fsutil"
+	"github.com/dlorenc/multiclaude/internal/redact"
+)
+
+// ErrCorrupt reports a history file that exists but cannot be parsed
+var ErrCorrupt = errors.New("history file is corrupt")
+
+// DefaultSize is how many commands a recorder keeps in memory by default
+const DefaultSize = 100
+
+// DefaultMaxFileSize is how large the history file may grow before Record
+// compacts it
+const DefaultMaxFileSize int64 = 1 << 20
+
+// DefaultMaxArchives is how many archives Compact keeps when
+// CompactOptions.MaxArchives is not set
+const DefaultMaxArchives = 10
+
+// archiveLayout timestamps archive names; it sorts chronologically
+const archiveLayout = "20060102-150405.000000000"
+
+// maxArgLength is the longest string argument recorded in full; longer
+// values such as prompts are truncated to keep the file small
+const maxArgLength = 200
+
+// Entry is one processed command
+type Entry struct {
+	Command string                 `json:"command"`
+	Time    time.Time              `json:"time"`
+	Success bool                   `json:"success"`
+	Error   string                 `json:"error,omitempty"`
+	Args    map[string]interface{} `json:"args,omitempty"`
+}
+
+// Recorder records commands to its file and keeps the most recent in
+// memory, oldest first
+type Recorder struct {
+	mu          sync.Mutex
+	path        string
+	size        int
+	entries     []Entry // The newest size entries
+	fileSize    int64   // Bytes in the history file
+	maxFileSize int64   // fileSize past which Record compacts
+}
+
+// NewRecorder creates a recorder appending to the file at path and keeping up
+// to size commands in memory, loaded from any history saved by a previous
+// run. A missing file starts empty; one that can't be parsed fails with
+// ErrCorrupt.
+func NewRecorder(path string, size int) (*Recorder, error) {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	r := &Recorder{path: path, size: size, maxFileSize: DefaultMaxFileSize}
+	if path == "" {
+		return r, nil
+	}
+
+	entries, err := readEntries(path)
+	if err != nil {
+		return nil, err
+	}
+	r.setRecent(entries)
+	if info, err := os.Stat(path); err == nil {
+		r.fileSize = info.Size()
+	}
+	return r, nil
+}
+
+// readEntries reads every entry in the history file at path. A missing file
+// has none.
+func readEntries(path string) ([]Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	var entries []Entry
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrCorrupt, i+1, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// setRecent keeps the newest size of entries in memory. Caller must hold
+// r.mu, or own r exclusively.
+func (r *Recorder) setRecent(entries []Entry) {
+	if len(entries) > r.size {
+		entries = entries[len(entries)-r.size:]
+	}
+	r.entries = append([]Entry{}, entries...)
+}
+
+// MoveAside renames the history file at path to a timestamped name beside
+// it, so a recorder can start empty while the old file is kept for
+// inspection. It returns the new path.
+func MoveAside(path string) (string, error) {
+	moved := path + ".corrupt-" + time.Now().Format("20060102-150405")
+	if err := os.Rename(path, moved); err != nil {
+		return "", fmt.Errorf("failed to move history aside: %w", err)
+	}
+	return moved, nil
+}
+
+// Record appends a command to the history file and to the recent commands,
+// dropping the oldest from memory once the recorder is full. Args are
+// redacted and truncated first. If the file has grown past its size limit,
+// the entries no longer held in memory are archived out of it.
+func (r *Recorder) Record(command string, args map[string]interface{}, success bool, errMsg string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e := Entry{
+		Command: command,
+		Time:    time.Now(),
+		Success: success,
+		Error:   errMsg,
+		Args:    sanitizeArgs(args),
+	}
+	r.entries = append(r.entries, e)
+	if len(r.entries) > r.size {
+		r.entries = append([]Entry(nil), r.entries[len(r.entries)-r.size:]...)
+	}
+
+	if err := r.appendUnlocked(e); err != nil {
+		return err
+	}
+	if r.path != "" && r.fileSize > r.maxFileSize {
+		if _, err := r.compactUnlocked(CompactOptions{Keep: r.size}); err != nil {
+			return fmt.Errorf("failed to compact history: %w", err)
+		}
+	}
+	return nil
+}
+
+// Recent returns up to n of the most recent commands, oldest first. A
+// non-positive n returns everything recorded.
+func (r *Recorder) Recent(n int) []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := r.entries
+	if n > 0 && len(entries) > n {
+		entries = entries[len(entries)-n:]
+	}
+	return append([]Entry{}, entries...)
+}
+
+// CompactOptions selects which entries Compact archives
+type CompactOptions struct {
+	// MaxAge archives entries older than this; zero disables the age limit
+	MaxAge time.Duration
+	// Keep is how many of the newest entries stay in the live file; zero
+	// disables the count limit
+	Keep int
+	// Summarize writes per-command counts to the archive instead of the
+	// archived entries themselves
+	Summarize bool
+	// MaxArchives is how many archives to keep; older ones are deleted.
+	// Zero means DefaultMaxArchives.
+	MaxArchives int
+}
+
+// CompactResult reports what Compact did
+type CompactResult struct {
+	Archived int    `json:"archived"`
+	Kept     int    `json:"kept"`
+	Archive  string `json:"archive,omitempty"` // Path of the archive file, if one was written
+	Pruned   int    `json:"pruned,omitempty"`  // Old archives deleted
+}
+
+// Summary stands in for archived entries when CompactOptions.Summarize is set
+type Summary struct {
+	From     time.Time      `json:"from"`
+	To       time.Time      `json:"to"`
+	Total    int            `json:"total"`
+	Failures int            `json:"failures"`
+	Commands map[string]int `json:"commands"`
+}
+
+// Compact archives the entries in the history file that are older than
+// opts.MaxAge or beyond the newest opts.Keep, replaces the file with the rest,
+// and prunes archives beyond opts.MaxArchives. A recorder with no path only
+// trims its recent commands.
+func (r *Recorder) Compact(opts CompactOptions) (CompactResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.compactUnlocked(opts)
+}
+
+// compactUnlocked is Compact. Caller must hold r.mu.
+func (r *Recorder) compactUnlocked(opts CompactOptions) (CompactResult, error) {
+	entries := r.entries
+	if r.path != "" {
+		all, err := readEntries(r.path)
+		if err != nil {
+			return CompactResult{}, err
+		}
+		entries = all
+	}
+
+	// Entries are oldest first, so everything before cut is archived
+	cut := 0
+	if opts.MaxAge > 0 {
+		cutoff := time.Now().Add(-opts.MaxAge)
+		cut = sort.Search(len(entries), func(i int) bool {
+			return !entries[i].Time.Before(cutoff)
+		})
+	}
+	if opts.Keep > 0 && len(entries)-cut > opts.Keep {
+		cut = len(entries) - opts.Keep
+	}
+
+	result := CompactResult{Archived: cut, Kept: len(entries) - cut}
+	if cut == 0 {
+		return result, nil
+	}
+
+	old, kept := entries[:cut], entries[cut:]
+	if r.path != "" {
+		var archive interface{} = old
+		if opts.Summarize {
+			archive = summarize(old)
+		}
+		result.Archive = archivePath(r.path, time.Now())
+		if err := writeJSONAtomic(result.Archive, archive); err != nil {
+			return CompactResult{}, fmt.Errorf("failed to write history archive: %w", err)
+		}
+		written, err := writeEntries(r.path, kept)
+		if err != nil {
+			return CompactResult{}, err
+		}
+		r.fileSize = written
+
+		maxArchives := opts.MaxArchives
+		if maxArchives <= 0 {
+			maxArchives = DefaultMaxArchives
+		}
+		pruned, err := pruneArchives(r.path, maxArchives)
+		result.Pruned = pruned
+		if err != nil {
+			return result, err
+		}
+	}
+
+	r.setRecent(kept)
+	return result, nil
+}
+
+// archivePath returns an unused archive name for path, timestamped at now
+func archivePath(path string, now time.Time) string {
+	for {
+		archive := path + "." + now.Format(archiveLayout)
+		if _, err := os.Stat(archive); os.IsNotExist(err) {
+			return archive
+		}
+		now = now.Add(time.Nanosecond)
+	}
+}
+
+// Archives returns the archives Compact has written beside path, oldest
+// first
+func Archives(path string) ([]string, error) {
+	dirEntries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list history archives: %w", err)
+	}
+
+	prefix := filepath.Base(path) + "."
+	var archives []string
+	for _, entry := range dirEntries {
+		stamp, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if _, err := time.Parse(archiveLayout, stamp); err != nil {
+			continue
+		}
+		archives = append(archives, filepath.Join(filepath.Dir(path), entry.Name()))
+	}
+	sort.Strings(archives)
+	return archives, nil
+}
+
+// pruneArchives deletes the oldest archives of path so at most keep remain,
+// and returns how many it deleted
+func pruneArchives(path string, keep int) (int, error) {
+	archives, err := Archives(path)
+	if err != nil {
+		return 0, err
+	}
+	pruned := 0
+	for len(archives) > keep {
+		if err := os.Remove(archives[0]); err != nil && !os.IsNotExist(err) {
+			return pruned, fmt.Errorf("failed to prune history archive: %w", err)
+		}
+		archives = archives[1:]
+		pruned++
+	}
+	return pruned, nil
+}
+
+// summarize counts entries by command
+func summarize(entries []Entry) Summary {
+	s := Summary{Total: len(entries), Commands: make(map[string]int)}
+	for i, e := range entries {
+		if i == 0 || e.Time.Before(s.From) {
+			s.From = e.Time
+		}
+		if e.Time.After(s.To) {
+			s.To = e.Time
+		}
+		if !e.Success {
+			s.Failures++
+		}
+		s.Commands[e.Command]++
+	}
+	return s
+}
+
+// sanitizeArgs returns a copy of args with sensitive values redacted and
+// long strings truncated
+func sanitizeArgs(args map[string]interface{}) map[string]interface{} {
+	if len(args) == 0 {
+		return nil
+	}
+
+	out := make(map[string]interface{}, len(args))
+	for key, value := range args {
+		if redact.IsSensitiveEnv(key) {
+			out[key] = redact.Redacted
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			if len(v) > maxArgLength {
+				v = v[:maxArgLength] + "..."
+			}
+			out[key] = v
+		case map[string]string:
+			out[key] = redact.Env(v)
+		case map[string]interface{}:
+			// Nested objects such as env are redacted by key
+			nested := make(map[string]interface{}, len(v))
+			for k, nv := range v {
+				if redact.IsSensitiveEnv(k) {
+					nv = redact.Redacted
+				}
+				nested[k] = nv
+			}
+			out[key] = nested
+		default:
+			out[key] = v
+		}
+	}
+	return out
+}
+
+// appendUnlocked appends e to the history file. Caller must hold r.mu.
+func (r *Recorder) appendUnlocked(e Entry) error {
+	if r.path == "" {
+		return nil
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history: %w", err)
+	}
+
+	f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	// One write per entry, so concurrent readers never see half a line
+	n, writeErr := f.Write(append(line, '\n'))
+	r.fileSize += int64(n)
+	closeErr := f.Close()
+	if err := errors.Join(writeErr, closeErr); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// writeEntries replaces the history file at path with entries, atomically,
+// and returns the new file's size
+func writeEntries(path string, entries []Entry) (int64, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return 0, fmt.Errorf("failed to marshal history: %w", err)
+		}
+	}
+	if err := fsutil.WriteFileAtomic(path, buf.Bytes(), 0600); err != nil {
+		return 0, fmt.Errorf("failed to write history: %w", err)
+	}
+	return int64(buf.Len()), nil
+}
+
+// writeJSONAtomic writes v as indented JSON to path atomically
+func writeJSONAtomic(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal history: %w", err)
+	}
+
+	if err := fsutil.WriteFileAtomic(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
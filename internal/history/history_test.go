@@ -0,0 +1,307 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dlorenc/multiclaude/internal/redact"
+)
+
+// commands returns the command names of entries in order
+func commands(entries []Entry) []string {
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Command)
+	}
+	return names
+}
+
+func TestRecorderKeepsMostRecent(t *testing.T) {
+	r, err := NewRecorder("", 3)
+	if err != nil {
+		t.Fatalf("NewRecorder() failed: %v", err)
+	}
+	for _, cmd := range []string{"a", "b", "c", "d"} {
+		if err := r.Record(cmd, nil, true, ""); err != nil {
+			t.Fatalf("Record() failed: %v", err)
+		}
+	}
+
+	if got := strings.Join(commands(r.Recent(0)), ","); got != "b,c,d" {
+		t.Errorf("Recent(0) = %s, want b,c,d", got)
+	}
+	if got := strings.Join(commands(r.Recent(2)), ","); got != "c,d" {
+		t.Errorf("Recent(2) = %s, want c,d", got)
+	}
+}
+
+func TestRecorderPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "commands.jsonl")
+
+	r, err := NewRecorder(path, 10)
+	if err != nil {
+		t.Fatalf("NewRecorder() failed: %v", err)
+	}
+	r.Record("ping", nil, true, "")
+	r.Record("kill_agent", map[string]interface{}{"repo": "r", "agent": "a"}, false, "agent is busy")
+
+	// A new recorder, as after a daemon restart, sees the same history
+	reloaded, err := NewRecorder(path, 10)
+	if err != nil {
+		t.Fatalf("NewRecorder() failed: %v", err)
+	}
+	entries := reloaded.Recent(0)
+	if len(entries) != 2 || entries[0].Command != "ping" || entries[1].Command != "kill_agent" {
+		t.Fatalf("reloaded history = %+v", entries)
+	}
+	if entries[1].Success || entries[1].Error != "agent is busy" || entries[1].Args["agent"] != "a" {
+		t.Errorf("kill_agent entry = %+v", entries[1])
+	}
+	if entries[0].Time.IsZero() {
+		t.Error("entries should be timestamped")
+	}
+
+	// Shrinking the size keeps only the newest
+	small, err := NewRecorder(path, 1)
+	if err != nil {
+		t.Fatalf("NewRecorder() failed: %v", err)
+	}
+	if got := commands(small.Recent(0)); len(got) != 1 || got[0] != "kill_agent" {
+		t.Errorf("history after shrinking = %v", got)
+	}
+}
+
+func TestRecorderCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "commands.jsonl")
+	if err := os.WriteFile(path, []byte(`[{"command": "ping"`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewRecorder(path, 10); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("NewRecorder() error = %v, want ErrCorrupt", err)
+	}
+
+	moved, err := MoveAside(path)
+	if err != nil {
+		t.Fatalf("MoveAside() failed: %v", err)
+	}
+	if data, err := os.ReadFile(moved); err != nil || !strings.Contains(string(data), "ping") {
+		t.Errorf("moved file = %q (%v), want the corrupt contents", data, err)
+	}
+
+	r, err := NewRecorder(path, 10)
+	if err != nil {
+		t.Fatalf("NewRecorder() after MoveAside failed: %v", err)
+	}
+	if got := r.Recent(0); len(got) != 0 {
+		t.Errorf("history = %+v, want empty", got)
+	}
+}
+
+func TestRecorderRedactsArgs(t *testing.T) {
+	r, _ := NewRecorder("", 10)
+	r.Record("spawn_agent", map[string]interface{}{
+		"name":      "worker",
+		"api_token": "secret",
+		"env":       map[string]interface{}{"MODEL": "fast", "GITHUB_TOKEN": "ghp_secret"},
+		"prompt":    strings.Repeat("x", 1000),
+	}, true, "")
+
+	args := r.Recent(0)[0].Args
+	if args["name"] != "worker" {
+		t.Errorf("name = %v, want worker", args["name"])
+	}
+	if args["api_token"] != redact.Redacted {
+		t.Errorf("api_token = %v, want it redacted", args["api_token"])
+	}
+	env := args["env"].(map[string]interface{})
+	if env["MODEL"] != "fast" || env["GITHUB_TOKEN"] != redact.Redacted {
+		t.Errorf("env = %v, want GITHUB_TOKEN redacted", env)
+	}
+	if prompt := args["prompt"].(string); len(prompt) > maxArgLength+3 {
+		t.Errorf("prompt recorded with %d bytes, want it truncated", len(prompt))
+	}
+}
+
+func TestCompactArchivesWithoutLoss(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "commands.jsonl")
+
+	// The file holds every command, not just the 10 kept in memory
+	r, err := NewRecorder(path, 10)
+	if err != nil {
+		t.Fatalf("NewRecorder() failed: %v", err)
+	}
+	for i := 0; i < 500; i++ {
+		if err := r.Record(fmt.Sprintf("cmd-%d", i), nil, true, ""); err != nil {
+			t.Fatalf("Record() failed: %v", err)
+		}
+	}
+
+	result, err := r.Compact(CompactOptions{Keep: 100})
+	if err != nil {
+		t.Fatalf("Compact() failed: %v", err)
+	}
+	if result.Archived != 400 || result.Kept != 100 || result.Archive == "" {
+		t.Fatalf("Compact() = %+v, want 400 archived and 100 kept", result)
+	}
+
+	data, err := os.ReadFile(result.Archive)
+	if err != nil {
+		t.Fatalf("failed to read archive: %v", err)
+	}
+	var archived []Entry
+	if err := json.Unmarshal(data, &archived); err != nil {
+		t.Fatalf("archive is not a list of entries: %v", err)
+	}
+
+	// The archive followed by the live file is every command, in order
+	live, err := readEntries(path)
+	if err != nil {
+		t.Fatalf("readEntries() failed: %v", err)
+	}
+	all := append(commands(archived), commands(live)...)
+	if len(all) != 500 {
+		t.Fatalf("archive and live history hold %d entries, want 500", len(all))
+	}
+	for i, cmd := range all {
+		if want := fmt.Sprintf("cmd-%d", i); cmd != want {
+			t.Fatalf("entry %d = %s, want %s", i, cmd, want)
+		}
+	}
+
+	// Nothing left to compact
+	if result, err := r.Compact(CompactOptions{Keep: 100}); err != nil || result.Archived != 0 || result.Archive != "" {
+		t.Errorf("second Compact() = %+v, %v, want no-op", result, err)
+	}
+}
+
+func TestCompactByAgeSummarizes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "commands.jsonl")
+
+	old := time.Now().Add(-48 * time.Hour)
+	if _, err := writeEntries(path, []Entry{
+		{Command: "ping", Time: old, Success: true},
+		{Command: "kill_agent", Time: old, Error: "agent is busy"},
+		{Command: "ping", Time: old, Success: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	r, _ := NewRecorder(path, 10)
+	r.Record("status", nil, true, "")
+
+	result, err := r.Compact(CompactOptions{MaxAge: 24 * time.Hour, Summarize: true})
+	if err != nil {
+		t.Fatalf("Compact() failed: %v", err)
+	}
+	if result.Archived != 3 || result.Kept != 1 {
+		t.Fatalf("Compact() = %+v, want 3 archived and 1 kept", result)
+	}
+	if got := commands(r.Recent(0)); len(got) != 1 || got[0] != "status" {
+		t.Errorf("live history = %v, want [status]", got)
+	}
+
+	data, err := os.ReadFile(result.Archive)
+	if err != nil {
+		t.Fatalf("failed to read archive: %v", err)
+	}
+	var summary Summary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatalf("archive is not a summary: %v", err)
+	}
+	if summary.Total != 3 || summary.Failures != 1 || summary.Commands["ping"] != 2 || summary.Commands["kill_agent"] != 1 {
+		t.Errorf("summary = %+v", summary)
+	}
+}
+
+func TestCompactPrunesArchives(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "commands.jsonl")
+
+	// Files that are not archives are left alone
+	unrelated := []string{path + ".corrupt-20240101-000000", path + ".notes"}
+	for _, f := range unrelated {
+		if err := os.WriteFile(f, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, _ := NewRecorder(path, 10)
+	var written []string
+	for i := 0; i < 4; i++ {
+		r.Record("ping", nil, true, "")
+		r.Record("status", nil, true, "")
+		result, err := r.Compact(CompactOptions{Keep: 1, MaxArchives: 2})
+		if err != nil {
+			t.Fatalf("Compact() failed: %v", err)
+		}
+		written = append(written, result.Archive)
+		want := 0
+		if len(written) > 2 {
+			want = 1
+		}
+		if result.Pruned != want {
+			t.Errorf("compaction %d pruned %d archives, want %d", i, result.Pruned, want)
+		}
+	}
+
+	archives, err := Archives(path)
+	if err != nil {
+		t.Fatalf("Archives() failed: %v", err)
+	}
+	if want := written[2:]; !slices.Equal(archives, want) {
+		t.Errorf("archives = %v, want the newest two %v", archives, want)
+	}
+	for _, f := range unrelated {
+		if _, err := os.Stat(f); err != nil {
+			t.Errorf("%s was removed: %v", f, err)
+		}
+	}
+}
+
+func TestRecordBoundsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "commands.jsonl")
+	r, err := NewRecorder(path, 5)
+	if err != nil {
+		t.Fatalf("NewRecorder() failed: %v", err)
+	}
+	r.maxFileSize = 1024
+
+	for i := 0; i < 200; i++ {
+		if err := r.Record("status", map[string]interface{}{"n": i}, true, ""); err != nil {
+			t.Fatalf("Record() failed: %v", err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > r.maxFileSize {
+			t.Fatalf("history file is %d bytes after %d records, want at most %d", info.Size(), i+1, r.maxFileSize)
+		}
+	}
+
+	// What was trimmed is archived, not lost, and the archives are pruned
+	archives, err := Archives(path)
+	if err != nil {
+		t.Fatalf("Archives() failed: %v", err)
+	}
+	if len(archives) == 0 || len(archives) > DefaultMaxArchives {
+		t.Errorf("got %d archives, want between 1 and %d", len(archives), DefaultMaxArchives)
+	}
+
+	// A restarted recorder reads the bounded file and sees the newest entries
+	reloaded, err := NewRecorder(path, 5)
+	if err != nil {
+		t.Fatalf("NewRecorder() failed: %v", err)
+	}
+	entries := reloaded.Recent(0)
+	if len(entries) != 5 || entries[4].Args["n"] != float64(199) {
+		t.Errorf("reloaded history = %+v, want the newest 5 entries", entries)
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"io"
 	"log"
 	"os"
+	"strings"
 	"sync"
+	"time"
 )
 
 // Logger provides structured logging
@@ -13,6 +15,22 @@ type Logger struct {
 	mu     sync.Mutex
 	writer io.Writer
 	logger *log.Logger
+	file   *os.File // Set when the logger opened the file itself
+	level  int      // Messages below this index in levels are dropped
+
+	lastError time.Time // When Error was last called
+}
+
+// levels are the log levels in increasing severity
+var levels = []string{"DEBUG", "INFO", "WARN", "ERROR"}
+
+func levelIndex(level string) int {
+	for i, l := range levels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
 }
 
 // New creates a new logger that writes to the given writer
@@ -30,7 +48,47 @@ func NewFile(path string) (*Logger, error) {
 		return nil, fmt.Errorf("failed to open log file: %w", err)
 	}
 
-	return New(f), nil
+	l := New(f)
+	l.file = f
+	return l, nil
+}
+
+// Reopen switches the logger to a freshly opened file at path, closing the
+// previous file if the logger opened it. After the log file is rotated by
+// renaming it, this starts a new file at the original path.
+func (l *Logger) Reopen(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	l.mu.Lock()
+	old := l.file
+	l.writer = f
+	l.file = f
+	l.logger.SetOutput(f)
+	l.mu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+// SetLevel sets the least severe level that is logged: "debug", "info",
+// "warn" or "error", in any case. The empty string logs everything.
+func (l *Logger) SetLevel(level string) error {
+	i := 0
+	if level != "" {
+		if i = levelIndex(strings.ToUpper(level)); i < 0 {
+			return fmt.Errorf("unknown log level %q", level)
+		}
+	}
+
+	l.mu.Lock()
+	l.level = i
+	l.mu.Unlock()
+	return nil
 }
 
 // Info logs an informational message
@@ -46,6 +104,18 @@ func (l *Logger) Warn(format string, args ...interface{}) {
 // Error logs an error message
 func (l *Logger) Error(format string, args ...interface{}) {
 	l.log("ERROR", format, args...)
+
+	l.mu.Lock()
+	l.lastError = time.Now()
+	l.mu.Unlock()
+}
+
+// LastError returns when an error was last logged, or the zero time if none
+// has been
+func (l *Logger) LastError() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lastError
 }
 
 // Debug logs a debug message
@@ -58,6 +128,10 @@ func (l *Logger) log(level, format string, args ...interface{}) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	if levelIndex(level) < l.level {
+		return
+	}
+
 	msg := fmt.Sprintf(format, args...)
 	l.logger.Printf("[%s] %s", level, msg)
 }
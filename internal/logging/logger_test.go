@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestNew(t *testing.T) {
@@ -100,6 +101,24 @@ func TestLoggerError(t *testing.T) {
 	}
 }
 
+func TestLoggerLastError(t *testing.T) {
+	logger := New(&bytes.Buffer{})
+	if got := logger.LastError(); !got.IsZero() {
+		t.Errorf("LastError() = %v before any error, want zero", got)
+	}
+
+	logger.Warn("only a warning")
+	if got := logger.LastError(); !got.IsZero() {
+		t.Errorf("LastError() = %v after a warning, want zero", got)
+	}
+
+	before := time.Now()
+	logger.Error("boom")
+	if got := logger.LastError(); got.Before(before) {
+		t.Errorf("LastError() = %v, want at or after %v", got, before)
+	}
+}
+
 func TestLoggerDebug(t *testing.T) {
 	buf := &bytes.Buffer{}
 	logger := New(buf)
@@ -115,6 +134,39 @@ func TestLoggerDebug(t *testing.T) {
 	}
 }
 
+func TestLoggerSetLevel(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := New(buf)
+
+	if err := logger.SetLevel("Warn"); err != nil {
+		t.Fatalf("SetLevel() error = %v", err)
+	}
+	logger.Debug("debug line")
+	logger.Info("info line")
+	logger.Warn("warn line")
+	logger.Error("error line")
+
+	output := buf.String()
+	if strings.Contains(output, "debug line") || strings.Contains(output, "info line") {
+		t.Errorf("output = %q, want messages below WARN dropped", output)
+	}
+	if !strings.Contains(output, "warn line") || !strings.Contains(output, "error line") {
+		t.Errorf("output = %q, want WARN and ERROR messages", output)
+	}
+
+	if err := logger.SetLevel("verbose"); err == nil {
+		t.Error("SetLevel(verbose) should fail")
+	}
+	buf.Reset()
+	if err := logger.SetLevel(""); err != nil {
+		t.Fatalf("SetLevel(\"\") error = %v", err)
+	}
+	logger.Debug("debug again")
+	if !strings.Contains(buf.String(), "debug again") {
+		t.Errorf("output = %q, want everything logged after SetLevel(\"\")", buf.String())
+	}
+}
+
 func TestLoggerClose(t *testing.T) {
 	tmpDir := t.TempDir()
 	logPath := filepath.Join(tmpDir, "test.log")
@@ -189,3 +241,52 @@ func TestLoggerThreadSafety(t *testing.T) {
 		t.Errorf("Expected 1000 log lines, got %d", len(lines))
 	}
 }
+
+func TestLoggerReopen(t *testing.T) {
+	tmpDir := t.TempDir()
+	logPath := filepath.Join(tmpDir, "test.log")
+	rotatedPath := logPath + ".1"
+
+	logger, err := NewFile(logPath)
+	if err != nil {
+		t.Fatalf("NewFile() error = %v", err)
+	}
+	defer logger.Close()
+	oldFile := logger.file
+
+	logger.Info("before rotation")
+	if err := os.Rename(logPath, rotatedPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := logger.Reopen(logPath); err != nil {
+		t.Fatalf("Reopen() error = %v", err)
+	}
+	logger.Info("after rotation")
+
+	if logger.file == oldFile {
+		t.Error("Reopen() kept the old file handle")
+	}
+	if _, err := oldFile.Write([]byte("x")); err == nil {
+		t.Error("Reopen() should close the file it replaced")
+	}
+
+	rotated, _ := os.ReadFile(rotatedPath)
+	current, _ := os.ReadFile(logPath)
+	if !strings.Contains(string(rotated), "before rotation") || strings.Contains(string(rotated), "after rotation") {
+		t.Errorf("rotated log = %q, want only the line before rotation", rotated)
+	}
+	if !strings.Contains(string(current), "after rotation") || strings.Contains(string(current), "before rotation") {
+		t.Errorf("new log = %q, want only the line after rotation", current)
+	}
+}
+
+func TestLoggerReopenKeepsNonFileWriter(t *testing.T) {
+	logger := New(os.Stderr)
+	if err := logger.Reopen(filepath.Join(t.TempDir(), "test.log")); err != nil {
+		t.Fatalf("Reopen() error = %v", err)
+	}
+	defer logger.Close()
+	if _, err := os.Stderr.Write(nil); err != nil {
+		t.Errorf("Reopen() closed a writer it did not open: %v", err)
+	}
+}
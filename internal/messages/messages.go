@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
+	"github.com/dlorenc/multiclaude/internal/fsutil"
 	"github.com/google/uuid"
 )
 
@@ -22,13 +24,14 @@ const (
 
 // Message represents a message between agents
 type Message struct {
-	ID        string     `json:"id"`
-	From      string     `json:"from"`
-	To        string     `json:"to"`
-	Timestamp time.Time  `json:"timestamp"`
-	Body      string     `json:"body"`
-	Status    Status     `json:"status"`
-	AckedAt   *time.Time `json:"acked_at,omitempty"`
+	ID          string     `json:"id"`
+	From        string     `json:"from"`
+	To          string     `json:"to"`
+	Timestamp   time.Time  `json:"timestamp"`
+	Body        string     `json:"body"`
+	Status      Status     `json:"status"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+	AckedAt     *time.Time `json:"acked_at,omitempty"`
 }
 
 // Manager handles message filesystem operations
@@ -103,8 +106,13 @@ func (m *Manager) UpdateStatus(repoName, agentName, messageID string, status Sta
 	}
 
 	msg.Status = status
-	if status == StatusAcked {
-		now := time.Now()
+	now := time.Now()
+	switch status {
+	case StatusPending:
+		msg.DeliveredAt = nil
+	case StatusDelivered:
+		msg.DeliveredAt = &now
+	case StatusAcked:
 		msg.AckedAt = &now
 	}
 
@@ -116,6 +124,69 @@ func (m *Manager) Ack(repoName, agentName, messageID string) error {
 	return m.UpdateStatus(repoName, agentName, messageID, StatusAcked)
 }
 
+// Receive returns an agent's pending messages, oldest first, and marks them
+// delivered. Delivered messages stay on disk until the recipient calls Ack,
+// so a recipient that crashes mid-processing doesn't lose them; Requeue makes
+// them available to Receive again.
+func (m *Manager) Receive(repoName, agentName string) ([]*Message, error) {
+	messages, err := m.List(repoName, agentName)
+	if err != nil {
+		return nil, err
+	}
+
+	var received []*Message
+	for _, msg := range messages {
+		if msg.Status != StatusPending {
+			continue
+		}
+		if err := m.UpdateStatus(repoName, agentName, msg.ID, StatusDelivered); err != nil {
+			return nil, err
+		}
+		msg.Status = StatusDelivered
+		received = append(received, msg)
+	}
+
+	sort.Slice(received, func(i, j int) bool {
+		return received[i].Timestamp.Before(received[j].Timestamp)
+	})
+
+	return received, nil
+}
+
+// Requeue returns delivered or read messages that have not been acknowledged
+// within olderThan to the pending state so they are delivered again.
+// Returns the number of messages requeued.
+func (m *Manager) Requeue(repoName, agentName string, olderThan time.Duration) (int, error) {
+	messages, err := m.List(repoName, agentName)
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	count := 0
+	for _, msg := range messages {
+		if msg.Status != StatusDelivered && msg.Status != StatusRead {
+			continue
+		}
+
+		// Messages delivered before DeliveredAt was recorded fall back to the send time
+		deliveredAt := msg.Timestamp
+		if msg.DeliveredAt != nil {
+			deliveredAt = *msg.DeliveredAt
+		}
+		if deliveredAt.After(cutoff) {
+			continue
+		}
+
+		if err := m.UpdateStatus(repoName, agentName, msg.ID, StatusPending); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
 // Delete removes a message file
 func (m *Manager) Delete(repoName, agentName, messageID string) error {
 	path := filepath.Join(m.agentDir(repoName, agentName), messageID+".json")
@@ -183,8 +254,10 @@ func (m *Manager) write(repoName, agentName string, msg *Message) error {
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
 
+	// Written atomically so a concurrent List never sees a partly written
+	// message. The temp file's extension keeps List from picking it up.
 	path := filepath.Join(m.agentDir(repoName, agentName), msg.ID+".json")
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	if err := fsutil.WriteFileAtomic(path, data, 0644); err != nil {
 		return fmt.Errorf("failed to write message file: %w", err)
 	}
 
@@ -202,6 +202,153 @@ func TestAckMessage(t *testing.T) {
 	}
 }
 
+func TestReceiveAndAck(t *testing.T) {
+	m := NewManager(t.TempDir())
+
+	first, err := m.Send("repo", "supervisor", "worker1", "first")
+	if err != nil {
+		t.Fatalf("Send() failed: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	second, err := m.Send("repo", "supervisor", "worker1", "second")
+	if err != nil {
+		t.Fatalf("Send() failed: %v", err)
+	}
+
+	received, err := m.Receive("repo", "worker1")
+	if err != nil {
+		t.Fatalf("Receive() failed: %v", err)
+	}
+	if len(received) != 2 || received[0].ID != first.ID || received[1].ID != second.ID {
+		t.Fatalf("Receive() returned %v, want [first second] in order", received)
+	}
+
+	// Received messages stay visible until acked
+	msg, err := m.Get("repo", "worker1", first.ID)
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	if msg.Status != StatusDelivered || msg.DeliveredAt == nil {
+		t.Errorf("received message status = %q, DeliveredAt = %v", msg.Status, msg.DeliveredAt)
+	}
+
+	// A second Receive doesn't hand out in-flight messages again
+	again, err := m.Receive("repo", "worker1")
+	if err != nil {
+		t.Fatalf("Receive() failed: %v", err)
+	}
+	if len(again) != 0 {
+		t.Errorf("Receive() returned %d in-flight messages, want 0", len(again))
+	}
+
+	if err := m.Ack("repo", "worker1", first.ID); err != nil {
+		t.Fatalf("Ack() failed: %v", err)
+	}
+
+	// Acked messages are never requeued; the unacked one is
+	count, err := m.Requeue("repo", "worker1", 0)
+	if err != nil {
+		t.Fatalf("Requeue() failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Requeue() = %d, want 1", count)
+	}
+	redelivered, err := m.Receive("repo", "worker1")
+	if err != nil {
+		t.Fatalf("Receive() failed: %v", err)
+	}
+	if len(redelivered) != 1 || redelivered[0].ID != second.ID {
+		t.Errorf("Receive() after requeue = %v, want [second]", redelivered)
+	}
+}
+
+func TestWriteIsAtomic(t *testing.T) {
+	root := t.TempDir()
+	m := NewManager(root)
+
+	msg, err := m.Send("repo", "supervisor", "worker1", "task")
+	if err != nil {
+		t.Fatalf("Send() failed: %v", err)
+	}
+	if err := m.Ack("repo", "worker1", msg.ID); err != nil {
+		t.Fatalf("Ack() failed: %v", err)
+	}
+
+	dir := filepath.Join(root, "repo", "worker1")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != msg.ID+".json" {
+		t.Errorf("message dir holds %v, want only %s.json", entries, msg.ID)
+	}
+
+	// A temp file left by an interrupted write is not a message
+	if err := os.WriteFile(filepath.Join(dir, ".msg-123.tmp"), []byte(`{"id": "partial`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	messages, err := m.List("repo", "worker1")
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	if len(messages) != 1 || messages[0].ID != msg.ID {
+		t.Errorf("List() = %v, want only %s", messages, msg.ID)
+	}
+}
+
+func TestRequeue(t *testing.T) {
+	m := NewManager(t.TempDir())
+
+	msg, err := m.Send("repo", "supervisor", "worker1", "task")
+	if err != nil {
+		t.Fatalf("Send() failed: %v", err)
+	}
+	if _, err := m.Receive("repo", "worker1"); err != nil {
+		t.Fatalf("Receive() failed: %v", err)
+	}
+
+	// Not yet expired
+	count, err := m.Requeue("repo", "worker1", time.Hour)
+	if err != nil {
+		t.Fatalf("Requeue() failed: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Requeue(1h) = %d, want 0", count)
+	}
+
+	// Read but unacked messages expire too
+	if err := m.UpdateStatus("repo", "worker1", msg.ID, StatusRead); err != nil {
+		t.Fatalf("UpdateStatus() failed: %v", err)
+	}
+	count, err = m.Requeue("repo", "worker1", 0)
+	if err != nil {
+		t.Fatalf("Requeue() failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Requeue(0) = %d, want 1", count)
+	}
+
+	requeued, err := m.Get("repo", "worker1", msg.ID)
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	if requeued.Status != StatusPending || requeued.DeliveredAt != nil {
+		t.Errorf("requeued message status = %q, DeliveredAt = %v, want pending and nil", requeued.Status, requeued.DeliveredAt)
+	}
+}
+
+func TestRequeueNoMessages(t *testing.T) {
+	m := NewManager(t.TempDir())
+
+	count, err := m.Requeue("repo", "nobody", 0)
+	if err != nil {
+		t.Fatalf("Requeue() failed: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Requeue() = %d, want 0", count)
+	}
+}
+
 func TestDeleteMessage(t *testing.T) {
 	tmpDir := t.TempDir()
 	m := NewManager(tmpDir)
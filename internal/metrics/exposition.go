@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the media type of the text Exposition produces
+const ContentType = "text/plain; version=0.0.4"
+
+// Labels are the label names and values of one sample
+type Labels map[string]string
+
+// Exposition builds a scrape in the Prometheus text exposition format.
+// Metric families are written in the order they are added; samples within
+// a family are sorted by their labels so output is stable.
+type Exposition struct {
+	b strings.Builder
+}
+
+// Sample is one value of a counter or gauge
+type Sample struct {
+	Labels Labels
+	Value  float64
+}
+
+// HistogramSample is one labelled histogram. Counts[i] is the number of
+// observations at or below Bounds[i], not yet cumulative; Count may exceed
+// their total by the observations above the last bound.
+type HistogramSample struct {
+	Labels Labels
+	Bounds []float64
+	Counts []uint64
+	Count  uint64
+	Sum    float64
+}
+
+// Counter adds a counter family
+func (e *Exposition) Counter(name, help string, samples ...Sample) {
+	e.family(name, help, "counter", samples)
+}
+
+// Gauge adds a gauge family
+func (e *Exposition) Gauge(name, help string, samples ...Sample) {
+	e.family(name, help, "gauge", samples)
+}
+
+// Histogram adds a histogram family
+func (e *Exposition) Histogram(name, help string, hists ...HistogramSample) {
+	e.header(name, help, "histogram")
+	sort.Slice(hists, func(i, j int) bool {
+		return formatLabels(hists[i].Labels) < formatLabels(hists[j].Labels)
+	})
+	for _, h := range hists {
+		var cumulative uint64
+		for i, bound := range h.Bounds {
+			cumulative += h.Counts[i]
+			e.line(name+"_bucket", withLabel(h.Labels, "le", formatValue(bound)), float64(cumulative))
+		}
+		e.line(name+"_bucket", withLabel(h.Labels, "le", "+Inf"), float64(h.Count))
+		e.line(name+"_sum", h.Labels, h.Sum)
+		e.line(name+"_count", h.Labels, float64(h.Count))
+	}
+}
+
+// String returns the exposition text
+func (e *Exposition) String() string {
+	return e.b.String()
+}
+
+func (e *Exposition) family(name, help, kind string, samples []Sample) {
+	e.header(name, help, kind)
+	sort.Slice(samples, func(i, j int) bool {
+		return formatLabels(samples[i].Labels) < formatLabels(samples[j].Labels)
+	})
+	for _, s := range samples {
+		e.line(name, s.Labels, s.Value)
+	}
+}
+
+func (e *Exposition) header(name, help, kind string) {
+	help = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+	fmt.Fprintf(&e.b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func (e *Exposition) line(name string, labels Labels, value float64) {
+	fmt.Fprintf(&e.b, "%s%s %s\n", name, formatLabels(labels), formatValue(value))
+}
+
+// withLabel returns a copy of labels with name set to value
+func withLabel(labels Labels, name, value string) Labels {
+	out := make(Labels, len(labels)+1)
+	for k, v := range labels {
+		out[k] = v
+	}
+	out[name] = value
+	return out
+}
+
+// formatLabels renders labels as {a="1",b="2"} in name order, or nothing
+// if there are none
+func formatLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + `="` + escape.Replace(labels[name]) + `"`
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
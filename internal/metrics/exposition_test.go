@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	var e Exposition
+	e.Counter("requests_total", "Requests.\nBy command.",
+		Sample{Labels: Labels{"command": "status"}, Value: 3},
+		Sample{Labels: Labels{"command": `say "hi"`}, Value: 1},
+	)
+	e.Gauge("up", "Whether it is up.", Sample{Value: 1})
+	e.Histogram("latency_seconds", "Latency.", HistogramSample{
+		Labels: Labels{"command": "status"},
+		Bounds: []float64{0.1, 1},
+		Counts: []uint64{2, 1},
+		Count:  4,
+		Sum:    7.5,
+	})
+	e.Gauge("inf", "Infinity.", Sample{Value: math.Inf(1)})
+
+	want := `# HELP requests_total Requests.\nBy command.
+# TYPE requests_total counter
+requests_total{command="say \"hi\""} 1
+requests_total{command="status"} 3
+# HELP up Whether it is up.
+# TYPE up gauge
+up 1
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{command="status",le="0.1"} 2
+latency_seconds_bucket{command="status",le="1"} 3
+latency_seconds_bucket{command="status",le="+Inf"} 4
+latency_seconds_sum{command="status"} 7.5
+latency_seconds_count{command="status"} 4
+# HELP inf Infinity.
+# TYPE inf gauge
+inf +Inf
+`
+	if got := e.String(); got != want {
+		t.Errorf("exposition mismatch:\n--- got ---\n%s\n--- want ---\n%s", got, want)
+	}
+}
@@ -0,0 +1,235 @@
+// Package metrics aggregates agent lifecycle events into totals for
+// dashboards: agents spawned, completed, and failed, and how long finished
+// agents ran.
+//
+// A Collector subscribes to the events bus. Counters are persisted to a JSON
+// file after every event so totals survive daemon restarts; a collector
+// created with an empty path keeps counters in memory only.
+//
+// Requests counts socket requests in memory. Both can be rendered in the
+// Prometheus text exposition format with an Exposition.
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dlorenc/multiclaude/internal/events"
+	"github.com/dlorenc/multiclaude/internal/fsutil"
+)
+
+// RuntimeBuckets are the upper bounds of the runtime histogram buckets.
+// Runtimes longer than the last bound are counted in an overflow bucket.
+var RuntimeBuckets = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	30 * time.Minute,
+	time.Hour,
+	2 * time.Hour,
+	4 * time.Hour,
+}
+
+// Bucket is one runtime histogram bucket. An UpperBound of zero marks the
+// overflow bucket.
+type Bucket struct {
+	UpperBound time.Duration `json:"upper_bound"`
+	Count      int           `json:"count"`
+}
+
+// Snapshot is a point-in-time copy of the aggregated metrics
+type Snapshot struct {
+	Spawned        int           `json:"spawned"`
+	Completed      int           `json:"completed"`
+	Failed         int           `json:"failed"`
+	AverageRuntime time.Duration `json:"average_runtime"`
+	Runtime        []Bucket      `json:"runtime_histogram"`
+}
+
+// counters is the persisted form of the collector's state
+type counters struct {
+	Spawned      int           `json:"spawned"`
+	Completed    int           `json:"completed"`
+	Failed       int           `json:"failed"`
+	RuntimeCount int           `json:"runtime_count"`
+	RuntimeTotal time.Duration `json:"runtime_total"`
+	Buckets      []int         `json:"runtime_buckets"` // len(RuntimeBuckets)+1, last is overflow
+}
+
+// Collector maintains aggregate counters from agent lifecycle events
+type Collector struct {
+	mu   sync.Mutex
+	path string
+	c    counters
+}
+
+// NewCollector creates a collector persisted at path, loading any counters
+// saved by a previous run. A missing file starts from zero.
+func NewCollector(path string) (*Collector, error) {
+	col := &Collector{
+		path: path,
+		c:    counters{Buckets: make([]int, len(RuntimeBuckets)+1)},
+	}
+	if path == "" {
+		return col, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return col, nil
+		}
+		return nil, fmt.Errorf("failed to read metrics: %w", err)
+	}
+
+	var saved counters
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("failed to parse metrics: %w", err)
+	}
+	// Keep saved buckets only if the bucket layout hasn't changed
+	if len(saved.Buckets) != len(RuntimeBuckets)+1 {
+		saved.Buckets = make([]int, len(RuntimeBuckets)+1)
+	}
+	col.c = saved
+	return col, nil
+}
+
+// Start subscribes the collector to bus and processes events in the
+// background until ctx is cancelled. The subscription is registered before
+// Start returns, so no event published afterwards is missed. The returned
+// channel is closed once the collector stops.
+func (col *Collector) Start(ctx context.Context, bus *events.Bus) <-chan struct{} {
+	ch, unsubscribe := bus.Subscribe(0)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		defer unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e := <-ch:
+				// Persistence failures are retried on the next event
+				_ = col.Observe(e)
+			}
+		}
+	}()
+
+	return done
+}
+
+// Observe records a single event and persists the updated counters
+func (col *Collector) Observe(e events.Event) error {
+	col.mu.Lock()
+	defer col.mu.Unlock()
+
+	switch e.Type {
+	case events.AgentSpawned:
+		col.c.Spawned++
+	case events.AgentCompleted:
+		col.c.Completed++
+		col.recordRuntimeUnlocked(e)
+	case events.AgentFailed:
+		col.c.Failed++
+		col.recordRuntimeUnlocked(e)
+	default:
+		return nil
+	}
+
+	return col.saveUnlocked()
+}
+
+// recordRuntimeUnlocked adds a finished agent's runtime to the histogram.
+// Caller must hold col.mu.
+func (col *Collector) recordRuntimeUnlocked(e events.Event) {
+	if e.StartedAt.IsZero() || e.Time.Before(e.StartedAt) {
+		return
+	}
+	runtime := e.Time.Sub(e.StartedAt)
+
+	col.c.RuntimeCount++
+	col.c.RuntimeTotal += runtime
+
+	bucket := len(RuntimeBuckets)
+	for i, bound := range RuntimeBuckets {
+		if runtime <= bound {
+			bucket = i
+			break
+		}
+	}
+	col.c.Buckets[bucket]++
+}
+
+// Snapshot returns a copy of the current aggregates
+func (col *Collector) Snapshot() Snapshot {
+	col.mu.Lock()
+	defer col.mu.Unlock()
+
+	snap := Snapshot{
+		Spawned:   col.c.Spawned,
+		Completed: col.c.Completed,
+		Failed:    col.c.Failed,
+		Runtime:   make([]Bucket, 0, len(col.c.Buckets)),
+	}
+	if col.c.RuntimeCount > 0 {
+		snap.AverageRuntime = col.c.RuntimeTotal / time.Duration(col.c.RuntimeCount)
+	}
+	for i, count := range col.c.Buckets {
+		var bound time.Duration
+		if i < len(RuntimeBuckets) {
+			bound = RuntimeBuckets[i]
+		}
+		snap.Runtime = append(snap.Runtime, Bucket{UpperBound: bound, Count: count})
+	}
+	return snap
+}
+
+// Expose adds the lifecycle totals and runtime histogram to e
+func (col *Collector) Expose(e *Exposition) {
+	col.mu.Lock()
+	defer col.mu.Unlock()
+
+	e.Counter("multiclaude_agents_spawned_total", "Agents spawned since metrics were first recorded.",
+		Sample{Value: float64(col.c.Spawned)})
+	e.Counter("multiclaude_agents_completed_total", "Agents that finished their task.",
+		Sample{Value: float64(col.c.Completed)})
+	e.Counter("multiclaude_agents_failed_total", "Agents that finished with a failure.",
+		Sample{Value: float64(col.c.Failed)})
+
+	bounds := make([]float64, len(RuntimeBuckets))
+	for i, bound := range RuntimeBuckets {
+		bounds[i] = bound.Seconds()
+	}
+	counts := make([]uint64, len(RuntimeBuckets))
+	for i := range RuntimeBuckets {
+		counts[i] = uint64(col.c.Buckets[i])
+	}
+	e.Histogram("multiclaude_agent_runtime_seconds", "How long finished agents ran.", HistogramSample{
+		Bounds: bounds,
+		Counts: counts,
+		Count:  uint64(col.c.RuntimeCount),
+		Sum:    col.c.RuntimeTotal.Seconds(),
+	})
+}
+
+// saveUnlocked writes the counters atomically. Caller must hold col.mu.
+func (col *Collector) saveUnlocked() error {
+	if col.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(col.c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal metrics: %w", err)
+	}
+
+	if err := fsutil.WriteFileAtomic(col.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dlorenc/multiclaude/internal/events"
+)
+
+func finished(t events.Type, runtime time.Duration) events.Event {
+	end := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	return events.Event{Type: t, Agent: "worker", StartedAt: end.Add(-runtime), Time: end}
+}
+
+func TestCollectorAggregates(t *testing.T) {
+	col, err := NewCollector("")
+	if err != nil {
+		t.Fatalf("NewCollector() failed: %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		col.Observe(events.Event{Type: events.AgentSpawned})
+	}
+	col.Observe(finished(events.AgentCompleted, 30*time.Second))
+	col.Observe(finished(events.AgentCompleted, 10*time.Minute))
+	col.Observe(finished(events.AgentFailed, 5*time.Hour))
+
+	snap := col.Snapshot()
+	if snap.Spawned != 4 || snap.Completed != 2 || snap.Failed != 1 {
+		t.Errorf("counts = %d/%d/%d, want 4/2/1", snap.Spawned, snap.Completed, snap.Failed)
+	}
+
+	wantAvg := (30*time.Second + 10*time.Minute + 5*time.Hour) / 3
+	if snap.AverageRuntime != wantAvg {
+		t.Errorf("AverageRuntime = %v, want %v", snap.AverageRuntime, wantAvg)
+	}
+
+	if len(snap.Runtime) != len(RuntimeBuckets)+1 {
+		t.Fatalf("got %d buckets, want %d", len(snap.Runtime), len(RuntimeBuckets)+1)
+	}
+	counts := map[time.Duration]int{}
+	for _, b := range snap.Runtime {
+		counts[b.UpperBound] = b.Count
+	}
+	if counts[time.Minute] != 1 || counts[15*time.Minute] != 1 || counts[0] != 1 {
+		t.Errorf("histogram = %+v, want one each in 1m, 15m, and overflow", snap.Runtime)
+	}
+}
+
+func TestCollectorPersistsAcrossRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+
+	col, err := NewCollector(path)
+	if err != nil {
+		t.Fatalf("NewCollector() failed: %v", err)
+	}
+	col.Observe(events.Event{Type: events.AgentSpawned})
+	col.Observe(finished(events.AgentCompleted, 2*time.Minute))
+
+	reloaded, err := NewCollector(path)
+	if err != nil {
+		t.Fatalf("NewCollector() reload failed: %v", err)
+	}
+	snap := reloaded.Snapshot()
+	if snap.Spawned != 1 || snap.Completed != 1 || snap.AverageRuntime != 2*time.Minute {
+		t.Errorf("reloaded snapshot = %+v, want saved counters", snap)
+	}
+}
+
+func TestCollectorStart(t *testing.T) {
+	col, _ := NewCollector("")
+	bus := events.NewBus()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := col.Start(ctx, bus)
+
+	bus.Publish(events.Event{Type: events.AgentSpawned})
+	bus.Publish(finished(events.AgentFailed, time.Minute))
+
+	deadline := time.Now().Add(time.Second)
+	for col.Snapshot().Failed == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if snap := col.Snapshot(); snap.Spawned != 1 || snap.Failed != 1 {
+		t.Fatalf("snapshot = %+v, want events from the bus", snap)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("collector did not stop after cancel")
+	}
+}
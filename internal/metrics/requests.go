@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// LatencyBuckets are the upper bounds, in seconds, of the request latency
+// histogram
+var LatencyBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30}
+
+// maxCommands bounds how many distinct commands are tracked, so clients
+// sending made-up commands cannot grow the metrics without limit. Later
+// commands are counted as OtherCommand.
+const maxCommands = 100
+
+// OtherCommand labels requests for commands beyond the first maxCommands
+const OtherCommand = "other"
+
+// latency is one command's latency histogram
+type latency struct {
+	counts []uint64 // per LatencyBuckets bound, not cumulative
+	count  uint64
+	sum    time.Duration
+}
+
+// Requests counts socket requests by command, failures by error code, and
+// each command's latency. Counts are kept in memory and start from zero
+// when the daemon does.
+type Requests struct {
+	mu       sync.Mutex
+	handled  map[string]uint64
+	failures map[string]uint64
+	latency  map[string]*latency
+}
+
+// NewRequests creates an empty request recorder
+func NewRequests() *Requests {
+	return &Requests{
+		handled:  make(map[string]uint64),
+		failures: make(map[string]uint64),
+		latency:  make(map[string]*latency),
+	}
+}
+
+// Observe records a request for command that took elapsed. code is the
+// failure's error code, and is ignored if the request succeeded.
+func (r *Requests) Observe(command string, success bool, code string, elapsed time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, known := r.handled[command]; !known && len(r.handled) >= maxCommands {
+		command = OtherCommand
+	}
+	r.handled[command]++
+	if !success {
+		r.failures[code]++
+	}
+
+	l := r.latency[command]
+	if l == nil {
+		l = &latency{counts: make([]uint64, len(LatencyBuckets))}
+		r.latency[command] = l
+	}
+	l.count++
+	l.sum += elapsed
+	seconds := elapsed.Seconds()
+	for i, bound := range LatencyBuckets {
+		if seconds <= bound {
+			l.counts[i]++
+			break
+		}
+	}
+}
+
+// Expose adds the request metrics to e
+func (r *Requests) Expose(e *Exposition) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	handled := make([]Sample, 0, len(r.handled))
+	for command, n := range r.handled {
+		handled = append(handled, Sample{Labels: Labels{"command": command}, Value: float64(n)})
+	}
+	e.Counter("multiclaude_requests_total", "Socket requests handled, by command.", handled...)
+
+	failures := make([]Sample, 0, len(r.failures))
+	for code, n := range r.failures {
+		failures = append(failures, Sample{Labels: Labels{"code": code}, Value: float64(n)})
+	}
+	e.Counter("multiclaude_request_errors_total", "Failed socket requests, by error code.", failures...)
+
+	hists := make([]HistogramSample, 0, len(r.latency))
+	for command, l := range r.latency {
+		hists = append(hists, HistogramSample{
+			Labels: Labels{"command": command},
+			Bounds: LatencyBuckets,
+			Counts: append([]uint64(nil), l.counts...),
+			Count:  l.count,
+			Sum:    l.sum.Seconds(),
+		})
+	}
+	e.Histogram("multiclaude_request_duration_seconds", "Time to handle a socket request, by command.", hists...)
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestsExpose(t *testing.T) {
+	r := NewRequests()
+	r.Observe("status", true, "", 2*time.Millisecond)
+	r.Observe("status", true, "", 20*time.Millisecond)
+	r.Observe("add_agent", false, "conflict", time.Minute)
+
+	var e Exposition
+	r.Expose(&e)
+	out := e.String()
+	for _, want := range []string{
+		`multiclaude_requests_total{command="status"} 2`,
+		`multiclaude_requests_total{command="add_agent"} 1`,
+		`multiclaude_request_errors_total{code="conflict"} 1`,
+		`multiclaude_request_duration_seconds_bucket{command="status",le="0.001"} 0`,
+		`multiclaude_request_duration_seconds_bucket{command="status",le="0.005"} 1`,
+		`multiclaude_request_duration_seconds_bucket{command="status",le="0.05"} 2`,
+		`multiclaude_request_duration_seconds_bucket{command="add_agent",le="30"} 0`,
+		`multiclaude_request_duration_seconds_bucket{command="add_agent",le="+Inf"} 1`,
+		`multiclaude_request_duration_seconds_count{command="status"} 2`,
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("exposition is missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRequestsBoundsCommands(t *testing.T) {
+	r := NewRequests()
+	for i := 0; i < maxCommands+5; i++ {
+		r.Observe(fmt.Sprintf("cmd%d", i), false, "bad_request", 0)
+	}
+	r.Observe("cmd0", true, "", 0)
+
+	if len(r.handled) != maxCommands+1 {
+		t.Errorf("tracking %d commands, want %d", len(r.handled), maxCommands+1)
+	}
+	if r.handled[OtherCommand] != 5 {
+		t.Errorf("%s count = %d, want 5", OtherCommand, r.handled[OtherCommand])
+	}
+	if r.handled["cmd0"] != 2 {
+		t.Errorf("known command count = %d, want 2", r.handled["cmd0"])
+	}
+}
@@ -0,0 +1,107 @@
+package names
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidAgentName is returned for names that are unsafe to use as a tmux
+// window name or a path component
+var ErrInvalidAgentName = errors.New("invalid agent name")
+
+// ErrInvalidRepoName is returned for repository names that are unsafe to use
+// as a path component
+var ErrInvalidRepoName = errors.New("invalid repository name")
+
+// isNameChar reports whether r may appear in an agent name
+func isNameChar(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') ||
+		r == '-' || r == '_' || r == '.'
+}
+
+// ValidateAgentName checks that name uses only ASCII letters, digits, '-',
+// '_' and '.', and does not start with '-' or '.'. Names become git branch
+// names too, so "..", a trailing '.' and a ".lock" suffix are rejected.
+func ValidateAgentName(name string) error {
+	return validateName(ErrInvalidAgentName, name)
+}
+
+// ValidateRepoName applies the rules of ValidateAgentName to a repository
+// name, which names directories under repos/, wts/, messages/ and output/
+func ValidateRepoName(name string) error {
+	return validateName(ErrInvalidRepoName, name)
+}
+
+// validateName checks name against the shared naming rules, reporting
+// failures as invalid
+func validateName(invalid error, name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: name cannot be empty", invalid)
+	}
+	if strings.HasPrefix(name, "-") || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("%w %q: cannot start with '-' or '.'", invalid, name)
+	}
+	for _, r := range name {
+		if !isNameChar(r) {
+			return fmt.Errorf("%w %q: character %q is not allowed (use letters, digits, '-', '_' or '.')", invalid, name, r)
+		}
+	}
+	if strings.Contains(name, "..") {
+		return fmt.Errorf("%w %q: cannot contain '..'", invalid, name)
+	}
+	if strings.HasSuffix(name, ".") || strings.HasSuffix(name, ".lock") {
+		return fmt.Errorf("%w %q: cannot end with '.' or '.lock'", invalid, name)
+	}
+	return nil
+}
+
+// tmuxSanitizer replaces problematic characters with hyphens for tmux session names.
+// tmux has issues with dots, colons, spaces, and forward slashes in session names.
+var tmuxSanitizer = strings.NewReplacer(
+	".", "-",
+	":", "-",
+	" ", "-",
+	"/", "-",
+)
+
+// TmuxSessionName returns the tmux session name for a repository. Control
+// characters are dropped and characters tmux mishandles become '-'.
+func TmuxSessionName(repoName string) string {
+	sanitized := strings.Map(func(r rune) rune {
+		if r < 32 {
+			return -1 // drop the character
+		}
+		return r
+	}, repoName)
+	return "mc-" + tmuxSanitizer.Replace(sanitized)
+}
+
+// SanitizeName maps a human label such as "Fix login bug" to a name that
+// passes ValidateAgentName ("fix-login-bug"). Runs of disallowed characters
+// become a single '-'. It returns "" if the label has no usable characters.
+func SanitizeName(label string) string {
+	var b strings.Builder
+	pendingDash := false
+	for _, r := range strings.ToLower(label) {
+		if !isNameChar(r) || r == '-' {
+			pendingDash = true
+			continue
+		}
+		if pendingDash && b.Len() > 0 {
+			b.WriteByte('-')
+		} else if r == '.' && strings.HasSuffix(b.String(), ".") {
+			continue // ".." is not allowed
+		}
+		pendingDash = false
+		b.WriteRune(r)
+	}
+	name := strings.TrimLeft(b.String(), "-._")
+	for {
+		trimmed := strings.TrimRight(strings.TrimSuffix(name, ".lock"), "-.")
+		if trimmed == name {
+			return name
+		}
+		name = trimmed
+	}
+}
@@ -0,0 +1,66 @@
+package names
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateAgentName(t *testing.T) {
+	valid := []string{"supervisor", "merge-queue", "happy-platypus", "review_42", "v1.2", "A1"}
+	for _, name := range valid {
+		if err := ValidateAgentName(name); err != nil {
+			t.Errorf("ValidateAgentName(%q) = %v, want nil", name, err)
+		}
+	}
+
+	invalid := []string{"", "has space", "tab\there", "a/b", `a\b`, "..", ".hidden", "-flag", "semi;colon", "$(rm)", "back`tick", "quote'd", "a:b", "ünïcode", "a..b", "trailing.", "branch.lock"}
+	for _, name := range invalid {
+		err := ValidateAgentName(name)
+		if !errors.Is(err, ErrInvalidAgentName) {
+			t.Errorf("ValidateAgentName(%q) = %v, want ErrInvalidAgentName", name, err)
+		}
+	}
+}
+
+func TestValidateRepoName(t *testing.T) {
+	if err := ValidateRepoName("demos.expanso.io"); err != nil {
+		t.Errorf("ValidateRepoName() = %v, want nil", err)
+	}
+	for _, name := range []string{"", "../up", ".hidden", "a/b", "a b", "repo.lock"} {
+		if err := ValidateRepoName(name); !errors.Is(err, ErrInvalidRepoName) {
+			t.Errorf("ValidateRepoName(%q) = %v, want ErrInvalidRepoName", name, err)
+		}
+	}
+}
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct {
+		label string
+		want  string
+	}{
+		{"Fix login bug", "fix-login-bug"},
+		{"  leading and trailing  ", "leading-and-trailing"},
+		{"feature/auth: part 2", "feature-auth-part-2"},
+		{"already-safe_name.v2", "already-safe_name.v2"},
+		{"..hidden", "hidden"},
+		{"--flag", "flag"},
+		{"a -- b", "a-b"},
+		{"$(rm -rf /)", "rm-rf"},
+		{"!!!", ""},
+		{"v1..2", "v1.2"},
+		{"ends with dot.", "ends-with-dot"},
+		{"index.lock", "index"},
+		{"a.lock.lock", "a"},
+	}
+	for _, tt := range tests {
+		got := SanitizeName(tt.label)
+		if got != tt.want {
+			t.Errorf("SanitizeName(%q) = %q, want %q", tt.label, got, tt.want)
+		}
+		if got != "" {
+			if err := ValidateAgentName(got); err != nil {
+				t.Errorf("SanitizeName(%q) = %q, which fails validation: %v", tt.label, got, err)
+			}
+		}
+	}
+}
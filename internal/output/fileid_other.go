@@ -0,0 +1,10 @@
+//go:build !unix
+
+package output
+
+import "os"
+
+// fileID is unavailable without inodes; callers fall back to comparing sizes
+func fileID(info os.FileInfo) (dev, ino uint64) {
+	return 0, 0
+}
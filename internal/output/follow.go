@@ -0,0 +1,121 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// followPollInterval is how often Follow checks a capture file for new output
+const followPollInterval = 100 * time.Millisecond
+
+// Follow streams an agent's capture file with tail -f semantics. If n > 0,
+// the last n lines are emitted first; after that each new line is emitted as
+// it is written. If the agent has no capture file yet, Follow waits for one
+// to appear. It returns when ctx is cancelled or emit returns an error.
+func (m *Manager) Follow(ctx context.Context, repoName, agentName string, n int, emit func(line string) error) error {
+	for {
+		path, err := m.FindLogPath(repoName, agentName)
+		if err == nil {
+			return FollowFile(ctx, path, n, emit)
+		}
+		if err := sleepContext(ctx, followPollInterval); err != nil {
+			return err
+		}
+	}
+}
+
+// FollowFile streams the file at path with tail -f semantics. If n > 0, the
+// last n lines are emitted first. A truncated file is re-read from the start
+// and a replaced (rotated) file is reopened. Incomplete trailing lines are
+// held back until their newline is written.
+func FollowFile(ctx context.Context, path string, n int, emit func(line string) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open output file: %w", err)
+	}
+	defer func() { f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat output file: %w", err)
+	}
+	offset := info.Size()
+
+	if n > 0 {
+		lines, err := tailAt(f, offset, n)
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			if err := emit(line); err != nil {
+				return err
+			}
+		}
+	}
+
+	var pending []byte
+	buf := make([]byte, tailChunkSize)
+	for {
+		nr, err := f.ReadAt(buf, offset)
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read output file: %w", err)
+		}
+		if nr > 0 {
+			offset += int64(nr)
+			pending = append(pending, buf[:nr]...)
+			for {
+				i := bytes.IndexByte(pending, '\n')
+				if i < 0 {
+					break
+				}
+				if err := emit(string(pending[:i])); err != nil {
+					return err
+				}
+				pending = pending[i+1:]
+			}
+			if nr == len(buf) {
+				continue
+			}
+		}
+
+		if err := sleepContext(ctx, followPollInterval); err != nil {
+			return err
+		}
+
+		current, err := os.Stat(path)
+		if err != nil {
+			// File was removed; keep waiting for it to be recreated
+			continue
+		}
+		if !os.SameFile(info, current) {
+			reopened, err := os.Open(path)
+			if err != nil {
+				continue
+			}
+			f.Close()
+			f = reopened
+			info = current
+			offset = 0
+			pending = nil
+		} else if current.Size() < offset {
+			offset = 0
+			pending = nil
+		}
+	}
+}
+
+// sleepContext waits for d or until ctx is cancelled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,135 @@
+package output
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// collectLines runs fn in the background and returns a channel of emitted lines
+func collectLines(ctx context.Context, fn func(ctx context.Context, emit func(string) error) error) (<-chan string, <-chan error) {
+	lines := make(chan string, 100)
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(ctx, func(line string) error {
+			lines <- line
+			return nil
+		})
+	}()
+	return lines, done
+}
+
+func expectLines(t *testing.T, lines <-chan string, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		select {
+		case got := <-lines:
+			if got != w {
+				t.Fatalf("got line %q, want %q", got, w)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("timed out waiting for line %q", w)
+		}
+	}
+}
+
+func appendToFile(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	}
+}
+
+func TestFollowFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.log")
+	writeLines(t, path, 5)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lines, done := collectLines(ctx, func(ctx context.Context, emit func(string) error) error {
+		return FollowFile(ctx, path, 2, emit)
+	})
+
+	expectLines(t, lines, "line 4", "line 5")
+
+	appendToFile(t, path, "new 1\nnew 2\npart")
+	expectLines(t, lines, "new 1", "new 2")
+
+	// The incomplete line is only emitted once its newline arrives
+	appendToFile(t, path, "ial\n")
+	expectLines(t, lines, "partial")
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("FollowFile() error = %v, want context.Canceled", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("FollowFile() did not stop after cancel")
+	}
+}
+
+func TestFollowFileTruncated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.log")
+	writeLines(t, path, 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lines, _ := collectLines(ctx, func(ctx context.Context, emit func(string) error) error {
+		return FollowFile(ctx, path, 0, emit)
+	})
+
+	// Let the follower reach end of file before truncating
+	time.Sleep(2 * followPollInterval)
+	if err := os.WriteFile(path, []byte("fresh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, lines, "fresh")
+}
+
+func TestFollowWaitsForCaptureFile(t *testing.T) {
+	m := NewManager(t.TempDir())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lines, _ := collectLines(ctx, func(ctx context.Context, emit func(string) error) error {
+		return m.Follow(ctx, "repo", "worker1", 10, emit)
+	})
+
+	time.Sleep(2 * followPollInterval)
+	path := m.LogPath("repo", "worker1", true)
+	writeLines(t, path, 2)
+
+	expectLines(t, lines, "line 1", "line 2")
+
+	for i := 3; i <= 5; i++ {
+		appendToFile(t, path, fmt.Sprintf("line %d\n", i))
+	}
+	expectLines(t, lines, "line 3", "line 4", "line 5")
+}
+
+func TestFollowStopsOnEmitError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.log")
+	writeLines(t, path, 3)
+
+	stop := errors.New("client gone")
+	err := FollowFile(context.Background(), path, 3, func(string) error {
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("FollowFile() error = %v, want %v", err, stop)
+	}
+}
@@ -0,0 +1,198 @@
+// Package output manages per-agent output capture files under OutputDir.
+//
+// Agent panes are piped with tmux pipe-pane into the hidden multiclaude
+// command CaptureCommand, which appends them to log files through a
+// RotatingWriter so a runaway agent can't fill the disk. System agents log to
+// <OutputDir>/<repo>/<agent>.log and workers/review agents to
+// <OutputDir>/<repo>/workers/<agent>.log, matching config.Paths.AgentLogFile.
+//
+// Full session transcripts are copied from Claude's session files to
+// <OutputDir>/<repo>/<agent>.transcript.jsonl.
+package output
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tailChunkSize is the block size used when scanning a file backwards for lines
+const tailChunkSize = 64 * 1024
+
+// PaneCapturer pipes a tmux pane's output into a shell command.
+// *tmux.Client satisfies this interface.
+type PaneCapturer interface {
+	PipePane(ctx context.Context, session, windowName, command string) error
+}
+
+// CaptureCommand is the hidden multiclaude command that copies its standard
+// input to the capture file named by its argument through a RotatingWriter
+const CaptureCommand = "_capture"
+
+// Manager handles agent output capture files
+type Manager struct {
+	outputRoot string
+
+	// CaptureExecutable is the multiclaude binary that Capture pipes panes
+	// into to serve CaptureCommand. When empty, panes are appended to their
+	// log files with a plain, unrotated cat.
+	CaptureExecutable string
+}
+
+// NewManager creates a new output manager rooted at outputRoot
+func NewManager(outputRoot string) *Manager {
+	return &Manager{outputRoot: outputRoot}
+}
+
+// LogPath returns the capture file path for an agent.
+// Worker and review agents are stored in the repo's workers/ subdirectory.
+func (m *Manager) LogPath(repoName, agentName string, isWorker bool) string {
+	if isWorker {
+		return filepath.Join(m.outputRoot, repoName, "workers", agentName+".log")
+	}
+	return filepath.Join(m.outputRoot, repoName, agentName+".log")
+}
+
+// FindLogPath returns the existing capture file for an agent, checking the
+// worker location first and then the system agent location.
+// Returns an error wrapping os.ErrNotExist if the agent has no capture file yet.
+func (m *Manager) FindLogPath(repoName, agentName string) (string, error) {
+	for _, isWorker := range []bool{true, false} {
+		path := m.LogPath(repoName, agentName, isWorker)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no output captured for agent %s in repo %s: %w", agentName, repoName, os.ErrNotExist)
+}
+
+// Capture starts capturing a tmux window's output to the agent's log file.
+// Output is appended, so captures persist across agent restarts, and the
+// file is rotated as it grows.
+// Returns the path of the capture file.
+func (m *Manager) Capture(ctx context.Context, tmux PaneCapturer, session, window, repoName, agentName string, isWorker bool) (string, error) {
+	logFile := m.LogPath(repoName, agentName, isWorker)
+
+	if err := os.MkdirAll(filepath.Dir(logFile), 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	if err := tmux.PipePane(ctx, session, window, pipeCommand(m.CaptureExecutable, logFile)); err != nil {
+		return "", fmt.Errorf("failed to start output capture: %w", err)
+	}
+
+	return logFile, nil
+}
+
+// CopyRotating appends everything read from r to the file at path through
+// a RotatingWriter. It is what CaptureCommand runs.
+func CopyRotating(path string, r io.Reader, maxSize int64, maxBackups int) error {
+	w, err := OpenRotating(path, maxSize, maxBackups)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// pipeCommand returns the shell command pipe-pane runs to append to logFile:
+// exe's CaptureCommand, or a plain unrotated cat when exe is ""
+func pipeCommand(exe, logFile string) string {
+	if exe == "" {
+		return "cat >> " + shellQuote(logFile)
+	}
+	return shellQuote(exe) + " " + CaptureCommand + " " + shellQuote(logFile)
+}
+
+// shellQuote quotes s as a single POSIX shell word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Tail returns the last n lines of an agent's capture file, oldest first.
+// If n <= 0, all lines are returned.
+func (m *Manager) Tail(repoName, agentName string, n int) ([]string, error) {
+	path, err := m.FindLogPath(repoName, agentName)
+	if err != nil {
+		return nil, err
+	}
+	return TailFile(path, n)
+}
+
+// TailFile returns the last n lines of the file at path, oldest first.
+// The file is read backwards in fixed-size chunks so large captures are not
+// loaded into memory. If n <= 0, all lines are returned.
+func TailFile(path string, n int) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open output file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat output file: %w", err)
+	}
+
+	return tailAt(f, info.Size(), n)
+}
+
+// tailAt returns the last n lines of the first size bytes of f
+func tailAt(f *os.File, size int64, n int) ([]string, error) {
+	if size == 0 {
+		return []string{}, nil
+	}
+
+	if n <= 0 {
+		data, err := io.ReadAll(io.NewSectionReader(f, 0, size))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read output file: %w", err)
+		}
+		return splitLines(data), nil
+	}
+
+	// Ignore a single trailing newline so it doesn't count as an empty line
+	end := size
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, size-1); err == nil && last[0] == '\n' {
+		end--
+	}
+
+	// Walk backwards until we've seen n newlines or hit the start of the file
+	var buf []byte
+	offset := end
+	for offset > 0 && bytes.Count(buf, []byte{'\n'}) < n {
+		chunk := int64(tailChunkSize)
+		if offset < chunk {
+			chunk = offset
+		}
+		offset -= chunk
+
+		block := make([]byte, chunk)
+		if _, err := f.ReadAt(block, offset); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read output file: %w", err)
+		}
+		buf = append(block, buf...)
+	}
+
+	lines := splitLines(buf)
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, nil
+}
+
+// splitLines splits data into lines, dropping a trailing empty line
+func splitLines(data []byte) []string {
+	text := strings.TrimSuffix(string(data), "\n")
+	if text == "" {
+		return []string{}
+	}
+	return strings.Split(text, "\n")
+}
@@ -0,0 +1,255 @@
+package output
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testCaptureMaxSize is the rotation threshold the test binary uses when it
+// serves CaptureCommand, small enough for a test to cross
+const testCaptureMaxSize = 64
+
+// TestMain lets the test binary stand in for multiclaude as a capture
+// executable: run with CaptureCommand and a file, it copies stdin there
+func TestMain(m *testing.M) {
+	if len(os.Args) == 3 && os.Args[1] == CaptureCommand {
+		if err := CopyRotating(os.Args[2], os.Stdin, testCaptureMaxSize, DefaultMaxBackups); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// fakeCapturer records pipe-pane calls instead of invoking tmux
+type fakeCapturer struct {
+	session string
+	window  string
+	command string
+	err     error
+}
+
+func (f *fakeCapturer) PipePane(ctx context.Context, session, windowName, command string) error {
+	f.session = session
+	f.window = windowName
+	f.command = command
+	return f.err
+}
+
+func writeLines(t *testing.T, path string, count int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	var sb strings.Builder
+	for i := 1; i <= count; i++ {
+		fmt.Fprintf(&sb, "line %d\n", i)
+	}
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+}
+
+func TestLogPath(t *testing.T) {
+	m := NewManager("/out")
+
+	if got := m.LogPath("repo", "supervisor", false); got != "/out/repo/supervisor.log" {
+		t.Errorf("LogPath(system) = %q", got)
+	}
+	if got := m.LogPath("repo", "worker1", true); got != "/out/repo/workers/worker1.log" {
+		t.Errorf("LogPath(worker) = %q", got)
+	}
+}
+
+func TestCapture(t *testing.T) {
+	tmpDir := t.TempDir()
+	m := NewManager(tmpDir)
+	fake := &fakeCapturer{}
+
+	path, err := m.Capture(context.Background(), fake, "mc-repo", "worker1", "repo", "worker1", true)
+	if err != nil {
+		t.Fatalf("Capture() failed: %v", err)
+	}
+
+	if path != m.LogPath("repo", "worker1", true) {
+		t.Errorf("Capture() path = %q, want worker log path", path)
+	}
+	if fake.session != "mc-repo" || fake.window != "worker1" || !strings.Contains(fake.command, path) {
+		t.Errorf("PipePane called with %q %q %q", fake.session, fake.window, fake.command)
+	}
+	if _, err := os.Stat(filepath.Dir(path)); err != nil {
+		t.Errorf("Capture() did not create output directory: %v", err)
+	}
+}
+
+func TestPipeCommand(t *testing.T) {
+	if got, want := pipeCommand("/usr/local/bin/multiclaude", "/out/it's.log"), `'/usr/local/bin/multiclaude' _capture '/out/it'\''s.log'`; got != want {
+		t.Errorf("pipeCommand() = %q, want %q", got, want)
+	}
+	if got, want := pipeCommand("", "/out/a.log"), "cat >> '/out/a.log'"; got != want {
+		t.Errorf("pipeCommand() without executable = %q, want %q", got, want)
+	}
+}
+
+func TestCaptureRotatesThroughExecutable(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager(t.TempDir())
+	m.CaptureExecutable = exe
+	fake := &fakeCapturer{}
+
+	path, err := m.Capture(context.Background(), fake, "mc-repo", "worker1", "repo", "worker1", true)
+	if err != nil {
+		t.Fatalf("Capture() failed: %v", err)
+	}
+
+	// Output from an earlier run of the agent is already near the limit
+	earlier := strings.Repeat("e", testCaptureMaxSize-4)
+	if err := os.WriteFile(path, []byte(earlier), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Run the command pipe-pane was given, as tmux would
+	const pane = "pane output line\n"
+	cmd := exec.Command("sh", "-c", fake.command)
+	cmd.Stdin = strings.NewReader(pane)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("pipe command %q failed: %v\n%s", fake.command, err, out)
+	}
+
+	if data, err := os.ReadFile(path); err != nil || string(data) != pane {
+		t.Errorf("capture file = %q, %v, want only the new output %q", data, err, pane)
+	}
+	copies, err := RotatedCopies(path)
+	if err != nil {
+		t.Fatalf("RotatedCopies() failed: %v", err)
+	}
+	if len(copies) != 1 {
+		t.Fatalf("rotated copies = %v, want the earlier output rotated aside", copies)
+	}
+	if data, err := os.ReadFile(copies[0]); err != nil || string(data) != earlier {
+		t.Errorf("rotated copy = %q, %v, want %q", data, err, earlier)
+	}
+}
+
+func TestCaptureError(t *testing.T) {
+	m := NewManager(t.TempDir())
+	fake := &fakeCapturer{err: errors.New("no server running")}
+
+	if _, err := m.Capture(context.Background(), fake, "s", "w", "repo", "agent", false); err == nil {
+		t.Error("Capture() should fail when pipe-pane fails")
+	}
+}
+
+func TestTail(t *testing.T) {
+	tmpDir := t.TempDir()
+	m := NewManager(tmpDir)
+	writeLines(t, m.LogPath("repo", "supervisor", false), 10)
+
+	tests := []struct {
+		name  string
+		n     int
+		first string
+		count int
+	}{
+		{"fewer than available", 3, "line 8", 3},
+		{"exactly available", 10, "line 1", 10},
+		{"more than available", 50, "line 1", 10},
+		{"all lines", 0, "line 1", 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := m.Tail("repo", "supervisor", tt.n)
+			if err != nil {
+				t.Fatalf("Tail() failed: %v", err)
+			}
+			if len(lines) != tt.count {
+				t.Fatalf("Tail() returned %d lines, want %d", len(lines), tt.count)
+			}
+			if lines[0] != tt.first {
+				t.Errorf("first line = %q, want %q", lines[0], tt.first)
+			}
+			if lines[len(lines)-1] != "line 10" {
+				t.Errorf("last line = %q, want %q", lines[len(lines)-1], "line 10")
+			}
+		})
+	}
+}
+
+func TestTailFindsWorkerLog(t *testing.T) {
+	tmpDir := t.TempDir()
+	m := NewManager(tmpDir)
+	writeLines(t, m.LogPath("repo", "worker1", true), 2)
+
+	lines, err := m.Tail("repo", "worker1", 1)
+	if err != nil {
+		t.Fatalf("Tail() failed: %v", err)
+	}
+	if len(lines) != 1 || lines[0] != "line 2" {
+		t.Errorf("Tail() = %v, want [line 2]", lines)
+	}
+}
+
+func TestTailMissingFile(t *testing.T) {
+	m := NewManager(t.TempDir())
+
+	_, err := m.Tail("repo", "nobody", 5)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Tail() error = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestTailFileLarge(t *testing.T) {
+	// Spans several read chunks to exercise the backwards scan
+	path := filepath.Join(t.TempDir(), "big.log")
+	writeLines(t, path, 20000)
+
+	lines, err := TailFile(path, 5)
+	if err != nil {
+		t.Fatalf("TailFile() failed: %v", err)
+	}
+	want := []string{"line 19996", "line 19997", "line 19998", "line 19999", "line 20000"}
+	if strings.Join(lines, ",") != strings.Join(want, ",") {
+		t.Errorf("TailFile() = %v, want %v", lines, want)
+	}
+}
+
+func TestTailFileNoTrailingNewline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "partial.log")
+	if err := os.WriteFile(path, []byte("a\nb\nc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err := TailFile(path, 2)
+	if err != nil {
+		t.Fatalf("TailFile() failed: %v", err)
+	}
+	if strings.Join(lines, ",") != "b,c" {
+		t.Errorf("TailFile() = %v, want [b c]", lines)
+	}
+}
+
+func TestTailFileEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.log")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err := TailFile(path, 5)
+	if err != nil {
+		t.Fatalf("TailFile() failed: %v", err)
+	}
+	if len(lines) != 0 {
+		t.Errorf("TailFile() = %v, want empty", lines)
+	}
+}
@@ -0,0 +1,173 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TranscriptPath returns the path of an agent's captured session transcript
+func (m *Manager) TranscriptPath(repoName, agentName string) string {
+	return filepath.Join(m.outputRoot, repoName, agentName+".transcript.jsonl")
+}
+
+// SessionFile returns where Claude records a session's conversation:
+// <home>/.claude/projects/<encoded-workdir>/<session-id>.jsonl, where the
+// working directory is encoded by replacing "/" with "-".
+func SessionFile(home, workDir, sessionID string) string {
+	encodedPath := strings.ReplaceAll(workDir, "/", "-")
+	return filepath.Join(home, ".claude", "projects", encodedPath, sessionID+".jsonl")
+}
+
+// transcriptPos records how far into which session file a transcript has
+// been copied, so a session file that is replaced or truncated is noticed
+type transcriptPos struct {
+	Dev    uint64 `json:"dev,omitempty"`
+	Inode  uint64 `json:"inode,omitempty"`
+	Offset int64  `json:"offset"`
+}
+
+// transcriptPosPath returns where the copy position of a transcript is kept
+func (m *Manager) transcriptPosPath(repoName, agentName string) string {
+	return m.TranscriptPath(repoName, agentName) + ".pos"
+}
+
+// CaptureTranscript copies new entries from a Claude session file into the
+// agent's transcript. Session files are append-only, so only the bytes past
+// the last copied offset are copied; calling it repeatedly keeps the
+// transcript in sync without rereading the whole session. The offset is kept
+// with the session file's inode, and copying restarts from the beginning
+// when the file has been replaced or has shrunk below it; entries already in
+// the transcript are kept. A session file that does not exist yet is not an
+// error.
+func (m *Manager) CaptureTranscript(repoName, agentName, sessionFile string) error {
+	src, err := os.Open(sessionFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to open session file: %w", err)
+	}
+	defer src.Close()
+
+	srcInfo, err := src.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat session file: %w", err)
+	}
+
+	dstPath := m.TranscriptPath(repoName, agentName)
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	dst, err := os.OpenFile(dstPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open transcript: %w", err)
+	}
+	defer dst.Close()
+
+	pos, err := m.readTranscriptPos(repoName, agentName, dst)
+	if err != nil {
+		return err
+	}
+
+	dev, ino := fileID(srcInfo)
+	replaced := pos.Inode != 0 && ino != 0 && (pos.Dev != dev || pos.Inode != ino)
+	if replaced || srcInfo.Size() < pos.Offset {
+		pos.Offset = 0
+	}
+
+	if _, err := src.Seek(pos.Offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek session file: %w", err)
+	}
+	n, err := io.Copy(dst, src)
+	if err != nil {
+		return fmt.Errorf("failed to copy transcript: %w", err)
+	}
+
+	return m.writeTranscriptPos(repoName, agentName, transcriptPos{Dev: dev, Inode: ino, Offset: pos.Offset + n})
+}
+
+// readTranscriptPos returns the saved copy position. Transcripts captured
+// before positions were saved are taken to be a copy of the session file's
+// first bytes, as they were.
+func (m *Manager) readTranscriptPos(repoName, agentName string, dst *os.File) (transcriptPos, error) {
+	data, err := os.ReadFile(m.transcriptPosPath(repoName, agentName))
+	if err == nil {
+		var pos transcriptPos
+		if json.Unmarshal(data, &pos) == nil {
+			return pos, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return transcriptPos{}, fmt.Errorf("failed to read transcript position: %w", err)
+	}
+
+	info, err := dst.Stat()
+	if err != nil {
+		return transcriptPos{}, fmt.Errorf("failed to stat transcript: %w", err)
+	}
+	return transcriptPos{Offset: info.Size()}, nil
+}
+
+// writeTranscriptPos saves the copy position
+func (m *Manager) writeTranscriptPos(repoName, agentName string, pos transcriptPos) error {
+	data, err := json.Marshal(pos)
+	if err != nil {
+		return fmt.Errorf("failed to marshal transcript position: %w", err)
+	}
+	if err := os.WriteFile(m.transcriptPosPath(repoName, agentName), data, 0644); err != nil {
+		return fmt.Errorf("failed to save transcript position: %w", err)
+	}
+	return nil
+}
+
+// TranscriptReader streams entries from a transcript one line at a time, so
+// large transcripts are never loaded into memory at once
+type TranscriptReader struct {
+	f *os.File
+	r *bufio.Reader
+}
+
+// Transcript opens an agent's captured transcript for reading.
+// Returns an error wrapping os.ErrNotExist if nothing has been captured yet.
+func (m *Manager) Transcript(repoName, agentName string) (*TranscriptReader, error) {
+	f, err := os.Open(m.TranscriptPath(repoName, agentName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open transcript for agent %s in repo %s: %w", agentName, repoName, err)
+	}
+	return &TranscriptReader{f: f, r: bufio.NewReader(f)}, nil
+}
+
+// Next returns the next transcript entry as raw JSON, or io.EOF when the
+// transcript is exhausted. Blank lines are skipped and a trailing entry
+// without a newline (one still being written) is not returned.
+func (t *TranscriptReader) Next() (json.RawMessage, error) {
+	for {
+		line, err := t.r.ReadBytes('\n')
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read transcript: %w", err)
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if !json.Valid(line) {
+			return nil, fmt.Errorf("invalid transcript entry: %.80s", line)
+		}
+		return json.RawMessage(line), nil
+	}
+}
+
+// Close closes the underlying transcript file
+func (t *TranscriptReader) Close() error {
+	return t.f.Close()
+}
@@ -0,0 +1,170 @@
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type transcriptEntry struct {
+	Type    string `json:"type"`
+	Index   int    `json:"index"`
+	Content string `json:"content"`
+}
+
+func writeSession(t *testing.T, path string, from, to int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open session file: %v", err)
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for i := from; i <= to; i++ {
+		entry := transcriptEntry{Type: "assistant", Index: i, Content: fmt.Sprintf("message %d", i)}
+		if err := enc.Encode(entry); err != nil {
+			t.Fatalf("Failed to write entry: %v", err)
+		}
+	}
+}
+
+func readTranscript(t *testing.T, m *Manager, repoName, agentName string) []transcriptEntry {
+	t.Helper()
+	r, err := m.Transcript(repoName, agentName)
+	if err != nil {
+		t.Fatalf("Transcript() failed: %v", err)
+	}
+	defer r.Close()
+
+	var entries []transcriptEntry
+	for {
+		raw, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return entries
+		}
+		if err != nil {
+			t.Fatalf("Next() failed: %v", err)
+		}
+		var e transcriptEntry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			t.Fatalf("Failed to decode entry: %v", err)
+		}
+		entries = append(entries, e)
+	}
+}
+
+func TestCaptureTranscript(t *testing.T) {
+	m := NewManager(t.TempDir())
+	session := SessionFile(t.TempDir(), "/wts/repo/worker1", "session-1")
+
+	writeSession(t, session, 1, 3)
+	if err := m.CaptureTranscript("repo", "worker1", session); err != nil {
+		t.Fatalf("CaptureTranscript() failed: %v", err)
+	}
+
+	// A second capture only appends entries written since the first
+	writeSession(t, session, 4, 5)
+	if err := m.CaptureTranscript("repo", "worker1", session); err != nil {
+		t.Fatalf("CaptureTranscript() failed: %v", err)
+	}
+
+	entries := readTranscript(t, m, "repo", "worker1")
+	if len(entries) != 5 {
+		t.Fatalf("got %d entries, want 5", len(entries))
+	}
+	for i, e := range entries {
+		if e.Index != i+1 || e.Content != fmt.Sprintf("message %d", i+1) {
+			t.Errorf("entry %d = %+v", i, e)
+		}
+	}
+}
+
+func TestCaptureTranscriptReplacedSession(t *testing.T) {
+	m := NewManager(t.TempDir())
+	session := SessionFile(t.TempDir(), "/wts/repo/worker1", "session-1")
+
+	writeSession(t, session, 1, 3)
+	if err := m.CaptureTranscript("repo", "worker1", session); err != nil {
+		t.Fatalf("CaptureTranscript() failed: %v", err)
+	}
+
+	// A replacement larger than the copied offset is read from its start,
+	// not from the old offset
+	replacement := session + ".new"
+	writeSession(t, replacement, 10, 14)
+	if err := os.Rename(replacement, session); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.CaptureTranscript("repo", "worker1", session); err != nil {
+		t.Fatalf("CaptureTranscript() failed: %v", err)
+	}
+
+	// A truncated session file is read from its start too
+	if err := os.Truncate(session, 0); err != nil {
+		t.Fatal(err)
+	}
+	writeSession(t, session, 20, 20)
+	if err := m.CaptureTranscript("repo", "worker1", session); err != nil {
+		t.Fatalf("CaptureTranscript() failed: %v", err)
+	}
+
+	var got []int
+	for _, e := range readTranscript(t, m, "repo", "worker1") {
+		got = append(got, e.Index)
+	}
+	if fmt.Sprint(got) != "[1 2 3 10 11 12 13 14 20]" {
+		t.Errorf("transcript indexes = %v, want [1 2 3 10 11 12 13 14 20]", got)
+	}
+}
+
+func TestCaptureTranscriptMissingSession(t *testing.T) {
+	m := NewManager(t.TempDir())
+
+	if err := m.CaptureTranscript("repo", "worker1", filepath.Join(t.TempDir(), "missing.jsonl")); err != nil {
+		t.Errorf("CaptureTranscript() with no session file = %v, want nil", err)
+	}
+	if _, err := m.Transcript("repo", "worker1"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Transcript() error = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestTranscriptLargeEntries(t *testing.T) {
+	m := NewManager(t.TempDir())
+	path := m.TranscriptPath("repo", "worker1")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Entries larger than bufio.Scanner's default token limit must still be read
+	big := strings.Repeat("x", 256*1024)
+	var sb strings.Builder
+	for i := 1; i <= 3; i++ {
+		data, _ := json.Marshal(transcriptEntry{Type: "tool_result", Index: i, Content: big})
+		sb.Write(data)
+		sb.WriteString("\n\n")
+	}
+	// A partially written trailing entry is not returned
+	sb.WriteString(`{"type":"assist`)
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := readTranscript(t, m, "repo", "worker1")
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+	for _, e := range entries {
+		if len(e.Content) != len(big) {
+			t.Errorf("entry %d content length = %d, want %d", e.Index, len(e.Content), len(big))
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxLogSize is the size at which Writer rotates a capture file
+const DefaultMaxLogSize int64 = 10 << 20
+
+// DefaultMaxBackups is how many rotated capture files are kept. Each is
+// named <log>.<timestamp>, and the oldest is deleted when another is needed.
+const DefaultMaxBackups = 3
+
+// rotatedLayout is the timestamp RotateFile appends to a rotated file. The
+// fraction keeps names unique and ordered when a file rotates more than once
+// a second; copies rotated by older builds have only the seconds.
+const (
+	rotatedLayout       = "20060102-150405.000000000"
+	rotatedSecondLayout = "20060102-150405"
+)
+
+// fileLocks serializes writes and rotation per capture file, so writers
+// opened separately for the same agent don't rotate over each other
+var fileLocks sync.Map // path -> *sync.Mutex
+
+// RotatingWriter appends to a capture file, rotating it with RotateFile once
+// it would grow past its size limit. Writers for the same path may be used
+// concurrently, including from other processes: a writer whose file was
+// rotated by someone else reopens the path before its next write.
+type RotatingWriter struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	mu         *sync.Mutex
+	f          *os.File
+}
+
+// Writer opens the agent's capture file for appending, rotating at
+// DefaultMaxLogSize and keeping DefaultMaxBackups old files
+func (m *Manager) Writer(repoName, agentName string, isWorker bool) (io.WriteCloser, error) {
+	return OpenRotating(m.LogPath(repoName, agentName, isWorker), DefaultMaxLogSize, DefaultMaxBackups)
+}
+
+// OpenRotating opens path for appending, creating it and its directory if
+// needed. Before a write would take the file past maxSize bytes it is
+// rotated, keeping at most maxBackups old copies.
+func OpenRotating(path string, maxSize int64, maxBackups int) (*RotatingWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	lock, _ := fileLocks.LoadOrStore(path, &sync.Mutex{})
+	w := &RotatingWriter{path: path, maxSize: maxSize, maxBackups: maxBackups, mu: lock.(*sync.Mutex)}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write appends p, rotating first if p would take the file past the limit.
+// A single write larger than the limit is still written whole.
+func (w *RotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return 0, os.ErrClosed
+	}
+	// Another writer may have rotated the file since we last wrote
+	if err := w.reopenIfMoved(); err != nil {
+		return 0, err
+	}
+
+	info, err := w.f.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat output file: %w", err)
+	}
+	if info.Size() > 0 && info.Size()+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	return w.f.Write(p)
+}
+
+// Close closes the capture file
+func (w *RotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
+
+func (w *RotatingWriter) open() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open output file: %w", err)
+	}
+	w.f = f
+	return nil
+}
+
+// reopenIfMoved reopens path if the open file is no longer the one at path
+func (w *RotatingWriter) reopenIfMoved() error {
+	current, err := os.Stat(w.path)
+	if err == nil {
+		if open, err := w.f.Stat(); err == nil && os.SameFile(current, open) {
+			return nil
+		}
+	}
+	w.f.Close()
+	return w.open()
+}
+
+// rotate moves the file aside and opens a fresh one
+func (w *RotatingWriter) rotate() error {
+	w.f.Close()
+	w.f = nil
+
+	if err := RotateFile(w.path, w.maxBackups); err != nil {
+		return err
+	}
+	return w.open()
+}
+
+// RotateFile renames path to path.<timestamp> and deletes the oldest rotated
+// copies so at most maxBackups remain; with maxBackups <= 0 the file is
+// removed instead. This is the only rotation scheme for capture files: it is
+// used by RotatingWriter and by the daemon's periodic size check.
+func RotateFile(path string, maxBackups int) error {
+	if maxBackups <= 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate output file: %w", err)
+		}
+		return nil
+	}
+
+	now := time.Now()
+	rotated := path + "." + now.Format(rotatedLayout)
+	for {
+		if _, err := os.Stat(rotated); os.IsNotExist(err) {
+			break
+		}
+		now = now.Add(time.Nanosecond)
+		rotated = path + "." + now.Format(rotatedLayout)
+	}
+	if err := os.Rename(path, rotated); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rotate output file: %w", err)
+	}
+
+	copies, err := RotatedCopies(path)
+	if err != nil {
+		return err
+	}
+	for len(copies) > maxBackups {
+		if err := os.Remove(copies[0]); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to prune rotated output file: %w", err)
+		}
+		copies = copies[1:]
+	}
+	return nil
+}
+
+// RotatedCopies returns the rotated copies of path, oldest first
+func RotatedCopies(path string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rotated output files: %w", err)
+	}
+
+	prefix := filepath.Base(path) + "."
+	var copies []string
+	for _, entry := range entries {
+		suffix, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok || entry.IsDir() || len(suffix) < len(rotatedSecondLayout) {
+			continue
+		}
+		if _, err := time.Parse(rotatedSecondLayout, suffix[:len(rotatedSecondLayout)]); err != nil {
+			continue
+		}
+		copies = append(copies, filepath.Join(filepath.Dir(path), entry.Name()))
+	}
+	sort.Strings(copies)
+	return copies, nil
+}
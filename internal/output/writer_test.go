@@ -0,0 +1,197 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	m := NewManager(t.TempDir())
+
+	w, err := m.Writer("repo", "happy-fox", true)
+	if err != nil {
+		t.Fatalf("Writer() failed: %v", err)
+	}
+	for i := 1; i <= 5; i++ {
+		fmt.Fprintf(w, "line %d\n", i)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	// A second writer appends rather than truncating
+	w, err = m.Writer("repo", "happy-fox", true)
+	if err != nil {
+		t.Fatalf("Writer() failed: %v", err)
+	}
+	fmt.Fprintf(w, "line 6\n")
+	w.Close()
+
+	if _, err := w.Write([]byte("late\n")); err == nil {
+		t.Error("Write() after Close() should fail")
+	}
+
+	lines, err := m.Tail("repo", "happy-fox", 2)
+	if err != nil {
+		t.Fatalf("Tail() failed: %v", err)
+	}
+	if strings.Join(lines, ",") != "line 5,line 6" {
+		t.Errorf("Tail(2) = %v, want [line 5 line 6]", lines)
+	}
+	if lines, _ := m.Tail("repo", "happy-fox", 100); len(lines) != 6 {
+		t.Errorf("Tail(100) returned %d lines, want all 6", len(lines))
+	}
+}
+
+func TestWriterRotates(t *testing.T) {
+	path := NewManager(t.TempDir()).LogPath("repo", "supervisor", false)
+	w, err := OpenRotating(path, 20, 2)
+	if err != nil {
+		t.Fatalf("OpenRotating() failed: %v", err)
+	}
+	defer w.Close()
+
+	// Each line is 8 bytes, so every file holds two
+	for i := 1; i <= 9; i++ {
+		fmt.Fprintf(w, "line %02d\n", i)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "line 09\n" {
+		t.Errorf("%s = %q, %v, want %q", path, data, err, "line 09\n")
+	}
+
+	copies, err := RotatedCopies(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"line 05\nline 06\n", "line 07\nline 08\n"}
+	if len(copies) != len(want) {
+		t.Fatalf("rotation kept %d copies, want %d: %v", len(copies), len(want), copies)
+	}
+	for i, file := range copies {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Errorf("failed to read %s: %v", file, err)
+			continue
+		}
+		if string(data) != want[i] {
+			t.Errorf("%s = %q, want %q", file, data, want[i])
+		}
+	}
+}
+
+func TestRotatedCopies(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "agent.log")
+	for _, name := range []string{
+		"agent.log",
+		"agent.log.20240115-120000",           // rotated by an older build
+		"agent.log.20240115-120000.000000001", // same second, later
+		"agent.log.20240116-090000.000000000",
+		"agent.log.bak",
+		"other.log.20240115-120000",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	copies, err := RotatedCopies(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, c := range copies {
+		names = append(names, filepath.Base(c))
+	}
+	want := "agent.log.20240115-120000,agent.log.20240115-120000.000000001,agent.log.20240116-090000.000000000"
+	if strings.Join(names, ",") != want {
+		t.Errorf("RotatedCopies() = %v, want %s", names, want)
+	}
+}
+
+func TestWriterConcurrent(t *testing.T) {
+	m := NewManager(t.TempDir())
+	const writers, perWriter = 8, 200
+
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w, err := m.Writer("repo", "supervisor", false)
+			if err != nil {
+				t.Errorf("Writer() failed: %v", err)
+				return
+			}
+			defer w.Close()
+			for j := 0; j < perWriter; j++ {
+				fmt.Fprintf(w, "writer %d line %d\n", i, j)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	lines, err := m.Tail("repo", "supervisor", 0)
+	if err != nil {
+		t.Fatalf("Tail() failed: %v", err)
+	}
+	if len(lines) != writers*perWriter {
+		t.Fatalf("got %d lines, want %d", len(lines), writers*perWriter)
+	}
+	seen := make(map[string]bool)
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "writer ") || seen[line] {
+			t.Fatalf("interleaved or duplicate line %q", line)
+		}
+		seen[line] = true
+	}
+}
+
+func TestWriterConcurrentRotation(t *testing.T) {
+	path := NewManager(t.TempDir()).LogPath("repo", "supervisor", false)
+	const writers, perWriter = 4, 50
+
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w, err := OpenRotating(path, 1<<10, 100)
+			if err != nil {
+				t.Errorf("OpenRotating() failed: %v", err)
+				return
+			}
+			defer w.Close()
+			for j := 0; j < perWriter; j++ {
+				fmt.Fprintf(w, "%032d\n", j)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Every line lands in exactly one file and none exceeds the limit
+	copies, err := RotatedCopies(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for _, file := range append(copies, path) {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) > 1<<10 {
+			t.Errorf("%s is %d bytes, over the 1KiB limit", file, len(data))
+		}
+		total += strings.Count(string(data), "\n")
+	}
+	if total != writers*perWriter {
+		t.Errorf("found %d lines across rotated files, want %d", total, writers*perWriter)
+	}
+}
@@ -0,0 +1,81 @@
+// Package procstat reports whether a process is running. A zombie process
+// has exited but not yet been reaped by its parent; it still answers signal
+// 0, so it is checked separately and treated as not running.
+package procstat
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"syscall"
+)
+
+// Status describes what a PID refers to
+type Status string
+
+const (
+	// StatusRunning means the process exists and is not a zombie
+	StatusRunning Status = "running"
+	// StatusZombie means the process has exited but has not been reaped
+	StatusZombie Status = "zombie"
+	// StatusGone means no process with the PID exists
+	StatusGone Status = "gone"
+)
+
+// errUnsupported is returned by readStat on platforms without /proc
+var errUnsupported = errors.New("process stat not supported on this platform")
+
+// StatReader returns the raw contents of /proc/<pid>/stat
+type StatReader func(pid int) ([]byte, error)
+
+// Check returns the status of pid. Where process state cannot be read, a
+// process that answers signal 0 is reported as running.
+func Check(pid int) Status {
+	return CheckWith(pid, readStat)
+}
+
+// CheckWith is Check with a custom stat reader, for tests
+func CheckWith(pid int, read StatReader) Status {
+	if pid <= 0 {
+		return StatusGone
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return StatusGone
+	}
+	// Send signal 0 to check if process exists (doesn't actually signal, just checks)
+	if process.Signal(syscall.Signal(0)) != nil {
+		return StatusGone
+	}
+
+	data, err := read(pid)
+	if err != nil {
+		return StatusRunning
+	}
+	state, ok := ParseState(data)
+	if ok && state == 'Z' {
+		return StatusZombie
+	}
+	return StatusRunning
+}
+
+// IsAlive reports whether pid is a running, non-zombie process
+func IsAlive(pid int) bool {
+	return Check(pid) == StatusRunning
+}
+
+// ParseState returns the state character from the contents of
+// /proc/<pid>/stat. The command name is wrapped in parentheses and may
+// itself contain spaces or parentheses, so the state is read after the last
+// closing parenthesis.
+func ParseState(stat []byte) (byte, bool) {
+	i := bytes.LastIndexByte(stat, ')')
+	if i < 0 {
+		return 0, false
+	}
+	rest := bytes.TrimLeft(stat[i+1:], " ")
+	if len(rest) == 0 {
+		return 0, false
+	}
+	return rest[0], true
+}
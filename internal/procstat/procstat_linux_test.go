@@ -0,0 +1,37 @@
+//go:build linux
+
+package procstat
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestCheckDetectsZombie(t *testing.T) {
+	cmd := exec.Command("sh", "-c", "exit 0")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start sh: %v", err)
+	}
+	pid := cmd.Process.Pid
+
+	// Until Wait is called the exited child stays a zombie
+	deadline := time.Now().Add(5 * time.Second)
+	for Check(pid) != StatusZombie {
+		if time.Now().After(deadline) {
+			cmd.Wait()
+			t.Fatalf("process %d never became a zombie, status %s", pid, Check(pid))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if IsAlive(pid) {
+		t.Error("zombie process should not be reported alive")
+	}
+
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if got := Check(pid); got != StatusGone {
+		t.Errorf("after reaping: got %s, want %s", got, StatusGone)
+	}
+}
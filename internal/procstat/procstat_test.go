@@ -0,0 +1,60 @@
+package procstat
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestParseState(t *testing.T) {
+	tests := []struct {
+		name  string
+		stat  string
+		state byte
+		ok    bool
+	}{
+		{"running", "1234 (claude) S 1 1234 1234 0 -1", 'S', true},
+		{"zombie", "1234 (claude) Z 1 1234 1234 0 -1", 'Z', true},
+		{"name with parens", "1234 (a) Z (b)) R 1 1234", 'R', true},
+		{"name with spaces", "1234 (my tool) Z 1", 'Z', true},
+		{"no parens", "1234 claude S", 0, false},
+		{"truncated", "1234 (claude)", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, ok := ParseState([]byte(tt.stat))
+			if state != tt.state || ok != tt.ok {
+				t.Errorf("ParseState(%q) = %q, %v, want %q, %v", tt.stat, state, ok, tt.state, tt.ok)
+			}
+		})
+	}
+}
+
+func TestCheckWithStubbedReader(t *testing.T) {
+	pid := os.Getpid()
+	stub := func(stat string, err error) StatReader {
+		return func(int) ([]byte, error) { return []byte(stat), err }
+	}
+
+	if got := CheckWith(pid, stub("1 (x) Z 0", nil)); got != StatusZombie {
+		t.Errorf("zombie stat: got %s, want %s", got, StatusZombie)
+	}
+	if got := CheckWith(pid, stub("1 (x) R 0", nil)); got != StatusRunning {
+		t.Errorf("running stat: got %s, want %s", got, StatusRunning)
+	}
+	if got := CheckWith(pid, stub("", errors.New("no /proc"))); got != StatusRunning {
+		t.Errorf("unreadable stat: got %s, want %s", got, StatusRunning)
+	}
+	if got := CheckWith(999999, stub("1 (x) R 0", nil)); got != StatusGone {
+		t.Errorf("missing pid: got %s, want %s", got, StatusGone)
+	}
+	if got := CheckWith(0, stub("1 (x) R 0", nil)); got != StatusGone {
+		t.Errorf("pid 0: got %s, want %s", got, StatusGone)
+	}
+}
+
+func TestIsAliveSelf(t *testing.T) {
+	if !IsAlive(os.Getpid()) {
+		t.Error("current process should be alive")
+	}
+}
@@ -0,0 +1,13 @@
+//go:build linux
+
+package procstat
+
+import (
+	"fmt"
+	"os"
+)
+
+// readStat reads /proc/<pid>/stat
+func readStat(pid int) ([]byte, error) {
+	return os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package procstat
+
+// readStat is unavailable without /proc, so Check falls back to signal 0
+func readStat(pid int) ([]byte, error) {
+	return nil, errUnsupported
+}
@@ -0,0 +1,189 @@
+// Package reconcile converges state with what is actually running. For each
+// agent it checks whether the agent's process is alive, whether its tmux
+// window exists, and whether its worktree is present, then updates state to
+// match and reports every change as a diff.
+//
+// Reconcile only updates state and recreates windows; it never removes
+// anything. Agents it marks dead are removed by the next cleanup. Running it
+// twice in a row makes no changes the second time.
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/dlorenc/multiclaude/internal/cleanup"
+	"github.com/dlorenc/multiclaude/internal/procstat"
+	"github.com/dlorenc/multiclaude/internal/state"
+)
+
+// Actions a reconcile can take on an agent
+const (
+	// ActionMarkDead marks an agent ReadyForCleanup
+	ActionMarkDead = "mark_dead"
+
+	// ActionRecreateWindow creates a tmux window for an agent whose process
+	// is still running but whose window is gone
+	ActionRecreateWindow = "recreate_window"
+)
+
+// TmuxClient is the subset of tmux operations reconcile needs.
+// *tmux.Client satisfies this interface.
+type TmuxClient interface {
+	HasSession(ctx context.Context, name string) (bool, error)
+	CreateSession(ctx context.Context, name string, detached bool) error
+	HasWindow(ctx context.Context, session, windowName string) (bool, error)
+	CreateWindow(ctx context.Context, session, windowName string) error
+	SetEnvironment(ctx context.Context, session, name, value string) error
+}
+
+// Change is one difference between state and reality and how it was resolved
+type Change struct {
+	Repo   string `json:"repo"`
+	Agent  string `json:"agent"`
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s/%s: %s (%s)", c.Repo, c.Agent, c.Action, c.Reason)
+}
+
+// Report lists the changes a reconcile made, or would make in a dry run
+type Report struct {
+	DryRun  bool     `json:"dry_run"`
+	Checked int      `json:"checked"`
+	Changes []Change `json:"changes"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// Reconciler compares agents in state with their processes, windows, and
+// worktrees
+type Reconciler struct {
+	state *state.State
+	tmux  TmuxClient
+	root  string // marks recreated sessions as owned by this root
+
+	// alive is swappable so tests can control process liveness
+	alive func(pid int) bool
+}
+
+// New creates a reconciler. Sessions it recreates are marked as owned by
+// root, the daemon's root directory.
+func New(st *state.State, tmux TmuxClient, root string) *Reconciler {
+	return &Reconciler{state: st, tmux: tmux, root: root, alive: procstat.IsAlive}
+}
+
+// Run checks every agent and updates state to match reality, or only
+// reports the changes if dryRun is set. Agents already marked
+// ReadyForCleanup are skipped. A failure on one agent is listed in
+// Report.Errors and does not stop the run.
+func (r *Reconciler) Run(ctx context.Context, dryRun bool) *Report {
+	report := &Report{DryRun: dryRun, Changes: []Change{}}
+
+	repos := r.state.GetAllRepos()
+	for _, repoName := range slices.Sorted(maps.Keys(repos)) {
+		repo := repos[repoName]
+
+		hasSession, err := r.tmux.HasSession(ctx, repo.TmuxSession)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("failed to check session %s: %v", repo.TmuxSession, err))
+			continue
+		}
+
+		for _, agentName := range slices.Sorted(maps.Keys(repo.Agents)) {
+			agent := repo.Agents[agentName]
+			if agent.ReadyForCleanup {
+				continue
+			}
+			report.Checked++
+
+			change, err := r.reconcileAgent(ctx, repoName, repo.TmuxSession, hasSession, agentName, agent, dryRun)
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("%s/%s: %v", repoName, agentName, err))
+				continue
+			}
+			if change == nil {
+				continue
+			}
+			report.Changes = append(report.Changes, *change)
+
+			// A recreated window also recreates a missing session
+			if change.Action == ActionRecreateWindow && !dryRun {
+				hasSession = true
+			}
+		}
+	}
+
+	return report
+}
+
+// reconcileAgent decides what, if anything, to change for one agent and
+// applies it unless dryRun is set
+func (r *Reconciler) reconcileAgent(ctx context.Context, repoName, session string, hasSession bool, agentName string, agent state.Agent, dryRun bool) (*Change, error) {
+	hasWindow := false
+	if hasSession {
+		var err error
+		hasWindow, err = r.tmux.HasWindow(ctx, session, agent.TmuxWindow)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check window: %w", err)
+		}
+	}
+	processAlive := agent.PID > 0 && r.alive(agent.PID)
+	ownsWorktree := agent.Type == state.AgentTypeWorker || agent.Type == state.AgentTypeReview
+	worktreeMissing := false
+	if ownsWorktree && agent.WorktreePath != "" {
+		if _, err := os.Stat(agent.WorktreePath); os.IsNotExist(err) {
+			worktreeMissing = true
+		}
+	}
+
+	// Dead persistent agents are left for the health check to restart
+	var change *Change
+	switch {
+	case !hasWindow && processAlive:
+		change = &Change{Action: ActionRecreateWindow, Reason: fmt.Sprintf("window missing but process %d is running", agent.PID)}
+	case agent.Type.IsPersistent():
+		return nil, nil
+	case !hasWindow:
+		change = &Change{Action: ActionMarkDead, Reason: "window and process are gone"}
+	case agent.PID > 0 && !processAlive:
+		change = &Change{Action: ActionMarkDead, Reason: fmt.Sprintf("process %d is not running", agent.PID)}
+	case worktreeMissing:
+		change = &Change{Action: ActionMarkDead, Reason: fmt.Sprintf("worktree %s is missing", agent.WorktreePath)}
+	default:
+		return nil, nil
+	}
+	change.Repo, change.Agent = repoName, agentName
+
+	if dryRun {
+		return change, nil
+	}
+
+	switch change.Action {
+	case ActionRecreateWindow:
+		if !hasSession {
+			if err := r.tmux.CreateSession(ctx, session, true); err != nil {
+				return nil, fmt.Errorf("failed to recreate session %s: %w", session, err)
+			}
+			if err := cleanup.MarkSession(ctx, r.tmux, session, r.root); err != nil {
+				return nil, err
+			}
+		}
+		if err := r.tmux.CreateWindow(ctx, session, agent.TmuxWindow); err != nil {
+			return nil, fmt.Errorf("failed to recreate window %s: %w", agent.TmuxWindow, err)
+		}
+	case ActionMarkDead:
+		agent.ReadyForCleanup = true
+		if agent.FailureReason == "" {
+			agent.FailureReason = "reconcile: " + change.Reason
+		}
+		if err := r.state.UpdateAgent(repoName, agentName, agent); err != nil {
+			return nil, fmt.Errorf("failed to mark agent dead: %w", err)
+		}
+	}
+	return change, nil
+}
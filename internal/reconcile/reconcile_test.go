@@ -0,0 +1,180 @@
+package reconcile
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dlorenc/multiclaude/internal/cleanup"
+	"github.com/dlorenc/multiclaude/internal/state"
+)
+
+// fakeTmux tracks sessions and windows in memory
+type fakeTmux struct {
+	windows map[string]map[string]bool // session -> window -> exists
+	created []string                   // "session:window" for each CreateWindow
+	env     map[string]string          // "session:name" -> value
+}
+
+func (f *fakeTmux) HasSession(ctx context.Context, name string) (bool, error) {
+	_, ok := f.windows[name]
+	return ok, nil
+}
+
+func (f *fakeTmux) CreateSession(ctx context.Context, name string, detached bool) error {
+	f.windows[name] = map[string]bool{}
+	return nil
+}
+
+func (f *fakeTmux) HasWindow(ctx context.Context, session, window string) (bool, error) {
+	return f.windows[session][window], nil
+}
+
+func (f *fakeTmux) CreateWindow(ctx context.Context, session, window string) error {
+	f.windows[session][window] = true
+	f.created = append(f.created, session+":"+window)
+	return nil
+}
+
+func (f *fakeTmux) SetEnvironment(ctx context.Context, session, name, value string) error {
+	if f.env == nil {
+		f.env = make(map[string]string)
+	}
+	f.env[session+":"+name] = value
+	return nil
+}
+
+// Live and dead PIDs as seen by the stubbed liveness check
+const (
+	livePID = 1001
+	deadPID = 1002
+)
+
+// seedDrift creates agents in every drift scenario: one healthy, and one for
+// each kind of mismatch between state and reality
+func seedDrift(t *testing.T) (*Reconciler, *state.State, *fakeTmux) {
+	t.Helper()
+	dir := t.TempDir()
+	st := state.New(filepath.Join(dir, "state.json"))
+
+	st.AddRepo("repo", &state.Repository{TmuxSession: "mc-repo", Agents: make(map[string]state.Agent)})
+	agents := map[string]state.Agent{
+		// Everything matches
+		"healthy": {Type: state.AgentTypeWorker, TmuxWindow: "healthy", PID: livePID, WorktreePath: dir},
+		// Window closed and process exited
+		"vanished": {Type: state.AgentTypeWorker, TmuxWindow: "vanished", PID: deadPID},
+		// Window closed but process still running
+		"detached": {Type: state.AgentTypeWorker, TmuxWindow: "detached", PID: livePID},
+		// Window open but process exited
+		"exited": {Type: state.AgentTypeWorker, TmuxWindow: "exited", PID: deadPID},
+		// Worktree deleted out from under a live worker
+		"no-worktree": {Type: state.AgentTypeReview, TmuxWindow: "no-worktree", PID: livePID, WorktreePath: filepath.Join(dir, "gone")},
+		// Persistent agents with a dead process are restarted by the health check
+		"supervisor": {Type: state.AgentTypeSupervisor, TmuxWindow: "supervisor", PID: deadPID},
+		// Already on its way out
+		"finished": {Type: state.AgentTypeWorker, TmuxWindow: "finished", ReadyForCleanup: true},
+	}
+	for name, agent := range agents {
+		st.AddAgent("repo", name, agent)
+	}
+
+	// A repository whose whole session is gone but whose agent is running
+	st.AddRepo("lost", &state.Repository{TmuxSession: "mc-lost", Agents: make(map[string]state.Agent)})
+	st.AddAgent("lost", "worker", state.Agent{Type: state.AgentTypeWorker, TmuxWindow: "worker", PID: livePID})
+
+	tmux := &fakeTmux{windows: map[string]map[string]bool{
+		"mc-repo": {"healthy": true, "exited": true, "no-worktree": true, "supervisor": true},
+	}}
+
+	r := New(st, tmux, "/mc-root")
+	r.alive = func(pid int) bool { return pid == livePID }
+	return r, st, tmux
+}
+
+// actions returns "<repo>/<agent> <action>" for each change
+func actions(report *Report) []string {
+	var out []string
+	for _, c := range report.Changes {
+		out = append(out, c.Repo+"/"+c.Agent+" "+c.Action)
+	}
+	return out
+}
+
+var wantActions = []string{
+	"lost/worker recreate_window",
+	"repo/detached recreate_window",
+	"repo/exited mark_dead",
+	"repo/no-worktree mark_dead",
+	"repo/vanished mark_dead",
+}
+
+func TestReconcileDryRun(t *testing.T) {
+	r, st, tmux := seedDrift(t)
+
+	report := r.Run(context.Background(), true)
+	if len(report.Errors) > 0 {
+		t.Fatalf("Errors = %v", report.Errors)
+	}
+	if !report.DryRun {
+		t.Error("report should be marked as a dry run")
+	}
+	if report.Checked != 7 {
+		t.Errorf("Checked = %d, want 7 (finished agent skipped)", report.Checked)
+	}
+	if got := actions(report); !reflect.DeepEqual(got, wantActions) {
+		t.Errorf("actions = %v, want %v", got, wantActions)
+	}
+
+	// Nothing changes
+	for _, name := range []string{"vanished", "exited", "no-worktree"} {
+		if agent, _ := st.GetAgent("repo", name); agent.ReadyForCleanup {
+			t.Errorf("dry run marked %s dead", name)
+		}
+	}
+	if len(tmux.created) != 0 {
+		t.Errorf("dry run created windows %v", tmux.created)
+	}
+	if _, ok := tmux.windows["mc-lost"]; ok {
+		t.Error("dry run recreated a session")
+	}
+}
+
+func TestReconcileConverges(t *testing.T) {
+	r, st, tmux := seedDrift(t)
+
+	report := r.Run(context.Background(), false)
+	if len(report.Errors) > 0 {
+		t.Fatalf("Errors = %v", report.Errors)
+	}
+	if got := actions(report); !reflect.DeepEqual(got, wantActions) {
+		t.Errorf("actions = %v, want %v", got, wantActions)
+	}
+
+	for _, name := range []string{"vanished", "exited", "no-worktree"} {
+		agent, _ := st.GetAgent("repo", name)
+		if !agent.ReadyForCleanup {
+			t.Errorf("%s should be marked ReadyForCleanup", name)
+		}
+		if agent.FailureReason == "" {
+			t.Errorf("%s should record why it was marked dead", name)
+		}
+	}
+	for _, name := range []string{"healthy", "detached", "supervisor"} {
+		if agent, _ := st.GetAgent("repo", name); agent.ReadyForCleanup {
+			t.Errorf("%s should not be marked dead", name)
+		}
+	}
+	if want := []string{"mc-lost:worker", "mc-repo:detached"}; !reflect.DeepEqual(tmux.created, want) {
+		t.Errorf("created windows = %v, want %v", tmux.created, want)
+	}
+	if root := tmux.env["mc-lost:"+cleanup.SessionRootEnv]; root != "/mc-root" {
+		t.Errorf("recreated session root = %q, want /mc-root", root)
+	}
+
+	// Running again finds nothing to change
+	report = r.Run(context.Background(), false)
+	if len(report.Changes) != 0 || len(report.Errors) != 0 {
+		t.Errorf("second run = %+v, want no changes", report)
+	}
+}
@@ -1,6 +1,7 @@
 package redact
 
 import (
+	"net/url"
 	"os"
 	"regexp"
 	"strings"
@@ -101,6 +102,9 @@ func (r *Redactor) Text(text string) string {
 	text = httpsPattern.ReplaceAllString(text, "https://github.com/<owner>/<repo>")
 	text = sshPattern.ReplaceAllString(text, "git@github.com:<owner>/<repo>")
 
+	// Redact credentials
+	text = Tokens(text)
+
 	// Redact known repo names in text
 	r.mu.Lock()
 	for original, redacted := range r.repoNames {
@@ -113,6 +117,79 @@ func (r *Redactor) Text(text string) string {
 	return text
 }
 
+// Redacted replaces the value of a sensitive environment variable
+const Redacted = "[REDACTED]"
+
+// SensitiveNamePattern matches names of variables and arguments whose values
+// are treated as secrets: those containing "token", "key", "secret" or
+// "password" in any case
+var SensitiveNamePattern = regexp.MustCompile(`(?i)token|key|secret|password`)
+
+// TailscaleKeyPattern matches Tailscale auth and API keys
+var TailscaleKeyPattern = regexp.MustCompile(`\btskey-[A-Za-z0-9_-]+`)
+
+// TokenPatterns match common credential formats: GitHub, Anthropic,
+// Tailscale, Slack and AWS keys, and JWTs. They catch secrets stored under
+// innocuous names or embedded in free text.
+var TokenPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\b(ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{20,}`),
+	regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{20,}`),
+	regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_-]{20,}`),
+	TailscaleKeyPattern,
+	regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`),
+	regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`),
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`),
+}
+
+// IsSensitiveEnv reports whether an environment variable's name suggests it
+// holds a secret
+func IsSensitiveEnv(name string) bool {
+	return SensitiveNamePattern.MatchString(name)
+}
+
+// Tokens returns s with every match of TokenPatterns replaced by Redacted
+func Tokens(s string) string {
+	for _, re := range TokenPatterns {
+		s = re.ReplaceAllString(s, Redacted)
+	}
+	return s
+}
+
+// Env returns a copy of env with the values of sensitive variables replaced
+// by Redacted
+func Env(env map[string]string) map[string]string {
+	if env == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(env))
+	for name, value := range env {
+		if IsSensitiveEnv(name) {
+			value = Redacted
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
+
+// URLCredentials returns rawURL with any password in its user info replaced
+// by Redacted. URLs without a password, or that do not parse, are returned
+// unchanged.
+func URLCredentials(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return rawURL
+	}
+	if _, hasPassword := u.User.Password(); hasPassword {
+		u.User = url.UserPassword(u.User.Username(), Redacted)
+	} else if u.Scheme == "https" || u.Scheme == "http" {
+		// A lone user in an HTTP URL is usually a token
+		u.User = url.User(Redacted)
+	} else {
+		return rawURL
+	}
+	return u.String()
+}
+
 // itoa converts an int to string without importing strconv
 func itoa(n int) string {
 	if n == 0 {
@@ -0,0 +1,136 @@
+// Package report builds summaries of what agents are working on across all
+// tracked repositories.
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dlorenc/multiclaude/internal/state"
+)
+
+// Agent statuses derived from state. The report is built from the state file
+// alone, so it cannot tell whether an agent's tmux window is still alive.
+const (
+	StatusActive    = "active"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+// AgentEntry describes one agent in a task report
+type AgentEntry struct {
+	Name      string          `json:"name"`
+	Type      state.AgentType `json:"type"`
+	Task      string          `json:"task,omitempty"`
+	Status    string          `json:"status"`
+	Summary   string          `json:"summary,omitempty"`
+	Failure   string          `json:"failure_reason,omitempty"`
+	PRNumber  int             `json:"pr_number,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// RepoEntry lists the agents in one repository
+type RepoEntry struct {
+	Name   string       `json:"name"`
+	Agents []AgentEntry `json:"agents"`
+}
+
+// TaskReport lists every repository's agents and their tasks
+type TaskReport struct {
+	GeneratedAt time.Time   `json:"generated_at"`
+	Repos       []RepoEntry `json:"repos"`
+}
+
+// AgentStatus derives an agent's status from its state fields
+func AgentStatus(agent state.Agent) string {
+	switch {
+	case agent.FailureReason != "":
+		return StatusFailed
+	case agent.ReadyForCleanup:
+		return StatusCompleted
+	default:
+		return StatusActive
+	}
+}
+
+// Build walks st and returns a report of every repository's agents, in
+// sorted order
+func Build(st *state.State) *TaskReport {
+	r := &TaskReport{GeneratedAt: time.Now(), Repos: []RepoEntry{}}
+
+	repoNames := st.ListRepos()
+	sort.Strings(repoNames)
+	for _, repoName := range repoNames {
+		entry := RepoEntry{Name: repoName, Agents: []AgentEntry{}}
+
+		agentNames, err := st.ListAgents(repoName)
+		if err != nil {
+			continue
+		}
+		sort.Strings(agentNames)
+		for _, agentName := range agentNames {
+			agent, exists := st.GetAgent(repoName, agentName)
+			if !exists {
+				continue
+			}
+			entry.Agents = append(entry.Agents, AgentEntry{
+				Name:      agentName,
+				Type:      agent.Type,
+				Task:      agent.Task,
+				Status:    AgentStatus(agent),
+				Summary:   agent.Summary,
+				Failure:   agent.FailureReason,
+				PRNumber:  agent.PRNumber,
+				CreatedAt: agent.CreatedAt,
+			})
+		}
+
+		r.Repos = append(r.Repos, entry)
+	}
+
+	return r
+}
+
+// WriteText writes the report as aligned plain text
+func (r *TaskReport) WriteText(w io.Writer) error {
+	if len(r.Repos) == 0 {
+		_, err := fmt.Fprintln(w, "No repositories tracked")
+		return err
+	}
+
+	for i, repo := range r.Repos {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s (%d agents)\n", repo.Name, len(repo.Agents))
+		if len(repo.Agents) == 0 {
+			continue
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, agent := range repo.Agents {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", agent.Name, agent.Type, agent.Status, describeTask(agent))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// describeTask returns the text shown in an agent's task column
+func describeTask(agent AgentEntry) string {
+	task := agent.Task
+	if task == "" && agent.PRNumber > 0 {
+		task = fmt.Sprintf("review PR #%d", agent.PRNumber)
+	}
+	if task == "" {
+		task = "-"
+	}
+	// Keep multi-line tasks on one row
+	return strings.Join(strings.Fields(task), " ")
+}
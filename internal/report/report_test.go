@@ -0,0 +1,102 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dlorenc/multiclaude/internal/state"
+)
+
+func seedState(t *testing.T) *state.State {
+	t.Helper()
+	st := state.New(filepath.Join(t.TempDir(), "state.json"))
+
+	st.AddRepo("web", &state.Repository{Agents: make(map[string]state.Agent)})
+	st.AddAgent("web", "supervisor", state.Agent{Type: state.AgentTypeSupervisor})
+	st.AddAgent("web", "swift-eagle", state.Agent{Type: state.AgentTypeWorker, Task: "Add login page"})
+	st.AddAgent("web", "calm-otter", state.Agent{Type: state.AgentTypeWorker, Task: "Fix\nflaky test", ReadyForCleanup: true})
+
+	st.AddRepo("api", &state.Repository{Agents: make(map[string]state.Agent)})
+	st.AddAgent("api", "bold-fox", state.Agent{Type: state.AgentTypeWorker, Task: "Rate limiting", FailureReason: "tests failed"})
+	st.AddAgent("api", "review-42", state.Agent{Type: state.AgentTypeReview, PRNumber: 42})
+
+	st.AddRepo("empty", &state.Repository{Agents: make(map[string]state.Agent)})
+	return st
+}
+
+func TestBuild(t *testing.T) {
+	r := Build(seedState(t))
+
+	var got []string
+	for _, repo := range r.Repos {
+		for _, agent := range repo.Agents {
+			got = append(got, repo.Name+"/"+agent.Name+" "+agent.Status+" "+agent.Task)
+		}
+	}
+	want := []string{
+		"api/bold-fox failed Rate limiting",
+		"api/review-42 active ",
+		"web/calm-otter completed Fix\nflaky test",
+		"web/supervisor active ",
+		"web/swift-eagle active Add login page",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("report entries:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if len(r.Repos) != 3 || r.Repos[1].Name != "empty" || len(r.Repos[1].Agents) != 0 {
+		t.Errorf("repos = %+v, want api, empty, web", r.Repos)
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Build(seedState(t)).WriteText(&buf); err != nil {
+		t.Fatalf("WriteText() failed: %v", err)
+	}
+
+	want := `api (2 agents)
+  bold-fox   worker  failed  Rate limiting
+  review-42  review  active  review PR #42
+
+empty (0 agents)
+
+web (3 agents)
+  calm-otter   worker      completed  Fix flaky test
+  supervisor   supervisor  active     -
+  swift-eagle  worker      active     Add login page
+`
+	if buf.String() != want {
+		t.Errorf("WriteText() =\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestReportJSON(t *testing.T) {
+	data, err := json.Marshal(Build(seedState(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded struct {
+		Repos []struct {
+			Name   string `json:"name"`
+			Agents []struct {
+				Name   string `json:"name"`
+				Type   string `json:"type"`
+				Task   string `json:"task"`
+				Status string `json:"status"`
+			} `json:"agents"`
+		} `json:"repos"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	agent := decoded.Repos[2].Agents[2]
+	if agent.Name != "swift-eagle" || agent.Type != "worker" || agent.Task != "Add login page" || agent.Status != "active" {
+		t.Errorf("web agent = %+v", agent)
+	}
+}
@@ -0,0 +1,227 @@
+// Package selftest checks that multiclaude's moving parts work together by
+// taking a throwaway agent through its whole life: a scratch worktree, a
+// tmux session and window, and an entry in state, each verified and then
+// removed.
+//
+// Every resource created is torn down before Run returns, including when an
+// earlier step failed, and each teardown is reported as a step of its own.
+package selftest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/dlorenc/multiclaude/internal/command"
+	"github.com/dlorenc/multiclaude/internal/state"
+)
+
+// AgentName is the name of the throwaway agent
+const AgentName = "selftest"
+
+// SessionPrefix begins the name of every tmux session the self-test creates
+const SessionPrefix = "mc-selftest-"
+
+// TmuxClient is the subset of tmux operations the self-test needs.
+// *tmux.Client satisfies this interface.
+type TmuxClient interface {
+	CreateSession(ctx context.Context, name string, detached bool) error
+	CreateWindow(ctx context.Context, session, windowName string) error
+	HasWindow(ctx context.Context, session, windowName string) (bool, error)
+	KillSession(ctx context.Context, name string) error
+}
+
+// Worktrees creates and removes the scratch worktree the agent runs in
+type Worktrees interface {
+	// Create makes a git worktree somewhere under dir and returns its path
+	Create(ctx context.Context, dir string) (string, error)
+	// Remove deletes a worktree returned by Create
+	Remove(ctx context.Context, path string) error
+}
+
+// Step is the outcome of one self-test step
+type Step struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// Report lists every step run, in order. OK is set only if all succeeded.
+type Report struct {
+	OK    bool   `json:"ok"`
+	Steps []Step `json:"steps"`
+}
+
+// Tester runs the self-test
+type Tester struct {
+	state     *state.State
+	tmux      TmuxClient
+	worktrees Worktrees
+	tempDir   string
+
+	report   *Report
+	teardown []func()
+}
+
+// New creates a tester. Scratch directories are created under tempDir, or
+// the system temp directory if it is empty.
+func New(st *state.State, tmux TmuxClient, worktrees Worktrees, tempDir string) *Tester {
+	return &Tester{state: st, tmux: tmux, worktrees: worktrees, tempDir: tempDir}
+}
+
+// Run creates the throwaway agent, verifies it, and tears it down. Run must
+// not be called concurrently on the same Tester.
+func (t *Tester) Run(ctx context.Context) *Report {
+	t.report = &Report{Steps: []Step{}}
+	t.teardown = nil
+	defer t.runTeardown()
+
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 36)
+	repoName := "selftest-" + suffix
+	session := SessionPrefix + suffix
+
+	var dir, worktreePath string
+	ok := t.step("create scratch directory", func() error {
+		var err error
+		dir, err = os.MkdirTemp(t.tempDir, "multiclaude-selftest-")
+		return err
+	})
+	if ok {
+		t.onTeardown("remove scratch directory", func() error { return os.RemoveAll(dir) })
+		ok = t.step("create worktree", func() error {
+			var err error
+			worktreePath, err = t.worktrees.Create(ctx, dir)
+			return err
+		})
+	}
+	if ok {
+		t.onTeardown("remove worktree", func() error { return t.worktrees.Remove(ctx, worktreePath) })
+		ok = t.step("create tmux session", func() error {
+			return t.tmux.CreateSession(ctx, session, true)
+		})
+	}
+	if ok {
+		t.onTeardown("kill tmux session", func() error { return t.tmux.KillSession(ctx, session) })
+		ok = t.step("create tmux window", func() error {
+			return t.tmux.CreateWindow(ctx, session, AgentName)
+		})
+	}
+	if ok {
+		ok = t.step("register repository", func() error {
+			return t.state.AddRepo(repoName, &state.Repository{
+				TmuxSession: session,
+				Agents:      make(map[string]state.Agent),
+			})
+		})
+	}
+	if ok {
+		// Removing the repository drops the agent with it, without
+		// archiving the throwaway agent in the agent history
+		t.onTeardown("remove from state", func() error { return t.state.RemoveRepo(repoName) })
+		ok = t.step("register agent", func() error {
+			return t.state.AddAgent(repoName, AgentName, state.Agent{
+				Type:         state.AgentTypeWorker,
+				WorktreePath: worktreePath,
+				TmuxWindow:   AgentName,
+				Task:         "multiclaude self-test",
+				CreatedAt:    time.Now(),
+			})
+		})
+	}
+	if ok {
+		ok = t.step("verify agent in state", func() error {
+			agent, exists := t.state.GetAgent(repoName, AgentName)
+			if !exists {
+				return fmt.Errorf("agent %q not found in repository %q", AgentName, repoName)
+			}
+			if agent.WorktreePath != worktreePath {
+				return fmt.Errorf("agent worktree is %q, want %q", agent.WorktreePath, worktreePath)
+			}
+			return nil
+		})
+	}
+	if ok {
+		t.step("verify agent in tmux", func() error {
+			exists, err := t.tmux.HasWindow(ctx, session, AgentName)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return fmt.Errorf("window %q not found in session %q", AgentName, session)
+			}
+			return nil
+		})
+	}
+	return t.report
+}
+
+// step runs fn and records its outcome
+func (t *Tester) step(name string, fn func() error) bool {
+	err := fn()
+	s := Step{Name: name, OK: err == nil}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	t.report.Steps = append(t.report.Steps, s)
+	return err == nil
+}
+
+// onTeardown registers a cleanup step to run when Run finishes
+func (t *Tester) onTeardown(name string, fn func() error) {
+	t.teardown = append(t.teardown, func() { t.step(name, fn) })
+}
+
+// runTeardown runs cleanup steps in reverse order of registration, then
+// sets the overall result
+func (t *Tester) runTeardown() {
+	for i := len(t.teardown) - 1; i >= 0; i-- {
+		t.teardown[i]()
+	}
+	t.report.OK = true
+	for _, s := range t.report.Steps {
+		if !s.OK {
+			t.report.OK = false
+			break
+		}
+	}
+}
+
+// GitWorktrees creates the scratch worktree from a new, empty repository
+// inside the scratch directory
+type GitWorktrees struct {
+	Runner command.Runner
+}
+
+// Create initializes a repository with one empty commit under dir and adds
+// a worktree for it
+func (g GitWorktrees) Create(ctx context.Context, dir string) (string, error) {
+	repo := filepath.Join(dir, "repo")
+	path := filepath.Join(dir, "worktree")
+	steps := []struct {
+		name string
+		args []string
+	}{
+		{"init", []string{"init", "--quiet", repo}},
+		{"commit", []string{"-C", repo, "-c", "user.name=multiclaude", "-c", "user.email=selftest@multiclaude.invalid",
+			"commit", "--quiet", "--allow-empty", "-m", "multiclaude self-test"}},
+		{"worktree add", []string{"-C", repo, "worktree", "add", "--quiet", "-b", AgentName, path}},
+	}
+	for _, step := range steps {
+		if _, err := g.Runner.Run(ctx, "git", step.args...); err != nil {
+			return "", fmt.Errorf("git %s: %w", step.name, err)
+		}
+	}
+	return path, nil
+}
+
+// Remove removes a worktree made by Create
+func (g GitWorktrees) Remove(ctx context.Context, path string) error {
+	repo := filepath.Join(filepath.Dir(path), "repo")
+	if _, err := g.Runner.Run(ctx, "git", "-C", repo, "worktree", "remove", "--force", path); err != nil {
+		return fmt.Errorf("git worktree remove: %w", err)
+	}
+	return nil
+}
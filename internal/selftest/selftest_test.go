@@ -0,0 +1,185 @@
+package selftest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dlorenc/multiclaude/internal/command"
+	"github.com/dlorenc/multiclaude/internal/state"
+)
+
+// fakeTmux tracks sessions and windows in memory
+type fakeTmux struct {
+	sessions  map[string][]string
+	windowErr error
+}
+
+func newFakeTmux() *fakeTmux {
+	return &fakeTmux{sessions: make(map[string][]string)}
+}
+
+func (f *fakeTmux) CreateSession(ctx context.Context, name string, detached bool) error {
+	f.sessions[name] = nil
+	return nil
+}
+
+func (f *fakeTmux) CreateWindow(ctx context.Context, session, windowName string) error {
+	if f.windowErr != nil {
+		return f.windowErr
+	}
+	f.sessions[session] = append(f.sessions[session], windowName)
+	return nil
+}
+
+func (f *fakeTmux) HasWindow(ctx context.Context, session, windowName string) (bool, error) {
+	for _, w := range f.sessions[session] {
+		if w == windowName {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeTmux) KillSession(ctx context.Context, name string) error {
+	delete(f.sessions, name)
+	return nil
+}
+
+// fakeWorktrees creates plain directories
+type fakeWorktrees struct {
+	live map[string]bool
+}
+
+func (f *fakeWorktrees) Create(ctx context.Context, dir string) (string, error) {
+	path := filepath.Join(dir, "worktree")
+	if err := os.Mkdir(path, 0755); err != nil {
+		return "", err
+	}
+	f.live[path] = true
+	return path, nil
+}
+
+func (f *fakeWorktrees) Remove(ctx context.Context, path string) error {
+	delete(f.live, path)
+	return os.RemoveAll(path)
+}
+
+// stepNames returns the names of the steps in order
+func stepNames(report *Report) []string {
+	var names []string
+	for _, s := range report.Steps {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+// assertCleanedUp checks that nothing the self-test created is left behind
+func assertCleanedUp(t *testing.T, st *state.State, tmux *fakeTmux, wt *fakeWorktrees, tempDir string) {
+	t.Helper()
+	if repos := st.ListRepos(); len(repos) != 0 {
+		t.Errorf("repositories left in state: %v", repos)
+	}
+	if len(tmux.sessions) != 0 {
+		t.Errorf("tmux sessions left: %v", tmux.sessions)
+	}
+	if len(wt.live) != 0 {
+		t.Errorf("worktrees left: %v", wt.live)
+	}
+	if entries, _ := os.ReadDir(tempDir); len(entries) != 0 {
+		t.Errorf("scratch files left in %s: %v", tempDir, entries)
+	}
+}
+
+func TestRunHappyPath(t *testing.T) {
+	tempDir := t.TempDir()
+	st := state.New(filepath.Join(t.TempDir(), "state.json"))
+	tmux := newFakeTmux()
+	wt := &fakeWorktrees{live: make(map[string]bool)}
+
+	report := New(st, tmux, wt, tempDir).Run(context.Background())
+	if !report.OK {
+		t.Errorf("report not OK: %+v", report.Steps)
+	}
+
+	want := []string{
+		"create scratch directory",
+		"create worktree",
+		"create tmux session",
+		"create tmux window",
+		"register repository",
+		"register agent",
+		"verify agent in state",
+		"verify agent in tmux",
+		"remove from state",
+		"kill tmux session",
+		"remove worktree",
+		"remove scratch directory",
+	}
+	if got := stepNames(report); !reflect.DeepEqual(got, want) {
+		t.Errorf("steps = %v, want %v", got, want)
+	}
+	assertCleanedUp(t, st, tmux, wt, tempDir)
+}
+
+func TestRunCleansUpAfterFailure(t *testing.T) {
+	tempDir := t.TempDir()
+	st := state.New(filepath.Join(t.TempDir(), "state.json"))
+	tmux := newFakeTmux()
+	tmux.windowErr = errors.New("no server running")
+	wt := &fakeWorktrees{live: make(map[string]bool)}
+
+	report := New(st, tmux, wt, tempDir).Run(context.Background())
+	if report.OK {
+		t.Fatal("report OK despite a failed step")
+	}
+
+	want := []string{
+		"create scratch directory",
+		"create worktree",
+		"create tmux session",
+		"create tmux window",
+		"kill tmux session",
+		"remove worktree",
+		"remove scratch directory",
+	}
+	if got := stepNames(report); !reflect.DeepEqual(got, want) {
+		t.Errorf("steps = %v, want %v", got, want)
+	}
+	if failed := report.Steps[3]; failed.OK || failed.Error != "no server running" {
+		t.Errorf("window step = %+v, want the induced failure", failed)
+	}
+	for _, s := range report.Steps[4:] {
+		if !s.OK {
+			t.Errorf("teardown step %q failed: %s", s.Name, s.Error)
+		}
+	}
+	assertCleanedUp(t, st, tmux, wt, tempDir)
+}
+
+func TestGitWorktrees(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	g := GitWorktrees{Runner: command.ExecRunner{Timeout: command.DefaultTimeout}}
+
+	path, err := g.Create(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(path, ".git")); err != nil {
+		t.Fatalf("worktree has no .git: %v", err)
+	}
+
+	if err := g.Remove(context.Background(), path); err != nil {
+		t.Fatalf("Remove() failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("worktree still exists after Remove: %v", err)
+	}
+}
@@ -1,24 +1,99 @@
 package socket
 
 import (
+	"bufio"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
 )
 
+// PingCommand is answered by the server itself, without a Handler or a
+// concurrency slot, so a ping succeeds whenever the socket is being served,
+// even while every handler is busy. A server that requires a token checks
+// it before answering. The response data is "pong".
+const PingCommand = "ping"
+
+// TimeCommand is answered by the server itself, like PingCommand, with
+// TimeData
+const TimeCommand = "time"
+
+// TimeData is the data of a time response
+type TimeData struct {
+	Time time.Time `json:"time"` // When the server answered
+}
+
 // Request represents a request sent to the daemon
 type Request struct {
 	Command string                 `json:"command"`
 	Args    map[string]interface{} `json:"args,omitempty"`
+	Token   string                 `json:"token,omitempty"` // Client token; empty for full access
+	ID      string                 `json:"id,omitempty"`    // Correlates the request with its responses and log lines
+
+	// AcceptGzip tells the server the client can read a gzip-compressed
+	// response. Servers that predate it ignore it and reply uncompressed.
+	AcceptGzip bool `json:"accept_gzip,omitempty"`
 }
 
-// Response represents a response from the daemon
+// Response represents a response from the daemon.
+// Streaming commands send any number of Partial responses followed by a
+// single terminal (non-Partial) response.
 type Response struct {
 	Success bool        `json:"success"`
 	Data    interface{} `json:"data,omitempty"`
 	Error   string      `json:"error,omitempty"`
+	Code    string      `json:"code,omitempty"` // Machine-readable failure reason
+	Partial bool        `json:"partial,omitempty"`
+	ID      string      `json:"id,omitempty"` // The ID of the request this answers
+
+	// Keepalive marks a Partial response with no data, sent on a quiet
+	// stream so the server notices a client that has gone away. Clients
+	// skip it.
+	Keepalive bool `json:"keepalive,omitempty"`
+}
+
+// CodeUnauthorized marks a request the client's token does not permit
+const CodeUnauthorized = "unauthorized"
+
+// CodeNotFound marks a request naming something that does not exist
+const CodeNotFound = "not_found"
+
+// CodeConflict marks a request that clashes with existing state
+const CodeConflict = "conflict"
+
+// CodeBadRequest marks a request with missing or invalid arguments; sending
+// it again unchanged will fail again
+const CodeBadRequest = "bad_request"
+
+// CodeBusy marks a request refused for now, for example while the daemon
+// drains or another actor holds the agent; it may succeed if retried later
+const CodeBusy = "busy"
+
+// CodeInternal marks a request that failed inside the daemon, for example
+// when git or tmux returned an error
+const CodeInternal = "internal"
+
+// UnauthorizedResponse creates a failure response with CodeUnauthorized.
+// It supports printf-style formatting.
+func UnauthorizedResponse(format string, args ...interface{}) Response {
+	return CodedErrorResponse(CodeUnauthorized, format, args...)
+}
+
+// CodedErrorResponse creates a failure response with a machine-readable
+// code, one of the Code constants. It supports printf-style formatting.
+func CodedErrorResponse(code, format string, args ...interface{}) Response {
+	resp := ErrorResponse(format, args...)
+	resp.Code = code
+	return resp
 }
 
 // ErrorResponse creates a failure response with the given error message.
@@ -38,80 +113,476 @@ func SuccessResponse(data interface{}) Response {
 	}
 }
 
-// Client connects to the daemon via Unix socket
+// DefaultMaxMessageSize bounds a single request or response when
+// Server.MaxMessageSize or ClientOptions.MaxMessageSize is not set
+const DefaultMaxMessageSize = 16 << 20
+
+// ErrMessageTooLarge is returned when a request or response runs past the
+// maximum message size. Reading stops at the limit, so an oversized message
+// is never buffered whole.
+var ErrMessageTooLarge = errors.New("message exceeds maximum size")
+
+// Networks a Server or Client can use. NetworkUnix is the default.
+const (
+	NetworkUnix = "unix"
+	NetworkTCP  = "tcp"
+)
+
+// ErrTokenRequired is returned by Server.Start for a TCP server that does
+// not require tokens. Anyone who can reach the port could otherwise drive
+// the daemon; a Unix socket is protected by its file permissions instead.
+var ErrTokenRequired = errors.New("tcp transport requires client tokens")
+
+// checkNetwork returns an error for a network other than unix or tcp
+func checkNetwork(network string) error {
+	if network != NetworkUnix && network != NetworkTCP {
+		return fmt.Errorf("unsupported network %q: want %q or %q", network, NetworkUnix, NetworkTCP)
+	}
+	return nil
+}
+
+// Client connects to the daemon via a Unix socket or TCP
 type Client struct {
-	socketPath string
+	network        string
+	address        string
+	token          string
+	retry          RetryPolicy
+	maxMessageSize int64
+	noCompression  bool
+}
+
+// RetryPolicy controls how a client retries connecting while the daemon's
+// socket is missing or refusing connections, as it is while the daemon
+// restarts. Only establishing the connection is retried; a request that
+// fails after it was sent is never resent.
+type RetryPolicy struct {
+	// MaxElapsed bounds the total time spent retrying. Zero disables retries.
+	MaxElapsed time.Duration
+	// InitialInterval is the first wait between attempts. Each later wait
+	// doubles, up to MaxInterval. Zero values use DefaultRetryPolicy's.
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
 }
 
-// NewClient creates a new socket client
+// NoRetry makes a single connection attempt. It is what NewClient uses.
+var NoRetry = RetryPolicy{}
+
+// DefaultRetryPolicy waits up to 5 seconds for the daemon's socket
+var DefaultRetryPolicy = RetryPolicy{
+	MaxElapsed:      5 * time.Second,
+	InitialInterval: 50 * time.Millisecond,
+	MaxInterval:     time.Second,
+}
+
+// ClientOptions configures a client made by NewClientWithOptions
+type ClientOptions struct {
+	// Token is sent with every request that does not carry its own
+	Token string
+	// Retry is the policy for connecting; the zero value is NoRetry
+	Retry RetryPolicy
+	// MaxMessageSize bounds each response in bytes. Zero means
+	// DefaultMaxMessageSize.
+	MaxMessageSize int64
+	// DisableCompression stops the client asking for compressed responses
+	DisableCompression bool
+}
+
+// NewClient creates a new socket client that does not retry connecting
 func NewClient(socketPath string) *Client {
-	return &Client{socketPath: socketPath}
+	return &Client{network: NetworkUnix, address: socketPath}
+}
+
+// NewClientWithOptions creates a new socket client configured by opts
+func NewClientWithOptions(socketPath string, opts ClientOptions) *Client {
+	return NewNetworkClient(NetworkUnix, socketPath, opts)
+}
+
+// NewNetworkClient creates a client that connects to address over network,
+// NetworkUnix or NetworkTCP. For TCP, address is a "host:port".
+func NewNetworkClient(network, address string, opts ClientOptions) *Client {
+	return &Client{
+		network:        network,
+		address:        address,
+		token:          opts.Token,
+		retry:          opts.Retry,
+		maxMessageSize: opts.MaxMessageSize,
+		noCompression:  opts.DisableCompression,
+	}
+}
+
+// NewRequestID returns a new random request ID
+func NewRequestID() string {
+	return uuid.NewString()
+}
+
+// WithToken returns a copy of the client that sends token with every
+// request that does not carry its own
+func (c *Client) WithToken(token string) *Client {
+	clone := *c
+	clone.token = token
+	return &clone
+}
+
+// dial connects to the daemon, retrying per the client's policy while the
+// socket is missing or refusing connections
+func (c *Client) dial(ctx context.Context) (net.Conn, error) {
+	if err := checkNetwork(c.network); err != nil {
+		return nil, err
+	}
+	policy := c.retry
+	if policy.InitialInterval <= 0 {
+		policy.InitialInterval = DefaultRetryPolicy.InitialInterval
+	}
+	if policy.MaxInterval <= 0 {
+		policy.MaxInterval = DefaultRetryPolicy.MaxInterval
+	}
+
+	var dialer net.Dialer
+	deadline := time.Now().Add(policy.MaxElapsed)
+	wait := policy.InitialInterval
+	for {
+		conn, err := dialer.DialContext(ctx, c.network, c.address)
+		if err == nil || !retryableDialError(err) {
+			return conn, err
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, err
+		}
+
+		timer := time.NewTimer(min(wait, remaining))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+		wait = min(wait*2, policy.MaxInterval)
+	}
+}
+
+// retryableDialError reports whether err means the daemon's socket is not
+// ready yet, rather than unusable
+func retryableDialError(err error) bool {
+	return errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.ECONNREFUSED)
 }
 
-// Send sends a request to the daemon and returns the response
+// Send sends a request to the daemon and returns the response. It waits for
+// as long as the daemon takes to reply; use SendContext to bound the wait.
 func (c *Client) Send(req Request) (*Response, error) {
-	conn, err := net.Dial("unix", c.socketPath)
+	return c.SendContext(context.Background(), req)
+}
+
+// SendContext sends a request to the daemon and returns the response. If ctx
+// is cancelled or its deadline passes before the reply arrives, the
+// connection is closed and the returned error wraps ctx.Err().
+func (c *Client) SendContext(ctx context.Context, req Request) (*Response, error) {
+	conn, err := c.dial(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to daemon: %w", err)
 	}
 	defer conn.Close()
 
+	// Closing the connection unblocks Encode and Decode when ctx is done
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	c.prepare(&req)
+	req.AcceptGzip = !c.noCompression
+
 	// Send request
 	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("failed to send request: %w", ctx.Err())
+		}
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 
 	// Read response
-	var resp Response
-	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+	resp, err := readResponse(conn, c.maxMessageSize)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("no response from daemon: %w", ctx.Err())
+		}
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
+	return resp, nil
+}
+
+// readResponse decodes a single response. Its first byte says whether it
+// is compressed: a gzip stream starts with 0x1f, a plain JSON response
+// with '{'. The size limit applies to the decompressed response.
+func readResponse(r io.Reader, limit int64) (*Response, error) {
+	br := bufio.NewReader(r)
+	var body io.Reader = br
+	if first, err := br.Peek(1); err == nil && first[0] == gzipMagic {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		body = zr
+	}
+
+	var resp Response
+	if err := newDecoder(body, limit).Decode(&resp); err != nil {
+		return nil, err
+	}
 	return &resp, nil
 }
 
-// Server listens on a Unix socket for requests
+// SendStream sends a streaming request to the daemon. onMsg is called for each
+// partial response in order. It returns the terminal response once the stream
+// ends. If onMsg returns an error or ctx is cancelled, the connection is closed,
+// which tells the server to stop streaming.
+func (c *Client) SendStream(ctx context.Context, req Request, onMsg func(Response) error) (*Response, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to daemon: %w", err)
+	}
+	defer conn.Close()
+
+	// Closing the connection unblocks Decode when ctx is cancelled
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	c.prepare(&req)
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	dec := newDecoder(conn, c.maxMessageSize)
+	for {
+		var resp Response
+		if err := dec.Decode(&resp); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+		if !resp.Partial {
+			return &resp, nil
+		}
+		if resp.Keepalive {
+			continue
+		}
+		if err := onMsg(resp); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Ping pings the server and returns the round-trip time
+func (c *Client) Ping() (time.Duration, error) {
+	return c.PingContext(context.Background())
+}
+
+// PingContext pings the server and returns the round-trip time. Use a
+// deadline on ctx to bound how long a health check waits.
+func (c *Client) PingContext(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+	resp, err := c.SendContext(ctx, Request{Command: PingCommand})
+	if err != nil {
+		return 0, err
+	}
+	if !resp.Success {
+		return 0, fmt.Errorf("ping failed: %s", resp.Error)
+	}
+	return time.Since(start), nil
+}
+
+// prepare fills in the client's token and a fresh ID where the request
+// leaves them empty
+func (c *Client) prepare(req *Request) {
+	if req.Token == "" {
+		req.Token = c.token
+	}
+	if req.ID == "" {
+		req.ID = NewRequestID()
+	}
+}
+
+// DefaultShutdownTimeout is how long Stop waits for in-flight requests when
+// Server.ShutdownTimeout is not set
+const DefaultShutdownTimeout = 5 * time.Second
+
+// DefaultIdleTimeout is how long a connection may stall when
+// Server.IdleTimeout is not set
+const DefaultIdleTimeout = 2 * time.Minute
+
+// DefaultKeepaliveInterval is how often a quiet stream sends a keepalive
+// when Server.KeepaliveInterval is not set
+const DefaultKeepaliveInterval = 30 * time.Second
+
+// DefaultCompressThreshold is the encoded size above which a response is
+// compressed, for clients that accept it, when Server.CompressThreshold is
+// not set
+const DefaultCompressThreshold = 32 << 10
+
+// gzipMagic is the first byte of a gzip stream
+const gzipMagic = 0x1f
+
+// DefaultMaxConcurrency is how many requests run at once when
+// Server.MaxConcurrency is not set
+const DefaultMaxConcurrency = 32
+
+// Server listens on a Unix socket, or a TCP port, for requests
 type Server struct {
-	socketPath string
-	listener   net.Listener
-	handler    Handler
+	// ShutdownTimeout bounds how long Stop waits for in-flight requests to
+	// finish before abandoning them. Zero means DefaultShutdownTimeout.
+	ShutdownTimeout time.Duration
+
+	// IdleTimeout bounds how long a connection may stall: waiting for the
+	// client's request, or blocked writing a response the client is not
+	// reading. Stalled connections are closed; a stream then ends as if the
+	// client had disconnected. A quiet stream with a live client is never
+	// reaped. Zero means DefaultIdleTimeout.
+	IdleTimeout time.Duration
+
+	// KeepaliveInterval is how often a stream that has sent nothing else
+	// sends a Keepalive response. A client that vanished without closing
+	// its end, such as a remote host that dropped off the network, then
+	// fails a write and its stream ends. Zero means
+	// DefaultKeepaliveInterval.
+	KeepaliveInterval time.Duration
+
+	// MaxConcurrency bounds how many requests run their Handler at once.
+	// Further requests queue until a slot frees up. Streams do not take a
+	// slot, since a followed log can stay open for hours. Zero means
+	// DefaultMaxConcurrency. Set it before calling Serve.
+	MaxConcurrency int
+
+	// MaxMessageSize bounds a request in bytes. Larger requests are
+	// rejected with CodeBadRequest. Zero means DefaultMaxMessageSize.
+	MaxMessageSize int64
+
+	// CompressThreshold is the encoded size in bytes above which a
+	// response is gzip-compressed for a client that set AcceptGzip. Zero
+	// means DefaultCompressThreshold; a negative value never compresses.
+	// Stream responses are never compressed.
+	CompressThreshold int
+
+	// RequireToken rejects requests that carry no token, or a token that
+	// Authorize refuses, with CodeUnauthorized before they are answered,
+	// pings included. It must be set to listen on TCP. Once the server is
+	// running, change it with SetRequireToken.
+	RequireToken bool
+
+	// Authorize reports whether a request's token is valid. It is only
+	// consulted when RequireToken is set; nil accepts any token. Checking
+	// what a token may do is still the handler's job.
+	Authorize func(token string) bool
+
+	network  string
+	address  string
+	listener net.Listener
+	handler  Handler
+
+	mu       sync.RWMutex
+	streams  map[string]StreamHandler
+	cancel   context.CancelFunc // Cancels stream handlers; set by Serve
+	stopping bool
+
+	active  sync.WaitGroup // Connections being handled
+	aborted chan struct{}  // Closed when Stop gives up waiting
+	slots   chan struct{}  // Holds a token per running Handler; set by Serve
 }
 
-// Handler processes requests
+// Handler processes requests. ctx is cancelled when the server stops.
 type Handler interface {
-	Handle(req Request) Response
+	Handle(ctx context.Context, req Request) Response
 }
 
 // HandlerFunc is an adapter to allow functions to be used as handlers
-type HandlerFunc func(Request) Response
+type HandlerFunc func(ctx context.Context, req Request) Response
 
 // Handle implements the Handler interface
-func (f HandlerFunc) Handle(req Request) Response {
-	return f(req)
+func (f HandlerFunc) Handle(ctx context.Context, req Request) Response {
+	return f(ctx, req)
+}
+
+// StreamHandler processes a request that produces a stream of responses.
+// Each call to send delivers a partial response to the client. ctx is
+// cancelled when the client disconnects or the server stops. Returning nil ends the stream with a
+// success response; returning an error ends it with an error response.
+type StreamHandler interface {
+	HandleStream(ctx context.Context, req Request, send func(Response) error) error
 }
 
-// NewServer creates a new socket server
+// StreamHandlerFunc is an adapter to allow functions to be used as stream handlers
+type StreamHandlerFunc func(ctx context.Context, req Request, send func(Response) error) error
+
+// HandleStream implements the StreamHandler interface
+func (f StreamHandlerFunc) HandleStream(ctx context.Context, req Request, send func(Response) error) error {
+	return f(ctx, req, send)
+}
+
+// NewServer creates a new socket server listening on a Unix socket
 func NewServer(socketPath string, handler Handler) *Server {
+	return NewNetworkServer(NetworkUnix, socketPath, handler)
+}
+
+// NewNetworkServer creates a server listening on address over network,
+// NetworkUnix or NetworkTCP. For TCP, address is a "host:port"; a port of 0
+// picks a free one, which Addr reports once started. Requests and responses
+// are framed the same way on both.
+func NewNetworkServer(network, address string, handler Handler) *Server {
 	return &Server{
-		socketPath: socketPath,
-		handler:    handler,
+		network: network,
+		address: address,
+		handler: handler,
+		aborted: make(chan struct{}),
 	}
 }
 
+// HandleStream registers a streaming handler for command. Requests for
+// command are routed to h instead of the server's Handler.
+func (s *Server) HandleStream(command string, h StreamHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.streams == nil {
+		s.streams = make(map[string]StreamHandler)
+	}
+	s.streams[command] = h
+}
+
+// SetRequireToken sets RequireToken on a server that may be serving requests
+func (s *Server) SetRequireToken(require bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.RequireToken = require
+}
+
 // Start starts the socket server
 func (s *Server) Start() error {
+	if err := checkNetwork(s.network); err != nil {
+		return err
+	}
+	if s.network == NetworkTCP {
+		if !s.RequireToken {
+			return ErrTokenRequired
+		}
+		listener, err := net.Listen(NetworkTCP, s.address)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", s.address, err)
+		}
+		s.listener = listener
+		return nil
+	}
+
 	// Remove stale socket file if exists
-	if err := os.Remove(s.socketPath); err != nil && !os.IsNotExist(err) {
+	if err := os.Remove(s.address); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to remove stale socket: %w", err)
 	}
 
-	listener, err := net.Listen("unix", s.socketPath)
+	listener, err := net.Listen(NetworkUnix, s.address)
 	if err != nil {
 		return fmt.Errorf("failed to listen on socket: %w", err)
 	}
 
 	// Set permissions
-	if err := os.Chmod(s.socketPath, 0600); err != nil {
+	if err := os.Chmod(s.address, 0600); err != nil {
 		listener.Close()
 		return fmt.Errorf("failed to set socket permissions: %w", err)
 	}
@@ -120,53 +591,363 @@ func (s *Server) Start() error {
 	return nil
 }
 
-// Serve accepts and handles connections
-func (s *Server) Serve() error {
+// Addr returns the address the server is listening on, or nil before Start
+func (s *Server) Addr() net.Addr {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Serve accepts and handles connections, each in its own goroutine, running
+// at most MaxConcurrency handlers at once. Handlers run under a context
+// derived from ctx, which is cancelled when ctx is or when Stop is called.
+func (s *Server) Serve(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	limit := s.MaxConcurrency
+	if limit <= 0 {
+		limit = DefaultMaxConcurrency
+	}
+	s.mu.Lock()
+	s.cancel = cancel
+	s.slots = make(chan struct{}, limit)
+	s.mu.Unlock()
+
 	for {
 		conn, err := s.listener.Accept()
 		if err != nil {
 			return fmt.Errorf("failed to accept connection: %w", err)
 		}
 
-		go s.handleConnection(conn)
+		// Register the connection under the lock so Stop cannot start
+		// waiting between the check and the Add
+		s.mu.Lock()
+		if s.stopping {
+			s.mu.Unlock()
+			conn.Close()
+			continue
+		}
+		s.active.Add(1)
+		s.mu.Unlock()
+
+		go func() {
+			defer s.active.Done()
+			s.handleConnection(ctx, conn)
+		}()
 	}
 }
 
-// Stop stops the server
+// Stop shuts the server down gracefully. It stops accepting connections,
+// cancels the context handlers run under, and waits up to ShutdownTimeout for in-flight
+// requests to send their responses. Requests still running after that are
+// abandoned and their connections closed.
 func (s *Server) Stop() error {
+	s.mu.Lock()
+	alreadyStopping := s.stopping
+	s.stopping = true
+	cancel := s.cancel
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+
 	if s.listener != nil {
 		if err := s.listener.Close(); err != nil {
 			return err
 		}
 	}
 
+	if !alreadyStopping {
+		s.drain()
+	}
+
 	// Remove socket file
-	if err := os.Remove(s.socketPath); err != nil && !os.IsNotExist(err) {
-		return err
+	if s.network == NetworkUnix {
+		if err := os.Remove(s.address); err != nil && !os.IsNotExist(err) {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// drain waits for active connections to finish, aborting them if they
+// outlast the shutdown timeout
+func (s *Server) drain() {
+	done := make(chan struct{})
+	go func() {
+		s.active.Wait()
+		close(done)
+	}()
+
+	timeout := s.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		close(s.aborted)
+	}
+}
+
 // handleConnection handles a single connection
-func (s *Server) handleConnection(conn net.Conn) {
+func (s *Server) handleConnection(ctx context.Context, conn net.Conn) {
 	defer conn.Close()
 
+	// A client that connects but never sends its request is reaped
+	conn.SetReadDeadline(time.Now().Add(s.idleTimeout()))
 	var req Request
-	if err := json.NewDecoder(conn).Decode(&req); err != nil {
-		if err != io.EOF {
-			resp := Response{
-				Success: false,
-				Error:   fmt.Sprintf("failed to decode request: %v", err),
-			}
-			json.NewEncoder(conn).Encode(resp)
+	if err := newDecoder(conn, s.MaxMessageSize).Decode(&req); err != nil {
+		if errors.Is(err, ErrMessageTooLarge) {
+			s.reply(conn, CodedErrorResponse(CodeBadRequest, "request exceeds the %d byte limit", maxMessageSize(s.MaxMessageSize)))
+			return
 		}
+		if err != io.EOF && !errors.Is(err, os.ErrDeadlineExceeded) {
+			s.reply(conn, CodedErrorResponse(CodeBadRequest, "failed to decode request: %v", err))
+		}
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	s.mu.RLock()
+	requireToken := s.RequireToken
+	s.mu.RUnlock()
+	if requireToken && (req.Token == "" || (s.Authorize != nil && !s.Authorize(req.Token))) {
+		resp := UnauthorizedResponse("a valid client token is required")
+		resp.ID = req.ID
+		s.reply(conn, resp)
+		return
+	}
+
+	switch req.Command {
+	case PingCommand:
+		resp := SuccessResponse("pong")
+		resp.ID = req.ID
+		s.reply(conn, resp)
+		return
+	case TimeCommand:
+		resp := SuccessResponse(TimeData{Time: time.Now()})
+		resp.ID = req.ID
+		s.reply(conn, resp)
 		return
 	}
 
-	resp := s.handler.Handle(req)
-	if err := json.NewEncoder(conn).Encode(resp); err != nil {
-		// Can't send error response at this point
+	s.mu.RLock()
+	stream, isStream := s.streams[req.Command]
+	s.mu.RUnlock()
+	if isStream {
+		s.serveStream(ctx, conn, req, stream)
 		return
 	}
+
+	// Queue for a slot; a request still queued when Stop gives up is dropped
+	select {
+	case s.slots <- struct{}{}:
+	case <-s.aborted:
+		return
+	}
+
+	// Stop cancels ctx, but a handler that ignores it and outlasts Stop's
+	// timeout is abandoned: the connection is closed and the eventual
+	// response discarded. Its slot is held until the handler actually returns.
+	done := make(chan Response, 1)
+	go func() {
+		defer func() { <-s.slots }()
+		done <- s.handler.Handle(ctx, req)
+	}()
+
+	var resp Response
+	select {
+	case resp = <-done:
+	case <-s.aborted:
+		return
+	}
+	resp.ID = req.ID
+	if req.AcceptGzip {
+		s.replyCompressed(conn, resp)
+		return
+	}
+	s.reply(conn, resp)
+}
+
+// replyCompressed writes resp to conn, gzip-compressed if it is larger than
+// the compression threshold
+func (s *Server) replyCompressed(conn net.Conn, resp Response) error {
+	threshold := s.CompressThreshold
+	if threshold == 0 {
+		threshold = DefaultCompressThreshold
+	}
+	data, err := json.Marshal(resp)
+	if err != nil || threshold < 0 || len(data) <= threshold {
+		return s.reply(conn, resp)
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(s.idleTimeout()))
+	zw := gzip.NewWriter(conn)
+	if _, err := zw.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// reply writes resp to conn, giving up if the client does not read it
+// within the idle timeout. Errors are dropped: there is nobody to tell.
+func (s *Server) reply(conn net.Conn, resp Response) error {
+	conn.SetWriteDeadline(time.Now().Add(s.idleTimeout()))
+	return json.NewEncoder(conn).Encode(resp)
+}
+
+// idleTimeout returns IdleTimeout or its default
+func (s *Server) idleTimeout() time.Duration {
+	if s.IdleTimeout > 0 {
+		return s.IdleTimeout
+	}
+	return DefaultIdleTimeout
+}
+
+// keepaliveInterval returns KeepaliveInterval or its default
+func (s *Server) keepaliveInterval() time.Duration {
+	if s.KeepaliveInterval > 0 {
+		return s.KeepaliveInterval
+	}
+	return DefaultKeepaliveInterval
+}
+
+// serveStream runs a stream handler, cancelling its context when the client
+// disconnects or the server stops, and finishes the stream with a terminal
+// response.
+func (s *Server) serveStream(ctx context.Context, conn net.Conn, req Request, h StreamHandler) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	disconnected := make(chan struct{})
+	var hangUp sync.Once
+	disconnect := func() {
+		hangUp.Do(func() {
+			close(disconnected)
+			cancel()
+		})
+	}
+
+	// Clients send nothing after the request, so any read completing means
+	// the client has closed its end of the connection
+	go func() {
+		io.Copy(io.Discard, conn)
+		disconnect()
+	}()
+
+	// The handler and the keepalive both write; a failed write means the
+	// client is gone
+	var writeMu sync.Mutex
+	var lastWrite time.Time
+	write := func(resp Response) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		resp.Partial = true
+		resp.ID = req.ID
+		if err := s.reply(conn, resp); err != nil {
+			disconnect()
+			return err
+		}
+		lastWrite = time.Now()
+		return nil
+	}
+	send := func(resp Response) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return write(resp)
+	}
+
+	keepaliveCtx, stopKeepalive := context.WithCancel(ctx)
+	keepaliveDone := make(chan struct{})
+	go func() {
+		defer close(keepaliveDone)
+		interval := s.keepaliveInterval()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-keepaliveCtx.Done():
+				return
+			case <-ticker.C:
+				writeMu.Lock()
+				quiet := time.Since(lastWrite) >= interval
+				writeMu.Unlock()
+				if quiet && write(Response{Success: true, Keepalive: true}) != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	err := h.HandleStream(ctx, req, send)
+	stopKeepalive()
+	<-keepaliveDone
+
+	select {
+	case <-disconnected:
+		// Client is gone; nobody to report to
+		return
+	default:
+	}
+	final := SuccessResponse(nil)
+	if err != nil {
+		final = ErrorResponse("%v", err)
+	}
+	final.ID = req.ID
+	s.reply(conn, final)
+}
+
+// maxMessageSize returns limit or its default
+func maxMessageSize(limit int64) int64 {
+	if limit > 0 {
+		return limit
+	}
+	return DefaultMaxMessageSize
+}
+
+// decoder reads a sequence of JSON messages, failing with
+// ErrMessageTooLarge once one runs past its size limit
+type decoder struct {
+	dec *json.Decoder
+	r   *limitReader
+}
+
+// newDecoder returns a decoder reading messages of at most limit bytes from
+// r. A zero limit means DefaultMaxMessageSize.
+func newDecoder(r io.Reader, limit int64) *decoder {
+	lr := &limitReader{r: r, limit: maxMessageSize(limit)}
+	return &decoder{dec: json.NewDecoder(lr), r: lr}
+}
+
+// Decode reads the next message into v
+func (d *decoder) Decode(v interface{}) error {
+	// Bytes the json.Decoder read ahead past the previous message belong
+	// to this one, so count from where that message ended
+	d.r.start = d.dec.InputOffset()
+	return d.dec.Decode(v)
+}
+
+// limitReader stops reading once the current message has used its limit
+type limitReader struct {
+	r     io.Reader
+	limit int64
+	read  int64 // Bytes read from r in total
+	start int64 // Offset where the current message begins
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	remaining := l.limit - (l.read - l.start)
+	if remaining <= 0 {
+		return 0, ErrMessageTooLarge
+	}
+	if int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	return n, err
 }
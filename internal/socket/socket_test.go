@@ -1,10 +1,16 @@
 package socket
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -14,7 +20,7 @@ func TestClientServerCommunication(t *testing.T) {
 	sockPath := filepath.Join(tmpDir, "test.sock")
 
 	// Create handler
-	handler := HandlerFunc(func(req Request) Response {
+	handler := HandlerFunc(func(_ context.Context, req Request) Response {
 		if req.Command == "test" {
 			return Response{
 				Success: true,
@@ -37,7 +43,7 @@ func TestClientServerCommunication(t *testing.T) {
 	// Run server in background
 	errCh := make(chan error, 1)
 	go func() {
-		errCh <- server.Serve()
+		errCh <- server.Serve(context.Background())
 	}()
 
 	// Give server time to start
@@ -87,7 +93,7 @@ func TestServerMultipleRequests(t *testing.T) {
 
 	// Create counter handler
 	counter := 0
-	handler := HandlerFunc(func(req Request) Response {
+	handler := HandlerFunc(func(_ context.Context, req Request) Response {
 		counter++
 		return Response{
 			Success: true,
@@ -102,7 +108,7 @@ func TestServerMultipleRequests(t *testing.T) {
 	}
 	defer server.Stop()
 
-	go server.Serve()
+	go server.Serve(context.Background())
 	time.Sleep(100 * time.Millisecond)
 
 	// Send multiple requests
@@ -130,7 +136,7 @@ func TestServerErrorResponse(t *testing.T) {
 	sockPath := filepath.Join(tmpDir, "test.sock")
 
 	// Create handler that returns error
-	handler := HandlerFunc(func(req Request) Response {
+	handler := HandlerFunc(func(_ context.Context, req Request) Response {
 		return Response{
 			Success: false,
 			Error:   "something went wrong",
@@ -144,7 +150,7 @@ func TestServerErrorResponse(t *testing.T) {
 	}
 	defer server.Stop()
 
-	go server.Serve()
+	go server.Serve(context.Background())
 	time.Sleep(100 * time.Millisecond)
 
 	// Send request
@@ -182,7 +188,7 @@ func TestServerRequestWithArgs(t *testing.T) {
 	sockPath := filepath.Join(tmpDir, "test.sock")
 
 	// Create handler that echoes args
-	handler := HandlerFunc(func(req Request) Response {
+	handler := HandlerFunc(func(_ context.Context, req Request) Response {
 		if name, ok := req.Args["name"].(string); ok {
 			return Response{
 				Success: true,
@@ -202,7 +208,7 @@ func TestServerRequestWithArgs(t *testing.T) {
 	}
 	defer server.Stop()
 
-	go server.Serve()
+	go server.Serve(context.Background())
 	time.Sleep(100 * time.Millisecond)
 
 	// Send request with args
@@ -238,7 +244,7 @@ func TestServerStaleSocket(t *testing.T) {
 	}
 
 	// Server should remove stale socket and start successfully
-	handler := HandlerFunc(func(req Request) Response {
+	handler := HandlerFunc(func(_ context.Context, req Request) Response {
 		return Response{Success: true}
 	})
 
@@ -254,7 +260,7 @@ func TestServerInvalidJSON(t *testing.T) {
 	sockPath := filepath.Join(tmpDir, "test.sock")
 
 	// Create handler
-	handler := HandlerFunc(func(req Request) Response {
+	handler := HandlerFunc(func(_ context.Context, req Request) Response {
 		return Response{Success: true}
 	})
 
@@ -265,7 +271,7 @@ func TestServerInvalidJSON(t *testing.T) {
 	}
 	defer server.Stop()
 
-	go server.Serve()
+	go server.Serve(context.Background())
 	time.Sleep(100 * time.Millisecond)
 
 	// Send invalid JSON directly
@@ -300,13 +306,16 @@ func TestServerInvalidJSON(t *testing.T) {
 	if resp.Error == "" {
 		t.Error("Expected error message in response")
 	}
+	if resp.Code != CodeBadRequest {
+		t.Errorf("Code = %q, want %q", resp.Code, CodeBadRequest)
+	}
 }
 
 func TestServerStopWithNilListener(t *testing.T) {
 	tmpDir := t.TempDir()
 	sockPath := filepath.Join(tmpDir, "test.sock")
 
-	handler := HandlerFunc(func(req Request) Response {
+	handler := HandlerFunc(func(_ context.Context, req Request) Response {
 		return Response{Success: true}
 	})
 
@@ -323,7 +332,7 @@ func TestServerStopRemovesSocket(t *testing.T) {
 	tmpDir := t.TempDir()
 	sockPath := filepath.Join(tmpDir, "test.sock")
 
-	handler := HandlerFunc(func(req Request) Response {
+	handler := HandlerFunc(func(_ context.Context, req Request) Response {
 		return Response{Success: true}
 	})
 
@@ -346,3 +355,1038 @@ func TestServerStopRemovesSocket(t *testing.T) {
 		t.Error("Socket file should be removed after Stop()")
 	}
 }
+
+func TestServerStream(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "test.sock")
+
+	server := NewServer(sockPath, HandlerFunc(func(_ context.Context, req Request) Response {
+		return ErrorResponse("not a stream")
+	}))
+	server.HandleStream("count", StreamHandlerFunc(func(ctx context.Context, req Request, send func(Response) error) error {
+		for i := 1; i <= 3; i++ {
+			if err := send(SuccessResponse(float64(i))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	server.HandleStream("fail", StreamHandlerFunc(func(ctx context.Context, req Request, send func(Response) error) error {
+		return errors.New("boom")
+	}))
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer server.Stop()
+	go server.Serve(context.Background())
+
+	client := NewClient(sockPath)
+
+	var got []float64
+	resp, err := client.SendStream(context.Background(), Request{Command: "count"}, func(r Response) error {
+		if !r.Partial {
+			t.Error("streamed response should be marked partial")
+		}
+		got = append(got, r.Data.(float64))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("SendStream() failed: %v", err)
+	}
+	if !resp.Success || resp.Partial {
+		t.Errorf("terminal response = %+v, want success and not partial", resp)
+	}
+	if len(got) != 3 || got[0] != 1 || got[1] != 2 || got[2] != 3 {
+		t.Errorf("streamed data = %v, want [1 2 3]", got)
+	}
+
+	resp, err = client.SendStream(context.Background(), Request{Command: "fail"}, func(Response) error { return nil })
+	if err != nil {
+		t.Fatalf("SendStream() failed: %v", err)
+	}
+	if resp.Success || resp.Error != "boom" {
+		t.Errorf("terminal response = %+v, want error boom", resp)
+	}
+
+	// Non-stream commands still go to the regular handler
+	resp, err = client.Send(Request{Command: "other"})
+	if err != nil {
+		t.Fatalf("Send() failed: %v", err)
+	}
+	if resp.Success {
+		t.Error("regular handler should have handled the request")
+	}
+}
+
+func TestServerStreamClientDisconnect(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "test.sock")
+
+	stopped := make(chan struct{})
+	server := NewServer(sockPath, HandlerFunc(func(_ context.Context, req Request) Response { return Response{} }))
+	server.HandleStream("forever", StreamHandlerFunc(func(ctx context.Context, req Request, send func(Response) error) error {
+		defer close(stopped)
+		send(SuccessResponse("started"))
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer server.Stop()
+	go server.Serve(context.Background())
+
+	client := NewClient(sockPath)
+	disconnect := errors.New("disconnect")
+	_, err := client.SendStream(context.Background(), Request{Command: "forever"}, func(Response) error {
+		return disconnect
+	})
+	if !errors.Is(err, disconnect) {
+		t.Fatalf("SendStream() error = %v, want %v", err, disconnect)
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(3 * time.Second):
+		t.Fatal("stream handler was not cancelled after client disconnected")
+	}
+}
+
+func TestClientSendContextDeadline(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "test.sock")
+
+	release := make(chan struct{})
+	server := NewServer(sockPath, HandlerFunc(func(_ context.Context, req Request) Response {
+		select {
+		case <-release:
+		case <-time.After(5 * time.Second):
+		}
+		return SuccessResponse("too late")
+	}))
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer server.Stop()
+	defer close(release)
+	go server.Serve(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := NewClient(sockPath).SendContext(ctx, Request{Command: "slow"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("SendContext() = %+v, %v, want context.DeadlineExceeded", resp, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("SendContext() returned after %v, want shortly after the deadline", elapsed)
+	}
+}
+
+func TestServerStreamKeepalive(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "test.sock")
+
+	server := NewServer(sockPath, HandlerFunc(func(_ context.Context, req Request) Response {
+		return SuccessResponse(nil)
+	}))
+	server.KeepaliveInterval = 10 * time.Millisecond
+	server.HandleStream("quiet", StreamHandlerFunc(func(ctx context.Context, req Request, send func(Response) error) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+		return send(SuccessResponse("done"))
+	}))
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer server.Stop()
+	go server.Serve(context.Background())
+
+	// A quiet stream sends keepalives on the wire
+	conn, err := net.Dial("unix", sockPath)
+	if err != nil {
+		t.Fatalf("Dial() failed: %v", err)
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(Request{Command: "quiet", ID: "q-1"}); err != nil {
+		t.Fatalf("Encode() failed: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var first Response
+	if err := json.NewDecoder(conn).Decode(&first); err != nil {
+		t.Fatalf("Decode() failed: %v", err)
+	}
+	if !first.Keepalive || !first.Partial || first.ID != "q-1" || first.Data != nil {
+		t.Errorf("first response = %+v, want a partial keepalive for q-1", first)
+	}
+
+	// Clients skip them
+	var got []interface{}
+	resp, err := NewClient(sockPath).SendStream(context.Background(), Request{Command: "quiet"}, func(r Response) error {
+		got = append(got, r.Data)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("SendStream() failed: %v", err)
+	}
+	if !resp.Success || len(got) != 1 || got[0] != "done" {
+		t.Errorf("SendStream() = %+v with data %v, want success with [done]", resp, got)
+	}
+}
+
+func TestServerStopCancelsInFlightStream(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "test.sock")
+
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+	server := NewServer(sockPath, HandlerFunc(func(_ context.Context, req Request) Response { return Response{} }))
+	server.HandleStream("forever", StreamHandlerFunc(func(ctx context.Context, req Request, send func(Response) error) error {
+		defer close(stopped)
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	go server.Serve(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go NewClient(sockPath).SendStream(ctx, Request{Command: "forever"}, func(Response) error { return nil })
+
+	select {
+	case <-started:
+	case <-time.After(3 * time.Second):
+		t.Fatal("stream handler did not start")
+	}
+	if err := server.Stop(); err != nil {
+		t.Fatalf("Stop() failed: %v", err)
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(3 * time.Second):
+		t.Fatal("stream handler was not cancelled by Stop")
+	}
+}
+
+func TestServerStopCancelsInFlightHandler(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "test.sock")
+
+	started := make(chan struct{})
+	cancelled := make(chan error, 1)
+	server := NewServer(sockPath, HandlerFunc(func(ctx context.Context, req Request) Response {
+		close(started)
+		select {
+		case <-ctx.Done():
+			cancelled <- ctx.Err()
+		case <-time.After(5 * time.Second):
+			cancelled <- nil
+		}
+		return ErrorResponse("stopped")
+	}))
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	go server.Serve(context.Background())
+
+	go NewClient(sockPath).Send(Request{Command: "sleep"})
+
+	select {
+	case <-started:
+	case <-time.After(3 * time.Second):
+		t.Fatal("handler did not start")
+	}
+	start := time.Now()
+	if err := server.Stop(); err != nil {
+		t.Fatalf("Stop() failed: %v", err)
+	}
+
+	if err := <-cancelled; !errors.Is(err, context.Canceled) {
+		t.Errorf("handler context error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Stop() took %v, want the cancelled handler to finish promptly", elapsed)
+	}
+}
+
+func TestServerEchoesRequestID(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "test.sock")
+
+	server := NewServer(sockPath, HandlerFunc(func(_ context.Context, req Request) Response {
+		// Stagger replies so responses finish out of order
+		time.Sleep(time.Duration(len(req.Args)) * 10 * time.Millisecond)
+		return SuccessResponse(req.Command)
+	}))
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer server.Stop()
+	go server.Serve(context.Background())
+
+	client := NewClient(sockPath)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			args := make(map[string]interface{})
+			for j := 0; j < 5-i; j++ {
+				args[string(rune('a'+j))] = j
+			}
+			id := NewRequestID()
+			resp, err := client.Send(Request{Command: "test", Args: args, ID: id})
+			if err != nil {
+				t.Errorf("Send() failed: %v", err)
+				return
+			}
+			if resp.ID != id {
+				t.Errorf("request %d: response ID = %q, want %q", i, resp.ID, id)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	t.Run("generated when empty", func(t *testing.T) {
+		resp, err := client.Send(Request{Command: "test"})
+		if err != nil {
+			t.Fatalf("Send() failed: %v", err)
+		}
+		if resp.ID == "" {
+			t.Error("response has no ID; client should have generated one")
+		}
+	})
+}
+
+func TestServerStreamEchoesRequestID(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "test.sock")
+
+	server := NewServer(sockPath, HandlerFunc(func(_ context.Context, req Request) Response { return Response{} }))
+	server.HandleStream("count", StreamHandlerFunc(func(ctx context.Context, req Request, send func(Response) error) error {
+		for i := 0; i < 3; i++ {
+			if err := send(SuccessResponse(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer server.Stop()
+	go server.Serve(context.Background())
+
+	id := NewRequestID()
+	resp, err := NewClient(sockPath).SendStream(context.Background(), Request{Command: "count", ID: id}, func(msg Response) error {
+		if msg.ID != id {
+			t.Errorf("partial response ID = %q, want %q", msg.ID, id)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("SendStream() failed: %v", err)
+	}
+	if resp.ID != id {
+		t.Errorf("final response ID = %q, want %q", resp.ID, id)
+	}
+}
+
+func TestServerStopDrainsInFlightRequests(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "test.sock")
+
+	started := make(chan struct{})
+	server := NewServer(sockPath, HandlerFunc(func(_ context.Context, req Request) Response {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		return SuccessResponse(map[string]interface{}{"finished": true})
+	}))
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	go server.Serve(context.Background())
+
+	type result struct {
+		resp *Response
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		resp, err := NewClient(sockPath).Send(Request{Command: "slow"})
+		results <- result{resp, err}
+	}()
+
+	<-started
+	if err := server.Stop(); err != nil {
+		t.Fatalf("Stop() failed: %v", err)
+	}
+
+	// Stop returns only after the response was written
+	select {
+	case r := <-results:
+		if r.err != nil {
+			t.Fatalf("Send() failed: %v", r.err)
+		}
+		data, ok := r.resp.Data.(map[string]interface{})
+		if !r.resp.Success || !ok || data["finished"] != true {
+			t.Errorf("response = %+v, want the handler's complete response", r.resp)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("client did not receive a response")
+	}
+
+	if _, err := NewClient(sockPath).Send(Request{Command: "slow"}); err == nil {
+		t.Error("Send() after Stop succeeded, want connection refused")
+	}
+}
+
+func TestServerStopAbandonsRequestsAfterTimeout(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "test.sock")
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	server := NewServer(sockPath, HandlerFunc(func(_ context.Context, req Request) Response {
+		close(started)
+		<-release
+		return SuccessResponse(nil)
+	}))
+	server.ShutdownTimeout = 50 * time.Millisecond
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	go server.Serve(context.Background())
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := NewClient(sockPath).Send(Request{Command: "stuck"})
+		errs <- err
+	}()
+
+	<-started
+	start := time.Now()
+	if err := server.Stop(); err != nil {
+		t.Fatalf("Stop() failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Stop() took %v, want about the 50ms timeout", elapsed)
+	}
+
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("Send() succeeded, want an error for the abandoned request")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("client was not disconnected after the shutdown timeout")
+	}
+}
+
+func TestResponseCodeJSONRoundTrip(t *testing.T) {
+	codes := []string{CodeBadRequest, CodeNotFound, CodeConflict, CodeBusy, CodeUnauthorized, CodeInternal}
+	for _, code := range codes {
+		t.Run(code, func(t *testing.T) {
+			data, err := json.Marshal(CodedErrorResponse(code, "failed %d", 1))
+			if err != nil {
+				t.Fatalf("Marshal() failed: %v", err)
+			}
+
+			var resp Response
+			if err := json.Unmarshal(data, &resp); err != nil {
+				t.Fatalf("Unmarshal() failed: %v", err)
+			}
+			if resp.Success || resp.Code != code || resp.Error != "failed 1" {
+				t.Errorf("round trip = %+v, want failure %q with code %q", resp, "failed 1", code)
+			}
+		})
+	}
+
+	// Responses without a code stay readable by older clients and vice versa
+	data, err := json.Marshal(ErrorResponse("plain"))
+	if err != nil {
+		t.Fatalf("Marshal() failed: %v", err)
+	}
+	if strings.Contains(string(data), `"code"`) {
+		t.Errorf("uncoded response = %s, want no code field", data)
+	}
+	var resp Response
+	if err := json.Unmarshal([]byte(`{"success":false,"error":"old daemon"}`), &resp); err != nil || resp.Code != "" {
+		t.Errorf("Unmarshal() of a codeless response = %+v, %v", resp, err)
+	}
+}
+
+func TestServerSendsResponseCode(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "test.sock")
+
+	server := NewServer(sockPath, HandlerFunc(func(_ context.Context, req Request) Response {
+		return CodedErrorResponse(CodeBusy, "try again later")
+	}))
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer server.Stop()
+	go server.Serve(context.Background())
+
+	resp, err := NewClient(sockPath).Send(Request{Command: "test"})
+	if err != nil {
+		t.Fatalf("Send() failed: %v", err)
+	}
+	if resp.Code != CodeBusy || resp.Error != "try again later" {
+		t.Errorf("response = %+v, want code %q", resp, CodeBusy)
+	}
+}
+
+func TestClientRetriesDialUntilServerStarts(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "test.sock")
+
+	server := NewServer(sockPath, HandlerFunc(func(_ context.Context, req Request) Response {
+		return SuccessResponse("pong")
+	}))
+	defer server.Stop()
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		if err := server.Start(); err != nil {
+			t.Errorf("Start() failed: %v", err)
+			return
+		}
+		server.Serve(context.Background())
+	}()
+
+	// Without retries the missing socket fails at once
+	if _, err := NewClient(sockPath).Send(Request{Command: "echo"}); err == nil {
+		t.Fatal("Send() without retries succeeded before the server started")
+	}
+
+	client := NewClientWithOptions(sockPath, ClientOptions{Retry: RetryPolicy{
+		MaxElapsed:      5 * time.Second,
+		InitialInterval: 10 * time.Millisecond,
+		MaxInterval:     50 * time.Millisecond,
+	}})
+	start := time.Now()
+	resp, err := client.Send(Request{Command: "echo"})
+	if err != nil {
+		t.Fatalf("Send() with retries failed: %v", err)
+	}
+	if resp.Data != "pong" {
+		t.Errorf("Data = %v, want pong", resp.Data)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Send() took %v, want it to connect soon after the server started", elapsed)
+	}
+}
+
+func TestClientRetryGivesUp(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "missing.sock")
+	client := NewClientWithOptions(sockPath, ClientOptions{Retry: RetryPolicy{
+		MaxElapsed:      100 * time.Millisecond,
+		InitialInterval: 10 * time.Millisecond,
+	}})
+
+	start := time.Now()
+	if _, err := client.Send(Request{Command: "ping"}); err == nil {
+		t.Fatal("Send() succeeded with no server")
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > time.Second {
+		t.Errorf("Send() gave up after %v, want about 100ms", elapsed)
+	}
+
+	// A cancelled context stops retrying early
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	client = NewClientWithOptions(sockPath, ClientOptions{Retry: DefaultRetryPolicy})
+	start = time.Now()
+	if _, err := client.SendContext(ctx, Request{Command: "ping"}); err == nil {
+		t.Fatal("SendContext() succeeded with no server")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("SendContext() kept retrying for %v after its context ended", elapsed)
+	}
+}
+
+func TestClientDoesNotResendAfterMidRequestFailure(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "test.sock")
+	listener, err := net.Listen("unix", sockPath)
+	if err != nil {
+		t.Fatalf("Listen() failed: %v", err)
+	}
+	defer listener.Close()
+
+	// Accept connections and drop them without replying
+	var mu sync.Mutex
+	accepted := 0
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			accepted++
+			mu.Unlock()
+			json.NewDecoder(conn).Decode(&Request{})
+			conn.Close()
+		}
+	}()
+
+	client := NewClientWithOptions(sockPath, ClientOptions{Retry: DefaultRetryPolicy})
+	if _, err := client.Send(Request{Command: "ping"}); err == nil {
+		t.Fatal("Send() succeeded although the connection was dropped")
+	}
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if accepted != 1 {
+		t.Errorf("server saw %d connections, want the request sent once", accepted)
+	}
+}
+
+func TestServerHandlesClientsConcurrently(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "test.sock")
+
+	server := NewServer(sockPath, HandlerFunc(func(_ context.Context, req Request) Response {
+		time.Sleep(20 * time.Millisecond)
+		return SuccessResponse(req.Command)
+	}))
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer server.Stop()
+	go server.Serve(context.Background())
+
+	const clients = 50
+	client := NewClient(sockPath)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Send(Request{Command: "test"})
+			if err != nil {
+				t.Errorf("Send() failed: %v", err)
+				return
+			}
+			if !resp.Success {
+				t.Errorf("response = %+v, want success", resp)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Serially this would take a full second
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("%d clients took %v, want them served concurrently", clients, elapsed)
+	}
+}
+
+func TestServerMaxConcurrencyQueuesRequests(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "test.sock")
+
+	var mu sync.Mutex
+	running, peak := 0, 0
+	server := NewServer(sockPath, HandlerFunc(func(_ context.Context, req Request) Response {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return SuccessResponse(nil)
+	}))
+	server.MaxConcurrency = 2
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer server.Stop()
+	go server.Serve(context.Background())
+
+	client := NewClient(sockPath)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if resp, err := client.Send(Request{Command: "test"}); err != nil || !resp.Success {
+				t.Errorf("Send() = %+v, %v; want queued requests to succeed", resp, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if peak != 2 {
+		t.Errorf("peak concurrent handlers = %d, want 2", peak)
+	}
+}
+
+func TestClientPing(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "test.sock")
+
+	// The handler is wedged; ping must not need it
+	release := make(chan struct{})
+	server := NewServer(sockPath, HandlerFunc(func(_ context.Context, req Request) Response {
+		<-release
+		return SuccessResponse(nil)
+	}))
+	server.MaxConcurrency = 1
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer server.Stop()
+	defer close(release)
+	go server.Serve(context.Background())
+
+	client := NewClient(sockPath)
+	go client.Send(Request{Command: "slow"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	before := time.Now()
+	rtt, err := client.PingContext(ctx)
+	if err != nil {
+		t.Fatalf("PingContext() failed: %v", err)
+	}
+	if rtt <= 0 || rtt > time.Since(before) {
+		t.Errorf("round trip = %v, want positive and at most the %v the call took", rtt, time.Since(before))
+	}
+
+	resp, err := client.Send(Request{Command: PingCommand, ID: "ping-1"})
+	if err != nil {
+		t.Fatalf("Send(ping) failed: %v", err)
+	}
+	if resp.Data != "pong" || resp.ID != "ping-1" {
+		t.Errorf("ping response = %+v, want pong for ping-1", resp)
+	}
+
+	resp, err = client.Send(Request{Command: TimeCommand})
+	if err != nil {
+		t.Fatalf("Send(time) failed: %v", err)
+	}
+	data, ok := resp.Data.(map[string]interface{})
+	if !ok {
+		t.Fatalf("time data = %v, want an object", resp.Data)
+	}
+	answered, err := time.Parse(time.RFC3339Nano, fmt.Sprint(data["time"]))
+	if err != nil || answered.Before(before) {
+		t.Errorf("time = %v (%v), want a timestamp after %v", data["time"], err, before)
+	}
+}
+
+func TestServerReapsIdleConnections(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "test.sock")
+
+	server := NewServer(sockPath, HandlerFunc(func(_ context.Context, req Request) Response {
+		return SuccessResponse(nil)
+	}))
+	server.IdleTimeout = 50 * time.Millisecond
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer server.Stop()
+	go server.Serve(context.Background())
+
+	// Connect but never send a request
+	conn, err := net.Dial("unix", sockPath)
+	if err != nil {
+		t.Fatalf("Dial() failed: %v", err)
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := conn.Read(make([]byte, 1))
+	if err != io.EOF {
+		t.Fatalf("Read() = %d, %v; want EOF once the server reaps the connection", n, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("connection reaped after %v, want about 50ms", elapsed)
+	}
+
+	// Prompt clients are unaffected
+	if _, err := NewClient(sockPath).Send(Request{Command: "test"}); err != nil {
+		t.Errorf("Send() failed: %v", err)
+	}
+}
+
+func TestServerMaxMessageSize(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "test.sock")
+
+	atLimit := `{"command":"test","args":{"pad":"xxxxxxxxxx"}}`
+	server := NewServer(sockPath, HandlerFunc(func(_ context.Context, req Request) Response {
+		return SuccessResponse(req.Args["pad"])
+	}))
+	server.MaxMessageSize = int64(len(atLimit))
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer server.Stop()
+	go server.Serve(context.Background())
+
+	send := func(raw string) Response {
+		t.Helper()
+		conn, err := net.Dial("unix", sockPath)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		defer conn.Close()
+		if _, err := conn.Write([]byte(raw + "\n")); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+		var resp Response
+		if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+			t.Fatalf("Failed to read response: %v", err)
+		}
+		return resp
+	}
+
+	if resp := send(atLimit); !resp.Success || resp.Data != "xxxxxxxxxx" {
+		t.Errorf("request at the limit = %+v, want success", resp)
+	}
+
+	overLimit := strings.Replace(atLimit, `"xxxxxxxxxx"`, `"xxxxxxxxxxx"`, 1)
+	resp := send(overLimit)
+	if resp.Success || resp.Code != CodeBadRequest || !strings.Contains(resp.Error, "limit") {
+		t.Errorf("request one byte over the limit = %+v, want a bad_request size error", resp)
+	}
+}
+
+func TestClientMaxMessageSize(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "test.sock")
+
+	server := NewServer(sockPath, HandlerFunc(func(_ context.Context, req Request) Response {
+		return SuccessResponse(strings.Repeat("x", 100))
+	}))
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer server.Stop()
+	go server.Serve(context.Background())
+
+	want := SuccessResponse(strings.Repeat("x", 100))
+	want.ID = "req-1"
+	encoded, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := int64(len(encoded))
+
+	client := NewClientWithOptions(sockPath, ClientOptions{MaxMessageSize: size})
+	if resp, err := client.Send(Request{Command: "test", ID: "req-1"}); err != nil || !resp.Success {
+		t.Errorf("Send() with a response at the limit = %+v, %v, want success", resp, err)
+	}
+
+	client = NewClientWithOptions(sockPath, ClientOptions{MaxMessageSize: size - 1})
+	if _, err := client.Send(Request{Command: "test", ID: "req-1"}); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("Send() with a response over the limit error = %v, want ErrMessageTooLarge", err)
+	}
+
+	// The token-carrying copy keeps the limit
+	if _, err := client.WithToken("t").Send(Request{Command: "test", ID: "req-1"}); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("WithToken() client error = %v, want ErrMessageTooLarge", err)
+	}
+}
+
+func TestClientMaxMessageSizeAppliesPerStreamMessage(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "test.sock")
+
+	server := NewServer(sockPath, HandlerFunc(func(_ context.Context, req Request) Response {
+		return Response{}
+	}))
+	server.HandleStream("follow", StreamHandlerFunc(func(ctx context.Context, req Request, send func(Response) error) error {
+		for i := 0; i < 50; i++ {
+			if err := send(SuccessResponse(strings.Repeat("x", 100))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer server.Stop()
+	go server.Serve(context.Background())
+
+	// Each message fits, though the stream as a whole is far larger
+	client := NewClientWithOptions(sockPath, ClientOptions{MaxMessageSize: 512})
+	count := 0
+	resp, err := client.SendStream(context.Background(), Request{Command: "follow"}, func(Response) error {
+		count++
+		return nil
+	})
+	if err != nil || !resp.Success {
+		t.Fatalf("SendStream() = %+v, %v, want success", resp, err)
+	}
+	if count != 50 {
+		t.Errorf("received %d partial responses, want 50", count)
+	}
+}
+
+func TestServerTransports(t *testing.T) {
+	handler := HandlerFunc(func(_ context.Context, req Request) Response {
+		return SuccessResponse(map[string]interface{}{"command": req.Command, "token": req.Token, "arg": req.Args["key"]})
+	})
+	count := StreamHandlerFunc(func(ctx context.Context, req Request, send func(Response) error) error {
+		for i := 1; i <= 2; i++ {
+			if err := send(SuccessResponse(float64(i))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	transports := []struct {
+		network string
+		address string
+	}{
+		{NetworkUnix, filepath.Join(t.TempDir(), "test.sock")},
+		{NetworkTCP, "127.0.0.1:0"},
+	}
+	for _, tt := range transports {
+		t.Run(tt.network, func(t *testing.T) {
+			server := NewNetworkServer(tt.network, tt.address, handler)
+			server.RequireToken = true
+			server.Authorize = func(token string) bool { return token == "secret" }
+			server.HandleStream("count", count)
+			if err := server.Start(); err != nil {
+				t.Fatalf("Start() failed: %v", err)
+			}
+			defer server.Stop()
+			go server.Serve(context.Background())
+
+			client := NewNetworkClient(tt.network, server.Addr().String(), ClientOptions{Token: "secret"})
+			resp, err := client.Send(Request{Command: "echo", Args: map[string]interface{}{"key": "value"}})
+			if err != nil {
+				t.Fatalf("Send() failed: %v", err)
+			}
+			data, _ := resp.Data.(map[string]interface{})
+			if !resp.Success || data["command"] != "echo" || data["token"] != "secret" || data["arg"] != "value" {
+				t.Errorf("response = %+v", resp)
+			}
+
+			var streamed int
+			resp, err = client.SendStream(context.Background(), Request{Command: "count"}, func(Response) error {
+				streamed++
+				return nil
+			})
+			if err != nil || !resp.Success || streamed != 2 {
+				t.Errorf("SendStream() = %+v, %v with %d messages, want success with 2", resp, err, streamed)
+			}
+
+			if _, err := client.Ping(); err != nil {
+				t.Errorf("Ping() failed: %v", err)
+			}
+
+			// A request without a token never reaches the handler
+			resp, err = NewNetworkClient(tt.network, server.Addr().String(), ClientOptions{}).Send(Request{Command: "echo"})
+			if err != nil {
+				t.Fatalf("Send() failed: %v", err)
+			}
+			if resp.Success || resp.Code != CodeUnauthorized {
+				t.Errorf("tokenless response = %+v, want %s", resp, CodeUnauthorized)
+			}
+
+			// Nor does a ping with a token Authorize refuses
+			if _, err := NewNetworkClient(tt.network, server.Addr().String(), ClientOptions{Token: "guess"}).Ping(); err == nil {
+				t.Error("Ping() with an invalid token succeeded")
+			}
+		})
+	}
+}
+
+func TestTCPServerRequiresToken(t *testing.T) {
+	server := NewNetworkServer(NetworkTCP, "127.0.0.1:0", HandlerFunc(func(context.Context, Request) Response { return Response{} }))
+	if err := server.Start(); !errors.Is(err, ErrTokenRequired) {
+		t.Errorf("Start() without RequireToken = %v, want ErrTokenRequired", err)
+	}
+	if server.Addr() != nil {
+		t.Error("server is listening after a refused Start")
+	}
+
+	if err := NewNetworkServer("udp", "127.0.0.1:0", nil).Start(); err == nil {
+		t.Error("Start() accepted an unsupported network")
+	}
+	if _, err := NewNetworkClient("udp", "127.0.0.1:1", ClientOptions{}).Send(Request{Command: "x"}); err == nil {
+		t.Error("Send() accepted an unsupported network")
+	}
+}
+
+func TestServerCompressesLargeResponses(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "test.sock")
+
+	payload := strings.Repeat("agent output line\n", 10000)
+	server := NewServer(sockPath, HandlerFunc(func(_ context.Context, req Request) Response {
+		if req.Command == "small" {
+			return SuccessResponse("ok")
+		}
+		return SuccessResponse(payload)
+	}))
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer server.Stop()
+	go server.Serve(context.Background())
+
+	// raw sends req and returns the bytes of the reply
+	raw := func(req string) []byte {
+		t.Helper()
+		conn, err := net.Dial("unix", sockPath)
+		if err != nil {
+			t.Fatalf("Dial() failed: %v", err)
+		}
+		defer conn.Close()
+		if _, err := conn.Write([]byte(req + "\n")); err != nil {
+			t.Fatal(err)
+		}
+		conn.(*net.UnixConn).CloseWrite()
+		data, err := io.ReadAll(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	plain, err := json.Marshal(SuccessResponse(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compressed := raw(`{"command":"big","accept_gzip":true}`)
+	if len(compressed) == 0 || compressed[0] != gzipMagic {
+		t.Fatalf("response to a client accepting gzip starts with %q, want gzip", compressed[:1])
+	}
+	if len(compressed) >= len(plain) {
+		t.Errorf("compressed response is %d bytes, want fewer than %d", len(compressed), len(plain))
+	}
+
+	// Clients that don't advertise support get plain JSON
+	if old := raw(`{"command":"big"}`); len(old) == 0 || old[0] != '{' {
+		t.Errorf("response to an old client starts with %q, want JSON", old[:1])
+	}
+	if small := raw(`{"command":"small","accept_gzip":true}`); len(small) == 0 || small[0] != '{' {
+		t.Errorf("small response starts with %q, want JSON", small[:1])
+	}
+
+	// The client decompresses transparently, with or without compression
+	for name, client := range map[string]*Client{
+		"default":            NewClient(sockPath),
+		"DisableCompression": NewClientWithOptions(sockPath, ClientOptions{DisableCompression: true}),
+	} {
+		resp, err := client.Send(Request{Command: "big"})
+		if err != nil {
+			t.Fatalf("%s: Send() failed: %v", name, err)
+		}
+		if got, _ := resp.Data.(string); got != payload {
+			t.Errorf("%s: Send() data is %d bytes, want the original %d", name, len(got), len(payload))
+		}
+	}
+
+	// The size limit applies to the decompressed response
+	client := NewClientWithOptions(sockPath, ClientOptions{MaxMessageSize: int64(len(compressed))})
+	if _, err := client.Send(Request{Command: "big"}); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("Send() over the decompressed limit error = %v, want ErrMessageTooLarge", err)
+	}
+}
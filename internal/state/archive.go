@@ -0,0 +1,136 @@
+package state
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// HistoryFileName is the JSON Lines archive of removed agents, kept beside
+// the state file
+const HistoryFileName = "history.jsonl"
+
+// maxHistoryLine bounds a single record in the archive
+const maxHistoryLine = 1024 * 1024
+
+// HistoryRecord is one removed agent in the archive
+type HistoryRecord struct {
+	Repo          string    `json:"repo"`
+	Agent         string    `json:"agent"`
+	Type          AgentType `json:"type"`
+	Branch        string    `json:"branch,omitempty"`
+	Task          string    `json:"task,omitempty"`
+	Summary       string    `json:"summary,omitempty"`
+	FailureReason string    `json:"failure_reason,omitempty"`
+	PRNumber      int       `json:"pr_number,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	RemovedAt     time.Time `json:"removed_at"`
+}
+
+// HistoryFilter selects archived records. Zero fields match everything.
+type HistoryFilter struct {
+	Since time.Time   // Only records removed at or after Since
+	Until time.Time   // Only records removed before Until
+	Types []AgentType // Only records of these agent types
+}
+
+// Match reports whether rec passes the filter
+func (f HistoryFilter) Match(rec HistoryRecord) bool {
+	if !f.Since.IsZero() && rec.RemovedAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !rec.RemovedAt.Before(f.Until) {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if rec.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// historyPath returns the archive path for this state file
+func (s *State) historyPath() string {
+	return filepath.Join(filepath.Dir(s.path), HistoryFileName)
+}
+
+// archiveAgentUnlocked appends a removed agent to the archive. Caller must
+// hold s.mu.
+func (s *State) archiveAgentUnlocked(repoName, agentName string, agent Agent) error {
+	line, err := json.Marshal(HistoryRecord{
+		Repo:          repoName,
+		Agent:         agentName,
+		Type:          agent.Type,
+		Branch:        agent.Branch,
+		Task:          agent.Task,
+		Summary:       agent.Summary,
+		FailureReason: agent.FailureReason,
+		PRNumber:      agent.PRNumber,
+		CreatedAt:     agent.CreatedAt,
+		RemovedAt:     time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal history record: %w", err)
+	}
+
+	f, err := os.OpenFile(s.historyPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open agent history: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to append agent history: %w", err)
+	}
+	return f.Close()
+}
+
+// StreamHistory copies archived records matching filter to w, one JSON
+// object per line. The archive is read a line at a time, so memory use does
+// not grow with its size. A missing archive writes nothing.
+func (s *State) StreamHistory(w io.Writer, filter HistoryFilter) error {
+	f, err := os.Open(s.historyPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to open agent history: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxHistoryLine)
+	out := bufio.NewWriter(w)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var rec HistoryRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return fmt.Errorf("agent history line %d: %w", lineNum, err)
+		}
+		if !filter.Match(rec) {
+			continue
+		}
+		if _, err := out.Write(line); err != nil {
+			return err
+		}
+		if err := out.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read agent history: %w", err)
+	}
+	return out.Flush()
+}
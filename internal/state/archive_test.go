@@ -0,0 +1,175 @@
+package state
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestRemoveAgentArchivesHistory(t *testing.T) {
+	tmpDir := t.TempDir()
+	s := New(filepath.Join(tmpDir, "state.json"))
+	s.AddRepo("test-repo", &Repository{Agents: make(map[string]Agent)})
+	s.AddAgent("test-repo", "worker1", Agent{Type: AgentTypeWorker, Task: "fix bug", CreatedAt: time.Now()})
+
+	if err := s.RemoveAgent("test-repo", "worker1"); err != nil {
+		t.Fatalf("RemoveAgent() failed: %v", err)
+	}
+	// Removing an agent that is not there archives nothing
+	if err := s.RemoveAgent("test-repo", "worker1"); err != nil {
+		t.Fatalf("RemoveAgent() failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := s.StreamHistory(&buf, HistoryFilter{}); err != nil {
+		t.Fatalf("StreamHistory() failed: %v", err)
+	}
+	var rec HistoryRecord
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("history is not a single record: %v\n%s", err, buf.String())
+	}
+	if rec.Repo != "test-repo" || rec.Agent != "worker1" || rec.Task != "fix bug" || rec.RemovedAt.IsZero() {
+		t.Errorf("archived record = %+v", rec)
+	}
+}
+
+func TestRemoveAgentArchiveFailureKeepsAgent(t *testing.T) {
+	tmpDir := t.TempDir()
+	s := New(filepath.Join(tmpDir, "state.json"))
+	s.AddRepo("test-repo", &Repository{Agents: make(map[string]Agent)})
+	s.AddAgent("test-repo", "worker1", Agent{Type: AgentTypeWorker, ReadyForCleanup: true})
+
+	// A directory where the archive should be makes appending fail
+	if err := os.Mkdir(filepath.Join(tmpDir, HistoryFileName), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RemoveAgent("test-repo", "worker1"); err == nil {
+		t.Fatal("RemoveAgent() succeeded without an archive")
+	}
+	if _, err := s.PruneReadyAgents(); err == nil {
+		t.Fatal("PruneReadyAgents() succeeded without an archive")
+	}
+
+	// Neither the in-memory nor the saved state lost the agent
+	if _, ok := s.GetAgent("test-repo", "worker1"); !ok {
+		t.Error("agent removed from memory despite the archive failure")
+	}
+	saved, err := Load(filepath.Join(tmpDir, "state.json"))
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if _, ok := saved.GetAgent("test-repo", "worker1"); !ok {
+		t.Error("agent removed from the saved state despite the archive failure")
+	}
+}
+
+func TestStreamHistoryMissingArchive(t *testing.T) {
+	s := New(filepath.Join(t.TempDir(), "state.json"))
+	var buf bytes.Buffer
+	if err := s.StreamHistory(&buf, HistoryFilter{}); err != nil || buf.Len() != 0 {
+		t.Errorf("StreamHistory() = %q, %v, want nothing", buf.String(), err)
+	}
+}
+
+// countingWriter counts lines and checks each is a record passing the filter
+type countingWriter struct {
+	t      *testing.T
+	filter HistoryFilter
+	buf    []byte
+	lines  int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		var rec HistoryRecord
+		if err := json.Unmarshal(w.buf[:i], &rec); err != nil {
+			w.t.Fatalf("output line is not a record: %v", err)
+		}
+		if !w.filter.Match(rec) {
+			w.t.Fatalf("record %+v does not match filter", rec)
+		}
+		w.lines++
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+func TestStreamHistoryLargeArchive(t *testing.T) {
+	if testing.Short() {
+		t.Skip("writes a large archive")
+	}
+	tmpDir := t.TempDir()
+	s := New(filepath.Join(tmpDir, "state.json"))
+
+	// Synthetic archive: one record a minute, cycling through agent types
+	const records = 200000
+	types := []AgentType{AgentTypeWorker, AgentTypeSupervisor, AgentTypeReview, AgentTypeMergeQueue}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f, err := os.Create(filepath.Join(tmpDir, HistoryFileName))
+	if err != nil {
+		t.Fatalf("failed to create archive: %v", err)
+	}
+	bw := bufio.NewWriter(f)
+	enc := json.NewEncoder(bw)
+	for i := 0; i < records; i++ {
+		enc.Encode(HistoryRecord{
+			Repo:      "repo",
+			Agent:     fmt.Sprintf("agent-%d", i),
+			Type:      types[i%len(types)],
+			Task:      "a synthetic task description long enough to make the archive sizeable",
+			CreatedAt: start.Add(time.Duration(i) * time.Minute),
+			RemovedAt: start.Add(time.Duration(i)*time.Minute + time.Second),
+		})
+	}
+	if err := bw.Flush(); err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+	f.Close()
+	info, _ := os.Stat(filepath.Join(tmpDir, HistoryFileName))
+
+	// Workers removed during the first 40000 minutes: every fourth record
+	filter := HistoryFilter{
+		Since: start,
+		Until: start.Add(40000 * time.Minute),
+		Types: []AgentType{AgentTypeWorker},
+	}
+
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	w := &countingWriter{t: t, filter: filter}
+	if err := s.StreamHistory(w, filter); err != nil {
+		t.Fatalf("StreamHistory() failed: %v", err)
+	}
+
+	runtime.ReadMemStats(&after)
+	if w.lines != 10000 {
+		t.Errorf("streamed %d records, want 10000", w.lines)
+	}
+	// Heap obtained from the OS bounds the peak heap during the stream
+	if grew := int64(after.HeapSys) - int64(before.HeapSys); grew > info.Size()/4 {
+		t.Errorf("heap grew by %d bytes streaming a %d byte archive", grew, info.Size())
+	}
+
+	// Everything streams through unchanged with no filter
+	counter := &countingWriter{t: t}
+	if err := s.StreamHistory(counter, HistoryFilter{}); err != nil {
+		t.Fatalf("StreamHistory() failed: %v", err)
+	}
+	if counter.lines != records {
+		t.Errorf("streamed %d records unfiltered, want %d", counter.lines, records)
+	}
+}
@@ -0,0 +1,167 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/dlorenc/multiclaude/internal/events"
+	"github.com/dlorenc/multiclaude/internal/fsutil"
+)
+
+const (
+	backupPrefix = "state-"
+	backupSuffix = ".json"
+	// backupTimeFormat names backups so they sort oldest first
+	backupTimeFormat = "20060102T150405.000000000Z"
+)
+
+// SetBackupRetention sets how many backups Backup keeps in a directory.
+// Older ones are removed after each new backup. Zero or less keeps them all.
+func (s *State) SetBackupRetention(keep int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backupKeep = keep
+}
+
+// Backup writes a timestamped copy of the current state into dir, creating
+// it if needed, and returns the backup's path. The copy is written the same
+// way as the state file, so a crash never leaves a partial backup behind.
+func (s *State) Backup(dir string) (string, error) {
+	s.mu.RLock()
+	data, err := json.MarshalIndent(s, "", "  ")
+	keep := s.backupKeep
+	stamp := s.clock().UTC().Format(backupTimeFormat)
+	s.mu.RUnlock()
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	path := filepath.Join(dir, backupPrefix+stamp+backupSuffix)
+	if err := fsutil.WriteFileAtomic(path, data, 0600); err != nil {
+		return "", fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	if keep > 0 {
+		if _, err := PruneBackups(dir, keep); err != nil {
+			return path, err
+		}
+	}
+	return path, nil
+}
+
+// ListBackups returns the paths of the backups in dir, oldest first
+func ListBackups(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, backupPrefix+"*"+backupSuffix))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// PruneBackups removes all but the newest keep backups in dir and returns
+// the paths it removed
+func PruneBackups(dir string, keep int) ([]string, error) {
+	paths, err := ListBackups(dir)
+	if err != nil {
+		return nil, err
+	}
+	if keep < 0 {
+		keep = 0
+	}
+	if len(paths) <= keep {
+		return nil, nil
+	}
+
+	var removed []string
+	for _, path := range paths[:len(paths)-keep] {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return removed, fmt.Errorf("failed to remove old backup: %w", err)
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+// Restore replaces the state with the backup at path and saves it. The
+// backup is checked before anything is written: it must parse, be at a
+// schema version this build understands (older ones are migrated), and
+// name a current repository that it contains. Returns s.
+func (s *State) Restore(path string) (*State, error) {
+	backup, err := readBackup(path)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := s.beginUpdate()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	previous := s.Repos
+	s.Repos = backup.Repos
+	s.CurrentRepo = backup.CurrentRepo
+	if err := s.writeUnlocked(); err != nil {
+		return nil, err
+	}
+
+	for name := range previous {
+		if _, kept := s.Repos[name]; !kept {
+			s.publishChangeUnlocked(events.Removed, name, "")
+		}
+	}
+	for name := range s.Repos {
+		if _, existed := previous[name]; existed {
+			s.publishChangeUnlocked(events.Updated, name, "")
+		} else {
+			s.publishChangeUnlocked(events.Added, name, "")
+		}
+	}
+	return s, nil
+}
+
+// readBackup parses and validates a backup file
+func readBackup(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup: %w", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat backup: %w", err)
+	}
+	data, _, err = migrate(data, info.ModTime())
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup %s: %w", path, err)
+	}
+
+	var backup State
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return nil, fmt.Errorf("invalid backup %s: %w", path, err)
+	}
+	if backup.Repos == nil {
+		backup.Repos = make(map[string]*Repository)
+	}
+	for name, repo := range backup.Repos {
+		if repo == nil {
+			return nil, fmt.Errorf("invalid backup %s: repository %q is empty", path, name)
+		}
+		if repo.Agents == nil {
+			repo.Agents = make(map[string]Agent)
+		}
+	}
+	defaultAgentStatuses(backup.Repos)
+	if backup.CurrentRepo != "" {
+		if _, ok := backup.Repos[backup.CurrentRepo]; !ok {
+			return nil, fmt.Errorf("invalid backup %s: current repository %q not found", path, backup.CurrentRepo)
+		}
+	}
+	return &backup, nil
+}
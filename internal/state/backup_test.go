@@ -0,0 +1,114 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackupRestore(t *testing.T) {
+	tmpDir := t.TempDir()
+	statePath := filepath.Join(tmpDir, "state.json")
+	s := New(statePath)
+	s.AddRepo("test-repo", &Repository{GithubURL: "https://github.com/owner/repo", Agents: make(map[string]Agent)})
+	s.AddAgent("test-repo", "worker1", Agent{Type: AgentTypeWorker, Task: "fix bug"})
+
+	backup, err := s.Backup(filepath.Join(tmpDir, "backups"))
+	if err != nil {
+		t.Fatalf("Backup() failed: %v", err)
+	}
+	if !strings.HasPrefix(filepath.Base(backup), "state-") {
+		t.Errorf("Backup() path = %s", backup)
+	}
+
+	s.RemoveAgent("test-repo", "worker1")
+	s.AddRepo("other-repo", &Repository{Agents: make(map[string]Agent)})
+
+	if _, err := s.Restore(backup); err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	if _, ok := s.GetAgent("test-repo", "worker1"); !ok {
+		t.Error("Restore() did not bring back the removed agent")
+	}
+	if _, ok := s.GetRepo("other-repo"); ok {
+		t.Error("Restore() kept a repository added after the backup")
+	}
+
+	// The restored state is what is on disk
+	loaded, err := Load(statePath)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if agent, ok := loaded.GetAgent("test-repo", "worker1"); !ok || agent.Task != "fix bug" {
+		t.Errorf("saved state agent = %+v, %v", agent, ok)
+	}
+	if len(loaded.Repos) != 1 {
+		t.Errorf("saved state has %d repos, want 1", len(loaded.Repos))
+	}
+}
+
+func TestRestoreRejectsInvalidBackup(t *testing.T) {
+	tmpDir := t.TempDir()
+	s := New(filepath.Join(tmpDir, "state.json"))
+	s.AddRepo("test-repo", &Repository{Agents: make(map[string]Agent)})
+
+	tests := map[string]string{
+		"not json":        "{",
+		"future version":  `{"schema_version": 999, "repos": {}}`,
+		"missing current": `{"schema_version": 1, "repos": {}, "current_repo": "gone"}`,
+		"null repository": `{"schema_version": 1, "repos": {"bad": null}}`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(tmpDir, strings.ReplaceAll(name, " ", "-")+".json")
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Restore(path); err == nil {
+				t.Error("Restore() succeeded, want error")
+			}
+			if _, ok := s.GetRepo("test-repo"); !ok {
+				t.Error("a rejected backup changed the state")
+			}
+		})
+	}
+}
+
+func TestBackupRetention(t *testing.T) {
+	tmpDir := t.TempDir()
+	dir := filepath.Join(tmpDir, "backups")
+	s := New(filepath.Join(tmpDir, "state.json"))
+	now := time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC)
+	s.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	s.SetBackupRetention(2)
+
+	var paths []string
+	for i := 0; i < 5; i++ {
+		path, err := s.Backup(dir)
+		if err != nil {
+			t.Fatalf("Backup() failed: %v", err)
+		}
+		paths = append(paths, path)
+	}
+
+	kept, err := ListBackups(dir)
+	if err != nil {
+		t.Fatalf("ListBackups() failed: %v", err)
+	}
+	if len(kept) != 2 || kept[0] != paths[3] || kept[1] != paths[4] {
+		t.Errorf("kept backups = %v, want the newest two of %v", kept, paths)
+	}
+
+	removed, err := PruneBackups(dir, 1)
+	if err != nil {
+		t.Fatalf("PruneBackups() failed: %v", err)
+	}
+	if len(removed) != 1 || removed[0] != paths[3] {
+		t.Errorf("PruneBackups() removed %v, want %s", removed, paths[3])
+	}
+}
@@ -0,0 +1,11 @@
+package state
+
+// Advisory locking keeps the daemon and CLI from interleaving writes to the
+// state file. The lock is taken on a separate file beside it, because saves
+// replace state.json by rename and a lock on the old inode would not be
+// seen by the next reader.
+
+// lockPath returns the lock file used for the state file at path
+func lockPath(path string) string {
+	return path + ".lock"
+}
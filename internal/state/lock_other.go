@@ -0,0 +1,9 @@
+//go:build !unix
+
+package state
+
+// acquireLock is a no-op where flock is unavailable; writes are still
+// atomic, but concurrent writers are not serialized
+func acquireLock(path string, exclusive bool) (func(), error) {
+	return func() {}, nil
+}
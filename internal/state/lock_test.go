@@ -0,0 +1,127 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLockedUpdateConcurrentWriters(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	if err := New(statePath).AddRepo("test-repo", &Repository{}); err != nil {
+		t.Fatalf("AddRepo() failed: %v", err)
+	}
+
+	// Each writer loads its own copy, as the daemon and CLI processes do
+	const writers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			st, err := Load(statePath)
+			if err != nil {
+				errs <- err
+				return
+			}
+			errs <- st.LockedUpdate(func(st *State) error {
+				return st.AddAgent("test-repo", fmt.Sprintf("agent-%d", i), Agent{
+					Type:      AgentTypeWorker,
+					CreatedAt: time.Now(),
+				})
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("writer failed: %v", err)
+		}
+	}
+
+	final, err := Load(statePath)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	agents, _ := final.ListAgents("test-repo")
+	if len(agents) != writers {
+		t.Errorf("state has %d agents, want %d: %v", len(agents), writers, agents)
+	}
+}
+
+func TestLockedUpdateRefreshesReceiver(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	stale := New(statePath)
+	if err := stale.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	// Another writer adds a repo the stale copy has not seen
+	other, _ := Load(statePath)
+	if err := other.AddRepo("other-repo", &Repository{}); err != nil {
+		t.Fatalf("AddRepo() failed: %v", err)
+	}
+
+	if err := stale.LockedUpdate(func(st *State) error {
+		return st.AddRepo("new-repo", &Repository{})
+	}); err != nil {
+		t.Fatalf("LockedUpdate() failed: %v", err)
+	}
+	for _, name := range []string{"other-repo", "new-repo"} {
+		if _, exists := stale.GetRepo(name); !exists {
+			t.Errorf("receiver missing %s after LockedUpdate", name)
+		}
+	}
+
+	// A failing update leaves the file untouched
+	wantErr := errors.New("abort")
+	if err := stale.LockedUpdate(func(st *State) error {
+		st.RemoveRepo("other-repo")
+		return wantErr
+	}); !errors.Is(err, wantErr) {
+		t.Fatalf("LockedUpdate() = %v, want %v", err, wantErr)
+	}
+	if _, exists := stale.GetRepo("other-repo"); !exists {
+		t.Error("failed update changed the receiver")
+	}
+	reloaded, _ := Load(statePath)
+	if _, exists := reloaded.GetRepo("other-repo"); !exists {
+		t.Error("failed update was written to disk")
+	}
+}
+
+func TestMutatorsKeepOtherWriters(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	daemon := New(statePath)
+	if err := daemon.AddRepo("test-repo", &Repository{}); err != nil {
+		t.Fatalf("AddRepo() failed: %v", err)
+	}
+
+	// The CLI adds an agent the daemon's copy has not seen
+	cli, _ := Load(statePath)
+	if err := cli.LockedUpdate(func(st *State) error {
+		return st.AddAgent("test-repo", "from-cli", Agent{Type: AgentTypeWorker, CreatedAt: time.Now()})
+	}); err != nil {
+		t.Fatalf("LockedUpdate() failed: %v", err)
+	}
+
+	// The daemon's next change is made on top of it instead of dropping it
+	if err := daemon.AddAgent("test-repo", "from-daemon", Agent{Type: AgentTypeWorker, CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("AddAgent() failed: %v", err)
+	}
+
+	reloaded, err := Load(statePath)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	for _, name := range []string{"from-cli", "from-daemon"} {
+		if _, exists := reloaded.GetAgent("test-repo", name); !exists {
+			t.Errorf("state missing %s", name)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// CurrentSchemaVersion is the schema version this build writes. State files
+// without a schema_version field are version 0.
+const CurrentSchemaVersion = 1
+
+// Migration rewrites raw state JSON from one schema version to the next
+type Migration func(raw json.RawMessage) (json.RawMessage, error)
+
+// migrations returns the registered migrations keyed by the version they
+// upgrade from. modTime is the state file's modification time, for
+// migrations that need a timestamp to backfill.
+func migrations(modTime time.Time) map[int]Migration {
+	return map[int]Migration{
+		0: backfillAgentCreatedAt(modTime),
+	}
+}
+
+// migrate applies migrations in sequence until raw is at
+// CurrentSchemaVersion. It reports whether anything was migrated.
+func migrate(raw json.RawMessage, modTime time.Time) (json.RawMessage, bool, error) {
+	var header struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return nil, false, fmt.Errorf("failed to parse state file: %w", err)
+	}
+
+	version := header.SchemaVersion
+	if version > CurrentSchemaVersion {
+		return nil, false, fmt.Errorf("state file schema version %d is newer than this build supports (%d); upgrade multiclaude", version, CurrentSchemaVersion)
+	}
+	if version == CurrentSchemaVersion {
+		return raw, false, nil
+	}
+
+	registry := migrations(modTime)
+	for ; version < CurrentSchemaVersion; version++ {
+		m, ok := registry[version]
+		if !ok {
+			return nil, false, fmt.Errorf("no migration from state schema version %d", version)
+		}
+		var err error
+		if raw, err = m(raw); err != nil {
+			return nil, false, fmt.Errorf("failed to migrate state from schema version %d: %w", version, err)
+		}
+	}
+	return raw, true, nil
+}
+
+// decodeObject parses raw as a JSON object, keeping numbers exact
+func decodeObject(raw json.RawMessage) (map[string]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var obj map[string]interface{}
+	if err := dec.Decode(&obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// backfillAgentCreatedAt is the v0 to v1 migration. Early state files did
+// not record when agents were created; those agents get the file's
+// modification time, the latest time they are known to have existed.
+func backfillAgentCreatedAt(modTime time.Time) Migration {
+	return func(raw json.RawMessage) (json.RawMessage, error) {
+		obj, err := decodeObject(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		repos, _ := obj["repos"].(map[string]interface{})
+		for _, r := range repos {
+			repo, _ := r.(map[string]interface{})
+			agents, _ := repo["agents"].(map[string]interface{})
+			for _, a := range agents {
+				agent, ok := a.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if createdAt, _ := agent["created_at"].(string); createdAt == "" || createdAt == (time.Time{}).Format(time.RFC3339) {
+					agent["created_at"] = modTime.UTC().Format(time.RFC3339Nano)
+				}
+			}
+		}
+
+		obj["schema_version"] = 1
+		return json.Marshal(obj)
+	}
+}
@@ -0,0 +1,114 @@
+package state
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stateV0 is a state file from before schema versioning, with one agent
+// that never recorded its creation time
+const stateV0 = `{
+  "repos": {
+    "my-repo": {
+      "github_url": "https://github.com/test/repo",
+      "tmux_session": "mc-my-repo",
+      "agents": {
+        "supervisor": {
+          "type": "supervisor",
+          "worktree_path": "/tmp/wts/supervisor",
+          "tmux_window": "supervisor",
+          "session_id": "abc",
+          "pid": 4194304
+        },
+        "worker1": {
+          "type": "worker",
+          "worktree_path": "/tmp/wts/worker1",
+          "tmux_window": "worker1",
+          "session_id": "def",
+          "pid": 0,
+          "task": "fix the bug",
+          "created_at": "2024-01-02T03:04:05Z"
+        }
+      }
+    }
+  },
+  "current_repo": "my-repo"
+}`
+
+func TestLoadMigratesV0(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(statePath, []byte(stateV0), 0644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+	mtime := time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)
+	if err := os.Chtimes(statePath, mtime, mtime); err != nil {
+		t.Fatalf("failed to set mtime: %v", err)
+	}
+
+	s, err := Load(statePath)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if s.SchemaVersion != CurrentSchemaVersion {
+		t.Errorf("SchemaVersion = %d, want %d", s.SchemaVersion, CurrentSchemaVersion)
+	}
+
+	supervisor, _ := s.GetAgent("my-repo", "supervisor")
+	if !supervisor.CreatedAt.Equal(mtime) {
+		t.Errorf("backfilled CreatedAt = %v, want file mtime %v", supervisor.CreatedAt, mtime)
+	}
+	if supervisor.PID != 4194304 || supervisor.SessionID != "abc" {
+		t.Errorf("supervisor fields changed by migration: %+v", supervisor)
+	}
+	worker, _ := s.GetAgent("my-repo", "worker1")
+	if want := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC); !worker.CreatedAt.Equal(want) {
+		t.Errorf("existing CreatedAt = %v, want it kept as %v", worker.CreatedAt, want)
+	}
+	if worker.Task != "fix the bug" || s.GetCurrentRepo() != "my-repo" {
+		t.Errorf("fields lost in migration: task %q, current repo %q", worker.Task, s.GetCurrentRepo())
+	}
+
+	// The migrated state was saved back at the current version
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatalf("failed to read state: %v", err)
+	}
+	var header struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	json.Unmarshal(data, &header)
+	if header.SchemaVersion != CurrentSchemaVersion {
+		t.Errorf("saved schema_version = %d, want %d", header.SchemaVersion, CurrentSchemaVersion)
+	}
+}
+
+func TestLoadCurrentVersionIsNotRewritten(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	if err := New(statePath).AddRepo("my-repo", &Repository{}); err != nil {
+		t.Fatalf("AddRepo() failed: %v", err)
+	}
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(statePath, old, old)
+
+	if _, err := Load(statePath); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	info, _ := os.Stat(statePath)
+	if !info.ModTime().Equal(old) {
+		t.Error("Load() rewrote a state file already at the current version")
+	}
+}
+
+func TestLoadRejectsNewerVersion(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	os.WriteFile(statePath, []byte(`{"schema_version": 99, "repos": {}}`), 0644)
+
+	_, err := Load(statePath)
+	if err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("Load() = %v, want an error about a newer schema version", err)
+	}
+}
@@ -2,13 +2,40 @@ package state
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
+	"sort"
 	"sync"
 	"time"
+
+	"github.com/dlorenc/multiclaude/internal/events"
+	"github.com/dlorenc/multiclaude/internal/fsutil"
+	"github.com/dlorenc/multiclaude/internal/names"
+	"github.com/dlorenc/multiclaude/internal/redact"
 )
 
+// ErrRepoNotFound is returned when a named repository is not in state
+var ErrRepoNotFound = errors.New("repository not found")
+
+// ErrRepoExists is returned when a repository name is already taken
+var ErrRepoExists = errors.New("repository already exists")
+
+// ErrAgentNotFound is returned when a named agent is not in its repository
+var ErrAgentNotFound = errors.New("agent not found")
+
+// ErrAgentExists is returned when an agent name is already taken in its
+// repository
+var ErrAgentExists = errors.New("agent already exists")
+
+// ErrMergeQueueConflict is returned when a merge queue operation does not fit
+// the queue's current contents
+var ErrMergeQueueConflict = errors.New("merge queue conflict")
+
+// ErrRepoHasAgents is returned when an operation needs a repository without
+// agents
+var ErrRepoHasAgents = errors.New("repository has agents")
+
 // AgentType represents the type of agent
 type AgentType string
 
@@ -36,6 +63,18 @@ func (t AgentType) IsPersistent() bool {
 	}
 }
 
+// ParseAgentType converts a string to an AgentType, returning an error if
+// it is not a known agent type
+func ParseAgentType(s string) (AgentType, error) {
+	switch t := AgentType(s); t {
+	case AgentTypeSupervisor, AgentTypeWorker, AgentTypeMergeQueue, AgentTypePRShepherd,
+		AgentTypeWorkspace, AgentTypeReview, AgentTypeGenericPersistent, AgentTypeUAT:
+		return t, nil
+	default:
+		return "", fmt.Errorf("invalid agent type: %q", s)
+	}
+}
+
 // TrackMode defines which PRs the merge queue should track
 type TrackMode string
 
@@ -69,16 +108,57 @@ type MergeQueueConfig struct {
 	Enabled bool `json:"enabled"`
 	// TrackMode determines which PRs to track: "all", "author", or "assigned" (default: "all")
 	TrackMode TrackMode `json:"track_mode"`
+	// MaxRetries is how many times a failed merge is requeued before it is
+	// dropped. Zero means the default (2) and -1 disables retries.
+	MaxRetries int `json:"max_retries,omitempty"`
+}
+
+// Retries returns the effective number of retries for a failed merge
+func (c MergeQueueConfig) Retries() int {
+	switch {
+	case c.MaxRetries == 0:
+		return DefaultMergeQueueMaxRetries
+	case c.MaxRetries < 0:
+		return 0
+	}
+	return c.MaxRetries
 }
 
+// DefaultMergeQueueMaxRetries is the default number of retries for a failed merge
+const DefaultMergeQueueMaxRetries = 2
+
 // DefaultMergeQueueConfig returns the default merge queue configuration
 func DefaultMergeQueueConfig() MergeQueueConfig {
 	return MergeQueueConfig{
-		Enabled:   true,
-		TrackMode: TrackModeAll,
+		Enabled:    true,
+		TrackMode:  TrackModeAll,
+		MaxRetries: DefaultMergeQueueMaxRetries,
 	}
 }
 
+// MergeQueueStatus represents the status of a merge queue entry
+type MergeQueueStatus string
+
+const (
+	// MergeQueueStatusPending means the PR is waiting its turn
+	MergeQueueStatusPending MergeQueueStatus = "pending"
+	// MergeQueueStatusClaimed means the merge-queue agent is processing the PR
+	MergeQueueStatusClaimed MergeQueueStatus = "claimed"
+)
+
+// MergeQueueEntry is a pull request waiting to be merged
+type MergeQueueEntry struct {
+	PRNumber   int              `json:"pr_number"`
+	PRURL      string           `json:"pr_url,omitempty"`
+	Branch     string           `json:"branch,omitempty"`
+	Status     MergeQueueStatus `json:"status"`
+	Attempts   int              `json:"attempts,omitempty"`   // Number of times the entry has been claimed
+	ClaimedBy  string           `json:"claimed_by,omitempty"` // Agent processing the entry
+	LastError  string           `json:"last_error,omitempty"` // Failure reason from the last attempt
+	EnqueuedAt time.Time        `json:"enqueued_at"`
+	ClaimedAt  time.Time        `json:"claimed_at,omitempty"`
+}
+
 // PRShepherdConfig holds configuration for the PR shepherd agent (used in fork mode)
 type PRShepherdConfig struct {
 	// Enabled determines whether the PR shepherd agent should run (default: true in fork mode)
@@ -95,6 +175,34 @@ func DefaultPRShepherdConfig() PRShepherdConfig {
 	}
 }
 
+// SpawnLimitConfig limits how quickly and how many workers can be spawned in a
+// repository. Limits are off unless configured: a zero or negative field
+// disables that limit.
+type SpawnLimitConfig struct {
+	// MaxWorkers is the maximum number of concurrently active workers
+	MaxWorkers int `json:"max_workers,omitempty"`
+	// SpawnsPerMinute is the sustained rate at which workers can be spawned
+	SpawnsPerMinute float64 `json:"spawns_per_minute,omitempty"`
+	// Burst is how many spawns can happen back to back before the rate applies
+	Burst int `json:"burst,omitempty"`
+}
+
+// ClaudeConfig customizes how the claude CLI is invoked for one agent type.
+// Empty fields keep the defaults.
+type ClaudeConfig struct {
+	// BinaryPath is the claude binary to run instead of the one on PATH
+	BinaryPath string `json:"binary_path,omitempty"`
+	// Model is passed to claude as --model
+	Model string `json:"model,omitempty"`
+	// ExtraArgs are appended to the claude command line
+	ExtraArgs []string `json:"extra_args,omitempty"`
+}
+
+// IsZero reports whether the config changes nothing
+func (c ClaudeConfig) IsZero() bool {
+	return c.BinaryPath == "" && c.Model == "" && len(c.ExtraArgs) == 0
+}
+
 // ForkConfig holds fork-related configuration for a repository
 type ForkConfig struct {
 	// IsFork is true if the repository is detected as a fork
@@ -107,6 +215,9 @@ type ForkConfig struct {
 	UpstreamRepo string `json:"upstream_repo,omitempty"`
 	// ForceForkMode forces fork mode even for non-forks (edge case)
 	ForceForkMode bool `json:"force_fork_mode,omitempty"`
+	// SyncRemote names the remote that sync brings the fork up to date
+	// with; empty means "upstream"
+	SyncRemote string `json:"sync_remote,omitempty"`
 }
 
 // TaskStatus represents the status of a completed task
@@ -143,128 +254,452 @@ type TaskHistoryEntry struct {
 
 // Agent represents an agent's state
 type Agent struct {
-	Type            AgentType `json:"type"`
-	WorktreePath    string    `json:"worktree_path"`
-	TmuxWindow      string    `json:"tmux_window"`
-	SessionID       string    `json:"session_id"`
-	PID             int       `json:"pid"`
-	Task            string    `json:"task,omitempty"`           // Only for workers
-	Summary         string    `json:"summary,omitempty"`        // Brief summary of work done (workers only)
-	FailureReason   string    `json:"failure_reason,omitempty"` // Why the task failed (workers only)
-	CreatedAt       time.Time `json:"created_at"`
-	LastNudge       time.Time `json:"last_nudge,omitempty"`
-	ReadyForCleanup bool      `json:"ready_for_cleanup,omitempty"` // Only for workers
+	Type            AgentType   `json:"type"`
+	WorktreePath    string      `json:"worktree_path"`
+	Branch          string      `json:"branch,omitempty"` // Branch checked out in the worktree when the agent started
+	TmuxWindow      string      `json:"tmux_window"`
+	SessionID       string      `json:"session_id"`
+	PID             int         `json:"pid"`
+	Task            string      `json:"task,omitempty"`           // Only for workers
+	Summary         string      `json:"summary,omitempty"`        // Brief summary of work done (workers only)
+	FailureReason   string      `json:"failure_reason,omitempty"` // Why the task failed (workers only)
+	CreatedAt       time.Time   `json:"created_at"`
+	LastNudge       time.Time   `json:"last_nudge,omitempty"`
+	ReadyForCleanup bool        `json:"ready_for_cleanup,omitempty"` // Only for workers
+	PRNumber        int         `json:"pr_number,omitempty"`         // PR under review (review agents only)
+	TargetBranch    string      `json:"target_branch,omitempty"`     // Branch the reviewed PR merges into (review agents only)
+	RestartCount    int         `json:"restart_count,omitempty"`     // Automatic restarts since the last manual restart or stable run
+	LastRestart     time.Time   `json:"last_restart,omitempty"`      // When the agent was last automatically restarted
+	LeaseOwner      string      `json:"lease_owner,omitempty"`       // Actor currently acting on the agent
+	LeaseExpiry     time.Time   `json:"lease_expiry,omitempty"`      // When LeaseOwner's lease lapses
+	LastHeartbeat   time.Time   `json:"last_heartbeat,omitempty"`    // When the agent last reported it was alive
+	Status          AgentStatus `json:"status,omitempty"`            // Reported status; empty is read as running
+
+	// Env is the extra environment the agent was started with. Values of
+	// sensitive variables are redacted, so they are not restored on restart.
+	Env map[string]string `json:"env,omitempty"`
+}
+
+// AgentStatus is an agent's status. Starting, running, blocked, completed
+// and failed are recorded with SetAgentStatus. Active is never recorded; it
+// groups every agent that has not finished, and is what Lifecycle reports
+// for them.
+type AgentStatus string
+
+const (
+	// AgentStatusActive means the agent has not signalled completion
+	AgentStatusActive AgentStatus = "active"
+	// AgentStatusCompleted means the agent finished and awaits cleanup
+	AgentStatusCompleted AgentStatus = "completed"
+	// AgentStatusFailed means the agent finished with a failure reason
+	AgentStatusFailed AgentStatus = "failed"
+	// AgentStatusStarting means the agent's process is being launched
+	AgentStatusStarting AgentStatus = "starting"
+	// AgentStatusRunning means the agent is working. Agents recorded before
+	// statuses existed load as running.
+	AgentStatusRunning AgentStatus = "running"
+	// AgentStatusBlocked means the agent is waiting on something outside
+	// itself, such as a human answer or a failing dependency
+	AgentStatusBlocked AgentStatus = "blocked"
+)
+
+// ParseAgentStatus converts a string to an AgentStatus, returning an error
+// if it is not a known status
+func ParseAgentStatus(s string) (AgentStatus, error) {
+	switch st := AgentStatus(s); st {
+	case AgentStatusActive, AgentStatusCompleted, AgentStatusFailed,
+		AgentStatusStarting, AgentStatusRunning, AgentStatusBlocked:
+		return st, nil
+	default:
+		return "", fmt.Errorf("invalid agent status: %q", s)
+	}
+}
+
+// Lifecycle reduces the agent's status to active, completed or failed. An
+// agent marked ready for cleanup has finished, and failed if it gave a
+// failure reason; an agent recorded as failed has failed; every other
+// agent is active.
+func (a Agent) Lifecycle() AgentStatus {
+	switch {
+	case a.ReadyForCleanup && a.FailureReason != "":
+		return AgentStatusFailed
+	case a.ReadyForCleanup:
+		return AgentStatusCompleted
+	case a.Status == AgentStatusFailed:
+		return AgentStatusFailed
+	default:
+		return AgentStatusActive
+	}
+}
+
+// CurrentStatus returns the agent's status with the lifecycle taken into
+// account: finished agents report completed or failed, and an unrecorded
+// status reads as running
+func (a Agent) CurrentStatus() AgentStatus {
+	if lc := a.Lifecycle(); lc != AgentStatusActive {
+		return lc
+	}
+	if a.Status == "" {
+		return AgentStatusRunning
+	}
+	return a.Status
+}
+
+// AgentFilter selects agents by type and status. Empty fields match every
+// agent; set fields must all match. A Status of active, completed or failed
+// matches the agent's Lifecycle; any other matches its CurrentStatus.
+type AgentFilter struct {
+	Type   AgentType
+	Status AgentStatus
+}
+
+// Matches reports whether agent passes the filter
+func (f AgentFilter) Matches(agent Agent) bool {
+	if f.Type != "" && agent.Type != f.Type {
+		return false
+	}
+	switch f.Status {
+	case "":
+	case AgentStatusActive, AgentStatusCompleted, AgentStatusFailed:
+		if agent.Lifecycle() != f.Status {
+			return false
+		}
+	default:
+		if agent.CurrentStatus() != f.Status {
+			return false
+		}
+	}
+	return true
+}
+
+// LeaseHeld reports whether someone other than owner holds an unexpired
+// lease on the agent at time now
+func (a Agent) LeaseHeld(owner string, now time.Time) bool {
+	return a.LeaseOwner != "" && a.LeaseOwner != owner && now.Before(a.LeaseExpiry)
+}
+
+// RestartBackoff is the schedule for automatically restarting dead agents.
+// The first restart is immediate; each later one waits twice as long as the
+// one before, up to Max. After MaxRestarts restarts the agent is left down.
+// An agent that stays up for StableAfter after a restart has its count
+// cleared, so occasional crashes spread over days never exhaust it.
+type RestartBackoff struct {
+	Initial     time.Duration // Delay before the second restart
+	Max         time.Duration // Longest delay between restarts
+	MaxRestarts int           // Restarts allowed before giving up (0 means unlimited)
+	StableAfter time.Duration // Uptime after which the restart count resets (0 means never)
+}
+
+// DefaultRestartBackoff is the schedule the daemon uses for persistent agents
+var DefaultRestartBackoff = RestartBackoff{
+	Initial:     30 * time.Second,
+	Max:         10 * time.Minute,
+	MaxRestarts: 5,
+	StableAfter: 30 * time.Minute,
+}
+
+// Delay returns how long to wait after the restartCount-th restart before
+// the next one
+func (b RestartBackoff) Delay(restartCount int) time.Duration {
+	if restartCount <= 0 {
+		return 0
+	}
+	delay := b.Initial
+	for i := 1; i < restartCount && delay < b.Max; i++ {
+		delay *= 2
+	}
+	if delay > b.Max {
+		delay = b.Max
+	}
+	return delay
+}
+
+// NextRestart returns the earliest time the agent may be restarted again.
+// It returns false if the agent has used up its restarts.
+func (b RestartBackoff) NextRestart(agent Agent) (time.Time, bool) {
+	if b.MaxRestarts > 0 && agent.RestartCount >= b.MaxRestarts {
+		return time.Time{}, false
+	}
+	if agent.RestartCount == 0 {
+		return time.Time{}, true
+	}
+	return agent.LastRestart.Add(b.Delay(agent.RestartCount)), true
+}
+
+// Stable reports whether an agent that is still running at now has been up
+// long enough since its last restart for its restart count to be cleared
+func (b RestartBackoff) Stable(agent Agent, now time.Time) bool {
+	if b.StableAfter <= 0 || agent.RestartCount == 0 {
+		return false
+	}
+	return !now.Before(agent.LastRestart.Add(b.StableAfter))
 }
 
 // Repository represents a tracked repository's state
 type Repository struct {
-	GithubURL        string             `json:"github_url"`
-	TmuxSession      string             `json:"tmux_session"`
-	Agents           map[string]Agent   `json:"agents"`
-	TaskHistory      []TaskHistoryEntry `json:"task_history,omitempty"`
-	MergeQueueConfig MergeQueueConfig   `json:"merge_queue_config,omitempty"`
-	PRShepherdConfig PRShepherdConfig   `json:"pr_shepherd_config,omitempty"`
-	ForkConfig       ForkConfig         `json:"fork_config,omitempty"`
-	TargetBranch     string             `json:"target_branch,omitempty"` // Default branch for PRs (usually "main")
+	GithubURL        string                     `json:"github_url"`
+	TmuxSession      string                     `json:"tmux_session"`
+	Agents           map[string]Agent           `json:"agents"`
+	TaskHistory      []TaskHistoryEntry         `json:"task_history,omitempty"`
+	MergeQueueConfig MergeQueueConfig           `json:"merge_queue_config,omitempty"`
+	PRShepherdConfig PRShepherdConfig           `json:"pr_shepherd_config,omitempty"`
+	ForkConfig       ForkConfig                 `json:"fork_config,omitempty"`
+	SpawnLimitConfig SpawnLimitConfig           `json:"spawn_limit_config,omitempty"`
+	ClaudeConfig     map[AgentType]ClaudeConfig `json:"claude_config,omitempty"` // Per agent type claude invocation
+	TargetBranch     string                     `json:"target_branch,omitempty"` // Default branch for PRs (usually "main")
+	MergeQueue       []MergeQueueEntry          `json:"merge_queue,omitempty"`   // PRs waiting to merge, head first
+}
+
+// clone returns a deep copy of the repository, so it can be read without
+// holding the state lock
+func (r *Repository) clone() *Repository {
+	c := *r
+	c.Agents = make(map[string]Agent, len(r.Agents))
+	for name, agent := range r.Agents {
+		c.Agents[name] = agent
+	}
+	if r.TaskHistory != nil {
+		c.TaskHistory = append([]TaskHistoryEntry(nil), r.TaskHistory...)
+	}
+	if r.ClaudeConfig != nil {
+		c.ClaudeConfig = make(map[AgentType]ClaudeConfig, len(r.ClaudeConfig))
+		for agentType, config := range r.ClaudeConfig {
+			config.ExtraArgs = append([]string(nil), config.ExtraArgs...)
+			c.ClaudeConfig[agentType] = config
+		}
+	}
+	if r.MergeQueue != nil {
+		c.MergeQueue = append([]MergeQueueEntry(nil), r.MergeQueue...)
+	}
+	return &c
 }
 
 // State represents the entire daemon state
 type State struct {
-	Repos       map[string]*Repository `json:"repos"`
-	CurrentRepo string                 `json:"current_repo,omitempty"`
-	mu          sync.RWMutex
-	path        string
+	SchemaVersion int                    `json:"schema_version"`
+	Repos         map[string]*Repository `json:"repos"`
+	CurrentRepo   string                 `json:"current_repo,omitempty"`
+	mu            sync.RWMutex
+	path          string
+	bus           *events.Bus
+
+	// now returns the current time. Tests replace it; nil means time.Now.
+	now func() time.Time
+
+	// pending collects events raised inside LockedUpdate until the update
+	// is written
+	pending []events.Event
+
+	// inUpdate is set on the copy LockedUpdate hands to its callback. Saves
+	// are skipped until the callback returns, then written once.
+	inUpdate bool
+
+	// fileLocked is set while a mutator holds the exclusive file lock taken
+	// by beginUpdate. disk describes the file as s last read or wrote it, so
+	// beginUpdate can tell whether another process has saved since.
+	fileLocked bool
+	disk       os.FileInfo
+
+	// backupKeep is how many backups Backup leaves in its directory; 0
+	// keeps them all
+	backupKeep int
 }
 
 // New creates a new empty state
 func New(path string) *State {
 	return &State{
-		Repos: make(map[string]*Repository),
-		path:  path,
+		SchemaVersion: CurrentSchemaVersion,
+		Repos:         make(map[string]*Repository),
+		path:          path,
 	}
 }
 
-// Load loads state from disk
+// Load loads state from disk, holding a shared lock on it while reading so
+// it never sees a write in progress. A reader that cannot create the lock
+// file reads without it. A file written at an older schema version is
+// migrated and saved back at the current version.
 func Load(path string) (*State, error) {
+	s, migrated, err := func() (*State, bool, error) {
+		if unlock, err := acquireLock(path, false); err == nil {
+			defer unlock()
+		}
+		return loadUnlocked(path)
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	if migrated {
+		if err := s.Save(); err != nil {
+			return nil, fmt.Errorf("failed to save migrated state: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// loadUnlocked reads and parses the state file, migrating it in memory if
+// it is at an older schema version. Caller handles locking.
+func loadUnlocked(path string) (*State, bool, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			// No state file, return empty state
-			return New(path), nil
+			return New(path), false, nil
 		}
-		return nil, fmt.Errorf("failed to read state file: %w", err)
+		return nil, false, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to stat state file: %w", err)
+	}
+	data, migrated, err := migrate(data, info.ModTime())
+	if err != nil {
+		return nil, false, err
 	}
 
 	var s State
 	if err := json.Unmarshal(data, &s); err != nil {
-		return nil, fmt.Errorf("failed to parse state file: %w", err)
+		return nil, false, fmt.Errorf("failed to parse state file: %w", err)
 	}
 
 	s.path = path
+	s.SchemaVersion = CurrentSchemaVersion
+	s.disk = info
 
 	// Initialize map if nil
 	if s.Repos == nil {
 		s.Repos = make(map[string]*Repository)
 	}
+	defaultAgentStatuses(s.Repos)
+	defaultMergeQueueRetries(s.Repos)
+
+	return &s, migrated, nil
+}
+
+// Save persists state to disk, replacing whatever is there. Mutators save
+// on their own; Save is for writing a state that was built in memory.
+func (s *State) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	return &s, nil
+	return s.saveUnlocked()
 }
 
-// atomicWrite writes data to a file atomically using a temp file and rename.
-// This prevents corruption if the process crashes during writing.
-func atomicWrite(path string, data []byte) error {
-	// Use a unique temp file to avoid races between concurrent saves.
-	// CreateTemp creates a file with a unique name in the same directory.
-	dir := filepath.Dir(path)
-	tmpFile, err := os.CreateTemp(dir, ".state-*.tmp")
+// LockedUpdate applies fn to the latest state on disk while holding an
+// exclusive lock on the state file, then saves the result. Writers that go
+// through LockedUpdate, in this process or another, are serialized, so none
+// of their changes are lost. fn may call the usual State methods on the
+// state it is given; nothing is written unless fn succeeds. On success the
+// receiver is refreshed to the saved state. Every mutator takes the same
+// lock through beginUpdate, so fn only needs LockedUpdate to make several
+// changes as one.
+func (s *State) LockedUpdate(fn func(*State) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := acquireLock(s.path, true)
 	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
+		return err
 	}
-	tmpPath := tmpFile.Name()
-
-	// Write data and close the file
-	_, writeErr := tmpFile.Write(data)
-	closeErr := tmpFile.Close()
+	defer unlock()
 
-	// Check for write or close errors
-	if writeErr != nil {
-		os.Remove(tmpPath) // Clean up temp file on error
-		return fmt.Errorf("failed to write state file: %w", writeErr)
+	fresh, _, err := loadUnlocked(s.path)
+	if err != nil {
+		return err
 	}
-	if closeErr != nil {
-		os.Remove(tmpPath) // Clean up temp file on error
-		return fmt.Errorf("failed to close temp file: %w", closeErr)
+	fresh.inUpdate = true
+	fresh.bus = s.bus
+	fresh.now = s.now
+
+	if err := fn(fresh); err != nil {
+		return err
 	}
 
-	// Atomic rename
-	if err := os.Rename(tmpPath, path); err != nil {
-		os.Remove(tmpPath) // Clean up temp file on error
-		return fmt.Errorf("failed to rename state file: %w", err)
+	fresh.mu.Lock()
+	fresh.inUpdate = false
+	err = fresh.writeUnlocked()
+	repos, current, disk, pending := fresh.Repos, fresh.CurrentRepo, fresh.disk, fresh.pending
+	fresh.mu.Unlock()
+	if err != nil {
+		return err
 	}
 
+	s.Repos = repos
+	s.CurrentRepo = current
+	s.disk = disk
+	if s.bus != nil {
+		for _, e := range pending {
+			s.bus.Publish(e)
+		}
+	}
 	return nil
 }
 
-// Save persists state to disk
-func (s *State) Save() error {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// beginUpdate starts a read-modify-write of the state: it takes s.mu and the
+// exclusive file lock, then reloads the state if another process has saved
+// it since s last read or wrote it, so the change is made to the latest
+// state rather than overwriting it. Inside LockedUpdate, which already holds
+// the file lock, only s.mu is taken. The returned function releases both.
+func (s *State) beginUpdate() (func(), error) {
+	s.mu.Lock()
+	if s.inUpdate {
+		return s.mu.Unlock, nil
+	}
 
-	data, err := json.MarshalIndent(s, "", "  ")
+	unlock, err := acquireLock(s.path, true)
 	if err != nil {
-		return fmt.Errorf("failed to marshal state: %w", err)
+		s.mu.Unlock()
+		return nil, err
+	}
+	if err := s.refreshUnlocked(); err != nil {
+		unlock()
+		s.mu.Unlock()
+		return nil, err
+	}
+
+	s.fileLocked = true
+	return func() {
+		s.fileLocked = false
+		unlock()
+		s.mu.Unlock()
+	}, nil
+}
+
+// refreshUnlocked reloads the state from disk if the file has changed since
+// s last read or wrote it. Caller must hold s.mu and the file lock.
+func (s *State) refreshUnlocked() error {
+	info, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat state file: %w", err)
+	}
+	if s.disk != nil && os.SameFile(s.disk, info) && s.disk.ModTime().Equal(info.ModTime()) && s.disk.Size() == info.Size() {
+		return nil
 	}
 
-	return atomicWrite(s.path, data)
+	fresh, _, err := loadUnlocked(s.path)
+	if err != nil {
+		return err
+	}
+	s.Repos = fresh.Repos
+	s.CurrentRepo = fresh.CurrentRepo
+	s.disk = fresh.disk
+	return nil
 }
 
 // AddRepo adds a new repository to the state
 func (s *State) AddRepo(name string, repo *Repository) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	release, err := s.beginUpdate()
+	if err != nil {
+		return err
+	}
+	defer release()
 
 	if _, exists := s.Repos[name]; exists {
-		return fmt.Errorf("repository %q already exists", name)
+		return fmt.Errorf("%w: %q", ErrRepoExists, name)
 	}
 
 	if repo.Agents == nil {
@@ -272,29 +707,74 @@ func (s *State) AddRepo(name string, repo *Repository) error {
 	}
 
 	s.Repos[name] = repo
-	return s.saveUnlocked()
+	return s.saveAndPublishUnlocked(events.Added, name, "")
 }
 
-// GetRepo returns a repository by name
+// GetRepo returns a copy of a repository by name. Changes to the copy are
+// not saved; use the Update methods instead.
 func (s *State) GetRepo(name string) (*Repository, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	repo, exists := s.Repos[name]
-	return repo, exists
+	if !exists {
+		return nil, false
+	}
+	return repo.clone(), true
 }
 
 // RemoveRepo removes a repository from the state
 func (s *State) RemoveRepo(name string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	release, err := s.beginUpdate()
+	if err != nil {
+		return err
+	}
+	defer release()
 
 	if _, exists := s.Repos[name]; !exists {
-		return fmt.Errorf("repository %q not found", name)
+		return fmt.Errorf("%w: %q", ErrRepoNotFound, name)
 	}
 
 	delete(s.Repos, name)
-	return s.saveUnlocked()
+	return s.saveAndPublishUnlocked(events.Removed, name, "")
+}
+
+// RenameRepo moves a repository to a new name, keeping its config, and sets
+// its TmuxSession to tmuxSession. The current repository follows the rename.
+// Agents keep paths under the old name, so a repository that has any is
+// refused with ErrRepoHasAgents. Moving the repository's directories and
+// session is up to the caller.
+func (s *State) RenameRepo(oldName, newName, tmuxSession string) error {
+	release, err := s.beginUpdate()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	repo, exists := s.Repos[oldName]
+	if !exists {
+		return fmt.Errorf("%w: %q", ErrRepoNotFound, oldName)
+	}
+	if _, taken := s.Repos[newName]; taken {
+		return fmt.Errorf("%w: %q", ErrRepoExists, newName)
+	}
+	if len(repo.Agents) > 0 {
+		return fmt.Errorf("%w: %q has %d; remove them before renaming", ErrRepoHasAgents, oldName, len(repo.Agents))
+	}
+
+	delete(s.Repos, oldName)
+	repo.TmuxSession = tmuxSession
+	s.Repos[newName] = repo
+	if s.CurrentRepo == oldName {
+		s.CurrentRepo = newName
+	}
+	if err := s.saveUnlocked(); err != nil {
+		return err
+	}
+
+	s.publishChangeUnlocked(events.Removed, oldName, "")
+	s.publishChangeUnlocked(events.Added, newName, "")
+	return nil
 }
 
 // ListRepos returns all repository names
@@ -312,23 +792,40 @@ func (s *State) ListRepos() []string {
 // ClearAllAgents removes all agents from all repositories
 // but preserves the repository entries themselves
 func (s *State) ClearAllAgents() error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	release, err := s.beginUpdate()
+	if err != nil {
+		return err
+	}
+	defer release()
 
-	for _, repo := range s.Repos {
+	var removed []AgentRef
+	for repoName, repo := range s.Repos {
+		for agentName := range repo.Agents {
+			removed = append(removed, AgentRef{Repo: repoName, Name: agentName})
+		}
 		repo.Agents = make(map[string]Agent)
 	}
-	return s.saveUnlocked()
+	if err := s.saveUnlocked(); err != nil {
+		return err
+	}
+
+	for _, ref := range removed {
+		s.publishChangeUnlocked(events.Removed, ref.Repo, ref.Name)
+	}
+	return nil
 }
 
 // SetCurrentRepo sets the current/default repository
 func (s *State) SetCurrentRepo(name string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	release, err := s.beginUpdate()
+	if err != nil {
+		return err
+	}
+	defer release()
 
 	// Verify the repo exists
 	if _, exists := s.Repos[name]; !exists {
-		return fmt.Errorf("repository %q not found", name)
+		return fmt.Errorf("%w: %q", ErrRepoNotFound, name)
 	}
 
 	s.CurrentRepo = name
@@ -344,8 +841,11 @@ func (s *State) GetCurrentRepo() string {
 
 // ClearCurrentRepo clears the current/default repository
 func (s *State) ClearCurrentRepo() error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	release, err := s.beginUpdate()
+	if err != nil {
+		return err
+	}
+	defer release()
 
 	s.CurrentRepo = ""
 	return s.saveUnlocked()
@@ -360,101 +860,539 @@ func (s *State) GetAllRepos() map[string]*Repository {
 	// Create a deep copy to avoid concurrent access issues
 	repos := make(map[string]*Repository, len(s.Repos))
 	for name, repo := range s.Repos {
-		// Copy the repository
-		repoCopy := &Repository{
-			GithubURL:        repo.GithubURL,
-			TmuxSession:      repo.TmuxSession,
-			Agents:           make(map[string]Agent, len(repo.Agents)),
-			MergeQueueConfig: repo.MergeQueueConfig,
-			PRShepherdConfig: repo.PRShepherdConfig,
-			ForkConfig:       repo.ForkConfig,
-			TargetBranch:     repo.TargetBranch,
-		}
-		// Copy agents
-		for agentName, agent := range repo.Agents {
-			repoCopy.Agents[agentName] = agent
-		}
-		// Copy task history
-		if repo.TaskHistory != nil {
-			repoCopy.TaskHistory = make([]TaskHistoryEntry, len(repo.TaskHistory))
-			copy(repoCopy.TaskHistory, repo.TaskHistory)
-		}
-		repos[name] = repoCopy
+		repos[name] = repo.clone()
 	}
 	return repos
 }
 
-// AddAgent adds a new agent to a repository
-func (s *State) AddAgent(repoName, agentName string, agent Agent) error {
+// SetEventBus attaches a bus that agent mutators publish lifecycle events to.
+// Events are published while the state lock is held, so subscribers see them
+// in the order the changes were made.
+func (s *State) SetEventBus(bus *events.Bus) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.bus = bus
+}
+
+// publishUnlocked publishes an agent lifecycle event if a bus is attached.
+// Caller must hold s.mu.
+func (s *State) publishUnlocked(t events.Type, repoName, agentName string, agent Agent) {
+	s.emitUnlocked(events.Event{
+		Type:      t,
+		Repo:      repoName,
+		Agent:     agentName,
+		AgentType: string(agent.Type),
+		Reason:    agent.FailureReason,
+		StartedAt: agent.CreatedAt,
+	})
+}
+
+// publishChangeUnlocked publishes an Added, Updated or Removed event for a
+// repository, or for an agent if agentName is set. Caller must hold s.mu
+// and must only call it once the change has been saved.
+func (s *State) publishChangeUnlocked(t events.Type, repoName, agentName string) {
+	s.emitUnlocked(events.Event{Type: t, Repo: repoName, Agent: agentName, Time: s.clock()})
+}
+
+// saveAndPublishUnlocked saves state and, if that succeeds, publishes the
+// change. Caller must hold s.mu.
+func (s *State) saveAndPublishUnlocked(t events.Type, repoName, agentName string) error {
+	if err := s.saveUnlocked(); err != nil {
+		return err
+	}
+	s.publishChangeUnlocked(t, repoName, agentName)
+	return nil
+}
+
+// emitUnlocked publishes e if a bus is attached. Inside LockedUpdate the
+// event is held until the update is written. Caller must hold s.mu.
+func (s *State) emitUnlocked(e events.Event) {
+	if s.bus == nil {
+		return
+	}
+	if s.inUpdate {
+		s.pending = append(s.pending, e)
+		return
+	}
+	s.bus.Publish(e)
+}
+
+// AddAgent adds a new agent to a repository
+func (s *State) AddAgent(repoName, agentName string, agent Agent) error {
+	if err := names.ValidateAgentName(agentName); err != nil {
+		return err
+	}
+
+	release, err := s.beginUpdate()
+	if err != nil {
+		return err
+	}
+	defer release()
 
 	repo, exists := s.Repos[repoName]
 	if !exists {
-		return fmt.Errorf("repository %q not found", repoName)
+		return fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
 	}
 
 	if _, exists := repo.Agents[agentName]; exists {
-		return fmt.Errorf("agent %q already exists in repository %q", agentName, repoName)
+		return fmt.Errorf("%w: %q in repository %q", ErrAgentExists, agentName, repoName)
 	}
 
 	repo.Agents[agentName] = agent
-	return s.saveUnlocked()
+	if err := s.saveUnlocked(); err != nil {
+		return err
+	}
+
+	s.publishChangeUnlocked(events.Added, repoName, agentName)
+	s.publishUnlocked(events.AgentSpawned, repoName, agentName, agent)
+	return nil
+}
+
+// AssignReview registers a review agent for agent.PRNumber. The check and the
+// insert happen under one lock, so a PR can never be assigned to two active
+// review agents. Review agents that are ready for cleanup no longer hold
+// their PR.
+func (s *State) AssignReview(repoName, agentName string, agent Agent) error {
+	if err := names.ValidateAgentName(agentName); err != nil {
+		return err
+	}
+
+	release, err := s.beginUpdate()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	repo, exists := s.Repos[repoName]
+	if !exists {
+		return fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
+	}
+
+	if agent.Type != AgentTypeReview || agent.PRNumber <= 0 {
+		return fmt.Errorf("review assignment requires a review agent with a PR number")
+	}
+
+	for name, existing := range repo.Agents {
+		if existing.Type == AgentTypeReview && existing.PRNumber == agent.PRNumber && !existing.ReadyForCleanup {
+			return fmt.Errorf("PR #%d is already assigned to review agent %q", agent.PRNumber, name)
+		}
+	}
+
+	if _, exists := repo.Agents[agentName]; exists {
+		return fmt.Errorf("%w: %q in repository %q", ErrAgentExists, agentName, repoName)
+	}
+
+	repo.Agents[agentName] = agent
+	if err := s.saveUnlocked(); err != nil {
+		return err
+	}
+
+	s.publishChangeUnlocked(events.Added, repoName, agentName)
+	s.publishUnlocked(events.AgentSpawned, repoName, agentName, agent)
+	return nil
 }
 
 // UpdateAgent updates an existing agent
 func (s *State) UpdateAgent(repoName, agentName string, agent Agent) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	release, err := s.beginUpdate()
+	if err != nil {
+		return err
+	}
+	defer release()
 
 	repo, exists := s.Repos[repoName]
 	if !exists {
-		return fmt.Errorf("repository %q not found", repoName)
+		return fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
 	}
 
-	if _, exists := repo.Agents[agentName]; !exists {
-		return fmt.Errorf("agent %q not found in repository %q", agentName, repoName)
+	previous, exists := repo.Agents[agentName]
+	if !exists {
+		return fmt.Errorf("%w: %q in repository %q", ErrAgentNotFound, agentName, repoName)
+	}
+
+	// Record how a finishing agent ended in its status too
+	if lc := agent.Lifecycle(); lc != AgentStatusActive {
+		agent.Status = lc
 	}
 
 	repo.Agents[agentName] = agent
-	return s.saveUnlocked()
+	if err := s.saveUnlocked(); err != nil {
+		return err
+	}
+	s.publishChangeUnlocked(events.Updated, repoName, agentName)
+	s.publishFinishedUnlocked(repoName, agentName, previous, agent)
+	return nil
+}
+
+// publishFinishedUnlocked publishes AgentCompleted or AgentFailed if the
+// change from previous to agent is the one that finished the agent. Caller
+// must hold s.mu.
+func (s *State) publishFinishedUnlocked(repoName, agentName string, previous, agent Agent) {
+	if previous.Lifecycle() != AgentStatusActive {
+		return
+	}
+	switch agent.Lifecycle() {
+	case AgentStatusFailed:
+		s.publishUnlocked(events.AgentFailed, repoName, agentName, agent)
+	case AgentStatusCompleted:
+		s.publishUnlocked(events.AgentCompleted, repoName, agentName, agent)
+	}
 }
 
 // UpdateAgentPID updates just the PID of an agent
 func (s *State) UpdateAgentPID(repoName, agentName string, pid int) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	release, err := s.beginUpdate()
+	if err != nil {
+		return err
+	}
+	defer release()
 
 	repo, exists := s.Repos[repoName]
 	if !exists {
-		return fmt.Errorf("repository %q not found", repoName)
+		return fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
 	}
 
 	agent, exists := repo.Agents[agentName]
 	if !exists {
-		return fmt.Errorf("agent %q not found in repository %q", agentName, repoName)
+		return fmt.Errorf("%w: %q in repository %q", ErrAgentNotFound, agentName, repoName)
 	}
 
 	agent.PID = pid
 	repo.Agents[agentName] = agent
-	return s.saveUnlocked()
+	return s.saveAndPublishUnlocked(events.Updated, repoName, agentName)
 }
 
-// RemoveAgent removes an agent from a repository
-func (s *State) RemoveAgent(repoName, agentName string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// SetAgentStatus records an agent's status. Active is not a status an agent
+// can be put in; use starting, running, blocked, completed or failed.
+// Setting failed on an active agent finishes it, publishing AgentFailed.
+func (s *State) SetAgentStatus(repoName, agentName string, status AgentStatus) error {
+	if _, err := ParseAgentStatus(string(status)); err != nil || status == AgentStatusActive {
+		return fmt.Errorf("invalid agent status: %q", status)
+	}
+
+	release, err := s.beginUpdate()
+	if err != nil {
+		return err
+	}
+	defer release()
 
 	repo, exists := s.Repos[repoName]
 	if !exists {
-		return fmt.Errorf("repository %q not found", repoName)
+		return fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
 	}
 
-	delete(repo.Agents, agentName)
-	return s.saveUnlocked()
-}
+	previous, exists := repo.Agents[agentName]
+	if !exists {
+		return fmt.Errorf("%w: %q in repository %q", ErrAgentNotFound, agentName, repoName)
+	}
 
-// GetAgent returns an agent by name
+	agent := previous
+	agent.Status = status
+	repo.Agents[agentName] = agent
+	if err := s.saveAndPublishUnlocked(events.Updated, repoName, agentName); err != nil {
+		return err
+	}
+	s.publishFinishedUnlocked(repoName, agentName, previous, agent)
+	return nil
+}
+
+// defaultAgentStatuses sets agents saved without a status, by builds that
+// predate it, to running
+func defaultAgentStatuses(repos map[string]*Repository) {
+	for _, repo := range repos {
+		if repo == nil {
+			continue
+		}
+		for name, agent := range repo.Agents {
+			if agent.Status == "" {
+				agent.Status = AgentStatusRunning
+				repo.Agents[name] = agent
+			}
+		}
+	}
+}
+
+// defaultMergeQueueRetries applies the default retry count to merge queue
+// configs saved before MaxRetries existed
+func defaultMergeQueueRetries(repos map[string]*Repository) {
+	for _, repo := range repos {
+		if repo != nil && repo.MergeQueueConfig.TrackMode != "" && repo.MergeQueueConfig.MaxRetries == 0 {
+			repo.MergeQueueConfig.MaxRetries = DefaultMergeQueueMaxRetries
+		}
+	}
+}
+
+// RecordRestart counts an automatic restart of an agent at the given time
+func (s *State) RecordRestart(repoName, agentName string, at time.Time) error {
+	release, err := s.beginUpdate()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	repo, exists := s.Repos[repoName]
+	if !exists {
+		return fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
+	}
+
+	agent, exists := repo.Agents[agentName]
+	if !exists {
+		return fmt.Errorf("%w: %q in repository %q", ErrAgentNotFound, agentName, repoName)
+	}
+
+	agent.RestartCount++
+	agent.LastRestart = at
+	repo.Agents[agentName] = agent
+	return s.saveAndPublishUnlocked(events.Updated, repoName, agentName)
+}
+
+// UpdateHeartbeat records that an agent reported itself alive at the given time
+func (s *State) UpdateHeartbeat(repoName, agentName string, at time.Time) error {
+	release, err := s.beginUpdate()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	repo, exists := s.Repos[repoName]
+	if !exists {
+		return fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
+	}
+
+	agent, exists := repo.Agents[agentName]
+	if !exists {
+		return fmt.Errorf("%w: %q in repository %q", ErrAgentNotFound, agentName, repoName)
+	}
+
+	agent.LastHeartbeat = at
+	repo.Agents[agentName] = agent
+	return s.saveAndPublishUnlocked(events.Updated, repoName, agentName)
+}
+
+// Heartbeat records that an agent is alive as of now
+func (s *State) Heartbeat(repoName, agentName string) error {
+	return s.UpdateHeartbeat(repoName, agentName, s.clock())
+}
+
+// AgentRef identifies an agent by repository and name, with its record as
+// it stood when the reference was taken.
+type AgentRef struct {
+	Repo  string
+	Name  string
+	Agent Agent
+}
+
+// sortAgentRefs orders refs by repo then agent name
+func sortAgentRefs(refs []AgentRef) {
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Repo != refs[j].Repo {
+			return refs[i].Repo < refs[j].Repo
+		}
+		return refs[i].Name < refs[j].Name
+	})
+}
+
+// StaleAgents returns agents whose last heartbeat is older than threshold,
+// ordered by repo then agent name. Agents that have never sent a heartbeat
+// or are already marked ready for cleanup are not considered stale.
+func (s *State) StaleAgents(threshold time.Duration) []AgentRef {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cutoff := s.clock().Add(-threshold)
+	var stale []AgentRef
+	for repoName, repo := range s.Repos {
+		for agentName, agent := range repo.Agents {
+			if agent.LastHeartbeat.IsZero() || agent.ReadyForCleanup {
+				continue
+			}
+			if agent.LastHeartbeat.Before(cutoff) {
+				stale = append(stale, AgentRef{Repo: repoName, Name: agentName, Agent: agent})
+			}
+		}
+	}
+	sortAgentRefs(stale)
+	return stale
+}
+
+// clock returns the current time from s.now, falling back to time.Now
+func (s *State) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+// ResetRestarts clears an agent's restart count so automatic restarts start
+// over from the beginning of the backoff schedule
+func (s *State) ResetRestarts(repoName, agentName string) error {
+	release, err := s.beginUpdate()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	repo, exists := s.Repos[repoName]
+	if !exists {
+		return fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
+	}
+
+	agent, exists := repo.Agents[agentName]
+	if !exists {
+		return fmt.Errorf("%w: %q in repository %q", ErrAgentNotFound, agentName, repoName)
+	}
+
+	agent.RestartCount = 0
+	agent.LastRestart = time.Time{}
+	repo.Agents[agentName] = agent
+	return s.saveAndPublishUnlocked(events.Updated, repoName, agentName)
+}
+
+// AcquireLease gives owner exclusive use of an agent for ttl. It returns
+// false if another owner holds an unexpired lease. An owner acquiring a lease
+// it already holds renews it, and an expired lease can be taken by anyone.
+func (s *State) AcquireLease(repoName, agentName, owner string, ttl time.Duration) (bool, error) {
+	if owner == "" {
+		return false, fmt.Errorf("lease owner is required")
+	}
+
+	release, err := s.beginUpdate()
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	repo, exists := s.Repos[repoName]
+	if !exists {
+		return false, fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
+	}
+
+	agent, exists := repo.Agents[agentName]
+	if !exists {
+		return false, fmt.Errorf("%w: %q in repository %q", ErrAgentNotFound, agentName, repoName)
+	}
+
+	now := time.Now()
+	if agent.LeaseHeld(owner, now) {
+		return false, nil
+	}
+
+	agent.LeaseOwner = owner
+	agent.LeaseExpiry = now.Add(ttl)
+	repo.Agents[agentName] = agent
+	if err := s.saveAndPublishUnlocked(events.Updated, repoName, agentName); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ReleaseLease gives up owner's lease on an agent. Releasing a lease owner
+// doesn't hold is a no-op, so a lease that expired and was taken by someone
+// else is left alone.
+func (s *State) ReleaseLease(repoName, agentName, owner string) error {
+	release, err := s.beginUpdate()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	repo, exists := s.Repos[repoName]
+	if !exists {
+		return fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
+	}
+
+	agent, exists := repo.Agents[agentName]
+	if !exists {
+		return fmt.Errorf("%w: %q in repository %q", ErrAgentNotFound, agentName, repoName)
+	}
+
+	if agent.LeaseOwner != owner {
+		return nil
+	}
+
+	agent.LeaseOwner = ""
+	agent.LeaseExpiry = time.Time{}
+	repo.Agents[agentName] = agent
+	return s.saveAndPublishUnlocked(events.Updated, repoName, agentName)
+}
+
+// RemoveAgent removes an agent from a repository and appends it to the
+// agent history archive. The archive is written first, so a failure there
+// leaves the agent in place; a save that fails afterwards can leave a record
+// for an agent that is archived again when it is next removed.
+func (s *State) RemoveAgent(repoName, agentName string) error {
+	release, err := s.beginUpdate()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	repo, exists := s.Repos[repoName]
+	if !exists {
+		return fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
+	}
+
+	agent, existed := repo.Agents[agentName]
+	if existed {
+		if err := s.archiveAgentUnlocked(repoName, agentName, agent); err != nil {
+			return err
+		}
+	}
+	delete(repo.Agents, agentName)
+	if err := s.saveUnlocked(); err != nil {
+		return err
+	}
+
+	if existed {
+		s.publishChangeUnlocked(events.Removed, repoName, agentName)
+	}
+	return nil
+}
+
+// PruneReadyAgents removes every agent marked ReadyForCleanup across all
+// repositories in a single save and returns what it removed, ordered by repo
+// then agent name, with each record as it stood so the caller can tear down
+// its worktree and window. Removed agents are archived to the history log first, as
+// in RemoveAgent.
+func (s *State) PruneReadyAgents() ([]AgentRef, error) {
+	release, err := s.beginUpdate()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var pruned []AgentRef
+	for repoName, repo := range s.Repos {
+		for agentName, agent := range repo.Agents {
+			if agent.ReadyForCleanup {
+				pruned = append(pruned, AgentRef{Repo: repoName, Name: agentName, Agent: agent})
+			}
+		}
+	}
+	if len(pruned) == 0 {
+		return nil, nil
+	}
+	sortAgentRefs(pruned)
+
+	for _, ref := range pruned {
+		if err := s.archiveAgentUnlocked(ref.Repo, ref.Name, ref.Agent); err != nil {
+			return nil, err
+		}
+	}
+	for _, ref := range pruned {
+		delete(s.Repos[ref.Repo].Agents, ref.Name)
+	}
+	if err := s.saveUnlocked(); err != nil {
+		return nil, err
+	}
+
+	for _, ref := range pruned {
+		s.publishChangeUnlocked(events.Removed, ref.Repo, ref.Name)
+	}
+	return pruned, nil
+}
+
+// GetAgent returns an agent by name
 func (s *State) GetAgent(repoName, agentName string) (Agent, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -475,7 +1413,7 @@ func (s *State) ListAgents(repoName string) ([]string, error) {
 
 	repo, exists := s.Repos[repoName]
 	if !exists {
-		return nil, fmt.Errorf("repository %q not found", repoName)
+		return nil, fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
 	}
 
 	agents := make([]string, 0, len(repo.Agents))
@@ -485,6 +1423,96 @@ func (s *State) ListAgents(repoName string) ([]string, error) {
 	return agents, nil
 }
 
+// ListAgentNamesPage returns up to limit names of agents matching filter,
+// sorted, starting at offset, along with the total number of matches. A
+// limit of zero or less returns everything from offset on; an offset past
+// the end returns an empty page.
+func (s *State) ListAgentNamesPage(repoName string, filter AgentFilter, offset, limit int) ([]string, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	repo, exists := s.Repos[repoName]
+	if !exists {
+		return nil, 0, fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
+	}
+	return agentNamesPage(repo, filter, offset, limit)
+}
+
+// ListAgentsPage returns up to limit agents, in name order, starting at
+// offset, along with the total number of agents in the repository
+func (s *State) ListAgentsPage(repoName string, offset, limit int) ([]Agent, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	repo, exists := s.Repos[repoName]
+	if !exists {
+		return nil, 0, fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
+	}
+	names, total, err := agentNamesPage(repo, AgentFilter{}, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	agents := make([]Agent, 0, len(names))
+	for _, name := range names {
+		agents = append(agents, repo.Agents[name])
+	}
+	return agents, total, nil
+}
+
+// GetAgentsByType returns the names of agents of the given type, sorted
+func (s *State) GetAgentsByType(repoName string, agentType AgentType) ([]string, error) {
+	names, _, err := s.ListAgentNamesPage(repoName, AgentFilter{Type: agentType}, 0, 0)
+	return names, err
+}
+
+// agentNamesPage slices repo's sorted, filtered agent names; callers hold
+// the lock
+func agentNamesPage(repo *Repository, filter AgentFilter, offset, limit int) ([]string, int, error) {
+	if offset < 0 {
+		return nil, 0, fmt.Errorf("offset must not be negative, got %d", offset)
+	}
+
+	names := make([]string, 0, len(repo.Agents))
+	for name, agent := range repo.Agents {
+		if filter.Matches(agent) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	total := len(names)
+	if offset >= total {
+		return []string{}, total, nil
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+	return names[offset:end], total, nil
+}
+
+// AgentsOnBranch returns the names of agents in a repository working on
+// branch, sorted
+func (s *State) AgentsOnBranch(repoName, branch string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	repo, exists := s.Repos[repoName]
+	if !exists {
+		return nil, fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
+	}
+
+	var agents []string
+	for name, agent := range repo.Agents {
+		if agent.Branch == branch {
+			agents = append(agents, name)
+		}
+	}
+	sort.Strings(agents)
+	return agents, nil
+}
+
 // GetMergeQueueConfig returns the merge queue config for a repository
 func (s *State) GetMergeQueueConfig(repoName string) (MergeQueueConfig, error) {
 	s.mu.RLock()
@@ -492,28 +1520,35 @@ func (s *State) GetMergeQueueConfig(repoName string) (MergeQueueConfig, error) {
 
 	repo, exists := s.Repos[repoName]
 	if !exists {
-		return MergeQueueConfig{}, fmt.Errorf("repository %q not found", repoName)
+		return MergeQueueConfig{}, fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
 	}
 
 	// Return default config if not set (for backward compatibility)
 	if repo.MergeQueueConfig.TrackMode == "" {
 		return DefaultMergeQueueConfig(), nil
 	}
-	return repo.MergeQueueConfig, nil
+	config := repo.MergeQueueConfig
+	if config.MaxRetries == 0 {
+		config.MaxRetries = DefaultMergeQueueMaxRetries
+	}
+	return config, nil
 }
 
 // UpdateMergeQueueConfig updates the merge queue config for a repository
 func (s *State) UpdateMergeQueueConfig(repoName string, config MergeQueueConfig) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	release, err := s.beginUpdate()
+	if err != nil {
+		return err
+	}
+	defer release()
 
 	repo, exists := s.Repos[repoName]
 	if !exists {
-		return fmt.Errorf("repository %q not found", repoName)
+		return fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
 	}
 
 	repo.MergeQueueConfig = config
-	return s.saveUnlocked()
+	return s.saveAndPublishUnlocked(events.Updated, repoName, "")
 }
 
 // GetPRShepherdConfig returns the PR shepherd config for a repository
@@ -523,7 +1558,7 @@ func (s *State) GetPRShepherdConfig(repoName string) (PRShepherdConfig, error) {
 
 	repo, exists := s.Repos[repoName]
 	if !exists {
-		return PRShepherdConfig{}, fmt.Errorf("repository %q not found", repoName)
+		return PRShepherdConfig{}, fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
 	}
 
 	// Return default config if not set (for backward compatibility)
@@ -535,16 +1570,89 @@ func (s *State) GetPRShepherdConfig(repoName string) (PRShepherdConfig, error) {
 
 // UpdatePRShepherdConfig updates the PR shepherd config for a repository
 func (s *State) UpdatePRShepherdConfig(repoName string, config PRShepherdConfig) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	release, err := s.beginUpdate()
+	if err != nil {
+		return err
+	}
+	defer release()
 
 	repo, exists := s.Repos[repoName]
 	if !exists {
-		return fmt.Errorf("repository %q not found", repoName)
+		return fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
 	}
 
 	repo.PRShepherdConfig = config
-	return s.saveUnlocked()
+	return s.saveAndPublishUnlocked(events.Updated, repoName, "")
+}
+
+// GetSpawnLimitConfig returns the spawn limits for a repository
+func (s *State) GetSpawnLimitConfig(repoName string) (SpawnLimitConfig, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	repo, exists := s.Repos[repoName]
+	if !exists {
+		return SpawnLimitConfig{}, fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
+	}
+	return repo.SpawnLimitConfig, nil
+}
+
+// UpdateSpawnLimitConfig updates the spawn limits for a repository
+func (s *State) UpdateSpawnLimitConfig(repoName string, config SpawnLimitConfig) error {
+	release, err := s.beginUpdate()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	repo, exists := s.Repos[repoName]
+	if !exists {
+		return fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
+	}
+
+	repo.SpawnLimitConfig = config
+	return s.saveAndPublishUnlocked(events.Updated, repoName, "")
+}
+
+// GetClaudeConfig returns how claude is invoked for an agent type in a
+// repository. The zero value means the defaults apply.
+func (s *State) GetClaudeConfig(repoName string, agentType AgentType) (ClaudeConfig, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	repo, exists := s.Repos[repoName]
+	if !exists {
+		return ClaudeConfig{}, fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
+	}
+
+	config := repo.ClaudeConfig[agentType]
+	config.ExtraArgs = append([]string(nil), config.ExtraArgs...)
+	return config, nil
+}
+
+// UpdateClaudeConfig sets how claude is invoked for an agent type in a
+// repository. A zero config restores the defaults.
+func (s *State) UpdateClaudeConfig(repoName string, agentType AgentType, config ClaudeConfig) error {
+	release, err := s.beginUpdate()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	repo, exists := s.Repos[repoName]
+	if !exists {
+		return fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
+	}
+
+	if config.IsZero() {
+		delete(repo.ClaudeConfig, agentType)
+	} else {
+		if repo.ClaudeConfig == nil {
+			repo.ClaudeConfig = make(map[AgentType]ClaudeConfig)
+		}
+		repo.ClaudeConfig[agentType] = config
+	}
+	return s.saveAndPublishUnlocked(events.Updated, repoName, "")
 }
 
 // GetForkConfig returns the fork config for a repository
@@ -554,7 +1662,7 @@ func (s *State) GetForkConfig(repoName string) (ForkConfig, error) {
 
 	repo, exists := s.Repos[repoName]
 	if !exists {
-		return ForkConfig{}, fmt.Errorf("repository %q not found", repoName)
+		return ForkConfig{}, fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
 	}
 
 	return repo.ForkConfig, nil
@@ -562,16 +1670,19 @@ func (s *State) GetForkConfig(repoName string) (ForkConfig, error) {
 
 // UpdateForkConfig updates the fork config for a repository
 func (s *State) UpdateForkConfig(repoName string, config ForkConfig) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	release, err := s.beginUpdate()
+	if err != nil {
+		return err
+	}
+	defer release()
 
 	repo, exists := s.Repos[repoName]
 	if !exists {
-		return fmt.Errorf("repository %q not found", repoName)
+		return fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
 	}
 
 	repo.ForkConfig = config
-	return s.saveUnlocked()
+	return s.saveAndPublishUnlocked(events.Updated, repoName, "")
 }
 
 // IsForkMode returns true if the repository should operate in fork mode.
@@ -590,16 +1701,19 @@ func (s *State) IsForkMode(repoName string) bool {
 
 // AddTaskHistory adds a completed task to the repository's history
 func (s *State) AddTaskHistory(repoName string, entry TaskHistoryEntry) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	release, err := s.beginUpdate()
+	if err != nil {
+		return err
+	}
+	defer release()
 
 	repo, exists := s.Repos[repoName]
 	if !exists {
-		return fmt.Errorf("repository %q not found", repoName)
+		return fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
 	}
 
 	repo.TaskHistory = append(repo.TaskHistory, entry)
-	return s.saveUnlocked()
+	return s.saveAndPublishUnlocked(events.Updated, repoName, "")
 }
 
 // GetTaskHistory returns the task history for a repository, optionally limited to N entries
@@ -609,7 +1723,7 @@ func (s *State) GetTaskHistory(repoName string, limit int) ([]TaskHistoryEntry,
 
 	repo, exists := s.Repos[repoName]
 	if !exists {
-		return nil, fmt.Errorf("repository %q not found", repoName)
+		return nil, fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
 	}
 
 	history := repo.TaskHistory
@@ -633,12 +1747,15 @@ func (s *State) GetTaskHistory(repoName string, limit int) ([]TaskHistoryEntry,
 
 // UpdateTaskHistoryStatus updates the status and PR info for a task by name
 func (s *State) UpdateTaskHistoryStatus(repoName, taskName string, status TaskStatus, prURL string, prNumber int) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	release, err := s.beginUpdate()
+	if err != nil {
+		return err
+	}
+	defer release()
 
 	repo, exists := s.Repos[repoName]
 	if !exists {
-		return fmt.Errorf("repository %q not found", repoName)
+		return fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
 	}
 
 	// Find the most recent entry with this name and update it
@@ -651,7 +1768,7 @@ func (s *State) UpdateTaskHistoryStatus(repoName, taskName string, status TaskSt
 			if prNumber > 0 {
 				repo.TaskHistory[i].PRNumber = prNumber
 			}
-			return s.saveUnlocked()
+			return s.saveAndPublishUnlocked(events.Updated, repoName, "")
 		}
 	}
 
@@ -660,12 +1777,15 @@ func (s *State) UpdateTaskHistoryStatus(repoName, taskName string, status TaskSt
 
 // UpdateTaskHistorySummary updates the summary and failure reason for a task by name
 func (s *State) UpdateTaskHistorySummary(repoName, taskName, summary, failureReason string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	release, err := s.beginUpdate()
+	if err != nil {
+		return err
+	}
+	defer release()
 
 	repo, exists := s.Repos[repoName]
 	if !exists {
-		return fmt.Errorf("repository %q not found", repoName)
+		return fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
 	}
 
 	// Find the most recent entry with this name and update it
@@ -679,19 +1799,192 @@ func (s *State) UpdateTaskHistorySummary(repoName, taskName, summary, failureRea
 				// Also update status to failed if a failure reason is provided
 				repo.TaskHistory[i].Status = TaskStatusFailed
 			}
-			return s.saveUnlocked()
+			return s.saveAndPublishUnlocked(events.Updated, repoName, "")
 		}
 	}
 
 	return fmt.Errorf("task %q not found in history", taskName)
 }
 
-// saveUnlocked saves state without acquiring lock (caller must hold lock)
+// EnqueueMerge appends a PR to the end of the repository's merge queue.
+// Returns an error if the PR is already queued.
+func (s *State) EnqueueMerge(repoName string, entry MergeQueueEntry) error {
+	release, err := s.beginUpdate()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	repo, exists := s.Repos[repoName]
+	if !exists {
+		return fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
+	}
+
+	for _, queued := range repo.MergeQueue {
+		if queued.PRNumber == entry.PRNumber {
+			return fmt.Errorf("%w: PR #%d is already queued", ErrMergeQueueConflict, entry.PRNumber)
+		}
+	}
+
+	entry.Status = MergeQueueStatusPending
+	entry.ClaimedBy = ""
+	entry.ClaimedAt = time.Time{}
+	if entry.EnqueuedAt.IsZero() {
+		entry.EnqueuedAt = time.Now()
+	}
+
+	repo.MergeQueue = append(repo.MergeQueue, entry)
+	return s.saveAndPublishUnlocked(events.Updated, repoName, "")
+}
+
+// ClaimMergeHead atomically claims the PR at the head of the merge queue for
+// claimant. PRs merge one at a time in FIFO order, so nothing can be claimed
+// while the head is already claimed. Returns false if the queue is empty or busy.
+func (s *State) ClaimMergeHead(repoName, claimant string) (MergeQueueEntry, bool, error) {
+	release, err := s.beginUpdate()
+	if err != nil {
+		return MergeQueueEntry{}, false, err
+	}
+	defer release()
+
+	repo, exists := s.Repos[repoName]
+	if !exists {
+		return MergeQueueEntry{}, false, fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
+	}
+
+	if len(repo.MergeQueue) == 0 || repo.MergeQueue[0].Status == MergeQueueStatusClaimed {
+		return MergeQueueEntry{}, false, nil
+	}
+
+	head := &repo.MergeQueue[0]
+	head.Status = MergeQueueStatusClaimed
+	head.ClaimedBy = claimant
+	head.ClaimedAt = time.Now()
+	head.Attempts++
+
+	if err := s.saveAndPublishUnlocked(events.Updated, repoName, ""); err != nil {
+		return MergeQueueEntry{}, false, err
+	}
+	return *head, true, nil
+}
+
+// CompleteMerge finishes processing the claimed head of the merge queue.
+// An empty failureReason means the merge succeeded and the entry is removed.
+// On failure the entry moves to the back of the queue while it has retries
+// left (per MergeQueueConfig.MaxRetries) and is dropped otherwise.
+// Returns true if the entry was requeued.
+func (s *State) CompleteMerge(repoName string, prNumber int, failureReason string) (bool, error) {
+	release, err := s.beginUpdate()
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	repo, exists := s.Repos[repoName]
+	if !exists {
+		return false, fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
+	}
+
+	if len(repo.MergeQueue) == 0 || repo.MergeQueue[0].PRNumber != prNumber || repo.MergeQueue[0].Status != MergeQueueStatusClaimed {
+		return false, fmt.Errorf("%w: PR #%d is not the claimed head", ErrMergeQueueConflict, prNumber)
+	}
+
+	maxRetries := repo.MergeQueueConfig.Retries()
+
+	head := repo.MergeQueue[0]
+	repo.MergeQueue = repo.MergeQueue[1:]
+
+	requeued := false
+	if failureReason != "" && head.Attempts <= maxRetries {
+		head.Status = MergeQueueStatusPending
+		head.ClaimedBy = ""
+		head.ClaimedAt = time.Time{}
+		head.LastError = failureReason
+		repo.MergeQueue = append(repo.MergeQueue, head)
+		requeued = true
+	}
+
+	if len(repo.MergeQueue) == 0 {
+		repo.MergeQueue = nil
+	}
+
+	return requeued, s.saveAndPublishUnlocked(events.Updated, repoName, "")
+}
+
+// PeekQueue returns a snapshot of the repository's merge queue, head first
+func (s *State) PeekQueue(repoName string) ([]MergeQueueEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	repo, exists := s.Repos[repoName]
+	if !exists {
+		return nil, fmt.Errorf("%w: %q", ErrRepoNotFound, repoName)
+	}
+
+	queue := make([]MergeQueueEntry, len(repo.MergeQueue))
+	copy(queue, repo.MergeQueue)
+	return queue, nil
+}
+
+// Export returns the whole state as JSON for debugging. Secrets are
+// redacted: sensitive agent environment values and credentials embedded in
+// repository URLs.
+func (s *State) Export() ([]byte, error) {
+	s.mu.RLock()
+	data, err := json.Marshal(s)
+	s.mu.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	// Redact a copy so the live state keeps its values
+	var exported State
+	if err := json.Unmarshal(data, &exported); err != nil {
+		return nil, fmt.Errorf("failed to copy state: %w", err)
+	}
+	for _, repo := range exported.Repos {
+		repo.GithubURL = redact.URLCredentials(repo.GithubURL)
+		repo.ForkConfig.UpstreamURL = redact.URLCredentials(repo.ForkConfig.UpstreamURL)
+		for name, agent := range repo.Agents {
+			agent.Env = redact.Env(agent.Env)
+			repo.Agents[name] = agent
+		}
+	}
+
+	return json.Marshal(&exported)
+}
+
+// saveUnlocked saves state under the file lock without acquiring s.mu
+// (caller must hold it). Inside LockedUpdate the save is left to the end.
 func (s *State) saveUnlocked() error {
+	if s.inUpdate {
+		return nil
+	}
+	if s.fileLocked {
+		return s.writeUnlocked()
+	}
+
+	unlock, err := acquireLock(s.path, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return s.writeUnlocked()
+}
+
+// writeUnlocked marshals and atomically writes the state. Caller must hold
+// s.mu and the file lock.
+func (s *State) writeUnlocked() error {
 	data, err := json.MarshalIndent(s, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal state: %w", err)
 	}
 
-	return atomicWrite(s.path, data)
+	if err := fsutil.WriteFileAtomic(s.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		s.disk = info
+	}
+	return nil
 }
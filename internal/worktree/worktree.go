@@ -1,6 +1,7 @@
 package worktree
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -8,6 +9,35 @@ import (
 	"strings"
 )
 
+// ErrWorktreeExists is returned when a worktree cannot be created because its
+// path or branch is already used by another worktree. Use errors.Is to detect it.
+var ErrWorktreeExists = errors.New("worktree already exists")
+
+// ErrPathExists is returned when a worktree cannot be created because its
+// path is already taken by a file or a non-empty directory that is not a
+// worktree, such as one left behind by a crashed worker
+var ErrPathExists = errors.New("worktree path already exists")
+
+// WorktreeExistsError describes the worktree that conflicts with a new one
+type WorktreeExistsError struct {
+	Path           string // Path the new worktree was requested at
+	Branch         string // Branch the new worktree was requested for
+	ExistingPath   string // Path of the conflicting worktree
+	ExistingBranch string // Branch checked out in the conflicting worktree
+}
+
+func (e *WorktreeExistsError) Error() string {
+	if e.ExistingBranch == e.Branch {
+		return fmt.Sprintf("branch %q is already checked out in worktree %s", e.Branch, e.ExistingPath)
+	}
+	return fmt.Sprintf("%s is already a worktree for branch %q", e.ExistingPath, e.ExistingBranch)
+}
+
+// Is makes errors.Is(err, ErrWorktreeExists) match any WorktreeExistsError
+func (e *WorktreeExistsError) Is(target error) bool {
+	return target == ErrWorktreeExists
+}
+
 // Manager handles git worktree operations
 type Manager struct {
 	repoPath string
@@ -48,18 +78,109 @@ func resolvePathWithSymlinks(path string) (string, error) {
 	return evalPath, nil
 }
 
-// Create creates a new git worktree
+// Create creates a new git worktree. If a worktree for branch already exists
+// at path it is reused; if the path or branch is used by a different
+// worktree, a *WorktreeExistsError is returned, and if path is taken by
+// something else, ErrPathExists.
 func (m *Manager) Create(path, branch string) error {
-	_, err := m.runGit("worktree", "add", path, branch)
+	reuse, err := m.checkConflict(path, branch)
+	if err != nil || reuse {
+		return err
+	}
+	_, err = m.runGit("worktree", "add", path, branch)
 	return err
 }
 
-// CreateNewBranch creates a new worktree with a new branch
+// CreateNewBranch creates a new worktree with a new branch. Conflicts with
+// existing worktrees are handled as in Create.
 func (m *Manager) CreateNewBranch(path, newBranch, startPoint string) error {
-	_, err := m.runGit("worktree", "add", "-b", newBranch, path, startPoint)
+	reuse, err := m.checkConflict(path, newBranch)
+	if err != nil || reuse {
+		return err
+	}
+	_, err = m.runGit("worktree", "add", "-b", newBranch, path, startPoint)
 	return err
 }
 
+// checkConflict looks for an existing worktree using path or branch before
+// one is created. It returns true if a worktree for branch already exists at
+// path and can be reused. Stale entries whose directories were deleted are
+// pruned instead of being reported as conflicts. A path occupied by anything
+// other than a worktree is reported as ErrPathExists.
+func (m *Manager) checkConflict(path, branch string) (bool, error) {
+	worktrees, err := m.List()
+	if err != nil {
+		return false, err
+	}
+
+	evalPath, err := resolvePathWithSymlinks(path)
+	if err != nil {
+		return false, err
+	}
+
+	pruned := false
+	for _, wt := range worktrees {
+		wtEval, err := resolvePathWithSymlinks(wt.Path)
+		if err != nil {
+			continue
+		}
+		samePath := wtEval == evalPath
+		sameBranch := branch != "" && wt.Branch == branch
+		if !samePath && !sameBranch {
+			continue
+		}
+
+		if _, err := os.Stat(wt.Path); os.IsNotExist(err) {
+			// The directory is gone; git still tracks it until pruned
+			if !pruned {
+				if err := m.Prune(); err != nil {
+					return false, err
+				}
+				pruned = true
+			}
+			continue
+		}
+
+		if samePath && sameBranch {
+			return true, nil
+		}
+		return false, &WorktreeExistsError{
+			Path:           path,
+			Branch:         branch,
+			ExistingPath:   wt.Path,
+			ExistingBranch: wt.Branch,
+		}
+	}
+
+	if occupied, err := pathOccupied(path); err != nil || occupied {
+		if occupied {
+			err = fmt.Errorf("%w: %s", ErrPathExists, path)
+		}
+		return false, err
+	}
+	return false, nil
+}
+
+// pathOccupied reports whether path is a file or a non-empty directory. git
+// only adds a worktree at a path that is missing or an empty directory.
+func pathOccupied(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !info.IsDir() {
+		return true, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return false, err
+	}
+	return len(entries) > 0, nil
+}
+
 // Remove removes a git worktree
 func (m *Manager) Remove(path string, force bool) error {
 	args := []string{"worktree", "remove", path}
@@ -113,6 +234,42 @@ func (m *Manager) Prune() error {
 	return err
 }
 
+// FindStaleWorktrees returns git worktree entries in repoDir whose
+// directories no longer exist
+func FindStaleWorktrees(repoDir string) ([]string, error) {
+	worktrees, err := NewManager(repoDir).List()
+	if err != nil {
+		return nil, err
+	}
+
+	var stale []string
+	for _, wt := range worktrees {
+		if _, err := os.Stat(wt.Path); os.IsNotExist(err) {
+			stale = append(stale, wt.Path)
+		}
+	}
+	return stale, nil
+}
+
+// RepairWorktrees prunes git worktree entries in repoDir whose directories
+// no longer exist and returns their paths. With dryRun it returns the paths
+// that would be pruned without pruning them.
+func RepairWorktrees(repoDir string, dryRun bool) ([]string, error) {
+	stale, err := FindStaleWorktrees(repoDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(stale) == 0 || dryRun {
+		return stale, nil
+	}
+
+	if err := NewManager(repoDir).Prune(); err != nil {
+		return nil, fmt.Errorf("failed to prune worktrees: %w", err)
+	}
+	return stale, nil
+}
+
 // HasUncommittedChanges checks if a worktree has uncommitted changes
 func HasUncommittedChanges(path string) (bool, error) {
 	cmd := exec.Command("git", "status", "--porcelain")
@@ -240,6 +397,12 @@ func (m *Manager) DeleteBranch(branchName string) error {
 	return err
 }
 
+// DeleteMergedBranch deletes a branch only if it is fully merged (git branch -d)
+func (m *Manager) DeleteMergedBranch(branchName string) error {
+	_, err := m.runGit("branch", "-d", branchName)
+	return err
+}
+
 // ListBranchesWithPrefix lists all branches that start with the given prefix
 func (m *Manager) ListBranchesWithPrefix(prefix string) ([]string, error) {
 	cmd := exec.Command("git", "for-each-ref", "--format=%(refname:short)", "refs/heads/"+prefix)
@@ -555,6 +718,84 @@ func CleanupOrphanedWithDetails(wtRootDir string, manager *Manager) (*CleanupOrp
 		Errors: make(map[string]string),
 	}
 
+	orphaned, err := FindOrphaned(wtRootDir, manager)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range orphaned {
+		if err := os.RemoveAll(path); err != nil {
+			result.Errors[path] = err.Error()
+		} else {
+			result.Removed = append(result.Removed, path)
+		}
+	}
+
+	return result, nil
+}
+
+// BrokenSymlink is a worktree path that is a symlink to a missing target
+type BrokenSymlink struct {
+	Path   string `json:"path"`
+	Target string `json:"target"`
+}
+
+// CheckSymlink reports whether path is a symlink whose target does not
+// exist, returning the link's target if so
+func CheckSymlink(path string) (target string, broken bool) {
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return "", false
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		return "", false
+	}
+	target, _ = os.Readlink(path)
+	return target, true
+}
+
+// FindBrokenSymlinks returns entries of wtRootDir, plus any additional
+// worktree paths (such as those recorded in state), that are dangling
+// symlinks. FindOrphaned skips these because they are not directories.
+func FindBrokenSymlinks(wtRootDir string, paths ...string) ([]BrokenSymlink, error) {
+	candidates := []string{}
+	entries, err := os.ReadDir(wtRootDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.Type()&os.ModeSymlink != 0 {
+			candidates = append(candidates, filepath.Join(wtRootDir, entry.Name()))
+		}
+	}
+	candidates = append(candidates, paths...)
+
+	seen := make(map[string]bool)
+	var broken []BrokenSymlink
+	for _, path := range candidates {
+		if path == "" || seen[path] {
+			continue
+		}
+		seen[path] = true
+		if target, ok := CheckSymlink(path); ok {
+			broken = append(broken, BrokenSymlink{Path: path, Target: target})
+		}
+	}
+	return broken, nil
+}
+
+// RemoveBrokenSymlink removes the symlink at path if its target is still
+// missing. It never follows the link, so the target is left untouched.
+func RemoveBrokenSymlink(path string) error {
+	if _, broken := CheckSymlink(path); !broken {
+		return fmt.Errorf("%s is not a broken symlink", path)
+	}
+	return os.Remove(path)
+}
+
+// FindOrphaned returns directories in wtRootDir that are not registered git
+// worktrees, without removing them
+func FindOrphaned(wtRootDir string, manager *Manager) ([]string, error) {
 	// Get all worktrees from git
 	gitWorktrees, err := manager.List()
 	if err != nil {
@@ -574,11 +815,12 @@ func CleanupOrphanedWithDetails(wtRootDir string, manager *Manager) (*CleanupOrp
 	entries, err := os.ReadDir(wtRootDir)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return result, nil
+			return nil, nil
 		}
 		return nil, err
 	}
 
+	var orphaned []string
 	for _, entry := range entries {
 		if !entry.IsDir() {
 			continue
@@ -591,16 +833,11 @@ func CleanupOrphanedWithDetails(wtRootDir string, manager *Manager) (*CleanupOrp
 		}
 
 		if !gitPaths[evalPath] {
-			// This is an orphaned directory
-			if err := os.RemoveAll(path); err != nil {
-				result.Errors[path] = err.Error()
-			} else {
-				result.Removed = append(result.Removed, path)
-			}
+			orphaned = append(orphaned, path)
 		}
 	}
 
-	return result, nil
+	return orphaned, nil
 }
 
 // WorktreeState represents the current state of a worktree
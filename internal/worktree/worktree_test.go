@@ -1,6 +1,7 @@
 package worktree
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -2989,3 +2990,229 @@ func TestCleanupMergedBranchesWithRemoteDeletion(t *testing.T) {
 		}
 	})
 }
+
+func TestCreateDetectsBranchConflict(t *testing.T) {
+	repoPath, cleanup := createTestRepo(t)
+	defer cleanup()
+
+	manager := NewManager(repoPath)
+	wt1Path := filepath.Join(repoPath, "wt1")
+	if err := manager.CreateNewBranch(wt1Path, "work/agent", "main"); err != nil {
+		t.Fatalf("Failed to create wt1: %v", err)
+	}
+
+	// The same branch at another path is a conflict
+	wt2Path := filepath.Join(repoPath, "wt2")
+	err := manager.Create(wt2Path, "work/agent")
+	if !errors.Is(err, ErrWorktreeExists) {
+		t.Fatalf("Create() error = %v, want ErrWorktreeExists", err)
+	}
+	var existsErr *WorktreeExistsError
+	if !errors.As(err, &existsErr) || existsErr.ExistingBranch != "work/agent" {
+		t.Errorf("error = %#v, want details of the conflicting worktree", err)
+	}
+	if _, statErr := os.Stat(wt2Path); !os.IsNotExist(statErr) {
+		t.Error("conflicting worktree should not be created")
+	}
+
+	// The same path with a different branch is a conflict
+	if err := manager.CreateNewBranch(wt1Path, "work/other", "main"); !errors.Is(err, ErrWorktreeExists) {
+		t.Errorf("CreateNewBranch() on used path error = %v, want ErrWorktreeExists", err)
+	}
+
+	// The same path and branch reuses the existing worktree
+	if err := manager.CreateNewBranch(wt1Path, "work/agent", "main"); err != nil {
+		t.Errorf("CreateNewBranch() for existing worktree = %v, want reuse", err)
+	}
+}
+
+func TestCreatePrunesStaleConflict(t *testing.T) {
+	repoPath, cleanup := createTestRepo(t)
+	defer cleanup()
+
+	manager := NewManager(repoPath)
+	wt1Path := filepath.Join(repoPath, "wt1")
+	if err := manager.CreateNewBranch(wt1Path, "work/agent", "main"); err != nil {
+		t.Fatalf("Failed to create wt1: %v", err)
+	}
+
+	// Deleting the directory leaves a stale entry that still holds the branch
+	if err := os.RemoveAll(wt1Path); err != nil {
+		t.Fatal(err)
+	}
+
+	wt2Path := filepath.Join(repoPath, "wt2")
+	if err := manager.Create(wt2Path, "work/agent"); err != nil {
+		t.Fatalf("Create() with stale conflict = %v, want success", err)
+	}
+	if branch, _ := GetCurrentBranch(wt2Path); branch != "work/agent" {
+		t.Errorf("wt2 branch = %q, want work/agent", branch)
+	}
+}
+
+func TestCreateRejectsOccupiedPath(t *testing.T) {
+	repoPath, cleanup := createTestRepo(t)
+	defer cleanup()
+
+	manager := NewManager(repoPath)
+
+	// A leftover directory with files in it is not overwritten
+	leftover := filepath.Join(repoPath, "leftover")
+	if err := os.MkdirAll(leftover, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(leftover, "notes.txt"), []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.CreateNewBranch(leftover, "work/leftover", "main"); !errors.Is(err, ErrPathExists) {
+		t.Errorf("CreateNewBranch() in a non-empty directory error = %v, want ErrPathExists", err)
+	}
+	if errors.Is(ErrPathExists, ErrWorktreeExists) {
+		t.Error("ErrPathExists should be distinct from ErrWorktreeExists")
+	}
+
+	// Neither is a file
+	file := filepath.Join(repoPath, "file")
+	if err := os.WriteFile(file, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.CreateNewBranch(file, "work/file", "main"); !errors.Is(err, ErrPathExists) {
+		t.Errorf("CreateNewBranch() on a file error = %v, want ErrPathExists", err)
+	}
+	if exists, _ := manager.BranchExists("work/file"); exists {
+		t.Error("branch should not be created when the path is occupied")
+	}
+
+	// An empty directory is fine, as it is for git
+	empty := filepath.Join(repoPath, "empty")
+	if err := os.MkdirAll(empty, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.CreateNewBranch(empty, "work/empty", "main"); err != nil {
+		t.Fatalf("CreateNewBranch() in an empty directory = %v, want success", err)
+	}
+	if err := manager.Remove(empty, false); err != nil {
+		t.Fatalf("Remove() failed: %v", err)
+	}
+	if exists, _ := manager.Exists(empty); exists {
+		t.Error("worktree should be gone after Remove()")
+	}
+}
+
+func TestRepairWorktrees(t *testing.T) {
+	repoPath, cleanup := createTestRepo(t)
+	defer cleanup()
+
+	manager := NewManager(repoPath)
+	livePath := filepath.Join(repoPath, "live")
+	stalePath := filepath.Join(repoPath, "stale")
+	if err := manager.CreateNewBranch(livePath, "work/live", "main"); err != nil {
+		t.Fatalf("Failed to create live worktree: %v", err)
+	}
+	if err := manager.CreateNewBranch(stalePath, "work/stale", "main"); err != nil {
+		t.Fatalf("Failed to create stale worktree: %v", err)
+	}
+	if err := os.RemoveAll(stalePath); err != nil {
+		t.Fatal(err)
+	}
+
+	// A dry run reports the stale entry without pruning it
+	planned, err := RepairWorktrees(repoPath, true)
+	if err != nil {
+		t.Fatalf("RepairWorktrees(dry run) failed: %v", err)
+	}
+	if len(planned) != 1 || filepath.Base(planned[0]) != "stale" {
+		t.Errorf("planned = %v, want only the stale worktree", planned)
+	}
+	if stale, _ := FindStaleWorktrees(repoPath); len(stale) != 1 {
+		t.Errorf("dry run pruned worktrees, stale = %v", stale)
+	}
+
+	pruned, err := RepairWorktrees(repoPath, false)
+	if err != nil {
+		t.Fatalf("RepairWorktrees() failed: %v", err)
+	}
+	if len(pruned) != 1 || filepath.Base(pruned[0]) != "stale" {
+		t.Errorf("pruned = %v, want only the stale worktree", pruned)
+	}
+
+	worktrees, err := manager.List()
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	for _, wt := range worktrees {
+		if filepath.Base(wt.Path) == "stale" {
+			t.Error("stale worktree is still registered")
+		}
+	}
+	if exists, _ := manager.Exists(livePath); !exists {
+		t.Error("live worktree should not be pruned")
+	}
+
+	// Nothing left to repair
+	if pruned, err := RepairWorktrees(repoPath, false); err != nil || len(pruned) != 0 {
+		t.Errorf("second RepairWorktrees() = %v, %v, want nothing pruned", pruned, err)
+	}
+}
+
+func TestFindBrokenSymlinks(t *testing.T) {
+	wtRoot := t.TempDir()
+	targets := t.TempDir()
+
+	// A symlinked worktree whose target still exists
+	liveTarget := filepath.Join(targets, "live")
+	if err := os.Mkdir(liveTarget, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(liveTarget, filepath.Join(wtRoot, "live")); err != nil {
+		t.Fatal(err)
+	}
+
+	// A symlinked worktree whose target was deleted
+	dangling := filepath.Join(wtRoot, "dangling")
+	if err := os.Symlink(filepath.Join(targets, "gone"), dangling); err != nil {
+		t.Fatal(err)
+	}
+
+	// A plain directory and a dangling link outside wtRoot passed explicitly
+	if err := os.Mkdir(filepath.Join(wtRoot, "plain"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	elsewhere := filepath.Join(targets, "elsewhere")
+	if err := os.Symlink(filepath.Join(targets, "missing"), elsewhere); err != nil {
+		t.Fatal(err)
+	}
+
+	broken, err := FindBrokenSymlinks(wtRoot, elsewhere, dangling, "")
+	if err != nil {
+		t.Fatalf("FindBrokenSymlinks() failed: %v", err)
+	}
+	want := []BrokenSymlink{
+		{Path: dangling, Target: filepath.Join(targets, "gone")},
+		{Path: elsewhere, Target: filepath.Join(targets, "missing")},
+	}
+	if len(broken) != len(want) {
+		t.Fatalf("FindBrokenSymlinks() = %v, want %v", broken, want)
+	}
+	for i := range want {
+		if broken[i] != want[i] {
+			t.Errorf("broken[%d] = %v, want %v", i, broken[i], want[i])
+		}
+	}
+
+	// Removal only applies to broken links
+	if err := RemoveBrokenSymlink(filepath.Join(wtRoot, "live")); err == nil {
+		t.Error("RemoveBrokenSymlink() should refuse a live symlink")
+	}
+	if err := RemoveBrokenSymlink(dangling); err != nil {
+		t.Fatalf("RemoveBrokenSymlink() failed: %v", err)
+	}
+	if _, err := os.Lstat(dangling); !os.IsNotExist(err) {
+		t.Error("dangling symlink should be removed")
+	}
+
+	// A missing root is not an error
+	if _, err := FindBrokenSymlinks(filepath.Join(wtRoot, "nope")); err != nil {
+		t.Errorf("FindBrokenSymlinks() on missing dir = %v", err)
+	}
+}
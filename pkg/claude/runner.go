@@ -39,7 +39,11 @@ import (
 	"context"
 	"crypto/rand"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -203,6 +207,29 @@ type Config struct {
 	// This is useful for showing restart instructions or other information.
 	// If empty, no MOTD is displayed.
 	MOTD string
+
+	// Env holds extra environment variables for the Claude process.
+	// They are set on the command line with env(1), so they apply to Claude
+	// and its children but not to the shell in the window. Set EnvFile to
+	// keep the values off the command line.
+	Env map[string]string
+
+	// EnvFile, if set, is where Env is written before Claude starts. The
+	// command then reads the variables from the file instead of carrying
+	// their values, so secrets never appear in the terminal or its output
+	// capture. With an empty Env an existing file is still used, which lets
+	// a restart reuse the environment of the first start.
+	EnvFile string
+
+	// BinaryPath overrides Runner.BinaryPath for this start, so agents
+	// can run different claude builds.
+	BinaryPath string
+
+	// Model selects the model with --model. If empty, Claude's default is used.
+	Model string
+
+	// ExtraArgs are appended to the command line.
+	ExtraArgs []string
 }
 
 // StartResult contains information about a started Claude instance.
@@ -233,6 +260,12 @@ func (r *Runner) Start(ctx context.Context, session, window string, cfg Config)
 		}
 	}
 
+	if cfg.EnvFile != "" && len(cfg.Env) > 0 {
+		if err := WriteEnvFile(cfg.EnvFile, cfg.Env); err != nil {
+			return nil, err
+		}
+	}
+
 	// Build the command
 	cmd := r.buildCommand(sessionID, cfg)
 
@@ -301,7 +334,18 @@ func (r *Runner) buildCommand(sessionID string, cfg Config) string {
 	// Claude Code only reads credentials from ~/.claude/.credentials.json
 	// regardless of CLAUDE_CONFIG_DIR setting. Slash commands go in ~/.claude/commands/.
 
-	cmd += r.BinaryPath
+	if cfg.EnvFile != "" {
+		if _, err := os.Stat(cfg.EnvFile); err == nil {
+			cmd += EnvFilePrefix(cfg.EnvFile)
+		}
+	} else {
+		cmd += EnvPrefix(cfg.Env)
+	}
+	if cfg.BinaryPath != "" {
+		cmd += cfg.BinaryPath
+	} else {
+		cmd += r.BinaryPath
+	}
 
 	// Add session ID or resume
 	if cfg.Resume {
@@ -320,9 +364,137 @@ func (r *Runner) buildCommand(sessionID string, cfg Config) string {
 		cmd += fmt.Sprintf(" --append-system-prompt-file %s", cfg.SystemPromptFile)
 	}
 
+	cmd += ExtraArgs(cfg.Model, cfg.ExtraArgs)
+
 	return cmd
 }
 
+// ExtraArgs returns command-line arguments selecting model and appending
+// extra, each quoted for the shell and preceded by a space, such as
+// " --model 'opus' '--verbose'". It returns "" when there are none.
+func ExtraArgs(model string, extra []string) string {
+	var b strings.Builder
+	if model != "" {
+		b.WriteString(" --model ")
+		b.WriteString(shellQuote(model))
+	}
+	for _, arg := range extra {
+		b.WriteString(" ")
+		b.WriteString(shellQuote(arg))
+	}
+	return b.String()
+}
+
+// EnvPrefix returns a shell command prefix that runs the command after it
+// with env added to its environment, such as "env 'A=1' 'B=2' ". Variables
+// are sorted so the command is stable. It returns "" for an empty env.
+func EnvPrefix(env map[string]string) string {
+	if len(env) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("env ")
+	for _, name := range names {
+		b.WriteString(shellQuote(name + "=" + env[name]))
+		b.WriteString(" ")
+	}
+	return b.String()
+}
+
+// WriteEnvFile writes env to path as shell export statements, readable only
+// by the owner, for EnvFilePrefix to load
+func WriteEnvFile(path string, env map[string]string) error {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		if !ValidEnvName(name) {
+			return fmt.Errorf("invalid environment variable name %q", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "export %s=%s\n", name, shellQuote(env[name]))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create environment directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0600); err != nil {
+		return fmt.Errorf("failed to write environment file: %w", err)
+	}
+	// WriteFile keeps the mode of a file that already exists
+	return os.Chmod(path, 0600)
+}
+
+// CleanupEnvFiles removes the "<agent>.env" files in dir whose agent is not
+// in agents and returns their paths
+func CleanupEnvFiles(dir string, agents []string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keep := make(map[string]bool, len(agents))
+	for _, agent := range agents {
+		keep[agent+".env"] = true
+	}
+
+	var removed []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".env" || keep[entry.Name()] {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+// EnvFilePrefix returns a shell command prefix that runs the command after it
+// with the variables in the file at path, written by WriteEnvFile, added to
+// its environment. It runs under sh whatever the interactive shell is, and
+// only the path appears on the command line.
+func EnvFilePrefix(path string) string {
+	return `sh -c '. "$0" && exec "$@"' ` + shellQuote(path) + " "
+}
+
+// ValidEnvName reports whether name can be used as an environment variable
+// name: letters, digits, and underscores, not starting with a digit
+func ValidEnvName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// shellQuote quotes s for POSIX shells
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
 // SendMessage sends a message to a running Claude instance.
 // This properly handles multiline messages using paste-buffer and sends
 // text + Enter atomically to prevent race conditions.
@@ -3,6 +3,9 @@ package claude
 import (
 	"context"
 	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -503,6 +506,31 @@ func TestBuildCommand(t *testing.T) {
 				"CLAUDE_CONFIG_DIR",
 			},
 		},
+		{
+			name: "with model and extra args",
+			config: Config{
+				SessionID: "test-session",
+				Model:     "opus",
+				ExtraArgs: []string{"--verbose", "it's"},
+			},
+			contains: []string{
+				"/path/to/claude --session-id test-session",
+				" --model 'opus' '--verbose' 'it'\\''s'",
+			},
+		},
+		{
+			name: "with binary override",
+			config: Config{
+				SessionID:  "test-session",
+				BinaryPath: "/opt/claude-beta",
+			},
+			contains: []string{
+				"/opt/claude-beta --session-id test-session",
+			},
+			excludes: []string{
+				"/path/to/claude",
+			},
+		},
 		{
 			name: "with workdir",
 			config: Config{
@@ -611,3 +639,126 @@ func TestIsBinaryAvailable(t *testing.T) {
 // were removed because CLAUDE_CONFIG_DIR is no longer used. Claude Code only reads
 // credentials from ~/.claude/.credentials.json regardless of CLAUDE_CONFIG_DIR,
 // and slash commands are now embedded directly in agent prompts.
+
+func TestEnvPrefixReachesChild(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	env := map[string]string{
+		"MC_TEST_PLAIN":  "value",
+		"MC_TEST_QUOTED": `it's "quoted" $HOME`,
+	}
+	cmd := EnvPrefix(env) + `sh -c 'printf "%s|%s" "$MC_TEST_PLAIN" "$MC_TEST_QUOTED"'`
+
+	out, err := exec.Command("sh", "-c", cmd).Output()
+	if err != nil {
+		t.Fatalf("running %q failed: %v", cmd, err)
+	}
+	if want := `value|it's "quoted" $HOME`; string(out) != want {
+		t.Errorf("child saw %q, want %q", out, want)
+	}
+}
+
+func TestEnvPrefix(t *testing.T) {
+	if got := EnvPrefix(nil); got != "" {
+		t.Errorf("EnvPrefix(nil) = %q, want empty", got)
+	}
+	got := EnvPrefix(map[string]string{"B": "2", "A": "1"})
+	if want := "env 'A=1' 'B=2' "; got != want {
+		t.Errorf("EnvPrefix() = %q, want %q", got, want)
+	}
+
+	runner := NewRunner(WithBinaryPath("/path/to/claude"))
+	cmd := runner.buildCommand("s", Config{Env: map[string]string{"MODEL": "fast"}})
+	if !strings.HasPrefix(cmd, "env 'MODEL=fast' /path/to/claude") {
+		t.Errorf("buildCommand() = %q, want env prefix before the binary", cmd)
+	}
+}
+
+func TestEnvFileKeepsSecretsOffCommandLine(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	envFile := filepath.Join(t.TempDir(), "env", "agent.env")
+	secret := `s3cr3t's "value" $HOME`
+	runner := NewRunner(WithBinaryPath("/path/to/claude"))
+	cfg := Config{Env: map[string]string{"API_TOKEN": secret, "MODE": "fast"}, EnvFile: envFile}
+	if err := WriteEnvFile(cfg.EnvFile, cfg.Env); err != nil {
+		t.Fatalf("WriteEnvFile() failed: %v", err)
+	}
+
+	cmd := runner.buildCommand("s", cfg)
+	if strings.Contains(cmd, "s3cr3t") || strings.Contains(cmd, "fast") {
+		t.Errorf("buildCommand() = %q, must not contain environment values", cmd)
+	}
+	if !strings.HasPrefix(cmd, EnvFilePrefix(envFile)+"/path/to/claude") {
+		t.Errorf("buildCommand() = %q, want the env file prefix before the binary", cmd)
+	}
+
+	info, err := os.Stat(envFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("env file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	// The variables reach the command, and only the command
+	check := EnvFilePrefix(envFile) + `sh -c 'printf "%s|%s" "$API_TOKEN" "$MODE"'; printf "|%s" "$MODE"`
+	out, err := exec.Command("sh", "-c", check).Output()
+	if err != nil {
+		t.Fatalf("running %q failed: %v", check, err)
+	}
+	if want := secret + "|fast|"; string(out) != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+
+	// Without the file, nothing is sourced
+	cmd = runner.buildCommand("s", Config{EnvFile: filepath.Join(t.TempDir(), "missing.env")})
+	if !strings.HasPrefix(cmd, "/path/to/claude") {
+		t.Errorf("buildCommand() with a missing env file = %q", cmd)
+	}
+
+	if err := WriteEnvFile(envFile, map[string]string{"BAD-NAME": "x"}); err == nil {
+		t.Error("WriteEnvFile() should reject invalid names")
+	}
+}
+
+func TestCleanupEnvFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, agent := range []string{"live", "gone"} {
+		if err := WriteEnvFile(filepath.Join(dir, agent+".env"), map[string]string{"A": "1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := CleanupEnvFiles(dir, []string{"live"})
+	if err != nil {
+		t.Fatalf("CleanupEnvFiles() failed: %v", err)
+	}
+	if len(removed) != 1 || filepath.Base(removed[0]) != "gone.env" {
+		t.Errorf("removed = %v, want only gone.env", removed)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "live.env")); err != nil {
+		t.Error("env file of a live agent should be kept")
+	}
+
+	if removed, err := CleanupEnvFiles(filepath.Join(dir, "missing"), nil); err != nil || len(removed) != 0 {
+		t.Errorf("CleanupEnvFiles() on a missing dir = %v, %v", removed, err)
+	}
+}
+
+func TestValidEnvName(t *testing.T) {
+	for _, name := range []string{"MODEL", "_PRIVATE", "api_key2"} {
+		if !ValidEnvName(name) {
+			t.Errorf("ValidEnvName(%q) = false, want true", name)
+		}
+	}
+	for _, name := range []string{"", "2FAST", "BAD-NAME", "A=B", "HAS SPACE"} {
+		if ValidEnvName(name) {
+			t.Errorf("ValidEnvName(%q) = true, want false", name)
+		}
+	}
+}
@@ -3,6 +3,8 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 )
@@ -13,42 +15,76 @@ type Paths struct {
 	DaemonPID       string // daemon.pid
 	DaemonSock      string // daemon.sock
 	DaemonLog       string // daemon.log
+	DaemonMeta      string // daemon-meta.json
 	StateFile       string // state.json
+	MetricsFile     string // metrics.json
+	HistoryFile     string // commands.jsonl
+	TokensFile      string // tokens.json
 	ReposDir        string // repos/
 	WorktreesDir    string // wts/
 	MessagesDir     string // messages/
 	OutputDir       string // output/
 	ClaudeConfigDir string // claude-config/
 	ArchiveDir      string // archive/ (for paused work)
+	CacheDir        string // regenerable files such as prompts; Root in the legacy layout
 }
 
-// DefaultPaths returns the default paths for multiclaude
+// DefaultPaths returns the default paths for multiclaude, in the layout
+// named by $MULTICLAUDE_LAYOUT (legacy if unset)
 func DefaultPaths() (*Paths, error) {
+	return PathsForLayout(Layout(os.Getenv(LayoutEnv)))
+}
+
+// legacyPaths returns the single-root layout under ~/.multiclaude
+func legacyPaths() (*Paths, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return nil, err
 	}
+	return PathsAt(filepath.Join(home, ".multiclaude")), nil
+}
 
-	root := filepath.Join(home, ".multiclaude")
-
+// PathsAt returns the single-root layout with every path under root
+func PathsAt(root string) *Paths {
 	return &Paths{
 		Root:            root,
 		DaemonPID:       filepath.Join(root, "daemon.pid"),
 		DaemonSock:      filepath.Join(root, "daemon.sock"),
 		DaemonLog:       filepath.Join(root, "daemon.log"),
+		DaemonMeta:      filepath.Join(root, "daemon-meta.json"),
 		StateFile:       filepath.Join(root, "state.json"),
+		MetricsFile:     filepath.Join(root, "metrics.json"),
+		HistoryFile:     filepath.Join(root, "commands.jsonl"),
+		TokensFile:      filepath.Join(root, "tokens.json"),
 		ReposDir:        filepath.Join(root, "repos"),
 		WorktreesDir:    filepath.Join(root, "wts"),
 		MessagesDir:     filepath.Join(root, "messages"),
 		OutputDir:       filepath.Join(root, "output"),
 		ClaudeConfigDir: filepath.Join(root, "claude-config"),
 		ArchiveDir:      filepath.Join(root, "archive"),
-	}, nil
+		CacheDir:        root,
+	}
 }
 
-// EnsureDirectories creates all necessary directories if they don't exist
+// EnsureDirectories creates all necessary directories with mode 0700 if they
+// don't exist. It fails without creating anything if one of them exists but
+// can't be used; see Validate.
 func (p *Paths) EnsureDirectories() error {
-	dirs := []string{
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	for _, dir := range p.dirs() {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+// dirs returns the directories EnsureDirectories manages, in creation order
+func (p *Paths) dirs() []string {
+	var dirs []string
+	for _, dir := range []string{
 		p.Root,
 		p.ReposDir,
 		p.WorktreesDir,
@@ -56,15 +92,58 @@ func (p *Paths) EnsureDirectories() error {
 		p.OutputDir,
 		p.ClaudeConfigDir,
 		p.ArchiveDir,
+		p.CacheDir,
+		filepath.Dir(p.DaemonSock),
+	} {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
 	}
-
-	for _, dir := range dirs {
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return err
+	return dirs
+}
+
+// Validate reports every directory EnsureDirectories manages that exists but
+// is not a directory or is not writable. Directories that don't exist yet are
+// not an error.
+func (p *Paths) Validate() error {
+	var errs []error
+	for _, dir := range p.dirs() {
+		info, err := os.Stat(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", dir, err))
+			continue
+		}
+		if !info.IsDir() {
+			errs = append(errs, fmt.Errorf("%s exists but is not a directory", dir))
+			continue
+		}
+		if err := checkWritable(dir); err != nil {
+			errs = append(errs, fmt.Errorf("%s is not writable: %w", dir, err))
 		}
 	}
+	return errors.Join(errs...)
+}
 
-	return nil
+// checkWritable creates and removes a file in dir. Checking mode bits
+// isn't enough, since ownership, ACLs and read-only mounts also apply.
+func checkWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// PromptsDir returns the directory for generated agent prompt files
+func (p *Paths) PromptsDir() string {
+	if p.CacheDir == "" {
+		return filepath.Join(p.Root, "prompts")
+	}
+	return filepath.Join(p.CacheDir, "prompts")
 }
 
 // RepoDir returns the path for a specific repository
@@ -116,6 +195,24 @@ func (p *Paths) AgentLogFile(repoName, agentName string, isWorker bool) string {
 	return filepath.Join(p.RepoOutputDir(repoName), agentName+".log")
 }
 
+// RepoEnvDir returns the directory holding a repository's agent environment
+// files
+func (p *Paths) RepoEnvDir(repoName string) string {
+	return filepath.Join(p.Root, "env", repoName)
+}
+
+// AgentEnvFile returns the path of the file holding an agent's extra
+// environment, which may include secrets
+func (p *Paths) AgentEnvFile(repoName, agentName string) string {
+	return filepath.Join(p.RepoEnvDir(repoName), agentName+".env")
+}
+
+// AgentTokenFile returns the path of the file holding the socket client
+// token that the daemon hands to the agents it spawns
+func (p *Paths) AgentTokenFile() string {
+	return filepath.Join(p.Root, "agent-token")
+}
+
 // AgentClaudeConfigDir returns the path for a specific agent's Claude config directory
 // This is used to set CLAUDE_CONFIG_DIR for per-agent slash commands
 func (p *Paths) AgentClaudeConfigDir(repoName, agentName string) string {
@@ -130,19 +227,7 @@ func (p *Paths) AgentCommandsDir(repoName, agentName string) string {
 // NewTestPaths creates a Paths instance for testing with all paths under tmpDir.
 // This eliminates duplicate test setup code and ensures consistent path configuration.
 func NewTestPaths(tmpDir string) *Paths {
-	return &Paths{
-		Root:            tmpDir,
-		DaemonPID:       filepath.Join(tmpDir, "daemon.pid"),
-		DaemonSock:      filepath.Join(tmpDir, "daemon.sock"),
-		DaemonLog:       filepath.Join(tmpDir, "daemon.log"),
-		StateFile:       filepath.Join(tmpDir, "state.json"),
-		ReposDir:        filepath.Join(tmpDir, "repos"),
-		WorktreesDir:    filepath.Join(tmpDir, "wts"),
-		MessagesDir:     filepath.Join(tmpDir, "messages"),
-		OutputDir:       filepath.Join(tmpDir, "output"),
-		ClaudeConfigDir: filepath.Join(tmpDir, "claude-config"),
-		ArchiveDir:      filepath.Join(tmpDir, "archive"),
-	}
+	return PathsAt(tmpDir)
 }
 
 // RepoArchiveDir returns the path for a repository's archived work
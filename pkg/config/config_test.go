@@ -81,6 +81,64 @@ func TestEnsureDirectories(t *testing.T) {
 	}
 }
 
+func TestEnsureDirectoriesMode(t *testing.T) {
+	paths := NewTestPaths(filepath.Join(t.TempDir(), "root"))
+
+	if err := paths.EnsureDirectories(); err != nil {
+		t.Fatalf("EnsureDirectories() failed: %v", err)
+	}
+	for _, dir := range []string{paths.Root, paths.ReposDir, paths.WorktreesDir, paths.OutputDir, paths.MessagesDir, paths.ClaudeConfigDir, paths.ArchiveDir} {
+		info, err := os.Stat(dir)
+		if err != nil {
+			t.Errorf("Directory not created: %s", dir)
+			continue
+		}
+		if perm := info.Mode().Perm(); perm != 0700 {
+			t.Errorf("%s mode = %o, want 700", dir, perm)
+		}
+	}
+
+	if err := paths.EnsureDirectories(); err != nil {
+		t.Errorf("EnsureDirectories() second call failed: %v", err)
+	}
+	if err := paths.Validate(); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+}
+
+func TestValidateFileInsteadOfDirectory(t *testing.T) {
+	paths := NewTestPaths(t.TempDir())
+	if err := os.WriteFile(paths.WorktreesDir, []byte("not a dir"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := paths.Validate()
+	if err == nil || !strings.Contains(err.Error(), paths.WorktreesDir) {
+		t.Fatalf("Validate() = %v, want an error naming %s", err, paths.WorktreesDir)
+	}
+	if err := paths.EnsureDirectories(); err == nil {
+		t.Error("EnsureDirectories() should fail when a file is where a directory should be")
+	}
+	if _, err := os.Stat(paths.MessagesDir); !os.IsNotExist(err) {
+		t.Error("EnsureDirectories() should not create anything when validation fails")
+	}
+}
+
+func TestValidateNotWritable(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to any directory")
+	}
+	paths := NewTestPaths(t.TempDir())
+	if err := os.Mkdir(paths.OutputDir, 0500); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(paths.OutputDir, 0700) })
+
+	if err := paths.Validate(); err == nil || !strings.Contains(err.Error(), "not writable") {
+		t.Errorf("Validate() = %v, want a not writable error", err)
+	}
+}
+
 func TestRepoPaths(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -229,6 +287,7 @@ func TestNewTestPaths(t *testing.T) {
 		"DaemonSock":      filepath.Join(tmpDir, "daemon.sock"),
 		"DaemonLog":       filepath.Join(tmpDir, "daemon.log"),
 		"StateFile":       filepath.Join(tmpDir, "state.json"),
+		"MetricsFile":     filepath.Join(tmpDir, "metrics.json"),
 		"ReposDir":        filepath.Join(tmpDir, "repos"),
 		"WorktreesDir":    filepath.Join(tmpDir, "wts"),
 		"MessagesDir":     filepath.Join(tmpDir, "messages"),
@@ -248,6 +307,9 @@ func TestNewTestPaths(t *testing.T) {
 	if paths.StateFile != expectedPaths["StateFile"] {
 		t.Errorf("StateFile = %q, want %q", paths.StateFile, expectedPaths["StateFile"])
 	}
+	if paths.MetricsFile != expectedPaths["MetricsFile"] {
+		t.Errorf("MetricsFile = %q, want %q", paths.MetricsFile, expectedPaths["MetricsFile"])
+	}
 	if paths.ReposDir != expectedPaths["ReposDir"] {
 		t.Errorf("ReposDir = %q, want %q", paths.ReposDir, expectedPaths["ReposDir"])
 	}
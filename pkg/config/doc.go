@@ -30,12 +30,36 @@ func DirectoryDocs() []PathDoc {
 			Type:        "file",
 			Notes:       "Useful for debugging daemon issues. Check this when agents behave unexpectedly.",
 		},
+		{
+			Path:        "daemon-meta.json",
+			Description: "How many times the daemon has started, and when and why it last started",
+			Type:        "file",
+			Notes:       "The reason is fresh, crash-recovery (a PID file was left behind), or stale-takeover (forced start over a live PID). Shown by the status command.",
+		},
 		{
 			Path:        "state.json",
 			Description: "Central state file containing all tracked repositories and agents",
 			Type:        "file",
 			Notes:       "Written atomically via temp file + rename. See StateDoc() for format details.",
 		},
+		{
+			Path:        "metrics.json",
+			Description: "Aggregate agent metrics (spawned, completed, failed, runtimes)",
+			Type:        "file",
+			Notes:       "Written atomically after each agent lifecycle event so totals survive daemon restarts.",
+		},
+		{
+			Path:        "commands.jsonl",
+			Description: "The socket commands the daemon processed, one JSON object per line",
+			Type:        "file",
+			Notes:       "Sensitive arguments are redacted. Once it passes 1 MiB, entries beyond the newest 100 are archived to commands.jsonl.<timestamp>, and the health check archives entries older than 7 days; the compact socket command archives on demand. The newest 10 archives are kept by default. Read recent commands with the history socket command.",
+		},
+		{
+			Path:        "tokens.json",
+			Description: "Optional socket client tokens and the capability each grants",
+			Type:        "file",
+			Notes:       "Each token is read-only or read-write. Requests without a token keep full access; unknown tokens are rejected. Loaded when the daemon starts.",
+		},
 		{
 			Path:        "repos/",
 			Description: "Contains cloned git repositories (bare or working)",
@@ -84,6 +108,12 @@ func DirectoryDocs() []PathDoc {
 			Type:        "directory",
 			Notes:       "Contains msg-<uuid>.json files addressed to this agent.",
 		},
+		{
+			Path:        "env/<repo-name>/<agent-name>.env",
+			Description: "Extra environment for an agent's Claude process",
+			Type:        "file",
+			Notes:       "Mode 0600; may hold secrets. Sourced when the agent starts or restarts so values are never typed into its tmux window.",
+		},
 		{
 			Path:        "prompts/",
 			Description: "Generated prompt files for agents",
@@ -121,6 +151,8 @@ func StateDocs() []StateFieldDoc {
 		{Field: "repos.<name>.agents.<name>.created_at", Type: "time.Time", Description: "When the agent was created"},
 		{Field: "repos.<name>.agents.<name>.last_nudge", Type: "time.Time", Description: "Last time agent was nudged (omitempty)"},
 		{Field: "repos.<name>.agents.<name>.ready_for_cleanup", Type: "bool", Description: "Whether worker is ready to be cleaned up (workers only, omitempty)"},
+		{Field: "repos.<name>.agents.<name>.pr_number", Type: "int", Description: "PR under review (review agents only, omitempty)"},
+		{Field: "repos.<name>.agents.<name>.target_branch", Type: "string", Description: "Branch the reviewed PR merges into (review agents only, omitempty)"},
 	}
 }
 
@@ -140,6 +172,7 @@ func MessageDocs() []MessageFieldDoc {
 		{Field: "timestamp", Type: "time.Time", Description: "When the message was sent"},
 		{Field: "body", Type: "string", Description: "Message content (markdown text)"},
 		{Field: "status", Type: "string", Description: "Message status: pending, delivered, read, or acked"},
+		{Field: "delivered_at", Type: "time.Time", Description: "When the message was last delivered; cleared on requeue (omitempty)"},
 		{Field: "acked_at", Type: "time.Time", Description: "When the message was acknowledged (omitempty)"},
 	}
 }
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// FileEnv is the environment variable naming a config file to load instead
+// of the default one
+const FileEnv = "MULTICLAUDE_CONFIG"
+
+// Environment variables that override config file values
+const (
+	MaxWorkersEnv      = "MULTICLAUDE_MAX_WORKERS"
+	SpawnsPerMinuteEnv = "MULTICLAUDE_SPAWNS_PER_MINUTE"
+	SpawnBurstEnv      = "MULTICLAUDE_SPAWN_BURST"
+	LogLevelEnv        = "MULTICLAUDE_LOG_LEVEL"
+)
+
+// LogLevels are the accepted values of Config.LogLevel
+var LogLevels = []string{"debug", "info", "warn", "error"}
+
+// Config holds user defaults for multiclaude. A zero field means "not set",
+// so the built-in default applies. The daemon reads it at startup and again
+// on reload.
+type Config struct {
+	// MaxWorkers caps concurrently active workers in a repository that has
+	// no spawn limits of its own. -1 means unlimited.
+	MaxWorkers int `json:"max_workers,omitempty"`
+
+	// SpawnsPerMinute is the default sustained worker spawn rate. -1 means
+	// unlimited.
+	SpawnsPerMinute float64 `json:"spawns_per_minute,omitempty"`
+
+	// SpawnBurst is the default number of back-to-back spawns allowed
+	SpawnBurst int `json:"spawn_burst,omitempty"`
+
+	// LogLevel is the minimum level the daemon logs: one of LogLevels
+	LogLevel string `json:"log_level,omitempty"`
+}
+
+// DefaultConfigFile returns the config file Load reads: $MULTICLAUDE_CONFIG
+// if set, otherwise config.json in $XDG_CONFIG_HOME/multiclaude
+// (~/.config/multiclaude).
+func DefaultConfigFile() (string, error) {
+	if path := os.Getenv(FileEnv); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(xdgDir("XDG_CONFIG_HOME", filepath.Join(home, ".config")), "multiclaude", "config.json"), nil
+}
+
+// Load reads the default config file. Values are taken, lowest precedence
+// first, from:
+//  1. the zero Config (built-in defaults)
+//  2. the config file, if it exists
+//  3. MULTICLAUDE_* environment variables
+func Load() (*Config, error) {
+	path, err := DefaultConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	return LoadFile(path)
+}
+
+// LoadFile is like Load but reads the config file at path. A missing file
+// is not an error.
+func LoadFile(path string) (*Config, error) {
+	cfg := &Config{}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	default:
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return cfg, nil
+}
+
+// applyEnv overrides fields with any environment variables that are set
+func (c *Config) applyEnv() error {
+	if v := os.Getenv(LogLevelEnv); v != "" {
+		c.LogLevel = v
+	}
+	for env, field := range map[string]*int{MaxWorkersEnv: &c.MaxWorkers, SpawnBurstEnv: &c.SpawnBurst} {
+		if v := os.Getenv(env); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: must be an integer", env, v)
+			}
+			*field = n
+		}
+	}
+	if v := os.Getenv(SpawnsPerMinuteEnv); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: must be a number", SpawnsPerMinuteEnv, v)
+		}
+		c.SpawnsPerMinute = rate
+	}
+	return nil
+}
+
+// Validate reports every field with a value multiclaude can't use
+func (c *Config) Validate() error {
+	var errs []error
+	if c.MaxWorkers < -1 {
+		errs = append(errs, fmt.Errorf("max_workers must be positive, or -1 for unlimited, got %d", c.MaxWorkers))
+	}
+	if c.SpawnsPerMinute < 0 && c.SpawnsPerMinute != -1 {
+		errs = append(errs, fmt.Errorf("spawns_per_minute must be positive, or -1 for unlimited, got %g", c.SpawnsPerMinute))
+	}
+	if c.SpawnBurst < 0 {
+		errs = append(errs, fmt.Errorf("spawn_burst must not be negative, got %d", c.SpawnBurst))
+	}
+	if c.LogLevel != "" && !validLogLevel(c.LogLevel) {
+		errs = append(errs, fmt.Errorf("log_level must be one of %v, got %q", LogLevels, c.LogLevel))
+	}
+	return errors.Join(errs...)
+}
+
+func validLogLevel(level string) bool {
+	for _, l := range LogLevels {
+		if level == l {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// clearConfigEnv unsets every variable Load reads so the host environment
+// can't leak into a test
+func clearConfigEnv(t *testing.T) {
+	t.Helper()
+	for _, env := range []string{FileEnv, MaxWorkersEnv, SpawnsPerMinuteEnv, SpawnBurstEnv, LogLevelEnv} {
+		t.Setenv(env, "")
+	}
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFile(t *testing.T) {
+	clearConfigEnv(t)
+	path := writeConfig(t, `{"max_workers": 4, "spawns_per_minute": 2.5, "log_level": "debug"}`)
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() failed: %v", err)
+	}
+	want := Config{MaxWorkers: 4, SpawnsPerMinute: 2.5, LogLevel: "debug"}
+	if *cfg != want {
+		t.Errorf("LoadFile() = %+v, want %+v", *cfg, want)
+	}
+}
+
+func TestLoadFileAbsent(t *testing.T) {
+	clearConfigEnv(t)
+
+	cfg, err := LoadFile(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatalf("LoadFile() failed: %v", err)
+	}
+	if *cfg != (Config{}) {
+		t.Errorf("LoadFile() = %+v, want the zero Config", *cfg)
+	}
+}
+
+func TestLoadFileEnvOverrides(t *testing.T) {
+	clearConfigEnv(t)
+	path := writeConfig(t, `{"max_workers": 4, "spawn_burst": 3}`)
+	t.Setenv(MaxWorkersEnv, "-1")
+	t.Setenv(SpawnsPerMinuteEnv, "6")
+	t.Setenv(LogLevelEnv, "warn")
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() failed: %v", err)
+	}
+	want := Config{MaxWorkers: -1, SpawnsPerMinute: 6, SpawnBurst: 3, LogLevel: "warn"}
+	if *cfg != want {
+		t.Errorf("LoadFile() = %+v, want %+v", *cfg, want)
+	}
+
+	t.Setenv(SpawnBurstEnv, "lots")
+	if _, err := LoadFile(path); err == nil || !strings.Contains(err.Error(), SpawnBurstEnv) {
+		t.Errorf("LoadFile() error = %v, want one naming %s", err, SpawnBurstEnv)
+	}
+}
+
+func TestLoadFileInvalid(t *testing.T) {
+	clearConfigEnv(t)
+
+	if _, err := LoadFile(writeConfig(t, `{"max_workers": `)); err == nil {
+		t.Error("LoadFile() should fail on malformed JSON")
+	}
+
+	_, err := LoadFile(writeConfig(t, `{"max_workers": -5, "spawn_burst": -1, "log_level": "chatty"}`))
+	if err == nil {
+		t.Fatal("LoadFile() should fail validation")
+	}
+	for _, field := range []string{"max_workers", "spawn_burst", "log_level"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("error %q does not mention %s", err, field)
+		}
+	}
+}
+
+func TestLoadUsesDefaultConfigFile(t *testing.T) {
+	clearConfigEnv(t)
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	dir := filepath.Join(configHome, "multiclaude")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"max_workers": 2}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if cfg.MaxWorkers != 2 {
+		t.Errorf("MaxWorkers = %d, want 2", cfg.MaxWorkers)
+	}
+
+	t.Setenv(FileEnv, writeConfig(t, `{"max_workers": 7}`))
+	if cfg, err = Load(); err != nil || cfg.MaxWorkers != 7 {
+		t.Errorf("Load() with %s = %+v, %v, want max_workers 7", FileEnv, cfg, err)
+	}
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Layout selects where multiclaude keeps its files
+type Layout string
+
+const (
+	// LayoutLegacy keeps everything under a single ~/.multiclaude root
+	LayoutLegacy Layout = "legacy"
+	// LayoutXDG follows the XDG Base Directory specification
+	LayoutXDG Layout = "xdg"
+)
+
+// LayoutEnv is the environment variable DefaultPaths reads the layout from
+const LayoutEnv = "MULTICLAUDE_LAYOUT"
+
+// PathsForLayout returns the paths for layout. An empty layout is legacy.
+func PathsForLayout(layout Layout) (*Paths, error) {
+	switch layout {
+	case "", LayoutLegacy:
+		return legacyPaths()
+	case LayoutXDG:
+		return XDGPaths()
+	default:
+		return nil, fmt.Errorf("unknown %s %q (want %q or %q)", LayoutEnv, layout, LayoutLegacy, LayoutXDG)
+	}
+}
+
+// XDGPaths returns paths following the XDG Base Directory specification:
+//   - state, logs, repositories and worktrees under $XDG_STATE_HOME/multiclaude
+//   - socket tokens and agent Claude configs under $XDG_CONFIG_HOME/multiclaude
+//   - generated prompts under $XDG_CACHE_HOME/multiclaude
+//   - the daemon socket under $XDG_RUNTIME_DIR/multiclaude
+//
+// Unset or relative variables fall back to ~/.local/state, ~/.config and
+// ~/.cache as the specification requires. With no runtime directory the
+// socket lives in the state directory.
+func XDGPaths() (*Paths, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	state := filepath.Join(xdgDir("XDG_STATE_HOME", filepath.Join(home, ".local", "state")), "multiclaude")
+	conf := filepath.Join(xdgDir("XDG_CONFIG_HOME", filepath.Join(home, ".config")), "multiclaude")
+	cache := filepath.Join(xdgDir("XDG_CACHE_HOME", filepath.Join(home, ".cache")), "multiclaude")
+	runtime := state
+	if dir := xdgDir("XDG_RUNTIME_DIR", ""); dir != "" {
+		runtime = filepath.Join(dir, "multiclaude")
+	}
+
+	return &Paths{
+		Root:            state,
+		DaemonPID:       filepath.Join(state, "daemon.pid"),
+		DaemonSock:      filepath.Join(runtime, "daemon.sock"),
+		DaemonLog:       filepath.Join(state, "daemon.log"),
+		DaemonMeta:      filepath.Join(state, "daemon-meta.json"),
+		StateFile:       filepath.Join(state, "state.json"),
+		MetricsFile:     filepath.Join(state, "metrics.json"),
+		HistoryFile:     filepath.Join(state, "commands.jsonl"),
+		TokensFile:      filepath.Join(conf, "tokens.json"),
+		ReposDir:        filepath.Join(state, "repos"),
+		WorktreesDir:    filepath.Join(state, "wts"),
+		MessagesDir:     filepath.Join(state, "messages"),
+		OutputDir:       filepath.Join(state, "output"),
+		ClaudeConfigDir: filepath.Join(conf, "claude-config"),
+		ArchiveDir:      filepath.Join(state, "archive"),
+		CacheDir:        cache,
+	}, nil
+}
+
+// xdgDir returns the value of the XDG variable env, or fallback if it is
+// unset or not absolute
+func xdgDir(env, fallback string) string {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return dir
+	}
+	return fallback
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestXDGPaths(t *testing.T) {
+	base := t.TempDir()
+	t.Setenv("HOME", filepath.Join(base, "home"))
+	t.Setenv("XDG_STATE_HOME", filepath.Join(base, "state"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(base, "config"))
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(base, "cache"))
+	t.Setenv("XDG_RUNTIME_DIR", filepath.Join(base, "run"))
+
+	paths, err := XDGPaths()
+	if err != nil {
+		t.Fatalf("XDGPaths() failed: %v", err)
+	}
+
+	state := filepath.Join(base, "state", "multiclaude")
+	conf := filepath.Join(base, "config", "multiclaude")
+	tests := map[string]string{
+		paths.Root:            state,
+		paths.StateFile:       filepath.Join(state, "state.json"),
+		paths.DaemonLog:       filepath.Join(state, "daemon.log"),
+		paths.DaemonPID:       filepath.Join(state, "daemon.pid"),
+		paths.WorktreesDir:    filepath.Join(state, "wts"),
+		paths.OutputDir:       filepath.Join(state, "output"),
+		paths.TokensFile:      filepath.Join(conf, "tokens.json"),
+		paths.ClaudeConfigDir: filepath.Join(conf, "claude-config"),
+		paths.PromptsDir():    filepath.Join(base, "cache", "multiclaude", "prompts"),
+		paths.DaemonSock:      filepath.Join(base, "run", "multiclaude", "daemon.sock"),
+	}
+	for got, want := range tests {
+		if got != want {
+			t.Errorf("path = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestXDGPathsDefaults(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", "")
+	t.Setenv("XDG_CONFIG_HOME", "relative/config") // ignored, per the spec
+	t.Setenv("XDG_CACHE_HOME", "")
+	t.Setenv("XDG_RUNTIME_DIR", "")
+
+	paths, err := XDGPaths()
+	if err != nil {
+		t.Fatalf("XDGPaths() failed: %v", err)
+	}
+
+	state := filepath.Join(home, ".local", "state", "multiclaude")
+	if paths.StateFile != filepath.Join(state, "state.json") {
+		t.Errorf("StateFile = %q, want under %s", paths.StateFile, state)
+	}
+	if want := filepath.Join(home, ".config", "multiclaude", "tokens.json"); paths.TokensFile != want {
+		t.Errorf("TokensFile = %q, want %q", paths.TokensFile, want)
+	}
+	if want := filepath.Join(home, ".cache", "multiclaude", "prompts"); paths.PromptsDir() != want {
+		t.Errorf("PromptsDir() = %q, want %q", paths.PromptsDir(), want)
+	}
+	if want := filepath.Join(state, "daemon.sock"); paths.DaemonSock != want {
+		t.Errorf("DaemonSock = %q, want %q without a runtime dir", paths.DaemonSock, want)
+	}
+}
+
+func TestDefaultPathsLayout(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, "state"))
+
+	t.Setenv(LayoutEnv, "")
+	paths, err := DefaultPaths()
+	if err != nil {
+		t.Fatalf("DefaultPaths() failed: %v", err)
+	}
+	root := filepath.Join(home, ".multiclaude")
+	if paths.Root != root || paths.PromptsDir() != filepath.Join(root, "prompts") {
+		t.Errorf("legacy layout: Root = %q, PromptsDir() = %q, want everything under %s", paths.Root, paths.PromptsDir(), root)
+	}
+
+	t.Setenv(LayoutEnv, string(LayoutXDG))
+	paths, err = DefaultPaths()
+	if err != nil {
+		t.Fatalf("DefaultPaths() failed: %v", err)
+	}
+	if want := filepath.Join(home, "state", "multiclaude"); paths.Root != want {
+		t.Errorf("xdg layout: Root = %q, want %q", paths.Root, want)
+	}
+
+	t.Setenv(LayoutEnv, "flat")
+	if _, err := DefaultPaths(); err == nil || !strings.Contains(err.Error(), "flat") {
+		t.Errorf("DefaultPaths() error = %v, want unknown layout", err)
+	}
+}
+
+func TestEnsureDirectoriesCreatesSocketDir(t *testing.T) {
+	base := t.TempDir()
+	paths := NewTestPaths(filepath.Join(base, "state"))
+	paths.DaemonSock = filepath.Join(base, "run", "daemon.sock")
+	paths.CacheDir = filepath.Join(base, "cache")
+
+	if err := paths.EnsureDirectories(); err != nil {
+		t.Fatalf("EnsureDirectories() failed: %v", err)
+	}
+	for _, dir := range []string{filepath.Join(base, "run"), paths.CacheDir} {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			t.Errorf("Directory not created: %s", dir)
+		}
+	}
+}
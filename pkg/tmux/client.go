@@ -42,11 +42,18 @@
 //	if tmux.IsSessionNotFound(err) {
 //	    // Handle missing session
 //	}
+//
+//	// Every method reports a missing tmux binary the same way:
+//	if tmux.IsNotInstalled(err) {
+//	    // Ask the user to install tmux
+//	}
 package tmux
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os/exec"
 	"strings"
 )
@@ -87,7 +94,8 @@ func (c *Client) tmuxCmd(ctx context.Context, args ...string) *exec.Cmd {
 
 // wrapCommandError wraps an error from a tmux command, checking for context cancellation first.
 // If err is nil, returns nil. If context is cancelled, returns context error.
-// Otherwise, wraps in CommandError with the given operation and target information.
+// If the tmux binary is missing, returns a NotInstalledError. Otherwise, wraps
+// in CommandError with the given operation and target information.
 func (c *Client) wrapCommandError(ctx context.Context, err error, op, session, window string) error {
 	if err == nil {
 		return nil
@@ -95,6 +103,10 @@ func (c *Client) wrapCommandError(ctx context.Context, err error, op, session, w
 	if ctx.Err() != nil {
 		return ctx.Err()
 	}
+	// A bare name is looked up in PATH; an explicit path is opened directly
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+		return &NotInstalledError{Path: c.tmuxPath}
+	}
 	return &CommandError{
 		Op:      op,
 		Session: session,
@@ -128,7 +140,7 @@ func (c *Client) HasSession(ctx context.Context, name string) (bool, error) {
 				return false, nil
 			}
 		}
-		return false, &CommandError{Op: "has-session", Session: name, Err: err}
+		return false, c.wrapCommandError(ctx, err, "has-session", name, "")
 	}
 	return true, nil
 }
@@ -151,6 +163,12 @@ func (c *Client) KillSession(ctx context.Context, name string) error {
 	return c.wrapCommandError(ctx, cmd.Run(), "kill-session", name, "")
 }
 
+// RenameSession gives a tmux session a new name.
+func (c *Client) RenameSession(ctx context.Context, name, newName string) error {
+	cmd := c.tmuxCmd(ctx, "rename-session", "-t", name, newName)
+	return c.wrapCommandError(ctx, cmd.Run(), "rename-session", name, "")
+}
+
 // ListSessions returns a list of all tmux session names.
 func (c *Client) ListSessions(ctx context.Context) ([]string, error) {
 	cmd := c.tmuxCmd(ctx, "list-sessions", "-F", "#{session_name}")
@@ -165,7 +183,7 @@ func (c *Client) ListSessions(ctx context.Context) ([]string, error) {
 				return []string{}, nil
 			}
 		}
-		return nil, &CommandError{Op: "list-sessions", Err: err}
+		return nil, c.wrapCommandError(ctx, err, "list-sessions", "", "")
 	}
 
 	sessions := strings.Split(strings.TrimSpace(string(output)), "\n")
@@ -175,6 +193,35 @@ func (c *Client) ListSessions(ctx context.Context) ([]string, error) {
 	return sessions, nil
 }
 
+// SetEnvironment sets a variable in a session's environment. Windows
+// created in the session afterwards inherit it.
+func (c *Client) SetEnvironment(ctx context.Context, session, name, value string) error {
+	cmd := c.tmuxCmd(ctx, "set-environment", "-t", session, name, value)
+	return c.wrapCommandError(ctx, cmd.Run(), "set-environment", session, "")
+}
+
+// GetEnvironment returns a variable from a session's environment, or an
+// empty string if the session does not set it.
+func (c *Client) GetEnvironment(ctx context.Context, session, name string) (string, error) {
+	cmd := c.tmuxCmd(ctx, "show-environment", "-t", session, name)
+	output, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if exitErr, ok := err.(*exec.ExitError); ok && strings.Contains(string(exitErr.Stderr), "unknown variable") {
+			return "", nil
+		}
+		return "", c.wrapCommandError(ctx, err, "show-environment", session, "")
+	}
+
+	// A variable removed with set-environment -r is shown as "-NAME"
+	if value, ok := strings.CutPrefix(strings.TrimSpace(string(output)), name+"="); ok {
+		return value, nil
+	}
+	return "", nil
+}
+
 // =============================================================================
 // Window Management
 // =============================================================================
@@ -186,6 +233,15 @@ func (c *Client) CreateWindow(ctx context.Context, session, windowName string) e
 	return c.wrapCommandError(ctx, cmd.Run(), "new-window", session, windowName)
 }
 
+// CreateWindowWithCommand creates a new window in the specified session that
+// runs command instead of the default shell. The window closes when the
+// command exits.
+func (c *Client) CreateWindowWithCommand(ctx context.Context, session, windowName, command string) error {
+	target := fmt.Sprintf("%s:", session)
+	cmd := c.tmuxCmd(ctx, "new-window", "-t", target, "-n", windowName, command)
+	return c.wrapCommandError(ctx, cmd.Run(), "new-window", session, windowName)
+}
+
 // HasWindow checks if a window with the given name exists in the session.
 // Uses exact matching via tmux format strings.
 func (c *Client) HasWindow(ctx context.Context, session, windowName string) (bool, error) {
@@ -193,10 +249,7 @@ func (c *Client) HasWindow(ctx context.Context, session, windowName string) (boo
 	cmd := c.tmuxCmd(ctx, "list-windows", "-t", session, "-F", "#{window_name}")
 	output, err := cmd.Output()
 	if err != nil {
-		if ctx.Err() != nil {
-			return false, ctx.Err()
-		}
-		return false, &CommandError{Op: "list-windows", Session: session, Err: err}
+		return false, c.wrapCommandError(ctx, err, "list-windows", session, "")
 	}
 
 	// Check for exact match
@@ -221,10 +274,7 @@ func (c *Client) ListWindows(ctx context.Context, session string) ([]string, err
 	cmd := c.tmuxCmd(ctx, "list-windows", "-t", session, "-F", "#{window_name}")
 	output, err := cmd.Output()
 	if err != nil {
-		if ctx.Err() != nil {
-			return nil, ctx.Err()
-		}
-		return nil, &CommandError{Op: "list-windows", Session: session, Err: err}
+		return nil, c.wrapCommandError(ctx, err, "list-windows", session, "")
 	}
 
 	windows := strings.Split(strings.TrimSpace(string(output)), "\n")
@@ -244,10 +294,7 @@ func (c *Client) SendKeys(ctx context.Context, session, windowName, text string)
 	target := fmt.Sprintf("%s:%s", session, windowName)
 	cmd := c.tmuxCmd(ctx, "send-keys", "-t", target, text, "C-m")
 	if err := cmd.Run(); err != nil {
-		if ctx.Err() != nil {
-			return ctx.Err()
-		}
-		return &CommandError{Op: "send-keys", Session: session, Window: windowName, Err: err}
+		return c.wrapCommandError(ctx, err, "send-keys", session, windowName)
 	}
 	return nil
 }
@@ -269,19 +316,13 @@ func (c *Client) SendKeysLiteral(ctx context.Context, session, windowName, text
 		// Set the buffer with the text
 		setCmd := c.tmuxCmd(ctx, "set-buffer", text)
 		if err := setCmd.Run(); err != nil {
-			if ctx.Err() != nil {
-				return ctx.Err()
-			}
-			return &CommandError{Op: "set-buffer", Session: session, Window: windowName, Err: err}
+			return c.wrapCommandError(ctx, err, "set-buffer", session, windowName)
 		}
 
 		// Paste the buffer to the target
 		pasteCmd := c.tmuxCmd(ctx, "paste-buffer", "-t", target)
 		if err := pasteCmd.Run(); err != nil {
-			if ctx.Err() != nil {
-				return ctx.Err()
-			}
-			return &CommandError{Op: "paste-buffer", Session: session, Window: windowName, Err: err}
+			return c.wrapCommandError(ctx, err, "paste-buffer", session, windowName)
 		}
 		return nil
 	}
@@ -289,10 +330,7 @@ func (c *Client) SendKeysLiteral(ctx context.Context, session, windowName, text
 	// No newlines, send the text using send-keys with literal mode
 	cmd := c.tmuxCmd(ctx, "send-keys", "-t", target, "-l", text)
 	if err := cmd.Run(); err != nil {
-		if ctx.Err() != nil {
-			return ctx.Err()
-		}
-		return &CommandError{Op: "send-keys", Session: session, Window: windowName, Err: err}
+		return c.wrapCommandError(ctx, err, "send-keys", session, windowName)
 	}
 	return nil
 }
@@ -304,10 +342,7 @@ func (c *Client) SendEnter(ctx context.Context, session, windowName string) erro
 	target := fmt.Sprintf("%s:%s", session, windowName)
 	cmd := c.tmuxCmd(ctx, "send-keys", "-t", target, "C-m")
 	if err := cmd.Run(); err != nil {
-		if ctx.Err() != nil {
-			return ctx.Err()
-		}
-		return &CommandError{Op: "send-keys", Session: session, Window: windowName, Err: err}
+		return c.wrapCommandError(ctx, err, "send-keys", session, windowName)
 	}
 	return nil
 }
@@ -326,10 +361,7 @@ func (c *Client) SendKeysLiteralWithEnter(ctx context.Context, session, windowNa
 		c.tmuxPath, c.tmuxPath, target, c.tmuxPath, target)
 	cmd := exec.CommandContext(ctx, "sh", "-c", cmdStr, "sh", text)
 	if err := cmd.Run(); err != nil {
-		if ctx.Err() != nil {
-			return ctx.Err()
-		}
-		return &CommandError{Op: "send-keys-atomic", Session: session, Window: windowName, Err: err}
+		return c.wrapCommandError(ctx, err, "send-keys-atomic", session, windowName)
 	}
 	return nil
 }
@@ -345,10 +377,7 @@ func (c *Client) GetPanePID(ctx context.Context, session, windowName string) (in
 	cmd := c.tmuxCmd(ctx, "display-message", "-t", target, "-p", "#{pane_pid}")
 	output, err := cmd.Output()
 	if err != nil {
-		if ctx.Err() != nil {
-			return 0, ctx.Err()
-		}
-		return 0, &CommandError{Op: "display-message", Session: session, Window: windowName, Err: err}
+		return 0, c.wrapCommandError(ctx, err, "display-message", session, windowName)
 	}
 
 	var pid int
@@ -363,6 +392,19 @@ func (c *Client) GetPanePID(ctx context.Context, session, windowName string) (in
 // Output Capture - Third Differentiator
 // =============================================================================
 
+// CapturePane returns the text currently visible in the first pane of a
+// window, with trailing blank lines removed. Unlike pipe-pane it needs no
+// setup, so it suits one-off checks of what an agent is showing.
+func (c *Client) CapturePane(ctx context.Context, session, windowName string) (string, error) {
+	target := fmt.Sprintf("%s:%s", session, windowName)
+	cmd := c.tmuxCmd(ctx, "capture-pane", "-p", "-t", target)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", c.wrapCommandError(ctx, err, "capture-pane", session, windowName)
+	}
+	return strings.TrimRight(string(output), "\n"), nil
+}
+
 // StartPipePane starts capturing pane output to a file.
 // The output is appended to the file, so it persists across restarts.
 //
@@ -372,15 +414,19 @@ func (c *Client) GetPanePID(ctx context.Context, session, windowName string) (in
 //	// ... run commands in the pane ...
 //	client.StopPipePane(ctx, "my-session", "my-window")
 func (c *Client) StartPipePane(ctx context.Context, session, windowName, outputFile string) error {
+	// cat >> appends to the file so output is preserved
+	return c.PipePane(ctx, session, windowName, fmt.Sprintf("cat >> '%s'", outputFile))
+}
+
+// PipePane starts piping pane output to the standard input of a shell
+// command, unless the pane is already piped. Use it instead of
+// StartPipePane when the output needs processing, such as log rotation.
+func (c *Client) PipePane(ctx context.Context, session, windowName, command string) error {
 	target := fmt.Sprintf("%s:%s", session, windowName)
 	// Use -o to open a pipe (output only, not input)
-	// cat >> appends to the file so output is preserved
-	cmd := c.tmuxCmd(ctx, "pipe-pane", "-o", "-t", target, fmt.Sprintf("cat >> '%s'", outputFile))
+	cmd := c.tmuxCmd(ctx, "pipe-pane", "-o", "-t", target, command)
 	if err := cmd.Run(); err != nil {
-		if ctx.Err() != nil {
-			return ctx.Err()
-		}
-		return &CommandError{Op: "pipe-pane", Session: session, Window: windowName, Err: err}
+		return c.wrapCommandError(ctx, err, "pipe-pane", session, windowName)
 	}
 	return nil
 }
@@ -2,9 +2,11 @@ package tmux
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -394,6 +396,36 @@ func TestKillSession(t *testing.T) {
 	}
 }
 
+func TestSessionEnvironment(t *testing.T) {
+	ctx := context.Background()
+	client := NewClient()
+	sessionName := createTestSessionOrSkip(t, ctx, client)
+	defer client.KillSession(ctx, sessionName)
+
+	value, err := client.GetEnvironment(ctx, sessionName, "MC_TEST_VAR")
+	if err != nil {
+		t.Fatalf("GetEnvironment of unset variable failed: %v", err)
+	}
+	if value != "" {
+		t.Errorf("unset variable = %q, want empty", value)
+	}
+
+	if err := client.SetEnvironment(ctx, sessionName, "MC_TEST_VAR", "/tmp/a b"); err != nil {
+		t.Fatalf("SetEnvironment failed: %v", err)
+	}
+	value, err = client.GetEnvironment(ctx, sessionName, "MC_TEST_VAR")
+	if err != nil {
+		t.Fatalf("GetEnvironment failed: %v", err)
+	}
+	if value != "/tmp/a b" {
+		t.Errorf("variable = %q, want %q", value, "/tmp/a b")
+	}
+
+	if _, err := client.GetEnvironment(ctx, "mc-test-no-such-session", "MC_TEST_VAR"); err == nil {
+		t.Error("GetEnvironment on a missing session should fail")
+	}
+}
+
 func TestSendKeys(t *testing.T) {
 	ctx := context.Background()
 	client := NewClient()
@@ -1273,3 +1305,99 @@ func TestStopPipePaneContextCancellation(t *testing.T) {
 		t.Errorf("Expected context.Canceled, got %v", err)
 	}
 }
+
+func TestCapturePane(t *testing.T) {
+	ctx := context.Background()
+	client := NewClient()
+	sessionName := createTestSessionOrSkip(t, ctx, client)
+	defer client.KillSession(ctx, sessionName)
+
+	// Run the command directly rather than typing it into a shell, which
+	// may not be ready for input yet. The output differs from the command
+	// line so only the output can match.
+	windowName := "capture-window"
+	if err := client.CreateWindowWithCommand(ctx, sessionName, windowName, "printf 'captured-%d\\n' 42; sleep 30"); err != nil {
+		t.Fatalf("Failed to create window: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	var output string
+	for time.Now().Before(deadline) {
+		var err error
+		output, err = client.CapturePane(ctx, sessionName, windowName)
+		if err != nil {
+			t.Fatalf("CapturePane() failed: %v", err)
+		}
+		if strings.Contains(output, "captured-42") {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if !strings.Contains(output, "captured-42") {
+		t.Errorf("CapturePane() = %q, want it to contain the command output", output)
+	}
+	if strings.HasSuffix(output, "\n") {
+		t.Error("CapturePane() should trim trailing blank lines")
+	}
+
+	if _, err := client.CapturePane(ctx, sessionName, "nonexistent"); err == nil {
+		t.Error("CapturePane() on a missing window should fail")
+	}
+}
+
+func TestCreateWindowWithCommand(t *testing.T) {
+	ctx := context.Background()
+	client := NewClient()
+	sessionName := createTestSessionOrSkip(t, ctx, client)
+	defer client.KillSession(ctx, sessionName)
+
+	windowName := "command-window"
+	if err := client.CreateWindowWithCommand(ctx, sessionName, windowName, "sleep 30"); err != nil {
+		t.Fatalf("CreateWindowWithCommand() failed: %v", err)
+	}
+	exists, err := client.HasWindow(ctx, sessionName, windowName)
+	if err != nil {
+		t.Fatalf("HasWindow failed: %v", err)
+	}
+	if !exists {
+		t.Error("Window should exist while its command runs")
+	}
+
+	if err := client.KillWindow(ctx, sessionName, windowName); err != nil {
+		t.Fatalf("KillWindow() failed: %v", err)
+	}
+}
+
+func TestNotInstalled(t *testing.T) {
+	ctx := context.Background()
+	for name, path := range map[string]string{
+		"not in PATH":      "tmux-does-not-exist",
+		"missing abs path": filepath.Join(t.TempDir(), "tmux"),
+	} {
+		client := NewClient(WithTmuxPath(path))
+		if client.IsTmuxAvailable() {
+			t.Errorf("%s: IsTmuxAvailable() = true", name)
+		}
+
+		_, err := client.HasSession(ctx, "any")
+		if !IsNotInstalled(err) {
+			t.Errorf("%s: HasSession() error = %v, want NotInstalledError", name, err)
+		}
+		var notInstalled *NotInstalledError
+		if !errors.As(err, &notInstalled) || notInstalled.Path != path {
+			t.Errorf("%s: error = %#v, want the binary path %q", name, err, path)
+		}
+
+		if err := client.KillWindow(ctx, "any", "window"); !errors.Is(err, &NotInstalledError{}) {
+			t.Errorf("%s: KillWindow() error = %v, want NotInstalledError", name, err)
+		}
+		if _, err := client.CapturePane(ctx, "any", "window"); !IsNotInstalled(err) {
+			t.Errorf("%s: CapturePane() error = %v, want NotInstalledError", name, err)
+		}
+	}
+
+	// Failures of an installed tmux are not reported as missing tmux
+	if IsNotInstalled(&CommandError{Op: "kill-window", Err: errors.New("exit status 1")}) {
+		t.Error("IsNotInstalled() matched a CommandError")
+	}
+}
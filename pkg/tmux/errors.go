@@ -1,6 +1,9 @@
 package tmux
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 // SessionNotFoundError indicates that a tmux session does not exist.
 type SessionNotFoundError struct {
@@ -33,6 +36,21 @@ func (e *WindowNotFoundError) Is(target error) bool {
 	return ok
 }
 
+// NotInstalledError indicates that the tmux binary could not be found.
+type NotInstalledError struct {
+	Path string // Binary the client tried to run
+}
+
+func (e *NotInstalledError) Error() string {
+	return fmt.Sprintf("tmux is not installed: %s not found", e.Path)
+}
+
+// Is returns true if target is a *NotInstalledError.
+func (e *NotInstalledError) Is(target error) bool {
+	_, ok := target.(*NotInstalledError)
+	return ok
+}
+
 // CommandError wraps errors from tmux command execution with additional context.
 type CommandError struct {
 	Op      string // Operation that failed (e.g., "create-session", "send-keys")
@@ -66,3 +84,8 @@ func IsWindowNotFound(err error) bool {
 	_, ok := err.(*WindowNotFoundError)
 	return ok
 }
+
+// IsNotInstalled returns true if the error indicates tmux is not installed.
+func IsNotInstalled(err error) bool {
+	return errors.Is(err, &NotInstalledError{})
+}